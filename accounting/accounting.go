@@ -0,0 +1,390 @@
+// Package accounting tracks the memory used by the subsystems of a column
+// store against a process level budget.
+//
+// Memory is either reserved and released explicitly by a subsystem, or sampled
+// from a gauge registered with Track for subsystems that already know their
+// size (e.g. the active parts of all tables). When the memory in use reaches
+// the high watermark of the budget, every subsystem asking to be admitted is
+// handled according to its Policy: inserts are blocked until memory is freed,
+// caches are asked to evict entries and queries fail.
+package accounting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ErrLimitExceeded is returned when a subsystem is denied memory because the
+// budget is exhausted.
+var ErrLimitExceeded = errors.New("memory limit exceeded")
+
+// Subsystem identifies a consumer of memory.
+type Subsystem int
+
+const (
+	// ActiveParts is the memory held by the active and pending blocks of
+	// all tables.
+	ActiveParts Subsystem = iota
+	// Query is the memory allocated during query execution.
+	Query
+	// Cache is the memory held by caches.
+	Cache
+	// WAL is the memory held by WAL records waiting to be written.
+	WAL
+
+	numSubsystems
+)
+
+func (s Subsystem) String() string {
+	switch s {
+	case ActiveParts:
+		return "active_parts"
+	case Query:
+		return "query"
+	case Cache:
+		return "cache"
+	case WAL:
+		return "wal"
+	default:
+		return fmt.Sprintf("Subsystem(%d)", int(s))
+	}
+}
+
+// Policy determines what happens when a subsystem asks to be admitted while
+// the memory in use is above the high watermark.
+type Policy int
+
+const (
+	// PolicyIgnore admits the subsystem regardless of the memory in use.
+	PolicyIgnore Policy = iota
+	// PolicyBlock waits until enough memory is freed or the context is
+	// canceled.
+	PolicyBlock
+	// PolicyEvict asks the registered evictors to free memory and fails
+	// with ErrLimitExceeded if they could not free enough.
+	PolicyEvict
+	// PolicyFail fails with ErrLimitExceeded.
+	PolicyFail
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyIgnore:
+		return "ignore"
+	case PolicyBlock:
+		return "block"
+	case PolicyEvict:
+		return "evict"
+	case PolicyFail:
+		return "fail"
+	default:
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+}
+
+// Evictor frees up to the given number of bytes and returns the number of
+// bytes it freed.
+type Evictor func(bytes int64) int64
+
+type registeredEvictor struct {
+	id    uint64
+	evict Evictor
+}
+
+// Accountant tracks the memory used by all subsystems against a limit.
+type Accountant struct {
+	limit        int64
+	watermark    float64
+	pollInterval time.Duration
+	policies     [numSubsystems]Policy
+
+	reserved [numSubsystems]atomic.Int64
+
+	mtx      sync.RWMutex
+	gauges   [numSubsystems]map[uint64]func() int64
+	nextID   uint64
+	evictors []registeredEvictor
+	// released is closed and replaced whenever memory is released, to wake
+	// up blocked subsystems.
+	released chan struct{}
+
+	reg     prometheus.Registerer
+	metrics *metrics
+}
+
+type metrics struct {
+	denied  *prometheus.CounterVec
+	blocked *prometheus.CounterVec
+	evicted prometheus.Counter
+}
+
+type Option func(*Accountant)
+
+// WithPolicy sets the policy of the given subsystem. By default inserts of
+// active parts block, caches evict and queries fail. WAL records are always
+// admitted since they are only written as part of an admitted insert.
+func WithPolicy(s Subsystem, p Policy) Option {
+	return func(a *Accountant) {
+		a.policies[s] = p
+	}
+}
+
+// WithHighWatermark sets the fraction of the limit above which the policies
+// are applied. The default is 0.9.
+func WithHighWatermark(fraction float64) Option {
+	return func(a *Accountant) {
+		a.watermark = fraction
+	}
+}
+
+// WithPollInterval sets how often blocked subsystems check the memory in use.
+// Gauges registered with Track are not notified when memory is freed, so
+// blocked subsystems poll them. The default is 10ms.
+func WithPollInterval(d time.Duration) Option {
+	return func(a *Accountant) {
+		a.pollInterval = d
+	}
+}
+
+// WithRegistry registers the metrics of the accountant with the given
+// registry.
+func WithRegistry(reg prometheus.Registerer) Option {
+	return func(a *Accountant) {
+		a.reg = reg
+	}
+}
+
+// New returns an accountant enforcing the given limit in bytes.
+func New(limit int64, options ...Option) *Accountant {
+	a := &Accountant{
+		limit:        limit,
+		watermark:    0.9,
+		pollInterval: 10 * time.Millisecond,
+		released:     make(chan struct{}),
+		reg:          prometheus.NewRegistry(),
+	}
+	for s := range a.gauges {
+		a.gauges[s] = make(map[uint64]func() int64)
+	}
+	a.policies[ActiveParts] = PolicyBlock
+	a.policies[Query] = PolicyFail
+	a.policies[Cache] = PolicyEvict
+	a.policies[WAL] = PolicyIgnore
+	for _, option := range options {
+		option(a)
+	}
+	a.metrics = newMetrics(a, a.reg)
+	return a
+}
+
+func newMetrics(a *Accountant, reg prometheus.Registerer) *metrics {
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "frostdb_memory_limit_bytes",
+		Help: "The memory budget enforced by the memory accountant.",
+	}, func() float64 { return float64(a.limit) })
+	for s := Subsystem(0); s < numSubsystems; s++ {
+		s := s
+		promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "frostdb_memory_used_bytes",
+			Help:        "The memory in use by each subsystem.",
+			ConstLabels: prometheus.Labels{"subsystem": s.String()},
+		}, func() float64 { return float64(a.UsedBy(s)) })
+	}
+	return &metrics{
+		denied: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "frostdb_memory_denied_total",
+			Help: "Number of times a subsystem was denied memory.",
+		}, []string{"subsystem"}),
+		blocked: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "frostdb_memory_blocked_total",
+			Help: "Number of times a subsystem was blocked waiting for memory.",
+		}, []string{"subsystem"}),
+		evicted: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "frostdb_memory_evicted_bytes_total",
+			Help: "Number of bytes freed by evictors.",
+		}),
+	}
+}
+
+// Limit returns the limit in bytes.
+func (a *Accountant) Limit() int64 {
+	return a.limit
+}
+
+// Used returns the memory in use by all subsystems.
+func (a *Accountant) Used() int64 {
+	var used int64
+	for s := Subsystem(0); s < numSubsystems; s++ {
+		used += a.UsedBy(s)
+	}
+	return used
+}
+
+// UsedBy returns the memory in use by the given subsystem.
+func (a *Accountant) UsedBy(s Subsystem) int64 {
+	used := a.reserved[s].Load()
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	for _, g := range a.gauges[s] {
+		used += g()
+	}
+	return used
+}
+
+// Track registers a gauge reporting memory used by the given subsystem. The
+// returned function unregisters it.
+func (a *Accountant) Track(s Subsystem, gauge func() int64) func() {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	id := a.nextID
+	a.nextID++
+	a.gauges[s][id] = gauge
+	return func() {
+		a.mtx.Lock()
+		defer a.mtx.Unlock()
+		delete(a.gauges[s], id)
+		a.notify()
+	}
+}
+
+// RegisterEvictor registers an evictor called when a subsystem with the
+// PolicyEvict policy needs memory or memory is reclaimed, see Reclaim.
+// Evictors are called in the order they were registered. The returned
+// function unregisters it.
+func (a *Accountant) RegisterEvictor(e Evictor) func() {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	id := a.nextID
+	a.nextID++
+	a.evictors = append(a.evictors, registeredEvictor{id: id, evict: e})
+	return func() {
+		a.mtx.Lock()
+		defer a.mtx.Unlock()
+		for i, r := range a.evictors {
+			if r.id == id {
+				a.evictors = append(a.evictors[:i:i], a.evictors[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Reclaim asks the registered evictors to free memory until the memory in use
+// is below the high watermark, and returns the number of bytes they freed. It
+// is meant to be called periodically, so that memory is reclaimed before
+// subsystems are denied memory.
+func (a *Accountant) Reclaim() int64 {
+	if a.limit <= 0 {
+		return 0
+	}
+	return a.evict(0)
+}
+
+// evict asks the registered evictors to free memory until n more bytes can be
+// used without exceeding the high watermark.
+func (a *Accountant) evict(n int64) int64 {
+	a.mtx.RLock()
+	evictors := a.evictors
+	a.mtx.RUnlock()
+	var freed int64
+	for _, e := range evictors {
+		need := a.Used() + n - a.highWatermark()
+		if need <= 0 {
+			break
+		}
+		evicted := e.evict(need)
+		a.metrics.evicted.Add(float64(evicted))
+		freed += evicted
+	}
+	return freed
+}
+
+// Admit applies the policy of the given subsystem if the memory in use is
+// above the high watermark. It returns nil if the subsystem may proceed.
+func (a *Accountant) Admit(ctx context.Context, s Subsystem) error {
+	return a.admit(ctx, s, 0)
+}
+
+// Reserve applies the policy of the given subsystem if reserving n bytes
+// would exceed the high watermark, and reserves them if the subsystem may
+// proceed. Reserved bytes must be released with Release.
+func (a *Accountant) Reserve(ctx context.Context, s Subsystem, n int64) error {
+	if err := a.admit(ctx, s, n); err != nil {
+		return err
+	}
+	a.reserved[s].Add(n)
+	return nil
+}
+
+// Add accounts for n bytes used by the given subsystem without applying any
+// policy. A negative n releases memory.
+func (a *Accountant) Add(s Subsystem, n int64) {
+	a.reserved[s].Add(n)
+	if n < 0 {
+		a.mtx.Lock()
+		a.notify()
+		a.mtx.Unlock()
+	}
+}
+
+// Release releases n bytes reserved by the given subsystem.
+func (a *Accountant) Release(s Subsystem, n int64) {
+	a.Add(s, -n)
+}
+
+// notify wakes up blocked subsystems. The mutex must be held.
+func (a *Accountant) notify() {
+	close(a.released)
+	a.released = make(chan struct{})
+}
+
+func (a *Accountant) highWatermark() int64 {
+	return int64(float64(a.limit) * a.watermark)
+}
+
+func (a *Accountant) admit(ctx context.Context, s Subsystem, n int64) error {
+	if a.limit <= 0 || a.Used()+n <= a.highWatermark() {
+		return nil
+	}
+
+	switch a.policies[s] {
+	case PolicyBlock:
+		a.metrics.blocked.WithLabelValues(s.String()).Inc()
+		ticker := time.NewTicker(a.pollInterval)
+		defer ticker.Stop()
+		for {
+			a.mtx.RLock()
+			released := a.released
+			a.mtx.RUnlock()
+			if a.Used()+n <= a.highWatermark() {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				a.metrics.denied.WithLabelValues(s.String()).Inc()
+				return fmt.Errorf("waiting for %s memory: %w", s, ctx.Err())
+			case <-released:
+			case <-ticker.C:
+			}
+		}
+	case PolicyEvict:
+		a.evict(n)
+		if a.Used()+n <= a.highWatermark() {
+			return nil
+		}
+		a.metrics.denied.WithLabelValues(s.String()).Inc()
+		return fmt.Errorf("%s: %w", s, ErrLimitExceeded)
+	case PolicyFail:
+		a.metrics.denied.WithLabelValues(s.String()).Inc()
+		return fmt.Errorf("%s: %w", s, ErrLimitExceeded)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,102 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountant(t *testing.T) {
+	ctx := context.Background()
+	a := New(100, WithHighWatermark(1))
+
+	require.NoError(t, a.Reserve(ctx, Query, 60))
+	size := int64(30)
+	untrack := a.Track(ActiveParts, func() int64 { return size })
+	require.Equal(t, int64(90), a.Used())
+	require.Equal(t, int64(30), a.UsedBy(ActiveParts))
+
+	// Queries fail.
+	err := a.Reserve(ctx, Query, 20)
+	require.True(t, errors.Is(err, ErrLimitExceeded))
+
+	// Caches evict.
+	a.RegisterEvictor(func(bytes int64) int64 {
+		require.Equal(t, int64(10), bytes)
+		a.Release(Query, bytes)
+		return bytes
+	})
+	require.NoError(t, a.Reserve(ctx, Cache, 20))
+	require.Equal(t, int64(100), a.Used())
+
+	// Inserts block until memory is released.
+	done := make(chan error)
+	go func() {
+		done <- a.Reserve(ctx, ActiveParts, 10)
+	}()
+	select {
+	case <-done:
+		t.Fatal("reserve did not block")
+	case <-time.After(50 * time.Millisecond):
+	}
+	untrack()
+	require.NoError(t, <-done)
+
+	// Blocked inserts return when the context is canceled.
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = a.Reserve(ctx, ActiveParts, 50)
+	require.True(t, errors.Is(err, context.Canceled))
+
+	// WAL records are always admitted.
+	require.NoError(t, a.Reserve(ctx, WAL, 50))
+}
+
+func TestAllocator(t *testing.T) {
+	a := New(100)
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	alloc := NewAllocator(a, Query, mem)
+
+	b := alloc.Allocate(10)
+	require.Equal(t, int64(10), a.UsedBy(Query))
+	b = alloc.Reallocate(20, b)
+	require.Equal(t, int64(20), a.UsedBy(Query))
+	alloc.Free(b)
+	require.Equal(t, int64(0), a.UsedBy(Query))
+}
+
+func TestAccountantReclaim(t *testing.T) {
+	a := New(100, WithHighWatermark(0.5))
+	require.Zero(t, a.Reclaim())
+
+	size := int64(80)
+	a.Track(Cache, func() int64 { return size })
+	var calls []int
+	a.RegisterEvictor(func(bytes int64) int64 {
+		calls = append(calls, 1)
+		size -= 20
+		return 20
+	})
+	unregister := a.RegisterEvictor(func(bytes int64) int64 {
+		calls = append(calls, 2)
+		size -= bytes
+		return bytes
+	})
+
+	// Evictors are called in order until the memory in use is below the
+	// high watermark.
+	require.Equal(t, int64(30), a.Reclaim())
+	require.Equal(t, []int{1, 2}, calls)
+	require.Equal(t, int64(50), a.Used())
+
+	unregister()
+	size = 80
+	calls = nil
+	require.Equal(t, int64(20), a.Reclaim())
+	require.Equal(t, []int{1}, calls)
+}
@@ -0,0 +1,39 @@
+package accounting
+
+import (
+	"github.com/apache/arrow/go/v14/arrow/memory"
+)
+
+// Allocator is an Arrow allocator accounting the memory it allocates to a
+// subsystem. Allocations are never denied since Arrow allocators cannot fail;
+// the accounted memory instead counts against the budget of the other
+// subsystems.
+type Allocator struct {
+	memory.Allocator
+	a *Accountant
+	s Subsystem
+}
+
+// NewAllocator returns an allocator accounting the memory allocated by the
+// given allocator to the given subsystem.
+func NewAllocator(a *Accountant, s Subsystem, allocator memory.Allocator) *Allocator {
+	return &Allocator{Allocator: allocator, a: a, s: s}
+}
+
+func (a *Allocator) Allocate(size int) []byte {
+	b := a.Allocator.Allocate(size)
+	a.a.Add(a.s, int64(len(b)))
+	return b
+}
+
+func (a *Allocator) Reallocate(size int, b []byte) []byte {
+	n := len(b)
+	b = a.Allocator.Reallocate(size, b)
+	a.a.Add(a.s, int64(len(b)-n))
+	return b
+}
+
+func (a *Allocator) Free(b []byte) {
+	a.a.Add(a.s, -int64(len(b)))
+	a.Allocator.Free(b)
+}
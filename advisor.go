@@ -0,0 +1,229 @@
+package frostdb
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// WithSortingAdvisor records the columns used by the filters of the queries
+// executed against each table, so that Table.SortingAdvice can recommend a
+// sorting column order favoring the columns queries filter on. If
+// logInterval is greater than 0, the recommendations that differ from the
+// configured sorting columns are logged at that interval.
+func WithSortingAdvisor(logInterval time.Duration) Option {
+	return func(s *ColumnStore) error {
+		s.sortingAdvisor = true
+		s.sortingAdvisorInterval = logInterval
+		return nil
+	}
+}
+
+// ColumnUsage counts the predicates of the queries executed against a table
+// that filter on a column.
+type ColumnUsage struct {
+	// Column is the name of the schema column. Predicates on the concrete
+	// columns of a dynamic column are counted for the dynamic column.
+	Column string
+	// Equality is the number of equality predicates on the column.
+	Equality int64
+	// Range is the number of <, <=, > and >= predicates on the column.
+	Range int64
+	// Other is the number of any other predicates on the column.
+	Other int64
+}
+
+// Total returns the number of predicates on the column.
+func (u ColumnUsage) Total() int64 {
+	return u.Equality + u.Range + u.Other
+}
+
+// SortingAdvice is a sorting column recommendation for a table.
+type SortingAdvice struct {
+	// Current is the configured sorting column order.
+	Current []string
+	// Recommended is the recommended sorting column order. Columns filtered
+	// on by equality come first as they narrow down the rows to scan the
+	// most, followed by columns filtered on by range, followed by the rest
+	// of the current sorting columns.
+	Recommended []string
+	// Usage is the recorded predicate usage of the columns, sorted by total
+	// number of predicates in descending order.
+	Usage []ColumnUsage
+	// Queries is the number of filtered queries recorded.
+	Queries int64
+}
+
+// Differs returns whether the recommended sorting column order differs from
+// the current one.
+func (a SortingAdvice) Differs() bool {
+	return !slices.Equal(a.Current, a.Recommended)
+}
+
+// predicateUsage records the columns used by query filters.
+type predicateUsage struct {
+	mtx     sync.Mutex
+	queries int64
+	columns map[string]*ColumnUsage
+}
+
+func newPredicateUsage() *predicateUsage {
+	return &predicateUsage{columns: map[string]*ColumnUsage{}}
+}
+
+// recordPredicates records the predicates of the given filter.
+func (t *Table) recordPredicates(filter logicalplan.Expr) {
+	if t.usage == nil || filter == nil {
+		return
+	}
+
+	t.usage.mtx.Lock()
+	defer t.usage.mtx.Unlock()
+	t.usage.queries++
+	t.recordExpr(filter)
+}
+
+func (t *Table) recordExpr(expr logicalplan.Expr) {
+	e, ok := expr.(*logicalplan.BinaryExpr)
+	if !ok {
+		return
+	}
+	switch e.Op {
+	case logicalplan.OpAnd, logicalplan.OpOr:
+		t.recordExpr(e.Left)
+		t.recordExpr(e.Right)
+		return
+	}
+
+	col, ok := e.Left.(*logicalplan.Column)
+	if !ok {
+		return
+	}
+	name, ok := t.schemaColumnName(col.ColumnName)
+	if !ok {
+		return
+	}
+	u, ok := t.usage.columns[name]
+	if !ok {
+		u = &ColumnUsage{Column: name}
+		t.usage.columns[name] = u
+	}
+	switch e.Op {
+	case logicalplan.OpEq:
+		u.Equality++
+	case logicalplan.OpLt, logicalplan.OpLtEq, logicalplan.OpGt, logicalplan.OpGtEq:
+		u.Range++
+	default:
+		u.Other++
+	}
+}
+
+// schemaColumnName returns the name of the schema column the given column
+// belongs to.
+func (t *Table) schemaColumnName(name string) (string, bool) {
+	if _, ok := t.schema.ColumnByName(name); ok {
+		return name, true
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if def, ok := t.schema.ColumnByName(name[:i]); ok && def.Dynamic {
+			return def.Name, true
+		}
+	}
+	return "", false
+}
+
+// SortingAdvice returns a sorting column recommendation based on the filters
+// of the queries executed against the table. The recommendation matches the
+// current sorting columns unless the column store was created with
+// WithSortingAdvisor.
+func (t *Table) SortingAdvice() SortingAdvice {
+	defs := t.schema.SortingColumns()
+	advice := SortingAdvice{
+		Current: make([]string, 0, len(defs)),
+	}
+	for _, def := range defs {
+		advice.Current = append(advice.Current, def.Name)
+	}
+	if t.usage == nil {
+		advice.Recommended = advice.Current
+		return advice
+	}
+
+	t.usage.mtx.Lock()
+	advice.Queries = t.usage.queries
+	advice.Usage = make([]ColumnUsage, 0, len(t.usage.columns))
+	for _, u := range t.usage.columns {
+		advice.Usage = append(advice.Usage, *u)
+	}
+	t.usage.mtx.Unlock()
+
+	sort.Slice(advice.Usage, func(i, j int) bool {
+		if advice.Usage[i].Total() != advice.Usage[j].Total() {
+			return advice.Usage[i].Total() > advice.Usage[j].Total()
+		}
+		return advice.Usage[i].Column < advice.Usage[j].Column
+	})
+
+	byCount := func(count func(ColumnUsage) int64) []string {
+		usage := slices.Clone(advice.Usage)
+		sort.SliceStable(usage, func(i, j int) bool {
+			return count(usage[i]) > count(usage[j])
+		})
+		names := []string{}
+		for _, u := range usage {
+			if count(u) > 0 {
+				names = append(names, u.Column)
+			}
+		}
+		return names
+	}
+
+	seen := map[string]struct{}{}
+	add := func(names ...string) {
+		for _, name := range names {
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			advice.Recommended = append(advice.Recommended, name)
+		}
+	}
+	add(byCount(func(u ColumnUsage) int64 { return u.Equality })...)
+	add(byCount(func(u ColumnUsage) int64 { return u.Range })...)
+	add(advice.Current...)
+	return advice
+}
+
+// logSortingAdvice periodically logs the sorting column recommendations of
+// the tables of the database that differ from their configuration.
+func (db *DB) logSortingAdvice(ctx context.Context, interval time.Duration) {
+	ticker := db.columnStore.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			for _, table := range db.tablesSnapshot() {
+				advice := table.SortingAdvice()
+				if advice.Queries == 0 || !advice.Differs() {
+					continue
+				}
+				level.Info(db.logger).Log(
+					"msg", "sorting columns do not match query filters",
+					"table", table.name,
+					"current", strings.Join(advice.Current, ","),
+					"recommended", strings.Join(advice.Recommended, ","),
+					"queries", advice.Queries,
+				)
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package frostdb
 
 import (
 	"context"
+	"math"
 	"math/rand"
 	"sort"
 	"strconv"
@@ -508,3 +509,268 @@ func Test_Aggregation_DynCol(t *testing.T) {
 		})
 	require.NoError(t, err)
 }
+
+func TestHistogramAggregation(t *testing.T) {
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	for i := 1; i <= 10; i++ {
+		exampleType := "cpu"
+		if i%2 == 0 {
+			exampleType = "memory"
+		}
+		r, err := dynparquet.Samples{{
+			ExampleType: exampleType,
+			Labels:      map[string]string{"node": "test"},
+			Timestamp:   int64(i),
+			Value:       int64(i),
+		}}.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(context.Background(), r)
+		r.Release()
+		require.NoError(t, err)
+	}
+
+	histogram := logicalplan.Histogram(logicalplan.Col("value"), 2, 5)
+	require.Equal(t, "histogram(value, [2, 5])", histogram.Name())
+
+	engine := query.NewEngine(memory.NewGoAllocator(), db.TableProvider())
+	results := map[string][]int64{}
+	require.NoError(t, engine.ScanTable("test").
+		Aggregate(
+			[]logicalplan.Expr{histogram},
+			[]logicalplan.Expr{logicalplan.Col("example_type")},
+		).Execute(context.Background(), func(_ context.Context, r arrow.Record) error {
+		exampleTypes := r.Column(r.Schema().FieldIndices("example_type")[0])
+		counts := r.Column(r.Schema().FieldIndices(histogram.Name())[0]).(*array.List)
+		for i := 0; i < int(r.NumRows()); i++ {
+			start, end := counts.ValueOffsets(i)
+			values := array.NewSlice(counts.ListValues(), start, end)
+			var exampleType string
+			switch arr := exampleTypes.(type) {
+			case *array.Dictionary:
+				exampleType = string(arr.Dictionary().(*array.Binary).Value(arr.GetValueIndex(i)))
+			default:
+				exampleType = string(arr.(*array.Binary).Value(i))
+			}
+			results[exampleType] = values.(*array.Int64).Int64Values()
+			values.Release()
+		}
+		return nil
+	}))
+
+	// Buckets count the values <= 2, in (2, 5] and > 5.
+	require.Equal(t, map[string][]int64{
+		"cpu":    {1, 2, 2}, // 1, 3, 5, 7, 9
+		"memory": {1, 1, 3}, // 2, 4, 6, 8, 10
+	}, results)
+
+	err = engine.ScanTable("test").
+		Aggregate(
+			[]logicalplan.Expr{logicalplan.Histogram(logicalplan.Col("value"), 5, 2)},
+			[]logicalplan.Expr{logicalplan.Col("example_type")},
+		).Execute(context.Background(), func(context.Context, arrow.Record) error { return nil })
+	require.ErrorContains(t, err, "strictly increasing")
+}
+
+func TestFirstLastAggregation(t *testing.T) {
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	// Samples are inserted out of order of their timestamps.
+	for _, ts := range []int64{3, 1, 4, 10, 5, 9, 2, 6, 8, 7} {
+		exampleType := "cpu"
+		if ts%2 == 0 {
+			exampleType = "memory"
+		}
+		r, err := dynparquet.Samples{{
+			ExampleType: exampleType,
+			Labels:      map[string]string{"node": "test"},
+			Timestamp:   ts,
+			Value:       ts * 10,
+		}}.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(context.Background(), r)
+		r.Release()
+		require.NoError(t, err)
+	}
+
+	first := logicalplan.First(logicalplan.Col("value"))
+	last := logicalplan.Last(logicalplan.Col("value"))
+	require.Equal(t, "first(value order by timestamp)", first.Name())
+	require.Equal(t, "last(value order by timestamp)", last.Name())
+
+	engine := query.NewEngine(memory.NewGoAllocator(), db.TableProvider())
+	results := map[string][2]int64{}
+	require.NoError(t, engine.ScanTable("test").
+		Aggregate(
+			[]logicalplan.Expr{first, last},
+			[]logicalplan.Expr{logicalplan.Col("example_type")},
+		).Execute(context.Background(), func(_ context.Context, r arrow.Record) error {
+		require.Equal(t, 3, int(r.NumCols()))
+		exampleTypes := r.Column(r.Schema().FieldIndices("example_type")[0])
+		firsts := r.Column(r.Schema().FieldIndices(first.Name())[0]).(*array.Int64)
+		lasts := r.Column(r.Schema().FieldIndices(last.Name())[0]).(*array.Int64)
+		for i := 0; i < int(r.NumRows()); i++ {
+			var exampleType string
+			switch arr := exampleTypes.(type) {
+			case *array.Dictionary:
+				exampleType = string(arr.Dictionary().(*array.Binary).Value(arr.GetValueIndex(i)))
+			default:
+				exampleType = string(arr.(*array.Binary).Value(i))
+			}
+			results[exampleType] = [2]int64{firsts.Value(i), lasts.Value(i)}
+		}
+		return nil
+	}))
+
+	require.Equal(t, map[string][2]int64{
+		"cpu":    {10, 90},
+		"memory": {20, 100},
+	}, results)
+}
+
+func TestVarianceAggregation(t *testing.T) {
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	insert := func(exampleType string, ts, value int64) {
+		r, err := dynparquet.Samples{{
+			ExampleType: exampleType,
+			Labels:      map[string]string{"node": "test"},
+			Timestamp:   ts,
+			Value:       value,
+		}}.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(context.Background(), r)
+		r.Release()
+		require.NoError(t, err)
+	}
+	// The large offset of the values makes naive sums of squares lose
+	// precision.
+	const offset = 1_000_000_000
+	for i := int64(1); i <= 5; i++ {
+		insert("cpu", i, offset+2*i-1)  // 1, 3, 5, 7, 9
+		insert("memory", i, offset+4*i) // 4, 8, 12, 16, 20
+	}
+	insert("disk", 1, 1)
+
+	stddev := logicalplan.Stddev(logicalplan.Col("value"))
+	variance := logicalplan.Variance(logicalplan.Col("value"))
+	require.Equal(t, "stddev(value)", stddev.Name())
+	require.Equal(t, "variance(value)", variance.Name())
+
+	type result struct {
+		stddev, variance float64
+		valid            bool
+	}
+	engine := query.NewEngine(memory.NewGoAllocator(), db.TableProvider())
+	results := map[string]result{}
+	require.NoError(t, engine.ScanTable("test").
+		Aggregate(
+			[]logicalplan.Expr{stddev, variance},
+			[]logicalplan.Expr{logicalplan.Col("example_type")},
+		).Execute(context.Background(), func(_ context.Context, r arrow.Record) error {
+		exampleTypes := r.Column(r.Schema().FieldIndices("example_type")[0])
+		stddevs := r.Column(r.Schema().FieldIndices(stddev.Name())[0]).(*array.Float64)
+		variances := r.Column(r.Schema().FieldIndices(variance.Name())[0]).(*array.Float64)
+		for i := 0; i < int(r.NumRows()); i++ {
+			var exampleType string
+			switch arr := exampleTypes.(type) {
+			case *array.Dictionary:
+				exampleType = string(arr.Dictionary().(*array.Binary).Value(arr.GetValueIndex(i)))
+			default:
+				exampleType = string(arr.(*array.Binary).Value(i))
+			}
+			results[exampleType] = result{
+				stddev:   stddevs.Value(i),
+				variance: variances.Value(i),
+				valid:    stddevs.IsValid(i) && variances.IsValid(i),
+			}
+		}
+		return nil
+	}))
+
+	require.Len(t, results, 3)
+	require.True(t, results["cpu"].valid)
+	require.InDelta(t, 10, results["cpu"].variance, 1e-6)
+	require.InDelta(t, math.Sqrt(10), results["cpu"].stddev, 1e-6)
+	require.True(t, results["memory"].valid)
+	require.InDelta(t, 40, results["memory"].variance, 1e-6)
+	require.InDelta(t, math.Sqrt(40), results["memory"].stddev, 1e-6)
+	// The variance of a single value is undefined.
+	require.False(t, results["disk"].valid)
+}
+
+func TestAnyValueAggregation(t *testing.T) {
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	for i := 1; i <= 10; i++ {
+		exampleType, node := "cpu", "node-a"
+		if i%2 == 0 {
+			exampleType, node = "memory", "node-b"
+		}
+		r, err := dynparquet.Samples{{
+			ExampleType: exampleType,
+			Labels:      map[string]string{"node": node},
+			Timestamp:   int64(i),
+			Value:       int64(i),
+		}}.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(context.Background(), r)
+		r.Release()
+		require.NoError(t, err)
+	}
+
+	anyValue := logicalplan.AnyValue(logicalplan.Col("labels.node"))
+	require.Equal(t, "any_value(labels.node)", anyValue.Name())
+
+	stringValue := func(arr arrow.Array, i int) string {
+		switch arr := arr.(type) {
+		case *array.Dictionary:
+			return string(arr.Dictionary().(*array.Binary).Value(arr.GetValueIndex(i)))
+		default:
+			return string(arr.(*array.Binary).Value(i))
+		}
+	}
+	engine := query.NewEngine(memory.NewGoAllocator(), db.TableProvider())
+	results := map[string]string{}
+	require.NoError(t, engine.ScanTable("test").
+		Aggregate(
+			[]logicalplan.Expr{anyValue, logicalplan.Sum(logicalplan.Col("value"))},
+			[]logicalplan.Expr{logicalplan.Col("example_type")},
+		).Execute(context.Background(), func(_ context.Context, r arrow.Record) error {
+		exampleTypes := r.Column(r.Schema().FieldIndices("example_type")[0])
+		nodes := r.Column(r.Schema().FieldIndices(anyValue.Name())[0])
+		for i := 0; i < int(r.NumRows()); i++ {
+			results[stringValue(exampleTypes, i)] = stringValue(nodes, i)
+		}
+		return nil
+	}))
+
+	require.Equal(t, map[string]string{
+		"cpu":    "node-a",
+		"memory": "node-b",
+	}, results)
+}
@@ -0,0 +1,99 @@
+package frostdb
+
+import (
+	"context"
+
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/polarsignals/frostdb/audit"
+)
+
+// WithAuditSink registers sinks that receive audit events for operations that
+// modify the structure or data of the column store, such as creating tables,
+// changing table configurations, dropping databases and restoring them, which
+// truncates their tables. Deletions of persisted blocks are audited by the
+// sinks of the bucket, see StorageWithAuditSink.
+func WithAuditSink(sinks ...audit.Sink) Option {
+	return func(s *ColumnStore) error {
+		s.auditSinks = append(s.auditSinks, sinks...)
+		return nil
+	}
+}
+
+// audit emits the given event to all audit sinks of the column store.
+func (s *ColumnStore) audit(ctx context.Context, event audit.Event) {
+	if event.Time.IsZero() {
+		event.Time = s.clock.Now()
+	}
+	emitAudit(ctx, s.logger, s.auditSinks, event)
+}
+
+// emitAudit emits the given event to the given sinks. Errors of the sinks are
+// logged.
+func emitAudit(ctx context.Context, logger log.Logger, sinks []audit.Sink, event audit.Event) {
+	for _, sink := range sinks {
+		if err := sink.Emit(ctx, event); err != nil {
+			level.Error(logger).Log(
+				"msg", "failed to emit audit event",
+				"type", event.Type,
+				"db", event.Database,
+				"table", event.Table,
+				"err", err,
+			)
+		}
+	}
+}
+
+// audit emits the given event for this database. Events are only emitted
+// once the database is set up, so replaying the WAL or loading a snapshot
+// does not emit events for operations that were already audited.
+func (db *DB) audit(ctx context.Context, event audit.Event) {
+	if !db.auditEnabled.Load() {
+		return
+	}
+	event.Database = db.name
+	db.columnStore.audit(ctx, event)
+}
+
+type auditRecord struct {
+	Type       string            `frostdb:",rle_dict,asc(0)"`
+	Timestamp  int64             `frostdb:",asc(1)"`
+	Database   string            `frostdb:",rle_dict"`
+	Table      string            `frostdb:",rle_dict"`
+	Attributes map[string]string `frostdb:",rle_dict,null"`
+}
+
+// AuditTableSink is an audit.Sink that stores audit events in a frostdb
+// table.
+type AuditTableSink struct {
+	table *GenericTable[auditRecord]
+}
+
+// NewAuditTableSink returns an audit.Sink that writes events into the table
+// with the given name in db, creating it if it does not exist. The table has
+// the columns type, timestamp (in milliseconds), database, table and the
+// dynamic column attributes.
+func NewAuditTableSink(db *DB, name string, options ...TableOption) (*AuditTableSink, error) {
+	table, err := NewGenericTable[auditRecord](db, name, memory.DefaultAllocator, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditTableSink{table: table}, nil
+}
+
+func (s *AuditTableSink) Emit(ctx context.Context, event audit.Event) error {
+	return s.table.Write(ctx, auditRecord{
+		Type:       string(event.Type),
+		Timestamp:  event.Time.UnixMilli(),
+		Database:   event.Database,
+		Table:      event.Table,
+		Attributes: event.Attributes,
+	})
+}
+
+// Release releases the resources held by the sink.
+func (s *AuditTableSink) Release() {
+	s.table.Release()
+}
@@ -0,0 +1,167 @@
+// Package audit defines the events frostdb emits for operations that modify
+// the data or the structure of a column store, and sinks to deliver them to.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+// EventType is the type of operation an Event records.
+type EventType string
+
+const (
+	// EventDBCreated is emitted when a database is created.
+	EventDBCreated EventType = "db_created"
+	// EventDBDropped is emitted when a database and all of its data are
+	// dropped.
+	EventDBDropped EventType = "db_dropped"
+	// EventDBRestored is emitted when a database is restored to the state as
+	// of the transaction in the "tx" attribute. The restore is committed in
+	// the transaction in the "restore_tx" attribute.
+	EventDBRestored EventType = "db_restored"
+	// EventTableCreated is emitted when a table is created.
+	EventTableCreated EventType = "table_created"
+	// EventTableConfigChanged is emitted when the configuration (including the
+	// schema) of an existing table is replaced.
+	EventTableConfigChanged EventType = "table_config_changed"
+	// EventTableTruncated is emitted when the data of a table written after
+	// the transaction in the "tx" attribute is discarded, e.g. when its
+	// database is restored.
+	EventTableTruncated EventType = "table_truncated"
+	// EventBlockDeleted is emitted when a block persisted to a bucket is
+	// deleted. The "block" attribute is the id of the block and the "reason"
+	// attribute is either "deleted" for blocks that were deleted on request or
+	// "incomplete" for blocks that were discarded because they were not
+	// completely persisted. Database is the storage prefix of the database,
+	// which is its name unless configured otherwise.
+	EventBlockDeleted EventType = "block_deleted"
+)
+
+// Event is a single audit event.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Type     EventType `json:"type"`
+	Database string    `json:"database"`
+	// Table is empty for database level events.
+	Table string `json:"table,omitempty"`
+	// Attributes contains additional event type specific information.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Sink receives audit events. Events are delivered synchronously as part of
+// the audited operation, so implementations should not block for long. An
+// error returned by a sink is logged but does not fail the operation.
+type Sink interface {
+	Emit(ctx context.Context, event Event) error
+}
+
+// SinkFunc is an adapter to allow the use of ordinary functions as Sinks.
+type SinkFunc func(ctx context.Context, event Event) error
+
+func (f SinkFunc) Emit(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// LoggerSink writes audit events to a logger.
+type LoggerSink struct {
+	logger log.Logger
+}
+
+// NewLoggerSink returns a Sink that writes events as structured log lines.
+func NewLoggerSink(logger log.Logger) *LoggerSink {
+	return &LoggerSink{logger: logger}
+}
+
+func (s *LoggerSink) Emit(_ context.Context, event Event) error {
+	keyvals := []interface{}{
+		"msg", "audit event",
+		"ts", event.Time.Format(time.RFC3339Nano),
+		"type", event.Type,
+		"db", event.Database,
+	}
+	if event.Table != "" {
+		keyvals = append(keyvals, "table", event.Table)
+	}
+	keys := make([]string, 0, len(event.Attributes))
+	for k := range event.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		keyvals = append(keyvals, k, event.Attributes[k])
+	}
+	return s.logger.Log(keyvals...)
+}
+
+// WebhookSink posts audit events as JSON to an HTTP endpoint.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+	header http.Header
+}
+
+type WebhookOption func(*WebhookSink)
+
+// WithHTTPClient sets the client used to post events. The default client
+// times out after 5 seconds.
+func WithHTTPClient(client *http.Client) WebhookOption {
+	return func(s *WebhookSink) {
+		s.client = client
+	}
+}
+
+// WithHeader sets a header on every request, e.g. for authentication.
+func WithHeader(key, value string) WebhookOption {
+	return func(s *WebhookSink) {
+		s.header.Set(key, value)
+	}
+}
+
+// NewWebhookSink returns a Sink that posts every event as a JSON object to
+// the given URL.
+func NewWebhookSink(url string, options ...WebhookOption) *WebhookSink {
+	s := &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+		header: http.Header{},
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+func (s *WebhookSink) Emit(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit webhook returned status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSink(t *testing.T) {
+	var received []Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "secret", r.Header.Get("Authorization"))
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var e Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		received = append(received, e)
+		if e.Table == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	sink := NewWebhookSink(srv.URL, WithHeader("Authorization", "secret"))
+	event := Event{
+		Time:       time.UnixMilli(1000).UTC(),
+		Type:       EventTableCreated,
+		Database:   "db",
+		Table:      "table",
+		Attributes: map[string]string{"key": "value"},
+	}
+	require.NoError(t, sink.Emit(context.Background(), event))
+	require.Equal(t, []Event{event}, received)
+
+	event.Table = "fail"
+	require.Error(t, sink.Emit(context.Background(), event))
+}
+
+func TestLoggerSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewLoggerSink(log.NewLogfmtLogger(&buf))
+	require.NoError(t, sink.Emit(context.Background(), Event{
+		Time:       time.UnixMilli(1000).UTC(),
+		Type:       EventDBDropped,
+		Database:   "db",
+		Attributes: map[string]string{"b": "2", "a": "1"},
+	}))
+	require.Equal(t, "msg=\"audit event\" ts=1970-01-01T00:00:01Z type=db_dropped db=db a=1 b=2\n", buf.String())
+}
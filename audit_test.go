@@ -0,0 +1,180 @@
+package frostdb
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/polarsignals/frostdb/audit"
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+type testAuditSink struct {
+	mtx    sync.Mutex
+	events []audit.Event
+}
+
+func (s *testAuditSink) Emit(_ context.Context, event audit.Event) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *testAuditSink) types() []audit.EventType {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	types := make([]audit.EventType, 0, len(s.events))
+	for _, e := range s.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestAudit(t *testing.T) {
+	ctx := context.Background()
+	sink := &testAuditSink{}
+	dir := t.TempDir()
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithWAL(),
+		WithStoragePath(dir),
+		WithAuditSink(sink),
+	)
+	require.NoError(t, err)
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	_, err = db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	// Same config, no event.
+	_, err = db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	_, err = db.Table("test", NewTableConfig(dynparquet.SampleDefinition(), WithRowGroupSize(10)))
+	require.NoError(t, err)
+
+	require.Equal(t, []audit.EventType{
+		audit.EventDBCreated,
+		audit.EventTableCreated,
+		audit.EventTableConfigChanged,
+	}, sink.types())
+	require.Equal(t, "test", sink.events[0].Database)
+	require.Empty(t, sink.events[0].Table)
+	require.Equal(t, "test", sink.events[1].Database)
+	require.Equal(t, "test", sink.events[1].Table)
+	require.False(t, sink.events[1].Time.IsZero())
+	require.NoError(t, c.Close())
+
+	// Recovering the database and the table from the WAL must not emit
+	// events.
+	sink = &testAuditSink{}
+	c, err = New(
+		WithLogger(newTestLogger(t)),
+		WithWAL(),
+		WithStoragePath(dir),
+		WithAuditSink(sink),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+	_, err = c.DB(ctx, "test")
+	require.NoError(t, err)
+	require.Empty(t, sink.types())
+
+	require.NoError(t, c.DropDB("test"))
+	require.Equal(t, []audit.EventType{audit.EventDBDropped}, sink.types())
+}
+
+func TestAuditSinkUsesStore(t *testing.T) {
+	ctx := context.Background()
+	var (
+		c   *ColumnStore
+		dbs [][]string
+	)
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithStoragePath(t.TempDir()),
+		WithAuditSink(audit.SinkFunc(func(context.Context, audit.Event) error {
+			// The store is not locked while events are emitted.
+			dbs = append(dbs, c.DBs())
+			return nil
+		})),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.DB(ctx, "test")
+	require.NoError(t, err)
+	require.NoError(t, c.DropDB("test"))
+	require.Equal(t, [][]string{{"test"}, {}}, dbs)
+}
+
+func TestAuditTableSink(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+
+	auditDB, err := c.DB(ctx, "audit")
+	require.NoError(t, err)
+	sink, err := NewAuditTableSink(auditDB, "events")
+	require.NoError(t, err)
+	defer sink.Release()
+	c.auditSinks = append(c.auditSinks, sink)
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	_, err = db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	engine := query.NewEngine(memory.DefaultAllocator, auditDB.TableProvider())
+	rows := int64(0)
+	require.NoError(t, engine.ScanTable("events").
+		Filter(logicalplan.Col("table").Eq(logicalplan.Literal("test"))).
+		Execute(ctx, func(_ context.Context, r arrow.Record) error {
+			rows += r.NumRows()
+			return nil
+		}))
+	require.Equal(t, int64(1), rows)
+}
+
+func TestAuditBlockDeleted(t *testing.T) {
+	ctx := context.Background()
+	sink := &testAuditSink{}
+	storage := NewDefaultObjstoreBucket(objstore.NewInMemBucket(), StorageWithAuditSink(sink))
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithReadWriteStorage(storage),
+		WithManualBlockRotation(),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	writeTx, err := table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	block := table.ActiveBlock().ulid.String()
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	db.Wait(writeTx + 2)
+	require.Empty(t, sink.types())
+
+	require.NoError(t, storage.DeleteBlock(ctx, filepath.Join("test", "test", block)))
+	require.Equal(t, []audit.EventType{audit.EventBlockDeleted}, sink.types())
+	require.Equal(t, "test", sink.events[0].Database)
+	require.Equal(t, "test", sink.events[0].Table)
+	require.Equal(t, map[string]string{"block": block, "reason": "deleted"}, sink.events[0].Attributes)
+	require.False(t, sink.events[0].Time.IsZero())
+}
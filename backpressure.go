@@ -0,0 +1,123 @@
+package frostdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBackpressure is returned by inserts when a table cannot keep up with the
+// rate of inserts. Producers should shed load or retry later. Use errors.As
+// with a *BackpressureError to find out which threshold was exceeded.
+var ErrBackpressure = errors.New("backpressure")
+
+// BackpressureError is the error returned when an insert is rejected because
+// of backpressure. It matches ErrBackpressure with errors.Is.
+type BackpressureError struct {
+	Table string
+	// Reason is the exceeded threshold, either "active_bytes" or
+	// "compaction_backlog".
+	Reason    string
+	Value     int64
+	Threshold int64
+}
+
+func (e *BackpressureError) Error() string {
+	return fmt.Sprintf("backpressure on table %s: %s %d exceeds %d", e.Table, e.Reason, e.Value, e.Threshold)
+}
+
+func (e *BackpressureError) Is(target error) bool {
+	return target == ErrBackpressure
+}
+
+// BackpressureConfig configures when inserts into a table are subject to
+// backpressure.
+type BackpressureConfig struct {
+	// MaxActiveBytes is the size of the active and pending blocks of a
+	// table above which inserts are subject to backpressure. Disabled if 0.
+	MaxActiveBytes int64
+	// MaxCompactionBacklog is the number of bytes waiting to be compacted in
+	// the active block of a table above which inserts are subject to
+	// backpressure. Disabled if 0.
+	MaxCompactionBacklog int64
+	// Block makes inserts wait until the table is below the thresholds or
+	// the context is done instead of returning a *BackpressureError.
+	Block bool
+	// PollInterval is how often blocked inserts check the thresholds. The
+	// default is 10ms.
+	PollInterval time.Duration
+}
+
+// WithBackpressure applies backpressure to inserts into tables that exceed
+// the thresholds of the given config.
+func WithBackpressure(config BackpressureConfig) Option {
+	return func(s *ColumnStore) error {
+		if config.MaxActiveBytes < 0 || config.MaxCompactionBacklog < 0 {
+			return fmt.Errorf("backpressure thresholds must not be negative")
+		}
+		if config.PollInterval == 0 {
+			config.PollInterval = 10 * time.Millisecond
+		}
+		s.backpressure = config
+		return nil
+	}
+}
+
+// backpressureError returns a *BackpressureError if the table exceeds any of
+// the backpressure thresholds.
+func (t *Table) backpressureError() error {
+	config := t.db.columnStore.backpressure
+	if config.MaxActiveBytes > 0 {
+		if size := t.memorySize(); size > config.MaxActiveBytes {
+			return &BackpressureError{
+				Table:     t.name,
+				Reason:    "active_bytes",
+				Value:     size,
+				Threshold: config.MaxActiveBytes,
+			}
+		}
+	}
+	if config.MaxCompactionBacklog > 0 {
+		block := t.ActiveBlock()
+		if block == nil {
+			return nil
+		}
+		if backlog := block.Index().CompactionBacklog(); backlog > config.MaxCompactionBacklog {
+			return &BackpressureError{
+				Table:     t.name,
+				Reason:    "compaction_backlog",
+				Value:     backlog,
+				Threshold: config.MaxCompactionBacklog,
+			}
+		}
+	}
+	return nil
+}
+
+// applyBackpressure returns a *BackpressureError, or blocks if configured to
+// do so, while the table exceeds any of the backpressure thresholds.
+func (t *Table) applyBackpressure(ctx context.Context) error {
+	err := t.backpressureError()
+	if err == nil {
+		return nil
+	}
+	if !t.db.columnStore.backpressure.Block {
+		t.metrics.backpressure.WithLabelValues("rejected").Inc()
+		return err
+	}
+
+	t.metrics.backpressure.WithLabelValues("blocked").Inc()
+	ticker := t.db.columnStore.clock.NewTicker(t.db.columnStore.backpressure.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", err, ctx.Err())
+		case <-ticker.C():
+		}
+		if err = t.backpressureError(); err == nil {
+			return nil
+		}
+	}
+}
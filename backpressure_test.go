@@ -0,0 +1,71 @@
+package frostdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+)
+
+func TestBackpressure(t *testing.T) {
+	ctx := context.Background()
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+
+	t.Run("Reject", func(t *testing.T) {
+		c, err := New(WithBackpressure(BackpressureConfig{MaxActiveBytes: 1}))
+		require.NoError(t, err)
+		defer c.Close()
+		db, err := c.DB(ctx, "test")
+		require.NoError(t, err)
+		table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+		require.NoError(t, err)
+
+		_, err = table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+
+		_, err = table.InsertRecord(ctx, r)
+		require.True(t, errors.Is(err, ErrBackpressure))
+		var bpErr *BackpressureError
+		require.True(t, errors.As(err, &bpErr))
+		require.Equal(t, "test", bpErr.Table)
+		require.Equal(t, "active_bytes", bpErr.Reason)
+		require.Equal(t, int64(1), bpErr.Threshold)
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		c, err := New(
+			WithBackpressure(BackpressureConfig{MaxActiveBytes: 1, Block: true}),
+			WithManualBlockRotation(),
+		)
+		require.NoError(t, err)
+		defer c.Close()
+		db, err := c.DB(ctx, "test")
+		require.NoError(t, err)
+		table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+		require.NoError(t, err)
+
+		_, err = table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+
+		insertCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err = table.InsertRecord(insertCtx, r)
+		require.True(t, errors.Is(err, ErrBackpressure))
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+		// Inserts are unblocked once the block is rotated.
+		done := make(chan error)
+		go func() {
+			_, err := table.InsertRecord(ctx, r)
+			done <- err
+		}()
+		require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), true))
+		require.NoError(t, <-done)
+	})
+}
@@ -0,0 +1,64 @@
+package frostdb
+
+import (
+	"context"
+	"sync"
+)
+
+// BlockFailurePolicy describes how a query handles persisted blocks that can't
+// be read, e.g. because they are missing or corrupt in the bucket.
+// Availability-sensitive queries can skip such blocks and return partial
+// results instead of failing. The policy only covers failures to open a
+// block; once row groups of a block were returned, errors reading them fail
+// the query.
+type BlockFailurePolicy struct {
+	// Retries is the number of times opening a block is retried before the
+	// block is skipped or the query fails.
+	Retries int
+	// Skip skips blocks that can't be read instead of failing the query.
+	Skip bool
+	// Skipped, if set, records the blocks skipped by the query.
+	Skipped *SkippedBlocks
+}
+
+// SkippedBlock is a block skipped by a query because it couldn't be read.
+type SkippedBlock struct {
+	// BlockDir is the directory of the block in the bucket.
+	BlockDir string
+	// Err is the error reading the block.
+	Err error
+}
+
+// SkippedBlocks collects the blocks skipped by a query. It is safe for
+// concurrent use.
+type SkippedBlocks struct {
+	mtx    sync.Mutex
+	blocks []SkippedBlock
+}
+
+func (s *SkippedBlocks) add(b SkippedBlock) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.blocks = append(s.blocks, b)
+}
+
+// Blocks returns the skipped blocks.
+func (s *SkippedBlocks) Blocks() []SkippedBlock {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]SkippedBlock(nil), s.blocks...)
+}
+
+type blockFailurePolicyKey struct{}
+
+// WithBlockFailurePolicy returns a context making the queries executed with it
+// handle unreadable blocks according to the given policy. By default, a block
+// that can't be read fails the query.
+func WithBlockFailurePolicy(ctx context.Context, policy BlockFailurePolicy) context.Context {
+	return context.WithValue(ctx, blockFailurePolicyKey{}, policy)
+}
+
+func blockFailurePolicyFromContext(ctx context.Context) BlockFailurePolicy {
+	policy, _ := ctx.Value(blockFailurePolicyKey{}).(BlockFailurePolicy)
+	return policy
+}
@@ -0,0 +1,96 @@
+package frostdb
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query"
+)
+
+// flakyBucket fails to return the attributes of block data files while
+// failures is positive.
+type flakyBucket struct {
+	objstore.Bucket
+	failures atomic.Int64
+}
+
+func (b *flakyBucket) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	if strings.HasSuffix(name, blockDataFileName) && b.failures.Add(-1) >= 0 {
+		return objstore.ObjectAttributes{}, errors.New("unavailable")
+	}
+	return b.Bucket.Attributes(ctx, name)
+}
+
+func TestBlockFailurePolicy(t *testing.T) {
+	ctx := context.Background()
+	bucket := &flakyBucket{Bucket: objstore.NewInMemBucket()}
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithReadWriteStorage(NewDefaultObjstoreBucket(bucket)),
+		WithManualBlockRotation(),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	writeTx, err := table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	db.Wait(writeTx + 2)
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	rows := func(ctx context.Context) (int64, error) {
+		var n int64
+		err := engine.ScanTable("test").Execute(ctx, func(_ context.Context, r arrow.Record) error {
+			n += r.NumRows()
+			return nil
+		})
+		return n, err
+	}
+
+	t.Run("Fail", func(t *testing.T) {
+		bucket.failures.Store(1)
+		_, err := rows(ctx)
+		require.Error(t, err)
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		bucket.failures.Store(2)
+		n, err := rows(WithBlockFailurePolicy(ctx, BlockFailurePolicy{Retries: 2}))
+		require.NoError(t, err)
+		require.Equal(t, int64(3), n)
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		bucket.failures.Store(2)
+		skipped := &SkippedBlocks{}
+		n, err := rows(WithBlockFailurePolicy(ctx, BlockFailurePolicy{
+			Retries: 1,
+			Skip:    true,
+			Skipped: skipped,
+		}))
+		require.NoError(t, err)
+		require.Zero(t, n)
+		blocks := skipped.Blocks()
+		require.Len(t, blocks, 1)
+		require.True(t, strings.HasPrefix(blocks[0].BlockDir, filepath.Join("test", "test")+"/"), blocks[0].BlockDir)
+		require.EqualError(t, blocks[0].Err, "unavailable")
+	})
+}
@@ -0,0 +1,138 @@
+package frostdb
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/parquet-go/parquet-go"
+)
+
+// BlockCatalog is notified when blocks are added to or removed from a bucket,
+// e.g. to maintain the manifests of an Iceberg or Delta Lake table, so that
+// other query engines can discover the Parquet files of the blocks written by
+// frostdb.
+type BlockCatalog interface {
+	UpdateCatalog(ctx context.Context, update CatalogUpdate) error
+}
+
+// BlockCatalogFunc is an adapter to allow the use of ordinary functions as
+// BlockCatalogs.
+type BlockCatalogFunc func(ctx context.Context, update CatalogUpdate) error
+
+func (f BlockCatalogFunc) UpdateCatalog(ctx context.Context, update CatalogUpdate) error {
+	return f(ctx, update)
+}
+
+// CatalogUpdate is a change of the blocks of a table.
+type CatalogUpdate struct {
+	// Table is the prefix of the table in the bucket, which is the bucket
+	// prefix of its database joined with the name of the table.
+	Table   string
+	Added   []CatalogBlock
+	Removed []CatalogBlock
+}
+
+// CatalogBlock is a block of a table. Only the ID and Path of removed blocks
+// are set.
+type CatalogBlock struct {
+	ID ulid.ULID
+	// Path is the path of the Parquet file of the block in the bucket.
+	Path    string
+	Size    int64
+	NumRows int64
+	// Columns are the columns of the block and their statistics. They are
+	// empty if the statistics of the block are unknown.
+	Columns []CatalogColumn
+}
+
+// CatalogColumn is a column of a block and its statistics.
+type CatalogColumn struct {
+	Name      string
+	NumValues int64
+	NullCount int64
+	// Min and Max are the bounds of the non-null values of the column. They
+	// are null if all values are null.
+	Min parquet.Value
+	Max parquet.Value
+}
+
+// StorageWithCatalog makes the bucket update the given catalog whenever a block
+// is persisted to it or deleted from it. Empty blocks are not cataloged. The
+// catalog is updated after the bucket, so a failed update is logged but does
+// not fail the persistence or deletion of the block.
+func StorageWithCatalog(catalog BlockCatalog) DefaultObjstoreBucketOption {
+	return func(b *DefaultObjstoreBucket) {
+		b.catalog = catalog
+	}
+}
+
+// blockCataloger is implemented by sinks that catalog the blocks persisted to
+// them.
+type blockCataloger interface {
+	catalogBlock(ctx context.Context, blockDir string)
+}
+
+// catalogBlock adds the persisted block in the given directory to the catalog.
+func (b *DefaultObjstoreBucket) catalogBlock(ctx context.Context, blockDir string) {
+	if b.catalog == nil {
+		return
+	}
+	block, err := b.tableManifestBlock(ctx, blockDir)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to read block to catalog", "block", blockDir, "err", err)
+		return
+	}
+	if block.Size == 0 {
+		return
+	}
+	b.updateCatalog(ctx, blockDir, CatalogUpdate{Added: []CatalogBlock{block.catalogBlock(blockDir)}})
+}
+
+// uncatalogBlock removes the deleted block in the given directory from the
+// catalog.
+func (b *DefaultObjstoreBucket) uncatalogBlock(ctx context.Context, blockDir string) {
+	if b.catalog == nil {
+		return
+	}
+	id, err := ulid.Parse(filepath.Base(blockDir))
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to uncatalog block", "block", blockDir, "err", err)
+		return
+	}
+	b.updateCatalog(ctx, blockDir, CatalogUpdate{Removed: []CatalogBlock{{
+		ID:   id,
+		Path: filepath.Join(blockDir, blockDataFileName),
+	}}})
+}
+
+func (b *DefaultObjstoreBucket) updateCatalog(ctx context.Context, blockDir string, update CatalogUpdate) {
+	update.Table = filepath.Dir(strings.TrimSuffix(blockDir, "/"))
+	if err := b.catalog.UpdateCatalog(ctx, update); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to update block catalog", "block", blockDir, "err", err)
+	}
+}
+
+func (b tableManifestBlock) catalogBlock(blockDir string) CatalogBlock {
+	block := CatalogBlock{
+		ID:      ulid.MustParse(b.ULID),
+		Path:    filepath.Join(blockDir, blockDataFileName),
+		Size:    b.Size,
+		NumRows: b.NumRows,
+	}
+	for _, col := range b.Columns {
+		c := CatalogColumn{
+			Name:      col.Name,
+			NumValues: col.NumValues,
+			NullCount: col.NullCount,
+		}
+		if !col.allNull() && col.Min != nil {
+			c.Min = col.Kind.Value(col.Min)
+			c.Max = col.Kind.Value(col.Max)
+		}
+		block.Columns = append(block.Columns, c)
+	}
+	return block
+}
@@ -0,0 +1,87 @@
+package frostdb
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+)
+
+func TestStorageWithCatalog(t *testing.T) {
+	ctx := context.Background()
+	var (
+		mtx     sync.Mutex
+		updates []CatalogUpdate
+	)
+	storage := NewDefaultObjstoreBucket(objstore.NewInMemBucket(), StorageWithCatalog(
+		BlockCatalogFunc(func(_ context.Context, update CatalogUpdate) error {
+			mtx.Lock()
+			defer mtx.Unlock()
+			updates = append(updates, update)
+			return nil
+		}),
+	))
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithReadWriteStorage(storage),
+		WithManualBlockRotation(),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	writeTx, err := table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	id := table.ActiveBlock().ulid
+	blockDir := filepath.Join("test", "test", id.String())
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	db.Wait(writeTx + 2)
+
+	mtx.Lock()
+	require.Len(t, updates, 1)
+	added := updates[0]
+	mtx.Unlock()
+	require.Equal(t, "test/test", added.Table)
+	require.Empty(t, added.Removed)
+	require.Len(t, added.Added, 1)
+	block := added.Added[0]
+	require.Equal(t, id, block.ID)
+	require.Equal(t, filepath.Join(blockDir, blockDataFileName), block.Path)
+	require.Equal(t, int64(3), block.NumRows)
+	attribs, err := storage.Attributes(ctx, block.Path)
+	require.NoError(t, err)
+	require.Equal(t, attribs.Size, block.Size)
+
+	var value *CatalogColumn
+	for i, col := range block.Columns {
+		if col.Name == "value" {
+			value = &block.Columns[i]
+		}
+	}
+	require.NotNil(t, value)
+	require.Equal(t, int64(3), value.NumValues)
+	require.Zero(t, value.NullCount)
+	require.Equal(t, int64(3), value.Min.Int64())
+	require.Equal(t, int64(5), value.Max.Int64())
+
+	require.NoError(t, storage.DeleteBlock(ctx, blockDir))
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Len(t, updates, 2)
+	require.Equal(t, CatalogUpdate{
+		Table:   "test/test",
+		Removed: []CatalogBlock{{ID: id, Path: block.Path}},
+	}, updates[1])
+}
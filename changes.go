@@ -0,0 +1,334 @@
+package frostdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+
+	walpb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/wal/v1alpha1"
+	"github.com/polarsignals/frostdb/wal"
+)
+
+// ErrChangesUnavailable is returned by WatchChanges if the changes of some of
+// the requested transactions are no longer available, either because the WAL
+// is disabled or because it was truncated after a snapshot or block
+// persistence.
+var ErrChangesUnavailable = errors.New("changes are unavailable")
+
+// maxPendingChanges is the number of changes that are buffered for a watcher
+// until they are delivered. The changes of a watcher that falls further
+// behind are dropped and read from the WAL instead.
+const maxPendingChanges = 1024
+
+// Change is a change to the data of a table that was committed in a
+// transaction. Since frostdb does not support deleting rows, every change is
+// an insert of the rows of Record, unless the database was restored.
+type Change struct {
+	Tx     uint64
+	Table  string
+	Record arrow.Record
+	// RestoredTx is set if the database was restored in the transaction, see
+	// DB.RestoreToTx. The table is rolled back to its state as of
+	// RestoredTx, discarding the changes of the later transactions, and
+	// Record is nil. Restores are only passed to the watchers running when
+	// the database is restored, they are not read from the WAL.
+	RestoredTx uint64
+}
+
+func (c Change) release() {
+	if c.Record != nil {
+		c.Record.Release()
+	}
+}
+
+// changeLog fans out committed inserts to the watchers of a database.
+type changeLog struct {
+	mtx      sync.Mutex
+	closed   bool
+	watchers map[*changeWatcher]struct{}
+}
+
+type changeWatcher struct {
+	// pending holds the changes published since the watcher was registered
+	// that have not been delivered yet. The changes are not necessarily
+	// ordered or committed.
+	pending []Change
+	// dropped is set once the pending changes were dropped because the
+	// watcher fell behind. No changes are buffered until it is reset.
+	dropped bool
+	// wake is signaled when transactions were committed or the changes were
+	// closed.
+	wake chan struct{}
+}
+
+func newChangeWatcher() *changeWatcher {
+	return &changeWatcher{wake: make(chan struct{}, 1)}
+}
+
+// publish passes the insert of the given transaction to all watchers. It must
+// be called before the transaction commits, so that watchers that observe the
+// transaction as committed are guaranteed to have received its change.
+func (l *changeLog) publish(tx uint64, table string, r arrow.Record) {
+	l.add(Change{Tx: tx, Table: table, Record: r})
+}
+
+// publishRestore passes the restore of the table to the state as of
+// restoredTx in the given transaction to all watchers, like publish.
+func (l *changeLog) publishRestore(tx uint64, table string, restoredTx uint64) {
+	l.add(Change{Tx: tx, Table: table, RestoredTx: restoredTx})
+}
+
+func (l *changeLog) add(c Change) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	for w := range l.watchers {
+		if w.dropped {
+			continue
+		}
+		if len(w.pending) == maxPendingChanges {
+			releaseChanges(w.pending)
+			w.pending = nil
+			w.dropped = true
+			continue
+		}
+		if c.Record != nil {
+			c.Record.Retain()
+		}
+		w.pending = append(w.pending, c)
+	}
+}
+
+// notify wakes the watchers, e.g. once the high watermark advanced.
+func (l *changeLog) notify() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	for w := range l.watchers {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (l *changeLog) register(w *changeWatcher) bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.closed {
+		return false
+	}
+	if l.watchers == nil {
+		l.watchers = map[*changeWatcher]struct{}{}
+	}
+	l.watchers[w] = struct{}{}
+	return true
+}
+
+func (l *changeLog) unregister(w *changeWatcher) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	delete(l.watchers, w)
+	releaseChanges(w.pending)
+	w.pending = nil
+}
+
+// take removes the pending changes of the watcher that were committed at or
+// below the given watermark and returns them ordered by transaction. If the
+// changes of the watcher were dropped since the last call, it reports so
+// instead and the watcher buffers changes again.
+func (l *changeLog) take(w *changeWatcher, watermark uint64) (changes []Change, dropped, closed bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if w.dropped {
+		w.dropped = false
+		return nil, true, l.closed
+	}
+	var committed []Change
+	pending := w.pending[:0]
+	for _, c := range w.pending {
+		if c.Tx <= watermark {
+			committed = append(committed, c)
+			continue
+		}
+		pending = append(pending, c)
+	}
+	w.pending = pending
+	sort.Slice(committed, func(i, j int) bool {
+		return committed[i].Tx < committed[j].Tx
+	})
+	return committed, false, l.closed
+}
+
+func (l *changeLog) close() {
+	l.mtx.Lock()
+	l.closed = true
+	l.mtx.Unlock()
+	l.notify()
+}
+
+func releaseChanges(changes []Change) {
+	for _, c := range changes {
+		c.release()
+	}
+}
+
+// WatchChanges calls the callback with every change committed in transactions
+// greater than or equal to fromTx, ordered by transaction. Changes committed
+// before WatchChanges was called are read from the WAL, so they are only
+// available if the WAL is enabled and has not been truncated past fromTx.
+// To start from the changes committed after the call, use the high watermark
+// plus one as fromTx. A consumer that resumes watching should pass the
+// transaction of the last change it processed plus one.
+//
+// WatchChanges blocks until the context is canceled, the callback returns an
+// error or the database is closed. The record of a change is only valid
+// during the callback and needs to be retained to be used afterwards.
+//
+// Changes committed while the watcher is running are buffered until they are
+// delivered. If the callback falls too far behind, the buffered changes are
+// dropped and read from the WAL instead, or WatchChanges returns
+// ErrChangesUnavailable if the WAL is disabled.
+func (db *DB) WatchChanges(ctx context.Context, fromTx uint64, callback func(ctx context.Context, change Change) error) error {
+	w := newChangeWatcher()
+	if !db.changes.register(w) {
+		return errors.New("database closed")
+	}
+	defer db.changes.unregister(w)
+
+	// next is the transaction of the next change to deliver.
+	next := fromTx
+	deliver := func(ctx context.Context, c Change) error {
+		next = c.Tx + 1
+		return callback(ctx, c)
+	}
+	// All transactions up to lastTx either published their changes before
+	// the watcher was registered or will be read from the WAL, so they are
+	// read from the WAL. Later transactions publish their changes to the
+	// watcher.
+	lastTx := db.tx.Load()
+	if next <= lastTx {
+		if err := db.replayChanges(ctx, next, lastTx, deliver); err != nil {
+			return err
+		}
+	}
+
+	for {
+		changes, dropped, closed := db.changes.take(w, db.highWatermark.Load())
+		if dropped {
+			// The watcher buffers the changes of the transactions after
+			// lastTx again, the changes up to it are read from the WAL.
+			lastTx = db.tx.Load()
+			if next <= lastTx {
+				if err := db.replayChanges(ctx, next, lastTx, deliver); err != nil {
+					return fmt.Errorf("the watcher fell behind: %w", err)
+				}
+			}
+			continue
+		}
+		for i, c := range changes {
+			if c.Tx <= lastTx || c.Tx < next {
+				c.release()
+				continue
+			}
+			err := deliver(ctx, c)
+			c.release()
+			if err != nil {
+				releaseChanges(changes[i+1:])
+				return err
+			}
+		}
+		if closed {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.wake:
+		}
+	}
+}
+
+// replayChanges calls the callback with the inserts of the transactions from
+// fromTx to toTx that are logged in the WAL.
+func (db *DB) replayChanges(ctx context.Context, fromTx, toTx uint64, callback func(ctx context.Context, change Change) error) error {
+	if _, ok := db.wal.(*wal.NopWAL); ok {
+		return fmt.Errorf("%w: WAL is disabled", ErrChangesUnavailable)
+	}
+
+	if err := db.waitForWAL(ctx, toTx); err != nil {
+		return err
+	}
+
+	firstIndex, err := db.wal.FirstIndex()
+	if err != nil {
+		return fmt.Errorf("read first WAL index: %w", err)
+	}
+	if fromTx == 0 {
+		fromTx = 1
+	}
+	if firstIndex > fromTx {
+		return fmt.Errorf("%w: WAL starts at transaction %d", ErrChangesUnavailable, firstIndex)
+	}
+
+	errStop := errors.New("stop replay")
+	err = db.wal.Replay(fromTx, func(tx uint64, record *walpb.Record) error {
+		if tx > toTx {
+			return errStop
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		write, ok := record.Entry.EntryType.(*walpb.Entry_Write_)
+		if !ok || !write.Write.Arrow {
+			return nil
+		}
+
+		reader, err := ipc.NewReader(bytes.NewReader(write.Write.Data))
+		if err != nil {
+			return fmt.Errorf("create ipc reader: %w", err)
+		}
+		defer reader.Release()
+		r, err := reader.Read()
+		if err != nil {
+			return fmt.Errorf("read record: %w", err)
+		}
+		return callback(ctx, Change{
+			Tx:     tx,
+			Table:  write.Write.TableName,
+			Record: r,
+		})
+	})
+	if errors.Is(err, errStop) {
+		return nil
+	}
+	return err
+}
+
+// waitForWAL returns once all transactions up to tx are logged to the WAL,
+// which logs records asynchronously.
+func (db *DB) waitForWAL(ctx context.Context, tx uint64) error {
+	ticker := db.columnStore.clock.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		lastIndex, err := db.wal.LastIndex()
+		if err != nil {
+			return fmt.Errorf("read last WAL index: %w", err)
+		}
+		if lastIndex >= tx {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
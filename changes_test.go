@@ -0,0 +1,168 @@
+package frostdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+)
+
+func TestWatchChanges(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithWAL(),
+		WithStoragePath(t.TempDir()),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	insert := func() uint64 {
+		r, err := dynparquet.NewTestSamples().ToRecord()
+		require.NoError(t, err)
+		defer r.Release()
+		tx, err := table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+		return tx
+	}
+	first := insert()
+	second := insert()
+
+	errDone := errors.New("done")
+	watch := func(fromTx uint64, n int) ([]uint64, error) {
+		var txs []uint64
+		err := db.WatchChanges(ctx, fromTx, func(_ context.Context, change Change) error {
+			require.Equal(t, "test", change.Table)
+			require.Equal(t, int64(3), change.Record.NumRows())
+			txs = append(txs, change.Tx)
+			if len(txs) == n {
+				return errDone
+			}
+			return nil
+		})
+		return txs, err
+	}
+
+	// Changes committed before the call are read from the WAL.
+	txs, err := watch(0, 2)
+	require.ErrorIs(t, err, errDone)
+	require.Equal(t, []uint64{first, second}, txs)
+
+	txs, err = watch(second, 1)
+	require.ErrorIs(t, err, errDone)
+	require.Equal(t, []uint64{second}, txs)
+
+	// Changes committed after the call are passed to the watcher.
+	fromTx := db.HighWatermark() + 1
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		txs, err = watch(fromTx, 1)
+	}()
+	third := insert()
+	<-done
+	require.ErrorIs(t, err, errDone)
+	require.Equal(t, []uint64{third}, txs)
+}
+
+func TestWatchChangesWithoutWAL(t *testing.T) {
+	ctx := context.Background()
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	_, err = db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	err = db.WatchChanges(ctx, 0, func(context.Context, Change) error { return nil })
+	require.ErrorIs(t, err, ErrChangesUnavailable)
+}
+
+func TestWatchChangesFallBehind(t *testing.T) {
+	for _, withWAL := range []bool{true, false} {
+		t.Run(fmt.Sprintf("wal=%t", withWAL), func(t *testing.T) {
+			ctx := context.Background()
+			options := []Option{WithLogger(newTestLogger(t))}
+			if withWAL {
+				options = append(options, WithWAL(), WithStoragePath(t.TempDir()))
+			}
+			c, err := New(options...)
+			require.NoError(t, err)
+			defer c.Close()
+
+			db, err := c.DB(ctx, "test")
+			require.NoError(t, err)
+			table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+			require.NoError(t, err)
+			r, err := dynparquet.NewTestSamples().ToRecord()
+			require.NoError(t, err)
+			defer r.Release()
+
+			// The callback blocks on the first change, while more changes
+			// are committed than are buffered.
+			const n = maxPendingChanges + 10
+			var (
+				txs     []uint64
+				entered = make(chan struct{})
+				release = make(chan struct{})
+				done    = make(chan error)
+			)
+			errDone := errors.New("done")
+			go func() {
+				done <- db.WatchChanges(ctx, db.HighWatermark()+1, func(_ context.Context, change Change) error {
+					if len(txs) == 0 {
+						close(entered)
+						<-release
+					}
+					txs = append(txs, change.Tx)
+					if len(txs) == n {
+						return errDone
+					}
+					return nil
+				})
+			}()
+
+			// Without the WAL, only the changes committed once the watcher is
+			// registered are delivered.
+			require.Eventually(t, func() bool {
+				db.changes.mtx.Lock()
+				defer db.changes.mtx.Unlock()
+				return len(db.changes.watchers) == 1
+			}, time.Second, time.Millisecond)
+
+			var inserted []uint64
+			insert := func() {
+				tx, err := table.InsertRecord(ctx, r)
+				require.NoError(t, err)
+				inserted = append(inserted, tx)
+			}
+			insert()
+			<-entered
+			for len(inserted) < n {
+				insert()
+			}
+			close(release)
+
+			err = <-done
+			if !withWAL {
+				require.ErrorIs(t, err, ErrChangesUnavailable)
+				return
+			}
+			// The dropped changes are read from the WAL.
+			require.ErrorIs(t, err, errDone)
+			require.Equal(t, inserted, txs)
+		})
+	}
+}
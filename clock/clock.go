@@ -0,0 +1,152 @@
+// Package clock abstracts the time of a column store, so that tests can drive
+// time deterministically, e.g. to compress days of block rotations and
+// periodic maintenance into seconds.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time and tickers.
+type Clock interface {
+	Now() time.Time
+	// NewTicker returns a ticker that sends the time on its channel every d.
+	NewTicker(d time.Duration) Ticker
+	// After returns a channel that receives the time once d elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// Ticker delivers ticks of a Clock at intervals.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real returns the clock of the system.
+func Real() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// Manual is a clock whose time only changes when it is advanced. It is safe
+// for concurrent use.
+type Manual struct {
+	mtx     sync.Mutex
+	now     time.Time
+	tickers map[*manualTicker]struct{}
+	timers  map[*manualTimer]struct{}
+}
+
+// NewManual returns a manual clock starting at the given time.
+func NewManual(now time.Time) *Manual {
+	return &Manual{
+		now:     now,
+		tickers: map[*manualTicker]struct{}{},
+		timers:  map[*manualTimer]struct{}{},
+	}
+}
+
+func (c *Manual) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+// Advance moves the time of the clock forward by d and fires the tickers and
+// timers that are due. Like the tickers of the system, a ticker that is due
+// several times delivers a single tick if its receiver is not ready.
+func (c *Manual) Advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.now = c.now.Add(d)
+	for t := range c.timers {
+		if c.now.Before(t.at) {
+			continue
+		}
+		t.c <- c.now
+		delete(c.timers, t)
+	}
+	for t := range c.tickers {
+		if c.now.Before(t.next) {
+			continue
+		}
+		select {
+		case t.c <- c.now:
+		default:
+		}
+		for !c.now.Before(t.next) {
+			t.next = t.next.Add(t.d)
+		}
+	}
+}
+
+// NewTicker returns a ticker that ticks whenever the clock was advanced by d
+// since its last tick.
+func (c *Manual) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for clock.Manual.NewTicker")
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	t := &manualTicker{
+		clock: c,
+		c:     make(chan time.Time, 1),
+		d:     d,
+		next:  c.now.Add(d),
+	}
+	c.tickers[t] = struct{}{}
+	return t
+}
+
+// After returns a channel that receives the time once the clock was advanced
+// by d.
+func (c *Manual) After(d time.Duration) <-chan time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	t := &manualTimer{
+		c:  make(chan time.Time, 1),
+		at: c.now.Add(d),
+	}
+	if d <= 0 {
+		t.c <- c.now
+		return t.c
+	}
+	c.timers[t] = struct{}{}
+	return t.c
+}
+
+type manualTimer struct {
+	c  chan time.Time
+	at time.Time
+}
+
+type manualTicker struct {
+	clock *Manual
+	c     chan time.Time
+	d     time.Duration
+	next  time.Time
+}
+
+func (t *manualTicker) C() <-chan time.Time { return t.c }
+
+func (t *manualTicker) Stop() {
+	t.clock.mtx.Lock()
+	defer t.clock.mtx.Unlock()
+	delete(t.clock.tickers, t)
+}
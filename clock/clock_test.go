@@ -0,0 +1,69 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManual(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewManual(start)
+	require.Equal(t, start, c.Now())
+
+	ticker := c.NewTicker(time.Minute)
+	ticked := func() bool {
+		select {
+		case <-ticker.C():
+			return true
+		default:
+			return false
+		}
+	}
+
+	c.Advance(30 * time.Second)
+	require.Equal(t, start.Add(30*time.Second), c.Now())
+	require.False(t, ticked())
+
+	c.Advance(30 * time.Second)
+	require.True(t, ticked())
+
+	// Missed ticks are dropped.
+	c.Advance(time.Hour)
+	require.True(t, ticked())
+	require.False(t, ticked())
+	c.Advance(59 * time.Second)
+	require.False(t, ticked())
+	c.Advance(time.Second)
+	require.True(t, ticked())
+
+	ticker.Stop()
+	c.Advance(time.Hour)
+	require.False(t, ticked())
+}
+
+func TestManualAfter(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewManual(start)
+	fired := func(ch <-chan time.Time) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	require.True(t, fired(c.After(0)))
+
+	after := c.After(time.Minute)
+	c.Advance(30 * time.Second)
+	require.False(t, fired(after))
+	c.Advance(time.Hour)
+	require.True(t, fired(after))
+
+	// Timers fire once.
+	c.Advance(time.Hour)
+	require.False(t, fired(after))
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/go-kit/log"
+	"github.com/parquet-go/parquet-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/polarsignals/frostdb"
+	walpb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/wal/v1alpha1"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/sqlparse"
+	"github.com/polarsignals/frostdb/wal"
+)
+
+const (
+	databasesDir  = "databases"
+	walDir        = "wal"
+	snapshotsDir  = "snapshots"
+	blockDataFile = "data.parquet"
+)
+
+func (c *cli) tables(ctx context.Context, _ []string) error {
+	type table struct{ db, name, source string }
+	var tables []table
+
+	if c.dir != "" {
+		store, err := c.open()
+		if err != nil {
+			return err
+		}
+		for _, dbName := range store.DBs() {
+			db, err := store.GetDB(dbName)
+			if err != nil {
+				return err
+			}
+			for _, name := range db.TableNames() {
+				tables = append(tables, table{dbName, name, "dir"})
+			}
+		}
+	}
+	if c.bucket != nil {
+		dbs, err := c.bucket.Prefixes(ctx, "")
+		if err != nil {
+			return err
+		}
+		for _, db := range dbs {
+			names, err := c.bucket.Prefixes(ctx, db)
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				tables = append(tables, table{db, name, "bucket"})
+			}
+		}
+	}
+
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].db != tables[j].db {
+			return tables[i].db < tables[j].db
+		}
+		if tables[i].name != tables[j].name {
+			return tables[i].name < tables[j].name
+		}
+		return tables[i].source < tables[j].source
+	})
+	w := c.table()
+	fmt.Fprintln(w, "DATABASE\tTABLE\tSOURCE")
+	for _, t := range tables {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.db, t.name, t.source)
+	}
+	return w.Flush()
+}
+
+func (c *cli) blocks(ctx context.Context, args []string) error {
+	if c.bucket == nil {
+		return errors.New("blocks requires -bucket")
+	}
+	blocks, err := c.bucket.Blocks(ctx, filepath.Join(args[0], args[1]))
+	if err != nil {
+		return err
+	}
+
+	w := c.table()
+	fmt.Fprintln(w, "BLOCK\tSIZE\tLAST MODIFIED")
+	for _, block := range blocks {
+		attrs, err := c.bucket.Attributes(ctx, filepath.Join(block, blockDataFile))
+		if err != nil {
+			return fmt.Errorf("block %s: %w", block, err)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", filepath.Base(block), attrs.Size, attrs.LastModified.UTC().Format("2006-01-02T15:04:05Z"))
+	}
+	return w.Flush()
+}
+
+// snapshotFiles returns the paths of the snapshots of the given database
+// ordered by their transaction.
+func (c *cli) snapshotFiles(db string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(c.dir, databasesDir, db, snapshotsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".fdbs") {
+			files = append(files, filepath.Join(c.dir, databasesDir, db, snapshotsDir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// snapshotTx returns the transaction of the snapshot at the given path.
+func snapshotTx(path string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), ".fdbs"), 10, 64)
+}
+
+func (c *cli) snapshots(_ context.Context, args []string) error {
+	if c.dir == "" {
+		return errors.New("snapshots requires -dir")
+	}
+	files, err := c.snapshotFiles(args[0])
+	if err != nil {
+		return err
+	}
+
+	w := c.table()
+	fmt.Fprintln(w, "TX\tSIZE")
+	for _, file := range files {
+		tx, err := snapshotTx(file)
+		if err != nil {
+			return fmt.Errorf("snapshot %s: %w", file, err)
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%d\t%d\n", tx, info.Size())
+	}
+	return w.Flush()
+}
+
+func (c *cli) schema(ctx context.Context, args []string) error {
+	if c.dir != "" {
+		table, err := c.getTable(args[0], args[1])
+		if err == nil {
+			b, err := protojson.MarshalOptions{Multiline: true}.Marshal(table.Schema().Definition())
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(c.out, string(b))
+			return err
+		}
+		if c.bucket == nil {
+			return err
+		}
+	}
+
+	// The table only exists in the bucket, whose blocks hold the Parquet
+	// schema but not the definition of the table's schema.
+	blocks, err := c.bucket.Blocks(ctx, filepath.Join(args[0], args[1]))
+	if err != nil {
+		return err
+	}
+	if len(blocks) == 0 {
+		return fmt.Errorf("table %s/%s not found", args[0], args[1])
+	}
+	sort.Strings(blocks)
+	file, err := c.openBlock(ctx, blocks[len(blocks)-1])
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(c.out, file.Schema())
+	return err
+}
+
+func (c *cli) openBlock(ctx context.Context, block string) (*parquet.File, error) {
+	name := filepath.Join(block, blockDataFile)
+	attrs, err := c.bucket.Attributes(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.bucket.GetReaderAt(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return parquet.OpenFile(r, attrs.Size)
+}
+
+// getTable returns the table of the column store of the data directory.
+// Tables only present in the bucket have no schema and cannot be returned.
+func (c *cli) getTable(dbName, name string) (*frostdb.Table, error) {
+	store, err := c.open()
+	if err != nil {
+		return nil, err
+	}
+	db, err := store.GetDB(dbName)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetTable(name)
+}
+
+func (c *cli) query(ctx context.Context, args []string) error {
+	if c.dir == "" {
+		return errors.New("query requires -dir, tables only present in the bucket have no schema")
+	}
+	table, err := c.getTable(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	db, err := c.store.GetDB(args[0])
+	if err != nil {
+		return err
+	}
+
+	var dynColNames []string
+	for _, col := range table.Schema().Columns() {
+		if col.Dynamic {
+			dynColNames = append(dynColNames, col.Name)
+		}
+	}
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	res, err := sqlparse.NewParser().ExperimentalParse(engine.ScanTable(args[1]), dynColNames, args[2])
+	if err != nil {
+		return err
+	}
+	if res.Explain {
+		s, err := res.Plan.Explain(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(c.out, s)
+		return err
+	}
+
+	// Results are collected first since the schema of their records may
+	// differ and the callback may be called concurrently.
+	var records []arrow.Record
+	defer func() {
+		for _, r := range records {
+			r.Release()
+		}
+	}()
+	var mtx sync.Mutex
+	if err := res.Plan.Execute(ctx, func(_ context.Context, r arrow.Record) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if r.NumRows() > 0 {
+			r.Retain()
+			records = append(records, r)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	w := c.table()
+	var schema *arrow.Schema
+	for _, r := range records {
+		if schema == nil || !schema.Equal(r.Schema()) {
+			if schema != nil {
+				fmt.Fprintln(w)
+			}
+			schema = r.Schema()
+			names := make([]string, 0, len(schema.Fields()))
+			for _, field := range schema.Fields() {
+				names = append(names, field.Name)
+			}
+			fmt.Fprintln(w, strings.Join(names, "\t"))
+		}
+		for i := 0; i < int(r.NumRows()); i++ {
+			values := make([]string, 0, r.NumCols())
+			for _, col := range r.Columns() {
+				values = append(values, formatValue(col, i))
+			}
+			fmt.Fprintln(w, strings.Join(values, "\t"))
+		}
+	}
+	return w.Flush()
+}
+
+// formatValue formats the value at index i of the given array. Binary values
+// are formatted as strings, since frostdb stores strings as binary.
+func formatValue(arr arrow.Array, i int) string {
+	if arr.IsNull(i) {
+		return "null"
+	}
+	switch arr := arr.(type) {
+	case *array.Dictionary:
+		return formatValue(arr.Dictionary(), arr.GetValueIndex(i))
+	case *array.Binary:
+		return string(arr.Value(i))
+	default:
+		return arr.ValueStr(i)
+	}
+}
+
+func (c *cli) verify(ctx context.Context, _ []string) error {
+	failed := 0
+	w := c.table()
+	report := func(kind, name string, err error) {
+		status := "ok"
+		if err != nil {
+			status = err.Error()
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", kind, name, status)
+	}
+
+	if c.dir != "" {
+		// The WAL is replayed from a copy since replaying a corrupt WAL
+		// truncates it.
+		dir, err := c.copyDir()
+		if err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(filepath.Join(c.dir, databasesDir))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, entry := range entries {
+			db := entry.Name()
+			if _, err := os.Stat(filepath.Join(dir, databasesDir, db, walDir)); err == nil {
+				report("wal", db, verifyWAL(filepath.Join(dir, databasesDir, db, walDir)))
+			}
+
+			files, err := c.snapshotFiles(db)
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				report("snapshot", filepath.Join(db, filepath.Base(file)), verifySnapshot(ctx, file))
+			}
+		}
+	}
+
+	if c.bucket != nil {
+		dbs, err := c.bucket.Prefixes(ctx, "")
+		if err != nil {
+			return err
+		}
+		for _, db := range dbs {
+			tables, err := c.bucket.Prefixes(ctx, db)
+			if err != nil {
+				return err
+			}
+			for _, table := range tables {
+				blocks, err := c.bucket.Blocks(ctx, filepath.Join(db, table))
+				if err != nil {
+					return err
+				}
+				for _, block := range blocks {
+					err := c.bucket.VerifyBlock(ctx, block)
+					if errors.Is(err, frostdb.ErrBlockUnverifiable) {
+						fmt.Fprintf(w, "block\t%s\tunverifiable, no manifest\n", block)
+						continue
+					}
+					report("block", block, err)
+				}
+			}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
+
+// verifyWAL replays the WAL in the given directory and decodes its writes.
+func verifyWAL(dir string) error {
+	w, err := wal.Open(log.NewNopLogger(), prometheus.NewRegistry(), dir)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	lastIndex, err := w.LastIndex()
+	if err != nil {
+		return err
+	}
+	var lastTx uint64
+	if err := w.Replay(0, func(tx uint64, record *walpb.Record) error {
+		lastTx = tx
+		write := record.GetEntry().GetWrite()
+		if write == nil {
+			return nil
+		}
+		reader, err := ipc.NewReader(bytes.NewReader(write.Data))
+		if err != nil {
+			return fmt.Errorf("tx %d: decode write: %w", tx, err)
+		}
+		defer reader.Release()
+		for reader.Next() {
+		}
+		if err := reader.Err(); err != nil {
+			return fmt.Errorf("tx %d: decode write: %w", tx, err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	// Replay stops and truncates the WAL at the first corrupt record.
+	if lastTx != lastIndex {
+		return fmt.Errorf("corrupt record at tx %d", lastTx+1)
+	}
+	return nil
+}
+
+// verifySnapshot loads the snapshot at the given path into an empty
+// database.
+func verifySnapshot(ctx context.Context, path string) error {
+	tx, err := snapshotTx(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	store, err := frostdb.New()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	db, err := store.DB(ctx, "verify")
+	if err != nil {
+		return err
+	}
+	_, err = frostdb.LoadSnapshot(ctx, db, tx, f, info.Size(), false)
+	return err
+}
+
+func (c *cli) export(_ context.Context, args []string) error {
+	if c.dir == "" {
+		return errors.New("export requires -dir, the blocks of a bucket are Parquet files already")
+	}
+	table, err := c.getTable(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(args[2])
+	if err != nil {
+		return err
+	}
+	if err := table.ActiveBlock().Serialize(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,230 @@
+// Command frostdb inspects and queries frostdb data directories and buckets.
+//
+// Usage:
+//
+//	frostdb [-dir <path>] [-bucket <path>] <command> [arguments]
+//
+// The data directory is the storage path of a column store with a WAL, the
+// bucket is a filesystem bucket holding persisted blocks. Both are opened
+// read-only: replaying a WAL truncates it if its tail is corrupt, so the data
+// directory is copied to a temporary directory before it is replayed. The
+// commands are:
+//
+//	tables                          list the tables of all databases
+//	blocks <db> <table>             list the blocks of a table in the bucket
+//	snapshots <db>                  list the snapshots of a database
+//	schema <db> <table>             print the schema of a table
+//	query <db> <table> <sql>        run a SQL query on a table
+//	verify                          verify WAL, snapshots and blocks
+//	export <db> <table> <file>      export a table to a Parquet file
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/thanos-io/objstore/providers/filesystem"
+
+	"github.com/polarsignals/frostdb"
+)
+
+const usage = `Usage: frostdb [-dir <path>] [-bucket <path>] <command> [arguments]
+
+Commands:
+  tables                          list the tables of all databases
+  blocks <db> <table>             list the blocks of a table in the bucket
+  snapshots <db>                  list the snapshots of a database
+  schema <db> <table>             print the schema of a table
+  query <db> <table> <sql>        run a SQL query on a table
+  verify                          verify WAL, snapshots and blocks
+  export <db> <table> <file>      export a table to a Parquet file
+
+Flags:
+`
+
+type command struct {
+	args int
+	run  func(c *cli, ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"tables":    {0, (*cli).tables},
+	"blocks":    {2, (*cli).blocks},
+	"snapshots": {1, (*cli).snapshots},
+	"schema":    {2, (*cli).schema},
+	"query":     {3, (*cli).query},
+	"verify":    {0, (*cli).verify},
+	"export":    {3, (*cli).export},
+}
+
+func main() {
+	flags := flag.NewFlagSet("frostdb", flag.ExitOnError)
+	dir := flags.String("dir", "", "Storage path of the column store holding the WAL and snapshots.")
+	bucket := flags.String("bucket", "", "Path of a filesystem bucket holding persisted blocks.")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(os.Args[1:])
+
+	args := flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[args[0]]
+	if !ok || len(args)-1 != cmd.args {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	c, err := newCLI(*dir, *bucket, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	err = cmd.run(c, context.Background(), args[1:])
+	if closeErr := c.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// cli holds the data directory and bucket the commands operate on.
+type cli struct {
+	dir    string
+	bucket *frostdb.DefaultObjstoreBucket
+	out    io.Writer
+
+	// tmpDir is the copy of the data directory that is replayed. It is
+	// created lazily since some commands only read the files of the data
+	// directory.
+	tmpDir string
+	// store is opened lazily for the same reason.
+	store *frostdb.ColumnStore
+}
+
+func newCLI(dir, bucket string, out io.Writer) (*cli, error) {
+	if dir == "" && bucket == "" {
+		return nil, errors.New("one of -dir and -bucket must be set")
+	}
+	c := &cli{dir: dir, out: out}
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+	}
+	if bucket != "" {
+		if _, err := os.Stat(bucket); err != nil {
+			return nil, err
+		}
+		b, err := filesystem.NewBucket(bucket)
+		if err != nil {
+			return nil, err
+		}
+		c.bucket = frostdb.NewDefaultObjstoreBucket(b)
+	}
+	return c, nil
+}
+
+// open opens the column store of the data directory and bucket. The store
+// loads the latest snapshot and replays the WAL of every database from a copy
+// of the data directory. Since it has no data sink, it does not write to the
+// bucket.
+func (c *cli) open() (*frostdb.ColumnStore, error) {
+	if c.store != nil {
+		return c.store, nil
+	}
+	var options []frostdb.Option
+	if c.dir != "" {
+		dir, err := c.copyDir()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, frostdb.WithWAL(), frostdb.WithStoragePath(dir))
+	}
+	if c.bucket != nil {
+		options = append(options, frostdb.WithReadOnlyStorage(c.bucket))
+	}
+	store, err := frostdb.New(options...)
+	if err != nil {
+		return nil, fmt.Errorf("open column store: %w", err)
+	}
+	c.store = store
+	return store, nil
+}
+
+// copyDir returns a copy of the data directory.
+func (c *cli) copyDir() (string, error) {
+	if c.tmpDir != "" {
+		return c.tmpDir, nil
+	}
+	tmpDir, err := os.MkdirTemp("", "frostdb")
+	if err != nil {
+		return "", err
+	}
+	c.tmpDir = tmpDir
+	if err := copyDir(c.dir, tmpDir); err != nil {
+		return "", fmt.Errorf("copy data directory: %w", err)
+	}
+	return tmpDir, nil
+}
+
+func copyDir(from, to string) error {
+	return filepath.WalkDir(from, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		dst, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(dst, src); err != nil {
+			dst.Close()
+			return err
+		}
+		return dst.Close()
+	})
+}
+
+func (c *cli) close() error {
+	var err error
+	if c.store != nil {
+		err = c.store.Close()
+	}
+	if c.tmpDir != "" {
+		if rmErr := os.RemoveAll(c.tmpDir); err == nil {
+			err = rmErr
+		}
+	}
+	return err
+}
+
+// table returns a tab writer writing to the output.
+func (c *cli) table() *tabwriter.Writer {
+	return tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+}
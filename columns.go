@@ -0,0 +1,189 @@
+package frostdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+
+	"github.com/polarsignals/frostdb/pqarrow/convert"
+)
+
+// ColumnData holds the values of a column inserted with InsertColumns. Exactly
+// one of the value slices must be set, matching the type of the column.
+type ColumnData struct {
+	Int64s   []int64
+	Float64s []float64
+	Strings  []string
+	Bools    []bool
+	// Valid marks the values that are not null. All values are valid if nil.
+	Valid []bool
+}
+
+func (c ColumnData) len() int {
+	switch {
+	case c.Int64s != nil:
+		return len(c.Int64s)
+	case c.Float64s != nil:
+		return len(c.Float64s)
+	case c.Strings != nil:
+		return len(c.Strings)
+	case c.Bools != nil:
+		return len(c.Bools)
+	default:
+		return len(c.Valid)
+	}
+}
+
+func (c ColumnData) kinds() int {
+	n := 0
+	for _, set := range []bool{c.Int64s != nil, c.Float64s != nil, c.Strings != nil, c.Bools != nil} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// InsertColumns inserts rows given as columns of values, keyed by column name.
+// Dynamic columns are keyed by their concrete name, e.g. "labels.namespace".
+// All the columns must have the same number of values. This avoids building a
+// row or struct representation of the data for writers that already hold
+// columnar data. Repeated columns are not supported.
+func (t *Table) InsertColumns(ctx context.Context, columns map[string]ColumnData) (uint64, error) {
+	record, err := t.columnsToRecord(columns)
+	if err != nil {
+		return 0, err
+	}
+	defer record.Release()
+	return t.InsertRecord(ctx, record)
+}
+
+func (t *Table) columnsToRecord(columns map[string]ColumnData) (arrow.Record, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("no columns to insert")
+	}
+
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	// The columns of records are ordered by name, like the columns of the
+	// schema.
+	sort.Strings(names)
+
+	numRows := columns[names[0]].len()
+	fields := make([]arrow.Field, 0, len(names))
+	arrays := make([]arrow.Array, 0, len(names))
+	defer func() {
+		for _, arr := range arrays {
+			arr.Release()
+		}
+	}()
+	mem := memory.NewGoAllocator()
+	for _, name := range names {
+		data := columns[name]
+		if data.len() != numRows {
+			return nil, fmt.Errorf("column %q has %d values, expected %d", name, data.len(), numRows)
+		}
+		if data.Valid != nil && len(data.Valid) != numRows {
+			return nil, fmt.Errorf("column %q has %d validity values, expected %d", name, len(data.Valid), numRows)
+		}
+
+		def, ok := t.schema.ColumnByName(name)
+		if !ok {
+			dynamic, _, found := strings.Cut(name, ".")
+			def, ok = t.schema.ColumnByName(dynamic)
+			if !found || !ok || !def.Dynamic {
+				return nil, fmt.Errorf("column %q not found in schema", name)
+			}
+		} else if def.Dynamic {
+			return nil, fmt.Errorf("dynamic column %q must be inserted by concrete column name", name)
+		}
+		if def.StorageLayout.Repeated() {
+			return nil, fmt.Errorf("repeated column %q is not supported", name)
+		}
+		if data.Valid != nil && !def.StorageLayout.Optional() && !def.Dynamic {
+			return nil, fmt.Errorf("column %q is not nullable", name)
+		}
+
+		typ, err := convert.ParquetNodeToType(def.StorageLayout)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", name, err)
+		}
+		arr, err := columnArray(mem, typ, data)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", name, err)
+		}
+		arrays = append(arrays, arr)
+		fields = append(fields, arrow.Field{
+			Name:     name,
+			Type:     typ,
+			Nullable: def.StorageLayout.Optional() || def.Dynamic,
+		})
+	}
+
+	for _, def := range t.schema.Columns() {
+		if def.Dynamic || def.StorageLayout.Optional() {
+			continue
+		}
+		if _, ok := columns[def.Name]; !ok {
+			return nil, fmt.Errorf("missing non-nullable column %q", def.Name)
+		}
+	}
+
+	return array.NewRecord(arrow.NewSchema(fields, nil), arrays, int64(numRows)), nil
+}
+
+// columnArray returns an array of the given type holding the column's values.
+func columnArray(mem memory.Allocator, typ arrow.DataType, data ColumnData) (arrow.Array, error) {
+	if data.kinds() > 1 {
+		return nil, errors.New("more than one type of values")
+	}
+
+	b := array.NewBuilder(mem, typ)
+	defer b.Release()
+	switch b := b.(type) {
+	case *array.Int64Builder:
+		if data.Int64s == nil {
+			return nil, fmt.Errorf("expected int64 values for %s column", typ)
+		}
+		b.AppendValues(data.Int64s, data.Valid)
+	case *array.Float64Builder:
+		if data.Float64s == nil {
+			return nil, fmt.Errorf("expected float64 values for %s column", typ)
+		}
+		b.AppendValues(data.Float64s, data.Valid)
+	case *array.BooleanBuilder:
+		if data.Bools == nil {
+			return nil, fmt.Errorf("expected bool values for %s column", typ)
+		}
+		b.AppendValues(data.Bools, data.Valid)
+	case *array.BinaryBuilder:
+		if data.Strings == nil {
+			return nil, fmt.Errorf("expected string values for %s column", typ)
+		}
+		b.AppendStringValues(data.Strings, data.Valid)
+	case *array.BinaryDictionaryBuilder:
+		if data.Strings == nil {
+			return nil, fmt.Errorf("expected string values for %s column", typ)
+		}
+		for i, v := range data.Strings {
+			if data.Valid != nil && !data.Valid[i] {
+				b.AppendNull()
+				continue
+			}
+			if err := b.AppendString(v); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported column type %s", typ)
+	}
+	return b.NewArray(), nil
+}
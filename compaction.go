@@ -0,0 +1,174 @@
+package frostdb
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"unsafe"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/util"
+	"github.com/parquet-go/parquet-go"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/pqarrow"
+)
+
+// concurrentCompaction returns the number of rows of the row groups that
+// compactions of the table encode concurrently, or 0 if row groups are
+// encoded one after the other.
+func (t *Table) concurrentCompaction() int {
+	if t.db.columnStore.compactionConcurrency <= 1 {
+		return 0
+	}
+	return int(t.config.Load().RowGroupSize)
+}
+
+// writeRowGroupsConcurrently writes the rows read from rows to w as row groups
+// of the given number of rows. The rows are read one row group at a time, and
+// up to the compaction concurrency of the column store row groups are encoded
+// concurrently, each into a file of its own. The files are concatenated into w
+// in order once all of them are encoded. The rows buffered for encoding count
+// towards the compaction memory budget of the column store, which is shared by
+// the compactions of all tables.
+func (t *Table) writeRowGroupsConcurrently(w io.Writer, rows parquet.RowReader, dynamicColumns map[string][]string, rowGroupSize int) error {
+	return t.encodeConcurrently(w, dynamicColumns, func(encode func(weight int64, write func(dynparquet.ParquetWriter) error) error) error {
+		for {
+			batch, err := readRowGroup(rows, rowGroupSize)
+			if err != nil {
+				return err
+			}
+			if len(batch) == 0 {
+				return nil
+			}
+			if err := encode(rowsSize(batch), func(pw dynparquet.ParquetWriter) error {
+				_, err := pw.WriteRows(batch)
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// writeRecordConcurrently is writeRowGroupsConcurrently for a sorted record,
+// which is sliced into row groups of the given number of rows.
+func (t *Table) writeRecordConcurrently(w io.Writer, record arrow.Record, rowGroupSize int) error {
+	rowSize := util.TotalRecordSize(record) / max(record.NumRows(), 1)
+	return t.encodeConcurrently(w, pqarrow.RecordDynamicCols(record), func(encode func(weight int64, write func(dynparquet.ParquetWriter) error) error) error {
+		for offset := int64(0); offset < record.NumRows(); offset += int64(rowGroupSize) {
+			slice := record.NewSlice(offset, min(offset+int64(rowGroupSize), record.NumRows()))
+			if err := encode(rowSize*slice.NumRows(), func(pw dynparquet.ParquetWriter) error {
+				defer slice.Release()
+				return pqarrow.RecordsToFile(t.schema, pw, []arrow.Record{slice})
+			}); err != nil {
+				slice.Release()
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// encodeConcurrently calls produce with a function that encodes a row group
+// written by write in the background. The weight is the memory the row group
+// holds until it is encoded. The encoded row groups are concatenated into w in
+// the order they were produced.
+func (t *Table) encodeConcurrently(
+	w io.Writer,
+	dynamicColumns map[string][]string,
+	produce func(encode func(weight int64, write func(dynparquet.ParquetWriter) error) error) error,
+) error {
+	cs := t.db.columnStore
+	g, ctx := errgroup.WithContext(context.Background())
+	g.SetLimit(cs.compactionConcurrency)
+
+	var bufs []*bytes.Buffer
+	err := produce(func(weight int64, write func(dynparquet.ParquetWriter) error) error {
+		// A row group larger than the budget is encoded on its own.
+		weight = min(max(weight, 1), cs.compactionBudgetSize)
+		if err := cs.compactionBudget.Acquire(ctx, weight); err != nil {
+			return err
+		}
+		b := &bytes.Buffer{}
+		bufs = append(bufs, b)
+		g.Go(func() error {
+			defer cs.compactionBudget.Release(weight)
+			pw, err := t.schema.GetWriter(b, dynamicColumns, false)
+			if err != nil {
+				return err
+			}
+			defer t.schema.PutWriter(pw)
+			if err := write(pw); err != nil {
+				return err
+			}
+			return pw.Close()
+		})
+		return nil
+	})
+	// An encoding error cancels the context, so it takes precedence over the
+	// error of producing the row groups.
+	if waitErr := g.Wait(); waitErr != nil {
+		err = waitErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(bufs) == 0 {
+		pw, err := t.schema.GetWriter(w, dynamicColumns, false)
+		if err != nil {
+			return err
+		}
+		defer t.schema.PutWriter(pw)
+		return pw.Close()
+	}
+	files := make([]*parquet.File, 0, len(bufs))
+	for _, b := range bufs {
+		buf, err := dynparquet.ReaderFromBytes(b.Bytes())
+		if err != nil {
+			return err
+		}
+		files = append(files, buf.ParquetFile())
+	}
+	return dynparquet.ConcatFiles(w, files)
+}
+
+// readRowGroup reads up to n rows from rows. The rows are copied as readers
+// may reuse the memory of the rows they return.
+func readRowGroup(rows parquet.RowReader, n int) ([]parquet.Row, error) {
+	batch := make([]parquet.Row, n)
+	read := 0
+	for read < n {
+		m, err := rows.ReadRows(batch[read:])
+		for i := read; i < read+m; i++ {
+			batch[i] = batch[i].Clone()
+		}
+		read += m
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if m == 0 {
+			break
+		}
+	}
+	return batch[:read], nil
+}
+
+// rowsSize returns an estimate of the memory held by the rows.
+func rowsSize(rows []parquet.Row) int64 {
+	size := int64(0)
+	for _, row := range rows {
+		size += int64(len(row)) * int64(unsafe.Sizeof(parquet.Value{}))
+		for _, v := range row {
+			if v.Kind() == parquet.ByteArray || v.Kind() == parquet.FixedLenByteArray {
+				size += int64(len(v.ByteArray()))
+			}
+		}
+	}
+	return size
+}
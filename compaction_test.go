@@ -0,0 +1,237 @@
+package frostdb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/parquet-go/parquet-go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/index"
+	"github.com/polarsignals/frostdb/parts"
+	"github.com/polarsignals/frostdb/query"
+)
+
+func TestTableConcurrentCompaction(t *testing.T) {
+	for name, options := range map[string][]Option{
+		"records":      {WithCompactionConcurrency(4)},
+		"parquet":      {WithCompactionConcurrency(4), WithActivePartCompression()},
+		"small budget": {WithCompactionConcurrency(4), WithCompactionMemoryBudget(1)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := New(options...)
+			require.NoError(t, err)
+			defer c.Close()
+
+			db, err := c.DB(context.Background(), "test")
+			require.NoError(t, err)
+			table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition(), WithRowGroupSize(4)))
+			require.NoError(t, err)
+
+			ctx := context.Background()
+			const numInserts, rowsPerInsert = 3, 10
+			for i := 0; i < numInserts; i++ {
+				samples := make(dynparquet.Samples, 0, rowsPerInsert)
+				for j := 0; j < rowsPerInsert; j++ {
+					ts := int64(j*numInserts + i)
+					samples = append(samples, dynparquet.Sample{
+						ExampleType: "cpu",
+						Labels:      map[string]string{"node": "test"},
+						Timestamp:   ts,
+						Value:       ts,
+					})
+				}
+				r, err := samples.ToRecord()
+				require.NoError(t, err)
+				_, err = table.InsertRecord(ctx, r)
+				require.NoError(t, err)
+				r.Release()
+			}
+			require.NoError(t, table.EnsureCompaction())
+
+			// The compacted part is made of row groups of the row group size
+			// that are sorted across row groups.
+			numParts, numRowGroups := 0, 0
+			var timestamps []int64
+			table.ActiveBlock().Index().Iterate(func(node *index.Node) bool {
+				if node.Part() == nil {
+					return true
+				}
+				numParts++
+				buf, err := node.Part().AsSerializedBuffer(nil)
+				require.NoError(t, err)
+				numRowGroups += buf.NumRowGroups()
+				leaf, ok := buf.ParquetFile().Schema().Lookup("timestamp")
+				require.True(t, ok)
+				rows := buf.MultiDynamicRowGroup().Rows()
+				defer rows.Close()
+				rowBuf := make([]parquet.Row, 10)
+				for {
+					n, err := rows.ReadRows(rowBuf)
+					for _, row := range rowBuf[:n] {
+						for _, v := range row {
+							if v.Column() == leaf.ColumnIndex {
+								timestamps = append(timestamps, v.Int64())
+							}
+						}
+					}
+					if err == io.EOF || n == 0 {
+						return true
+					}
+					require.NoError(t, err)
+				}
+			})
+			require.Equal(t, 1, numParts)
+			require.Equal(t, (numInserts*rowsPerInsert+3)/4, numRowGroups)
+			expected := make([]int64, numInserts*rowsPerInsert)
+			for i := range expected {
+				expected[i] = int64(i)
+			}
+			require.Equal(t, expected, timestamps)
+
+			rows := int64(0)
+			engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+			err = engine.ScanTable("test").
+				Execute(ctx, func(_ context.Context, r arrow.Record) error {
+					rows += r.NumRows()
+					return nil
+				})
+			require.NoError(t, err)
+			require.Equal(t, int64(len(expected)), rows)
+		})
+	}
+}
+
+func TestTableCompactionConcatenatesNonOverlappingParts(t *testing.T) {
+	for name, tc := range map[string]struct {
+		labels       []string
+		numRowGroups int
+		concats      float64
+		values       []int64
+	}{
+		"same schema": {
+			labels:       []string{"node", "node", "node"},
+			numRowGroups: 3,
+			concats:      1,
+			values:       []int64{0, 0, 0, 1, 1, 1, 2, 2, 2},
+		},
+		// Parts with different dynamic columns are merged, the null labels of
+		// the other parts sorting first.
+		"different schemas": {
+			labels:       []string{"node", "pod", "zone"},
+			numRowGroups: 1,
+			concats:      0,
+			values:       []int64{2, 2, 2, 1, 1, 1, 0, 0, 0},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := New(WithActivePartCompression())
+			require.NoError(t, err)
+			defer c.Close()
+
+			db, err := c.DB(context.Background(), "test")
+			require.NoError(t, err)
+			table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+			require.NoError(t, err)
+
+			// Each insert covers a distinct range of the sort key, inserted in
+			// reverse order.
+			ctx := context.Background()
+			for i := len(tc.labels) - 1; i >= 0; i-- {
+				samples := dynparquet.Samples{}
+				for ts := int64(0); ts < 3; ts++ {
+					samples = append(samples, dynparquet.Sample{
+						ExampleType: "cpu",
+						Labels:      map[string]string{tc.labels[i]: fmt.Sprintf("value%d", i)},
+						Timestamp:   ts,
+						Value:       int64(i),
+					})
+				}
+				r, err := samples.ToRecord()
+				require.NoError(t, err)
+				_, err = table.InsertRecord(ctx, r)
+				require.NoError(t, err)
+				r.Release()
+			}
+			require.NoError(t, table.EnsureCompaction())
+			require.Equal(t, tc.concats, testutil.ToFloat64(table.metrics.partConcats))
+
+			numRowGroups := 0
+			var values []int64
+			table.ActiveBlock().Index().Iterate(func(node *index.Node) bool {
+				if node.Part() == nil {
+					return true
+				}
+				buf, err := node.Part().AsSerializedBuffer(nil)
+				require.NoError(t, err)
+				numRowGroups += buf.NumRowGroups()
+				leaf, ok := buf.ParquetFile().Schema().Lookup("value")
+				require.True(t, ok)
+				rows := buf.MultiDynamicRowGroup().Rows()
+				defer rows.Close()
+				rowBuf := make([]parquet.Row, 10)
+				for {
+					n, err := rows.ReadRows(rowBuf)
+					for _, row := range rowBuf[:n] {
+						for _, v := range row {
+							if v.Column() == leaf.ColumnIndex {
+								values = append(values, v.Int64())
+							}
+						}
+					}
+					if err == io.EOF || n == 0 {
+						return true
+					}
+					require.NoError(t, err)
+				}
+			})
+			require.Equal(t, tc.numRowGroups, numRowGroups)
+			require.Equal(t, tc.values, values)
+		})
+	}
+}
+
+// noMergePolicy never merges any parts.
+type noMergePolicy struct{}
+
+func (noMergePolicy) ShouldCompact(index.LevelState) bool { return false }
+
+func (noMergePolicy) Plan(index.LevelState, []parts.Part) [][]parts.Part { return nil }
+
+func TestTableMergePolicy(t *testing.T) {
+	c, err := New(WithMergePolicy(noMergePolicy{}))
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	samples := dynparquet.NewTestSamples()
+	for i := 0; i < 3; i++ {
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+		r.Release()
+	}
+	require.NoError(t, table.EnsureCompaction())
+
+	numParts := 0
+	table.ActiveBlock().Index().Iterate(func(node *index.Node) bool {
+		if node.Part() != nil {
+			numParts++
+			require.Equal(t, 0, node.Part().CompactionLevel())
+		}
+		return true
+	})
+	require.Equal(t, 3, numParts)
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -24,9 +25,14 @@ import (
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/maps"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/polarsignals/frostdb/accounting"
+	"github.com/polarsignals/frostdb/audit"
+	"github.com/polarsignals/frostdb/clock"
 	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/encryption"
 	schemapb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/schema/v1alpha1"
 	schemav2pb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/schema/v1alpha2"
 	tablepb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/table/v1alpha1"
@@ -73,6 +79,67 @@ type ColumnStore struct {
 	compactAfterRecovery           bool
 	compactAfterRecoveryTableNames []string
 
+	// encryptionKeys is set if the WAL and snapshots should be encrypted at
+	// rest.
+	encryptionKeys encryption.KeyProvider
+
+	// integrityScrubInterval is the interval at which the integrity of
+	// persisted blocks is verified. Disabled if 0.
+	integrityScrubInterval time.Duration
+
+	auditSinks []audit.Sink
+
+	partEventCallbacks []func(PartEvent)
+	scanHook           ScanHook
+
+	// accountant tracks the memory used by the column store against a
+	// process level budget. Disabled if nil.
+	accountant *accounting.Accountant
+	untrack    []func()
+	// caches are the caches whose memory is reclaimed when the memory in use
+	// exceeds the high watermark of the accountant.
+	caches           []EvictableCache
+	evictionInterval time.Duration
+
+	backpressure BackpressureConfig
+
+	sortingAdvisor         bool
+	sortingAdvisorInterval time.Duration
+
+	scanSharing       bool
+	scanSharingWindow time.Duration
+
+	activePartCompression bool
+
+	// writeBufferInterval and writeBufferSize are the interval and the
+	// staged size at which the write buffers of the tables are flushed.
+	// Inserts are added to the index right away if the interval is 0.
+	writeBufferInterval time.Duration
+	writeBufferSize     int64
+
+	// clock is the time of the column store, e.g. of the timestamps of
+	// blocks and of periodic maintenance.
+	clock clock.Clock
+
+	// logSamplingBurst is the number of high-frequency log events of each
+	// kind logged per logSamplingInterval and table.
+	logSamplingBurst    int
+	logSamplingInterval time.Duration
+
+	// schemas are the schemas of the tables, shared by tables with the same
+	// schema definition.
+	schemas *schemaRegistry
+
+	// compactionConcurrency is the number of row groups a compaction encodes
+	// concurrently. compactionBudget limits the memory of the rows buffered
+	// for encoding by all compactions of the column store.
+	compactionConcurrency int
+	compactionBudgetSize  int64
+	compactionBudget      *semaphore.Weighted
+	// mergePolicy decides when the indexes of the tables are compacted, the
+	// default policy of the index is used if nil.
+	mergePolicy index.MergePolicy
+
 	// testingOptions are options only used for testing purposes.
 	testingOptions struct {
 		disableReclaimDiskSpaceOnSnapshot bool
@@ -91,16 +158,23 @@ func New(
 	options ...Option,
 ) (*ColumnStore, error) {
 	s := &ColumnStore{
-		dbs:                 make(map[string]*DB),
-		dbReplaysInProgress: make(map[string]chan struct{}),
-		reg:                 prometheus.NewRegistry(),
-		logger:              log.NewNopLogger(),
-		tracer:              trace.NewNoopTracerProvider().Tracer(""),
-		indexConfig:         DefaultIndexConfig(),
-		indexDegree:         2,
-		splitSize:           2,
-		granuleSizeBytes:    1 * MiB,
-		activeMemorySize:    512 * MiB,
+		dbs:                   make(map[string]*DB),
+		dbReplaysInProgress:   make(map[string]chan struct{}),
+		reg:                   prometheus.NewRegistry(),
+		logger:                log.NewNopLogger(),
+		tracer:                trace.NewNoopTracerProvider().Tracer(""),
+		indexConfig:           DefaultIndexConfig(),
+		indexDegree:           2,
+		splitSize:             2,
+		granuleSizeBytes:      1 * MiB,
+		activeMemorySize:      512 * MiB,
+		schemas:               newSchemaRegistry(),
+		clock:                 clock.Real(),
+		logSamplingBurst:      defaultLogSamplingBurst,
+		logSamplingInterval:   defaultLogSamplingInterval,
+		compactionConcurrency: 1,
+		compactionBudgetSize:  128 * MiB,
+		evictionInterval:      time.Second,
 	}
 
 	for _, option := range options {
@@ -108,6 +182,7 @@ func New(
 			return nil, err
 		}
 	}
+	s.compactionBudget = semaphore.NewWeighted(s.compactionBudgetSize)
 
 	s.metrics = metrics{
 		shutdownDuration: promauto.With(s.reg).NewHistogram(prometheus.HistogramOpts{
@@ -132,6 +207,8 @@ func New(
 		return nil, err
 	}
 
+	s.trackMemory()
+
 	return s, nil
 }
 
@@ -227,6 +304,18 @@ func WithStoragePath(path string) Option {
 	}
 }
 
+// WithActivePartCompression makes the parts of the active blocks of the tables
+// hold their rows encoded with lightweight encodings, dictionary encoding for
+// strings and delta encoding for integers, instead of decoded records. This
+// increases how much recent data fits in memory before a block is rotated, at
+// the cost of decoding the parts when they are read.
+func WithActivePartCompression() Option {
+	return func(s *ColumnStore) error {
+		s.activePartCompression = true
+		return nil
+	}
+}
+
 func WithIndexConfig(indexConfig []*IndexConfig) Option {
 	return func(s *ColumnStore) error {
 		s.indexConfig = indexConfig
@@ -273,6 +362,79 @@ func WithRecoveryConcurrency(concurrency int) Option {
 	}
 }
 
+// WithEncryption encrypts the WAL and snapshots at rest using keys provided by
+// the given KeyProvider. WAL records and snapshots written before encryption
+// was enabled can still be read. To encrypt blocks persisted to object
+// storage, wrap the bucket with encryption.NewBucket.
+func WithEncryption(keys encryption.KeyProvider) Option {
+	return func(s *ColumnStore) error {
+		s.encryptionKeys = keys
+		return nil
+	}
+}
+
+// WithClock sets the clock the column store takes the time from, e.g. for the
+// timestamps of blocks, audit events, periodic maintenance and the window of
+// shared scans, so that tests can drive time deterministically with a
+// clock.Manual. Durations reported in
+// metrics and logs are measured with the system clock.
+func WithClock(c clock.Clock) Option {
+	return func(s *ColumnStore) error {
+		s.clock = c
+		return nil
+	}
+}
+
+// WithCompactionConcurrency encodes up to the given number of row groups
+// concurrently when compacting parts into Parquet, which shortens compactions
+// of tables with many columns. It only applies to tables with a row group
+// size, see WithRowGroupSize. The rows buffered for encoding are limited by
+// WithCompactionMemoryBudget. Defaults to 1, i.e. row groups are encoded one
+// after the other.
+func WithCompactionConcurrency(concurrency int) Option {
+	return func(s *ColumnStore) error {
+		if concurrency < 1 {
+			return fmt.Errorf("compaction concurrency must be at least 1, got %d", concurrency)
+		}
+		s.compactionConcurrency = concurrency
+		return nil
+	}
+}
+
+// WithCompactionMemoryBudget limits the memory of the rows buffered for
+// concurrent encoding by all compactions of the column store, see
+// WithCompactionConcurrency. Defaults to 128MiB.
+func WithCompactionMemoryBudget(bytes int64) Option {
+	return func(s *ColumnStore) error {
+		if bytes <= 0 {
+			return fmt.Errorf("compaction memory budget must be positive, got %d", bytes)
+		}
+		s.compactionBudgetSize = bytes
+		return nil
+	}
+}
+
+// WithMergePolicy sets the policy that decides when the levels of the indexes
+// of all tables are compacted and which of their parts are merged together,
+// e.g. to bucket parts by time or tenant. Defaults to
+// index.LeveledMergePolicy.
+func WithMergePolicy(policy index.MergePolicy) Option {
+	return func(s *ColumnStore) error {
+		s.mergePolicy = policy
+		return nil
+	}
+}
+
+// WithIntegrityScrubInterval periodically verifies the integrity of all blocks
+// persisted by the tables of each database at the given interval. See
+// Table.VerifyIntegrity.
+func WithIntegrityScrubInterval(interval time.Duration) Option {
+	return func(s *ColumnStore) error {
+		s.integrityScrubInterval = interval
+		return nil
+	}
+}
+
 // Close persists all data from the columnstore to storage.
 // It is no longer valid to use the coumnstore for reads or writes, and the object should not longer be reused.
 func (s *ColumnStore) Close() error {
@@ -284,6 +446,10 @@ func (s *ColumnStore) Close() error {
 		s.metrics.shutdownDuration.Observe(float64(time.Since(ts)))
 	}(time.Now())
 
+	for _, untrack := range s.untrack {
+		untrack()
+	}
+
 	errg := &errgroup.Group{}
 	errg.SetLimit(runtime.GOMAXPROCS(0))
 	for _, db := range s.dbs {
@@ -352,9 +518,51 @@ type dbMetrics struct {
 	snapshotMetrics *snapshotMetrics
 }
 
+// dbRegisterer records the collectors registered by a database, so that they
+// can be unregistered once the database is dropped or relocated and a database
+// with the same name can register them again.
+type dbRegisterer struct {
+	prometheus.Registerer
+
+	mtx        sync.Mutex
+	collectors []prometheus.Collector
+}
+
+func newDBRegisterer(reg prometheus.Registerer) *dbRegisterer {
+	return &dbRegisterer{Registerer: reg}
+}
+
+func (r *dbRegisterer) Register(c prometheus.Collector) error {
+	if err := r.Registerer.Register(c); err != nil {
+		return err
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.collectors = append(r.collectors, c)
+	return nil
+}
+
+func (r *dbRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// unregisterAll unregisters all the collectors registered by the database.
+func (r *dbRegisterer) unregisterAll() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, c := range r.collectors {
+		r.Registerer.Unregister(c)
+	}
+	r.collectors = nil
+}
+
 type DB struct {
 	columnStore *ColumnStore
-	reg         prometheus.Registerer
+	reg         *dbRegisterer
 	logger      log.Logger
 	tracer      trace.Tracer
 	name        string
@@ -365,6 +573,9 @@ type DB struct {
 
 	storagePath string
 	wal         WAL
+	// bucketPrefix is the prefix of the blocks of the database in the data
+	// sinks and sources, the name of the database if empty.
+	bucketPrefix string
 
 	// The database supports multiple data sources and sinks.
 	sources []DataSource
@@ -383,6 +594,35 @@ type DB struct {
 
 	snapshotInProgress atomic.Bool
 
+	// stopScrub stops the background integrity scrub, if any.
+	stopScrub func()
+	// stopAdvisor stops the background sorting advice logging, if any.
+	stopAdvisor func()
+	// stopWriteFlusher stops the background flushing of the write buffers,
+	// if any.
+	stopWriteFlusher func()
+	// flushRequests requests a flush of the write buffers once they exceed
+	// the configured size.
+	flushRequests chan struct{}
+
+	// auditEnabled is set once the database is set up, audit events are not
+	// emitted during recovery.
+	auditEnabled atomic.Bool
+
+	// changes passes committed inserts to WatchChanges.
+	changes changeLog
+
+	// restoreTx is the transaction the database is restored to once it is
+	// set up, see OpenAtTx.
+	restoreTx *uint64
+	// writes is held for reading by the writes to the tables of the database
+	// and for writing by RestoreToTx, so that no write lands while the
+	// database is restored.
+	writes *sync.RWMutex
+
+	// createdAt is the time the database was created, see DBInfo.CreatedAt.
+	createdAt time.Time
+
 	metrics *dbMetrics
 }
 
@@ -440,9 +680,34 @@ func (s *ColumnStore) DB(ctx context.Context, name string, opts ...DBOption) (*D
 		if err := applyOptsToDB(db); err != nil {
 			return nil, err
 		}
+		restored, err := db.restoreIfRequested(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range restored {
+			db.audit(ctx, event)
+		}
 		return db, nil
 	}
 
+	// The creation of a new database, and its restore if requested, are
+	// audited once the store is unlocked, so that audit sinks can use the
+	// store.
+	var (
+		created  bool
+		restored []audit.Event
+	)
+	defer func() {
+		if created {
+			s.audit(ctx, audit.Event{
+				Type:     audit.EventDBCreated,
+				Database: name,
+			})
+		}
+		for _, event := range restored {
+			db.audit(ctx, event)
+		}
+	}()
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
@@ -454,6 +719,10 @@ func (s *ColumnStore) DB(ctx context.Context, name string, opts ...DBOption) (*D
 			if err := applyOptsToDB(db); err != nil {
 				return nil, err
 			}
+			var err error
+			if restored, err = db.restoreIfRequested(ctx); err != nil {
+				return nil, err
+			}
 			return db, nil
 		}
 
@@ -470,12 +739,13 @@ func (s *ColumnStore) DB(ctx context.Context, name string, opts ...DBOption) (*D
 		s.mtx.Lock()
 	}
 
-	reg := prometheus.WrapRegistererWith(prometheus.Labels{"db": name}, s.reg)
+	reg := newDBRegisterer(prometheus.WrapRegistererWith(prometheus.Labels{"db": name}, s.reg))
 	logger := log.WithPrefix(s.logger, "db", name)
 	db = &DB{
 		columnStore: s,
 		name:        name,
 		mtx:         &sync.RWMutex{},
+		writes:      &sync.RWMutex{},
 		tables:      map[string]*Table{},
 		roTables:    map[string]*Table{},
 		reg:         reg,
@@ -490,12 +760,24 @@ func (s *ColumnStore) DB(ctx context.Context, name string, opts ...DBOption) (*D
 	if err := applyOptsToDB(db); err != nil {
 		return nil, err
 	}
+	// The database is new if nothing was stored for it before.
+	existed := false
+	if s.storagePath != "" {
+		if _, err := os.Stat(db.storagePath); err == nil {
+			existed = true
+		}
+	}
+	createdAt, err := db.loadCreatedAt()
+	if err != nil {
+		return nil, err
+	}
+	db.createdAt = createdAt
 
 	if dbSetupErr := func() error {
 		if err := os.RemoveAll(db.trashDir()); err != nil {
 			return err
 		}
-		db.txPool = NewTxPool(&db.highWatermark)
+		db.txPool = NewTxPool(&db.highWatermark, TxPoolWithWatermarkCallback(db.changes.notify))
 		// Wait to start the compactor pool since benchmarks show that WAL
 		// replay is a lot more efficient if it is not competing against
 		// compaction. Additionally, if the CompactAfterRecovery option is
@@ -503,7 +785,7 @@ func (s *ColumnStore) DB(ctx context.Context, name string, opts ...DBOption) (*D
 		// our compactor pool.
 		if len(db.sources) != 0 {
 			for _, source := range db.sources {
-				prefixes, err := source.Prefixes(ctx, name)
+				prefixes, err := source.Prefixes(ctx, db.prefix())
 				if err != nil {
 					return err
 				}
@@ -599,7 +881,54 @@ func (s *ColumnStore) DB(ctx context.Context, name string, opts ...DBOption) (*D
 		}
 	}
 
+	if s.integrityScrubInterval > 0 && len(db.sources) > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			db.scrub(ctx, s.integrityScrubInterval)
+		}()
+		db.stopScrub = func() {
+			cancel()
+			<-done
+		}
+	}
+
+	if s.writeBufferInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		db.flushRequests = make(chan struct{}, 1)
+		go func() {
+			defer close(done)
+			db.flushWriteBuffers(ctx, s.writeBufferInterval)
+		}()
+		db.stopWriteFlusher = func() {
+			cancel()
+			<-done
+		}
+	}
+
+	if s.sortingAdvisor && s.sortingAdvisorInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			db.logSortingAdvice(ctx, s.sortingAdvisorInterval)
+		}()
+		db.stopAdvisor = func() {
+			cancel()
+			<-done
+		}
+	}
+
+	if restored, err = db.restoreIfRequested(ctx); err != nil {
+		_ = db.closeInternal()
+		return nil, err
+	}
+
+	db.auditEnabled.Store(true)
 	s.dbs[name] = db
+	created = !existed && len(db.tables) == 0 && len(db.roTables) == 0
 	return db, nil
 }
 
@@ -610,6 +939,22 @@ func (s *ColumnStore) DBs() []string {
 	return maps.Keys(s.dbs)
 }
 
+// DBInfos returns the metadata of all the databases of this column store,
+// ordered by name.
+func (s *ColumnStore) DBInfos() []DBInfo {
+	s.mtx.RLock()
+	dbs := maps.Values(s.dbs)
+	s.mtx.RUnlock()
+	infos := make([]DBInfo, 0, len(dbs))
+	for _, db := range dbs {
+		infos = append(infos, db.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
 func (s *ColumnStore) GetDB(name string) (*DB, error) {
 	s.mtx.RLock()
 	defer s.mtx.RUnlock()
@@ -629,16 +974,35 @@ func (s *ColumnStore) DropDB(name string) error {
 		return err
 	}
 	s.mtx.Lock()
-	defer s.mtx.Unlock()
 	delete(s.dbs, name)
-	return os.Remove(filepath.Join(s.DatabasesDir(), name))
+	db.reg.unregisterAll()
+	err = os.Remove(filepath.Join(db.storagePath, createdAtPath))
+	if err == nil || os.IsNotExist(err) {
+		err = os.Remove(db.storagePath)
+	}
+	s.mtx.Unlock()
+	if err != nil {
+		return err
+	}
+	// The drop is audited once the store is unlocked, so that audit sinks can
+	// use the store.
+	s.audit(context.Background(), audit.Event{
+		Type:     audit.EventDBDropped,
+		Database: name,
+	})
+	return nil
 }
 
 func (db *DB) openWAL(ctx context.Context) (WAL, error) {
+	var walOpts []wal.Option
+	if db.columnStore.encryptionKeys != nil {
+		walOpts = append(walOpts, wal.WithEncryption(db.columnStore.encryptionKeys))
+	}
 	wal, err := wal.Open(
 		db.logger,
 		db.reg,
 		db.walDir(),
+		walOpts...,
 	)
 	if err != nil {
 		return nil, err
@@ -655,8 +1019,46 @@ func (db *DB) openWAL(ctx context.Context) (WAL, error) {
 const (
 	walPath       = "wal"
 	snapshotsPath = "snapshots"
+	createdAtPath = "created_at"
 )
 
+// loadCreatedAt returns the time the database was created. The time is stored
+// in the storage directory of the database when it is first opened, if the
+// column store keeps its data in a WAL. Otherwise nothing outlives the
+// column store, so the database is created whenever it is opened.
+func (db *DB) loadCreatedAt() (time.Time, error) {
+	now := db.columnStore.clock.Now()
+	if !db.columnStore.enableWAL || db.columnStore.storagePath == "" {
+		return now, nil
+	}
+	path := filepath.Join(db.storagePath, createdAtPath)
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		createdAt, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parse creation time of database %s: %w", db.name, err)
+		}
+		return createdAt, nil
+	case !os.IsNotExist(err):
+		return time.Time{}, fmt.Errorf("read creation time of database %s: %w", db.name, err)
+	}
+
+	if err := os.MkdirAll(db.storagePath, dirPerms); err != nil {
+		return time.Time{}, err
+	}
+	// The file is written and renamed, so that a crash never leaves a
+	// partially written time behind.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(now.Format(time.RFC3339Nano)), filePerms); err != nil {
+		return time.Time{}, fmt.Errorf("write creation time of database %s: %w", db.name, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return time.Time{}, fmt.Errorf("write creation time of database %s: %w", db.name, err)
+	}
+	return now, nil
+}
+
 func (db *DB) walDir() string {
 	return filepath.Join(db.storagePath, walPath)
 }
@@ -708,9 +1110,8 @@ func (db *DB) recover(ctx context.Context, wal WAL) error {
 		}
 	}
 
-	// persistedTables is a map from a table name to the last transaction
-	// persisted.
-	persistedTables := map[string]uint64{}
+	// persistedBlocks are the IDs of the persisted blocks of each table.
+	persistedBlocks := map[string]map[ulid.ULID]struct{}{}
 	var lastTx uint64
 
 	start := time.Now()
@@ -720,20 +1121,23 @@ func (db *DB) recover(ctx context.Context, wal WAL) error {
 		}
 		switch e := record.Entry.EntryType.(type) {
 		case *walpb.Entry_TableBlockPersisted_:
-			persistedTables[e.TableBlockPersisted.TableName] = tx
+			var id ulid.ULID
+			if err := id.UnmarshalBinary(e.TableBlockPersisted.BlockId); err != nil {
+				return err
+			}
+			tableName := e.TableBlockPersisted.TableName
+			if persistedBlocks[tableName] == nil {
+				persistedBlocks[tableName] = map[ulid.ULID]struct{}{}
+			}
+			persistedBlocks[tableName][id] = struct{}{}
 			if tx > snapshotTx {
-				// The loaded snapshot has data in a table that has been
-				// persisted. Delete all data in this table, since it has
+				// The loaded snapshot has data in a block that has been
+				// persisted. Delete all data in this block, since it has
 				// already been persisted.
 				db.mtx.Lock()
-				if table, ok := db.tables[e.TableBlockPersisted.TableName]; ok {
-					table.ActiveBlock().index, err = index.NewLSM(
-						table.name,
-						table.schema,
-						table.configureLSMLevels(db.columnStore.indexConfig),
-						index.LSMWithMetrics(table.metrics.indexMetrics),
-					)
-					if err != nil {
+				if table, ok := db.tables[tableName]; ok && table.ActiveBlock().ulid == id {
+					if err := table.resetActiveIndex(); err != nil {
+						db.mtx.Unlock()
 						return err
 					}
 				}
@@ -746,6 +1150,10 @@ func (db *DB) recover(ctx context.Context, wal WAL) error {
 	}); err != nil {
 		return err
 	}
+	persisted := func(table string, id ulid.ULID) bool {
+		_, ok := persistedBlocks[table][id]
+		return ok
+	}
 
 	// performSnapshot is set to true if a snapshot should be performed after
 	// replay. This is set in cases where there could be "dead bytes" in the
@@ -778,12 +1186,6 @@ func (db *DB) recover(ctx context.Context, wal WAL) error {
 				return err
 			}
 
-			if lastPersistedTx, ok := persistedTables[entry.TableName]; ok && tx < lastPersistedTx {
-				// This block has already been successfully persisted, so we can
-				// skip it.
-				return nil
-			}
-
 			tableName := entry.TableName
 			table, err := db.GetTable(tableName)
 			var tableErr ErrTableNotFound
@@ -824,15 +1226,21 @@ func (db *DB) recover(ctx context.Context, wal WAL) error {
 				return fmt.Errorf("get table: %w", err)
 			}
 
-			// If we get to this point it means a block was finished but did
-			// not get persisted.
-			level.Info(db.logger).Log(
-				"msg", "writing unfinished block in recovery",
-				"table", tableName,
-				"tx", tx,
-			)
-			table.pendingBlocks[table.active] = struct{}{}
-			go table.writeBlock(table.active, db.columnStore.manualBlockRotation, false)
+			// If we get to this point it means a block was finished. Write
+			// it unless it was already persisted.
+			if !persisted(tableName, table.active.ulid) {
+				level.Info(db.logger).Log(
+					"msg", "writing unfinished block in recovery",
+					"table", tableName,
+					"tx", tx,
+				)
+				table.pendingBlocks[table.active] = struct{}{}
+				table.blockWrites.Add(1)
+				go func(block *TableBlock) {
+					defer table.blockWrites.Done()
+					table.writeBlock(block, db.columnStore.manualBlockRotation, false)
+				}(table.active)
+			}
 
 			protoEqual := false
 			switch schema.(type) {
@@ -845,12 +1253,12 @@ func (db *DB) recover(ctx context.Context, wal WAL) error {
 				// If schemas are identical from block to block we should we
 				// reuse the previous schema in order to retain pooled memory
 				// for it.
-				schema, err := dynparquet.SchemaFromDefinition(schema)
+				shared, err := db.columnStore.schemas.acquire(entry.Config)
 				if err != nil {
 					return fmt.Errorf("initialize schema: %w", err)
 				}
 
-				table.schema = schema
+				table.useSchema(shared)
 			}
 
 			table.active, err = newTableBlock(table, table.active.minTx, tx, id)
@@ -860,12 +1268,6 @@ func (db *DB) recover(ctx context.Context, wal WAL) error {
 		case *walpb.Entry_Write_:
 			entry := e.Write
 			tableName := entry.TableName
-			if lastPersistedTx, ok := persistedTables[tableName]; ok && tx < lastPersistedTx {
-				// This write has already been successfully persisted, so we can
-				// skip it.
-				return nil
-			}
-
 			table, err := db.GetTable(tableName)
 			var tableErr ErrTableNotFound
 			if errors.As(err, &tableErr) {
@@ -877,7 +1279,13 @@ func (db *DB) recover(ctx context.Context, wal WAL) error {
 			if err != nil {
 				return fmt.Errorf("get table: %w", err)
 			}
+			if persisted(tableName, table.active.ulid) {
+				// This write has already been successfully persisted with
+				// its block, so we can skip it.
+				return nil
+			}
 
+			block := table.active
 			writeWg.Go(func() error {
 				switch e.Write.Arrow {
 				case true:
@@ -890,7 +1298,7 @@ func (db *DB) recover(ctx context.Context, wal WAL) error {
 						return fmt.Errorf("read record: %w", err)
 					}
 
-					if err := table.active.InsertRecord(ctx, tx, record); err != nil {
+					if err := block.InsertRecord(ctx, tx, record); err != nil {
 						return fmt.Errorf("insert record into block: %w", err)
 					}
 				default:
@@ -976,8 +1384,17 @@ func (db *DB) Close(options ...CloseOption) error {
 		opt(opts)
 	}
 	level.Info(db.logger).Log("msg", "closing DB")
+	// The tables flush their write buffers themselves when their blocks are
+	// written.
+	if db.stopWriteFlusher != nil {
+		db.stopWriteFlusher()
+		db.stopWriteFlusher = nil
+	}
 	shouldPersist := len(db.sinks) > 0 && !db.columnStore.manualBlockRotation
 	for _, table := range db.tables {
+		// Wait for rotated blocks to be written before the WAL is closed, so
+		// that their persistence is recorded.
+		table.blockWrites.Wait()
 		table.close()
 		if shouldPersist {
 			// Write the blocks but no snapshots since they are long-running
@@ -1018,6 +1435,16 @@ func (db *DB) Close(options ...CloseOption) error {
 }
 
 func (db *DB) closeInternal() error {
+	if db.stopScrub != nil {
+		db.stopScrub()
+	}
+	if db.stopAdvisor != nil {
+		db.stopAdvisor()
+	}
+	if db.stopWriteFlusher != nil {
+		db.stopWriteFlusher()
+	}
+	db.changes.close()
 	if db.columnStore.enableWAL && db.wal != nil {
 		if err := db.wal.Close(); err != nil {
 			return err
@@ -1109,16 +1536,31 @@ func (db *DB) promoteReadOnlyTableLocked(name string, config *tablepb.TableConfi
 	if !ok {
 		return nil, fmt.Errorf("read only table %s not found", name)
 	}
-	schema, err := schemaFromTableConfig(config)
+	shared, err := db.columnStore.schemas.acquire(config)
 	if err != nil {
 		return nil, err
 	}
 	table.config.Store(config)
-	table.schema = schema
+	table.useSchema(shared)
 	delete(db.roTables, name)
 	return table, nil
 }
 
+// TableDryRun checks that a table with the given name and config could be
+// created with Table, without creating it. Unlike Table, which fails lazily
+// when a bad schema is first used, all the problems of the config are
+// returned at once.
+func (db *DB) TableDryRun(name string, config *tablepb.TableConfig) error {
+	var errs []error
+	if !validateName(name) {
+		errs = append(errs, errors.New("invalid table name"))
+	}
+	if err := ValidateTableConfig(config); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
 // Table will get or create a new table with the given name and config. If a table already exists with the given name, it will have it's configuration updated.
 func (db *DB) Table(name string, config *tablepb.TableConfig) (*Table, error) {
 	if config == nil {
@@ -1127,14 +1569,66 @@ func (db *DB) Table(name string, config *tablepb.TableConfig) (*Table, error) {
 	if !validateName(name) {
 		return nil, errors.New("invalid table name")
 	}
+	return db.table(name, config, true)
+}
+
+// ErrTableConfigMismatch is returned by GetOrCreateTable when the table
+// already exists with a different config.
+type ErrTableConfigMismatch struct {
+	TableName string
+}
+
+func (e ErrTableConfigMismatch) Error() string {
+	return fmt.Sprintf("table %s already exists with a different config", e.TableName)
+}
+
+// GetOrCreateTable returns the table with the given name, creating it with the
+// given config if it doesn't exist. Unlike Table, the config is validated
+// upfront, and if the table already exists with a different config an
+// ErrTableConfigMismatch is returned instead of replacing the config of the
+// table.
+func (db *DB) GetOrCreateTable(name string, config *tablepb.TableConfig) (*Table, error) {
+	if err := db.TableDryRun(name, config); err != nil {
+		return nil, fmt.Errorf("invalid table %s: %w", name, err)
+	}
+	return db.table(name, config, false)
+}
+
+// table returns the table with the given name, creating it if it doesn't
+// exist. If the table exists with a different config, the config of the table
+// is replaced if replace is true, otherwise ErrTableConfigMismatch is
+// returned.
+func (db *DB) table(name string, config *tablepb.TableConfig, replace bool) (*Table, error) {
 	db.mtx.RLock()
 	table, ok := db.tables[name]
 	db.mtx.RUnlock()
 	if ok {
-		table.config.Store(config)
+		if !replace {
+			if !proto.Equal(table.config.Load(), config) {
+				return nil, ErrTableConfigMismatch{TableName: name}
+			}
+			return table, nil
+		}
+		if old := table.config.Swap(config); !proto.Equal(old, config) {
+			db.audit(context.Background(), audit.Event{
+				Type:  audit.EventTableConfigChanged,
+				Table: name,
+			})
+		}
 		return table, nil
 	}
 
+	var created bool
+	defer func() {
+		// Emitted after the lock below is released.
+		if created {
+			db.audit(context.Background(), audit.Event{
+				Type:  audit.EventTableCreated,
+				Table: name,
+			})
+		}
+	}()
+
 	db.mtx.Lock()
 	defer db.mtx.Unlock()
 
@@ -1142,11 +1636,15 @@ func (db *DB) Table(name string, config *tablepb.TableConfig) (*Table, error) {
 	// name wasn't concurrently created.
 	table, ok = db.tables[name]
 	if ok {
+		if !replace && !proto.Equal(table.config.Load(), config) {
+			return nil, ErrTableConfigMismatch{TableName: name}
+		}
 		return table, nil
 	}
 
 	// Check if this table exists as a read only table
-	if _, ok := db.roTables[name]; ok {
+	_, promoted := db.roTables[name]
+	if promoted {
 		var err error
 		table, err = db.promoteReadOnlyTableLocked(name, config)
 		if err != nil {
@@ -1171,12 +1669,13 @@ func (db *DB) Table(name string, config *tablepb.TableConfig) (*Table, error) {
 	tx, _, commit := db.begin()
 	defer commit()
 
-	id := generateULID()
+	id := generateULID(db.columnStore.clock.Now())
 	if err := table.newTableBlock(0, tx, id); err != nil {
 		return nil, err
 	}
 
 	db.tables[name] = table
+	created = !promoted
 	return table, nil
 }
 
@@ -1202,6 +1701,41 @@ func (db *DB) TableProvider() *DBTableProvider {
 	return NewDBTableProvider(db)
 }
 
+// DBInfo is the metadata of a database.
+type DBInfo struct {
+	Name string
+	// CreatedAt is the time the database was created. It is persisted in the
+	// storage directory of column stores with a WAL, and is the time the
+	// database was opened otherwise.
+	CreatedAt time.Time
+	// Tables is the number of tables of the database, including read-only
+	// tables.
+	Tables int
+	// Bytes is the size of the data held in memory by the active blocks of
+	// the tables. Data persisted to block storage is not accounted for.
+	Bytes int64
+}
+
+// Info returns the metadata of the database.
+func (db *DB) Info() DBInfo {
+	db.mtx.RLock()
+	tables := maps.Values(db.tables)
+	numTables := len(db.tables) + len(db.roTables)
+	db.mtx.RUnlock()
+
+	info := DBInfo{
+		Name:      db.name,
+		CreatedAt: db.createdAt,
+		Tables:    numTables,
+	}
+	for _, table := range tables {
+		if block := table.ActiveBlock(); block != nil {
+			info.Bytes += block.Size()
+		}
+	}
+	return info
+}
+
 // TableNames returns the names of all the db's tables.
 func (db *DB) TableNames() []string {
 	db.mtx.RLock()
@@ -1254,6 +1788,7 @@ func (db *DB) begin() (uint64, uint64, func()) {
 		if mark := db.highWatermark.Load(); mark+1 == txn {
 			// This is the next consecutive transaction; increase the watermark.
 			db.highWatermark.Store(txn)
+			db.changes.notify()
 		}
 
 		// place completed transaction in the waiting pool
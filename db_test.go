@@ -8,6 +8,8 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"runtime/pprof"
 	"strings"
 	"testing"
 	"time"
@@ -15,20 +17,28 @@ import (
 	"github.com/apache/arrow/go/v14/arrow"
 	"github.com/apache/arrow/go/v14/arrow/array"
 	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"github.com/thanos-io/objstore"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/polarsignals/frostdb/clock"
 	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/encryption"
 	schemapb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/schema/v1alpha1"
 	walpb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/wal/v1alpha1"
+	"github.com/polarsignals/frostdb/index"
+	"github.com/polarsignals/frostdb/parts"
 	"github.com/polarsignals/frostdb/query"
 	"github.com/polarsignals/frostdb/query/logicalplan"
 	"github.com/polarsignals/frostdb/query/physicalplan"
 	"github.com/polarsignals/frostdb/recovery"
+	"github.com/polarsignals/frostdb/storage"
 )
 
 func TestDBWithWALAndBucket(t *testing.T) {
@@ -101,6 +111,104 @@ func TestDBWithWALAndBucket(t *testing.T) {
 	require.Equal(t, int64(300), rows)
 }
 
+func TestDBWithEncryption(t *testing.T) {
+	config := NewTableConfig(
+		dynparquet.SampleDefinition(),
+	)
+
+	logger := newTestLogger(t)
+	keys, err := encryption.NewStaticKeyProvider("key1", map[string][]byte{
+		"key1": make([]byte, 32),
+	})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	bucket := objstore.NewInMemBucket()
+	sinksource := NewDefaultObjstoreBucket(
+		encryption.NewBucket(storage.NewBucketReaderAt(bucket), keys),
+	)
+
+	newStore := func() *ColumnStore {
+		c, err := New(
+			WithLogger(logger),
+			WithWAL(),
+			WithStoragePath(dir),
+			WithReadWriteStorage(sinksource),
+			WithActiveMemorySize(100*KiB),
+			WithSnapshotTriggerSize(1*KiB),
+			WithEncryption(keys),
+		)
+		require.NoError(t, err)
+		return c
+	}
+
+	c := newStore()
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", config)
+	require.NoError(t, err)
+
+	samples := dynparquet.NewTestSamples()
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+	}
+	require.NoError(t, table.EnsureCompaction())
+	require.NoError(t, c.Close())
+
+	// Everything written to disk and the bucket must be encrypted.
+	require.NoError(t, filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".fdbs" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.True(t, encryption.IsEncrypted(data), path)
+		return nil
+	}))
+	require.NoError(t, bucket.Iter(ctx, "", func(name string) error {
+		rc, err := bucket.Get(ctx, name)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return err
+		}
+		require.True(t, encryption.IsEncrypted(data), name)
+		return nil
+	}, objstore.WithRecursiveIter))
+
+	c = newStore()
+	defer c.Close()
+	db, err = c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err = db.Table("test", config)
+	require.NoError(t, err)
+
+	pool := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer pool.AssertSize(t, 0)
+	rows := int64(0)
+	err = table.View(ctx, func(ctx context.Context, tx uint64) error {
+		return table.Iterator(
+			ctx,
+			tx,
+			pool,
+			[]logicalplan.Callback{func(ctx context.Context, ar arrow.Record) error {
+				rows += ar.NumRows()
+				return nil
+			}},
+		)
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(300), rows)
+}
+
 func TestDBWithWAL(t *testing.T) {
 	ctx := context.Background()
 	config := NewTableConfig(
@@ -1562,18 +1670,21 @@ func TestDBRecover(t *testing.T) {
 		dir := setup(t, true, WithReadWriteStorage(sinksource))
 
 		// The previous wal and snapshots directories should be empty since data
-		// is persisted on Close, rendering the directories useless.
+		// is persisted on Close, rendering the directories useless. Only the
+		// creation time of the databases is kept.
 		databasesDir := filepath.Join(dir, "databases")
 		entries, err := os.ReadDir(databasesDir)
 		require.NoError(t, err)
 		for _, e := range entries {
 			dbEntries, err := os.ReadDir(filepath.Join(databasesDir, e.Name()))
 			require.NoError(t, err)
-			if len(dbEntries) > 0 {
-				entryNames := make([]string, 0, len(dbEntries))
-				for _, e := range dbEntries {
+			entryNames := make([]string, 0, len(dbEntries))
+			for _, e := range dbEntries {
+				if e.Name() != createdAtPath {
 					entryNames = append(entryNames, e.Name())
 				}
+			}
+			if len(entryNames) > 0 {
 				t.Fatalf("expected an empty dir but found the following entries: %v", entryNames)
 			}
 		}
@@ -1761,6 +1872,27 @@ func TestDBMinTXPersisted(t *testing.T) {
 // deploying new code.
 func TestReplayBackwardsCompatibility(t *testing.T) {
 	const storagePath = "testdata/oldwal"
+	// Opening the database stores its creation time and replays the old WAL
+	// into a WAL of the current format, the files it writes are removed so
+	// that the test data is left as it was.
+	existing := map[string]struct{}{}
+	require.NoError(t, filepath.WalkDir(storagePath, func(path string, _ fs.DirEntry, err error) error {
+		existing[path] = struct{}{}
+		return err
+	}))
+	t.Cleanup(func() {
+		var written []string
+		require.NoError(t, filepath.WalkDir(storagePath, func(path string, _ fs.DirEntry, err error) error {
+			if _, ok := existing[path]; !ok {
+				written = append(written, path)
+			}
+			return err
+		}))
+		// Files are removed before the directories they are in.
+		for i := len(written) - 1; i >= 0; i-- {
+			require.NoError(t, os.RemoveAll(written[i]))
+		}
+	})
 	c, err := New(WithWAL(), WithStoragePath(storagePath))
 	require.NoError(t, err)
 	defer c.Close()
@@ -2271,3 +2403,780 @@ func Test_DB_WithParquetDiskCompaction(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, int64(300), rows)
 }
+
+func Test_DB_WithParquetMmapCompaction(t *testing.T) {
+	cfg := DefaultIndexConfig()
+	cfg[0].Type = CompactionTypeParquetMmap
+	cfg[1].Type = CompactionTypeParquetMmap
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithIndexConfig(cfg),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, c.Close())
+	})
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	samples := dynparquet.NewTestSamples()
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+	}
+	require.NoError(t, table.EnsureCompaction())
+	require.NotZero(t, table.ActiveBlock().Index().LevelSize(index.L2))
+
+	// Mapped parts stay readable across garbage collections.
+	runtime.GC()
+	pool := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer pool.AssertSize(t, 0)
+	rows := int64(0)
+	err = table.View(ctx, func(ctx context.Context, tx uint64) error {
+		return table.Iterator(
+			ctx,
+			tx,
+			pool,
+			[]logicalplan.Callback{func(ctx context.Context, ar arrow.Record) error {
+				rows += ar.NumRows()
+				return nil
+			}},
+		)
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(300), rows)
+}
+
+func Test_DB_ParquetMmapCompactionSplitAndRelease(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultIndexConfig()
+	cfg[0].Type = CompactionTypeParquetMmap
+	cfg[1].Type = CompactionTypeParquetMmap
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithIndexConfig(cfg),
+		WithStoragePath(dir),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition(), WithMaxPartRows(100)))
+	require.NoError(t, err)
+
+	samples := dynparquet.NewTestSamples()
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+	}
+
+	var compact []parts.Part
+	table.ActiveBlock().Index().Iterate(func(node *index.Node) bool {
+		if p := node.Part(); p != nil {
+			compact = append(compact, p)
+		}
+		return true
+	})
+	compacted, _, _, err := table.parquetMmapCompaction(compact)
+	require.NoError(t, err)
+	require.Greater(t, len(compacted), 1, "compacted part should be split")
+	rows := int64(0)
+	for _, p := range compacted {
+		require.LessOrEqual(t, p.NumRows(), int64(100))
+		rows += p.NumRows()
+	}
+	require.Equal(t, int64(300), rows)
+
+	// The files are written under the storage path and removed once mapped.
+	entries, err := os.ReadDir(table.partsDir())
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// Releasing a part unmaps it.
+	buf, err := compacted[0].AsSerializedBuffer(table.schema)
+	require.NoError(t, err)
+	for _, p := range compacted {
+		p.Release()
+	}
+	rowGroup := buf.ParquetFile().RowGroups()[0]
+	_, err = rowGroup.ColumnChunks()[0].Pages().ReadPage()
+	require.Error(t, err)
+}
+
+func Test_DB_Authorizer(t *testing.T) {
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+
+	var requests []query.AccessRequest
+	authorizer := query.AuthorizerFunc(func(_ context.Context, req query.AccessRequest) (query.AccessPolicy, error) {
+		requests = append(requests, req)
+		if req.Touches("value") {
+			return query.AccessPolicy{}, errors.New("access to column value denied")
+		}
+		return query.AccessPolicy{
+			RowFilter: logicalplan.Col("labels.namespace").Eq(logicalplan.Literal("default")),
+		}, nil
+	})
+
+	pool := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer pool.AssertSize(t, 0)
+	engine := query.NewEngine(pool, db.TableProvider(), query.WithAuthorizer(authorizer))
+
+	countRows := func(b query.Builder) (int64, error) {
+		rows := int64(0)
+		err := b.Execute(ctx, func(_ context.Context, r arrow.Record) error {
+			rows += r.NumRows()
+			return nil
+		})
+		return rows, err
+	}
+
+	t.Run("DeniedProjection", func(t *testing.T) {
+		_, err := countRows(engine.ScanTable("test").Project(logicalplan.Col("value")))
+		require.Error(t, err)
+	})
+
+	t.Run("DeniedFilter", func(t *testing.T) {
+		_, err := countRows(engine.ScanTable("test").
+			Filter(logicalplan.Col("value").Gt(logicalplan.Literal(int64(3)))).
+			Project(logicalplan.Col("timestamp")),
+		)
+		require.Error(t, err)
+	})
+
+	t.Run("DeniedAllColumns", func(t *testing.T) {
+		_, err := countRows(engine.ScanTable("test"))
+		require.Error(t, err)
+	})
+
+	t.Run("RowFilter", func(t *testing.T) {
+		requests = nil
+		rows, err := countRows(engine.ScanTable("test").Project(logicalplan.Col("timestamp")))
+		require.NoError(t, err)
+		require.Equal(t, int64(2), rows)
+		require.Len(t, requests, 1)
+		require.Equal(t, "test", requests[0].Table)
+		require.True(t, requests[0].Touches("timestamp"))
+		require.False(t, requests[0].Touches("labels.namespace"))
+
+		// User filters cannot widen the row filter.
+		rows, err = countRows(engine.ScanTable("test").
+			Filter(logicalplan.Or(
+				logicalplan.Col("labels.node").Eq(logicalplan.Literal("test3")),
+				logicalplan.Col("labels.namespace").Eq(logicalplan.Literal("default")),
+			)).
+			Project(logicalplan.Col("timestamp")),
+		)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), rows)
+	})
+}
+
+func Test_DB_RowFilter(t *testing.T) {
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	_, err = table.InsertRecord(context.Background(), r)
+	require.NoError(t, err)
+
+	type namespaceKey struct{}
+	pool := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer pool.AssertSize(t, 0)
+	engine := query.NewEngine(pool, db.TableProvider(), query.WithRowFilter(
+		func(ctx context.Context, table string) (logicalplan.Expr, error) {
+			require.Equal(t, "test", table)
+			ns, ok := ctx.Value(namespaceKey{}).(string)
+			if !ok {
+				return nil, errors.New("no namespace")
+			}
+			return logicalplan.Col("labels.namespace").Eq(logicalplan.Literal(ns)), nil
+		},
+	))
+
+	countRows := func(ctx context.Context) (int64, error) {
+		rows := int64(0)
+		err := engine.ScanTable("test").
+			Filter(logicalplan.Col("labels.namespace").NotEq(logicalplan.Literal("other"))).
+			Execute(ctx, func(_ context.Context, r arrow.Record) error {
+				rows += r.NumRows()
+				return nil
+			})
+		return rows, err
+	}
+
+	_, err = countRows(context.Background())
+	require.Error(t, err)
+
+	rows, err := countRows(context.WithValue(context.Background(), namespaceKey{}, "default"))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), rows)
+
+	rows, err = countRows(context.WithValue(context.Background(), namespaceKey{}, "other"))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), rows)
+}
+
+func Test_DB_AsOfTx(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	var txs []uint64
+	for i := 0; i < 3; i++ {
+		r, err := dynparquet.NewTestSamples().ToRecord()
+		require.NoError(t, err)
+		tx, err := table.InsertRecord(ctx, r)
+		r.Release()
+		require.NoError(t, err)
+		txs = append(txs, tx)
+	}
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	countRows := func(b query.Builder) int64 {
+		var rows int64
+		require.NoError(t, b.Execute(ctx, func(_ context.Context, r arrow.Record) error {
+			rows += r.NumRows()
+			return nil
+		}))
+		return rows
+	}
+	require.Equal(t, int64(9), countRows(engine.ScanTable("test")))
+	for i, tx := range txs {
+		require.Equal(t, int64(3*(i+1)), countRows(engine.ScanTable("test").AsOfTx(tx)))
+	}
+	require.Equal(t, int64(4), countRows(
+		engine.ScanTable("test").
+			Filter(logicalplan.Col("labels.namespace").Eq(logicalplan.Literal("default"))).
+			AsOfTx(txs[1]),
+	))
+}
+
+func Test_DB_AsOfTxCompacted(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	var txs []uint64
+	for i := 0; i < 3; i++ {
+		r, err := dynparquet.NewTestSamples().ToRecord()
+		require.NoError(t, err)
+		tx, err := table.InsertRecord(ctx, r)
+		r.Release()
+		require.NoError(t, err)
+		txs = append(txs, tx)
+	}
+
+	// Compaction drops the transactions of the rows, so they can't be read
+	// as of the transactions before the last write anymore.
+	require.NoError(t, table.EnsureCompaction())
+	require.Equal(t, txs[2], table.SnapshotHorizon())
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	countRows := func(b query.Builder) (int64, error) {
+		var rows int64
+		err := b.Execute(ctx, func(_ context.Context, r arrow.Record) error {
+			rows += r.NumRows()
+			return nil
+		})
+		return rows, err
+	}
+	for _, tx := range txs[:2] {
+		_, err := countRows(engine.ScanTable("test").AsOfTx(tx))
+		require.ErrorIs(t, err, logicalplan.ErrSnapshotUnavailable)
+		_, err = countRows(engine.ScanSchema("test").AsOfTx(tx))
+		require.ErrorIs(t, err, logicalplan.ErrSnapshotUnavailable)
+	}
+	rows, err := countRows(engine.ScanTable("test").AsOfTx(txs[2]))
+	require.NoError(t, err)
+	require.Equal(t, int64(9), rows)
+}
+
+func Test_DB_Infos(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	before := time.Now()
+	ctx := context.Background()
+	for _, name := range []string{"tenant-b", "tenant-a"} {
+		_, err := c.DB(ctx, name)
+		require.NoError(t, err)
+	}
+	db, err := c.GetDB("tenant-a")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+
+	infos := c.DBInfos()
+	require.Len(t, infos, 2)
+	require.Equal(t, "tenant-a", infos[0].Name)
+	require.Equal(t, 1, infos[0].Tables)
+	require.Equal(t, table.ActiveBlock().Size(), infos[0].Bytes)
+	require.Positive(t, infos[0].Bytes)
+	require.False(t, infos[0].CreatedAt.Before(before))
+	require.Equal(t, "tenant-b", infos[1].Name)
+	require.Zero(t, infos[1].Tables)
+	require.Zero(t, infos[1].Bytes)
+
+	require.NoError(t, c.DropDB("tenant-a"))
+	infos = c.DBInfos()
+	require.Len(t, infos, 1)
+	require.Equal(t, "tenant-b", infos[0].Name)
+	require.Equal(t, []string{"tenant-b"}, c.DBs())
+}
+
+func Test_DB_CreatedAt(t *testing.T) {
+	dir := t.TempDir()
+	clk := clock.NewManual(time.Unix(100, 0))
+	open := func() *ColumnStore {
+		c, err := New(
+			WithLogger(newTestLogger(t)),
+			WithWAL(),
+			WithStoragePath(dir),
+			WithClock(clk),
+		)
+		require.NoError(t, err)
+		return c
+	}
+	createdAt := func(c *ColumnStore) time.Time {
+		db, err := c.DB(context.Background(), "test")
+		require.NoError(t, err)
+		return db.Info().CreatedAt
+	}
+
+	c := open()
+	require.True(t, time.Unix(100, 0).Equal(createdAt(c)))
+	require.NoError(t, c.Close())
+
+	// The creation time survives restarts.
+	clk.Advance(time.Hour)
+	c = open()
+	require.True(t, time.Unix(100, 0).Equal(createdAt(c)))
+
+	// A database that is dropped and created again has a new creation time.
+	require.NoError(t, c.DropDB("test"))
+	require.True(t, time.Unix(100, 0).Add(time.Hour).Equal(createdAt(c)))
+	require.NoError(t, c.Close())
+}
+
+func Test_DB_StoragePathAndRelocate(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithWAL(),
+		WithStoragePath(dir),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "hot", "tenant")
+	db, err := c.DB(ctx, "tenant", WithDBStoragePath(path))
+	require.NoError(t, err)
+	require.Equal(t, path, db.StoragePath())
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	samples := dynparquet.NewTestSamples()
+	for i := 0; i < 10; i++ {
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(ctx, r)
+		r.Release()
+		require.NoError(t, err)
+	}
+	_, err = os.Stat(filepath.Join(path, walPath))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(c.DatabasesDir(), "tenant"))
+	require.True(t, os.IsNotExist(err))
+
+	// The storage path of an open database can only be changed by relocating
+	// it.
+	_, err = c.DB(ctx, "tenant", WithDBStoragePath(t.TempDir()))
+	require.Error(t, err)
+
+	countRows := func(db *DB) int {
+		rows := 0
+		engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+		require.NoError(t, engine.ScanTable("test").
+			Execute(ctx, func(ctx context.Context, r arrow.Record) error {
+				rows += int(r.NumRows())
+				return nil
+			}))
+		return rows
+	}
+	require.Equal(t, 30, countRows(db))
+
+	newPath := filepath.Join(t.TempDir(), "cold", "tenant")
+	db, err = c.RelocateDB(ctx, "tenant", newPath)
+	require.NoError(t, err)
+	require.Equal(t, newPath, db.StoragePath())
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	// The data is recovered from the new location.
+	_, err = db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	require.Equal(t, 30, countRows(db))
+}
+
+func Test_DB_BucketPrefix(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	sinksource := NewDefaultObjstoreBucket(bucket)
+	newStore := func() *ColumnStore {
+		c, err := New(
+			WithLogger(newTestLogger(t)),
+			WithReadWriteStorage(sinksource),
+		)
+		require.NoError(t, err)
+		return c
+	}
+
+	ctx := context.Background()
+	c := newStore()
+	db, err := c.DB(ctx, "tenant", WithDBBucketPrefix("cold/tenant"))
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+
+	var objects []string
+	require.NoError(t, bucket.Iter(ctx, "", func(name string) error {
+		objects = append(objects, name)
+		return nil
+	}, objstore.WithRecursiveIter))
+	require.NotEmpty(t, objects)
+	for _, name := range objects {
+		require.True(t, strings.HasPrefix(name, "cold/tenant/test/"), name)
+	}
+
+	c = newStore()
+	defer c.Close()
+	db, err = c.DB(ctx, "tenant", WithDBBucketPrefix("cold/tenant"))
+	require.NoError(t, err)
+	rows := 0
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	require.NoError(t, engine.ScanTable("test").
+		Execute(ctx, func(ctx context.Context, r arrow.Record) error {
+			rows += int(r.NumRows())
+			return nil
+		}))
+	require.Equal(t, 3, rows)
+}
+
+func Test_DB_PreparedQuery(t *testing.T) {
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+
+	mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+	engine := query.NewEngine(mem, db.TableProvider())
+	prepared, err := engine.ScanTable("test").
+		Filter(logicalplan.Col("timestamp").Gt(logicalplan.Literal(1))).
+		Distinct(logicalplan.Col("example_type")).(query.LocalQueryBuilder).
+		Prepare(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, prepared.DrawString())
+
+	// The prepared plan is executed concurrently, and re-executed, with
+	// every execution seeing only its own distinct values.
+	for i := 0; i < 2; i++ {
+		errg := &errgroup.Group{}
+		for j := 0; j < 4; j++ {
+			errg.Go(func() error {
+				rows := 0
+				if err := prepared.Execute(ctx, func(_ context.Context, r arrow.Record) error {
+					rows += int(r.NumRows())
+					return nil
+				}); err != nil {
+					return err
+				}
+				if rows != 1 {
+					return fmt.Errorf("expected 1 distinct row, got %d", rows)
+				}
+				return nil
+			})
+		}
+		require.NoError(t, errg.Wait())
+	}
+
+	// Errors of the plan are reported when it is prepared.
+	_, err = engine.ScanTable("test").
+		Filter(logicalplan.Col("example_type").RegexMatch("a(")).(query.LocalQueryBuilder).
+		Prepare(ctx)
+	require.Error(t, err)
+}
+
+func Test_DB_PreparedQueryTenants(t *testing.T) {
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(context.Background(), r)
+	require.NoError(t, err)
+
+	type namespaceKey struct{}
+	withNamespace := func(ns string) context.Context {
+		return context.WithValue(context.Background(), namespaceKey{}, ns)
+	}
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider(),
+		query.WithAuthorizer(query.AuthorizerFunc(func(ctx context.Context, _ query.AccessRequest) (query.AccessPolicy, error) {
+			if ctx.Value(namespaceKey{}) == "denied" {
+				return query.AccessPolicy{}, errors.New("access denied")
+			}
+			return query.AccessPolicy{}, nil
+		})),
+		query.WithRowFilter(func(ctx context.Context, _ string) (logicalplan.Expr, error) {
+			ns, ok := ctx.Value(namespaceKey{}).(string)
+			if !ok {
+				return nil, errors.New("no namespace")
+			}
+			return logicalplan.Col("labels.namespace").Eq(logicalplan.Literal(ns)), nil
+		}),
+	)
+	prepared, err := engine.ScanTable("test").(query.LocalQueryBuilder).Prepare(withNamespace("default"))
+	require.NoError(t, err)
+
+	countRows := func(ctx context.Context) (int64, error) {
+		var rows int64
+		err := prepared.Execute(ctx, func(_ context.Context, r arrow.Record) error {
+			rows += r.NumRows()
+			return nil
+		})
+		return rows, err
+	}
+
+	// The row filter and the authorizer are applied under the context of
+	// every execution, not the one the query was prepared with.
+	rows, err := countRows(withNamespace("default"))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), rows)
+	rows, err = countRows(withNamespace("other"))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), rows)
+	_, err = countRows(withNamespace("denied"))
+	require.Error(t, err)
+	_, err = countRows(context.Background())
+	require.Error(t, err)
+}
+
+func Test_DB_QueryNow(t *testing.T) {
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for timestamp := int64(8); timestamp <= 10; timestamp++ {
+		samples := dynparquet.NewTestSamples()
+		for i := range samples {
+			samples[i].Timestamp = timestamp
+		}
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(ctx, r)
+		r.Release()
+		require.NoError(t, err)
+	}
+
+	clk := clock.NewManual(time.UnixMilli(10))
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider(), query.WithClock(clk))
+	builder := engine.ScanTable("test").
+		Filter(logicalplan.Col("timestamp").GtEq(logicalplan.Sub(logicalplan.Now(), logicalplan.Duration(2*time.Millisecond))))
+	count := func() int {
+		rows := 0
+		require.NoError(t, builder.Execute(ctx, func(_ context.Context, r arrow.Record) error {
+			rows += int(r.NumRows())
+			return nil
+		}))
+		return rows
+	}
+
+	// now() is bound to the time of the clock whenever the query is executed.
+	require.Equal(t, 9, count())
+	clk.Advance(time.Millisecond)
+	require.Equal(t, 6, count())
+
+	// Prepared queries would keep the time they were prepared at.
+	_, err = builder.(query.LocalQueryBuilder).Prepare(ctx)
+	require.Error(t, err)
+}
+
+func Test_DB_QueryTags(t *testing.T) {
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	_, err = table.InsertRecord(context.Background(), r)
+	require.NoError(t, err)
+
+	var logged [][]interface{}
+	reg := prometheus.NewRegistry()
+	engine := query.NewEngine(
+		memory.DefaultAllocator,
+		db.TableProvider(),
+		query.WithQueryLogger(log.LoggerFunc(func(keyvals ...interface{}) error {
+			logged = append(logged, keyvals)
+			return nil
+		})),
+		query.WithTagMetrics(reg, "dashboard"),
+	)
+
+	ctx := query.WithTags(context.Background(), map[string]string{"dashboard": "cpu", "user": "a"})
+	ctx = query.WithTags(ctx, map[string]string{"user": "b"})
+	require.Equal(t, map[string]string{"dashboard": "cpu", "user": "b"}, query.TagsFromContext(ctx))
+
+	require.NoError(t, engine.ScanTable("test").Execute(ctx, func(context.Context, arrow.Record) error {
+		active := engine.ActiveQueries()
+		require.Len(t, active, 1)
+		require.Equal(t, query.TagsFromContext(ctx), active[0].Tags)
+		require.NotEmpty(t, active[0].Plan)
+		return nil
+	}))
+	require.Empty(t, engine.ActiveQueries())
+
+	require.Len(t, logged, 1)
+	require.Contains(t, logged[0], "tag.dashboard")
+	require.Contains(t, logged[0], "cpu")
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP frostdb_query_executed_total Number of queries executed, by tags and whether they failed.
+# TYPE frostdb_query_executed_total counter
+frostdb_query_executed_total{dashboard="cpu",result="success"} 1
+`), "frostdb_query_executed_total"))
+
+	// Executions of prepared queries are tracked like any other.
+	prepared, err := engine.ScanTable("test").(query.LocalQueryBuilder).Prepare(ctx)
+	require.NoError(t, err)
+	require.NoError(t, prepared.Execute(ctx, func(context.Context, arrow.Record) error {
+		active := engine.ActiveQueries()
+		require.Len(t, active, 1)
+		require.Equal(t, query.TagsFromContext(ctx), active[0].Tags)
+		require.Equal(t, prepared.DrawString(), active[0].Plan)
+		return nil
+	}))
+	require.Empty(t, engine.ActiveQueries())
+	require.Len(t, logged, 2)
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP frostdb_query_executed_total Number of queries executed, by tags and whether they failed.
+# TYPE frostdb_query_executed_total counter
+frostdb_query_executed_total{dashboard="cpu",result="success"} 2
+`), "frostdb_query_executed_total"))
+}
+
+func Test_DB_QueryProfileLabels(t *testing.T) {
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	_, err = table.InsertRecord(context.Background(), r)
+	require.NoError(t, err)
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	labels := func(filter logicalplan.Expr) map[string]string {
+		labels := map[string]string{}
+		require.NoError(t, engine.ScanTable("test").Filter(filter).Execute(context.Background(), func(ctx context.Context, _ arrow.Record) error {
+			pprof.ForLabels(ctx, func(key, value string) bool {
+				labels[key] = value
+				return true
+			})
+			return nil
+		}))
+		return labels
+	}
+
+	gt := labels(logicalplan.Col("value").Gt(logicalplan.Literal(int64(0))))
+	require.Equal(t, "test", gt["db"])
+	require.Equal(t, "test", gt["table"])
+	require.NotEmpty(t, gt["query_fingerprint"])
+	// Queries only differing by their literals share a fingerprint.
+	require.Equal(t, gt, labels(logicalplan.Col("value").Gt(logicalplan.Literal(int64(1)))))
+	require.NotEqual(t, gt["query_fingerprint"], labels(logicalplan.Col("value").Lt(logicalplan.Literal(int64(10))))["query_fingerprint"])
+}
@@ -0,0 +1,173 @@
+package frostdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/go-kit/log/level"
+)
+
+// WithDBStoragePath stores the WAL and snapshots of the database in the given
+// directory instead of in the databases directory of the column store, e.g. to
+// put the data of a tenant on a different disk. Databases stored outside of the
+// databases directory are not recovered when the column store is opened, they
+// are recovered once opened with the same path. The storage path of an open
+// database can only be changed with ColumnStore.RelocateDB.
+func WithDBStoragePath(path string) DBOption {
+	return func(db *DB) error {
+		if db.txPool != nil && filepath.Clean(path) != filepath.Clean(db.storagePath) {
+			return fmt.Errorf("database %s is already stored in %s, use RelocateDB to move it", db.name, db.storagePath)
+		}
+		db.storagePath = path
+		return nil
+	}
+}
+
+// WithDBBucketPrefix persists the blocks of the database to the data sinks, and
+// reads them from the data sources, under the given prefix instead of the name
+// of the database.
+func WithDBBucketPrefix(prefix string) DBOption {
+	return func(db *DB) error {
+		if prefix == "" {
+			return errors.New("bucket prefix cannot be empty")
+		}
+		if db.txPool != nil && prefix != db.prefix() {
+			return fmt.Errorf("database %s already uses bucket prefix %s", db.name, db.prefix())
+		}
+		db.bucketPrefix = prefix
+		return nil
+	}
+}
+
+// prefix returns the prefix of the blocks of the database in the data sinks and
+// sources.
+func (db *DB) prefix() string {
+	if db.bucketPrefix != "" {
+		return db.bucketPrefix
+	}
+	return db.name
+}
+
+// StoragePath returns the directory the WAL and snapshots of the database are
+// stored in.
+func (db *DB) StoragePath() string {
+	return db.storagePath
+}
+
+// RelocateDB moves the WAL and snapshots of the named database to the given
+// directory, e.g. to move a tenant to a different storage tier before
+// decommissioning the old one. The database is closed, its storage directory
+// moved, and the database reopened from the new directory by replaying its
+// WAL. Writes and queries to the database must be stopped while it is
+// relocated, and the returned database must be used afterwards as the previous
+// one is closed.
+func (s *ColumnStore) RelocateDB(ctx context.Context, name, path string) (*DB, error) {
+	db, err := s.GetDB(name)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Clean(path) == filepath.Clean(db.storagePath) {
+		return db, nil
+	}
+	if !s.enableWAL {
+		// Nothing is stored on disk without the WAL, the data is kept in
+		// memory.
+		db.mtx.Lock()
+		db.storagePath = path
+		db.mtx.Unlock()
+		return db, nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("relocating database %s: %s already exists", name, path)
+	}
+
+	s.mtx.Lock()
+	delete(s.dbs, name)
+	s.mtx.Unlock()
+
+	// The data of the database is in its WAL and snapshots, so the database
+	// is closed without persisting or snapshotting it, like on a crash, and
+	// recovered from its new location.
+	for _, table := range db.tables {
+		table.close()
+	}
+	if err := db.closeInternal(); err != nil {
+		return nil, err
+	}
+	db.reg.unregisterAll()
+
+	opts := []DBOption{WithDBStoragePath(path)}
+	if db.bucketPrefix != "" {
+		opts = append(opts, WithDBBucketPrefix(db.bucketPrefix))
+	}
+	if err := moveDir(db.storagePath, path); err != nil {
+		// Reopen the database from its old location.
+		opts[0] = WithDBStoragePath(db.storagePath)
+		if _, reopenErr := s.DB(ctx, name, opts...); reopenErr != nil {
+			return nil, errors.Join(err, reopenErr)
+		}
+		return nil, fmt.Errorf("relocating database %s: %w", name, err)
+	}
+	level.Info(s.logger).Log("msg", "relocated database", "db", name, "from", db.storagePath, "to", path)
+
+	return s.DB(ctx, name, opts...)
+}
+
+// moveDir moves the directory from to the path to, copying it if it can't be
+// renamed, e.g. because the paths are on different devices.
+func moveDir(from, to string) error {
+	if _, err := os.Stat(from); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(to), os.FileMode(0o755)); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+
+	if err := filepath.WalkDir(from, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, os.FileMode(0o755))
+		}
+		return copyFile(path, target)
+	}); err != nil {
+		_ = os.RemoveAll(to)
+		return err
+	}
+	return os.RemoveAll(from)
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,247 @@
+// Package debug inspects the physical layout of the Parquet files backing
+// blocks and parts: their row groups, and the encodings, sizes, statistics and
+// bloom filters of every column chunk. It is meant to diagnose storage
+// regressions programmatically, for example a dictionary encoded column
+// falling back to plain encoding.
+package debug
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/parts"
+)
+
+// FileLayout is the layout of a Parquet file.
+type FileLayout struct {
+	// Size is the size of the file in bytes.
+	Size      int64
+	NumRows   int64
+	RowGroups []RowGroupLayout
+}
+
+// RowGroupLayout is the layout of a row group.
+type RowGroupLayout struct {
+	NumRows int64
+	// Size is the uncompressed size of the row group in bytes.
+	Size    int64
+	Columns []ColumnChunkLayout
+}
+
+// ColumnChunkLayout is the layout of a column chunk of a row group.
+type ColumnChunkLayout struct {
+	// Path is the dot separated path of the column.
+	Path string
+	// Type is the physical type of the column.
+	Type string
+	// Encodings are the encodings used by the pages of the chunk.
+	Encodings   []string
+	Compression string
+
+	CompressedSize   int64
+	UncompressedSize int64
+	NumValues        int64
+
+	// NullCount, Min and Max are taken from the chunk statistics. Min and
+	// Max are empty if the chunk has no statistics.
+	NullCount int64
+	Min       string
+	Max       string
+
+	HasDictionary  bool
+	HasBloomFilter bool
+	// DictionaryFallback is true if the chunk has a dictionary page but some
+	// of its data pages are not dictionary encoded. This happens when the
+	// dictionary grows too large and the writer falls back to another
+	// encoding.
+	DictionaryFallback bool
+}
+
+// Layout returns the layout of the given Parquet file.
+func Layout(f *parquet.File) *FileLayout {
+	md := f.Metadata()
+	l := &FileLayout{
+		Size:      f.Size(),
+		NumRows:   md.NumRows,
+		RowGroups: make([]RowGroupLayout, 0, len(md.RowGroups)),
+	}
+	for _, rg := range md.RowGroups {
+		rgl := RowGroupLayout{
+			NumRows: rg.NumRows,
+			Size:    rg.TotalByteSize,
+			Columns: make([]ColumnChunkLayout, 0, len(rg.Columns)),
+		}
+		for _, c := range rg.Columns {
+			rgl.Columns = append(rgl.Columns, columnChunkLayout(&c.MetaData))
+		}
+		l.RowGroups = append(l.RowGroups, rgl)
+	}
+	return l
+}
+
+// BufferLayout returns the layout of the given serialized buffer.
+func BufferLayout(buf *dynparquet.SerializedBuffer) *FileLayout {
+	return Layout(buf.ParquetFile())
+}
+
+// PartLayout returns the layout of the given part. Parts that are not backed
+// by a Parquet file are serialized with the given schema first, so the layout
+// is the one the part would have once persisted.
+func PartLayout(p parts.Part, schema *dynparquet.Schema) (*FileLayout, error) {
+	buf, err := p.AsSerializedBuffer(schema)
+	if err != nil {
+		return nil, fmt.Errorf("serialize part: %w", err)
+	}
+	return BufferLayout(buf), nil
+}
+
+func columnChunkLayout(md *format.ColumnMetaData) ColumnChunkLayout {
+	c := ColumnChunkLayout{
+		Path:             strings.Join(md.PathInSchema, "."),
+		Type:             md.Type.String(),
+		Encodings:        make([]string, 0, len(md.Encoding)),
+		Compression:      md.Codec.String(),
+		CompressedSize:   md.TotalCompressedSize,
+		UncompressedSize: md.TotalUncompressedSize,
+		NumValues:        md.NumValues,
+		NullCount:        md.Statistics.NullCount,
+		HasDictionary:    md.DictionaryPageOffset != 0,
+		HasBloomFilter:   md.BloomFilterOffset != 0,
+	}
+	for _, e := range md.Encoding {
+		c.Encodings = append(c.Encodings, e.String())
+	}
+
+	minValue, maxValue := md.Statistics.MinValue, md.Statistics.MaxValue
+	if minValue == nil && maxValue == nil {
+		// Deprecated statistics written by older writers.
+		minValue, maxValue = md.Statistics.Min, md.Statistics.Max
+	}
+	c.Min = formatValue(md.Type, minValue)
+	c.Max = formatValue(md.Type, maxValue)
+
+	for _, s := range md.EncodingStats {
+		if s.PageType == format.DictionaryPage {
+			c.HasDictionary = true
+		}
+	}
+	if c.HasDictionary {
+		for _, s := range md.EncodingStats {
+			if s.PageType == format.DictionaryPage || s.Count == 0 {
+				continue
+			}
+			if !isDictionaryEncoding(s.Encoding) {
+				c.DictionaryFallback = true
+			}
+		}
+	}
+	return c
+}
+
+func isDictionaryEncoding(e format.Encoding) bool {
+	return e == format.RLEDictionary || e == format.PlainDictionary
+}
+
+// formatValue formats a plain encoded statistics value of the given physical
+// type.
+func formatValue(t format.Type, b []byte) string {
+	if b == nil {
+		return ""
+	}
+	switch t {
+	case format.Boolean:
+		if len(b) == 1 {
+			return fmt.Sprint(b[0] != 0)
+		}
+	case format.Int32:
+		if len(b) == 4 {
+			return fmt.Sprint(int32(binary.LittleEndian.Uint32(b)))
+		}
+	case format.Int64:
+		if len(b) == 8 {
+			return fmt.Sprint(int64(binary.LittleEndian.Uint64(b)))
+		}
+	case format.Float:
+		if len(b) == 4 {
+			return fmt.Sprint(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		}
+	case format.Double:
+		if len(b) == 8 {
+			return fmt.Sprint(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		}
+	case format.ByteArray:
+		return fmt.Sprintf("%q", b)
+	}
+	return fmt.Sprintf("%x", b)
+}
+
+// Columns returns the layout of the chunks of the column with the given path,
+// one per row group. It returns nil if there is no such column.
+func (l *FileLayout) Columns(path string) []ColumnChunkLayout {
+	var chunks []ColumnChunkLayout
+	for _, rg := range l.RowGroups {
+		for _, c := range rg.Columns {
+			if c.Path == path {
+				chunks = append(chunks, c)
+			}
+		}
+	}
+	return chunks
+}
+
+// WriteTo writes a human readable dump of the layout to w.
+func (l *FileLayout) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	fmt.Fprintf(cw, "size: %d bytes, rows: %d, row groups: %d\n", l.Size, l.NumRows, len(l.RowGroups))
+	for i, rg := range l.RowGroups {
+		fmt.Fprintf(cw, "\nrow group %d: rows: %d, size: %d bytes\n", i, rg.NumRows, rg.Size)
+		tw := tabwriter.NewWriter(cw, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "COLUMN\tTYPE\tENCODINGS\tCODEC\tCOMPRESSED\tUNCOMPRESSED\tVALUES\tNULLS\tMIN\tMAX\tDICT\tBLOOM")
+		for _, c := range rg.Columns {
+			dict := fmt.Sprint(c.HasDictionary)
+			if c.DictionaryFallback {
+				dict = "fallback"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\t%s\t%t\n",
+				c.Path, c.Type, strings.Join(c.Encodings, ","), c.Compression,
+				c.CompressedSize, c.UncompressedSize, c.NumValues, c.NullCount,
+				c.Min, c.Max, dict, c.HasBloomFilter,
+			)
+		}
+		if err := tw.Flush(); err != nil {
+			return cw.n, err
+		}
+	}
+	return cw.n, cw.err
+}
+
+// String returns the human readable dump of the layout.
+func (l *FileLayout) String() string {
+	var sb strings.Builder
+	_, _ = l.WriteTo(&sb)
+	return sb.String()
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	w.err = err
+	return n, err
+}
@@ -0,0 +1,77 @@
+package debug
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/parts"
+)
+
+func TestLayout(t *testing.T) {
+	schema := dynparquet.NewSampleSchema()
+	samples := dynparquet.NewTestSamples()
+	buf, err := samples.ToBuffer(schema)
+	require.NoError(t, err)
+
+	b := bytes.NewBuffer(nil)
+	require.NoError(t, schema.SerializeBuffer(b, buf))
+	serBuf, err := dynparquet.ReaderFromBytes(b.Bytes())
+	require.NoError(t, err)
+
+	l := BufferLayout(serBuf)
+	require.Equal(t, int64(3), l.NumRows)
+	require.Equal(t, int64(b.Len()), l.Size)
+	require.Len(t, l.RowGroups, 1)
+
+	values := l.Columns("value")
+	require.Len(t, values, 1)
+	require.Equal(t, "INT64", values[0].Type)
+	require.Equal(t, int64(3), values[0].NumValues)
+	require.Equal(t, "3", values[0].Min)
+	require.Equal(t, "5", values[0].Max)
+	require.False(t, values[0].DictionaryFallback)
+
+	namespaces := l.Columns("labels.namespace")
+	require.Len(t, namespaces, 1)
+	require.True(t, namespaces[0].HasDictionary)
+	require.False(t, namespaces[0].DictionaryFallback)
+
+	require.Nil(t, l.Columns("unknown"))
+	require.Contains(t, l.String(), "labels.namespace")
+
+	// Record parts are serialized before their layout is returned.
+	r, err := samples.ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	pl, err := PartLayout(parts.NewArrowPart(0, r, 0, schema), schema)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), pl.NumRows)
+}
+
+func TestDictionaryFallback(t *testing.T) {
+	md := &format.ColumnMetaData{
+		Type:                 format.ByteArray,
+		Encoding:             []format.Encoding{format.Plain, format.RLEDictionary},
+		PathInSchema:         []string{"labels", "namespace"},
+		DictionaryPageOffset: 4,
+		EncodingStats: []format.PageEncodingStats{
+			{PageType: format.DictionaryPage, Encoding: format.Plain, Count: 1},
+			{PageType: format.DataPage, Encoding: format.RLEDictionary, Count: 2},
+			{PageType: format.DataPage, Encoding: format.Plain, Count: 1},
+		},
+		Statistics: format.Statistics{MinValue: []byte("a"), MaxValue: []byte("b")},
+	}
+	c := columnChunkLayout(md)
+	require.Equal(t, "labels.namespace", c.Path)
+	require.True(t, c.HasDictionary)
+	require.True(t, c.DictionaryFallback)
+	require.Equal(t, `"a"`, c.Min)
+	require.Equal(t, `"b"`, c.Max)
+
+	md.EncodingStats = md.EncodingStats[:2]
+	require.False(t, columnChunkLayout(md).DictionaryFallback)
+}
@@ -1,6 +1,14 @@
 package dynparquet
 
-import "github.com/parquet-go/parquet-go"
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/segmentio/encoding/thrift"
+)
 
 type concatenatedDynamicRowGroup struct {
 	parquet.RowGroup
@@ -32,3 +40,169 @@ func (c *concatenatedDynamicRowGroup) DynamicColumns() map[string][]string {
 func (c *concatenatedDynamicRowGroup) DynamicRows() DynamicRowReader {
 	return newDynamicRowGroupReader(c, c.fields)
 }
+
+// ConcatFiles writes a Parquet file to w made of the row groups of the given
+// files, in order. The encoded pages of the row groups are copied as is, only
+// the offsets in the metadata are rewritten, so files encoded concurrently can
+// be combined into one file without encoding them again. The files must have
+// the same schema, the key-value metadata of the first file is kept.
+func ConcatFiles(w io.Writer, files []*parquet.File) error {
+	if len(files) == 0 {
+		return errors.New("no files to concatenate")
+	}
+	for _, f := range files[1:] {
+		if f.Schema().String() != files[0].Schema().String() {
+			return errors.New("cannot concatenate files with different schemas")
+		}
+	}
+
+	cw := &countingWriter{w: w}
+	if _, err := cw.Write([]byte("PAR1")); err != nil {
+		return err
+	}
+
+	var (
+		numRows       int64
+		rowGroups     []format.RowGroup
+		columnIndexes []format.ColumnIndex
+		offsetIndexes []format.OffsetIndex
+	)
+	for _, f := range files {
+		dataEnd, err := dataEndOffset(f)
+		if err != nil {
+			return err
+		}
+		// The row groups of the file start after its magic header.
+		delta := cw.n - 4
+		if _, err := io.Copy(cw, io.NewSectionReader(f, 4, dataEnd-4)); err != nil {
+			return err
+		}
+
+		metadata := f.Metadata()
+		numRows += metadata.NumRows
+		fileColumnIndexes, fileOffsetIndexes := f.ColumnIndexes(), f.OffsetIndexes()
+		for i, rg := range metadata.RowGroups {
+			rg.Columns = append([]format.ColumnChunk(nil), rg.Columns...)
+			for j := range rg.Columns {
+				c := &rg.Columns[j]
+				c.FileOffset = shiftOffset(c.FileOffset, delta)
+				c.MetaData.DataPageOffset = shiftOffset(c.MetaData.DataPageOffset, delta)
+				c.MetaData.IndexPageOffset = shiftOffset(c.MetaData.IndexPageOffset, delta)
+				c.MetaData.DictionaryPageOffset = shiftOffset(c.MetaData.DictionaryPageOffset, delta)
+				c.MetaData.BloomFilterOffset = shiftOffset(c.MetaData.BloomFilterOffset, delta)
+				c.ColumnIndexOffset, c.ColumnIndexLength = 0, 0
+				c.OffsetIndexOffset, c.OffsetIndexLength = 0, 0
+
+				if fileColumnIndexes != nil && fileOffsetIndexes != nil {
+					k := i*len(rg.Columns) + j
+					columnIndexes = append(columnIndexes, fileColumnIndexes[k])
+					offsetIndex := fileOffsetIndexes[k]
+					offsetIndex.PageLocations = append([]format.PageLocation(nil), offsetIndex.PageLocations...)
+					for p := range offsetIndex.PageLocations {
+						offsetIndex.PageLocations[p].Offset += delta
+					}
+					offsetIndexes = append(offsetIndexes, offsetIndex)
+				}
+			}
+			rg.FileOffset = shiftOffset(rg.FileOffset, delta)
+			rg.Ordinal = int16(len(rowGroups))
+			rowGroups = append(rowGroups, rg)
+		}
+	}
+
+	// The page index is only written if all files have one, as it is
+	// addressed by the position of the column chunks.
+	if len(rowGroups) > 0 && len(columnIndexes) == len(rowGroups)*len(rowGroups[0].Columns) {
+		protocol := new(thrift.CompactProtocol)
+		k := 0
+		for i := range rowGroups {
+			for j := range rowGroups[i].Columns {
+				b, err := thrift.Marshal(protocol, &columnIndexes[k])
+				if err != nil {
+					return err
+				}
+				rowGroups[i].Columns[j].ColumnIndexOffset = cw.n
+				rowGroups[i].Columns[j].ColumnIndexLength = int32(len(b))
+				if _, err := cw.Write(b); err != nil {
+					return err
+				}
+				k++
+			}
+		}
+		k = 0
+		for i := range rowGroups {
+			for j := range rowGroups[i].Columns {
+				b, err := thrift.Marshal(protocol, &offsetIndexes[k])
+				if err != nil {
+					return err
+				}
+				rowGroups[i].Columns[j].OffsetIndexOffset = cw.n
+				rowGroups[i].Columns[j].OffsetIndexLength = int32(len(b))
+				if _, err := cw.Write(b); err != nil {
+					return err
+				}
+				k++
+			}
+		}
+	}
+
+	first := files[0].Metadata()
+	footer, err := thrift.Marshal(new(thrift.CompactProtocol), &format.FileMetaData{
+		Version:          first.Version,
+		Schema:           first.Schema,
+		NumRows:          numRows,
+		RowGroups:        rowGroups,
+		KeyValueMetadata: first.KeyValueMetadata,
+		CreatedBy:        first.CreatedBy,
+		ColumnOrders:     first.ColumnOrders,
+	})
+	if err != nil {
+		return err
+	}
+	length := len(footer)
+	footer = append(footer, 0, 0, 0, 0)
+	footer = append(footer, "PAR1"...)
+	binary.LittleEndian.PutUint32(footer[length:], uint32(length))
+	_, err = cw.Write(footer)
+	return err
+}
+
+// dataEndOffset returns the offset at which the row groups of the file end,
+// i.e. where its page index or else its footer starts.
+func dataEndOffset(f *parquet.File) (int64, error) {
+	var trailer [8]byte
+	if _, err := f.ReadAt(trailer[:], f.Size()-8); err != nil {
+		return 0, err
+	}
+	end := f.Size() - 8 - int64(binary.LittleEndian.Uint32(trailer[:4]))
+	for _, rg := range f.Metadata().RowGroups {
+		for _, c := range rg.Columns {
+			if c.ColumnIndexOffset > 0 && c.ColumnIndexOffset < end {
+				end = c.ColumnIndexOffset
+			}
+			if c.OffsetIndexOffset > 0 && c.OffsetIndexOffset < end {
+				end = c.OffsetIndexOffset
+			}
+		}
+	}
+	return end, nil
+}
+
+// shiftOffset moves a file offset by delta, unless it is unset.
+func shiftOffset(offset, delta int64) int64 {
+	if offset == 0 {
+		return 0
+	}
+	return offset + delta
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
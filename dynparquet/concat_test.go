@@ -0,0 +1,99 @@
+package dynparquet
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcatFiles(t *testing.T) {
+	schema := NewSampleSchema()
+	samples := NewTestSamples()
+
+	files := make([]*parquet.File, 0, 2)
+	var expected []parquet.Row
+	for i := 0; i < 2; i++ {
+		for j := range samples {
+			samples[j].Timestamp += int64(i * 10)
+		}
+		buf, err := samples.ToBuffer(schema)
+		require.NoError(t, err)
+		b := bytes.NewBuffer(nil)
+		require.NoError(t, schema.SerializeBuffer(b, buf))
+		serBuf, err := ReaderFromBytes(b.Bytes())
+		require.NoError(t, err)
+		files = append(files, serBuf.ParquetFile())
+		expected = append(expected, readAllRows(t, serBuf.ParquetFile().RowGroups()[0].Rows())...)
+	}
+
+	b := bytes.NewBuffer(nil)
+	require.NoError(t, ConcatFiles(b, files))
+
+	serBuf, err := ReaderFromBytes(b.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, int64(6), serBuf.NumRows())
+	require.Equal(t, 2, serBuf.NumRowGroups())
+	require.Equal(t, files[0].Schema().String(), serBuf.ParquetFile().Schema().String())
+
+	var actual []parquet.Row
+	for _, rg := range serBuf.ParquetFile().RowGroups() {
+		actual = append(actual, readAllRows(t, rg.Rows())...)
+	}
+	require.Equal(t, len(expected), len(actual))
+	for i := range expected {
+		require.True(t, expected[i].Equal(actual[i]), "row %d", i)
+	}
+
+	// The page index of the second row group points at its own pages.
+	rg := serBuf.ParquetFile().RowGroups()[1]
+	rows := rg.Rows()
+	require.NoError(t, rows.SeekToRow(2))
+	actual = readAllRows(t, rows)
+	require.Len(t, actual, 1)
+	require.True(t, expected[5].Equal(actual[0]))
+	for i, chunk := range rg.ColumnChunks() {
+		index, err := chunk.ColumnIndex()
+		require.NoError(t, err)
+		expectedIndex, err := files[1].RowGroups()[0].ColumnChunks()[i].ColumnIndex()
+		require.NoError(t, err)
+		require.Equal(t, expectedIndex.NumPages(), index.NumPages())
+	}
+}
+
+func TestConcatFilesSchemaMismatch(t *testing.T) {
+	schema := NewSampleSchema()
+	files := make([]*parquet.File, 0, 2)
+	for _, labels := range [][]string{{"label1"}, {"label2"}} {
+		buf, err := schema.NewBuffer(map[string][]string{"labels": labels})
+		require.NoError(t, err)
+		b := bytes.NewBuffer(nil)
+		require.NoError(t, schema.SerializeBuffer(b, buf))
+		serBuf, err := ReaderFromBytes(b.Bytes())
+		require.NoError(t, err)
+		files = append(files, serBuf.ParquetFile())
+	}
+	require.Error(t, ConcatFiles(io.Discard, files))
+}
+
+func readAllRows(t *testing.T, rows parquet.Rows) []parquet.Row {
+	t.Helper()
+	defer rows.Close()
+	var result []parquet.Row
+	buf := make([]parquet.Row, 2)
+	for {
+		n, err := rows.ReadRows(buf)
+		for _, row := range buf[:n] {
+			result = append(result, row.Clone())
+		}
+		if err == io.EOF {
+			return result
+		}
+		require.NoError(t, err)
+		if n == 0 {
+			return result
+		}
+	}
+}
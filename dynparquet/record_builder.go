@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"unsafe"
 
 	"github.com/apache/arrow/go/v14/arrow"
 	"github.com/apache/arrow/go/v14/arrow/array"
@@ -76,7 +77,7 @@ const (
 //
 // # Repeated columns
 //
-// Fields of type []int64, []float64, []bool, and []string are supported. These
+// Fields of type []int64, []uint64, []float64, []float32, []bool, and []string are supported. These
 // are represented as arrow.LIST.
 //
 // Generated schema for the repeated columns applies all supported tags. By
@@ -194,7 +195,7 @@ func NewBuild[T any](mem memory.Allocator) *Build[T] {
 				typ = arrow.ListOf(typ)
 				fr.build = newFieldBuild(typ, mem, name, true)
 			}
-		case reflect.Int64, reflect.Float64, reflect.Bool, reflect.String:
+		case reflect.Int64, reflect.Uint64, reflect.Float64, reflect.Float32, reflect.Bool, reflect.String:
 			typ, styp = baseType(fty, dictionary)
 			fr.typ = styp
 			fr.nullable = nullable
@@ -241,8 +242,9 @@ func (b *Build[T]) numRowsBefore(fieldIdx int) int {
 }
 
 func (b *Build[T]) Append(values ...T) error {
-	for _, value := range values {
-		v := reflect.ValueOf(value)
+	for i := range values {
+		// Taking the address of the value avoids copying it to the heap.
+		v := reflect.ValueOf(&values[i]).Elem()
 		for v.Kind() == reflect.Ptr {
 			v = v.Elem()
 		}
@@ -275,6 +277,19 @@ func (b *Build[T]) NewRecord() arrow.Record {
 	)
 }
 
+// Reset discards the values appended since the last record was built. The
+// builders are kept, so that a caller-held Build can be reused without
+// allocating new builders for every batch of values.
+func (b *Build[T]) Reset() {
+	for _, f := range b.fields {
+		b.buffer = f.build.NewArray(b.buffer)
+	}
+	for i := range b.buffer {
+		b.buffer[i].Release()
+	}
+	b.buffer = b.buffer[:0]
+}
+
 func (b Build[T]) Schema(name string) (s *schemapb.Schema) {
 	s = &schemapb.Schema{Name: name, Columns: make([]*schemapb.Column, 0, len(b.fields))}
 	var toSort []*fieldRecord
@@ -328,8 +343,18 @@ type mapFieldBuilder struct {
 	newField   func(string) fieldBuilder
 	rowsBefore func() int
 	columns    map[string]fieldBuilder
-	seen       map[string]struct{}
-	keys       []string
+	// idle are the builders of the dynamic columns of the previous record that
+	// are not part of the current record yet. They are reused if the dynamic
+	// column is appended to again, and released otherwise.
+	idle map[string]fieldBuilder
+	seen map[string]struct{}
+	keys []string
+
+	// iter, key and value are reused to iterate over the maps without
+	// allocating.
+	iter  reflect.MapIter
+	key   reflect.Value
+	value reflect.Value
 }
 
 func newFieldFunc(dt arrow.DataType, mem memory.Allocator, name string, nullable bool) func(string) fieldBuilder {
@@ -349,6 +374,7 @@ func newMapFieldBuilder(newField func(string) fieldBuilder, rowsBefore func() in
 		newField:   newField,
 		rowsBefore: rowsBefore,
 		columns:    make(map[string]fieldBuilder),
+		idle:       make(map[string]fieldBuilder),
 		seen:       make(map[string]struct{}),
 	}
 }
@@ -383,10 +409,13 @@ func (m *mapFieldBuilder) NewArray(a []arrow.Array) []arrow.Array {
 	for _, key := range m.keys {
 		a = m.columns[key].NewArray(a)
 	}
-	for _, v := range m.columns {
+	// Builders that were not used for this record are released, the others
+	// are kept for the next record.
+	for _, v := range m.idle {
 		v.Release()
 	}
-	clear(m.columns)
+	clear(m.idle)
+	m.idle, m.columns = m.columns, m.idle
 	m.keys = m.keys[:0]
 	return a
 }
@@ -398,6 +427,10 @@ func (m *mapFieldBuilder) Release() {
 		v.Release()
 	}
 	clear(m.columns)
+	for _, v := range m.idle {
+		v.Release()
+	}
+	clear(m.idle)
 	m.keys = m.keys[:0]
 }
 
@@ -409,17 +442,24 @@ func (m *mapFieldBuilder) Append(v reflect.Value) error {
 		return nil
 	}
 	clear(m.seen)
-	keys := v.MapKeys()
 	size := m.Len()
 	if size == 0 {
 		// Maybe we never supplied dynamic columns before but other columns were
 		// appended.
 		size = m.rowsBefore()
 	}
-	for _, key := range keys {
-		name := key.Interface().(string)
+	if !m.key.IsValid() {
+		m.key = reflect.New(v.Type().Key()).Elem()
+		m.value = reflect.New(v.Type().Elem()).Elem()
+	}
+	m.iter.Reset(v)
+	defer m.iter.Reset(reflect.Value{})
+	for m.iter.Next() {
+		m.key.SetIterKey(&m.iter)
+		m.value.SetIterValue(&m.iter)
+		name := m.key.String()
 		m.seen[name] = struct{}{}
-		err := m.get(name, size).Append(v.MapIndex(key))
+		err := m.get(name, size).Append(m.value)
 		if err != nil {
 			return err
 		}
@@ -447,7 +487,11 @@ func (m *mapFieldBuilder) get(name string, size int) fieldBuilder {
 	if ok {
 		return f
 	}
-	f = m.newField(name)
+	if f, ok = m.idle[name]; ok {
+		delete(m.idle, name)
+	} else {
+		f = m.newField(name)
+	}
 	for i := 0; i < size; i++ {
 		f.AppendNull()
 	}
@@ -464,9 +508,15 @@ func baseType(fty reflect.Type, dictionary bool) (typ arrow.DataType, sty schema
 	case reflect.Int64:
 		typ = arrow.PrimitiveTypes.Int64
 		sty = schemapb.StorageLayout_TYPE_INT64
+	case reflect.Uint64:
+		typ = arrow.PrimitiveTypes.Uint64
+		sty = schemapb.StorageLayout_TYPE_UINT64
 	case reflect.Float64:
 		typ = arrow.PrimitiveTypes.Float64
 		sty = schemapb.StorageLayout_TYPE_DOUBLE
+	case reflect.Float32:
+		typ = arrow.PrimitiveTypes.Float32
+		sty = schemapb.StorageLayout_TYPE_FLOAT
 	case reflect.Bool:
 		typ = arrow.FixedWidthTypes.Boolean
 		sty = schemapb.StorageLayout_TYPE_BOOL
@@ -532,6 +582,29 @@ func newFieldBuild(dt arrow.DataType, mem memory.Allocator, name string, nullabl
 			}
 			return e.Append(v.Int())
 		}
+	case *array.Uint64Builder:
+		f.buildFunc = func(v reflect.Value) error {
+			if nullable {
+				if v.IsNil() {
+					e.AppendNull()
+					return nil
+				}
+				v = v.Elem()
+			}
+			e.Append(v.Uint())
+			return nil
+		}
+	case *array.Uint64DictionaryBuilder:
+		f.buildFunc = func(v reflect.Value) error {
+			if nullable {
+				if v.IsNil() {
+					e.AppendNull()
+					return nil
+				}
+				v = v.Elem()
+			}
+			return e.Append(v.Uint())
+		}
 	case *array.Float64Builder:
 		f.buildFunc = func(v reflect.Value) error {
 			if nullable {
@@ -555,6 +628,29 @@ func newFieldBuild(dt arrow.DataType, mem memory.Allocator, name string, nullabl
 			}
 			return e.Append(v.Float())
 		}
+	case *array.Float32Builder:
+		f.buildFunc = func(v reflect.Value) error {
+			if nullable {
+				if v.IsNil() {
+					e.AppendNull()
+					return nil
+				}
+				v = v.Elem()
+			}
+			e.Append(float32(v.Float()))
+			return nil
+		}
+	case *array.Float32DictionaryBuilder:
+		f.buildFunc = func(v reflect.Value) error {
+			if nullable {
+				if v.IsNil() {
+					e.AppendNull()
+					return nil
+				}
+				v = v.Elem()
+			}
+			return e.Append(float32(v.Float()))
+		}
 	case *array.BooleanBuilder:
 		f.buildFunc = func(v reflect.Value) error {
 			if nullable {
@@ -576,7 +672,7 @@ func newFieldBuild(dt arrow.DataType, mem memory.Allocator, name string, nullabl
 				}
 				v = v.Elem()
 			}
-			e.Append(v.Interface().(string))
+			e.Append(v.String())
 			return nil
 		}
 	case *array.BinaryDictionaryBuilder:
@@ -588,7 +684,7 @@ func newFieldBuild(dt arrow.DataType, mem memory.Allocator, name string, nullabl
 				}
 				v = v.Elem()
 			}
-			return e.AppendString(v.Interface().(string))
+			return appendDictString(e, v.String())
 		}
 	case *array.ListBuilder:
 		switch build := e.ValueBuilder().(type) {
@@ -615,6 +711,29 @@ func newFieldBuild(dt arrow.DataType, mem memory.Allocator, name string, nullabl
 				build.Reserve(v.Len())
 				return applyInt(v, build.Append)
 			}
+		case *array.Uint64Builder:
+			f.buildFunc = func(v reflect.Value) error {
+				if v.IsNil() {
+					e.AppendNull()
+					return nil
+				}
+				e.Append(true)
+				build.Reserve(v.Len())
+				return applyUint(v, func(i uint64) error {
+					build.Append(i)
+					return nil
+				})
+			}
+		case *array.Uint64DictionaryBuilder:
+			f.buildFunc = func(v reflect.Value) error {
+				if v.IsNil() {
+					e.AppendNull()
+					return nil
+				}
+				e.Append(true)
+				build.Reserve(v.Len())
+				return applyUint(v, build.Append)
+			}
 
 		case *array.Float64Builder:
 			f.buildFunc = func(v reflect.Value) error {
@@ -639,6 +758,29 @@ func newFieldBuild(dt arrow.DataType, mem memory.Allocator, name string, nullabl
 				build.Reserve(v.Len())
 				return applyFloat64(v, build.Append)
 			}
+		case *array.Float32Builder:
+			f.buildFunc = func(v reflect.Value) error {
+				if v.IsNil() {
+					e.AppendNull()
+					return nil
+				}
+				e.Append(true)
+				build.Reserve(v.Len())
+				return applyFloat32(v, func(i float32) error {
+					build.Append(i)
+					return nil
+				})
+			}
+		case *array.Float32DictionaryBuilder:
+			f.buildFunc = func(v reflect.Value) error {
+				if v.IsNil() {
+					e.AppendNull()
+					return nil
+				}
+				e.Append(true)
+				build.Reserve(v.Len())
+				return applyFloat32(v, build.Append)
+			}
 
 		case *array.StringBuilder:
 			f.buildFunc = func(v reflect.Value) error {
@@ -661,7 +803,9 @@ func newFieldBuild(dt arrow.DataType, mem memory.Allocator, name string, nullabl
 				}
 				e.Append(true)
 				build.Reserve(v.Len())
-				return applyString(v, build.AppendString)
+				return applyString(v, func(s string) error {
+					return appendDictString(build, s)
+				})
 			}
 		case *array.BooleanBuilder:
 			f.buildFunc = func(v reflect.Value) error {
@@ -683,9 +827,20 @@ func newFieldBuild(dt arrow.DataType, mem memory.Allocator, name string, nullabl
 	return
 }
 
+// appendDictString appends the string to the dictionary builder without
+// converting it to a byte slice, which would allocate. The builder copies the
+// values it adds to its dictionary.
+func appendDictString(b *array.BinaryDictionaryBuilder, s string) error {
+	if len(s) == 0 {
+		// A nil slice would be appended as null.
+		return b.Append([]byte{})
+	}
+	return b.Append(unsafe.Slice(unsafe.StringData(s), len(s)))
+}
+
 func applyString(v reflect.Value, apply func(string) error) error {
 	return listApply[string](v, func(v reflect.Value) string {
-		return v.Interface().(string)
+		return v.String()
 	}, apply)
 }
 
@@ -695,6 +850,12 @@ func applyFloat64(v reflect.Value, apply func(float64) error) error {
 	}, apply)
 }
 
+func applyFloat32(v reflect.Value, apply func(float32) error) error {
+	return listApply[float32](v, func(v reflect.Value) float32 {
+		return float32(v.Float())
+	}, apply)
+}
+
 func applyBool(v reflect.Value, apply func(bool) error) error {
 	return listApply[bool](v, func(v reflect.Value) bool {
 		return v.Bool()
@@ -707,6 +868,12 @@ func applyInt(v reflect.Value, apply func(int64) error) error {
 	}, apply)
 }
 
+func applyUint(v reflect.Value, apply func(uint64) error) error {
+	return listApply[uint64](v, func(v reflect.Value) uint64 {
+		return v.Uint()
+	}, apply)
+}
+
 func listApply[T any](v reflect.Value, fn func(reflect.Value) T, apply func(T) error) error {
 	for i := 0; i < v.Len(); i++ {
 		err := apply(fn(v.Index(i)))
@@ -736,8 +903,14 @@ func newUUIDSliceField(mem memory.Allocator, name string) (f *fieldBuilderFunc)
 		},
 	}
 	bd := b.(*array.BinaryDictionaryBuilder)
+	var scratch []byte
 	f.buildFunc = func(v reflect.Value) error {
-		return bd.Append(ExtractLocationIDs(v.Interface().([]uuid.UUID)))
+		// Same as ExtractLocationIDs, reusing the buffer of the previous value.
+		scratch = scratch[:0]
+		for i := v.Len() - 1; i >= 0; i-- {
+			scratch = append(scratch, v.Index(i).Addr().Interface().(*uuid.UUID)[:]...)
+		}
+		return bd.Append(scratch)
 	}
 	return
 }
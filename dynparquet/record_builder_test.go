@@ -167,6 +167,32 @@ func TestBuild_pointer_base_types(t *testing.T) {
 	require.JSONEq(t, want, string(got))
 }
 
+func TestBuildReset(t *testing.T) {
+	b := NewBuild[Sample](memory.DefaultAllocator)
+	defer b.Release()
+
+	samples := NewTestSamples()
+	require.NoError(t, b.Append(samples...))
+	b.Reset()
+	require.NoError(t, b.Append(samples[0]))
+	r := b.NewRecord()
+	defer r.Release()
+	require.Equal(t, int64(1), r.NumRows())
+	require.Equal(t, 5, r.Schema().NumFields())
+
+	// Appending values doesn't allocate for every row once the builders are
+	// warmed up.
+	many := make(Samples, 0, 3000)
+	for i := 0; i < 3000/len(samples); i++ {
+		many = append(many, samples...)
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		require.NoError(t, b.Append(many...))
+		b.Reset()
+	})
+	require.Less(t, allocs, float64(len(many))/10)
+}
+
 func point[T any](t T) *T {
 	return &t
 }
@@ -337,11 +337,14 @@ func DefinitionFromParquetFile(file *parquet.File) (*schemapb.Schema, error) {
 		for _, col := range rg.Columns {
 			name := col.MetaData.PathInSchema[0] // we only support flat schemas
 
-			// Check if the column is optional
-			nullable := false
+			// Check if the column is optional or unsigned
+			nullable, unsigned := false, false
 			for _, node := range schema.Fields() {
 				if node.Name() == name {
 					nullable = node.Optional()
+					if lt := node.Type().LogicalType(); lt != nil && lt.Integer != nil {
+						unsigned = !lt.Integer.IsSigned
+					}
 				}
 			}
 
@@ -360,7 +363,7 @@ func DefinitionFromParquetFile(file *parquet.File) (*schemapb.Schema, error) {
 
 			columns = append(columns, &schemapb.Column{
 				Name:          split[0],
-				StorageLayout: parquetColumnMetaDataToStorageLayout(col.MetaData, nullable),
+				StorageLayout: parquetColumnMetaDataToStorageLayout(col.MetaData, nullable, unsigned),
 				Dynamic:       isDynamic,
 			})
 		}
@@ -383,7 +386,7 @@ func SchemaFromParquetFile(file *parquet.File) (*Schema, error) {
 	return SchemaFromDefinition(def)
 }
 
-func parquetColumnMetaDataToStorageLayout(metadata format.ColumnMetaData, nullable bool) *schemapb.StorageLayout {
+func parquetColumnMetaDataToStorageLayout(metadata format.ColumnMetaData, nullable, unsigned bool) *schemapb.StorageLayout {
 	layout := &schemapb.StorageLayout{
 		Nullable: nullable,
 	}
@@ -413,8 +416,13 @@ func parquetColumnMetaDataToStorageLayout(metadata format.ColumnMetaData, nullab
 		layout.Type = schemapb.StorageLayout_TYPE_STRING
 	case format.Int64:
 		layout.Type = schemapb.StorageLayout_TYPE_INT64
+		if unsigned {
+			layout.Type = schemapb.StorageLayout_TYPE_UINT64
+		}
 	case format.Double:
 		layout.Type = schemapb.StorageLayout_TYPE_DOUBLE
+	case format.Float:
+		layout.Type = schemapb.StorageLayout_TYPE_FLOAT
 	case format.Boolean:
 		layout.Type = schemapb.StorageLayout_TYPE_BOOL
 	}
@@ -479,6 +487,10 @@ func storageLayoutToParquetNode(l StorageLayout) (parquet.Node, error) {
 		node = parquet.Int(64)
 	case int32(schemapb.StorageLayout_TYPE_DOUBLE):
 		node = parquet.Leaf(parquet.DoubleType)
+	case int32(schemapb.StorageLayout_TYPE_FLOAT):
+		node = parquet.Leaf(parquet.FloatType)
+	case int32(schemapb.StorageLayout_TYPE_UINT64):
+		node = parquet.Uint(64)
 	case int32(schemapb.StorageLayout_TYPE_BOOL):
 		node = parquet.Leaf(parquet.BooleanType)
 	default:
@@ -1651,3 +1663,59 @@ func SortingColumnsFromDef(def *schemav2pb.Schema) ([]parquet.SortingColumn, err
 
 	return sortingColumns, nil
 }
+
+// LightweightEncoded returns a schema with the same columns as s whose
+// columns are encoded to be cheap to decode: plain string columns are
+// dictionary encoded, plain int64 columns are delta encoded, and no column is
+// compressed.
+func (s *Schema) LightweightEncoded() (*Schema, error) {
+	if s.def == nil {
+		return nil, fmt.Errorf("schema has no definition")
+	}
+	def := proto.Clone(s.def)
+	switch def := def.(type) {
+	case *schemapb.Schema:
+		for _, col := range def.Columns {
+			if col.StorageLayout == nil {
+				continue
+			}
+			if col.StorageLayout.Encoding == schemapb.StorageLayout_ENCODING_PLAIN_UNSPECIFIED {
+				switch col.StorageLayout.Type {
+				case schemapb.StorageLayout_TYPE_STRING:
+					col.StorageLayout.Encoding = schemapb.StorageLayout_ENCODING_RLE_DICTIONARY
+				case schemapb.StorageLayout_TYPE_INT64:
+					col.StorageLayout.Encoding = schemapb.StorageLayout_ENCODING_DELTA_BINARY_PACKED
+				}
+			}
+			col.StorageLayout.Compression = schemapb.StorageLayout_COMPRESSION_NONE_UNSPECIFIED
+		}
+	case *schemav2pb.Schema:
+		var lightweight func(nodes []*schemav2pb.Node)
+		lightweight = func(nodes []*schemav2pb.Node) {
+			for _, node := range nodes {
+				switch n := node.Type.(type) {
+				case *schemav2pb.Node_Group:
+					lightweight(n.Group.GetNodes())
+				case *schemav2pb.Node_Leaf:
+					layout := n.Leaf.GetStorageLayout()
+					if layout == nil {
+						continue
+					}
+					if layout.Encoding == schemav2pb.StorageLayout_ENCODING_PLAIN_UNSPECIFIED {
+						switch layout.Type {
+						case schemav2pb.StorageLayout_TYPE_STRING:
+							layout.Encoding = schemav2pb.StorageLayout_ENCODING_RLE_DICTIONARY
+						case schemav2pb.StorageLayout_TYPE_INT64:
+							layout.Encoding = schemav2pb.StorageLayout_ENCODING_DELTA_BINARY_PACKED
+						}
+					}
+					layout.Compression = schemav2pb.StorageLayout_COMPRESSION_NONE_UNSPECIFIED
+				}
+			}
+		}
+		lightweight(def.Root.GetNodes())
+	default:
+		return nil, fmt.Errorf("unsupported schema definition %T", def)
+	}
+	return SchemaFromDefinition(def)
+}
@@ -354,3 +354,42 @@ func BenchmarkIsDynamicColumn(b *testing.B) {
 		_ = schema.IsDynamicColumn("labels.label1")
 	}
 }
+
+func TestValidateDefinition(t *testing.T) {
+	require.NoError(t, ValidateDefinition(SampleDefinition()))
+	require.NoError(t, ValidateDefinition(SampleDefinitionWithFloat()))
+	require.NoError(t, ValidateDefinition(NewNestedSampleSchema(t)))
+
+	def := &schemapb.Schema{
+		Name: "test_schema",
+		Columns: []*schemapb.Column{{
+			Name: "value",
+			StorageLayout: &schemapb.StorageLayout{
+				Type:     schemapb.StorageLayout_TYPE_DOUBLE,
+				Encoding: schemapb.StorageLayout_ENCODING_DELTA_BYTE_ARRAY,
+			},
+		}, {
+			Name: "labels",
+			StorageLayout: &schemapb.StorageLayout{
+				Type: schemapb.StorageLayout_TYPE_STRING,
+			},
+			Dynamic: true,
+		}, {
+			Name: "value",
+			StorageLayout: &schemapb.StorageLayout{
+				Type: schemapb.StorageLayout_TYPE_INT64,
+			},
+		}},
+		SortingColumns: []*schemapb.SortingColumn{{
+			Name:      "timestamp",
+			Direction: schemapb.SortingColumn_DIRECTION_ASCENDING,
+		}},
+	}
+	err := ValidateDefinition(def)
+	require.Error(t, err)
+	// All the problems are reported at once.
+	require.ErrorContains(t, err, `column "value": encoding ENCODING_DELTA_BYTE_ARRAY is only valid for string columns`)
+	require.ErrorContains(t, err, `dynamic column "labels" must be nullable`)
+	require.ErrorContains(t, err, `column "value" is defined more than once`)
+	require.ErrorContains(t, err, `sorting column "timestamp" is not a column of the schema`)
+}
@@ -0,0 +1,200 @@
+package dynparquet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	schemapb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/schema/v1alpha1"
+	schemav2pb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/schema/v1alpha2"
+)
+
+// ValidateDefinition checks the sanity of a schema definition: columns are
+// named, unique and have a valid storage layout, encodings are valid for the
+// types of their columns, dynamic columns are nullable and not repeated, and
+// sorting columns exist and have a direction. All the problems found are
+// returned joined in a single error, so that they can be fixed at once
+// instead of surfacing one by one when the schema is used.
+func ValidateDefinition(def proto.Message) error {
+	var errs []error
+	switch def := def.(type) {
+	case *schemapb.Schema:
+		errs = validateV1Definition(def)
+	case *schemav2pb.Schema:
+		errs = validateV2Definition(def)
+	default:
+		return fmt.Errorf("unsupported schema definition %T", def)
+	}
+	return errors.Join(errs...)
+}
+
+func validateV1Definition(def *schemapb.Schema) []error {
+	var errs []error
+	if def.Name == "" {
+		errs = append(errs, errors.New("schema has no name"))
+	}
+	if len(def.Columns) == 0 {
+		errs = append(errs, errors.New("schema has no columns"))
+	}
+
+	columns := make(map[string]*schemapb.Column, len(def.Columns))
+	for i, col := range def.Columns {
+		if col.Name == "" {
+			errs = append(errs, fmt.Errorf("column %d has no name", i))
+			continue
+		}
+		if _, ok := columns[col.Name]; ok {
+			errs = append(errs, fmt.Errorf("column %q is defined more than once", col.Name))
+			continue
+		}
+		columns[col.Name] = col
+		if col.Dynamic && strings.Contains(col.Name, ".") {
+			errs = append(errs, fmt.Errorf("dynamic column %q: name must not contain '.'", col.Name))
+		}
+		if col.StorageLayout == nil {
+			errs = append(errs, fmt.Errorf("column %q has no storage layout", col.Name))
+			continue
+		}
+		if err := validateStorageLayout(&v1storageLayoutWrapper{col.StorageLayout}); err != nil {
+			errs = append(errs, fmt.Errorf("column %q: %w", col.Name, err))
+		}
+		if col.Dynamic && !col.StorageLayout.Nullable {
+			errs = append(errs, fmt.Errorf("dynamic column %q must be nullable", col.Name))
+		}
+		if col.Dynamic && col.StorageLayout.Repeated {
+			errs = append(errs, fmt.Errorf("dynamic column %q must not be repeated", col.Name))
+		}
+	}
+
+	sorting := make(map[string]struct{}, len(def.SortingColumns))
+	for _, col := range def.SortingColumns {
+		if _, ok := columns[col.Name]; !ok {
+			errs = append(errs, fmt.Errorf("sorting column %q is not a column of the schema", col.Name))
+		}
+		if _, ok := sorting[col.Name]; ok {
+			errs = append(errs, fmt.Errorf("sorting column %q is defined more than once", col.Name))
+		}
+		sorting[col.Name] = struct{}{}
+		if col.Direction == schemapb.SortingColumn_DIRECTION_UNKNOWN_UNSPECIFIED {
+			errs = append(errs, fmt.Errorf("sorting column %q has no direction", col.Name))
+		}
+	}
+	if def.UniquePrimaryIndex && len(def.SortingColumns) == 0 {
+		errs = append(errs, errors.New("unique primary index requires sorting columns"))
+	}
+	return errs
+}
+
+func validateV2Definition(def *schemav2pb.Schema) []error {
+	var errs []error
+	if def.Root == nil {
+		return []error{errors.New("schema has no root group")}
+	}
+	if def.Root.Name == "" {
+		errs = append(errs, errors.New("schema has no name"))
+	}
+	if len(def.Root.Nodes) == 0 {
+		errs = append(errs, errors.New("schema has no columns"))
+	}
+
+	var validateNodes func(path string, nodes []*schemav2pb.Node)
+	validateNodes = func(path string, nodes []*schemav2pb.Node) {
+		names := make(map[string]struct{}, len(nodes))
+		for i, node := range nodes {
+			var name string
+			switch n := node.GetType().(type) {
+			case *schemav2pb.Node_Leaf:
+				name = n.Leaf.GetName()
+			case *schemav2pb.Node_Group:
+				name = n.Group.GetName()
+			default:
+				errs = append(errs, fmt.Errorf("node %d of %q has no type", i, path))
+				continue
+			}
+			if name == "" {
+				errs = append(errs, fmt.Errorf("node %d of %q has no name", i, path))
+				continue
+			}
+			if _, ok := names[name]; ok {
+				errs = append(errs, fmt.Errorf("node %q of %q is defined more than once", name, path))
+				continue
+			}
+			names[name] = struct{}{}
+
+			switch n := node.GetType().(type) {
+			case *schemav2pb.Node_Leaf:
+				if n.Leaf.StorageLayout == nil {
+					errs = append(errs, fmt.Errorf("column %q has no storage layout", name))
+					continue
+				}
+				if err := validateStorageLayout(&v2storageLayoutWrapper{n.Leaf.StorageLayout}); err != nil {
+					errs = append(errs, fmt.Errorf("column %q: %w", name, err))
+				}
+			case *schemav2pb.Node_Group:
+				validateNodes(name, n.Group.Nodes)
+			}
+		}
+	}
+	validateNodes(def.Root.Name, def.Root.Nodes)
+
+	sorting := make(map[string]struct{}, len(def.SortingColumns))
+	for _, col := range def.SortingColumns {
+		if col.Path == "" {
+			errs = append(errs, errors.New("sorting column has no path"))
+			continue
+		}
+		if _, ok := sorting[col.Path]; ok {
+			errs = append(errs, fmt.Errorf("sorting column %q is defined more than once", col.Path))
+		}
+		sorting[col.Path] = struct{}{}
+		if col.Direction == schemav2pb.SortingColumn_DIRECTION_UNKNOWN_UNSPECIFIED {
+			errs = append(errs, fmt.Errorf("sorting column %q has no direction", col.Path))
+		}
+	}
+	if def.UniquePrimaryIndex && len(def.SortingColumns) == 0 {
+		errs = append(errs, errors.New("unique primary index requires sorting columns"))
+	}
+	return errs
+}
+
+// validateStorageLayout checks that the type, encoding and compression of the
+// layout are known and that the encoding can encode values of the type.
+func validateStorageLayout(l StorageLayout) error {
+	var errs []error
+	typ := l.GetTypeInt32()
+	switch typ {
+	case int32(schemapb.StorageLayout_TYPE_STRING),
+		int32(schemapb.StorageLayout_TYPE_INT64),
+		int32(schemapb.StorageLayout_TYPE_DOUBLE),
+		int32(schemapb.StorageLayout_TYPE_FLOAT),
+		int32(schemapb.StorageLayout_TYPE_UINT64),
+		int32(schemapb.StorageLayout_TYPE_BOOL):
+	default:
+		errs = append(errs, fmt.Errorf("unknown storage layout type: %v", typ))
+	}
+
+	switch enc := l.GetEncodingInt32(); enc {
+	case int32(schemapb.StorageLayout_ENCODING_PLAIN_UNSPECIFIED),
+		int32(schemapb.StorageLayout_ENCODING_RLE_DICTIONARY):
+	case int32(schemapb.StorageLayout_ENCODING_DELTA_BINARY_PACKED):
+		if typ != int32(schemapb.StorageLayout_TYPE_INT64) && typ != int32(schemapb.StorageLayout_TYPE_UINT64) {
+			errs = append(errs, fmt.Errorf("encoding %s is only valid for int64 and uint64 columns", schemapb.StorageLayout_Encoding(enc)))
+		}
+	case int32(schemapb.StorageLayout_ENCODING_DELTA_BYTE_ARRAY),
+		int32(schemapb.StorageLayout_ENCODING_DELTA_LENGTH_BYTE_ARRAY):
+		if typ != int32(schemapb.StorageLayout_TYPE_STRING) {
+			errs = append(errs, fmt.Errorf("encoding %s is only valid for string columns", schemapb.StorageLayout_Encoding(enc)))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown encoding: %v", enc))
+	}
+
+	if comp := l.GetCompressionInt32(); comp != int32(schemapb.StorageLayout_COMPRESSION_NONE_UNSPECIFIED) {
+		if _, err := compressionFromDefinition(comp); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,131 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/thanos-io/objstore"
+
+	"github.com/polarsignals/frostdb/storage"
+)
+
+// Bucket is a storage.Bucket that transparently encrypts objects on upload
+// and decrypts them on read. Objects are encrypted under their name, so an
+// object copied or renamed in the underlying bucket can't be decrypted.
+//
+// Parquet modular encryption is not supported by the Parquet library used by
+// frostdb, so blocks are encrypted as a whole, including their Parquet
+// metadata. The id of the key a block is encrypted with is stored in the
+// unencrypted header of the object instead, see KeyID. Since the stream is
+// encrypted in chunks, reading a range of a block only requires fetching and
+// decrypting the chunks that cover the range.
+type Bucket struct {
+	storage.Bucket
+	keys KeyProvider
+}
+
+// NewBucket returns a Bucket that encrypts objects written to the given
+// bucket with keys provided by keys.
+func NewBucket(bucket storage.Bucket, keys KeyProvider) *Bucket {
+	return &Bucket{
+		Bucket: bucket,
+		keys:   keys,
+	}
+}
+
+// Upload encrypts the contents of r and uploads them to the underlying bucket.
+func (b *Bucket) Upload(ctx context.Context, name string, r io.Reader) error {
+	pr, pw := io.Pipe()
+	go func() {
+		w, err := NewWriter(pw, b.keys, name)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(w.Close())
+	}()
+
+	err := b.Bucket.Upload(ctx, name, pr)
+	// Unblock the writing goroutine in case the upload returned early.
+	pr.Close()
+	return err
+}
+
+// Get returns a reader over the decrypted object.
+func (b *Bucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	r, err := b.readerAt(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(r.NewReader()), nil
+}
+
+// GetRange returns a reader over the given range of the decrypted object. A
+// negative length reads until the end of the object.
+func (b *Bucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	r, err := b.readerAt(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		length = r.Size() - off
+	}
+	return io.NopCloser(io.NewSectionReader(r, off, length)), nil
+}
+
+// GetReaderAt returns an io.ReaderAt over the decrypted object.
+func (b *Bucket) GetReaderAt(ctx context.Context, name string) (io.ReaderAt, error) {
+	return b.readerAt(ctx, name)
+}
+
+// Attributes returns the attributes of the object. The size is the size of
+// the decrypted object.
+func (b *Bucket) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	r, attrs, err := b.open(ctx, name)
+	if err != nil {
+		return objstore.ObjectAttributes{}, err
+	}
+	attrs.Size = r.Size()
+	return attrs, nil
+}
+
+// KeyID returns the id of the key the object was encrypted with, without
+// decrypting it, e.g. to find the blocks that are still encrypted with a key
+// that is rotated out.
+func (b *Bucket) KeyID(ctx context.Context, name string) (string, error) {
+	ra, err := b.Bucket.GetReaderAt(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	id, err := KeyID(ra)
+	if err != nil {
+		return "", fmt.Errorf("read key id of object %s: %w", name, err)
+	}
+	return id, nil
+}
+
+func (b *Bucket) readerAt(ctx context.Context, name string) (*ReaderAt, error) {
+	r, _, err := b.open(ctx, name)
+	return r, err
+}
+
+func (b *Bucket) open(ctx context.Context, name string) (*ReaderAt, objstore.ObjectAttributes, error) {
+	attrs, err := b.Bucket.Attributes(ctx, name)
+	if err != nil {
+		return nil, objstore.ObjectAttributes{}, err
+	}
+	ra, err := b.Bucket.GetReaderAt(ctx, name)
+	if err != nil {
+		return nil, objstore.ObjectAttributes{}, err
+	}
+	r, err := NewReaderAt(ra, attrs.Size, b.keys, name)
+	if err != nil {
+		return nil, objstore.ObjectAttributes{}, fmt.Errorf("open encrypted object %s: %w", name, err)
+	}
+	return r, attrs, nil
+}
@@ -0,0 +1,455 @@
+// Package encryption implements encryption at rest for the artifacts frostdb
+// persists: WAL records, snapshots and bucket blocks.
+//
+// Data is encrypted with AES-GCM. Streams (snapshots, blocks) are split into
+// fixed-size chunks that are sealed independently so that encrypted files can
+// still be read at random offsets, which is how Parquet files are read from
+// object storage. Every encrypted artifact starts with a small header that
+// records the id of the key used to encrypt it, so that keys can be rotated
+// without re-encrypting existing data.
+//
+// The on-disk format of an encrypted stream is:
+//
+//	4-byte magic "FDBE"
+//	1-byte version
+//	2-byte length of the key id (little endian)
+//	<key id>
+//	4-byte plaintext chunk size (little endian)
+//	<chunk 0> ... <chunk n>
+//
+// Each chunk is a 12-byte nonce followed by the sealed chunk (ciphertext and a
+// 16-byte authentication tag). The name of the encrypted object, the index of
+// the chunk and whether it is the last chunk of the stream are authenticated
+// as additional data, which prevents chunks from being reordered, moved
+// between objects encrypted with the same key, or the stream from being
+// truncated at a chunk boundary without detection. The name is not stored, it
+// must be passed again to decrypt the object.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	magic   = "FDBE"
+	version = 2
+
+	// DefaultChunkSize is the size of the plaintext chunks streams are split
+	// into before being sealed.
+	DefaultChunkSize = 64 * 1024
+
+	nonceSize = 12
+	tagSize   = 16
+)
+
+var (
+	// ErrNotEncrypted is returned when the data passed to a decrypting
+	// function does not start with the encryption header.
+	ErrNotEncrypted = errors.New("data is not encrypted")
+	// ErrUnknownKey is returned by a KeyProvider if it does not know the
+	// requested key.
+	ErrUnknownKey = errors.New("unknown encryption key")
+	// ErrAuthentication is returned when encrypted data fails authentication,
+	// because it was encrypted with a different key or under a different
+	// name, or was modified.
+	ErrAuthentication = errors.New("message authentication failed")
+)
+
+// KeyProvider provides the keys used to encrypt and decrypt data. Keys must be
+// 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+type KeyProvider interface {
+	// CurrentKey returns the key that new data should be encrypted with and
+	// its id. The id is stored alongside the encrypted data.
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key with the given id. It is used when decrypting data
+	// that may have been encrypted with a key that is no longer current.
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeyProvider is a KeyProvider backed by an in-memory set of keys.
+type StaticKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeyProvider returns a KeyProvider that encrypts with the key
+// identified by current. Older keys can be passed in keys to allow reading
+// data encrypted before a key rotation.
+func NewStaticKeyProvider(current string, keys map[string][]byte) (*StaticKeyProvider, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current key %q: %w", current, ErrUnknownKey)
+	}
+	for id, key := range keys {
+		if _, err := aes.NewCipher(key); err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+	}
+	return &StaticKeyProvider{
+		current: current,
+		keys:    keys,
+	}, nil
+}
+
+func (p *StaticKeyProvider) CurrentKey() (string, []byte, error) {
+	return p.current, p.keys[p.current], nil
+}
+
+func (p *StaticKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("key %q: %w", id, ErrUnknownKey)
+	}
+	return key, nil
+}
+
+type header struct {
+	keyID     string
+	chunkSize int
+}
+
+func (h header) size() int {
+	return len(magic) + 1 + 2 + len(h.keyID) + 4
+}
+
+func (h header) marshal() []byte {
+	b := make([]byte, 0, h.size())
+	b = append(b, magic...)
+	b = append(b, version)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(h.keyID)))
+	b = append(b, h.keyID...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(h.chunkSize))
+	return b
+}
+
+// readHeader reads the header from the start of r.
+func readHeader(r io.ReaderAt) (header, error) {
+	fixed := make([]byte, len(magic)+1+2)
+	if _, err := r.ReadAt(fixed, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return header{}, ErrNotEncrypted
+		}
+		return header{}, err
+	}
+	if string(fixed[:len(magic)]) != magic {
+		return header{}, ErrNotEncrypted
+	}
+	if v := fixed[len(magic)]; v != version {
+		return header{}, fmt.Errorf("unsupported encryption version %d", v)
+	}
+	idLen := int(binary.LittleEndian.Uint16(fixed[len(magic)+1:]))
+	rest := make([]byte, idLen+4)
+	if _, err := r.ReadAt(rest, int64(len(fixed))); err != nil {
+		return header{}, fmt.Errorf("read encryption header: %w", err)
+	}
+	h := header{
+		keyID:     string(rest[:idLen]),
+		chunkSize: int(binary.LittleEndian.Uint32(rest[idLen:])),
+	}
+	if h.chunkSize <= 0 {
+		return header{}, fmt.Errorf("invalid encryption chunk size %d", h.chunkSize)
+	}
+	return h, nil
+}
+
+// KeyID returns the id of the key the given encrypted data was encrypted with.
+func KeyID(r io.ReaderAt) (string, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return "", err
+	}
+	return h.keyID, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData returns the additional data authenticated with the given
+// chunk of the named object. The name is variable-length but the chunk index
+// and last flag are not, so the encoding is unambiguous.
+func additionalData(name string, chunk uint64, last bool) []byte {
+	ad := make([]byte, len(name)+9)
+	n := copy(ad, name)
+	binary.LittleEndian.PutUint64(ad[n:], chunk)
+	if last {
+		ad[n+8] = 1
+	}
+	return ad
+}
+
+// Writer encrypts everything written to it into the underlying writer. Close
+// must be called to flush the final chunk. Close does not close the
+// underlying writer.
+type Writer struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	name  string
+	buf   []byte
+	out   []byte
+	chunk uint64
+	err   error
+}
+
+// NewWriter returns a Writer that encrypts data written to it with the
+// current key of kp. The name identifies the object, e.g. its path, and must be
+// passed to NewReaderAt to decrypt it.
+func NewWriter(w io.Writer, kp KeyProvider, name string) (*Writer, error) {
+	return newWriter(w, kp, name, DefaultChunkSize)
+}
+
+func newWriter(w io.Writer, kp KeyProvider, name string, chunkSize int) (*Writer, error) {
+	id, key, err := kp.CurrentKey()
+	if err != nil {
+		return nil, fmt.Errorf("get current key: %w", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	h := header{keyID: id, chunkSize: chunkSize}
+	if _, err := w.Write(h.marshal()); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:    w,
+		aead: aead,
+		name: name,
+		buf:  make([]byte, 0, chunkSize),
+		out:  make([]byte, 0, nonceSize+chunkSize+tagSize),
+	}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	written := 0
+	for len(p) > 0 {
+		if len(w.buf) == cap(w.buf) {
+			// Only flush a full chunk once more data arrives, since the last
+			// chunk must be flagged as such on Close.
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *Writer) flush(last bool) error {
+	nonce := w.out[:nonceSize]
+	if _, err := rand.Read(nonce); err != nil {
+		w.err = err
+		return err
+	}
+	sealed := w.aead.Seal(nonce, nonce, w.buf, additionalData(w.name, w.chunk, last))
+	if _, err := w.w.Write(sealed); err != nil {
+		w.err = err
+		return err
+	}
+	w.out = sealed[:0]
+	w.buf = w.buf[:0]
+	w.chunk++
+	return nil
+}
+
+// Close seals and writes the final chunk.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	err := w.flush(true)
+	if err == nil {
+		w.err = errors.New("encryption writer closed")
+	}
+	return err
+}
+
+// ReaderAt decrypts an encrypted stream at random offsets.
+type ReaderAt struct {
+	r         io.ReaderAt
+	aead      cipher.AEAD
+	name      string
+	keyID     string
+	dataStart int64
+	chunkSize int64
+	numChunks int64
+	size      int64
+
+	bufPool sync.Pool
+}
+
+// NewReaderAt returns a ReaderAt that decrypts the encrypted stream of the
+// given (encrypted) size in r. The name must be the name the stream was
+// encrypted with.
+func NewReaderAt(r io.ReaderAt, size int64, kp KeyProvider, name string) (*ReaderAt, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	key, err := kp.Key(h.keyID)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	dataStart := int64(h.size())
+	encChunkSize := int64(nonceSize + h.chunkSize + tagSize)
+	dataSize := size - dataStart
+	if dataSize < nonceSize+tagSize {
+		return nil, fmt.Errorf("encrypted stream too short: %d bytes", size)
+	}
+	numChunks := (dataSize + encChunkSize - 1) / encChunkSize
+	lastChunkSize := dataSize - (numChunks-1)*encChunkSize
+	if lastChunkSize < nonceSize+tagSize {
+		return nil, fmt.Errorf("encrypted stream has a truncated final chunk")
+	}
+
+	ra := &ReaderAt{
+		r:         r,
+		aead:      aead,
+		name:      name,
+		keyID:     h.keyID,
+		dataStart: dataStart,
+		chunkSize: int64(h.chunkSize),
+		numChunks: numChunks,
+		size:      (numChunks-1)*int64(h.chunkSize) + lastChunkSize - nonceSize - tagSize,
+	}
+	ra.bufPool.New = func() any {
+		b := make([]byte, encChunkSize)
+		return &b
+	}
+	return ra, nil
+}
+
+// Size returns the size of the decrypted stream.
+func (r *ReaderAt) Size() int64 { return r.size }
+
+// KeyID returns the id of the key the stream was encrypted with.
+func (r *ReaderAt) KeyID() string { return r.keyID }
+
+// ReadAt implements io.ReaderAt over the decrypted stream.
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	bufp := r.bufPool.Get().(*[]byte)
+	defer r.bufPool.Put(bufp)
+
+	n := 0
+	for n < len(p) && off < r.size {
+		chunk := off / r.chunkSize
+		plain, err := r.readChunk(chunk, *bufp)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], plain[off-chunk*r.chunkSize:])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *ReaderAt) readChunk(chunk int64, buf []byte) ([]byte, error) {
+	encChunkSize := int64(nonceSize) + r.chunkSize + tagSize
+	start := r.dataStart + chunk*encChunkSize
+	length := encChunkSize
+	last := chunk == r.numChunks-1
+	if last {
+		length = r.size - chunk*r.chunkSize + nonceSize + tagSize
+	}
+	buf = buf[:length]
+	if _, err := r.r.ReadAt(buf, start); err != nil && !(errors.Is(err, io.EOF) && last) {
+		return nil, fmt.Errorf("read encrypted chunk %d: %w", chunk, err)
+	}
+	plain, err := r.aead.Open(buf[nonceSize:nonceSize], buf[:nonceSize], buf[nonceSize:], additionalData(r.name, uint64(chunk), last))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt chunk %d: %w", chunk, ErrAuthentication)
+	}
+	return plain, nil
+}
+
+// NewReader returns an io.Reader that decrypts the whole stream sequentially.
+func (r *ReaderAt) NewReader() io.Reader {
+	return io.NewSectionReader(r, 0, r.size)
+}
+
+// Encrypt encrypts a single message with the current key of kp. It is meant
+// for small messages such as WAL records; streams should use NewWriter. The
+// name identifies the message and must be passed to Decrypt.
+func Encrypt(kp KeyProvider, name string, plaintext []byte) ([]byte, error) {
+	id, key, err := kp.CurrentKey()
+	if err != nil {
+		return nil, fmt.Errorf("get current key: %w", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	// A message is encoded as a stream with a single chunk.
+	h := header{keyID: id, chunkSize: len(plaintext) + 1}
+	out := make([]byte, 0, h.size()+nonceSize+len(plaintext)+tagSize)
+	out = append(out, h.marshal()...)
+	nonce := out[len(out) : len(out)+nonceSize]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = out[:len(out)+nonceSize]
+	return aead.Seal(out, nonce, plaintext, additionalData(name, 0, true)), nil
+}
+
+// Decrypt decrypts a message encrypted with Encrypt under the given name.
+func Decrypt(kp KeyProvider, name string, ciphertext []byte) ([]byte, error) {
+	r, err := NewReaderAt(bytesReaderAt(ciphertext), int64(len(ciphertext)), kp, name)
+	if err != nil {
+		return nil, err
+	}
+	if r.numChunks != 1 {
+		return nil, fmt.Errorf("expected a single encrypted chunk, found %d", r.numChunks)
+	}
+	buf := make([]byte, nonceSize+r.size+tagSize)
+	return r.readChunk(0, buf)
+}
+
+// IsEncrypted returns whether the given data starts with an encryption header.
+func IsEncrypted(data []byte) bool {
+	return len(data) >= len(magic) && string(data[:len(magic)]) == magic
+}
+
+type bytesReaderAt []byte
+
+func (b bytesReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
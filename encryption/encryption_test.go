@@ -0,0 +1,210 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/polarsignals/frostdb/storage"
+)
+
+func newTestKeyProvider(t *testing.T, current string, ids ...string) *StaticKeyProvider {
+	t.Helper()
+	keys := map[string][]byte{}
+	for _, id := range append(ids, current) {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		require.NoError(t, err)
+		keys[id] = key
+	}
+	kp, err := NewStaticKeyProvider(current, keys)
+	require.NoError(t, err)
+	return kp
+}
+
+func TestReaderAt(t *testing.T) {
+	kp := newTestKeyProvider(t, "key1")
+	const chunkSize = 16
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 10 * chunkSize, 10*chunkSize + 7} {
+		plaintext := make([]byte, size)
+		_, err := rand.Read(plaintext)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		w, err := newWriter(&buf, kp, "obj", chunkSize)
+		require.NoError(t, err)
+		// Write in odd-sized pieces to exercise chunk boundaries.
+		for p := plaintext; len(p) > 0; {
+			n := min(len(p), 5)
+			_, err := w.Write(p[:n])
+			require.NoError(t, err)
+			p = p[n:]
+		}
+		require.NoError(t, w.Close())
+		require.True(t, IsEncrypted(buf.Bytes()))
+
+		r, err := NewReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()), kp, "obj")
+		require.NoError(t, err)
+		require.Equal(t, int64(size), r.Size())
+		require.Equal(t, "key1", r.KeyID())
+
+		all, err := io.ReadAll(r.NewReader())
+		require.NoError(t, err)
+		require.Equal(t, plaintext, all)
+
+		for off := 0; off < size; off += 3 {
+			for _, length := range []int{1, chunkSize, 2*chunkSize + 1} {
+				p := make([]byte, length)
+				n, err := r.ReadAt(p, int64(off))
+				end := min(off+length, size)
+				if off+length > size {
+					require.ErrorIs(t, err, io.EOF)
+				} else {
+					require.NoError(t, err)
+				}
+				require.Equal(t, plaintext[off:end], p[:n])
+			}
+		}
+	}
+}
+
+func TestReaderAtTampered(t *testing.T) {
+	kp := newTestKeyProvider(t, "key1")
+	var buf bytes.Buffer
+	w, err := newWriter(&buf, kp, "obj", 16)
+	require.NoError(t, err)
+	_, err = w.Write(bytes.Repeat([]byte("a"), 100))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	t.Run("Modified", func(t *testing.T) {
+		data := bytes.Clone(buf.Bytes())
+		data[len(data)-1] ^= 0xff
+		r, err := NewReaderAt(bytes.NewReader(data), int64(len(data)), kp, "obj")
+		require.NoError(t, err)
+		_, err = io.ReadAll(r.NewReader())
+		require.Error(t, err)
+	})
+
+	t.Run("Renamed", func(t *testing.T) {
+		data := buf.Bytes()
+		r, err := NewReaderAt(bytes.NewReader(data), int64(len(data)), kp, "other")
+		require.NoError(t, err)
+		_, err = io.ReadAll(r.NewReader())
+		require.Error(t, err)
+	})
+
+	t.Run("ChunkFromOtherObject", func(t *testing.T) {
+		var other bytes.Buffer
+		w, err := newWriter(&other, kp, "other", 16)
+		require.NoError(t, err)
+		_, err = w.Write(bytes.Repeat([]byte("b"), 100))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		// Replace the first chunk with the first chunk of the other object.
+		encChunkSize := nonceSize + 16 + tagSize
+		start := header{keyID: "key1"}.size()
+		data := bytes.Clone(buf.Bytes())
+		copy(data[start:start+encChunkSize], other.Bytes()[start:])
+		r, err := NewReaderAt(bytes.NewReader(data), int64(len(data)), kp, "obj")
+		require.NoError(t, err)
+		_, err = r.ReadAt(make([]byte, 1), 0)
+		require.Error(t, err)
+	})
+
+	t.Run("TruncatedAtChunkBoundary", func(t *testing.T) {
+		encChunkSize := nonceSize + 16 + tagSize
+		data := buf.Bytes()[:header{keyID: "key1"}.size()+2*encChunkSize]
+		r, err := NewReaderAt(bytes.NewReader(data), int64(len(data)), kp, "obj")
+		require.NoError(t, err)
+		_, err = io.ReadAll(r.NewReader())
+		require.Error(t, err)
+	})
+}
+
+func TestKeyRotation(t *testing.T) {
+	old := newTestKeyProvider(t, "key1")
+	sealed, err := Encrypt(old, "msg", []byte("hello"))
+	require.NoError(t, err)
+
+	keys := map[string][]byte{"key2": make([]byte, 32)}
+	keys["key1"], err = old.Key("key1")
+	require.NoError(t, err)
+	rotated, err := NewStaticKeyProvider("key2", keys)
+	require.NoError(t, err)
+
+	opened, err := Decrypt(rotated, "msg", sealed)
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), opened)
+
+	_, err = Decrypt(newTestKeyProvider(t, "key3"), "msg", sealed)
+	require.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = Decrypt(rotated, "other", sealed)
+	require.Error(t, err)
+
+	_, err = Decrypt(rotated, "msg", []byte("hello"))
+	require.ErrorIs(t, err, ErrNotEncrypted)
+}
+
+func TestBucket(t *testing.T) {
+	ctx := context.Background()
+	inner := storage.NewBucketReaderAt(objstore.NewInMemBucket())
+	b := NewBucket(inner, newTestKeyProvider(t, "key1"))
+
+	plaintext := make([]byte, 3*DefaultChunkSize+100)
+	_, err := rand.Read(plaintext)
+	require.NoError(t, err)
+	require.NoError(t, b.Upload(ctx, "obj", bytes.NewReader(plaintext)))
+
+	// The underlying object must not contain the plaintext.
+	rc, err := inner.Get(ctx, "obj")
+	require.NoError(t, err)
+	raw, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	require.True(t, IsEncrypted(raw))
+	require.False(t, bytes.Contains(raw, plaintext[:64]))
+
+	attrs, err := b.Attributes(ctx, "obj")
+	require.NoError(t, err)
+	require.Equal(t, int64(len(plaintext)), attrs.Size)
+
+	// The key id is read without a key to decrypt the object.
+	id, err := NewBucket(inner, newTestKeyProvider(t, "key2")).KeyID(ctx, "obj")
+	require.NoError(t, err)
+	require.Equal(t, "key1", id)
+
+	rc, err = b.Get(ctx, "obj")
+	require.NoError(t, err)
+	got, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, plaintext, got)
+
+	rc, err = b.GetRange(ctx, "obj", DefaultChunkSize-10, 20)
+	require.NoError(t, err)
+	got, err = io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, plaintext[DefaultChunkSize-10:DefaultChunkSize+10], got)
+
+	ra, err := b.GetReaderAt(ctx, "obj")
+	require.NoError(t, err)
+	p := make([]byte, 100)
+	_, err = ra.ReadAt(p, 3*DefaultChunkSize)
+	require.NoError(t, err)
+	require.Equal(t, plaintext[3*DefaultChunkSize:], p)
+
+	// Objects can't be swapped in the underlying bucket.
+	require.NoError(t, b.Upload(ctx, "other", bytes.NewReader(plaintext)))
+	require.NoError(t, inner.Upload(ctx, "other", bytes.NewReader(raw)))
+	rc, err = b.Get(ctx, "other")
+	require.NoError(t, err)
+	_, err = io.ReadAll(rc)
+	require.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package frostdb
+
+import (
+	"github.com/oklog/ulid"
+
+	"github.com/polarsignals/frostdb/index"
+	"github.com/polarsignals/frostdb/parts"
+)
+
+// PartEventType is the type of a PartEvent.
+type PartEventType int
+
+const (
+	// PartCreated is emitted when an insert added a part to the index of
+	// the active block of a table.
+	PartCreated PartEventType = iota
+	// PartCompacted is emitted when the parts of a level of the index of a
+	// block were compacted into parts of the next level.
+	PartCompacted
+	// PartPersisted is emitted when a block was uploaded to the storage.
+	PartPersisted
+	// PartEvicted is emitted when the parts of a rotated block were dropped
+	// from memory, whether the block was persisted or not.
+	PartEvicted
+)
+
+func (t PartEventType) String() string {
+	switch t {
+	case PartCreated:
+		return "created"
+	case PartCompacted:
+		return "compacted"
+	case PartPersisted:
+		return "persisted"
+	case PartEvicted:
+		return "evicted"
+	default:
+		return "unknown"
+	}
+}
+
+// PartEvent describes an event in the lifecycle of the parts of a table.
+type PartEvent struct {
+	Type     PartEventType
+	Database string
+	Table    string
+	// Block is the ID of the block of the parts.
+	Block ulid.ULID
+	// Tx is the transaction that created the part, for PartCreated events.
+	Tx uint64
+	// Level is the level of the index the parts were created or compacted
+	// into, for PartCreated and PartCompacted events.
+	Level int
+	// Compacted is the number of parts that were compacted, for
+	// PartCompacted events.
+	Compacted int
+	// Parts is the number of parts the event is about, that is the parts
+	// created, compacted into or evicted. It is 0 for PartPersisted events,
+	// since a persisted block is a single file.
+	Parts int
+	// Rows is the number of rows of the parts, 0 if unknown.
+	Rows int64
+	// Size is the size of the parts in bytes, or the size of the file of a
+	// persisted block.
+	Size int64
+}
+
+// WithPartEventCallback registers a callback that is called on every event in
+// the lifecycle of the parts of the tables, e.g. to catalog persisted blocks,
+// trigger replication or warm caches. Callbacks are called synchronously by
+// the goroutine causing the event, so they must return quickly and must not
+// write to the column store.
+func WithPartEventCallback(callback func(PartEvent)) Option {
+	return func(s *ColumnStore) error {
+		s.partEventCallbacks = append(s.partEventCallbacks, callback)
+		return nil
+	}
+}
+
+// emitPartEvent passes the event of the parts of the table to the part event
+// callbacks of the column store.
+func (t *Table) emitPartEvent(event PartEvent) {
+	callbacks := t.db.columnStore.partEventCallbacks
+	if len(callbacks) == 0 {
+		return
+	}
+	event.Database = t.db.name
+	event.Table = t.name
+	for _, callback := range callbacks {
+		callback(event)
+	}
+}
+
+// partCompactionCallback returns the compaction callback of the index of the
+// block that emits PartCompacted events.
+func (t *TableBlock) partCompactionCallback() func(index.SentinelType, int, []parts.Part) {
+	return func(level index.SentinelType, compacted int, ps []parts.Part) {
+		event := PartEvent{
+			Type:      PartCompacted,
+			Block:     t.ulid,
+			Level:     int(level),
+			Compacted: compacted,
+			Parts:     len(ps),
+		}
+		for _, p := range ps {
+			event.Rows += p.NumRows()
+			event.Size += p.Size()
+		}
+		t.table.emitPartEvent(event)
+	}
+}
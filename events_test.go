@@ -0,0 +1,77 @@
+package frostdb
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+)
+
+func TestPartEvents(t *testing.T) {
+	ctx := context.Background()
+	var (
+		mtx    sync.Mutex
+		events []PartEvent
+	)
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithReadWriteStorage(NewDefaultObjstoreBucket(objstore.NewInMemBucket())),
+		WithManualBlockRotation(),
+		WithPartEventCallback(func(e PartEvent) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			events = append(events, e)
+		}),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	block := table.ActiveBlock().ulid
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	var writeTx uint64
+	for i := 0; i < 2; i++ {
+		writeTx, err = table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+	}
+	require.NoError(t, table.EnsureCompaction())
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	// Writing the block is asynchronous, so wait for both the new table block
+	// txn and the block persistence txn.
+	db.Wait(writeTx + 2)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	types := make([]PartEventType, 0, len(events))
+	for _, e := range events {
+		require.Equal(t, "test", e.Database)
+		require.Equal(t, "test", e.Table)
+		require.Equal(t, block, e.Block)
+		types = append(types, e.Type)
+	}
+	// The compaction cascades through the levels of the index.
+	require.Equal(t, []PartEventType{PartCreated, PartCreated, PartCompacted, PartCompacted, PartPersisted, PartEvicted}, types)
+
+	rows := r.NumRows()
+	require.Equal(t, 1, events[0].Parts)
+	require.Equal(t, rows, events[0].Rows)
+	require.NotZero(t, events[0].Tx)
+	require.Equal(t, 0, events[0].Level)
+	require.Equal(t, 1, events[2].Level)
+	require.Equal(t, 2, events[2].Compacted)
+	require.Equal(t, 2*rows, events[2].Rows)
+	require.Equal(t, 2, events[3].Level)
+	require.Equal(t, 2*rows, events[3].Rows)
+	require.NotZero(t, events[4].Size)
+	require.Equal(t, 2*rows, events[5].Rows)
+}
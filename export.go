@@ -0,0 +1,153 @@
+package frostdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/pqarrow"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+	"github.com/polarsignals/frostdb/query/physicalplan"
+)
+
+// hiveDefaultPartition is the partition of rows whose partition column is
+// null, as named by Hive.
+const hiveDefaultPartition = "__HIVE_DEFAULT_PARTITION__"
+
+type exportOptions struct {
+	unit   time.Duration
+	filter logicalplan.Expr
+}
+
+type ExportOption func(*exportOptions)
+
+// ExportWithTimestampUnit sets the unit of the values of the timestamp column
+// of an export. The default is milliseconds.
+func ExportWithTimestampUnit(unit time.Duration) ExportOption {
+	return func(o *exportOptions) {
+		o.unit = unit
+	}
+}
+
+// ExportWithFilter only exports the rows matching the given filter.
+func ExportWithFilter(filter logicalplan.Expr) ExportOption {
+	return func(o *exportOptions) {
+		o.filter = filter
+	}
+}
+
+// Export writes the rows of the table to the given sink in a hive-partitioned
+// layout, so that engines like DuckDB or DataFusion can query them as an
+// external table without a catalog integration. The rows are partitioned by
+// the UTC date of the given int64 timestamp column, and the rows of every date
+// are written to prefix/date=YYYY-MM-DD/<table>.parquet. Rows with a null
+// timestamp are written to the __HIVE_DEFAULT_PARTITION__ partition.
+//
+// All files of an export have the same schema, which holds the dynamic columns
+// of all exported rows, so the files can be read as one table. Exporting again
+// replaces the files of the exported dates, files of dates without rows are
+// not deleted. The files are buffered in memory until all rows are read.
+func (t *Table) Export(ctx context.Context, sink DataSink, prefix, timestampColumn string, options ...ExportOption) error {
+	opts := exportOptions{unit: time.Millisecond}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	var (
+		// The records have the same schema, so the dynamic columns of the
+		// first record are the dynamic columns of all files.
+		dynamicColumns map[string][]string
+		partitions     = map[string]*exportPartition{}
+	)
+	write := func(_ context.Context, r arrow.Record) error {
+		indices := r.Schema().FieldIndices(timestampColumn)
+		if len(indices) == 0 {
+			return fmt.Errorf("export: no timestamp column %q", timestampColumn)
+		}
+		timestamps, ok := r.Column(indices[0]).(*array.Int64)
+		if !ok {
+			return fmt.Errorf("export: timestamp column %q is of type %s, expected int64", timestampColumn, r.Column(indices[0]).DataType())
+		}
+		if dynamicColumns == nil {
+			dynamicColumns = pqarrow.RecordDynamicCols(r)
+		}
+
+		for i := 0; i < int(r.NumRows()); i++ {
+			date := hiveDefaultPartition
+			if timestamps.IsValid(i) {
+				date = time.Unix(0, timestamps.Value(i)*int64(opts.unit)).UTC().Format(time.DateOnly)
+			}
+			p, ok := partitions[date]
+			if !ok {
+				p = &exportPartition{}
+				w, err := t.schema.NewWriter(&p.buf, dynamicColumns, false)
+				if err != nil {
+					return err
+				}
+				p.w = w
+				partitions[date] = p
+			}
+			row, err := pqarrow.RecordToRow(t.schema, p.w.Schema(), r, i)
+			if err != nil {
+				return err
+			}
+			if _, err := p.w.WriteRows([]parquet.Row{row}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	callback := write
+	iterOptions := []logicalplan.Option{logicalplan.WithUnifiedSchema()}
+	if opts.filter != nil {
+		// The filter of the scan only prunes row groups, the rows are
+		// filtered before they are written.
+		iterOptions = append(iterOptions, logicalplan.WithFilter(opts.filter))
+		filter, err := physicalplan.Filter(memory.DefaultAllocator, t.tracer, opts.filter)
+		if err != nil {
+			return err
+		}
+		output := physicalplan.OutputPlan{}
+		output.SetNextCallback(write)
+		filter.SetNext(&output)
+		callback = filter.Callback
+	}
+	if err := t.View(ctx, func(ctx context.Context, tx uint64) error {
+		return t.Iterator(ctx, tx, memory.DefaultAllocator, []logicalplan.Callback{callback}, iterOptions...)
+	}); err != nil {
+		return err
+	}
+
+	dates := make([]string, 0, len(partitions))
+	for date := range partitions {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	for _, date := range dates {
+		p := partitions[date]
+		if err := p.w.Close(); err != nil {
+			return err
+		}
+		name := filepath.Join(prefix, "date="+date, t.name+".parquet")
+		if err := sink.Upload(ctx, name, bytes.NewReader(p.buf.Bytes())); err != nil {
+			return fmt.Errorf("export: upload %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// exportPartition is the file of a partition of an export.
+type exportPartition struct {
+	buf bytes.Buffer
+	w   dynparquet.ParquetWriter
+}
@@ -0,0 +1,81 @@
+package frostdb
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+func TestTableExport(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	day := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	insert := func(labels map[string]string, ts time.Time) {
+		r, err := dynparquet.Samples{{
+			ExampleType: "cpu",
+			Labels:      labels,
+			Timestamp:   ts.UnixMilli(),
+			Value:       1,
+		}}.ToRecord()
+		require.NoError(t, err)
+		defer r.Release()
+		_, err = table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+	}
+	// The days have different dynamic columns.
+	insert(map[string]string{"node": "a"}, day)
+	insert(map[string]string{"node": "b"}, day.Add(time.Hour))
+	insert(map[string]string{"pod": "c"}, day.Add(24*time.Hour))
+
+	bucket := objstore.NewInMemBucket()
+	require.NoError(t, table.Export(ctx, NewDefaultObjstoreBucket(bucket), "export", "timestamp"))
+
+	read := func(name string) *parquet.File {
+		rc, err := bucket.Get(ctx, name)
+		require.NoError(t, err)
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		f, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		return f
+	}
+	first := read(filepath.Join("export", "date=2024-01-02", "test.parquet"))
+	second := read(filepath.Join("export", "date=2024-01-03", "test.parquet"))
+	require.Equal(t, int64(2), first.NumRows())
+	require.Equal(t, int64(1), second.NumRows())
+	require.Equal(t, first.Schema().String(), second.Schema().String())
+	for _, name := range []string{"labels.node", "labels.pod"} {
+		_, ok := first.Schema().Lookup(name)
+		require.True(t, ok, name)
+	}
+
+	// Exporting with a filter only writes the matching dates.
+	filtered := objstore.NewInMemBucket()
+	require.NoError(t, table.Export(ctx, NewDefaultObjstoreBucket(filtered), "export", "timestamp",
+		ExportWithFilter(logicalplan.Col("labels.pod").Eq(logicalplan.Literal("c"))),
+	))
+	var names []string
+	require.NoError(t, filtered.Iter(ctx, "", func(name string) error {
+		names = append(names, name)
+		return nil
+	}, objstore.WithRecursiveIter))
+	require.Equal(t, []string{"export/date=2024-01-03/test.parquet"}, names)
+}
@@ -0,0 +1,2314 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.30.0
+// 	protoc        (unknown)
+// source: frostdb/logicalplan/v1alpha1/logicalplan.proto
+
+package logicalplanv1alpha1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Op is the operator of a binary expression.
+type Op int32
+
+const (
+	// OP_UNSPECIFIED is the default value. It is not a valid operator.
+	Op_OP_UNSPECIFIED Op = 0
+	// OP_EQ is the equality operator.
+	Op_OP_EQ Op = 1
+	// OP_NOT_EQ is the inequality operator.
+	Op_OP_NOT_EQ Op = 2
+	// OP_LT is the less than operator.
+	Op_OP_LT Op = 3
+	// OP_LT_EQ is the less than or equal operator.
+	Op_OP_LT_EQ Op = 4
+	// OP_GT is the greater than operator.
+	Op_OP_GT Op = 5
+	// OP_GT_EQ is the greater than or equal operator.
+	Op_OP_GT_EQ Op = 6
+	// OP_REGEX_MATCH is the regexp match operator.
+	Op_OP_REGEX_MATCH Op = 7
+	// OP_REGEX_NOT_MATCH is the regexp not match operator.
+	Op_OP_REGEX_NOT_MATCH Op = 8
+	// OP_AND is the logical and operator.
+	Op_OP_AND Op = 9
+	// OP_OR is the logical or operator.
+	Op_OP_OR Op = 10
+	// OP_ADD is the addition operator.
+	Op_OP_ADD Op = 11
+	// OP_SUB is the subtraction operator.
+	Op_OP_SUB Op = 12
+)
+
+// Enum value maps for Op.
+var (
+	Op_name = map[int32]string{
+		0:  "OP_UNSPECIFIED",
+		1:  "OP_EQ",
+		2:  "OP_NOT_EQ",
+		3:  "OP_LT",
+		4:  "OP_LT_EQ",
+		5:  "OP_GT",
+		6:  "OP_GT_EQ",
+		7:  "OP_REGEX_MATCH",
+		8:  "OP_REGEX_NOT_MATCH",
+		9:  "OP_AND",
+		10: "OP_OR",
+		11: "OP_ADD",
+		12: "OP_SUB",
+	}
+	Op_value = map[string]int32{
+		"OP_UNSPECIFIED":     0,
+		"OP_EQ":              1,
+		"OP_NOT_EQ":          2,
+		"OP_LT":              3,
+		"OP_LT_EQ":           4,
+		"OP_GT":              5,
+		"OP_GT_EQ":           6,
+		"OP_REGEX_MATCH":     7,
+		"OP_REGEX_NOT_MATCH": 8,
+		"OP_AND":             9,
+		"OP_OR":              10,
+		"OP_ADD":             11,
+		"OP_SUB":             12,
+	}
+)
+
+func (x Op) Enum() *Op {
+	p := new(Op)
+	*p = x
+	return p
+}
+
+func (x Op) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Op) Descriptor() protoreflect.EnumDescriptor {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_enumTypes[0].Descriptor()
+}
+
+func (Op) Type() protoreflect.EnumType {
+	return &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_enumTypes[0]
+}
+
+func (x Op) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Op.Descriptor instead.
+func (Op) EnumDescriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{0}
+}
+
+// AggFunc is an aggregation function.
+type AggFunc int32
+
+const (
+	// AGG_FUNC_UNSPECIFIED is the default value. It is not a valid function.
+	AggFunc_AGG_FUNC_UNSPECIFIED AggFunc = 0
+	// AGG_FUNC_SUM is the sum function.
+	AggFunc_AGG_FUNC_SUM AggFunc = 1
+	// AGG_FUNC_MIN is the min function.
+	AggFunc_AGG_FUNC_MIN AggFunc = 2
+	// AGG_FUNC_MAX is the max function.
+	AggFunc_AGG_FUNC_MAX AggFunc = 3
+	// AGG_FUNC_COUNT is the count function.
+	AggFunc_AGG_FUNC_COUNT AggFunc = 4
+	// AGG_FUNC_AVG is the average function.
+	AggFunc_AGG_FUNC_AVG AggFunc = 5
+	// AGG_FUNC_HISTOGRAM is the histogram function.
+	AggFunc_AGG_FUNC_HISTOGRAM AggFunc = 6
+	// AGG_FUNC_FIRST is the first function.
+	AggFunc_AGG_FUNC_FIRST AggFunc = 7
+	// AGG_FUNC_LAST is the last function.
+	AggFunc_AGG_FUNC_LAST AggFunc = 8
+	// AGG_FUNC_STDDEV is the sample standard deviation function.
+	AggFunc_AGG_FUNC_STDDEV AggFunc = 9
+	// AGG_FUNC_VARIANCE is the sample variance function.
+	AggFunc_AGG_FUNC_VARIANCE AggFunc = 10
+	// AGG_FUNC_ANY_VALUE is the any value function.
+	AggFunc_AGG_FUNC_ANY_VALUE AggFunc = 11
+)
+
+// Enum value maps for AggFunc.
+var (
+	AggFunc_name = map[int32]string{
+		0:  "AGG_FUNC_UNSPECIFIED",
+		1:  "AGG_FUNC_SUM",
+		2:  "AGG_FUNC_MIN",
+		3:  "AGG_FUNC_MAX",
+		4:  "AGG_FUNC_COUNT",
+		5:  "AGG_FUNC_AVG",
+		6:  "AGG_FUNC_HISTOGRAM",
+		7:  "AGG_FUNC_FIRST",
+		8:  "AGG_FUNC_LAST",
+		9:  "AGG_FUNC_STDDEV",
+		10: "AGG_FUNC_VARIANCE",
+		11: "AGG_FUNC_ANY_VALUE",
+	}
+	AggFunc_value = map[string]int32{
+		"AGG_FUNC_UNSPECIFIED": 0,
+		"AGG_FUNC_SUM":         1,
+		"AGG_FUNC_MIN":         2,
+		"AGG_FUNC_MAX":         3,
+		"AGG_FUNC_COUNT":       4,
+		"AGG_FUNC_AVG":         5,
+		"AGG_FUNC_HISTOGRAM":   6,
+		"AGG_FUNC_FIRST":       7,
+		"AGG_FUNC_LAST":        8,
+		"AGG_FUNC_STDDEV":      9,
+		"AGG_FUNC_VARIANCE":    10,
+		"AGG_FUNC_ANY_VALUE":   11,
+	}
+)
+
+func (x AggFunc) Enum() *AggFunc {
+	p := new(AggFunc)
+	*p = x
+	return p
+}
+
+func (x AggFunc) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AggFunc) Descriptor() protoreflect.EnumDescriptor {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_enumTypes[1].Descriptor()
+}
+
+func (AggFunc) Type() protoreflect.EnumType {
+	return &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_enumTypes[1]
+}
+
+func (x AggFunc) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AggFunc.Descriptor instead.
+func (AggFunc) EnumDescriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{1}
+}
+
+// Plan is a node of a logical query plan. Every node has exactly one operator
+// and, unless the operator is a scan, an input.
+type Plan struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Input of the node.
+	Input *Plan `protobuf:"bytes,1,opt,name=input,proto3" json:"input,omitempty"`
+	// Operator of the node.
+	//
+	// Types that are assignable to Operator:
+	//
+	//	*Plan_TableScan
+	//	*Plan_SchemaScan
+	//	*Plan_Filter
+	//	*Plan_Distinct
+	//	*Plan_Projection
+	//	*Plan_Aggregation
+	Operator isPlan_Operator `protobuf_oneof:"operator"`
+}
+
+func (x *Plan) Reset() {
+	*x = Plan{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Plan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Plan) ProtoMessage() {}
+
+func (x *Plan) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Plan.ProtoReflect.Descriptor instead.
+func (*Plan) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Plan) GetInput() *Plan {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
+func (m *Plan) GetOperator() isPlan_Operator {
+	if m != nil {
+		return m.Operator
+	}
+	return nil
+}
+
+func (x *Plan) GetTableScan() *TableScan {
+	if x, ok := x.GetOperator().(*Plan_TableScan); ok {
+		return x.TableScan
+	}
+	return nil
+}
+
+func (x *Plan) GetSchemaScan() *SchemaScan {
+	if x, ok := x.GetOperator().(*Plan_SchemaScan); ok {
+		return x.SchemaScan
+	}
+	return nil
+}
+
+func (x *Plan) GetFilter() *Filter {
+	if x, ok := x.GetOperator().(*Plan_Filter); ok {
+		return x.Filter
+	}
+	return nil
+}
+
+func (x *Plan) GetDistinct() *Distinct {
+	if x, ok := x.GetOperator().(*Plan_Distinct); ok {
+		return x.Distinct
+	}
+	return nil
+}
+
+func (x *Plan) GetProjection() *Projection {
+	if x, ok := x.GetOperator().(*Plan_Projection); ok {
+		return x.Projection
+	}
+	return nil
+}
+
+func (x *Plan) GetAggregation() *Aggregation {
+	if x, ok := x.GetOperator().(*Plan_Aggregation); ok {
+		return x.Aggregation
+	}
+	return nil
+}
+
+type isPlan_Operator interface {
+	isPlan_Operator()
+}
+
+type Plan_TableScan struct {
+	// TableScan scans the data of a table.
+	TableScan *TableScan `protobuf:"bytes,2,opt,name=table_scan,json=tableScan,proto3,oneof"`
+}
+
+type Plan_SchemaScan struct {
+	// SchemaScan scans the schema of a table.
+	SchemaScan *SchemaScan `protobuf:"bytes,3,opt,name=schema_scan,json=schemaScan,proto3,oneof"`
+}
+
+type Plan_Filter struct {
+	// Filter filters the rows of the input.
+	Filter *Filter `protobuf:"bytes,4,opt,name=filter,proto3,oneof"`
+}
+
+type Plan_Distinct struct {
+	// Distinct returns the distinct rows of the input.
+	Distinct *Distinct `protobuf:"bytes,5,opt,name=distinct,proto3,oneof"`
+}
+
+type Plan_Projection struct {
+	// Projection projects the columns of the input.
+	Projection *Projection `protobuf:"bytes,6,opt,name=projection,proto3,oneof"`
+}
+
+type Plan_Aggregation struct {
+	// Aggregation aggregates the rows of the input.
+	Aggregation *Aggregation `protobuf:"bytes,7,opt,name=aggregation,proto3,oneof"`
+}
+
+func (*Plan_TableScan) isPlan_Operator() {}
+
+func (*Plan_SchemaScan) isPlan_Operator() {}
+
+func (*Plan_Filter) isPlan_Operator() {}
+
+func (*Plan_Distinct) isPlan_Operator() {}
+
+func (*Plan_Projection) isPlan_Operator() {}
+
+func (*Plan_Aggregation) isPlan_Operator() {}
+
+// TableScan scans the data of a table.
+type TableScan struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Name of the table to scan.
+	TableName string `protobuf:"bytes,1,opt,name=table_name,json=tableName,proto3" json:"table_name,omitempty"`
+	// PhysicalProjection describes the columns that are physically read.
+	PhysicalProjection []*Expr `protobuf:"bytes,2,rep,name=physical_projection,json=physicalProjection,proto3" json:"physical_projection,omitempty"`
+	// Filter is the predicate used to rule out blocks of data.
+	Filter *Expr `protobuf:"bytes,3,opt,name=filter,proto3" json:"filter,omitempty"`
+	// Distinct describes the columns that are to be distinct.
+	Distinct []*Expr `protobuf:"bytes,4,rep,name=distinct,proto3" json:"distinct,omitempty"`
+	// Projection is the list of columns that are projected.
+	Projection []*Expr `protobuf:"bytes,5,rep,name=projection,proto3" json:"projection,omitempty"`
+	// SkipSources indicates to skip scanning the sources of the table.
+	SkipSources bool `protobuf:"varint,6,opt,name=skip_sources,json=skipSources,proto3" json:"skip_sources,omitempty"`
+	// AsOfTx is the transaction the table is read as of. If zero, the latest
+	// committed transaction is read.
+	AsOfTx uint64 `protobuf:"varint,7,opt,name=as_of_tx,json=asOfTx,proto3" json:"as_of_tx,omitempty"`
+}
+
+func (x *TableScan) Reset() {
+	*x = TableScan{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TableScan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TableScan) ProtoMessage() {}
+
+func (x *TableScan) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TableScan.ProtoReflect.Descriptor instead.
+func (*TableScan) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TableScan) GetTableName() string {
+	if x != nil {
+		return x.TableName
+	}
+	return ""
+}
+
+func (x *TableScan) GetPhysicalProjection() []*Expr {
+	if x != nil {
+		return x.PhysicalProjection
+	}
+	return nil
+}
+
+func (x *TableScan) GetFilter() *Expr {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+func (x *TableScan) GetDistinct() []*Expr {
+	if x != nil {
+		return x.Distinct
+	}
+	return nil
+}
+
+func (x *TableScan) GetProjection() []*Expr {
+	if x != nil {
+		return x.Projection
+	}
+	return nil
+}
+
+func (x *TableScan) GetSkipSources() bool {
+	if x != nil {
+		return x.SkipSources
+	}
+	return false
+}
+
+func (x *TableScan) GetAsOfTx() uint64 {
+	if x != nil {
+		return x.AsOfTx
+	}
+	return 0
+}
+
+// SchemaScan scans the schema of a table.
+type SchemaScan struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Name of the table to scan.
+	TableName string `protobuf:"bytes,1,opt,name=table_name,json=tableName,proto3" json:"table_name,omitempty"`
+	// PhysicalProjection describes the columns that are physically read.
+	PhysicalProjection []*Expr `protobuf:"bytes,2,rep,name=physical_projection,json=physicalProjection,proto3" json:"physical_projection,omitempty"`
+	// Filter is the predicate used to rule out blocks of data.
+	Filter *Expr `protobuf:"bytes,3,opt,name=filter,proto3" json:"filter,omitempty"`
+	// Distinct describes the columns that are to be distinct.
+	Distinct []*Expr `protobuf:"bytes,4,rep,name=distinct,proto3" json:"distinct,omitempty"`
+	// Projection is the list of columns that are projected.
+	Projection []*Expr `protobuf:"bytes,5,rep,name=projection,proto3" json:"projection,omitempty"`
+	// SkipSources indicates to skip scanning the sources of the table.
+	SkipSources bool `protobuf:"varint,6,opt,name=skip_sources,json=skipSources,proto3" json:"skip_sources,omitempty"`
+	// AsOfTx is the transaction the table is read as of. If zero, the latest
+	// committed transaction is read.
+	AsOfTx uint64 `protobuf:"varint,7,opt,name=as_of_tx,json=asOfTx,proto3" json:"as_of_tx,omitempty"`
+}
+
+func (x *SchemaScan) Reset() {
+	*x = SchemaScan{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SchemaScan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SchemaScan) ProtoMessage() {}
+
+func (x *SchemaScan) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SchemaScan.ProtoReflect.Descriptor instead.
+func (*SchemaScan) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SchemaScan) GetTableName() string {
+	if x != nil {
+		return x.TableName
+	}
+	return ""
+}
+
+func (x *SchemaScan) GetPhysicalProjection() []*Expr {
+	if x != nil {
+		return x.PhysicalProjection
+	}
+	return nil
+}
+
+func (x *SchemaScan) GetFilter() *Expr {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+func (x *SchemaScan) GetDistinct() []*Expr {
+	if x != nil {
+		return x.Distinct
+	}
+	return nil
+}
+
+func (x *SchemaScan) GetProjection() []*Expr {
+	if x != nil {
+		return x.Projection
+	}
+	return nil
+}
+
+func (x *SchemaScan) GetSkipSources() bool {
+	if x != nil {
+		return x.SkipSources
+	}
+	return false
+}
+
+func (x *SchemaScan) GetAsOfTx() uint64 {
+	if x != nil {
+		return x.AsOfTx
+	}
+	return 0
+}
+
+// Filter filters the rows of its input.
+type Filter struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Expr is the predicate rows need to match.
+	Expr *Expr `protobuf:"bytes,1,opt,name=expr,proto3" json:"expr,omitempty"`
+}
+
+func (x *Filter) Reset() {
+	*x = Filter{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Filter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Filter) ProtoMessage() {}
+
+func (x *Filter) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Filter.ProtoReflect.Descriptor instead.
+func (*Filter) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Filter) GetExpr() *Expr {
+	if x != nil {
+		return x.Expr
+	}
+	return nil
+}
+
+// Distinct returns the distinct rows of its input.
+type Distinct struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Exprs are the expressions whose values are distinct.
+	Exprs []*Expr `protobuf:"bytes,1,rep,name=exprs,proto3" json:"exprs,omitempty"`
+}
+
+func (x *Distinct) Reset() {
+	*x = Distinct{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Distinct) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Distinct) ProtoMessage() {}
+
+func (x *Distinct) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Distinct.ProtoReflect.Descriptor instead.
+func (*Distinct) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Distinct) GetExprs() []*Expr {
+	if x != nil {
+		return x.Exprs
+	}
+	return nil
+}
+
+// Projection projects the columns of its input.
+type Projection struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Exprs are the projected expressions.
+	Exprs []*Expr `protobuf:"bytes,1,rep,name=exprs,proto3" json:"exprs,omitempty"`
+}
+
+func (x *Projection) Reset() {
+	*x = Projection{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Projection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Projection) ProtoMessage() {}
+
+func (x *Projection) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Projection.ProtoReflect.Descriptor instead.
+func (*Projection) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Projection) GetExprs() []*Expr {
+	if x != nil {
+		return x.Exprs
+	}
+	return nil
+}
+
+// Aggregation aggregates the rows of its input.
+type Aggregation struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// AggExprs are the aggregation functions.
+	AggExprs []*Expr `protobuf:"bytes,1,rep,name=agg_exprs,json=aggExprs,proto3" json:"agg_exprs,omitempty"`
+	// GroupExprs are the expressions the rows are grouped by.
+	GroupExprs []*Expr `protobuf:"bytes,2,rep,name=group_exprs,json=groupExprs,proto3" json:"group_exprs,omitempty"`
+}
+
+func (x *Aggregation) Reset() {
+	*x = Aggregation{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Aggregation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Aggregation) ProtoMessage() {}
+
+func (x *Aggregation) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Aggregation.ProtoReflect.Descriptor instead.
+func (*Aggregation) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Aggregation) GetAggExprs() []*Expr {
+	if x != nil {
+		return x.AggExprs
+	}
+	return nil
+}
+
+func (x *Aggregation) GetGroupExprs() []*Expr {
+	if x != nil {
+		return x.GroupExprs
+	}
+	return nil
+}
+
+// Expr is an expression of a plan.
+type Expr struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The expression.
+	//
+	// Types that are assignable to Expr:
+	//
+	//	*Expr_Column
+	//	*Expr_DynamicColumn
+	//	*Expr_Binary
+	//	*Expr_Literal
+	//	*Expr_Alias
+	//	*Expr_AggregationFunction
+	//	*Expr_Duration
+	//	*Expr_Average
+	//	*Expr_RegexpColumnMatch
+	//	*Expr_All
+	//	*Expr_Not
+	Expr isExpr_Expr `protobuf_oneof:"expr"`
+}
+
+func (x *Expr) Reset() {
+	*x = Expr{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Expr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Expr) ProtoMessage() {}
+
+func (x *Expr) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Expr.ProtoReflect.Descriptor instead.
+func (*Expr) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{7}
+}
+
+func (m *Expr) GetExpr() isExpr_Expr {
+	if m != nil {
+		return m.Expr
+	}
+	return nil
+}
+
+func (x *Expr) GetColumn() *Column {
+	if x, ok := x.GetExpr().(*Expr_Column); ok {
+		return x.Column
+	}
+	return nil
+}
+
+func (x *Expr) GetDynamicColumn() *DynamicColumn {
+	if x, ok := x.GetExpr().(*Expr_DynamicColumn); ok {
+		return x.DynamicColumn
+	}
+	return nil
+}
+
+func (x *Expr) GetBinary() *BinaryExpr {
+	if x, ok := x.GetExpr().(*Expr_Binary); ok {
+		return x.Binary
+	}
+	return nil
+}
+
+func (x *Expr) GetLiteral() *Literal {
+	if x, ok := x.GetExpr().(*Expr_Literal); ok {
+		return x.Literal
+	}
+	return nil
+}
+
+func (x *Expr) GetAlias() *AliasExpr {
+	if x, ok := x.GetExpr().(*Expr_Alias); ok {
+		return x.Alias
+	}
+	return nil
+}
+
+func (x *Expr) GetAggregationFunction() *AggregationFunction {
+	if x, ok := x.GetExpr().(*Expr_AggregationFunction); ok {
+		return x.AggregationFunction
+	}
+	return nil
+}
+
+func (x *Expr) GetDuration() *DurationExpr {
+	if x, ok := x.GetExpr().(*Expr_Duration); ok {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *Expr) GetAverage() *AverageExpr {
+	if x, ok := x.GetExpr().(*Expr_Average); ok {
+		return x.Average
+	}
+	return nil
+}
+
+func (x *Expr) GetRegexpColumnMatch() *RegexpColumnMatch {
+	if x, ok := x.GetExpr().(*Expr_RegexpColumnMatch); ok {
+		return x.RegexpColumnMatch
+	}
+	return nil
+}
+
+func (x *Expr) GetAll() *AllExpr {
+	if x, ok := x.GetExpr().(*Expr_All); ok {
+		return x.All
+	}
+	return nil
+}
+
+func (x *Expr) GetNot() *NotExpr {
+	if x, ok := x.GetExpr().(*Expr_Not); ok {
+		return x.Not
+	}
+	return nil
+}
+
+type isExpr_Expr interface {
+	isExpr_Expr()
+}
+
+type Expr_Column struct {
+	// Column references a column.
+	Column *Column `protobuf:"bytes,1,opt,name=column,proto3,oneof"`
+}
+
+type Expr_DynamicColumn struct {
+	// DynamicColumn references all columns of a dynamic column.
+	DynamicColumn *DynamicColumn `protobuf:"bytes,2,opt,name=dynamic_column,json=dynamicColumn,proto3,oneof"`
+}
+
+type Expr_Binary struct {
+	// BinaryExpr is a binary expression.
+	Binary *BinaryExpr `protobuf:"bytes,3,opt,name=binary,proto3,oneof"`
+}
+
+type Expr_Literal struct {
+	// Literal is a literal value.
+	Literal *Literal `protobuf:"bytes,4,opt,name=literal,proto3,oneof"`
+}
+
+type Expr_Alias struct {
+	// AliasExpr names an expression.
+	Alias *AliasExpr `protobuf:"bytes,5,opt,name=alias,proto3,oneof"`
+}
+
+type Expr_AggregationFunction struct {
+	// AggregationFunction is an aggregation of an expression.
+	AggregationFunction *AggregationFunction `protobuf:"bytes,6,opt,name=aggregation_function,json=aggregationFunction,proto3,oneof"`
+}
+
+type Expr_Duration struct {
+	// DurationExpr is a time bucket of a duration.
+	Duration *DurationExpr `protobuf:"bytes,7,opt,name=duration,proto3,oneof"`
+}
+
+type Expr_Average struct {
+	// AverageExpr is the average of an expression.
+	Average *AverageExpr `protobuf:"bytes,8,opt,name=average,proto3,oneof"`
+}
+
+type Expr_RegexpColumnMatch struct {
+	// RegexpColumnMatch references the columns whose name matches a regexp.
+	RegexpColumnMatch *RegexpColumnMatch `protobuf:"bytes,9,opt,name=regexp_column_match,json=regexpColumnMatch,proto3,oneof"`
+}
+
+type Expr_All struct {
+	// AllExpr references all columns.
+	All *AllExpr `protobuf:"bytes,10,opt,name=all,proto3,oneof"`
+}
+
+type Expr_Not struct {
+	// NotExpr references all columns but the ones of an expression.
+	Not *NotExpr `protobuf:"bytes,11,opt,name=not,proto3,oneof"`
+}
+
+func (*Expr_Column) isExpr_Expr() {}
+
+func (*Expr_DynamicColumn) isExpr_Expr() {}
+
+func (*Expr_Binary) isExpr_Expr() {}
+
+func (*Expr_Literal) isExpr_Expr() {}
+
+func (*Expr_Alias) isExpr_Expr() {}
+
+func (*Expr_AggregationFunction) isExpr_Expr() {}
+
+func (*Expr_Duration) isExpr_Expr() {}
+
+func (*Expr_Average) isExpr_Expr() {}
+
+func (*Expr_RegexpColumnMatch) isExpr_Expr() {}
+
+func (*Expr_All) isExpr_Expr() {}
+
+func (*Expr_Not) isExpr_Expr() {}
+
+// Column references a column.
+type Column struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Name of the column.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+func (x *Column) Reset() {
+	*x = Column{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Column) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Column) ProtoMessage() {}
+
+func (x *Column) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Column.ProtoReflect.Descriptor instead.
+func (*Column) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Column) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// DynamicColumn references all columns of a dynamic column.
+type DynamicColumn struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Name of the dynamic column.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+func (x *DynamicColumn) Reset() {
+	*x = DynamicColumn{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DynamicColumn) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DynamicColumn) ProtoMessage() {}
+
+func (x *DynamicColumn) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DynamicColumn.ProtoReflect.Descriptor instead.
+func (*DynamicColumn) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DynamicColumn) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// BinaryExpr is a binary expression.
+type BinaryExpr struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Left operand.
+	Left *Expr `protobuf:"bytes,1,opt,name=left,proto3" json:"left,omitempty"`
+	// Operator of the expression.
+	Op Op `protobuf:"varint,2,opt,name=op,proto3,enum=frostdb.logicalplan.v1alpha1.Op" json:"op,omitempty"`
+	// Right operand.
+	Right *Expr `protobuf:"bytes,3,opt,name=right,proto3" json:"right,omitempty"`
+}
+
+func (x *BinaryExpr) Reset() {
+	*x = BinaryExpr{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BinaryExpr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BinaryExpr) ProtoMessage() {}
+
+func (x *BinaryExpr) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BinaryExpr.ProtoReflect.Descriptor instead.
+func (*BinaryExpr) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *BinaryExpr) GetLeft() *Expr {
+	if x != nil {
+		return x.Left
+	}
+	return nil
+}
+
+func (x *BinaryExpr) GetOp() Op {
+	if x != nil {
+		return x.Op
+	}
+	return Op_OP_UNSPECIFIED
+}
+
+func (x *BinaryExpr) GetRight() *Expr {
+	if x != nil {
+		return x.Right
+	}
+	return nil
+}
+
+// Literal is a literal value. A literal without a value is null.
+type Literal struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The value of the literal.
+	//
+	// Types that are assignable to Value:
+	//
+	//	*Literal_BoolValue
+	//	*Literal_Int64Value
+	//	*Literal_Float64Value
+	//	*Literal_StringValue
+	//	*Literal_BinaryValue
+	Value isLiteral_Value `protobuf_oneof:"value"`
+}
+
+func (x *Literal) Reset() {
+	*x = Literal{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Literal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Literal) ProtoMessage() {}
+
+func (x *Literal) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Literal.ProtoReflect.Descriptor instead.
+func (*Literal) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{11}
+}
+
+func (m *Literal) GetValue() isLiteral_Value {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (x *Literal) GetBoolValue() bool {
+	if x, ok := x.GetValue().(*Literal_BoolValue); ok {
+		return x.BoolValue
+	}
+	return false
+}
+
+func (x *Literal) GetInt64Value() int64 {
+	if x, ok := x.GetValue().(*Literal_Int64Value); ok {
+		return x.Int64Value
+	}
+	return 0
+}
+
+func (x *Literal) GetFloat64Value() float64 {
+	if x, ok := x.GetValue().(*Literal_Float64Value); ok {
+		return x.Float64Value
+	}
+	return 0
+}
+
+func (x *Literal) GetStringValue() string {
+	if x, ok := x.GetValue().(*Literal_StringValue); ok {
+		return x.StringValue
+	}
+	return ""
+}
+
+func (x *Literal) GetBinaryValue() []byte {
+	if x, ok := x.GetValue().(*Literal_BinaryValue); ok {
+		return x.BinaryValue
+	}
+	return nil
+}
+
+type isLiteral_Value interface {
+	isLiteral_Value()
+}
+
+type Literal_BoolValue struct {
+	// Bool value.
+	BoolValue bool `protobuf:"varint,1,opt,name=bool_value,json=boolValue,proto3,oneof"`
+}
+
+type Literal_Int64Value struct {
+	// Int64 value.
+	Int64Value int64 `protobuf:"varint,2,opt,name=int64_value,json=int64Value,proto3,oneof"`
+}
+
+type Literal_Float64Value struct {
+	// Float64 value.
+	Float64Value float64 `protobuf:"fixed64,3,opt,name=float64_value,json=float64Value,proto3,oneof"`
+}
+
+type Literal_StringValue struct {
+	// String value.
+	StringValue string `protobuf:"bytes,4,opt,name=string_value,json=stringValue,proto3,oneof"`
+}
+
+type Literal_BinaryValue struct {
+	// Binary value.
+	BinaryValue []byte `protobuf:"bytes,5,opt,name=binary_value,json=binaryValue,proto3,oneof"`
+}
+
+func (*Literal_BoolValue) isLiteral_Value() {}
+
+func (*Literal_Int64Value) isLiteral_Value() {}
+
+func (*Literal_Float64Value) isLiteral_Value() {}
+
+func (*Literal_StringValue) isLiteral_Value() {}
+
+func (*Literal_BinaryValue) isLiteral_Value() {}
+
+// AliasExpr names an expression.
+type AliasExpr struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The aliased expression.
+	Expr *Expr `protobuf:"bytes,1,opt,name=expr,proto3" json:"expr,omitempty"`
+	// The alias.
+	Alias string `protobuf:"bytes,2,opt,name=alias,proto3" json:"alias,omitempty"`
+}
+
+func (x *AliasExpr) Reset() {
+	*x = AliasExpr{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AliasExpr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AliasExpr) ProtoMessage() {}
+
+func (x *AliasExpr) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AliasExpr.ProtoReflect.Descriptor instead.
+func (*AliasExpr) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *AliasExpr) GetExpr() *Expr {
+	if x != nil {
+		return x.Expr
+	}
+	return nil
+}
+
+func (x *AliasExpr) GetAlias() string {
+	if x != nil {
+		return x.Alias
+	}
+	return ""
+}
+
+// AggregationFunction is an aggregation of an expression.
+type AggregationFunction struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The aggregation function.
+	Func AggFunc `protobuf:"varint,1,opt,name=func,proto3,enum=frostdb.logicalplan.v1alpha1.AggFunc" json:"func,omitempty"`
+	// The aggregated expression.
+	Expr *Expr `protobuf:"bytes,2,opt,name=expr,proto3" json:"expr,omitempty"`
+	// The upper bounds of the buckets of histogram aggregations.
+	Boundaries []float64 `protobuf:"fixed64,3,rep,packed,name=boundaries,proto3" json:"boundaries,omitempty"`
+	// The column first and last aggregations order the rows by.
+	OrderBy *Expr `protobuf:"bytes,4,opt,name=order_by,json=orderBy,proto3" json:"order_by,omitempty"`
+}
+
+func (x *AggregationFunction) Reset() {
+	*x = AggregationFunction{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AggregationFunction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregationFunction) ProtoMessage() {}
+
+func (x *AggregationFunction) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregationFunction.ProtoReflect.Descriptor instead.
+func (*AggregationFunction) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *AggregationFunction) GetFunc() AggFunc {
+	if x != nil {
+		return x.Func
+	}
+	return AggFunc_AGG_FUNC_UNSPECIFIED
+}
+
+func (x *AggregationFunction) GetExpr() *Expr {
+	if x != nil {
+		return x.Expr
+	}
+	return nil
+}
+
+func (x *AggregationFunction) GetBoundaries() []float64 {
+	if x != nil {
+		return x.Boundaries
+	}
+	return nil
+}
+
+func (x *AggregationFunction) GetOrderBy() *Expr {
+	if x != nil {
+		return x.OrderBy
+	}
+	return nil
+}
+
+// DurationExpr is a time bucket of a duration.
+type DurationExpr struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Duration in nanoseconds.
+	Duration int64 `protobuf:"varint,1,opt,name=duration,proto3" json:"duration,omitempty"`
+}
+
+func (x *DurationExpr) Reset() {
+	*x = DurationExpr{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DurationExpr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DurationExpr) ProtoMessage() {}
+
+func (x *DurationExpr) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DurationExpr.ProtoReflect.Descriptor instead.
+func (*DurationExpr) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *DurationExpr) GetDuration() int64 {
+	if x != nil {
+		return x.Duration
+	}
+	return 0
+}
+
+// AverageExpr is the average of an expression.
+type AverageExpr struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The averaged expression.
+	Expr *Expr `protobuf:"bytes,1,opt,name=expr,proto3" json:"expr,omitempty"`
+}
+
+func (x *AverageExpr) Reset() {
+	*x = AverageExpr{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AverageExpr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AverageExpr) ProtoMessage() {}
+
+func (x *AverageExpr) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AverageExpr.ProtoReflect.Descriptor instead.
+func (*AverageExpr) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *AverageExpr) GetExpr() *Expr {
+	if x != nil {
+		return x.Expr
+	}
+	return nil
+}
+
+// RegexpColumnMatch references the columns whose name matches a regexp.
+type RegexpColumnMatch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The regexp column names are matched against.
+	Pattern string `protobuf:"bytes,1,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	// Inverse references the columns whose name does not match instead.
+	Inverse bool `protobuf:"varint,2,opt,name=inverse,proto3" json:"inverse,omitempty"`
+}
+
+func (x *RegexpColumnMatch) Reset() {
+	*x = RegexpColumnMatch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RegexpColumnMatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegexpColumnMatch) ProtoMessage() {}
+
+func (x *RegexpColumnMatch) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegexpColumnMatch.ProtoReflect.Descriptor instead.
+func (*RegexpColumnMatch) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *RegexpColumnMatch) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *RegexpColumnMatch) GetInverse() bool {
+	if x != nil {
+		return x.Inverse
+	}
+	return false
+}
+
+// AllExpr references all columns.
+type AllExpr struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *AllExpr) Reset() {
+	*x = AllExpr{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AllExpr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AllExpr) ProtoMessage() {}
+
+func (x *AllExpr) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AllExpr.ProtoReflect.Descriptor instead.
+func (*AllExpr) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{17}
+}
+
+// NotExpr references all columns but the ones of an expression.
+type NotExpr struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The expression of the excluded columns.
+	Expr *Expr `protobuf:"bytes,1,opt,name=expr,proto3" json:"expr,omitempty"`
+}
+
+func (x *NotExpr) Reset() {
+	*x = NotExpr{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NotExpr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NotExpr) ProtoMessage() {}
+
+func (x *NotExpr) ProtoReflect() protoreflect.Message {
+	mi := &file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NotExpr.ProtoReflect.Descriptor instead.
+func (*NotExpr) Descriptor() ([]byte, []int) {
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *NotExpr) GetExpr() *Expr {
+	if x != nil {
+		return x.Expr
+	}
+	return nil
+}
+
+var File_frostdb_logicalplan_v1alpha1_logicalplan_proto protoreflect.FileDescriptor
+
+var file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDesc = []byte{
+	0x0a, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2f, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61,
+	0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2f, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2f, 0x6c,
+	0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x12, 0x1c, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61,
+	0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x22, 0x84,
+	0x04, 0x0a, 0x04, 0x50, 0x6c, 0x61, 0x6e, 0x12, 0x38, 0x0a, 0x05, 0x69, 0x6e, 0x70, 0x75, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62,
+	0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61,
+	0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x50, 0x6c, 0x61, 0x6e, 0x52, 0x05, 0x69, 0x6e, 0x70, 0x75,
+	0x74, 0x12, 0x48, 0x0a, 0x0a, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x73, 0x63, 0x61, 0x6e, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x27, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e,
+	0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c,
+	0x70, 0x68, 0x61, 0x31, 0x2e, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x53, 0x63, 0x61, 0x6e, 0x48, 0x00,
+	0x52, 0x09, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x53, 0x63, 0x61, 0x6e, 0x12, 0x4b, 0x0a, 0x0b, 0x73,
+	0x63, 0x68, 0x65, 0x6d, 0x61, 0x5f, 0x73, 0x63, 0x61, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x28, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63,
+	0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e,
+	0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x53, 0x63, 0x61, 0x6e, 0x48, 0x00, 0x52, 0x0a, 0x73, 0x63,
+	0x68, 0x65, 0x6d, 0x61, 0x53, 0x63, 0x61, 0x6e, 0x12, 0x3e, 0x0a, 0x06, 0x66, 0x69, 0x6c, 0x74,
+	0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74,
+	0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76,
+	0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x48, 0x00,
+	0x52, 0x06, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x12, 0x44, 0x0a, 0x08, 0x64, 0x69, 0x73, 0x74,
+	0x69, 0x6e, 0x63, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x26, 0x2e, 0x66, 0x72, 0x6f,
+	0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e,
+	0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x44, 0x69, 0x73, 0x74, 0x69, 0x6e,
+	0x63, 0x74, 0x48, 0x00, 0x52, 0x08, 0x64, 0x69, 0x73, 0x74, 0x69, 0x6e, 0x63, 0x74, 0x12, 0x4a,
+	0x0a, 0x0a, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x28, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67,
+	0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61,
+	0x31, 0x2e, 0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x0a,
+	0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x4d, 0x0a, 0x0b, 0x61, 0x67,
+	0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x29, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61,
+	0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x41,
+	0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x0b, 0x61, 0x67,
+	0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x42, 0x0a, 0x0a, 0x08, 0x6f, 0x70, 0x65,
+	0x72, 0x61, 0x74, 0x6f, 0x72, 0x22, 0xfc, 0x02, 0x0a, 0x09, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x53,
+	0x63, 0x61, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x4e, 0x61,
+	0x6d, 0x65, 0x12, 0x53, 0x0a, 0x13, 0x70, 0x68, 0x79, 0x73, 0x69, 0x63, 0x61, 0x6c, 0x5f, 0x70,
+	0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61,
+	0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45,
+	0x78, 0x70, 0x72, 0x52, 0x12, 0x70, 0x68, 0x79, 0x73, 0x69, 0x63, 0x61, 0x6c, 0x50, 0x72, 0x6f,
+	0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3a, 0x0a, 0x06, 0x66, 0x69, 0x6c, 0x74, 0x65,
+	0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64,
+	0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31,
+	0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x06, 0x66, 0x69, 0x6c,
+	0x74, 0x65, 0x72, 0x12, 0x3e, 0x0a, 0x08, 0x64, 0x69, 0x73, 0x74, 0x69, 0x6e, 0x63, 0x74, 0x18,
+	0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e,
+	0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c,
+	0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x08, 0x64, 0x69, 0x73, 0x74, 0x69,
+	0x6e, 0x63, 0x74, 0x12, 0x42, 0x0a, 0x0a, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64,
+	0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31,
+	0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x0a, 0x70, 0x72, 0x6f,
+	0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x6b, 0x69, 0x70, 0x5f,
+	0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x73,
+	0x6b, 0x69, 0x70, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x08, 0x61, 0x73,
+	0x5f, 0x6f, 0x66, 0x5f, 0x74, 0x78, 0x18, 0x07, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x61, 0x73,
+	0x4f, 0x66, 0x54, 0x78, 0x22, 0xfd, 0x02, 0x0a, 0x0a, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x53,
+	0x63, 0x61, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x4e, 0x61,
+	0x6d, 0x65, 0x12, 0x53, 0x0a, 0x13, 0x70, 0x68, 0x79, 0x73, 0x69, 0x63, 0x61, 0x6c, 0x5f, 0x70,
+	0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61,
+	0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45,
+	0x78, 0x70, 0x72, 0x52, 0x12, 0x70, 0x68, 0x79, 0x73, 0x69, 0x63, 0x61, 0x6c, 0x50, 0x72, 0x6f,
+	0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3a, 0x0a, 0x06, 0x66, 0x69, 0x6c, 0x74, 0x65,
+	0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64,
+	0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31,
+	0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x06, 0x66, 0x69, 0x6c,
+	0x74, 0x65, 0x72, 0x12, 0x3e, 0x0a, 0x08, 0x64, 0x69, 0x73, 0x74, 0x69, 0x6e, 0x63, 0x74, 0x18,
+	0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e,
+	0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c,
+	0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x08, 0x64, 0x69, 0x73, 0x74, 0x69,
+	0x6e, 0x63, 0x74, 0x12, 0x42, 0x0a, 0x0a, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64,
+	0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31,
+	0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x0a, 0x70, 0x72, 0x6f,
+	0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x6b, 0x69, 0x70, 0x5f,
+	0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x73,
+	0x6b, 0x69, 0x70, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x08, 0x61, 0x73,
+	0x5f, 0x6f, 0x66, 0x5f, 0x74, 0x78, 0x18, 0x07, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x61, 0x73,
+	0x4f, 0x66, 0x54, 0x78, 0x22, 0x40, 0x0a, 0x06, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x12, 0x36,
+	0x0a, 0x04, 0x65, 0x78, 0x70, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66,
+	0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c,
+	0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72,
+	0x52, 0x04, 0x65, 0x78, 0x70, 0x72, 0x22, 0x44, 0x0a, 0x08, 0x44, 0x69, 0x73, 0x74, 0x69, 0x6e,
+	0x63, 0x74, 0x12, 0x38, 0x0a, 0x05, 0x65, 0x78, 0x70, 0x72, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69,
+	0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31,
+	0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x05, 0x65, 0x78, 0x70, 0x72, 0x73, 0x22, 0x46, 0x0a, 0x0a,
+	0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x38, 0x0a, 0x05, 0x65, 0x78,
+	0x70, 0x72, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73,
+	0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e,
+	0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x05, 0x65,
+	0x78, 0x70, 0x72, 0x73, 0x22, 0x93, 0x01, 0x0a, 0x0b, 0x41, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3f, 0x0a, 0x09, 0x61, 0x67, 0x67, 0x5f, 0x65, 0x78, 0x70, 0x72,
+	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64,
+	0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31,
+	0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x08, 0x61, 0x67, 0x67,
+	0x45, 0x78, 0x70, 0x72, 0x73, 0x12, 0x43, 0x0a, 0x0b, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x5f, 0x65,
+	0x78, 0x70, 0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f,
+	0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e,
+	0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x0a,
+	0x67, 0x72, 0x6f, 0x75, 0x70, 0x45, 0x78, 0x70, 0x72, 0x73, 0x22, 0xbe, 0x06, 0x0a, 0x04, 0x45,
+	0x78, 0x70, 0x72, 0x12, 0x3e, 0x0a, 0x06, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f,
+	0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68,
+	0x61, 0x31, 0x2e, 0x43, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x48, 0x00, 0x52, 0x06, 0x63, 0x6f, 0x6c,
+	0x75, 0x6d, 0x6e, 0x12, 0x54, 0x0a, 0x0e, 0x64, 0x79, 0x6e, 0x61, 0x6d, 0x69, 0x63, 0x5f, 0x63,
+	0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2b, 0x2e, 0x66, 0x72,
+	0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61,
+	0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x44, 0x79, 0x6e, 0x61, 0x6d,
+	0x69, 0x63, 0x43, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x48, 0x00, 0x52, 0x0d, 0x64, 0x79, 0x6e, 0x61,
+	0x6d, 0x69, 0x63, 0x43, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x12, 0x42, 0x0a, 0x06, 0x62, 0x69, 0x6e,
+	0x61, 0x72, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x66, 0x72, 0x6f, 0x73,
+	0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e,
+	0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x45,
+	0x78, 0x70, 0x72, 0x48, 0x00, 0x52, 0x06, 0x62, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x12, 0x41, 0x0a,
+	0x07, 0x6c, 0x69, 0x74, 0x65, 0x72, 0x61, 0x6c, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x25,
+	0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c,
+	0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x4c, 0x69,
+	0x74, 0x65, 0x72, 0x61, 0x6c, 0x48, 0x00, 0x52, 0x07, 0x6c, 0x69, 0x74, 0x65, 0x72, 0x61, 0x6c,
+	0x12, 0x3f, 0x0a, 0x05, 0x61, 0x6c, 0x69, 0x61, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x27, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61,
+	0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x41,
+	0x6c, 0x69, 0x61, 0x73, 0x45, 0x78, 0x70, 0x72, 0x48, 0x00, 0x52, 0x05, 0x61, 0x6c, 0x69, 0x61,
+	0x73, 0x12, 0x66, 0x0a, 0x14, 0x61, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x5f, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x31, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61,
+	0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x41,
+	0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69,
+	0x6f, 0x6e, 0x48, 0x00, 0x52, 0x13, 0x61, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x48, 0x0a, 0x08, 0x64, 0x75, 0x72,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2a, 0x2e, 0x66, 0x72,
+	0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61,
+	0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x44, 0x75, 0x72, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x45, 0x78, 0x70, 0x72, 0x48, 0x00, 0x52, 0x08, 0x64, 0x75, 0x72, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x45, 0x0a, 0x07, 0x61, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x29, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c,
+	0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70,
+	0x68, 0x61, 0x31, 0x2e, 0x41, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65, 0x45, 0x78, 0x70, 0x72, 0x48,
+	0x00, 0x52, 0x07, 0x61, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65, 0x12, 0x61, 0x0a, 0x13, 0x72, 0x65,
+	0x67, 0x65, 0x78, 0x70, 0x5f, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x5f, 0x6d, 0x61, 0x74, 0x63,
+	0x68, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2f, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64,
+	0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31,
+	0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x52, 0x65, 0x67, 0x65, 0x78, 0x70, 0x43, 0x6f, 0x6c,
+	0x75, 0x6d, 0x6e, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x48, 0x00, 0x52, 0x11, 0x72, 0x65, 0x67, 0x65,
+	0x78, 0x70, 0x43, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x12, 0x39, 0x0a,
+	0x03, 0x61, 0x6c, 0x6c, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x25, 0x2e, 0x66, 0x72, 0x6f,
+	0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e,
+	0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x41, 0x6c, 0x6c, 0x45, 0x78, 0x70,
+	0x72, 0x48, 0x00, 0x52, 0x03, 0x61, 0x6c, 0x6c, 0x12, 0x39, 0x0a, 0x03, 0x6e, 0x6f, 0x74, 0x18,
+	0x0b, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x25, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e,
+	0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c,
+	0x70, 0x68, 0x61, 0x31, 0x2e, 0x4e, 0x6f, 0x74, 0x45, 0x78, 0x70, 0x72, 0x48, 0x00, 0x52, 0x03,
+	0x6e, 0x6f, 0x74, 0x42, 0x06, 0x0a, 0x04, 0x65, 0x78, 0x70, 0x72, 0x22, 0x1c, 0x0a, 0x06, 0x43,
+	0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0x23, 0x0a, 0x0d, 0x44, 0x79, 0x6e,
+	0x61, 0x6d, 0x69, 0x63, 0x43, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
+	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0xb0,
+	0x01, 0x0a, 0x0a, 0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x45, 0x78, 0x70, 0x72, 0x12, 0x36, 0x0a,
+	0x04, 0x6c, 0x65, 0x66, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72,
+	0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61,
+	0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52,
+	0x04, 0x6c, 0x65, 0x66, 0x74, 0x12, 0x30, 0x0a, 0x02, 0x6f, 0x70, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x20, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69,
+	0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31,
+	0x2e, 0x4f, 0x70, 0x52, 0x02, 0x6f, 0x70, 0x12, 0x38, 0x0a, 0x05, 0x72, 0x69, 0x67, 0x68, 0x74,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62,
+	0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61,
+	0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x05, 0x72, 0x69, 0x67, 0x68,
+	0x74, 0x22, 0xc7, 0x01, 0x0a, 0x07, 0x4c, 0x69, 0x74, 0x65, 0x72, 0x61, 0x6c, 0x12, 0x1f, 0x0a,
+	0x0a, 0x62, 0x6f, 0x6f, 0x6c, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x08, 0x48, 0x00, 0x52, 0x09, 0x62, 0x6f, 0x6f, 0x6c, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x21,
+	0x0a, 0x0b, 0x69, 0x6e, 0x74, 0x36, 0x34, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x03, 0x48, 0x00, 0x52, 0x0a, 0x69, 0x6e, 0x74, 0x36, 0x34, 0x56, 0x61, 0x6c, 0x75,
+	0x65, 0x12, 0x25, 0x0a, 0x0d, 0x66, 0x6c, 0x6f, 0x61, 0x74, 0x36, 0x34, 0x5f, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52, 0x0c, 0x66, 0x6c, 0x6f, 0x61,
+	0x74, 0x36, 0x34, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x23, 0x0a, 0x0c, 0x73, 0x74, 0x72, 0x69,
+	0x6e, 0x67, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00,
+	0x52, 0x0b, 0x73, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x23, 0x0a,
+	0x0c, 0x62, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x0c, 0x48, 0x00, 0x52, 0x0b, 0x62, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x56, 0x61, 0x6c,
+	0x75, 0x65, 0x42, 0x07, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x59, 0x0a, 0x09, 0x41,
+	0x6c, 0x69, 0x61, 0x73, 0x45, 0x78, 0x70, 0x72, 0x12, 0x36, 0x0a, 0x04, 0x65, 0x78, 0x70, 0x72,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62,
+	0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61,
+	0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x04, 0x65, 0x78, 0x70, 0x72,
+	0x12, 0x14, 0x0a, 0x05, 0x61, 0x6c, 0x69, 0x61, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x61, 0x6c, 0x69, 0x61, 0x73, 0x22, 0xe7, 0x01, 0x0a, 0x13, 0x41, 0x67, 0x67, 0x72, 0x65,
+	0x67, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x39,
+	0x0a, 0x04, 0x66, 0x75, 0x6e, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x25, 0x2e, 0x66,
+	0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c,
+	0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x41, 0x67, 0x67, 0x46,
+	0x75, 0x6e, 0x63, 0x52, 0x04, 0x66, 0x75, 0x6e, 0x63, 0x12, 0x36, 0x0a, 0x04, 0x65, 0x78, 0x70,
+	0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64,
+	0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31,
+	0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x04, 0x65, 0x78, 0x70,
+	0x72, 0x12, 0x1e, 0x0a, 0x0a, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x61, 0x72, 0x69, 0x65, 0x73, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x01, 0x52, 0x0a, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x61, 0x72, 0x69, 0x65,
+	0x73, 0x12, 0x3d, 0x0a, 0x08, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x5f, 0x62, 0x79, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f,
+	0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68,
+	0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x07, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x42, 0x79,
+	0x22, 0x2a, 0x0a, 0x0c, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x45, 0x78, 0x70, 0x72,
+	0x12, 0x1a, 0x0a, 0x08, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x08, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x45, 0x0a, 0x0b,
+	0x41, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65, 0x45, 0x78, 0x70, 0x72, 0x12, 0x36, 0x0a, 0x04, 0x65,
+	0x78, 0x70, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73,
+	0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e,
+	0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x52, 0x04, 0x65,
+	0x78, 0x70, 0x72, 0x22, 0x47, 0x0a, 0x11, 0x52, 0x65, 0x67, 0x65, 0x78, 0x70, 0x43, 0x6f, 0x6c,
+	0x75, 0x6d, 0x6e, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x74, 0x74,
+	0x65, 0x72, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x70, 0x61, 0x74, 0x74, 0x65,
+	0x72, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x69, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x07, 0x69, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x65, 0x22, 0x09, 0x0a, 0x07,
+	0x41, 0x6c, 0x6c, 0x45, 0x78, 0x70, 0x72, 0x22, 0x41, 0x0a, 0x07, 0x4e, 0x6f, 0x74, 0x45, 0x78,
+	0x70, 0x72, 0x12, 0x36, 0x0a, 0x04, 0x65, 0x78, 0x70, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x22, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63,
+	0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e,
+	0x45, 0x78, 0x70, 0x72, 0x52, 0x04, 0x65, 0x78, 0x70, 0x72, 0x2a, 0xbf, 0x01, 0x0a, 0x02, 0x4f,
+	0x70, 0x12, 0x12, 0x0a, 0x0e, 0x4f, 0x50, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46,
+	0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x4f, 0x50, 0x5f, 0x45, 0x51, 0x10, 0x01,
+	0x12, 0x0d, 0x0a, 0x09, 0x4f, 0x50, 0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x45, 0x51, 0x10, 0x02, 0x12,
+	0x09, 0x0a, 0x05, 0x4f, 0x50, 0x5f, 0x4c, 0x54, 0x10, 0x03, 0x12, 0x0c, 0x0a, 0x08, 0x4f, 0x50,
+	0x5f, 0x4c, 0x54, 0x5f, 0x45, 0x51, 0x10, 0x04, 0x12, 0x09, 0x0a, 0x05, 0x4f, 0x50, 0x5f, 0x47,
+	0x54, 0x10, 0x05, 0x12, 0x0c, 0x0a, 0x08, 0x4f, 0x50, 0x5f, 0x47, 0x54, 0x5f, 0x45, 0x51, 0x10,
+	0x06, 0x12, 0x12, 0x0a, 0x0e, 0x4f, 0x50, 0x5f, 0x52, 0x45, 0x47, 0x45, 0x58, 0x5f, 0x4d, 0x41,
+	0x54, 0x43, 0x48, 0x10, 0x07, 0x12, 0x16, 0x0a, 0x12, 0x4f, 0x50, 0x5f, 0x52, 0x45, 0x47, 0x45,
+	0x58, 0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x4d, 0x41, 0x54, 0x43, 0x48, 0x10, 0x08, 0x12, 0x0a, 0x0a,
+	0x06, 0x4f, 0x50, 0x5f, 0x41, 0x4e, 0x44, 0x10, 0x09, 0x12, 0x09, 0x0a, 0x05, 0x4f, 0x50, 0x5f,
+	0x4f, 0x52, 0x10, 0x0a, 0x12, 0x0a, 0x0a, 0x06, 0x4f, 0x50, 0x5f, 0x41, 0x44, 0x44, 0x10, 0x0b,
+	0x12, 0x0a, 0x0a, 0x06, 0x4f, 0x50, 0x5f, 0x53, 0x55, 0x42, 0x10, 0x0c, 0x2a, 0x82, 0x02, 0x0a,
+	0x07, 0x41, 0x67, 0x67, 0x46, 0x75, 0x6e, 0x63, 0x12, 0x18, 0x0a, 0x14, 0x41, 0x47, 0x47, 0x5f,
+	0x46, 0x55, 0x4e, 0x43, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44,
+	0x10, 0x00, 0x12, 0x10, 0x0a, 0x0c, 0x41, 0x47, 0x47, 0x5f, 0x46, 0x55, 0x4e, 0x43, 0x5f, 0x53,
+	0x55, 0x4d, 0x10, 0x01, 0x12, 0x10, 0x0a, 0x0c, 0x41, 0x47, 0x47, 0x5f, 0x46, 0x55, 0x4e, 0x43,
+	0x5f, 0x4d, 0x49, 0x4e, 0x10, 0x02, 0x12, 0x10, 0x0a, 0x0c, 0x41, 0x47, 0x47, 0x5f, 0x46, 0x55,
+	0x4e, 0x43, 0x5f, 0x4d, 0x41, 0x58, 0x10, 0x03, 0x12, 0x12, 0x0a, 0x0e, 0x41, 0x47, 0x47, 0x5f,
+	0x46, 0x55, 0x4e, 0x43, 0x5f, 0x43, 0x4f, 0x55, 0x4e, 0x54, 0x10, 0x04, 0x12, 0x10, 0x0a, 0x0c,
+	0x41, 0x47, 0x47, 0x5f, 0x46, 0x55, 0x4e, 0x43, 0x5f, 0x41, 0x56, 0x47, 0x10, 0x05, 0x12, 0x16,
+	0x0a, 0x12, 0x41, 0x47, 0x47, 0x5f, 0x46, 0x55, 0x4e, 0x43, 0x5f, 0x48, 0x49, 0x53, 0x54, 0x4f,
+	0x47, 0x52, 0x41, 0x4d, 0x10, 0x06, 0x12, 0x12, 0x0a, 0x0e, 0x41, 0x47, 0x47, 0x5f, 0x46, 0x55,
+	0x4e, 0x43, 0x5f, 0x46, 0x49, 0x52, 0x53, 0x54, 0x10, 0x07, 0x12, 0x11, 0x0a, 0x0d, 0x41, 0x47,
+	0x47, 0x5f, 0x46, 0x55, 0x4e, 0x43, 0x5f, 0x4c, 0x41, 0x53, 0x54, 0x10, 0x08, 0x12, 0x13, 0x0a,
+	0x0f, 0x41, 0x47, 0x47, 0x5f, 0x46, 0x55, 0x4e, 0x43, 0x5f, 0x53, 0x54, 0x44, 0x44, 0x45, 0x56,
+	0x10, 0x09, 0x12, 0x15, 0x0a, 0x11, 0x41, 0x47, 0x47, 0x5f, 0x46, 0x55, 0x4e, 0x43, 0x5f, 0x56,
+	0x41, 0x52, 0x49, 0x41, 0x4e, 0x43, 0x45, 0x10, 0x0a, 0x12, 0x16, 0x0a, 0x12, 0x41, 0x47, 0x47,
+	0x5f, 0x46, 0x55, 0x4e, 0x43, 0x5f, 0x41, 0x4e, 0x59, 0x5f, 0x56, 0x41, 0x4c, 0x55, 0x45, 0x10,
+	0x0b, 0x42, 0xa5, 0x02, 0x0a, 0x20, 0x63, 0x6f, 0x6d, 0x2e, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64,
+	0x62, 0x2e, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x76, 0x31,
+	0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x42, 0x10, 0x4c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70,
+	0x6c, 0x61, 0x6e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01, 0x5a, 0x5d, 0x67, 0x69, 0x74, 0x68,
+	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x70, 0x6f, 0x6c, 0x61, 0x72, 0x73, 0x69, 0x67, 0x6e,
+	0x61, 0x6c, 0x73, 0x2f, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2f, 0x67, 0x65, 0x6e, 0x2f,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x67, 0x6f, 0x2f, 0x66, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62,
+	0x2f, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2f, 0x76, 0x31, 0x61,
+	0x6c, 0x70, 0x68, 0x61, 0x31, 0x3b, 0x6c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61,
+	0x6e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0xa2, 0x02, 0x03, 0x46, 0x4c, 0x58, 0xaa,
+	0x02, 0x1c, 0x46, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x2e, 0x4c, 0x6f, 0x67, 0x69, 0x63, 0x61,
+	0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x2e, 0x56, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0xca, 0x02,
+	0x1c, 0x46, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x5c, 0x4c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c,
+	0x70, 0x6c, 0x61, 0x6e, 0x5c, 0x56, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0xe2, 0x02, 0x28,
+	0x46, 0x72, 0x6f, 0x73, 0x74, 0x64, 0x62, 0x5c, 0x4c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70,
+	0x6c, 0x61, 0x6e, 0x5c, 0x56, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x5c, 0x47, 0x50, 0x42,
+	0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0xea, 0x02, 0x1e, 0x46, 0x72, 0x6f, 0x73, 0x74,
+	0x64, 0x62, 0x3a, 0x3a, 0x4c, 0x6f, 0x67, 0x69, 0x63, 0x61, 0x6c, 0x70, 0x6c, 0x61, 0x6e, 0x3a,
+	0x3a, 0x56, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescOnce sync.Once
+	file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescData = file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDesc
+)
+
+func file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescGZIP() []byte {
+	file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescOnce.Do(func() {
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescData = protoimpl.X.CompressGZIP(file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescData)
+	})
+	return file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDescData
+}
+
+var file_frostdb_logicalplan_v1alpha1_logicalplan_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_frostdb_logicalplan_v1alpha1_logicalplan_proto_goTypes = []interface{}{
+	(Op)(0),                     // 0: frostdb.logicalplan.v1alpha1.Op
+	(AggFunc)(0),                // 1: frostdb.logicalplan.v1alpha1.AggFunc
+	(*Plan)(nil),                // 2: frostdb.logicalplan.v1alpha1.Plan
+	(*TableScan)(nil),           // 3: frostdb.logicalplan.v1alpha1.TableScan
+	(*SchemaScan)(nil),          // 4: frostdb.logicalplan.v1alpha1.SchemaScan
+	(*Filter)(nil),              // 5: frostdb.logicalplan.v1alpha1.Filter
+	(*Distinct)(nil),            // 6: frostdb.logicalplan.v1alpha1.Distinct
+	(*Projection)(nil),          // 7: frostdb.logicalplan.v1alpha1.Projection
+	(*Aggregation)(nil),         // 8: frostdb.logicalplan.v1alpha1.Aggregation
+	(*Expr)(nil),                // 9: frostdb.logicalplan.v1alpha1.Expr
+	(*Column)(nil),              // 10: frostdb.logicalplan.v1alpha1.Column
+	(*DynamicColumn)(nil),       // 11: frostdb.logicalplan.v1alpha1.DynamicColumn
+	(*BinaryExpr)(nil),          // 12: frostdb.logicalplan.v1alpha1.BinaryExpr
+	(*Literal)(nil),             // 13: frostdb.logicalplan.v1alpha1.Literal
+	(*AliasExpr)(nil),           // 14: frostdb.logicalplan.v1alpha1.AliasExpr
+	(*AggregationFunction)(nil), // 15: frostdb.logicalplan.v1alpha1.AggregationFunction
+	(*DurationExpr)(nil),        // 16: frostdb.logicalplan.v1alpha1.DurationExpr
+	(*AverageExpr)(nil),         // 17: frostdb.logicalplan.v1alpha1.AverageExpr
+	(*RegexpColumnMatch)(nil),   // 18: frostdb.logicalplan.v1alpha1.RegexpColumnMatch
+	(*AllExpr)(nil),             // 19: frostdb.logicalplan.v1alpha1.AllExpr
+	(*NotExpr)(nil),             // 20: frostdb.logicalplan.v1alpha1.NotExpr
+}
+var file_frostdb_logicalplan_v1alpha1_logicalplan_proto_depIdxs = []int32{
+	2,  // 0: frostdb.logicalplan.v1alpha1.Plan.input:type_name -> frostdb.logicalplan.v1alpha1.Plan
+	3,  // 1: frostdb.logicalplan.v1alpha1.Plan.table_scan:type_name -> frostdb.logicalplan.v1alpha1.TableScan
+	4,  // 2: frostdb.logicalplan.v1alpha1.Plan.schema_scan:type_name -> frostdb.logicalplan.v1alpha1.SchemaScan
+	5,  // 3: frostdb.logicalplan.v1alpha1.Plan.filter:type_name -> frostdb.logicalplan.v1alpha1.Filter
+	6,  // 4: frostdb.logicalplan.v1alpha1.Plan.distinct:type_name -> frostdb.logicalplan.v1alpha1.Distinct
+	7,  // 5: frostdb.logicalplan.v1alpha1.Plan.projection:type_name -> frostdb.logicalplan.v1alpha1.Projection
+	8,  // 6: frostdb.logicalplan.v1alpha1.Plan.aggregation:type_name -> frostdb.logicalplan.v1alpha1.Aggregation
+	9,  // 7: frostdb.logicalplan.v1alpha1.TableScan.physical_projection:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 8: frostdb.logicalplan.v1alpha1.TableScan.filter:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 9: frostdb.logicalplan.v1alpha1.TableScan.distinct:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 10: frostdb.logicalplan.v1alpha1.TableScan.projection:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 11: frostdb.logicalplan.v1alpha1.SchemaScan.physical_projection:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 12: frostdb.logicalplan.v1alpha1.SchemaScan.filter:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 13: frostdb.logicalplan.v1alpha1.SchemaScan.distinct:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 14: frostdb.logicalplan.v1alpha1.SchemaScan.projection:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 15: frostdb.logicalplan.v1alpha1.Filter.expr:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 16: frostdb.logicalplan.v1alpha1.Distinct.exprs:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 17: frostdb.logicalplan.v1alpha1.Projection.exprs:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 18: frostdb.logicalplan.v1alpha1.Aggregation.agg_exprs:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 19: frostdb.logicalplan.v1alpha1.Aggregation.group_exprs:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	10, // 20: frostdb.logicalplan.v1alpha1.Expr.column:type_name -> frostdb.logicalplan.v1alpha1.Column
+	11, // 21: frostdb.logicalplan.v1alpha1.Expr.dynamic_column:type_name -> frostdb.logicalplan.v1alpha1.DynamicColumn
+	12, // 22: frostdb.logicalplan.v1alpha1.Expr.binary:type_name -> frostdb.logicalplan.v1alpha1.BinaryExpr
+	13, // 23: frostdb.logicalplan.v1alpha1.Expr.literal:type_name -> frostdb.logicalplan.v1alpha1.Literal
+	14, // 24: frostdb.logicalplan.v1alpha1.Expr.alias:type_name -> frostdb.logicalplan.v1alpha1.AliasExpr
+	15, // 25: frostdb.logicalplan.v1alpha1.Expr.aggregation_function:type_name -> frostdb.logicalplan.v1alpha1.AggregationFunction
+	16, // 26: frostdb.logicalplan.v1alpha1.Expr.duration:type_name -> frostdb.logicalplan.v1alpha1.DurationExpr
+	17, // 27: frostdb.logicalplan.v1alpha1.Expr.average:type_name -> frostdb.logicalplan.v1alpha1.AverageExpr
+	18, // 28: frostdb.logicalplan.v1alpha1.Expr.regexp_column_match:type_name -> frostdb.logicalplan.v1alpha1.RegexpColumnMatch
+	19, // 29: frostdb.logicalplan.v1alpha1.Expr.all:type_name -> frostdb.logicalplan.v1alpha1.AllExpr
+	20, // 30: frostdb.logicalplan.v1alpha1.Expr.not:type_name -> frostdb.logicalplan.v1alpha1.NotExpr
+	9,  // 31: frostdb.logicalplan.v1alpha1.BinaryExpr.left:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	0,  // 32: frostdb.logicalplan.v1alpha1.BinaryExpr.op:type_name -> frostdb.logicalplan.v1alpha1.Op
+	9,  // 33: frostdb.logicalplan.v1alpha1.BinaryExpr.right:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 34: frostdb.logicalplan.v1alpha1.AliasExpr.expr:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	1,  // 35: frostdb.logicalplan.v1alpha1.AggregationFunction.func:type_name -> frostdb.logicalplan.v1alpha1.AggFunc
+	9,  // 36: frostdb.logicalplan.v1alpha1.AggregationFunction.expr:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 37: frostdb.logicalplan.v1alpha1.AggregationFunction.order_by:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 38: frostdb.logicalplan.v1alpha1.AverageExpr.expr:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	9,  // 39: frostdb.logicalplan.v1alpha1.NotExpr.expr:type_name -> frostdb.logicalplan.v1alpha1.Expr
+	40, // [40:40] is the sub-list for method output_type
+	40, // [40:40] is the sub-list for method input_type
+	40, // [40:40] is the sub-list for extension type_name
+	40, // [40:40] is the sub-list for extension extendee
+	0,  // [0:40] is the sub-list for field type_name
+}
+
+func init() { file_frostdb_logicalplan_v1alpha1_logicalplan_proto_init() }
+func file_frostdb_logicalplan_v1alpha1_logicalplan_proto_init() {
+	if File_frostdb_logicalplan_v1alpha1_logicalplan_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Plan); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TableScan); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SchemaScan); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Filter); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Distinct); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Projection); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Aggregation); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Expr); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Column); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DynamicColumn); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BinaryExpr); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Literal); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AliasExpr); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AggregationFunction); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DurationExpr); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AverageExpr); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RegexpColumnMatch); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AllExpr); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NotExpr); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[0].OneofWrappers = []interface{}{
+		(*Plan_TableScan)(nil),
+		(*Plan_SchemaScan)(nil),
+		(*Plan_Filter)(nil),
+		(*Plan_Distinct)(nil),
+		(*Plan_Projection)(nil),
+		(*Plan_Aggregation)(nil),
+	}
+	file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[7].OneofWrappers = []interface{}{
+		(*Expr_Column)(nil),
+		(*Expr_DynamicColumn)(nil),
+		(*Expr_Binary)(nil),
+		(*Expr_Literal)(nil),
+		(*Expr_Alias)(nil),
+		(*Expr_AggregationFunction)(nil),
+		(*Expr_Duration)(nil),
+		(*Expr_Average)(nil),
+		(*Expr_RegexpColumnMatch)(nil),
+		(*Expr_All)(nil),
+		(*Expr_Not)(nil),
+	}
+	file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes[11].OneofWrappers = []interface{}{
+		(*Literal_BoolValue)(nil),
+		(*Literal_Int64Value)(nil),
+		(*Literal_Float64Value)(nil),
+		(*Literal_StringValue)(nil),
+		(*Literal_BinaryValue)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDesc,
+			NumEnums:      2,
+			NumMessages:   19,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_frostdb_logicalplan_v1alpha1_logicalplan_proto_goTypes,
+		DependencyIndexes: file_frostdb_logicalplan_v1alpha1_logicalplan_proto_depIdxs,
+		EnumInfos:         file_frostdb_logicalplan_v1alpha1_logicalplan_proto_enumTypes,
+		MessageInfos:      file_frostdb_logicalplan_v1alpha1_logicalplan_proto_msgTypes,
+	}.Build()
+	File_frostdb_logicalplan_v1alpha1_logicalplan_proto = out.File
+	file_frostdb_logicalplan_v1alpha1_logicalplan_proto_rawDesc = nil
+	file_frostdb_logicalplan_v1alpha1_logicalplan_proto_goTypes = nil
+	file_frostdb_logicalplan_v1alpha1_logicalplan_proto_depIdxs = nil
+}
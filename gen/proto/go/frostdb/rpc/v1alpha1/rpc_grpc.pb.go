@@ -0,0 +1,213 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: frostdb/rpc/v1alpha1/rpc.proto
+
+package rpcv1alpha1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	FrostDBService_Insert_FullMethodName = "/frostdb.rpc.v1alpha1.FrostDBService/Insert"
+	FrostDBService_Query_FullMethodName  = "/frostdb.rpc.v1alpha1.FrostDBService/Query"
+)
+
+// FrostDBServiceClient is the client API for FrostDBService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FrostDBServiceClient interface {
+	// Insert inserts the records of a stream of requests. The response is sent
+	// once all requests have been inserted.
+	Insert(ctx context.Context, opts ...grpc.CallOption) (FrostDBService_InsertClient, error)
+	// Query executes a query and streams its results.
+	Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (FrostDBService_QueryClient, error)
+}
+
+type frostDBServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFrostDBServiceClient(cc grpc.ClientConnInterface) FrostDBServiceClient {
+	return &frostDBServiceClient{cc}
+}
+
+func (c *frostDBServiceClient) Insert(ctx context.Context, opts ...grpc.CallOption) (FrostDBService_InsertClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FrostDBService_ServiceDesc.Streams[0], FrostDBService_Insert_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &frostDBServiceInsertClient{stream}
+	return x, nil
+}
+
+type FrostDBService_InsertClient interface {
+	Send(*InsertRequest) error
+	CloseAndRecv() (*InsertResponse, error)
+	grpc.ClientStream
+}
+
+type frostDBServiceInsertClient struct {
+	grpc.ClientStream
+}
+
+func (x *frostDBServiceInsertClient) Send(m *InsertRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *frostDBServiceInsertClient) CloseAndRecv() (*InsertResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(InsertResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *frostDBServiceClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (FrostDBService_QueryClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FrostDBService_ServiceDesc.Streams[1], FrostDBService_Query_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &frostDBServiceQueryClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type FrostDBService_QueryClient interface {
+	Recv() (*QueryResponse, error)
+	grpc.ClientStream
+}
+
+type frostDBServiceQueryClient struct {
+	grpc.ClientStream
+}
+
+func (x *frostDBServiceQueryClient) Recv() (*QueryResponse, error) {
+	m := new(QueryResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FrostDBServiceServer is the server API for FrostDBService service.
+// All implementations must embed UnimplementedFrostDBServiceServer
+// for forward compatibility
+type FrostDBServiceServer interface {
+	// Insert inserts the records of a stream of requests. The response is sent
+	// once all requests have been inserted.
+	Insert(FrostDBService_InsertServer) error
+	// Query executes a query and streams its results.
+	Query(*QueryRequest, FrostDBService_QueryServer) error
+	mustEmbedUnimplementedFrostDBServiceServer()
+}
+
+// UnimplementedFrostDBServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedFrostDBServiceServer struct {
+}
+
+func (UnimplementedFrostDBServiceServer) Insert(FrostDBService_InsertServer) error {
+	return status.Errorf(codes.Unimplemented, "method Insert not implemented")
+}
+func (UnimplementedFrostDBServiceServer) Query(*QueryRequest, FrostDBService_QueryServer) error {
+	return status.Errorf(codes.Unimplemented, "method Query not implemented")
+}
+func (UnimplementedFrostDBServiceServer) mustEmbedUnimplementedFrostDBServiceServer() {}
+
+// UnsafeFrostDBServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FrostDBServiceServer will
+// result in compilation errors.
+type UnsafeFrostDBServiceServer interface {
+	mustEmbedUnimplementedFrostDBServiceServer()
+}
+
+func RegisterFrostDBServiceServer(s grpc.ServiceRegistrar, srv FrostDBServiceServer) {
+	s.RegisterService(&FrostDBService_ServiceDesc, srv)
+}
+
+func _FrostDBService_Insert_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FrostDBServiceServer).Insert(&frostDBServiceInsertServer{stream})
+}
+
+type FrostDBService_InsertServer interface {
+	SendAndClose(*InsertResponse) error
+	Recv() (*InsertRequest, error)
+	grpc.ServerStream
+}
+
+type frostDBServiceInsertServer struct {
+	grpc.ServerStream
+}
+
+func (x *frostDBServiceInsertServer) SendAndClose(m *InsertResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *frostDBServiceInsertServer) Recv() (*InsertRequest, error) {
+	m := new(InsertRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _FrostDBService_Query_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(QueryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FrostDBServiceServer).Query(m, &frostDBServiceQueryServer{stream})
+}
+
+type FrostDBService_QueryServer interface {
+	Send(*QueryResponse) error
+	grpc.ServerStream
+}
+
+type frostDBServiceQueryServer struct {
+	grpc.ServerStream
+}
+
+func (x *frostDBServiceQueryServer) Send(m *QueryResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// FrostDBService_ServiceDesc is the grpc.ServiceDesc for FrostDBService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FrostDBService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "frostdb.rpc.v1alpha1.FrostDBService",
+	HandlerType: (*FrostDBServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Insert",
+			Handler:       _FrostDBService_Insert_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Query",
+			Handler:       _FrostDBService_Query_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "frostdb/rpc/v1alpha1/rpc.proto",
+}
@@ -59,6 +59,51 @@ func (m *TableConfig) MarshalToSizedBufferVT(dAtA []byte) (int, error) {
 		}
 		i -= size
 	}
+	if m.MinPartBytes != 0 {
+		i = encodeVarint(dAtA, i, uint64(m.MinPartBytes))
+		i--
+		dAtA[i] = 0x60
+	}
+	if m.MinPartRows != 0 {
+		i = encodeVarint(dAtA, i, uint64(m.MinPartRows))
+		i--
+		dAtA[i] = 0x58
+	}
+	if m.AutoSort {
+		i--
+		if m.AutoSort {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x50
+	}
+	if m.VerifyInsertOrder {
+		i--
+		if m.VerifyInsertOrder {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x48
+	}
+	if m.PreAggregateSortingColumns != 0 {
+		i = encodeVarint(dAtA, i, uint64(m.PreAggregateSortingColumns))
+		i--
+		dAtA[i] = 0x40
+	}
+	if m.MaxPartBytes != 0 {
+		i = encodeVarint(dAtA, i, uint64(m.MaxPartBytes))
+		i--
+		dAtA[i] = 0x38
+	}
+	if m.MaxPartRows != 0 {
+		i = encodeVarint(dAtA, i, uint64(m.MaxPartRows))
+		i--
+		dAtA[i] = 0x30
+	}
 	if m.DisableWal {
 		i--
 		if m.DisableWal {
@@ -149,6 +194,27 @@ func (m *TableConfig) SizeVT() (n int) {
 	if m.DisableWal {
 		n += 2
 	}
+	if m.MaxPartRows != 0 {
+		n += 1 + sov(uint64(m.MaxPartRows))
+	}
+	if m.MaxPartBytes != 0 {
+		n += 1 + sov(uint64(m.MaxPartBytes))
+	}
+	if m.PreAggregateSortingColumns != 0 {
+		n += 1 + sov(uint64(m.PreAggregateSortingColumns))
+	}
+	if m.VerifyInsertOrder {
+		n += 2
+	}
+	if m.AutoSort {
+		n += 2
+	}
+	if m.MinPartRows != 0 {
+		n += 1 + sov(uint64(m.MinPartRows))
+	}
+	if m.MinPartBytes != 0 {
+		n += 1 + sov(uint64(m.MinPartBytes))
+	}
 	n += len(m.unknownFields)
 	return n
 }
@@ -353,6 +419,141 @@ func (m *TableConfig) UnmarshalVT(dAtA []byte) error {
 				}
 			}
 			m.DisableWal = bool(v != 0)
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxPartRows", wireType)
+			}
+			m.MaxPartRows = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxPartRows |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxPartBytes", wireType)
+			}
+			m.MaxPartBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxPartBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PreAggregateSortingColumns", wireType)
+			}
+			m.PreAggregateSortingColumns = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PreAggregateSortingColumns |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VerifyInsertOrder", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.VerifyInsertOrder = bool(v != 0)
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AutoSort", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.AutoSort = bool(v != 0)
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MinPartRows", wireType)
+			}
+			m.MinPartRows = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MinPartRows |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MinPartBytes", wireType)
+			}
+			m.MinPartBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MinPartBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skip(dAtA[iNdEx:])
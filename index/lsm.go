@@ -3,6 +3,8 @@ package index
 import (
 	"context"
 	"fmt"
+	"runtime/pprof"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,6 +18,7 @@ import (
 
 	"github.com/polarsignals/frostdb/dynparquet"
 	"github.com/polarsignals/frostdb/parts"
+	"github.com/polarsignals/frostdb/pqarrow"
 	"github.com/polarsignals/frostdb/query/expr"
 	"github.com/polarsignals/frostdb/query/logicalplan"
 )
@@ -37,14 +40,35 @@ type LSM struct {
 	levels  *Node
 	sizes   []atomic.Int64
 	configs []*LevelConfig
+	// reads is the number of parts of each level read by scans since the
+	// level was last compacted.
+	reads []atomic.Int64
 
 	logger  log.Logger
 	metrics *LSMMetrics
+
+	// compression is the schema records added to L0 are encoded with.
+	// Records are kept as is if nil.
+	compression *dynparquet.Schema
+	// profileLabels are the pprof labels of the compaction goroutines.
+	profileLabels pprof.LabelSet
+	// onCompaction is called after a level was compacted, if set.
+	onCompaction func(level SentinelType, compacted int, parts []parts.Part)
+	// policy decides when levels are compacted and which parts are merged.
+	policy MergePolicy
+	// undersized reports whether a compacted part is small enough to be
+	// merged with adjacent undersized parts of its level, if set.
+	undersized func(parts.Part) bool
+	// onUndersizedMerge is called for every run of undersized parts merged.
+	onUndersizedMerge func()
+	// writeBuffer stages the records added by Stage, if set.
+	writeBuffer *writeBuffer
 }
 
 // LSMMetrics are the metrics for an LSM index.
 type LSMMetrics struct {
 	Compactions        *prometheus.CounterVec
+	HotCompactions     *prometheus.CounterVec
 	LevelSize          *prometheus.GaugeVec
 	CompactionDuration prometheus.Histogram
 }
@@ -53,10 +77,14 @@ type LSMMetrics struct {
 // The Level is the sentinel node that represents the level.
 // The MaxSize is the maximum size in bytes that the level can reach before it triggers compaction into the next level.
 // The Compact function is called when the level reaches it's max size. NOTE: that this is not yet implemtened and the database will rotate the full block as normal.
+// The HotReads is the number of part reads by scans after which the level is compacted even if it has not reached its max size, so that
+// frequently queried data is merged into large sorted parts early while cold data is compacted lazily: the merge policy is asked to plan
+// the merge of a hot level with the reads of every part, and the default policy only merges the most read parts. Disabled if 0.
 type LevelConfig struct {
-	Level   SentinelType
-	MaxSize int64
-	Compact func([]parts.Part, ...parts.Option) ([]parts.Part, int64, int64, error)
+	Level    SentinelType
+	MaxSize  int64
+	HotReads int64
+	Compact  func([]parts.Part, ...parts.Option) ([]parts.Part, int64, int64, error)
 }
 
 type LSMOption func(*LSM)
@@ -73,6 +101,55 @@ func LSMWithMetrics(metrics *LSMMetrics) LSMOption {
 	}
 }
 
+// LSMWithCompression makes the index encode the records added to L0 into
+// Parquet with the given schema, trading a small decoding cost on reads for
+// the memory saved. The schema is expected to use lightweight encodings, see
+// dynparquet.Schema.LightweightEncoded.
+func LSMWithCompression(schema *dynparquet.Schema) LSMOption {
+	return func(l *LSM) {
+		l.compression = schema
+	}
+}
+
+// LSMWithProfileLabels sets the pprof labels, as key/value pairs, of the
+// goroutines compacting the index in the background, so that CPU profiles
+// attribute the time spent compacting to e.g. the table of the index.
+func LSMWithProfileLabels(labels ...string) LSMOption {
+	return func(l *LSM) {
+		l.profileLabels = pprof.Labels(labels...)
+	}
+}
+
+// LSMWithCompactionCallback sets a function that is called after a level of
+// the index was compacted into the next level, with the level compacted into,
+// the number of parts that were compacted and the parts they were compacted
+// into. The parts must not be retained after the function returned.
+func LSMWithCompactionCallback(f func(level SentinelType, compacted int, parts []parts.Part)) LSMOption {
+	return func(l *LSM) {
+		l.onCompaction = f
+	}
+}
+
+// LSMWithMergePolicy sets the policy that decides when the levels of the
+// index are compacted and which of their parts are merged together. Defaults
+// to LeveledMergePolicy.
+func LSMWithMergePolicy(policy MergePolicy) LSMOption {
+	return func(l *LSM) {
+		l.policy = policy
+	}
+}
+
+// LSMWithUndersizedParts makes compactions merge runs of adjacent parts of the
+// level compacted into, including the parts the level already had, for which
+// undersized returns true, so that the level is not left with many small
+// parts. merged is called for every run of parts merged.
+func LSMWithUndersizedParts(undersized func(parts.Part) bool, merged func()) LSMOption {
+	return func(l *LSM) {
+		l.undersized = undersized
+		l.onUndersizedMerge = merged
+	}
+}
+
 func NewLSMMetrics(reg prometheus.Registerer) *LSMMetrics {
 	return &LSMMetrics{
 		Compactions: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
@@ -80,6 +157,11 @@ func NewLSMMetrics(reg prometheus.Registerer) *LSMMetrics {
 			Help: "The total number of compactions that have occurred.",
 		}, []string{"level"}),
 
+		HotCompactions: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "frostdb_lsm_hot_compactions_total",
+			Help: "The total number of compactions of levels below their max size because they were frequently read.",
+		}, []string{"level"}),
+
 		LevelSize: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
 			Name: "frostdb_lsm_level_size_bytes",
 			Help: "The size of the level in bytes.",
@@ -104,9 +186,11 @@ func NewLSM(prefix string, schema *dynparquet.Schema, levels []*LevelConfig, opt
 		prefix:     prefix,
 		levels:     NewList(L0),
 		sizes:      make([]atomic.Int64, len(levels)),
+		reads:      make([]atomic.Int64, len(levels)),
 		configs:    levels,
 		compacting: &atomic.Bool{},
 		logger:     log.NewNopLogger(),
+		policy:     LeveledMergePolicy{},
 	}
 
 	for _, opt := range options {
@@ -125,9 +209,10 @@ func NewLSM(prefix string, schema *dynparquet.Schema, levels []*LevelConfig, opt
 	return lsm, nil
 }
 
-// Size returns the total size of the index in bytes.
+// Size returns the total size of the index in bytes, including the records
+// staged in its write buffer.
 func (l *LSM) Size() int64 {
-	var size int64
+	size := l.StagedSize()
 	for i := range l.sizes {
 		size += l.sizes[i].Load()
 	}
@@ -139,6 +224,19 @@ func (l *LSM) LevelSize(t SentinelType) int64 {
 	return l.sizes[t].Load()
 }
 
+// CompactionBacklog returns the number of bytes by which the levels that are
+// compacted into the next level exceed their configured max size. A growing
+// backlog means compaction does not keep up with inserts.
+func (l *LSM) CompactionBacklog() int64 {
+	var backlog int64
+	for i := 0; i < len(l.configs)-1; i++ {
+		if excess := l.sizes[i].Load() - l.configs[i].MaxSize; excess > 0 {
+			backlog += excess
+		}
+	}
+	return backlog
+}
+
 func validateLevels(levels []*LevelConfig) error {
 	for i, l := range levels {
 		if int(l.Level) != i {
@@ -165,22 +263,47 @@ func (l *LSM) MaxLevel() SentinelType {
 }
 
 func (l *LSM) Add(tx uint64, record arrow.Record) {
-	record.Retain()
-	size := util.TotalRecordSize(record)
-	l.levels.Prepend(parts.NewArrowPart(tx, record, uint64(size), l.schema, parts.WithCompactionLevel(int(L0))))
-	l0 := l.sizes[L0].Add(int64(size))
+	part, size := l.newL0Part(tx, record)
+	l.levels.Prepend(part)
+	l.addedToL0(size)
+}
+
+// addedToL0 accounts for parts of the given size added to L0 and compacts L0
+// in the background if the merge policy says so.
+func (l *LSM) addedToL0(size int64) {
+	l0 := l.sizes[L0].Add(size)
 	l.metrics.LevelSize.WithLabelValues(L0.String()).Set(float64(l0))
-	if l0 >= l.configs[L0].MaxSize {
+	if l.policy.ShouldCompact(l.levelState(L0)) {
 		if l.compacting.CompareAndSwap(false, true) {
 			l.compactionWg.Add(1)
-			go func() {
-				defer l.compactionWg.Done()
-				_ = l.compact(false)
-			}()
+			go l.compactInBackground()
 		}
 	}
 }
 
+// newL0Part returns the L0 part of the given record and its size.
+func (l *LSM) newL0Part(tx uint64, record arrow.Record) (parts.Part, int64) {
+	if l.compression != nil {
+		buf, err := pqarrow.SerializeRecord(record, l.compression)
+		if err == nil {
+			return parts.NewParquetPart(tx, buf, parts.WithCompactionLevel(int(L0))), buf.ParquetFile().Size()
+		}
+		level.Warn(l.logger).Log("msg", "failed to compress record, keeping it uncompressed", "err", err)
+	}
+	record.Retain()
+	size := util.TotalRecordSize(record)
+	return parts.NewArrowPart(tx, record, uint64(size), l.schema, parts.WithCompactionLevel(int(L0))), size
+}
+
+// compactInBackground compacts the index with the profile labels of the index.
+// The caller must have set compacting and added to compactionWg.
+func (l *LSM) compactInBackground() {
+	defer l.compactionWg.Done()
+	pprof.Do(context.Background(), l.profileLabels, func(context.Context) {
+		_ = l.compact(false)
+	})
+}
+
 func (l *LSM) WaitForPendingCompactions() {
 	l.compactionWg.Wait()
 }
@@ -213,17 +336,70 @@ func (l *LSM) Iterate(iter func(node *Node) bool) {
 	l.levels.Iterate(iter)
 }
 
-func (l *LSM) Scan(ctx context.Context, _ string, _ *dynparquet.Schema, filter logicalplan.Expr, tx uint64, callback func(context.Context, any) error) error {
-	l.RLock()
-	defer l.RUnlock()
+type partFilterKey struct{}
+
+// WithPartFilter returns a context making scans of the index executed with it
+// only read the parts for which keep returns true.
+func WithPartFilter(ctx context.Context, keep func(parts.Part) bool) context.Context {
+	return context.WithValue(ctx, partFilterKey{}, keep)
+}
+
+func partFilterFromContext(ctx context.Context) func(parts.Part) bool {
+	keep, _ := ctx.Value(partFilterKey{}).(func(parts.Part) bool)
+	return keep
+}
 
+// Scan passes the records and the row groups of the parts of the index visible
+// at the given transaction to the callback. The callback takes ownership of the
+// records and row groups: records must be released, and row groups hold a
+// reference to their part that must be released by calling their Release
+// method once they are no longer read.
+func (l *LSM) Scan(ctx context.Context, _ string, _ *dynparquet.Schema, filter logicalplan.Expr, tx uint64, callback func(context.Context, any) error) error {
 	booleanFilter, err := expr.BooleanExpr(filter)
 	if err != nil {
 		return fmt.Errorf("boolean expr: %w", err)
 	}
-	var iterError error
+	stats := expr.PruningStatsFromContext(ctx)
+	scanned := l.retainParts(tx, partFilterFromContext(ctx))
+	defer func() {
+		for _, s := range scanned {
+			s.node.part.Release()
+		}
+	}()
+
+	for len(scanned) > 0 {
+		s := scanned[0]
+		scanned = scanned[1:]
+		err := l.scanPart(ctx, s.level, s.node, booleanFilter, stats, callback)
+		s.node.part.Release()
+		if err != nil {
+			return err
+		}
+	}
+	l.compactHot()
+	return nil
+}
+
+// scannedNode is a node of a part read by a scan.
+type scannedNode struct {
+	level SentinelType
+	node  *Node
+}
+
+// retainParts retains and returns the nodes of the parts visible at the given
+// transaction that are kept by the given part filter, if any. The index is
+// only locked while the parts are retained, so compactions can release the
+// parts they merged while scans are still reading them. The memory of the
+// parts is released once the last scan releases them.
+func (l *LSM) retainParts(tx uint64, keep func(parts.Part) bool) []scannedNode {
+	l.RLock()
+	defer l.RUnlock()
+
+	scanned := l.retainStaged(tx, keep)
+	level := L0
 	l.levels.Iterate(func(node *Node) bool {
 		if node.part == nil { // encountered a sentinel node; continue on
+			level = node.sentinel
 			return true
 		}
 
@@ -231,39 +407,105 @@ func (l *LSM) Scan(ctx context.Context, _ string, _ *dynparquet.Schema, filter l
 			return true
 		}
 
-		if r := node.part.Record(); r != nil {
-			r.Retain()
-			if err := callback(ctx, r); err != nil {
-				iterError = err
-				return false
-			}
+		if keep != nil && !keep(node.part) {
 			return true
 		}
 
-		buf, err := node.part.AsSerializedBuffer(nil)
+		node.part.Retain()
+		scanned = append(scanned, scannedNode{level: level, node: node})
+		return true
+	})
+	return scanned
+}
+
+// scanPart passes the record or the row groups that may contain data matching
+// the filter of the part of the given node to the callback. Each row group
+// holds a reference to the part that the receiver must release by calling its
+// Release method once it no longer reads the row group.
+func (l *LSM) scanPart(
+	ctx context.Context,
+	level SentinelType,
+	node *Node,
+	booleanFilter expr.TrueNegativeFilter,
+	stats *expr.PruningStats,
+	callback func(context.Context, any) error,
+) error {
+	if r := node.part.Record(); r != nil {
+		l.read(level, node)
+		r.Retain()
+		return callback(ctx, r)
+	}
+
+	buf, err := node.part.AsSerializedBuffer(nil)
+	if err != nil {
+		return err
+	}
+
+	read := false
+	for i := 0; i < buf.NumRowGroups(); i++ {
+		rg := buf.DynamicRowGroup(i)
+		mayContainUsefulData, err := stats.Eval(booleanFilter, rg)
 		if err != nil {
-			iterError = err
-			return false
+			return err
 		}
 
-		for i := 0; i < buf.NumRowGroups(); i++ {
-			rg := buf.DynamicRowGroup(i)
-			mayContainUsefulData, err := booleanFilter.Eval(rg)
-			if err != nil {
-				iterError = err
-				return false
+		if mayContainUsefulData {
+			if !read {
+				l.read(level, node)
+				read = true
 			}
-
-			if mayContainUsefulData {
-				if err := callback(ctx, rg); err != nil {
-					iterError = err
-					return false
-				}
+			node.part.Retain()
+			if err := callback(ctx, &partRowGroup{DynamicRowGroup: rg, part: node.part}); err != nil {
+				return err
 			}
 		}
-		return true
-	})
-	return iterError
+	}
+	return nil
+}
+
+// partRowGroup is a row group of a part passed to the callback of a scan. It
+// holds a reference to the part until it is released.
+type partRowGroup struct {
+	dynparquet.DynamicRowGroup
+	part     parts.Part
+	released atomic.Bool
+}
+
+func (rg *partRowGroup) Release() {
+	if rg.released.CompareAndSwap(false, true) {
+		rg.part.Release()
+	}
+}
+
+// read records that a scan read the part of the given node in the given level.
+func (l *LSM) read(level SentinelType, node *Node) {
+	node.reads.Add(1)
+	l.reads[level].Add(1)
+}
+
+// levelState returns the current state of the given level.
+func (l *LSM) levelState(level SentinelType) LevelState {
+	return LevelState{
+		Level:  level,
+		Config: l.configs[level],
+		Size:   l.sizes[level].Load(),
+		Reads:  l.reads[level].Load(),
+	}
+}
+
+// compactHot starts a compaction if the merge policy compacts any level that
+// is compacted into the next level after it was read.
+func (l *LSM) compactHot() {
+	for i := 0; i < len(l.configs)-1; i++ {
+		if !l.policy.ShouldCompact(l.levelState(SentinelType(i))) {
+			continue
+		}
+		if l.compacting.CompareAndSwap(false, true) {
+			l.compactionWg.Add(1)
+			go l.compactInBackground()
+		}
+		return
+	}
 }
 
 // TODO: this should be changed to just retain the sentinel nodes in the lsm struct to do an O(1) lookup.
@@ -304,6 +546,20 @@ func (l *LSM) EnsureCompaction() error {
 	return l.compact(true /* ignoreSizes */)
 }
 
+// Release releases the parts of the index. The index must not be used
+// afterwards.
+func (l *LSM) Release() {
+	l.Lock()
+	defer l.Unlock()
+	l.releaseStaged()
+	l.levels.Iterate(func(node *Node) bool {
+		if node.part != nil {
+			node.part.Release()
+		}
+		return true
+	})
+}
+
 func (l *LSM) Rotate(level SentinelType, externalWriter func([]parts.Part) (parts.Part, int64, int64, error)) error {
 	for !l.compacting.CompareAndSwap(false, true) { // TODO: should backoff retry this probably
 		// Satisfy linter with a statement.
@@ -316,23 +572,25 @@ func (l *LSM) Rotate(level SentinelType, externalWriter func([]parts.Part) (part
 	}()
 
 	for i := 0; i < len(l.configs)-1; i++ {
-		if err := l.merge(SentinelType(i), nil); err != nil {
+		if err := l.merge(SentinelType(i), nil, nil, false); err != nil {
 			return err
 		}
 	}
-	return l.merge(level, externalWriter)
+	return l.merge(level, externalWriter, nil, false)
 }
 
 // Merge will merge the given level into an arrow record for the next level using the configured Compact function for the given level.
 // If this is the max level of the LSM an external writer must be provided to write the merged part elsewhere.
-func (l *LSM) merge(level SentinelType, externalWriter func([]parts.Part) (parts.Part, int64, int64, error)) error {
+// The parts are merged as grouped by the given merge policy, all parts are merged together if it is nil.
+// If hot, the level is merged because scans read it often and the policy plans the merge with the reads of every part.
+func (l *LSM) merge(level SentinelType, externalWriter func([]parts.Part) (parts.Part, int64, int64, error), policy MergePolicy, hot bool) error {
 	if int(level) > len(l.configs) {
 		return fmt.Errorf("level %d does not exist", level)
 	}
 	if int(level) == len(l.configs)-1 && externalWriter == nil {
 		return fmt.Errorf("cannot merge the last level without an external writer")
 	}
-	l.metrics.Compactions.WithLabelValues(level.String()).Inc()
+	start := time.Now()
 
 	nodeList := []*Node{}
 	var next *Node
@@ -362,71 +620,252 @@ func (l *LSM) merge(level SentinelType, externalWriter func([]parts.Part) (parts
 		return nil
 	}
 
-	var size int64
-	var compactedSize int64
-	var compacted []parts.Part
-	var err error
 	mergeList := make([]parts.Part, 0, len(nodeList))
 	for _, node := range nodeList {
 		mergeList = append(mergeList, node.part)
 	}
-	s := &Node{
-		sentinel: level + 1,
+	groups := [][]parts.Part{mergeList}
+	if policy != nil && externalWriter == nil {
+		var err error
+		state := l.levelState(level)
+		state.Hot = hot
+		state.PartReads = make(map[parts.Part]int64, len(nodeList))
+		for _, node := range nodeList {
+			state.PartReads[node.part] = node.reads.Load()
+		}
+		groups, err = planGroups(mergeList, policy.Plan(state, mergeList))
+		if err != nil {
+			return err
+		}
+		if len(groups) == 0 {
+			return nil
+		}
 	}
+	l.metrics.Compactions.WithLabelValues(level.String()).Inc()
 	if externalWriter != nil {
-		_, size, _, err = externalWriter(mergeList)
+		_, size, _, err := externalWriter(mergeList)
 		if err != nil {
 			return err
 		}
-		// Drop compacted files from list
-		if next != nil {
-			s.next.Store(next)
-		}
-	} else {
-		compacted, size, compactedSize, err = l.configs[level].Compact(mergeList, parts.WithCompactionLevel(int(level)+1))
+		// The written parts are kept until the index is released, so that
+		// they are still read until the external copy can be read instead.
+		l.logMerge(level, len(mergeList), size, 0, 0, time.Since(start))
+		return nil
+	}
+
+	var (
+		compacted           []parts.Part
+		merged              []parts.Part
+		size, compactedSize int64
+	)
+	for _, group := range groups {
+		groupCompacted, groupSize, groupCompactedSize, err := l.configs[level].Compact(group, parts.WithCompactionLevel(int(level)+1))
 		if err != nil {
+			for _, p := range compacted {
+				p.Release()
+			}
 			return err
 		}
+		compacted = append(compacted, groupCompacted...)
+		merged = append(merged, group...)
+		size += groupSize
+		compactedSize += groupCompactedSize
+	}
 
-		// Create new list for the compacted parts.
-		compactedList := &Node{
-			part: compacted[0],
+	mergedCompacted, next, absorbed, sizeDelta, err := l.mergeUndersized(level, compacted, next)
+	if err != nil {
+		for _, p := range compacted {
+			p.Release()
 		}
-		node := compactedList
-		for _, p := range compacted[1:] {
-			node.next.Store(&Node{
-				part: p,
-			})
-			node = node.next.Load()
+		return err
+	}
+	compacted = mergedCompacted
+	compactedSize += sizeDelta
+
+	// Create new list for the parts kept in the level followed by the
+	// compacted parts.
+	mergedParts := make(map[parts.Part]struct{}, len(merged))
+	for _, p := range merged {
+		mergedParts[p] = struct{}{}
+	}
+	s := &Node{
+		sentinel: level + 1,
+	}
+	head := s
+	var last *Node
+	for _, n := range nodeList {
+		if _, ok := mergedParts[n.part]; ok {
+			continue
+		}
+		kept := &Node{
+			part: n.part,
 		}
-		s.next.Store(compactedList)
-		if next != nil {
-			node.next.Store(next)
+		kept.reads.Store(n.reads.Load())
+		if last == nil {
+			head = kept
+		} else {
+			last.next.Store(kept)
 		}
-		l.sizes[level+1].Add(int64(compactedSize))
-		l.metrics.LevelSize.WithLabelValues(SentinelType(level + 1).String()).Set(float64(l.sizes[level+1].Load()))
+		last = kept
 	}
+	if last != nil {
+		last.next.Store(s)
+	}
+	node := s
+	for _, p := range compacted {
+		node.next.Store(&Node{
+			part: p,
+		})
+		node = node.next.Load()
+	}
+	if next != nil {
+		node.next.Store(next)
+	}
+	l.sizes[level+1].Add(int64(compactedSize))
+	l.metrics.LevelSize.WithLabelValues(SentinelType(level + 1).String()).Set(float64(l.sizes[level+1].Load()))
 
 	// Replace the compacted list with the new list
 	// find the node that points to the first node in our compacted list.
-	node := l.findNode(nodeList[0])
-	for !node.next.CompareAndSwap(nodeList[0], s) {
+	node = l.findNode(nodeList[0])
+	for !node.next.CompareAndSwap(nodeList[0], head) {
 		// This can happen at most once in the scenario where a new part is added to the L0 list while we are trying to replace it.
 		node = l.findNode(nodeList[0])
 	}
 	l.sizes[level].Add(-int64(size))
+	l.reads[level].Store(0)
 	l.metrics.LevelSize.WithLabelValues(level.String()).Set(float64(l.sizes[level].Load()))
+	l.logMerge(level, len(merged), size, len(compacted), compactedSize, time.Since(start))
+	if l.onCompaction != nil {
+		l.onCompaction(level+1, len(merged), compacted)
+	}
 
 	// release the old parts
 	l.Lock()
 	defer l.Unlock()
-	for _, part := range mergeList {
+	for _, part := range merged {
+		part.Release()
+	}
+	for _, part := range absorbed {
 		part.Release()
 	}
 
 	return nil
 }
 
+// mergeUndersized merges the runs of adjacent undersized parts among the
+// given parts compacted from the level and the parts at the head of the next
+// level that follow them, starting at next. It returns the resulting compacted
+// parts, the node following the parts of the next level that were merged, the
+// merged parts of the next level, which must be released once they are no
+// longer in the list, and the change in size of the next level. The compacted
+// parts that were merged are released.
+func (l *LSM) mergeUndersized(level SentinelType, compacted []parts.Part, next *Node) ([]parts.Part, *Node, []parts.Part, int64, error) {
+	if l.undersized == nil || len(compacted) == 0 {
+		return compacted, next, nil, 0, nil
+	}
+
+	// The parts of the next level were compacted before, so that only those
+	// adjacent to an undersized compacted part are merged. Parts inserted
+	// with a transaction are skipped since merging them would make them
+	// visible to every transaction.
+	candidates := slices.Clone(compacted)
+	var absorbed []parts.Part
+	if l.undersized(compacted[len(compacted)-1]) {
+		for next != nil && next.part != nil && next.part.TX() == 0 && l.undersized(next.part) {
+			candidates = append(candidates, next.part)
+			absorbed = append(absorbed, next.part)
+			next = next.next.Load()
+		}
+	}
+
+	var (
+		result    = make([]parts.Part, 0, len(candidates))
+		created   []parts.Part
+		replaced  []parts.Part
+		sizeDelta int64
+	)
+	for i := 0; i < len(candidates); {
+		j := i
+		for j < len(candidates) && l.undersized(candidates[j]) {
+			j++
+		}
+		if j-i < 2 {
+			result = append(result, candidates[i])
+			i = max(j, i+1)
+			continue
+		}
+		run := candidates[i:j]
+		runCompacted, runSize, runCompactedSize, err := l.configs[level].Compact(run, parts.WithCompactionLevel(int(level)+1))
+		if err != nil {
+			for _, p := range created {
+				p.Release()
+			}
+			return nil, nil, nil, 0, err
+		}
+		if l.onUndersizedMerge != nil {
+			l.onUndersizedMerge()
+		}
+		result = append(result, runCompacted...)
+		created = append(created, runCompacted...)
+		replaced = append(replaced, run...)
+		sizeDelta += runCompactedSize - runSize
+		i = j
+	}
+	if len(created) == 0 {
+		return compacted, next, nil, 0, nil
+	}
+
+	// The compacted parts that were merged were never in the list, unlike
+	// the merged parts of the next level.
+	merged := make(map[parts.Part]struct{}, len(replaced))
+	for _, p := range replaced {
+		merged[p] = struct{}{}
+	}
+	for _, p := range compacted {
+		if _, ok := merged[p]; ok {
+			p.Release()
+		}
+	}
+	return result, next, absorbed, sizeDelta, nil
+}
+
+// planGroups returns the non-empty groups of parts planned by a merge policy
+// for the given parts of a level. It returns an error if a group contains a
+// part that is not in the level or that is in another group.
+func planGroups(levelParts []parts.Part, planned [][]parts.Part) ([][]parts.Part, error) {
+	unplanned := make(map[parts.Part]struct{}, len(levelParts))
+	for _, p := range levelParts {
+		unplanned[p] = struct{}{}
+	}
+	groups := make([][]parts.Part, 0, len(planned))
+	for _, group := range planned {
+		if len(group) == 0 {
+			continue
+		}
+		for _, p := range group {
+			if _, ok := unplanned[p]; !ok {
+				return nil, fmt.Errorf("merge policy planned a part that is not in the level or in more than one group")
+			}
+			delete(unplanned, p)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// logMerge logs the merge of the parts of a level into the next level.
+func (l *LSM) logMerge(lvl SentinelType, parts int, size int64, compactedParts int, compactedSize int64, duration time.Duration) {
+	level.Debug(l.logger).Log(
+		"msg", "compacted level",
+		"level", lvl,
+		"parts", parts,
+		"size", size,
+		"compacted_parts", compactedParts,
+		"compacted_size", compactedSize,
+		"duration", duration,
+	)
+}
+
 // compact is a cascading compaction routine. It will start at the lowest level and compact until the next level is either the max level or the next level does not exceed the max size.
 // compact can not be run concurrently.
 func (l *LSM) compact(ignoreSizes bool) error {
@@ -437,8 +876,13 @@ func (l *LSM) compact(ignoreSizes bool) error {
 	}()
 
 	for i := 0; i < len(l.configs)-1; i++ {
-		if ignoreSizes || l.sizes[i].Load() >= l.configs[i].MaxSize {
-			if err := l.merge(SentinelType(i), nil); err != nil {
+		state := l.levelState(SentinelType(i))
+		if ignoreSizes || l.policy.ShouldCompact(state) {
+			hot := !ignoreSizes && state.Size < state.Config.MaxSize
+			if hot {
+				l.metrics.HotCompactions.WithLabelValues(SentinelType(i).String()).Inc()
+			}
+			if err := l.merge(SentinelType(i), nil, l.policy, hot); err != nil {
 				level.Error(l.logger).Log("msg", "failed to merge level", "level", i, "err", err)
 				return err
 			}
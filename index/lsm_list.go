@@ -25,12 +25,19 @@ type Node struct {
 	part parts.Part
 
 	sentinel SentinelType // sentinel nodes contain no parts, and are to indicate the start of a new sub list
+
+	reads atomic.Int64
 }
 
 func (n *Node) Part() parts.Part {
 	return n.part
 }
 
+// Reads returns the number of scans that read the part of the node.
+func (n *Node) Reads() int64 {
+	return n.reads.Load()
+}
+
 func (n *Node) String() string {
 	if n.part == nil {
 		if n.next.Load() == nil {
@@ -5,10 +5,12 @@ import (
 	"context"
 	"errors"
 	"io"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/util"
 	"github.com/parquet-go/parquet-go"
 	"github.com/stretchr/testify/require"
 
@@ -117,19 +119,19 @@ func Test_LSM_Basic(t *testing.T) {
 	lsm.Add(1, r)
 	lsm.Add(1, r)
 	check(t, lsm, 3, 0)
-	require.NoError(t, lsm.merge(L0, nil))
+	require.NoError(t, lsm.merge(L0, nil, nil, false))
 	check(t, lsm, 0, 1)
 	lsm.Add(1, r)
 	check(t, lsm, 1, 1)
 	lsm.Add(1, r)
 	check(t, lsm, 2, 1)
-	require.NoError(t, lsm.merge(L0, nil))
+	require.NoError(t, lsm.merge(L0, nil, nil, false))
 	check(t, lsm, 0, 2)
 	lsm.Add(1, r)
 	check(t, lsm, 1, 2)
-	require.NoError(t, lsm.merge(L1, nil))
+	require.NoError(t, lsm.merge(L1, nil, nil, false))
 	check(t, lsm, 1, 1)
-	require.NoError(t, lsm.merge(L0, nil))
+	require.NoError(t, lsm.merge(L0, nil, nil, false))
 	check(t, lsm, 0, 2)
 }
 
@@ -150,9 +152,9 @@ func Test_LSM_DuplicateSentinel(t *testing.T) {
 	lsm.Add(1, r)
 	lsm.Add(1, r)
 	check(t, lsm, 3, 0)
-	require.NoError(t, lsm.merge(L0, nil))
+	require.NoError(t, lsm.merge(L0, nil, nil, false))
 	check(t, lsm, 0, 1)
-	require.NoError(t, lsm.merge(L0, nil))
+	require.NoError(t, lsm.merge(L0, nil, nil, false))
 	check(t, lsm, 0, 1)
 }
 
@@ -174,6 +176,232 @@ func Test_LSM_Compaction(t *testing.T) {
 	}, time.Second, 5*time.Millisecond)
 }
 
+func Test_LSM_CompactionBacklog(t *testing.T) {
+	t.Parallel()
+	lsm, err := NewLSM("test", nil, []*LevelConfig{
+		{Level: L0, MaxSize: 1, Compact: parquetCompaction},
+		{Level: L1, MaxSize: 1},
+	})
+	require.NoError(t, err)
+
+	samples := dynparquet.NewTestSamples()
+	r, err := samples.ToRecord()
+	require.NoError(t, err)
+
+	require.Equal(t, int64(0), lsm.CompactionBacklog())
+	// InsertPart does not trigger a compaction.
+	part := parts.NewArrowPart(1, r, 100, nil)
+	lsm.InsertPart(L0, part)
+	require.Equal(t, int64(99), lsm.CompactionBacklog())
+	// The last level is never compacted.
+	lsm.InsertPart(L1, part)
+	require.Equal(t, int64(99), lsm.CompactionBacklog())
+}
+
+func Test_LSM_HotCompaction(t *testing.T) {
+	t.Parallel()
+	lsm, err := NewLSM("test", nil, []*LevelConfig{
+		{Level: L0, MaxSize: 1024 * 1024 * 1024, HotReads: 2, Compact: parquetCompaction},
+		{Level: L1, MaxSize: 1024 * 1024 * 1024},
+	})
+	require.NoError(t, err)
+
+	samples := dynparquet.NewTestSamples()
+	r, err := samples.ToRecord()
+	require.NoError(t, err)
+
+	lsm.Add(1, r)
+	scan := func() {
+		require.NoError(t, lsm.Scan(context.Background(), "", nil, nil, 1, func(ctx context.Context, v any) error {
+			if r, ok := v.(arrow.Record); ok {
+				r.Release()
+			}
+			return nil
+		}))
+	}
+
+	// A single read does not make the level hot.
+	scan()
+	lsm.WaitForPendingCompactions()
+	require.NotZero(t, lsm.sizes[L0].Load())
+	lsm.Iterate(func(node *Node) bool {
+		if node.Part() != nil {
+			require.Equal(t, int64(1), node.Reads())
+		}
+		return true
+	})
+
+	scan()
+	lsm.WaitForPendingCompactions()
+	require.Zero(t, lsm.sizes[L0].Load())
+	require.NotZero(t, lsm.sizes[L1].Load())
+	require.Zero(t, lsm.reads[L0].Load())
+}
+
+func Test_LSM_HotCompactionColdParts(t *testing.T) {
+	t.Parallel()
+	lsm, err := NewLSM("test", nil, []*LevelConfig{
+		{Level: L0, MaxSize: 1024 * 1024 * 1024, HotReads: 2, Compact: parquetCompaction},
+		{Level: L1, MaxSize: 1024 * 1024 * 1024},
+	})
+	require.NoError(t, err)
+
+	samples := dynparquet.NewTestSamples()
+	r, err := samples.ToRecord()
+	require.NoError(t, err)
+	lsm.Add(1, r)
+	lsm.Add(2, r)
+
+	// Only the part of tx 2 is read.
+	ctx := WithPartFilter(context.Background(), func(p parts.Part) bool {
+		return p.TX() == 2
+	})
+	for i := 0; i < 2; i++ {
+		require.NoError(t, lsm.Scan(ctx, "", nil, nil, 2, func(ctx context.Context, v any) error {
+			if r, ok := v.(arrow.Record); ok {
+				r.Release()
+			}
+			return nil
+		}))
+	}
+	lsm.WaitForPendingCompactions()
+
+	// The hot part is compacted into L1, the cold part is left in L0.
+	var l0, l1 []uint64
+	level := L0
+	lsm.Iterate(func(node *Node) bool {
+		switch {
+		case node.Part() == nil:
+			level = node.sentinel
+		case level == L0:
+			l0 = append(l0, node.Part().TX())
+		default:
+			l1 = append(l1, node.Part().TX())
+		}
+		return true
+	})
+	require.Equal(t, []uint64{1}, l0)
+	require.Len(t, l1, 1)
+	require.Zero(t, lsm.reads[L0].Load())
+}
+
+func Test_LSM_ScanRetainsParts(t *testing.T) {
+	t.Parallel()
+	lsm, err := NewLSM("test", nil, []*LevelConfig{
+		{Level: L0, MaxSize: 1024 * 1024 * 1024, Compact: parquetCompaction},
+		{Level: L1, MaxSize: 1024 * 1024 * 1024},
+	})
+	require.NoError(t, err)
+
+	schema := dynparquet.NewSampleSchema()
+	samples := dynparquet.NewTestSamples()
+	buf, err := samples.ToBuffer(schema)
+	require.NoError(t, err)
+	b := &bytes.Buffer{}
+	require.NoError(t, schema.SerializeBuffer(b, buf))
+	serBuf, err := dynparquet.ReaderFromBytes(b.Bytes())
+	require.NoError(t, err)
+	var released atomic.Int64
+	lsm.InsertPart(L0, parts.NewParquetPart(1, serBuf, parts.WithRelease(func() {
+		released.Add(1)
+	})))
+
+	// The level is compacted while it is scanned, the merged part is only
+	// released once the row groups read by the scan are released.
+	var rowGroups []any
+	require.NoError(t, lsm.Scan(context.Background(), "", nil, nil, 1, func(ctx context.Context, v any) error {
+		rowGroups = append(rowGroups, v)
+		if len(rowGroups) == 1 {
+			require.NoError(t, lsm.merge(L0, nil, nil, false))
+		}
+		return nil
+	}))
+	require.Len(t, rowGroups, 1)
+	require.Zero(t, lsm.sizes[L0].Load())
+	require.Zero(t, released.Load())
+
+	require.Equal(t, int64(len(samples)), rowGroups[0].(dynparquet.DynamicRowGroup).NumRows())
+	rowGroups[0].(interface{ Release() }).Release()
+	require.Equal(t, int64(1), released.Load())
+	// Releasing a row group again does not release the part again.
+	rowGroups[0].(interface{ Release() }).Release()
+	require.Equal(t, int64(1), released.Load())
+}
+
+// pairMergePolicy compacts L0 once it holds at least two parts and merges the
+// oldest parts in pairs.
+type pairMergePolicy struct {
+	LeveledMergePolicy
+	partSize int64
+}
+
+func (p pairMergePolicy) ShouldCompact(level LevelState) bool {
+	return level.Level == L0 && level.Size >= 2*p.partSize
+}
+
+func (pairMergePolicy) Plan(_ LevelState, levelParts []parts.Part) [][]parts.Part {
+	var groups [][]parts.Part
+	for i := len(levelParts) - 1; i > 0; i -= 2 {
+		groups = append(groups, []parts.Part{levelParts[i], levelParts[i-1]})
+	}
+	return groups
+}
+
+func Test_LSM_MergePolicy(t *testing.T) {
+	t.Parallel()
+	samples := dynparquet.NewTestSamples()
+	r, err := samples.ToRecord()
+	require.NoError(t, err)
+
+	lsm, err := NewLSM("test", nil, []*LevelConfig{
+		{Level: L0, MaxSize: 1024 * 1024 * 1024, Compact: parquetCompaction},
+		{Level: L1, MaxSize: 1024 * 1024 * 1024},
+	}, LSMWithMergePolicy(pairMergePolicy{partSize: util.TotalRecordSize(r)}))
+	require.NoError(t, err)
+
+	lsm.Add(1, r)
+	lsm.WaitForPendingCompactions()
+	check(t, lsm, 1, 0)
+
+	// Two parts are merged into one, the third part is kept in L0.
+	lsm.Add(1, r)
+	lsm.Add(1, r)
+	require.NoError(t, lsm.EnsureCompaction())
+	check(t, lsm, 1, 1)
+	require.Equal(t, util.TotalRecordSize(r), lsm.sizes[L0].Load())
+
+	lsm.Add(1, r)
+	require.NoError(t, lsm.EnsureCompaction())
+	check(t, lsm, 0, 2)
+	require.Zero(t, lsm.sizes[L0].Load())
+}
+
+func Test_LSM_MergePolicyInvalidPlan(t *testing.T) {
+	t.Parallel()
+	samples := dynparquet.NewTestSamples()
+	r, err := samples.ToRecord()
+	require.NoError(t, err)
+
+	lsm, err := NewLSM("test", nil, []*LevelConfig{
+		{Level: L0, MaxSize: 1024 * 1024 * 1024, Compact: parquetCompaction},
+		{Level: L1, MaxSize: 1024 * 1024 * 1024},
+	}, LSMWithMergePolicy(duplicateMergePolicy{}))
+	require.NoError(t, err)
+
+	lsm.Add(1, r)
+	require.Error(t, lsm.EnsureCompaction())
+	check(t, lsm, 1, 0)
+}
+
+// duplicateMergePolicy plans every part of a level twice.
+type duplicateMergePolicy struct {
+	LeveledMergePolicy
+}
+
+func (duplicateMergePolicy) Plan(_ LevelState, levelParts []parts.Part) [][]parts.Part {
+	return [][]parts.Part{levelParts, levelParts}
+}
+
 func Test_LSM_CascadeCompaction(t *testing.T) {
 	t.Parallel()
 	lsm, err := NewLSM("test", nil, []*LevelConfig{
@@ -206,3 +434,87 @@ func Test_LSM_CascadeCompaction(t *testing.T) {
 			lsm.sizes[4].Load() != 0
 	}, time.Second, 5*time.Millisecond)
 }
+
+func Test_LSM_WriteBuffer(t *testing.T) {
+	t.Parallel()
+	merge := func(compact []parts.Part, options ...parts.Option) ([]parts.Part, int64, int64, error) {
+		b := &bytes.Buffer{}
+		size, err := compactParts(b, compact)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		buf, err := dynparquet.ReaderFromBytes(b.Bytes())
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		return []parts.Part{parts.NewParquetPart(0, buf, options...)}, size, int64(b.Len()), nil
+	}
+	lsm, err := NewLSM("test", nil, []*LevelConfig{
+		{Level: L0, MaxSize: 1024 * 1024 * 1024, Compact: parquetCompaction},
+		{Level: L1, MaxSize: 1024 * 1024 * 1024},
+	}, LSMWithWriteBuffer(merge))
+	require.NoError(t, err)
+
+	samples := dynparquet.NewTestSamples()
+	r, err := samples.ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+
+	lsm.Stage(1, r)
+	lsm.Stage(1, r)
+	lsm.Stage(2, r)
+	staged := lsm.Stage(3, r)
+	require.Equal(t, staged, lsm.StagedSize())
+	require.Equal(t, staged, lsm.Size())
+
+	// Staged records are scanned like the records in L0.
+	check(t, lsm, 3, 0)
+	require.Equal(t, int64(2*len(samples)), scanRows(t, lsm, 1))
+
+	// Only the records up to the watermark are merged, the records of each
+	// transaction into parts of the transaction. A read at an older
+	// transaction reads the same records as before the flush, neither losing
+	// its own nor reading the newer ones.
+	lsm.Flush(2)
+	check(t, lsm, 0, 2)
+	require.Less(t, lsm.StagedSize(), staged)
+	require.Equal(t, int64(2*len(samples)), scanRows(t, lsm, 1))
+	require.Equal(t, int64(3*len(samples)), scanRows(t, lsm, 2))
+	var txs []uint64
+	lsm.Iterate(func(node *Node) bool {
+		if node.part != nil {
+			txs = append(txs, node.part.TX())
+		}
+		return true
+	})
+	require.ElementsMatch(t, []uint64{1, 2}, txs)
+
+	// Flushing all adds the remaining records as they are.
+	lsm.FlushAll(2)
+	require.Zero(t, lsm.StagedSize())
+	require.NoError(t, lsm.Scan(context.Background(), "", nil, nil, 3, func(_ context.Context, v any) error {
+		if r, ok := v.(arrow.Record); ok {
+			require.Equal(t, int64(len(samples)), r.NumRows())
+			r.Release()
+		}
+		return nil
+	}))
+}
+
+// scanRows returns the number of rows the index scans at the given
+// transaction.
+func scanRows(t *testing.T, lsm *LSM, tx uint64) int64 {
+	t.Helper()
+	var rows int64
+	require.NoError(t, lsm.Scan(context.Background(), "", nil, nil, tx, func(_ context.Context, v any) error {
+		switch v := v.(type) {
+		case arrow.Record:
+			rows += v.NumRows()
+			v.Release()
+		case dynparquet.DynamicRowGroup:
+			rows += v.NumRows()
+		}
+		return nil
+	}))
+	return rows
+}
@@ -0,0 +1,78 @@
+package index
+
+import (
+	"github.com/polarsignals/frostdb/parts"
+)
+
+// MergePolicy decides when a level of an LSM index is compacted into the next
+// level and which of its parts are merged together, e.g. to implement
+// time-bucketed or tenant-aware compaction strategies. A MergePolicy is used
+// concurrently by the indexes of all tables and must be safe for concurrent
+// use.
+type MergePolicy interface {
+	// ShouldCompact reports whether the level is compacted into the next
+	// level. It is called after records are added to L0 and after scans read
+	// the parts of a level. The last level is never compacted.
+	ShouldCompact(level LevelState) bool
+	// Plan groups the parts of a level that is compacted. The parts of each
+	// group are merged into new parts of the next level, so the groups
+	// determine the size of the compacted parts. Parts that are not part of
+	// any group are kept in the level. Plan is also called when compaction is
+	// forced, e.g. by LSM.EnsureCompaction, but not when the index is
+	// rotated, which always merges all parts.
+	Plan(level LevelState, parts []parts.Part) [][]parts.Part
+}
+
+// LevelState is the state of a level of an LSM index.
+type LevelState struct {
+	Level  SentinelType
+	Config *LevelConfig
+	// Size is the size of the parts of the level in bytes.
+	Size int64
+	// Reads is the number of parts of the level read by scans since the
+	// level was last compacted.
+	Reads int64
+	// Hot is set when the level is compacted before it reached its max size
+	// because it was read often, see LevelConfig.HotReads.
+	Hot bool
+	// PartReads is the number of times scans read each part of the level
+	// since the part was added to it. Only set when planning a merge.
+	PartReads map[parts.Part]int64
+}
+
+// LeveledMergePolicy is the default merge policy. It compacts a level once it
+// reaches its max size and merges all parts of the level together. A level
+// that is hot, see LevelConfig.HotReads, is compacted earlier, but only its hot
+// parts are merged while the cold parts are left in the level.
+type LeveledMergePolicy struct{}
+
+func (LeveledMergePolicy) ShouldCompact(level LevelState) bool {
+	if level.Size >= level.Config.MaxSize {
+		return true
+	}
+	hotReads := level.Config.HotReads
+	return hotReads > 0 && level.Reads >= hotReads && level.Size > 0
+}
+
+func (LeveledMergePolicy) Plan(level LevelState, levelParts []parts.Part) [][]parts.Part {
+	if level.Hot {
+		return [][]parts.Part{hotParts(level, levelParts)}
+	}
+	return [][]parts.Part{levelParts}
+}
+
+// hotParts returns the parts of the level that scans read at least as often as
+// the average part of the level.
+func hotParts(level LevelState, levelParts []parts.Part) []parts.Part {
+	var total int64
+	for _, p := range levelParts {
+		total += level.PartReads[p]
+	}
+	hot := make([]parts.Part, 0, len(levelParts))
+	for _, p := range levelParts {
+		if reads := level.PartReads[p]; reads > 0 && reads*int64(len(levelParts)) >= total {
+			hot = append(hot, p)
+		}
+	}
+	return hot
+}
@@ -0,0 +1,238 @@
+package index
+
+import (
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/util"
+	"github.com/go-kit/log/level"
+
+	"github.com/polarsignals/frostdb/parts"
+)
+
+// writeBuffer stages the records added to the index until they are flushed
+// into L0 in batches. Records are appended to one of several shards, picked
+// round robin, so that concurrent writers rarely contend on the same lock.
+type writeBuffer struct {
+	shards []writeBufferShard
+	next   atomic.Uint64
+	// size is the total size of the staged records in bytes.
+	size atomic.Int64
+
+	// merge merges a batch of staged records into sorted parts.
+	merge func([]parts.Part, ...parts.Option) ([]parts.Part, int64, int64, error)
+	// flushMtx serializes flushes.
+	flushMtx sync.Mutex
+}
+
+type writeBufferShard struct {
+	sync.Mutex
+	// nodes hold the staged records as unlinked L0 nodes, so that scans read
+	// them like the records in L0.
+	nodes []*Node
+}
+
+// LSMWithWriteBuffer makes Stage stage records in a write buffer instead of
+// adding them to L0. Staged records are visible to scans, and are flushed to
+// L0 in batches by Flush, which sorts and merges the records of each
+// transaction into parts using the given merge function. It is called with
+// the options of the parts it returns.
+func LSMWithWriteBuffer(merge func([]parts.Part, ...parts.Option) ([]parts.Part, int64, int64, error)) LSMOption {
+	return func(l *LSM) {
+		l.writeBuffer = &writeBuffer{
+			shards: make([]writeBufferShard, runtime.GOMAXPROCS(0)),
+			merge:  merge,
+		}
+	}
+}
+
+// Stage stages the record added at the given transaction in the write buffer
+// of the index and returns the total size of the staged records, see
+// LSMWithWriteBuffer. The record is added to L0 right away if the index has no
+// write buffer.
+func (l *LSM) Stage(tx uint64, record arrow.Record) int64 {
+	b := l.writeBuffer
+	if b == nil {
+		l.Add(tx, record)
+		return 0
+	}
+
+	record.Retain()
+	size := util.TotalRecordSize(record)
+	node := &Node{part: parts.NewArrowPart(tx, record, uint64(size), l.schema, parts.WithCompactionLevel(int(L0)))}
+	shard := &b.shards[b.next.Add(1)%uint64(len(b.shards))]
+	shard.Lock()
+	shard.nodes = append(shard.nodes, node)
+	shard.Unlock()
+	return b.size.Add(size)
+}
+
+// StagedSize returns the total size of the records staged in the write buffer
+// in bytes.
+func (l *LSM) StagedSize() int64 {
+	if l.writeBuffer == nil {
+		return 0
+	}
+	return l.writeBuffer.size.Load()
+}
+
+// Flush merges the staged records added at or before the given watermark into
+// L0. The records of each transaction are merged into parts of that
+// transaction, so that reads at any transaction, e.g. of older snapshots,
+// read the same records before and after the flush. Records added after the
+// watermark, whose transactions may not have completed yet, stay staged.
+func (l *LSM) Flush(watermark uint64) {
+	l.flush(watermark, false)
+}
+
+// FlushAll flushes the write buffer like Flush and then adds the records added
+// after the watermark to L0 one by one, so that no records remain staged. It
+// is called before the parts of the index are read without a scan, e.g. to
+// persist or snapshot them.
+func (l *LSM) FlushAll(watermark uint64) {
+	l.flush(watermark, true)
+}
+
+func (l *LSM) flush(watermark uint64, all bool) {
+	b := l.writeBuffer
+	if b == nil {
+		return
+	}
+	b.flushMtx.Lock()
+	defer b.flushMtx.Unlock()
+
+	var (
+		batch   []*Node
+		pending []*Node
+	)
+	for i := range b.shards {
+		shard := &b.shards[i]
+		shard.Lock()
+		for _, node := range shard.nodes {
+			if node.part.TX() <= watermark {
+				batch = append(batch, node)
+			} else if all {
+				pending = append(pending, node)
+			}
+		}
+		shard.Unlock()
+	}
+	if len(batch) == 0 && len(pending) == 0 {
+		return
+	}
+
+	txs := make(map[uint64][]*Node)
+	for _, node := range batch {
+		txs[node.part.TX()] = append(txs[node.part.TX()], node)
+	}
+	order := make([]uint64, 0, len(txs))
+	for tx := range txs {
+		order = append(order, tx)
+	}
+	slices.Sort(order)
+
+	var flushed []parts.Part
+	for _, tx := range order {
+		staged := make([]parts.Part, 0, len(txs[tx]))
+		for _, node := range txs[tx] {
+			staged = append(staged, node.part)
+		}
+		merged, _, _, err := b.merge(staged, parts.WithCompactionLevel(int(L0)), parts.WithTX(tx))
+		if err != nil {
+			// Fall back to adding the records one by one, they are merged
+			// when L0 is compacted.
+			level.Warn(l.logger).Log("msg", "failed to merge staged records", "tx", tx, "err", err)
+			pending = append(pending, txs[tx]...)
+			continue
+		}
+		flushed = append(flushed, merged...)
+	}
+	for _, node := range pending {
+		part, _ := l.newL0Part(node.part.TX(), node.part.Record())
+		flushed = append(flushed, part)
+	}
+
+	// The flushed parts replace the staged records under the lock of the
+	// index, so that scans read either of them but never both.
+	remove := make(map[*Node]struct{}, len(batch)+len(pending))
+	for _, node := range batch {
+		remove[node] = struct{}{}
+	}
+	for _, node := range pending {
+		remove[node] = struct{}{}
+	}
+	var flushedSize, stagedSize int64
+	l.Lock()
+	for _, part := range flushed {
+		l.levels.Prepend(part)
+		flushedSize += part.Size()
+	}
+	for i := range b.shards {
+		shard := &b.shards[i]
+		shard.Lock()
+		kept := shard.nodes[:0]
+		for _, node := range shard.nodes {
+			if _, ok := remove[node]; ok {
+				stagedSize += node.part.Size()
+				continue
+			}
+			kept = append(kept, node)
+		}
+		clear(shard.nodes[len(kept):])
+		shard.nodes = kept
+		shard.Unlock()
+	}
+	l.Unlock()
+	b.size.Add(-stagedSize)
+	for node := range remove {
+		node.part.Release()
+	}
+	l.addedToL0(flushedSize)
+}
+
+// retainStaged retains the staged records visible at the given transaction
+// that are kept by the given part filter, if any. The index must be locked.
+func (l *LSM) retainStaged(tx uint64, keep func(parts.Part) bool) []scannedNode {
+	b := l.writeBuffer
+	if b == nil {
+		return nil
+	}
+	var scanned []scannedNode
+	for i := range b.shards {
+		shard := &b.shards[i]
+		shard.Lock()
+		for _, node := range shard.nodes {
+			if node.part.TX() > tx {
+				continue
+			}
+			if keep != nil && !keep(node.part) {
+				continue
+			}
+			node.part.Retain()
+			scanned = append(scanned, scannedNode{level: L0, node: node})
+		}
+		shard.Unlock()
+	}
+	return scanned
+}
+
+// releaseStaged releases the records staged in the write buffer.
+func (l *LSM) releaseStaged() {
+	b := l.writeBuffer
+	if b == nil {
+		return
+	}
+	for i := range b.shards {
+		shard := &b.shards[i]
+		shard.Lock()
+		for _, node := range shard.nodes {
+			node.part.Release()
+		}
+		shard.nodes = nil
+		shard.Unlock()
+	}
+	b.size.Store(0)
+}
@@ -0,0 +1,453 @@
+// Package csv loads CSV data into frostdb tables, e.g. to load historical
+// extracts.
+package csv
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/pqarrow/convert"
+)
+
+// Table is the table CSV data is loaded into, e.g. a *frostdb.Table.
+type Table interface {
+	Schema() *dynparquet.Schema
+	InsertRecord(ctx context.Context, record arrow.Record) (uint64, error)
+}
+
+// Column maps a column of the CSV data to a column of the table.
+type Column struct {
+	// Source is the name of the column in the header of the CSV data.
+	Source string
+	// Target is the name of the column of the table. Dynamic columns are
+	// named by the name of the dynamic column and the label, e.g.
+	// "labels.node".
+	Target string
+	// Layout is the time layout of the values of an int64 column that
+	// holds timestamps, see time.Parse. If it is empty, values that are not
+	// integers are parsed as RFC 3339 timestamps.
+	Layout string
+	// Unit is the unit timestamps are converted to. The default is
+	// milliseconds.
+	Unit time.Duration
+}
+
+// BadRowPolicy determines what happens to rows that can't be loaded, e.g.
+// because a value can't be parsed.
+type BadRowPolicy int
+
+const (
+	// BadRowFail fails the load on the first bad row. This is the default.
+	BadRowFail BadRowPolicy = iota
+	// BadRowSkip skips bad rows.
+	BadRowSkip
+)
+
+// BadRow is a row that could not be loaded.
+type BadRow struct {
+	// Line is the line of the row in the CSV data.
+	Line   int
+	Record []string
+	Err    error
+}
+
+// Progress is the progress of a load.
+type Progress struct {
+	// Rows is the number of rows inserted into the table.
+	Rows int64
+	// SkippedRows is the number of bad rows that were skipped.
+	SkippedRows int64
+	// Bytes is the number of bytes read from the CSV data.
+	Bytes int64
+	// Tx is the transaction of the last insert.
+	Tx uint64
+}
+
+type options struct {
+	pool       memory.Allocator
+	mapping    []Column
+	comma      rune
+	batchSize  int
+	policy     BadRowPolicy
+	onBadRow   func(BadRow)
+	onProgress func(Progress)
+}
+
+type Option func(*options)
+
+// WithMapping maps the given columns of the CSV data to columns of the table.
+// By default, the columns of the CSV data are mapped to the columns of the
+// table of the same name, and columns that the table does not have are
+// ignored.
+func WithMapping(columns ...Column) Option {
+	return func(o *options) {
+		o.mapping = columns
+	}
+}
+
+// WithComma sets the field delimiter of the CSV data. The default is ','.
+func WithComma(comma rune) Option {
+	return func(o *options) {
+		o.comma = comma
+	}
+}
+
+// WithBatchSize sets the number of rows inserted into the table at once. The
+// default is 8192.
+func WithBatchSize(n int) Option {
+	return func(o *options) {
+		o.batchSize = n
+	}
+}
+
+// WithBadRowPolicy sets the policy for rows that can't be loaded.
+func WithBadRowPolicy(policy BadRowPolicy) Option {
+	return func(o *options) {
+		o.policy = policy
+	}
+}
+
+// WithBadRowHandler registers a function that is called with every bad row
+// that is skipped, e.g. to log it or write it to a dead letter file.
+func WithBadRowHandler(handler func(BadRow)) Option {
+	return func(o *options) {
+		o.onBadRow = handler
+	}
+}
+
+// WithProgress registers a function that is called after every batch of rows
+// is inserted.
+func WithProgress(fn func(Progress)) Option {
+	return func(o *options) {
+		o.onProgress = fn
+	}
+}
+
+// WithAllocator sets the allocator of the inserted records.
+func WithAllocator(pool memory.Allocator) Option {
+	return func(o *options) {
+		o.pool = pool
+	}
+}
+
+// Load streams the CSV data read from r into the table. The first row of the
+// data must be a header with the names of the columns. The values are parsed
+// according to the type of the table column they are mapped to. Empty values
+// of nullable columns are null. The rows are inserted in batches, so if the
+// load fails, the batches before the failure are inserted.
+func Load(ctx context.Context, r io.Reader, table Table, opts ...Option) (Progress, error) {
+	o := options{
+		pool:      memory.DefaultAllocator,
+		comma:     ',',
+		batchSize: 8192,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	counter := &countingReader{r: r}
+	reader := csv.NewReader(counter)
+	reader.Comma = o.comma
+	reader.ReuseRecord = true
+	// Rows may omit trailing columns that are not loaded.
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return Progress{}, fmt.Errorf("read header: %w", err)
+	}
+
+	columns, err := resolveColumns(table.Schema(), header, o.mapping)
+	if err != nil {
+		return Progress{}, err
+	}
+	fields := make([]arrow.Field, 0, len(columns))
+	for _, col := range columns {
+		fields = append(fields, col.field)
+	}
+	b := array.NewRecordBuilder(o.pool, arrow.NewSchema(fields, nil))
+	defer b.Release()
+
+	var (
+		progress Progress
+		rows     int
+		values   = make([]any, len(columns))
+	)
+	flush := func() error {
+		if rows == 0 {
+			return nil
+		}
+		record := b.NewRecord()
+		defer record.Release()
+		tx, err := table.InsertRecord(ctx, record)
+		if err != nil {
+			return err
+		}
+		progress.Rows += int64(rows)
+		progress.Bytes = counter.n
+		progress.Tx = tx
+		rows = 0
+		if o.onProgress != nil {
+			o.onProgress(progress)
+		}
+		return nil
+	}
+	badRow := func(row BadRow) error {
+		if o.policy != BadRowSkip {
+			return fmt.Errorf("line %d: %w", row.Line, row.Err)
+		}
+		progress.SkippedRows++
+		if o.onBadRow != nil {
+			o.onBadRow(row)
+		}
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return progress, err
+			}
+			if err := badRow(BadRow{Line: parseErr.StartLine, Record: clone(record), Err: err}); err != nil {
+				return progress, err
+			}
+			continue
+		}
+
+		if err := parseRow(columns, record, values); err != nil {
+			line, _ := reader.FieldPos(0)
+			if err := badRow(BadRow{Line: line, Record: clone(record), Err: err}); err != nil {
+				return progress, err
+			}
+			continue
+		}
+		for i, v := range values {
+			appendValue(b.Field(i), v)
+		}
+		rows++
+		if rows >= o.batchSize {
+			if err := flush(); err != nil {
+				return progress, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return progress, err
+	}
+	progress.Bytes = counter.n
+	return progress, nil
+}
+
+// column is a column of the CSV data mapped to a column of the table.
+type column struct {
+	Column
+	// index is the index of the column in the CSV records.
+	index    int
+	field    arrow.Field
+	nullable bool
+}
+
+// resolveColumns resolves the columns of the CSV data with the given header
+// that are loaded into the table with the given schema.
+func resolveColumns(schema *dynparquet.Schema, header []string, mapping []Column) ([]column, error) {
+	indices := make(map[string]int, len(header))
+	for i, name := range header {
+		indices[name] = i
+	}
+	if mapping == nil {
+		for _, name := range header {
+			if _, ok := columnDefinition(schema, name); ok {
+				mapping = append(mapping, Column{Source: name, Target: name})
+			}
+		}
+	}
+
+	columns := make([]column, 0, len(mapping))
+	targets := make(map[string]struct{}, len(mapping))
+	for _, m := range mapping {
+		index, ok := indices[m.Source]
+		if !ok {
+			return nil, fmt.Errorf("column %q is not in the header", m.Source)
+		}
+		if _, ok := targets[m.Target]; ok {
+			return nil, fmt.Errorf("column %q is mapped more than once", m.Target)
+		}
+		targets[m.Target] = struct{}{}
+		def, ok := columnDefinition(schema, m.Target)
+		if !ok {
+			return nil, fmt.Errorf("table has no column %q", m.Target)
+		}
+		typ, err := convert.ParquetNodeToType(def.StorageLayout)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", m.Target, err)
+		}
+		switch typ.(type) {
+		case *arrow.BinaryType, *arrow.DictionaryType, *arrow.Int64Type, *arrow.Uint64Type,
+			*arrow.Float64Type, *arrow.Float32Type, *arrow.BooleanType:
+		default:
+			return nil, fmt.Errorf("column %q: unsupported type %s", m.Target, typ)
+		}
+		if m.Unit == 0 {
+			m.Unit = time.Millisecond
+		}
+		nullable := def.Dynamic || def.StorageLayout.Optional()
+		columns = append(columns, column{
+			Column:   m,
+			index:    index,
+			field:    arrow.Field{Name: m.Target, Type: typ, Nullable: nullable},
+			nullable: nullable,
+		})
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		return columns[i].Target < columns[j].Target
+	})
+	return columns, nil
+}
+
+// columnDefinition returns the definition of the column of the schema with the
+// given name, which is the definition of the dynamic column for the columns
+// of a dynamic column.
+func columnDefinition(schema *dynparquet.Schema, name string) (dynparquet.ColumnDefinition, bool) {
+	if def, ok := schema.ColumnByName(name); ok && !def.Dynamic {
+		return def, true
+	}
+	dynamic, label, ok := strings.Cut(name, ".")
+	if !ok || label == "" {
+		return dynparquet.ColumnDefinition{}, false
+	}
+	def, ok := schema.ColumnByName(dynamic)
+	if !ok || !def.Dynamic {
+		return dynparquet.ColumnDefinition{}, false
+	}
+	return def, true
+}
+
+// parseRow parses the values of the columns of the record into values.
+func parseRow(columns []column, record []string, values []any) error {
+	for i, col := range columns {
+		if col.index >= len(record) {
+			return fmt.Errorf("column %q: missing value", col.Source)
+		}
+		v, err := col.parse(record[col.index])
+		if err != nil {
+			return fmt.Errorf("column %q: %w", col.Source, err)
+		}
+		values[i] = v
+	}
+	return nil
+}
+
+// parse parses the given value according to the type of the column. It
+// returns nil for null values.
+func (c column) parse(s string) (any, error) {
+	if s == "" {
+		if c.nullable {
+			return nil, nil
+		}
+		switch c.field.Type.(type) {
+		case *arrow.BinaryType, *arrow.DictionaryType:
+			return "", nil
+		default:
+			return nil, errors.New("missing value of non-nullable column")
+		}
+	}
+
+	switch c.field.Type.(type) {
+	case *arrow.BinaryType, *arrow.DictionaryType:
+		return s, nil
+	case *arrow.Int64Type:
+		return c.parseInt64(s)
+	case *arrow.Uint64Type:
+		return strconv.ParseUint(s, 10, 64)
+	case *arrow.Float64Type:
+		return strconv.ParseFloat(s, 64)
+	case *arrow.Float32Type:
+		v, err := strconv.ParseFloat(s, 32)
+		return float32(v), err
+	case *arrow.BooleanType:
+		return strconv.ParseBool(s)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", c.field.Type)
+	}
+}
+
+// parseInt64 parses an integer or a timestamp.
+func (c column) parseInt64(s string) (int64, error) {
+	if c.Layout == "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err == nil {
+			return v, nil
+		}
+		t, timeErr := time.Parse(time.RFC3339Nano, s)
+		if timeErr != nil {
+			// The value is most likely meant to be an integer.
+			return 0, err
+		}
+		return t.UnixNano() / int64(c.Unit), nil
+	}
+	t, err := time.Parse(c.Layout, s)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixNano() / int64(c.Unit), nil
+}
+
+func appendValue(b array.Builder, v any) {
+	if v == nil {
+		b.AppendNull()
+		return
+	}
+	switch b := b.(type) {
+	case *array.BinaryDictionaryBuilder:
+		// Appending to a binary dictionary does not fail.
+		_ = b.AppendString(v.(string))
+	case *array.BinaryBuilder:
+		b.AppendString(v.(string))
+	case *array.Int64Builder:
+		b.Append(v.(int64))
+	case *array.Uint64Builder:
+		b.Append(v.(uint64))
+	case *array.Float64Builder:
+		b.Append(v.(float64))
+	case *array.Float32Builder:
+		b.Append(v.(float32))
+	case *array.BooleanBuilder:
+		b.Append(v.(bool))
+	default:
+		panic(fmt.Sprintf("unsupported builder %T", b))
+	}
+}
+
+func clone(record []string) []string {
+	return append([]string(nil), record...)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,130 @@
+package csv
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb"
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+const data = `type,node,ts,value,ignored
+cpu,a,2024-01-02T00:00:00Z,1,x
+cpu,b,1704153600001,2,y
+cpu,c,yesterday,3,z
+cpu,,1704153600002,4
+`
+
+func newTable(t *testing.T) (*frostdb.DB, *frostdb.Table) {
+	c, err := frostdb.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", frostdb.NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	return db, table
+}
+
+var mapping = WithMapping(
+	Column{Source: "type", Target: "example_type"},
+	Column{Source: "node", Target: "labels.node"},
+	Column{Source: "ts", Target: "timestamp"},
+	Column{Source: "value", Target: "value"},
+)
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	db, table := newTable(t)
+
+	var (
+		bad      []BadRow
+		progress []Progress
+	)
+	p, err := Load(ctx, strings.NewReader(data), table,
+		mapping,
+		WithBatchSize(2),
+		WithBadRowPolicy(BadRowSkip),
+		WithBadRowHandler(func(row BadRow) { bad = append(bad, row) }),
+		WithProgress(func(p Progress) { progress = append(progress, p) }),
+	)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), p.Rows)
+	require.Equal(t, int64(1), p.SkippedRows)
+	require.Equal(t, int64(len(data)), p.Bytes)
+	require.Len(t, progress, 2)
+	require.Equal(t, int64(2), progress[0].Rows)
+
+	// The last row omits the ignored column, which is not a bad row.
+	require.Len(t, bad, 1)
+	require.Equal(t, 4, bad[0].Line)
+	require.Equal(t, "yesterday", bad[0].Record[2])
+	require.ErrorContains(t, bad[0].Err, `column "ts"`)
+
+	type row struct {
+		node      any
+		timestamp int64
+		value     int64
+	}
+	var rows []row
+	require.NoError(t, query.NewEngine(memory.DefaultAllocator, db.TableProvider()).
+		ScanTable("test").
+		Project(logicalplan.Col("labels.node"), logicalplan.Col("timestamp"), logicalplan.Col("value")).
+		Execute(ctx, func(_ context.Context, r arrow.Record) error {
+			for i := 0; i < int(r.NumRows()); i++ {
+				var node any
+				if col := r.Column(0); col.IsValid(i) {
+					node = string(col.GetOneForMarshal(i).([]byte))
+				}
+				rows = append(rows, row{
+					node:      node,
+					timestamp: r.Column(1).GetOneForMarshal(i).(int64),
+					value:     r.Column(2).GetOneForMarshal(i).(int64),
+				})
+			}
+			return nil
+		}))
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC).UnixMilli()
+	require.ElementsMatch(t, []row{
+		{node: "a", timestamp: day, value: 1},
+		{node: "b", timestamp: day + 1, value: 2},
+		{node: nil, timestamp: day + 2, value: 4},
+	}, rows)
+}
+
+func TestLoadBadRow(t *testing.T) {
+	_, table := newTable(t)
+	p, err := Load(context.Background(), strings.NewReader(data), table, mapping, WithBatchSize(1))
+	require.ErrorContains(t, err, "line 4")
+	require.Equal(t, int64(2), p.Rows)
+}
+
+func TestLoadMapping(t *testing.T) {
+	ctx := context.Background()
+	_, table := newTable(t)
+
+	// Without a mapping, columns are mapped by name.
+	p, err := Load(ctx, strings.NewReader("example_type,labels.node,timestamp,value,other\ncpu,a,1,2,x\n"), table)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), p.Rows)
+
+	_, err = Load(ctx, strings.NewReader(data), table, WithMapping(Column{Source: "missing", Target: "value"}))
+	require.ErrorContains(t, err, "not in the header")
+	_, err = Load(ctx, strings.NewReader(data), table, WithMapping(Column{Source: "value", Target: "missing"}))
+	require.ErrorContains(t, err, "no column")
+
+	// Timestamps are parsed with the layout of the column.
+	p, err = Load(ctx, strings.NewReader("ts\n02.01.2024\n"), table, WithMapping(
+		Column{Source: "ts", Target: "timestamp", Layout: "02.01.2006", Unit: time.Second},
+	))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), p.Rows)
+}
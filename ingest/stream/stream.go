@@ -0,0 +1,336 @@
+// Package stream ingests the messages of a stream, e.g. the partitions of a
+// Kafka topic consumed by a consumer group, into a frostdb table.
+//
+// The offsets of the ingested messages are checkpointed in a table of the
+// database the messages are ingested into, so that messages that are
+// delivered again, e.g. after the consumer group was rebalanced or the
+// consumer restarted before it committed its offsets, are skipped. The
+// checkpoints are written after the batch of messages they checkpoint, in a
+// separate transaction, so a batch is ingested again if the runner fails
+// between the two. Ingestion is therefore at least once.
+package stream
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+
+	"github.com/polarsignals/frostdb"
+	schemapb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/schema/v1alpha1"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// Message is a message of a partition of a stream.
+type Message struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Timestamp time.Time
+}
+
+// Offset is the offset of the last ingested message of a partition.
+type Offset struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+}
+
+// Consumer consumes the partitions assigned to a member of a consumer group,
+// e.g. a Kafka consumer group. A Kafka consumer implements Poll by fetching
+// records and Commit by committing the offsets after the given ones.
+type Consumer interface {
+	// Poll returns the next messages of the assigned partitions. It blocks
+	// until messages are available or the context is done. The messages of a
+	// partition are returned in the order of their offsets.
+	Poll(ctx context.Context) ([]Message, error)
+	// Commit commits the offsets of the messages that were ingested to the
+	// consumer group, so that consumption resumes after them.
+	Commit(ctx context.Context, offsets []Offset) error
+}
+
+// Decoder converts a batch of messages to a record of the table they are
+// ingested into. It returns nil if none of the messages result in rows.
+type Decoder func(messages []Message) (arrow.Record, error)
+
+// DefaultCheckpointTable is the default name of the table the offsets of the
+// ingested messages are checkpointed in.
+const DefaultCheckpointTable = "stream_checkpoints"
+
+type options struct {
+	checkpointTable string
+	pool            memory.Allocator
+}
+
+type Option func(*options)
+
+// WithCheckpointTable sets the name of the table the offsets of the ingested
+// messages are checkpointed in. The default is DefaultCheckpointTable.
+func WithCheckpointTable(name string) Option {
+	return func(o *options) {
+		o.checkpointTable = name
+	}
+}
+
+// WithAllocator sets the allocator used to read and write checkpoints.
+func WithAllocator(pool memory.Allocator) Option {
+	return func(o *options) {
+		o.pool = pool
+	}
+}
+
+type partition struct {
+	topic     string
+	partition int32
+}
+
+// Runner ingests the messages consumed by a consumer into a table.
+type Runner struct {
+	db          *frostdb.DB
+	table       *frostdb.Table
+	checkpoints *frostdb.Table
+	group       string
+	consumer    Consumer
+	decode      Decoder
+	pool        memory.Allocator
+
+	mtx sync.Mutex
+	// offsets are the checkpointed offsets of the partitions.
+	offsets map[partition]int64
+}
+
+// NewRunner returns a runner that ingests the messages consumed by the given
+// consumer of the given consumer group into the given table of the database.
+// The checkpoints of the group are loaded from the checkpoint table, which is
+// created if it does not exist.
+func NewRunner(
+	ctx context.Context,
+	db *frostdb.DB,
+	table string,
+	group string,
+	consumer Consumer,
+	decode Decoder,
+	opts ...Option,
+) (*Runner, error) {
+	o := options{
+		checkpointTable: DefaultCheckpointTable,
+		pool:            memory.DefaultAllocator,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	t, err := db.GetTable(table)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints, err := db.GetOrCreateTable(o.checkpointTable, frostdb.NewTableConfig(checkpointSchema()))
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint table: %w", err)
+	}
+
+	r := &Runner{
+		db:          db,
+		table:       t,
+		checkpoints: checkpoints,
+		group:       group,
+		consumer:    consumer,
+		decode:      decode,
+		pool:        o.pool,
+		offsets:     map[partition]int64{},
+	}
+	if err := r.loadCheckpoints(ctx, o.checkpointTable); err != nil {
+		return nil, fmt.Errorf("load checkpoints: %w", err)
+	}
+	return r, nil
+}
+
+// Checkpoint returns the checkpointed offset of the given partition. It
+// returns false if no message of the partition was ingested.
+func (r *Runner) Checkpoint(topic string, p int32) (int64, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	offset, ok := r.offsets[partition{topic: topic, partition: p}]
+	return offset, ok
+}
+
+// Run ingests messages until the context is done or ingestion fails. Every
+// batch of messages returned by the consumer is inserted into the table,
+// checkpointed and then committed to the consumer group. Messages at or
+// before the checkpoint of their partition were already ingested and are
+// skipped.
+func (r *Runner) Run(ctx context.Context) error {
+	for {
+		messages, err := r.consumer.Poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("poll: %w", err)
+		}
+		if err := r.ingest(ctx, messages); err != nil {
+			return err
+		}
+	}
+}
+
+// ingest ingests a batch of messages.
+func (r *Runner) ingest(ctx context.Context, messages []Message) error {
+	r.mtx.Lock()
+	fresh := make([]Message, 0, len(messages))
+	offsets := map[partition]int64{}
+	for _, m := range messages {
+		p := partition{topic: m.Topic, partition: m.Partition}
+		if checkpoint, ok := r.offsets[p]; ok && m.Offset <= checkpoint {
+			continue
+		}
+		fresh = append(fresh, m)
+		if offset, ok := offsets[p]; !ok || m.Offset > offset {
+			offsets[p] = m.Offset
+		}
+	}
+	r.mtx.Unlock()
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	record, err := r.decode(fresh)
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	if record != nil {
+		_, err := r.table.InsertRecord(ctx, record)
+		record.Release()
+		if err != nil {
+			return fmt.Errorf("insert: %w", err)
+		}
+	}
+
+	commit := make([]Offset, 0, len(offsets))
+	for p, offset := range offsets {
+		commit = append(commit, Offset{Topic: p.topic, Partition: p.partition, Offset: offset})
+	}
+	if err := r.checkpoint(ctx, commit); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	r.mtx.Lock()
+	for p, offset := range offsets {
+		r.offsets[p] = offset
+	}
+	r.mtx.Unlock()
+	if err := r.consumer.Commit(ctx, commit); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// checkpoint inserts the given offsets into the checkpoint table.
+func (r *Runner) checkpoint(ctx context.Context, offsets []Offset) error {
+	b := array.NewRecordBuilder(r.pool, checkpointArrowSchema)
+	defer b.Release()
+	for _, o := range offsets {
+		if err := b.Field(0).(*array.BinaryDictionaryBuilder).AppendString(r.group); err != nil {
+			return err
+		}
+		b.Field(1).(*array.Int64Builder).Append(o.Offset)
+		b.Field(2).(*array.Int64Builder).Append(int64(o.Partition))
+		if err := b.Field(3).(*array.BinaryDictionaryBuilder).AppendString(o.Topic); err != nil {
+			return err
+		}
+	}
+	record := b.NewRecord()
+	defer record.Release()
+	_, err := r.checkpoints.InsertRecord(ctx, record)
+	return err
+}
+
+// loadCheckpoints loads the latest checkpoints of the partitions of the group
+// from the checkpoint table.
+func (r *Runner) loadCheckpoints(ctx context.Context, table string) error {
+	return query.NewEngine(r.pool, r.db.TableProvider()).
+		ScanTable(table).
+		Filter(logicalplan.Col("group").Eq(logicalplan.Literal(r.group))).
+		Project(logicalplan.Col("topic"), logicalplan.Col("partition"), logicalplan.Col("offset")).
+		Execute(ctx, func(_ context.Context, record arrow.Record) error {
+			partitions := record.Column(1).(*array.Int64)
+			offsets := record.Column(2).(*array.Int64)
+			for i := 0; i < int(record.NumRows()); i++ {
+				topic, err := stringValue(record.Column(0), i)
+				if err != nil {
+					return err
+				}
+				p := partition{topic: topic, partition: int32(partitions.Value(i))}
+				if offset, ok := r.offsets[p]; !ok || offsets.Value(i) > offset {
+					r.offsets[p] = offsets.Value(i)
+				}
+			}
+			return nil
+		})
+}
+
+func stringValue(arr arrow.Array, i int) (string, error) {
+	switch arr := arr.(type) {
+	case *array.Binary:
+		return string(arr.Value(i)), nil
+	case *array.Dictionary:
+		return stringValue(arr.Dictionary(), arr.GetValueIndex(i))
+	default:
+		return "", fmt.Errorf("unexpected string column type %s", arr.DataType())
+	}
+}
+
+var checkpointArrowSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "group", Type: &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.Binary}},
+	{Name: "offset", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "partition", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "topic", Type: &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.Binary}},
+}, nil)
+
+func checkpointSchema() *schemapb.Schema {
+	return &schemapb.Schema{
+		Name: "stream_checkpoints",
+		Columns: []*schemapb.Column{{
+			Name: "group",
+			StorageLayout: &schemapb.StorageLayout{
+				Type:     schemapb.StorageLayout_TYPE_STRING,
+				Encoding: schemapb.StorageLayout_ENCODING_RLE_DICTIONARY,
+			},
+		}, {
+			Name: "offset",
+			StorageLayout: &schemapb.StorageLayout{
+				Type: schemapb.StorageLayout_TYPE_INT64,
+			},
+		}, {
+			Name: "partition",
+			StorageLayout: &schemapb.StorageLayout{
+				Type: schemapb.StorageLayout_TYPE_INT64,
+			},
+		}, {
+			Name: "topic",
+			StorageLayout: &schemapb.StorageLayout{
+				Type:     schemapb.StorageLayout_TYPE_STRING,
+				Encoding: schemapb.StorageLayout_ENCODING_RLE_DICTIONARY,
+			},
+		}},
+		SortingColumns: []*schemapb.SortingColumn{{
+			Name:      "group",
+			Direction: schemapb.SortingColumn_DIRECTION_ASCENDING,
+		}, {
+			Name:      "topic",
+			Direction: schemapb.SortingColumn_DIRECTION_ASCENDING,
+		}, {
+			Name:      "partition",
+			Direction: schemapb.SortingColumn_DIRECTION_ASCENDING,
+		}, {
+			Name:      "offset",
+			Direction: schemapb.SortingColumn_DIRECTION_ASCENDING,
+		}},
+	}
+}
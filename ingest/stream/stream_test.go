@@ -0,0 +1,132 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb"
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// fakeConsumer returns the given batches and cancels the run once they are
+// consumed.
+type fakeConsumer struct {
+	batches [][]Message
+	cancel  context.CancelFunc
+	commits [][]Offset
+}
+
+func (c *fakeConsumer) Poll(ctx context.Context) ([]Message, error) {
+	if len(c.batches) == 0 {
+		c.cancel()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	batch := c.batches[0]
+	c.batches = c.batches[1:]
+	return batch, nil
+}
+
+func (c *fakeConsumer) Commit(_ context.Context, offsets []Offset) error {
+	sort.Slice(offsets, func(i, j int) bool {
+		return offsets[i].Partition < offsets[j].Partition
+	})
+	c.commits = append(c.commits, offsets)
+	return nil
+}
+
+func messages(partition int32, offsets ...int64) []Message {
+	msgs := make([]Message, 0, len(offsets))
+	for _, o := range offsets {
+		msgs = append(msgs, Message{Topic: "samples", Partition: partition, Offset: o})
+	}
+	return msgs
+}
+
+// decode decodes every message to a sample whose value is the offset of the
+// message.
+func decode(msgs []Message) (arrow.Record, error) {
+	samples := make(dynparquet.Samples, 0, len(msgs))
+	for _, m := range msgs {
+		samples = append(samples, dynparquet.Sample{
+			ExampleType: "msg",
+			Labels:      map[string]string{"partition": strconv.Itoa(int(m.Partition))},
+			Value:       m.Offset,
+		})
+	}
+	return samples.ToRecord()
+}
+
+func TestRunner(t *testing.T) {
+	ctx := context.Background()
+	c, err := frostdb.New()
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	_, err = db.Table("samples", frostdb.NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	run := func(batches ...[]Message) (*Runner, *fakeConsumer) {
+		ctx, cancel := context.WithCancel(ctx)
+		consumer := &fakeConsumer{batches: batches, cancel: cancel}
+		r, err := NewRunner(ctx, db, "samples", "group", consumer, decode)
+		require.NoError(t, err)
+		require.True(t, errors.Is(r.Run(ctx), context.Canceled))
+		return r, consumer
+	}
+	values := func() []int64 {
+		var values []int64
+		require.NoError(t, query.NewEngine(memory.DefaultAllocator, db.TableProvider()).
+			ScanTable("samples").
+			Project(logicalplan.Col("value")).
+			Execute(ctx, func(_ context.Context, r arrow.Record) error {
+				for i := 0; i < int(r.NumRows()); i++ {
+					values = append(values, r.Column(0).GetOneForMarshal(i).(int64))
+				}
+				return nil
+			}))
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		return values
+	}
+
+	r, consumer := run(
+		append(messages(0, 0, 1), messages(1, 0)...),
+		messages(0, 2),
+	)
+	require.Equal(t, []int64{0, 0, 1, 2}, values())
+	require.Equal(t, [][]Offset{
+		{{Topic: "samples", Partition: 0, Offset: 1}, {Topic: "samples", Partition: 1, Offset: 0}},
+		{{Topic: "samples", Partition: 0, Offset: 2}},
+	}, consumer.commits)
+	offset, ok := r.Checkpoint("samples", 0)
+	require.True(t, ok)
+	require.Equal(t, int64(2), offset)
+
+	// A restarted runner loads the checkpoints and skips the messages that
+	// are delivered again.
+	r, consumer = run(
+		append(messages(0, 1, 2, 3), messages(1, 0, 1)...),
+		messages(1, 0),
+	)
+	require.Equal(t, []int64{0, 0, 1, 1, 2, 3}, values())
+	require.Len(t, consumer.commits, 1)
+	offset, ok = r.Checkpoint("samples", 1)
+	require.True(t, ok)
+	require.Equal(t, int64(1), offset)
+
+	// Checkpoints are per consumer group.
+	other, err := NewRunner(ctx, db, "samples", "other", &fakeConsumer{}, decode)
+	require.NoError(t, err)
+	_, ok = other.Checkpoint("samples", 0)
+	require.False(t, ok)
+}
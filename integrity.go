@@ -0,0 +1,342 @@
+package frostdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+)
+
+const (
+	// blockDataFileName is the name of the Parquet file of a persisted block.
+	blockDataFileName = "data.parquet"
+	// blockManifestFileName is the name of the manifest uploaded alongside
+	// the data of a persisted block.
+	blockManifestFileName = "manifest.json"
+)
+
+var (
+	// ErrBlockCorrupt is returned when the contents of a block do not match
+	// its manifest.
+	ErrBlockCorrupt = errors.New("block is corrupt")
+	// ErrBlockUnverifiable is returned when a block cannot be verified
+	// because it has no manifest, e.g. because it was persisted by an older
+	// version of frostdb.
+	ErrBlockUnverifiable = errors.New("block has no manifest")
+)
+
+// blockManifest describes the data of a persisted block so that its
+// integrity can be verified.
+type blockManifest struct {
+	// Size is the size in bytes of the block's data file.
+	Size int64 `json:"size"`
+	// SHA256 is the hex encoded SHA-256 hash of the block's data file.
+	SHA256 string `json:"sha256"`
+	// PreAggregates are the pre-aggregates of the block's rows, if the table
+	// pre-aggregates its blocks.
+	PreAggregates *blockPreAggregates `json:"pre_aggregates,omitempty"`
+}
+
+func uploadBlockManifest(ctx context.Context, sink DataSink, blockDir string, manifest blockManifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return sink.Upload(ctx, filepath.Join(blockDir, blockManifestFileName), bytes.NewReader(data))
+}
+
+// IntegrityVerifier is implemented by data sources that are able to verify
+// the integrity of the blocks they store.
+type IntegrityVerifier interface {
+	DataSource
+	// Blocks returns the directories of all blocks under the given prefix.
+	Blocks(ctx context.Context, prefix string) ([]string, error)
+	// VerifyBlock verifies that the data of the block in the given directory
+	// matches its manifest.
+	VerifyBlock(ctx context.Context, blockDir string) error
+	// CopyBlock uploads the block in the given directory to the given sink.
+	CopyBlock(ctx context.Context, blockDir string, to DataSink) error
+}
+
+// BlockIntegrityStatus is the result of verifying a single block.
+type BlockIntegrityStatus int
+
+const (
+	// BlockIntegrityOK means the block matches its manifest.
+	BlockIntegrityOK BlockIntegrityStatus = iota
+	// BlockIntegrityUnverified means the block has no manifest to verify it
+	// against.
+	BlockIntegrityUnverified
+	// BlockIntegrityCorrupt means the block does not match its manifest and
+	// could not be repaired.
+	BlockIntegrityCorrupt
+	// BlockIntegrityRepaired means the block did not match its manifest and
+	// was replaced with a valid copy from another source.
+	BlockIntegrityRepaired
+)
+
+func (s BlockIntegrityStatus) String() string {
+	switch s {
+	case BlockIntegrityOK:
+		return "ok"
+	case BlockIntegrityUnverified:
+		return "unverified"
+	case BlockIntegrityCorrupt:
+		return "corrupt"
+	case BlockIntegrityRepaired:
+		return "repaired"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// BlockIntegrity is the result of verifying a block in a data source.
+type BlockIntegrity struct {
+	// Source is the name of the data source the block is stored in.
+	Source string
+	// BlockDir is the directory of the block within the data source.
+	BlockDir string
+	Status   BlockIntegrityStatus
+	// Err is the verification error for blocks that are not OK.
+	Err error
+}
+
+// IntegrityReport is the result of verifying the integrity of a table.
+type IntegrityReport struct {
+	Blocks []BlockIntegrity
+}
+
+// Corrupt returns the blocks that were found to be corrupt and could not be
+// repaired.
+func (r *IntegrityReport) Corrupt() []BlockIntegrity {
+	var corrupt []BlockIntegrity
+	for _, b := range r.Blocks {
+		if b.Status == BlockIntegrityCorrupt {
+			corrupt = append(corrupt, b)
+		}
+	}
+	return corrupt
+}
+
+// VerifyIntegrity verifies the blocks of the table persisted in all data
+// sources that implement IntegrityVerifier against their manifests. This
+// detects bit rot as well as partially uploaded blocks. If a corrupt block is
+// stored in a source that is also configured as a sink and a valid copy of the
+// block exists in another source, the corrupt block is replaced with that
+// copy.
+func (t *Table) VerifyIntegrity(ctx context.Context) (*IntegrityReport, error) {
+	prefix := filepath.Join(t.db.prefix(), t.name)
+	verifiers := make([]IntegrityVerifier, 0, len(t.db.sources))
+	for _, source := range t.db.sources {
+		if v, ok := source.(IntegrityVerifier); ok {
+			verifiers = append(verifiers, v)
+		}
+	}
+
+	report := &IntegrityReport{}
+	for i, v := range verifiers {
+		blocks, err := v.Blocks(ctx, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("list blocks of %s: %w", v, err)
+		}
+		for _, blockDir := range blocks {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			result := BlockIntegrity{
+				Source:   v.String(),
+				BlockDir: blockDir,
+			}
+			err := v.VerifyBlock(ctx, blockDir)
+			switch {
+			case err == nil:
+				result.Status = BlockIntegrityOK
+			case errors.Is(err, ErrBlockUnverifiable):
+				result.Status = BlockIntegrityUnverified
+				result.Err = err
+			case errors.Is(err, ErrBlockCorrupt):
+				result.Status = BlockIntegrityCorrupt
+				result.Err = err
+				if repairErr := t.repairBlock(ctx, blockDir, i, verifiers); repairErr != nil {
+					level.Debug(t.logger).Log("msg", "failed to repair block", "block", blockDir, "err", repairErr)
+				} else {
+					result.Status = BlockIntegrityRepaired
+				}
+			default:
+				return nil, fmt.Errorf("verify block %s: %w", blockDir, err)
+			}
+			t.metrics.blocksVerified.WithLabelValues(result.Status.String()).Inc()
+			if result.Status == BlockIntegrityCorrupt || result.Status == BlockIntegrityRepaired {
+				level.Warn(t.logger).Log(
+					"msg", "block failed integrity verification",
+					"source", result.Source,
+					"block", blockDir,
+					"status", result.Status,
+					"err", result.Err,
+				)
+			}
+			report.Blocks = append(report.Blocks, result)
+		}
+	}
+	return report, nil
+}
+
+// repairBlock replaces the corrupt block stored in verifiers[corrupt] with a
+// valid copy from any of the other verifiers.
+func (t *Table) repairBlock(ctx context.Context, blockDir string, corrupt int, verifiers []IntegrityVerifier) error {
+	// Only repair sources that were configured as sinks, read-only sources
+	// must not be written to.
+	var sink DataSink
+	for _, s := range t.db.sinks {
+		if v, ok := s.(IntegrityVerifier); ok && v == verifiers[corrupt] {
+			sink = s
+		}
+	}
+	if sink == nil {
+		return fmt.Errorf("source %s is not writable", verifiers[corrupt])
+	}
+	for i, replica := range verifiers {
+		if i == corrupt {
+			continue
+		}
+		if err := replica.VerifyBlock(ctx, blockDir); err != nil {
+			continue
+		}
+		if err := replica.CopyBlock(ctx, blockDir, sink); err != nil {
+			return err
+		}
+		return verifiers[corrupt].VerifyBlock(ctx, blockDir)
+	}
+	return fmt.Errorf("no valid replica found")
+}
+
+// scrub periodically verifies the integrity of all tables of the database
+// until the given context is canceled.
+func (db *DB) scrub(ctx context.Context, interval time.Duration) {
+	ticker := db.columnStore.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			db.mtx.RLock()
+			tables := make([]*Table, 0, len(db.tables)+len(db.roTables))
+			for _, table := range db.tables {
+				tables = append(tables, table)
+			}
+			for _, table := range db.roTables {
+				tables = append(tables, table)
+			}
+			db.mtx.RUnlock()
+
+			for _, table := range tables {
+				report, err := table.VerifyIntegrity(ctx)
+				if err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					level.Error(db.logger).Log("msg", "failed to verify table integrity", "table", table.name, "err", err)
+					continue
+				}
+				if corrupt := report.Corrupt(); len(corrupt) > 0 {
+					level.Error(db.logger).Log("msg", "found corrupt blocks", "table", table.name, "count", len(corrupt))
+				}
+			}
+		}
+	}
+}
+
+// Blocks returns the directories of all blocks under the given prefix.
+func (b *DefaultObjstoreBucket) Blocks(ctx context.Context, prefix string) ([]string, error) {
+	var blocks []string
+	err := b.Iter(ctx, prefix, func(blockDir string) error {
+		if strings.HasSuffix(blockDir, "/") {
+			blocks = append(blocks, strings.TrimSuffix(blockDir, "/"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// VerifyBlock verifies that the data of the block in the given directory
+// matches its manifest.
+func (b *DefaultObjstoreBucket) VerifyBlock(ctx context.Context, blockDir string) error {
+	ctx, span := b.tracer.Start(ctx, "Source/VerifyBlock")
+	defer span.End()
+
+	manifest, err := b.readBlockManifest(ctx, blockDir)
+	if err != nil {
+		return err
+	}
+
+	rc, err := b.Get(ctx, filepath.Join(blockDir, blockDataFileName))
+	if err != nil {
+		if b.IsObjNotFoundErr(err) {
+			return fmt.Errorf("%w: data file missing", ErrBlockCorrupt)
+		}
+		return err
+	}
+	defer rc.Close()
+
+	hash := sha256.New()
+	w := &accountingWriter{w: hash}
+	if _, err := io.Copy(w, rc); err != nil {
+		return err
+	}
+	if w.n != manifest.Size {
+		return fmt.Errorf("%w: expected %d bytes, found %d", ErrBlockCorrupt, manifest.Size, w.n)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != manifest.SHA256 {
+		return fmt.Errorf("%w: expected sha256 %s, found %s", ErrBlockCorrupt, manifest.SHA256, sum)
+	}
+	return nil
+}
+
+// CopyBlock uploads the block in the given directory to the given sink.
+func (b *DefaultObjstoreBucket) CopyBlock(ctx context.Context, blockDir string, to DataSink) error {
+	// Upload the data first so that the block is detected as corrupt if the
+	// copy is interrupted.
+	for _, name := range []string{blockDataFileName, blockManifestFileName} {
+		if err := func() error {
+			rc, err := b.Get(ctx, filepath.Join(blockDir, name))
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			return to.Upload(ctx, filepath.Join(blockDir, name), rc)
+		}(); err != nil {
+			return fmt.Errorf("copy %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (b *DefaultObjstoreBucket) readBlockManifest(ctx context.Context, blockDir string) (*blockManifest, error) {
+	rc, err := b.Get(ctx, filepath.Join(blockDir, blockManifestFileName))
+	if err != nil {
+		if b.IsObjNotFoundErr(err) {
+			return nil, ErrBlockUnverifiable
+		}
+		return nil, err
+	}
+	defer rc.Close()
+
+	manifest := &blockManifest{}
+	if err := json.NewDecoder(rc).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("%w: invalid manifest: %v", ErrBlockCorrupt, err)
+	}
+	return manifest, nil
+}
@@ -0,0 +1,106 @@
+package frostdb
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+)
+
+func TestTableVerifyIntegrity(t *testing.T) {
+	ctx := context.Background()
+	primary := objstore.NewInMemBucket()
+	replica := objstore.NewInMemBucket()
+
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithReadWriteStorage(NewDefaultObjstoreBucket(primary)),
+		WithReadOnlyStorage(NewDefaultObjstoreBucket(replica)),
+		WithManualBlockRotation(),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	samples := dynparquet.NewTestSamples()
+	r, err := samples.ToRecord()
+	require.NoError(t, err)
+	writeTx, err := table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	// Writing the block is asynchronous, so wait for both the new table block
+	// txn and the block persistence txn.
+	db.Wait(writeTx + 2)
+
+	// Replicate the persisted block.
+	var dataFile string
+	require.NoError(t, primary.Iter(ctx, "", func(name string) error {
+		if filepath.Base(name) == blockDataFileName {
+			dataFile = name
+		}
+		rc, err := primary.Get(ctx, name)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return replica.Upload(ctx, name, rc)
+	}, objstore.WithRecursiveIter))
+	require.NotEmpty(t, dataFile)
+	blockDir := filepath.Dir(dataFile)
+
+	requireStatus := func(t *testing.T, expected ...BlockIntegrityStatus) {
+		t.Helper()
+		report, err := table.VerifyIntegrity(ctx)
+		require.NoError(t, err)
+		require.Len(t, report.Blocks, len(expected))
+		for i, b := range report.Blocks {
+			require.Equal(t, blockDir, b.BlockDir)
+			require.Equal(t, expected[i], b.Status, b.Err)
+		}
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		requireStatus(t, BlockIntegrityOK, BlockIntegrityOK)
+	})
+
+	readData := func(t *testing.T, bucket objstore.Bucket) []byte {
+		rc, err := bucket.Get(ctx, dataFile)
+		require.NoError(t, err)
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		return data
+	}
+	original := readData(t, primary)
+
+	t.Run("Repaired", func(t *testing.T) {
+		corrupted := bytes.Clone(original)
+		corrupted[len(corrupted)/2] ^= 0xff
+		require.NoError(t, primary.Upload(ctx, dataFile, bytes.NewReader(corrupted)))
+
+		requireStatus(t, BlockIntegrityRepaired, BlockIntegrityOK)
+		require.Equal(t, original, readData(t, primary))
+	})
+
+	t.Run("PartialUpload", func(t *testing.T) {
+		// The replica is not writable, so it cannot be repaired.
+		require.NoError(t, replica.Upload(ctx, dataFile, bytes.NewReader(original[:len(original)/2])))
+		requireStatus(t, BlockIntegrityOK, BlockIntegrityCorrupt)
+	})
+
+	t.Run("Unverified", func(t *testing.T) {
+		require.NoError(t, primary.Delete(ctx, filepath.Join(blockDir, blockManifestFileName)))
+		requireStatus(t, BlockIntegrityUnverified, BlockIntegrityCorrupt)
+	})
+}
@@ -3,12 +3,14 @@ package logictest
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/apache/arrow/go/v14/arrow/memory"
 	"github.com/cockroachdb/datadriven"
 	"github.com/stretchr/testify/require"
 
 	"github.com/polarsignals/frostdb"
+	"github.com/polarsignals/frostdb/clock"
 	"github.com/polarsignals/frostdb/dynparquet"
 	"github.com/polarsignals/frostdb/query"
 	"github.com/polarsignals/frostdb/query/physicalplan"
@@ -18,6 +20,10 @@ import (
 
 const testdataDirectory = "testdata"
 
+// now is the time now() of the test queries is bound to, in milliseconds
+// since the Unix epoch like the timestamps of the tests.
+var now = time.UnixMilli(130000)
+
 type frostDB struct {
 	*frostdb.DB
 	allocator *query.LimitAllocator
@@ -34,6 +40,7 @@ func (db frostDB) ScanTable(name string) query.Builder {
 		query.WithPhysicalplanOptions(
 			physicalplan.WithOrderedAggregations(),
 		),
+		query.WithClock(clock.NewManual(now)),
 	)
 	return queryEngine.ScanTable(name)
 }
@@ -59,6 +66,44 @@ var schemas = map[string]*schemapb.Schema{
 			Direction: schemapb.SortingColumn_DIRECTION_ASCENDING,
 		}},
 	},
+	"simple_float32": {
+		Name: "simple_float32",
+		Columns: []*schemapb.Column{{
+			Name: "name",
+			StorageLayout: &schemapb.StorageLayout{
+				Type:     schemapb.StorageLayout_TYPE_STRING,
+				Encoding: schemapb.StorageLayout_ENCODING_RLE_DICTIONARY,
+			},
+		}, {
+			Name: "value",
+			StorageLayout: &schemapb.StorageLayout{
+				Type: schemapb.StorageLayout_TYPE_FLOAT,
+			},
+		}},
+		SortingColumns: []*schemapb.SortingColumn{{
+			Name:      "name",
+			Direction: schemapb.SortingColumn_DIRECTION_ASCENDING,
+		}},
+	},
+	"simple_uint64": {
+		Name: "simple_uint64",
+		Columns: []*schemapb.Column{{
+			Name: "name",
+			StorageLayout: &schemapb.StorageLayout{
+				Type:     schemapb.StorageLayout_TYPE_STRING,
+				Encoding: schemapb.StorageLayout_ENCODING_RLE_DICTIONARY,
+			},
+		}, {
+			Name: "value",
+			StorageLayout: &schemapb.StorageLayout{
+				Type: schemapb.StorageLayout_TYPE_UINT64,
+			},
+		}},
+		SortingColumns: []*schemapb.SortingColumn{{
+			Name:      "name",
+			Direction: schemapb.SortingColumn_DIRECTION_ASCENDING,
+		}},
+	},
 	"prehashed": {
 		Name: "test",
 		Columns: []*schemapb.Column{{
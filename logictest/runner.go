@@ -288,6 +288,13 @@ func stringToValue(t parquet.Type, stringValue string) (any, error) {
 	case parquet.ByteArray:
 		return stringValue, nil
 	case parquet.Int64:
+		if lt := t.LogicalType(); lt != nil && lt.Integer != nil && !lt.Integer.IsSigned {
+			uintValue, err := strconv.ParseUint(stringValue, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected error converting %s to uint: %w", stringValue, err)
+			}
+			return uintValue, nil
+		}
 		intValue, err := strconv.Atoi(stringValue)
 		if err != nil {
 			return nil, fmt.Errorf("unexpected error converting %s to int: %w", stringValue, err)
@@ -299,6 +306,12 @@ func stringToValue(t parquet.Type, stringValue string) (any, error) {
 			return nil, fmt.Errorf("unexpected error converting %s to float: %w", stringValue, err)
 		}
 		return floatValue, nil
+	case parquet.Float:
+		floatValue, err := strconv.ParseFloat(stringValue, 32)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected error converting %s to float32: %w", stringValue, err)
+		}
+		return float32(floatValue), nil
 	case parquet.Boolean:
 		switch stringValue {
 		case "true":
@@ -414,6 +427,14 @@ func arrayToStringVals(a arrow.Array) ([]string, error) {
 			}
 			result[i] = strconv.Itoa(int(col.Value(i)))
 		}
+	case *array.Uint64:
+		for i := range result {
+			if col.IsNull(i) {
+				result[i] = nullString
+				continue
+			}
+			result[i] = strconv.FormatUint(col.Value(i), 10)
+		}
 	case *array.Float64:
 		for i := range result {
 			if col.IsNull(i) {
@@ -422,6 +443,14 @@ func arrayToStringVals(a arrow.Array) ([]string, error) {
 			}
 			result[i] = fmt.Sprintf("%f", float64(col.Value(i)))
 		}
+	case *array.Float32:
+		for i := range result {
+			if col.IsNull(i) {
+				result[i] = nullString
+				continue
+			}
+			result[i] = fmt.Sprintf("%f", col.Value(i))
+		}
 	case *array.Boolean:
 		for i := range result {
 			if col.IsNull(i) {
@@ -0,0 +1,75 @@
+package frostdb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+const (
+	defaultLogSamplingBurst    = 10
+	defaultLogSamplingInterval = time.Second
+)
+
+// WithLogSampling limits the logs of high-frequency events, such as the logs
+// of every insert and scan of a table, to the first burst events of each kind
+// per interval and table. The number of events dropped since the last logged
+// one is added to the next logged event. Sampling is disabled if burst is not
+// positive. By default, 10 events per second are logged.
+func WithLogSampling(burst int, interval time.Duration) Option {
+	return func(s *ColumnStore) error {
+		s.logSamplingBurst = burst
+		s.logSamplingInterval = interval
+		return nil
+	}
+}
+
+// logSampler limits the events passed to a logger to a burst per interval.
+type logSampler struct {
+	burst    int
+	interval time.Duration
+
+	mtx     sync.Mutex
+	start   time.Time
+	logged  int
+	dropped int
+}
+
+// sampledLogger returns a logger passing the events allowed by a new sampler
+// to the given logger. The logger is returned as is if burst is not positive.
+func sampledLogger(logger log.Logger, burst int, interval time.Duration) log.Logger {
+	if burst <= 0 {
+		return logger
+	}
+	s := &logSampler{burst: burst, interval: interval}
+	return log.LoggerFunc(func(keyvals ...interface{}) error {
+		dropped, ok := s.allow(time.Now())
+		if !ok {
+			return nil
+		}
+		if dropped > 0 {
+			keyvals = append(keyvals, "dropped", dropped)
+		}
+		return logger.Log(keyvals...)
+	})
+}
+
+// allow returns whether an event at the given time is logged and, if so, the
+// number of events dropped since the last logged event.
+func (s *logSampler) allow(now time.Time) (int, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if now.Sub(s.start) >= s.interval {
+		s.start = now
+		s.logged = 0
+	}
+	if s.logged >= s.burst {
+		s.dropped++
+		return 0, false
+	}
+	s.logged++
+	dropped := s.dropped
+	s.dropped = 0
+	return dropped, true
+}
@@ -0,0 +1,79 @@
+package frostdb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+)
+
+func TestLogSampler(t *testing.T) {
+	s := &logSampler{burst: 2, interval: time.Second}
+	now := time.Now()
+	allowed := func(now time.Time) bool {
+		_, ok := s.allow(now)
+		return ok
+	}
+	require.True(t, allowed(now))
+	require.True(t, allowed(now.Add(time.Millisecond)))
+	require.False(t, allowed(now.Add(2*time.Millisecond)))
+	require.False(t, allowed(now.Add(3*time.Millisecond)))
+
+	// The next interval reports the events dropped in the previous one.
+	dropped, ok := s.allow(now.Add(time.Second))
+	require.True(t, ok)
+	require.Equal(t, 2, dropped)
+	dropped, ok = s.allow(now.Add(time.Second + time.Millisecond))
+	require.True(t, ok)
+	require.Equal(t, 0, dropped)
+}
+
+func TestTableInsertLogSampling(t *testing.T) {
+	var (
+		mtx    sync.Mutex
+		events []map[string]interface{}
+	)
+	logger := log.LoggerFunc(func(keyvals ...interface{}) error {
+		event := map[string]interface{}{}
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			event[keyvals[i].(string)] = keyvals[i+1]
+		}
+		if event["msg"] == "inserted record" {
+			mtx.Lock()
+			events = append(events, event)
+			mtx.Unlock()
+		}
+		return nil
+	})
+	c, err := New(WithLogger(logger), WithLogSampling(2, time.Hour))
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	for i := 0; i < 5; i++ {
+		_, err = table.InsertRecord(context.Background(), r)
+		require.NoError(t, err)
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Len(t, events, 2)
+	for _, e := range events {
+		require.Equal(t, "test", e["db"])
+		require.Equal(t, "test", e["table"])
+		require.Equal(t, r.NumRows(), e["rows"])
+		require.NotNil(t, e["block"])
+	}
+}
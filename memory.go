@@ -0,0 +1,187 @@
+package frostdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/polarsignals/frostdb/accounting"
+)
+
+// WithMemoryAccountant accounts the memory held by the active and pending
+// blocks of all tables and by queued WAL records with the given accountant,
+// and admits inserts and queries according to its policies. Memory allocated
+// during query execution is accounted for by executing queries with an
+// allocator returned by accounting.NewAllocator.
+//
+// Blocked inserts are only unblocked once pending blocks are persisted, so the
+// limit should be larger than the active memory size of all tables.
+func WithMemoryAccountant(a *accounting.Accountant) Option {
+	return func(s *ColumnStore) error {
+		s.accountant = a
+		return nil
+	}
+}
+
+// EvictableCache is a cache of persisted data, e.g. a storage.LRUCache caching
+// the range reads of the bucket of the column store, whose memory can be
+// reclaimed under memory pressure. The evicted data is read from the bucket
+// again when it is queried.
+//
+// Tables drop the parts of their blocks from memory once the blocks are
+// persisted, and queries read persisted blocks from the bucket, so the
+// persisted parts held in memory are those in the caches of the bucket,
+// including the blocks prefetched with Table.Prefetch. Evicting persisted data
+// under memory pressure therefore evicts these caches; there are no persisted
+// parts held by the tables themselves to evict.
+type EvictableCache interface {
+	// Size returns the memory held by the cache in bytes.
+	Size() int64
+	// Evict evicts the least recently read data until at least the given
+	// number of bytes is freed, and returns the number of bytes freed.
+	Evict(bytes int64) int64
+}
+
+// WithEvictableCache accounts the memory held by the cache with the memory
+// accountant of the column store, see WithMemoryAccountant. Whenever the
+// memory in use exceeds the high watermark of the accountant, the least
+// recently read data of the caches is evicted, so that cold data is dropped
+// from memory before inserts block and queries fail. The memory is checked at
+// the eviction interval, see WithEvictionInterval. The hit ratio of the cache,
+// e.g. the frostdb_bucket_cache_requests_total metric of storage.WithCache,
+// and the frostdb_memory_evicted_bytes_total metric of the accountant help to
+// size the memory budget.
+func WithEvictableCache(cache EvictableCache) Option {
+	return func(s *ColumnStore) error {
+		s.caches = append(s.caches, cache)
+		return nil
+	}
+}
+
+// WithEvictionInterval sets the interval at which the memory of evictable
+// caches is reclaimed if the memory in use exceeds the high watermark of the
+// memory accountant. Defaults to 1s.
+func WithEvictionInterval(interval time.Duration) Option {
+	return func(s *ColumnStore) error {
+		s.evictionInterval = interval
+		return nil
+	}
+}
+
+// MemoryAccountant returns the memory accountant of the column store, or nil
+// if memory is not accounted for.
+func (s *ColumnStore) MemoryAccountant() *accounting.Accountant {
+	return s.accountant
+}
+
+// trackMemory registers the gauges of the column store with its accountant.
+func (s *ColumnStore) trackMemory() {
+	if s.accountant == nil {
+		return
+	}
+	s.untrack = append(s.untrack,
+		s.accountant.Track(accounting.ActiveParts, s.activePartsSize),
+		s.accountant.Track(accounting.WAL, s.walQueueSize),
+	)
+	if len(s.caches) == 0 {
+		return
+	}
+	for _, cache := range s.caches {
+		s.untrack = append(s.untrack,
+			s.accountant.Track(accounting.Cache, cache.Size),
+			s.accountant.RegisterEvictor(cache.Evict),
+		)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.evict(ctx)
+	}()
+	s.untrack = append(s.untrack, func() {
+		cancel()
+		<-done
+	})
+}
+
+// evict reclaims the memory of the evictable caches at the eviction interval
+// until the given context is canceled.
+func (s *ColumnStore) evict(ctx context.Context) {
+	ticker := s.clock.NewTicker(s.evictionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			s.accountant.Reclaim()
+		}
+	}
+}
+
+// activePartsSize returns the size of the active and pending blocks of all
+// tables.
+func (s *ColumnStore) activePartsSize() int64 {
+	var size int64
+	for _, db := range s.databases() {
+		for _, table := range db.tablesSnapshot() {
+			size += table.memorySize()
+		}
+	}
+	return size
+}
+
+// walQueueSize returns the size of the WAL records of all databases waiting
+// to be written.
+func (s *ColumnStore) walQueueSize() int64 {
+	var size int64
+	for _, db := range s.databases() {
+		if w, ok := db.wal.(interface{ QueueBytes() int64 }); ok {
+			size += w.QueueBytes()
+		}
+	}
+	return size
+}
+
+func (s *ColumnStore) databases() []*DB {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	dbs := make([]*DB, 0, len(s.dbs))
+	for _, db := range s.dbs {
+		dbs = append(dbs, db)
+	}
+	return dbs
+}
+
+func (db *DB) tablesSnapshot() []*Table {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+	tables := make([]*Table, 0, len(db.tables))
+	for _, table := range db.tables {
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// memorySize returns the size of the active and pending blocks of the table.
+func (t *Table) memorySize() int64 {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	var size int64
+	if t.active != nil {
+		size += t.active.Size()
+	}
+	for block := range t.pendingBlocks {
+		size += block.Size()
+	}
+	return size
+}
+
+// admit admits an operation of the given subsystem with the memory accountant
+// of the column store.
+func (t *Table) admit(ctx context.Context, s accounting.Subsystem) error {
+	if t.db.columnStore.accountant == nil {
+		return nil
+	}
+	return t.db.columnStore.accountant.Admit(ctx, s)
+}
@@ -0,0 +1,91 @@
+package frostdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb/accounting"
+	"github.com/polarsignals/frostdb/clock"
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/storage"
+)
+
+func TestMemoryAccountant(t *testing.T) {
+	a := accounting.New(1, accounting.WithHighWatermark(1))
+	c, err := New(WithMemoryAccountant(a))
+	require.NoError(t, err)
+	defer c.Close()
+	require.Equal(t, a, c.MemoryAccountant())
+
+	ctx := context.Background()
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+
+	// The first insert is admitted since no memory is used yet.
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	require.Equal(t, table.ActiveBlock().Size(), a.UsedBy(accounting.ActiveParts))
+
+	// Further inserts block until the context is done.
+	insertCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = table.InsertRecord(insertCtx, r)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// Queries fail.
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	err = engine.ScanTable("test").Execute(ctx, func(context.Context, arrow.Record) error {
+		return nil
+	})
+	require.True(t, errors.Is(err, accounting.ErrLimitExceeded))
+}
+
+func TestEvictableCache(t *testing.T) {
+	ctx := context.Background()
+	cache := storage.NewLRUCache(1024)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, cache.Set(ctx, key, make([]byte, 100)))
+	}
+	// a is the most recently read value.
+	_, ok, err := cache.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	a := accounting.New(1000, accounting.WithHighWatermark(0.25))
+	clk := clock.NewManual(time.Unix(0, 0))
+	c, err := New(
+		WithMemoryAccountant(a),
+		WithEvictableCache(cache),
+		WithClock(clk),
+		WithEvictionInterval(time.Second),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+	require.Equal(t, int64(400), a.UsedBy(accounting.Cache))
+
+	// The least recently read values are evicted until the memory in use is
+	// below the high watermark.
+	require.Eventually(t, func() bool {
+		clk.Advance(time.Second)
+		return cache.Size() <= 250
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, int64(200), cache.Size())
+	for key, cached := range map[string]bool{"a": true, "b": false, "c": false, "d": true} {
+		_, ok, err := cache.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, cached, ok, key)
+	}
+}
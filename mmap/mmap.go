@@ -0,0 +1,87 @@
+// Package mmap maps files into memory outside of the Go heap.
+//
+// Mapped memory is neither scanned by the garbage collector nor counted
+// towards its heap goal, which keeps large immutable buffers from inflating GC
+// pause times. The kernel may page file backed mappings out under memory
+// pressure and read them back in on access.
+package mmap
+
+import (
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+var errClosed = errors.New("mmap: mapping closed")
+
+// Mapping is a read-only mapping of a file. It implements io.ReaderAt and
+// copies the data it reads, so the mapped memory is never referenced from
+// the Go heap and can be unmapped once the mapping is closed or garbage
+// collected.
+type Mapping struct {
+	mtx  sync.RWMutex
+	data []byte
+	size int
+}
+
+// Map maps the given file into memory. The file may be closed and removed
+// once it is mapped; the mapped memory stays valid until the mapping is
+// closed. If the mapping is not closed explicitly, it is unmapped once it is
+// garbage collected.
+func Map(f *os.File) (*Mapping, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := int(info.Size())
+	m := &Mapping{size: size}
+	if size == 0 {
+		return m, nil
+	}
+	m.data, err = mmap(f, size)
+	if err != nil {
+		return nil, err
+	}
+	runtime.SetFinalizer(m, (*Mapping).Close)
+	return m, nil
+}
+
+// Len returns the size of the mapping in bytes.
+func (m *Mapping) Len() int {
+	return m.size
+}
+
+// ReadAt implements io.ReaderAt.
+func (m *Mapping) ReadAt(p []byte, off int64) (int, error) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	if m.data == nil && m.size != 0 {
+		return 0, errClosed
+	}
+	if off < 0 {
+		return 0, errors.New("mmap: negative offset")
+	}
+	if off >= int64(m.size) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Close unmaps the file. It is safe to call Close multiple times.
+func (m *Mapping) Close() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.data == nil {
+		return nil
+	}
+	data := m.data
+	m.data = nil
+	runtime.SetFinalizer(m, nil)
+	return munmap(data)
+}
@@ -0,0 +1,21 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package mmap
+
+import (
+	"io"
+	"os"
+)
+
+// mmap reads the file into the heap on platforms without mmap support.
+func mmap(f *os.File, size int) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(io.NewSectionReader(f, 0, int64(size)), data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func munmap(_ []byte) error {
+	return nil
+}
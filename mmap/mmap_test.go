@@ -0,0 +1,39 @@
+package mmap
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.WriteFile(path, []byte("hello world"), 0o644))
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	m, err := Map(f)
+	require.NoError(t, err)
+
+	// The mapping stays valid after the file is closed and removed.
+	require.NoError(t, f.Close())
+	require.NoError(t, os.Remove(path))
+	require.Equal(t, 11, m.Len())
+
+	b := make([]byte, 5)
+	n, err := m.ReadAt(b, 6)
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+	require.Equal(t, "world", string(b))
+
+	n, err = m.ReadAt(b, 8)
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, "rld", string(b[:n]))
+
+	require.NoError(t, m.Close())
+	require.NoError(t, m.Close())
+	_, err = m.ReadAt(b, 0)
+	require.Error(t, err)
+}
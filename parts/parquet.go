@@ -21,7 +21,7 @@ func (p *parquetPart) Record() arrow.Record {
 }
 
 func (p *parquetPart) Release() {
-	if p.release != nil {
+	if p.releaseRef() && p.release != nil {
 		p.release()
 	}
 }
@@ -2,6 +2,7 @@ package parts
 
 import (
 	"sort"
+	"sync/atomic"
 
 	"github.com/apache/arrow/go/v14/arrow"
 
@@ -12,6 +13,12 @@ type Part interface {
 	// Record returns the Arrow record for the part. If the part is not an Arrow
 	// record part, nil is returned.
 	Record() arrow.Record
+	// Retain adds a reference to the part, e.g. for a scan reading it while
+	// the part may be compacted concurrently. Each call must be paired with a
+	// call to Release.
+	Retain()
+	// Release removes a reference to the part. The memory of the part is
+	// released once its last reference is released.
 	Release()
 	SerializeBuffer(schema *dynparquet.Schema, w dynparquet.ParquetWriter) error
 	AsSerializedBuffer(schema *dynparquet.Schema) (*dynparquet.SerializedBuffer, error)
@@ -30,6 +37,17 @@ type basePart struct {
 	minRow          *dynparquet.DynamicRow
 	maxRow          *dynparquet.DynamicRow
 	release         func()
+	// refs is the number of references to the part in addition to the
+	// reference of its creator.
+	refs atomic.Int64
+}
+
+func (p *basePart) Retain() { p.refs.Add(1) }
+
+// releaseRef releases a reference to the part and returns true if it was the
+// last one.
+func (p *basePart) releaseRef() bool {
+	return p.refs.Add(-1) == -1
 }
 
 func (p *basePart) CompactionLevel() int {
@@ -46,6 +64,14 @@ func WithCompactionLevel(level int) Option {
 	}
 }
 
+// WithTX sets the transaction of the part, overriding the transaction it was
+// created with. Parts are visible to scans at or after their transaction.
+func WithTX(tx uint64) Option {
+	return func(p *basePart) {
+		p.tx = tx
+	}
+}
+
 func WithRelease(release func()) Option {
 	return func(p *basePart) {
 		p.release = release
@@ -119,3 +119,15 @@ func TestFindMaximumNonOverlappingSet(t *testing.T) {
 		})
 	}
 }
+
+func TestPartRetainRelease(t *testing.T) {
+	released := 0
+	p := NewParquetPart(0, nil, WithRelease(func() { released++ }))
+	p.Retain()
+	p.Retain()
+	p.Release()
+	p.Release()
+	require.Equal(t, 0, released)
+	p.Release()
+	require.Equal(t, 1, released)
+}
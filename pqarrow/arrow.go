@@ -9,6 +9,7 @@ import (
 
 	"github.com/apache/arrow/go/v14/arrow"
 	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/compute"
 	"github.com/apache/arrow/go/v14/arrow/memory"
 	"github.com/parquet-go/parquet-go"
 
@@ -248,7 +249,7 @@ func (c *ParquetConverter) Convert(ctx context.Context, rg parquet.RowGroup) err
 		c.outputSchema = schema
 		c.builder = builder.NewRecordBuilder(c.pool, c.outputSchema)
 	} else if !schema.Equal(c.outputSchema) { // new output schema; append new fields onto record builder
-		c.outputSchema = mergeArrowSchemas([]*arrow.Schema{c.outputSchema, schema})
+		c.outputSchema = MergeArrowSchemas([]*arrow.Schema{c.outputSchema, schema})
 		c.builder.ExpandSchema(c.outputSchema)
 		// Since we expanded the field we need to append nulls to the new field to match the max column length
 		if maxLen, _, anomaly := recordBuilderLength(c.builder); anomaly {
@@ -1020,6 +1021,15 @@ func copyArrToBuilder(builder builder.ColumnBuilder, arr arrow.Array, toCopy int
 				b.UnsafeAppend(arr.Value(i))
 			}
 		}
+	case *array.Float32:
+		b := builder.(*array.Float32Builder)
+		for i := 0; i < toCopy; i++ {
+			if arr.IsNull(i) {
+				b.UnsafeAppendBoolToBitmap(false)
+			} else {
+				b.UnsafeAppend(arr.Value(i))
+			}
+		}
 	case *array.Dictionary:
 		b := builder.(*array.BinaryDictionaryBuilder)
 		switch dict := arr.Dictionary().(type) {
@@ -1059,6 +1069,8 @@ func repeatLastValue(
 		repeatUint64Array(builder.(*array.Uint64Builder), arr, count)
 	case *array.Float64:
 		repeatFloat64Array(builder.(*array.Float64Builder), arr, count)
+	case *array.Float32:
+		repeatFloat32Array(builder.(*array.Float32Builder), arr, count)
 	case *array.Dictionary:
 		repeatDictionaryArray(builder.(array.DictionaryBuilder), arr, count)
 	default:
@@ -1132,6 +1144,19 @@ func repeatFloat64Array(
 	b.AppendValues(vals, nil)
 }
 
+func repeatFloat32Array(
+	b *array.Float32Builder,
+	arr *array.Float32,
+	count int,
+) {
+	val := arr.Value(arr.Len() - 1)
+	vals := make([]float32, count)
+	for i := 0; i < count; i++ {
+		vals[i] = val
+	}
+	b.AppendValues(vals, nil)
+}
+
 func repeatDictionaryArray(b array.DictionaryBuilder, arr *array.Dictionary, count int) {
 	switch db := b.(type) {
 	case *array.BinaryDictionaryBuilder:
@@ -1157,7 +1182,9 @@ func repeatDictionaryArray(b array.DictionaryBuilder, arr *array.Dictionary, cou
 	}
 }
 
-func mergeArrowSchemas(schemas []*arrow.Schema) *arrow.Schema {
+// MergeArrowSchemas returns the union of the fields of the given schemas,
+// sorted by name. If fields with the same name are found, the first is used.
+func MergeArrowSchemas(schemas []*arrow.Schema) *arrow.Schema {
 	fieldNames := make([]string, 0, 16)
 	fieldsMap := make(map[string]arrow.Field)
 
@@ -1199,6 +1226,62 @@ func ColToWriter(col int, writers []MultiColumnWriter) writer.ValueWriter {
 	return nil
 }
 
+// Unify returns the record with the given schema. Fields of the schema missing
+// from the record are padded with nulls allocated with the given allocator.
+// Columns of the record that are not part of the schema are dropped.
+func Unify(mem memory.Allocator, r arrow.Record, schema *arrow.Schema) (arrow.Record, error) {
+	if schema.Equal(r.Schema()) {
+		r.Retain()
+		return r, nil
+	}
+
+	cols := make([]arrow.Array, 0, schema.NumFields())
+	defer func() {
+		for _, c := range cols {
+			c.Release()
+		}
+	}()
+	for _, field := range schema.Fields() {
+		indices := r.Schema().FieldIndices(field.Name)
+		if len(indices) == 0 {
+			cols = append(cols, array.MakeArrayOfNull(mem, field.Type, int(r.NumRows())))
+			continue
+		}
+		col, err := castColumn(mem, r.Column(indices[0]), field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("unify column %s: %w", field.Name, err)
+		}
+		cols = append(cols, col)
+	}
+	return array.NewRecord(schema, cols, r.NumRows()), nil
+}
+
+// castColumn returns the given column with the given type. Dictionaries are
+// cast by casting their indices, e.g. when Arrow and Parquet parts use
+// different index types.
+func castColumn(mem memory.Allocator, col arrow.Array, dt arrow.DataType) (arrow.Array, error) {
+	if arrow.TypeEqual(col.DataType(), dt) {
+		col.Retain()
+		return col, nil
+	}
+
+	ctx := compute.WithAllocator(context.Background(), mem)
+	dict, ok := col.(*array.Dictionary)
+	dictType, isDict := dt.(*arrow.DictionaryType)
+	if !ok || !isDict {
+		return compute.CastArray(ctx, col, compute.SafeCastOptions(dt))
+	}
+	if !arrow.TypeEqual(dict.Dictionary().DataType(), dictType.ValueType) {
+		return nil, fmt.Errorf("dictionary values of type %s can't be cast to %s", dict.Dictionary().DataType(), dictType.ValueType)
+	}
+	indices, err := compute.CastArray(ctx, dict.Indices(), compute.SafeCastOptions(dictType.IndexType))
+	if err != nil {
+		return nil, err
+	}
+	defer indices.Release()
+	return array.NewDictionaryArray(dt, indices, dict.Dictionary()), nil
+}
+
 // Project will project the record according to the given projections.
 func Project(r arrow.Record, projections []logicalplan.Expr) arrow.Record {
 	if len(projections) == 0 {
@@ -845,3 +845,92 @@ func Test_ParquetToArrow(t *testing.T) {
 	defer r.Release()
 	require.Equal(t, int64(1000), r.NumRows())
 }
+
+func TestOrderColumns(t *testing.T) {
+	schema := dynparquet.NewSampleSchema()
+	record := func(names ...string) arrow.Record {
+		fields := make([]arrow.Field, 0, len(names))
+		cols := make([]arrow.Array, 0, len(names))
+		for _, name := range names {
+			b := array.NewInt64Builder(memory.DefaultAllocator)
+			b.Append(1)
+			fields = append(fields, arrow.Field{Name: name, Type: arrow.PrimitiveTypes.Int64})
+			cols = append(cols, b.NewArray())
+		}
+		return array.NewRecord(arrow.NewSchema(fields, nil), cols, 1)
+	}
+	names := func(r arrow.Record) []string {
+		var names []string
+		for _, f := range r.Schema().Fields() {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+
+	a := OrderColumns(record("value", "sum", "labels.b", "timestamp", "count", "labels.a", "example_type"), schema)
+	require.Equal(t, []string{"example_type", "labels.a", "labels.b", "timestamp", "value", "sum", "count"}, names(a))
+	b := OrderColumns(record("labels.a", "example_type", "timestamp", "labels.b", "value", "sum", "count"), schema)
+	require.Equal(t, names(a), names(b))
+
+	fingerprint := func(r arrow.Record) string {
+		i := r.Schema().Metadata().FindKey(SchemaFingerprintKey)
+		require.NotEqual(t, -1, i)
+		return r.Schema().Metadata().Values()[i]
+	}
+	require.Equal(t, fingerprint(a), fingerprint(b))
+	c := OrderColumns(record("example_type", "labels.c"), schema)
+	require.NotEqual(t, fingerprint(a), fingerprint(c))
+}
+
+func TestConvertOutputTypes(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+
+	db := array.NewDictionaryBuilder(mem, &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Uint32, ValueType: arrow.BinaryTypes.Binary}).(*array.BinaryDictionaryBuilder)
+	defer db.Release()
+	require.NoError(t, db.AppendString("a"))
+	db.AppendNull()
+	require.NoError(t, db.AppendString("a"))
+	dict := db.NewArray()
+	defer dict.Release()
+
+	tsb := array.NewTimestampBuilder(mem, &arrow.TimestampType{Unit: arrow.Millisecond})
+	defer tsb.Release()
+	tsb.AppendValues([]arrow.Timestamp{1, 2, 3}, nil)
+	ts := tsb.NewArray()
+	defer ts.Release()
+
+	ib := array.NewInt64Builder(mem)
+	defer ib.Release()
+	ib.AppendValues([]int64{4, 5, 6}, nil)
+	i64 := ib.NewArray()
+	defer i64.Release()
+
+	r := array.NewRecord(arrow.NewSchema([]arrow.Field{
+		{Name: "labels.a", Type: dict.DataType(), Nullable: true},
+		{Name: "time", Type: ts.DataType()},
+		{Name: "timestamp", Type: i64.DataType()},
+	}, nil), []arrow.Array{dict, ts, i64}, 3)
+	defer r.Release()
+
+	converted, err := ConvertOutputTypes(mem, r, OutputTypes{
+		ExpandDictionaries: true,
+		TimestampsAsInt64:  true,
+		TimestampColumns:   []string{"timestamp"},
+		TimestampUnit:      arrow.Nanosecond,
+	})
+	require.NoError(t, err)
+	defer converted.Release()
+
+	require.Equal(t, arrow.BinaryTypes.Binary, converted.Schema().Field(0).Type)
+	labels := converted.Column(0).(*array.Binary)
+	require.Equal(t, "a", labels.ValueString(0))
+	require.True(t, labels.IsNull(1))
+	require.Equal(t, "a", labels.ValueString(2))
+
+	require.Equal(t, arrow.PrimitiveTypes.Int64, converted.Schema().Field(1).Type)
+	require.Equal(t, []int64{1, 2, 3}, converted.Column(1).(*array.Int64).Int64Values())
+
+	require.Equal(t, &arrow.TimestampType{Unit: arrow.Nanosecond}, converted.Schema().Field(2).Type)
+	require.Equal(t, []arrow.Timestamp{4, 5, 6}, converted.Column(2).(*array.Timestamp).TimestampValues())
+}
@@ -1,6 +1,8 @@
 package arrowutils
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"fmt"
 	"sort"
@@ -9,6 +11,8 @@ import (
 	"github.com/apache/arrow/go/v14/arrow/array"
 	"github.com/apache/arrow/go/v14/arrow/compute"
 	"github.com/apache/arrow/go/v14/arrow/memory"
+
+	"github.com/polarsignals/frostdb/pqarrow/builder"
 )
 
 // SortRecord sorts the given record's rows by the given column. Currently only supports int64, string and binary columns.
@@ -49,6 +53,137 @@ func SortRecord(mem memory.Allocator, r arrow.Record, cols []int) (*array.Int64,
 	return indicesBuilder.NewInt64Array(), nil
 }
 
+// SortingColumn is a column to sort the rows of a record by.
+type SortingColumn struct {
+	// Index is the index of the column in the record.
+	Index      int
+	Descending bool
+	NullsFirst bool
+}
+
+// SortRecordByColumns returns the indices of the record's rows sorted by the
+// given columns. The sort is stable. Supports boolean, int64, uint64, float64,
+// string and binary columns, and dictionaries of string and binary values.
+func SortRecordByColumns(mem memory.Allocator, r arrow.Record, cols []SortingColumn) (*array.Int64, error) {
+	compare, err := NewRowComparator(r, cols)
+	if err != nil {
+		return nil, err
+	}
+
+	indices := make([]int64, r.NumRows())
+	for i := range indices {
+		indices[i] = int64(i)
+	}
+	sort.SliceStable(indices, func(a, b int) bool {
+		return compare(int(indices[a]), int(indices[b])) < 0
+	})
+
+	indicesBuilder := array.NewInt64Builder(mem)
+	defer indicesBuilder.Release()
+	indicesBuilder.AppendValues(indices, nil)
+	return indicesBuilder.NewInt64Array(), nil
+}
+
+// Sortable returns true if rows can be sorted by the given array, see
+// SortRecordByColumns.
+func Sortable(arr arrow.Array) bool {
+	_, err := columnComparator(arr, SortingColumn{})
+	return err == nil
+}
+
+// FirstUnsortedRow returns the index of the first row of the record that
+// sorts before the previous row by the given columns, or -1 if the rows are
+// sorted.
+func FirstUnsortedRow(r arrow.Record, cols []SortingColumn) (int64, error) {
+	compare, err := NewRowComparator(r, cols)
+	if err != nil {
+		return 0, err
+	}
+	for i := 1; i < int(r.NumRows()); i++ {
+		if compare(i-1, i) > 0 {
+			return int64(i), nil
+		}
+	}
+	return -1, nil
+}
+
+// NewRowComparator returns a function comparing two rows of the record by the
+// given columns. It returns a negative number if row i sorts before row j, a
+// positive number if it sorts after row j and 0 if the rows are equal by the
+// columns.
+func NewRowComparator(r arrow.Record, cols []SortingColumn) (func(i, j int) int, error) {
+	comparators := make([]func(i, j int) int, 0, len(cols))
+	for _, col := range cols {
+		compare, err := columnComparator(r.Column(col.Index), col)
+		if err != nil {
+			return nil, err
+		}
+		comparators = append(comparators, compare)
+	}
+	return func(i, j int) int {
+		for _, compare := range comparators {
+			if c := compare(i, j); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}, nil
+}
+
+// columnComparator returns a function comparing two rows of the given array
+// in the order of the given sorting column.
+func columnComparator(arr arrow.Array, col SortingColumn) (func(i, j int) int, error) {
+	var compare func(i, j int) int
+	switch a := arr.(type) {
+	case *array.Boolean:
+		compare = func(i, j int) int { return compareBools(a.Value(i), a.Value(j)) }
+	case *array.Int64:
+		compare = func(i, j int) int { return cmp.Compare(a.Value(i), a.Value(j)) }
+	case *array.Uint64:
+		compare = func(i, j int) int { return cmp.Compare(a.Value(i), a.Value(j)) }
+	case *array.Float64:
+		compare = func(i, j int) int { return cmp.Compare(a.Value(i), a.Value(j)) }
+	case *array.Float32:
+		compare = func(i, j int) int { return cmp.Compare(a.Value(i), a.Value(j)) }
+	case *array.String:
+		compare = func(i, j int) int { return cmp.Compare(a.Value(i), a.Value(j)) }
+	case *array.Binary:
+		compare = func(i, j int) int { return bytes.Compare(a.Value(i), a.Value(j)) }
+	case *array.Dictionary:
+		switch dict := a.Dictionary().(type) {
+		case *array.String:
+			compare = func(i, j int) int {
+				return cmp.Compare(dict.Value(a.GetValueIndex(i)), dict.Value(a.GetValueIndex(j)))
+			}
+		case *array.Binary:
+			compare = func(i, j int) int {
+				return bytes.Compare(dict.Value(a.GetValueIndex(i)), dict.Value(a.GetValueIndex(j)))
+			}
+		default:
+			return nil, fmt.Errorf("unsupported dictionary type for sorting %T", dict)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported column type for sorting %T", a)
+	}
+
+	return func(i, j int) int {
+		iNull, jNull := arr.IsNull(i), arr.IsNull(j)
+		if iNull || jNull {
+			if iNull == jNull {
+				return 0
+			}
+			if iNull == col.NullsFirst {
+				return -1
+			}
+			return 1
+		}
+		if col.Descending {
+			return -compare(i, j)
+		}
+		return compare(i, j)
+	}, nil
+}
+
 // ReorderRecord reorders the given record's rows by the given indices.
 // This is a wrapper around compute.Take which handles the type castings.
 func ReorderRecord(ctx context.Context, r arrow.Record, indices arrow.Array) (arrow.Record, error) {
@@ -64,6 +199,21 @@ func ReorderRecord(ctx context.Context, r arrow.Record, indices arrow.Array) (ar
 	return res.(*compute.RecordDatum).Value, nil
 }
 
+// TakeRecord returns a record of the given record's rows at the given indices.
+// Unlike ReorderRecord, it supports dictionary columns.
+func TakeRecord(mem memory.Allocator, r arrow.Record, indices *array.Int64) (arrow.Record, error) {
+	recordBuilder := builder.NewRecordBuilder(mem, r.Schema())
+	defer recordBuilder.Release()
+	for _, i := range indices.Int64Values() {
+		for colIdx, b := range recordBuilder.Fields() {
+			if err := builder.AppendValue(b, r.Column(colIdx), int(i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return recordBuilder.NewRecord(), nil
+}
+
 type orderedArray[T int64 | float64 | string] interface {
 	Value(int) T
 	IsNull(int) bool
@@ -81,3 +81,59 @@ func TestSortRecord(t *testing.T) {
 		require.True(t, stringCol.IsNull(stringCol.Len()-1)) // last is NULL
 	}
 }
+
+func TestSortRecordByColumns(t *testing.T) {
+	schema := arrow.NewSchema(
+		[]arrow.Field{
+			{Name: "string", Type: &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.Binary}},
+			{Name: "int", Type: arrow.PrimitiveTypes.Int64},
+		},
+		nil,
+	)
+
+	mem := memory.DefaultAllocator
+	db := array.NewDictionaryBuilder(mem, schema.Field(0).Type.(*arrow.DictionaryType)).(*array.BinaryDictionaryBuilder)
+	defer db.Release()
+	require.NoError(t, db.AppendString("b"))
+	db.AppendNull()
+	require.NoError(t, db.AppendString("a"))
+	require.NoError(t, db.AppendString("b"))
+	require.NoError(t, db.AppendString("a"))
+
+	ib := array.NewInt64Builder(mem)
+	defer ib.Release()
+	ib.AppendValues([]int64{1, 2, 3, 4, 5}, nil)
+
+	record := array.NewRecord(schema, []arrow.Array{db.NewArray(), ib.NewArray()}, 5)
+	defer record.Release()
+
+	for _, tc := range []struct {
+		cols    []SortingColumn
+		indices []int64
+	}{
+		{cols: []SortingColumn{{Index: 0}}, indices: []int64{2, 4, 0, 3, 1}},
+		{cols: []SortingColumn{{Index: 0, NullsFirst: true}}, indices: []int64{1, 2, 4, 0, 3}},
+		{cols: []SortingColumn{{Index: 0}, {Index: 1, Descending: true}}, indices: []int64{4, 2, 3, 0, 1}},
+		{cols: []SortingColumn{{Index: 0, Descending: true, NullsFirst: true}}, indices: []int64{1, 0, 3, 2, 4}},
+	} {
+		indices, err := SortRecordByColumns(mem, record, tc.cols)
+		require.NoError(t, err)
+		require.Equal(t, tc.indices, indices.Int64Values())
+
+		sorted, err := TakeRecord(mem, record, indices)
+		require.NoError(t, err)
+		row, err := FirstUnsortedRow(sorted, tc.cols)
+		require.NoError(t, err)
+		require.Equal(t, int64(-1), row)
+		sorted.Release()
+		indices.Release()
+	}
+
+	// Nulls sort last, so the "a" following the null is the first unsorted row.
+	row, err := FirstUnsortedRow(record, []SortingColumn{{Index: 0}})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), row)
+	row, err = FirstUnsortedRow(record, []SortingColumn{{Index: 1}})
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), row)
+}
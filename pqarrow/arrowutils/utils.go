@@ -13,6 +13,8 @@ type ArrayConcatenator struct {
 	arrs []arrow.Array
 }
 
+// Add adds the array to the concatenation. The concatenator takes ownership of
+// the array, which is released once concatenated or by Release.
 func (c *ArrayConcatenator) Add(arr arrow.Array) {
 	c.arrs = append(c.arrs, arr)
 }
@@ -22,7 +24,7 @@ func (c *ArrayConcatenator) NewArray(mem memory.Allocator) (arrow.Array, error)
 	if err != nil {
 		return nil, err
 	}
-	c.arrs = c.arrs[:0]
+	c.Release()
 	return arr, err
 }
 
@@ -65,8 +65,12 @@ func AppendValue(cb ColumnBuilder, arr arrow.Array, i int) error {
 		b.AppendSingle(arr.(*array.Boolean).Value(i))
 	case *array.Int64Builder:
 		b.Append(arr.(*array.Int64).Value(i))
+	case *array.Uint64Builder:
+		b.Append(arr.(*array.Uint64).Value(i))
 	case *array.Float64Builder:
 		b.Append(arr.(*array.Float64).Value(i))
+	case *array.Float32Builder:
+		b.Append(arr.(*array.Float32).Value(i))
 	case *array.StringBuilder:
 		b.Append(arr.(*array.String).Value(i))
 	case *array.BinaryBuilder:
@@ -101,6 +105,46 @@ func AppendValue(cb ColumnBuilder, arr arrow.Array, i int) error {
 		defer values.Release()
 
 		switch v := values.(type) {
+		case *array.Int64:
+			b.Append(true)
+			for j := 0; j < v.Len(); j++ {
+				switch bldr := vb.(type) {
+				case *OptInt64Builder:
+					bldr.Append(v.Value(j))
+				default:
+					return fmt.Errorf("uknown value builder type %T", bldr)
+				}
+			}
+		case *array.Float64:
+			b.Append(true)
+			for j := 0; j < v.Len(); j++ {
+				switch bldr := vb.(type) {
+				case *array.Float64Builder:
+					bldr.Append(v.Value(j))
+				default:
+					return fmt.Errorf("uknown value builder type %T", bldr)
+				}
+			}
+		case *array.Float32:
+			b.Append(true)
+			for j := 0; j < v.Len(); j++ {
+				switch bldr := vb.(type) {
+				case *array.Float32Builder:
+					bldr.Append(v.Value(j))
+				default:
+					return fmt.Errorf("uknown value builder type %T", bldr)
+				}
+			}
+		case *array.Uint64:
+			b.Append(true)
+			for j := 0; j < v.Len(); j++ {
+				switch bldr := vb.(type) {
+				case *array.Uint64Builder:
+					bldr.Append(v.Value(j))
+				default:
+					return fmt.Errorf("uknown value builder type %T", bldr)
+				}
+			}
 		case *array.Dictionary:
 			switch dict := v.Dictionary().(type) {
 			case *array.Binary:
@@ -163,6 +207,8 @@ func AppendGoValue(cb ColumnBuilder, v any) error {
 		b.AppendSingle(v.(bool))
 	case *array.Int64Builder:
 		b.Append(v.(int64))
+	case *array.Uint64Builder:
+		b.Append(v.(uint64))
 	case *array.StringBuilder:
 		b.Append(v.(string))
 	case *array.BinaryBuilder:
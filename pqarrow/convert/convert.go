@@ -90,6 +90,8 @@ func ParquetNodeToType(n parquet.Node) (arrow.DataType, error) {
 		dt = &arrow.BooleanType{}
 	case t.Kind() == parquet.Double:
 		dt = &arrow.Float64Type{}
+	case t.Kind() == parquet.Float:
+		dt = &arrow.Float32Type{}
 	default:
 		return nil, errors.New("unsupported type: " + n.Type().String())
 	}
@@ -131,6 +133,8 @@ func GetWriter(offset int, n parquet.Node) (writer.NewWriterFunc, error) {
 		wr = writer.NewBooleanValueWriter
 	case *arrow.Float64Type:
 		wr = writer.NewFloat64ValueWriter
+	case *arrow.Float32Type:
+		wr = writer.NewFloat32ValueWriter
 	case *arrow.DictionaryType:
 		wr = writer.NewDictionaryValueWriter
 	default:
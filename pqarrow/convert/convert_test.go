@@ -27,6 +27,10 @@ func TestParquetNodeToType(t *testing.T) {
 			parquetNode: parquet.Uint(64),
 			arrowType:   &arrow.Uint64Type{},
 		},
+		{
+			parquetNode: parquet.Leaf(parquet.FloatType),
+			arrowType:   &arrow.Float32Type{},
+		},
 		{
 			parquetNode: parquet.Leaf(parquet.BooleanType),
 			arrowType:   &arrow.BooleanType{},
@@ -68,10 +72,6 @@ func TestParquetNodeToType(t *testing.T) {
 			parquetNode: parquet.Leaf(parquet.Int96Type),
 			msg:         "unsupported type: INT96",
 		},
-		{
-			parquetNode: parquet.Leaf(parquet.FloatType),
-			msg:         "unsupported type: FLOAT",
-		},
 		{
 			parquetNode: parquet.Leaf(parquet.ByteArrayType),
 			msg:         "unsupported type: BYTE_ARRAY",
@@ -0,0 +1,92 @@
+package pqarrow
+
+import (
+	"encoding/hex"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+)
+
+// SchemaFingerprintKey is the key of the schema metadata holding the
+// fingerprint of the schema of records ordered by OrderColumns.
+const SchemaFingerprintKey = "frostdb.schema_fingerprint"
+
+// OrderColumns returns the record with its columns in a deterministic order:
+// the columns of the given schema in the order they are defined, with the
+// concrete columns of dynamic columns ordered by name, followed by the other
+// columns of the record, e.g. computed columns, in their current order. The
+// schema of the returned record holds its fingerprint under
+// SchemaFingerprintKey. The schema may be nil, in which case only the
+// fingerprint is added.
+func OrderColumns(r arrow.Record, schema *dynparquet.Schema) arrow.Record {
+	type rank struct {
+		column int
+		name   string
+		index  int
+	}
+	fields := r.Schema().Fields()
+	ranks := make([]rank, len(fields))
+	for i, f := range fields {
+		ranks[i] = rank{column: columnRank(schema, f.Name), name: f.Name, index: i}
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		if ranks[i].column != ranks[j].column {
+			return ranks[i].column < ranks[j].column
+		}
+		if ranks[i].column == len(schemaColumns(schema)) {
+			// Keep the order of columns not part of the schema.
+			return ranks[i].index < ranks[j].index
+		}
+		return ranks[i].name < ranks[j].name
+	})
+
+	ordered := make([]arrow.Field, 0, len(fields))
+	cols := make([]arrow.Array, 0, len(fields))
+	for _, rk := range ranks {
+		ordered = append(ordered, fields[rk.index])
+		cols = append(cols, r.Column(rk.index))
+	}
+	md := arrow.NewMetadata(
+		[]string{SchemaFingerprintKey},
+		[]string{SchemaFingerprint(arrow.NewSchema(ordered, nil))},
+	)
+	return array.NewRecord(arrow.NewSchema(ordered, &md), cols, r.NumRows())
+}
+
+// SchemaFingerprint returns a fingerprint of the names, types and order of the
+// fields of the given schema. Schemas with the same fingerprint can be read
+// with the same column mapping.
+func SchemaFingerprint(schema *arrow.Schema) string {
+	h := fnv.New64a()
+	for _, f := range schema.Fields() {
+		_, _ = h.Write([]byte(f.Name))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(f.Type.String()))
+		_, _ = h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func schemaColumns(schema *dynparquet.Schema) []dynparquet.ColumnDefinition {
+	if schema == nil {
+		return nil
+	}
+	return schema.Columns()
+}
+
+// columnRank returns the index of the schema column the named column belongs
+// to, or the number of columns of the schema if it doesn't belong to any.
+func columnRank(schema *dynparquet.Schema, name string) int {
+	columns := schemaColumns(schema)
+	for i, col := range columns {
+		if col.Name == name || (col.Dynamic && strings.HasPrefix(name, col.Name+".")) {
+			return i
+		}
+	}
+	return len(columns)
+}
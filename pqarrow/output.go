@@ -0,0 +1,81 @@
+package pqarrow
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/compute"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+)
+
+// OutputTypes describes the Arrow types a client requests query results in,
+// so that consumers with limited Arrow support don't need to convert them.
+type OutputTypes struct {
+	// ExpandDictionaries returns dictionary columns as columns of their
+	// value type.
+	ExpandDictionaries bool
+	// TimestampsAsInt64 returns timestamp columns as int64 columns holding
+	// the timestamps in their unit.
+	TimestampsAsInt64 bool
+	// TimestampColumns are the int64 columns returned as timestamps of
+	// TimestampUnit.
+	TimestampColumns []string
+	// TimestampUnit is the unit of the timestamps of TimestampColumns.
+	TimestampUnit arrow.TimeUnit
+}
+
+// ConvertOutputTypes returns the record with its columns converted to the
+// given output types.
+func ConvertOutputTypes(mem memory.Allocator, r arrow.Record, types OutputTypes) (arrow.Record, error) {
+	fields := make([]arrow.Field, 0, r.NumCols())
+	cols := make([]arrow.Array, 0, r.NumCols())
+	defer func() {
+		for _, c := range cols {
+			c.Release()
+		}
+	}()
+	for i, f := range r.Schema().Fields() {
+		col, err := convertOutputType(mem, f.Name, r.Column(i), types)
+		if err != nil {
+			return nil, fmt.Errorf("convert column %s: %w", f.Name, err)
+		}
+		f.Type = col.DataType()
+		fields = append(fields, f)
+		cols = append(cols, col)
+	}
+	md := r.Schema().Metadata()
+	return array.NewRecord(arrow.NewSchema(fields, &md), cols, r.NumRows()), nil
+}
+
+func convertOutputType(mem memory.Allocator, name string, col arrow.Array, types OutputTypes) (arrow.Array, error) {
+	switch c := col.(type) {
+	case *array.Dictionary:
+		if types.ExpandDictionaries {
+			ctx := compute.WithAllocator(context.Background(), mem)
+			return compute.TakeArray(ctx, c.Dictionary(), c.Indices())
+		}
+	case *array.Timestamp:
+		if types.TimestampsAsInt64 {
+			return withType(col, arrow.PrimitiveTypes.Int64), nil
+		}
+	case *array.Int64:
+		for _, tc := range types.TimestampColumns {
+			if tc == name {
+				return withType(col, &arrow.TimestampType{Unit: types.TimestampUnit}), nil
+			}
+		}
+	}
+	col.Retain()
+	return col, nil
+}
+
+// withType returns the given array as an array of the given type with the
+// same memory layout, without copying its buffers.
+func withType(col arrow.Array, dt arrow.DataType) arrow.Array {
+	data := col.Data()
+	converted := array.NewData(dt, data.Len(), data.Buffers(), data.Children(), data.NullN(), data.Offset())
+	defer converted.Release()
+	return array.MakeFromData(converted)
+}
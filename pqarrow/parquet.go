@@ -22,6 +22,12 @@ func ArrowScalarToParquetValue(sc scalar.Scalar) (parquet.Value, error) {
 		return parquet.ValueOf(string(s.Data())), nil
 	case *scalar.Int64:
 		return parquet.ValueOf(s.Value), nil
+	case *scalar.Uint64:
+		return parquet.ValueOf(s.Value), nil
+	case *scalar.Float64:
+		return parquet.ValueOf(s.Value), nil
+	case *scalar.Float32:
+		return parquet.ValueOf(s.Value), nil
 	case *scalar.FixedSizeBinary:
 		width := s.Type.(*arrow.FixedSizeBinaryType).ByteWidth
 		v := [16]byte{}
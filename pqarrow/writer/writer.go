@@ -257,6 +257,65 @@ func (w *float64ValueWriter) WritePage(p parquet.Page) error {
 	return nil
 }
 
+type float32ValueWriter struct {
+	b   *array.Float32Builder
+	buf []float32
+}
+
+func NewFloat32ValueWriter(b builder.ColumnBuilder, numValues int) ValueWriter {
+	res := &float32ValueWriter{
+		b: b.(*array.Float32Builder),
+	}
+	res.b.Reserve(numValues)
+	return res
+}
+
+func (w *float32ValueWriter) Write(values []parquet.Value) {
+	for _, v := range values {
+		if v.IsNull() {
+			w.b.AppendNull()
+		} else {
+			w.b.Append(v.Float())
+		}
+	}
+}
+
+func (w *float32ValueWriter) WritePage(p parquet.Page) error {
+	reader := p.Values()
+
+	freader, ok := reader.(parquet.FloatReader)
+	if ok {
+		// fast path
+		if w.buf == nil {
+			w.buf = make([]float32, p.NumValues())
+		}
+		values := w.buf
+		for {
+			n, err := freader.ReadFloats(values)
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("read values: %w", err)
+			}
+
+			w.b.AppendValues(values[:n], nil)
+			if err == io.EOF {
+				break
+			}
+		}
+		return nil
+	}
+
+	values := make([]parquet.Value, p.NumValues())
+	_, err := reader.ReadValues(values)
+	// We're reading all values in the page so we always expect an io.EOF.
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("read values: %w", err)
+	}
+
+	w.Write(values)
+
+	return nil
+}
+
 type booleanValueWriter struct {
 	b       *builder.OptBooleanBuilder
 	scratch struct {
@@ -400,6 +459,18 @@ func (s *structWriter) findLeafBuilder(searchIndex, currentIndex int, builder ar
 			}
 			return 1, true
 		}
+	case *array.Float32Builder:
+		if searchIndex == currentIndex {
+			for _, v := range values {
+				switch v.IsNull() {
+				case true:
+					b.AppendNull()
+				default:
+					b.Append(v.Float())
+				}
+			}
+			return 1, true
+		}
 	case *array.StringBuilder:
 		if searchIndex == currentIndex {
 			for _, v := range values {
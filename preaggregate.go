@@ -0,0 +1,686 @@
+package frostdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/go-kit/log/level"
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/pqarrow"
+	"github.com/polarsignals/frostdb/pqarrow/builder"
+	"github.com/polarsignals/frostdb/pqarrow/convert"
+	"github.com/polarsignals/frostdb/query/expr"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// maxPreAggregateBuckets is the maximum number of buckets of the
+// pre-aggregates of a block. Blocks with more buckets are persisted without
+// pre-aggregates.
+const maxPreAggregateBuckets = 1024
+
+// blockPreAggregates are the number of rows and the sums, minimums and
+// maximums of the numeric columns of a block, per bucket of rows with the
+// same values of the key columns.
+type blockPreAggregates struct {
+	// Keys are the columns the rows are bucketed by.
+	Keys []preAggregateKey `json:"keys"`
+	// Columns are the pre-aggregated columns and their statistics over the
+	// whole block. Columns with null values are not pre-aggregated.
+	Columns []tableManifestColumn `json:"columns"`
+	Buckets []preAggregateBucket  `json:"buckets"`
+}
+
+type preAggregateKey struct {
+	Name string       `json:"name"`
+	Kind parquet.Kind `json:"kind"`
+}
+
+type preAggregateBucket struct {
+	// Key are the plain encoded values of the key columns of the rows of the
+	// bucket, nil for null values.
+	Key     [][]byte `json:"key"`
+	NumRows int64    `json:"num_rows"`
+	// Aggregates are the aggregates of the values of the pre-aggregated
+	// columns of the rows of the bucket, in the order of the columns.
+	Aggregates []preAggregate `json:"aggregates"`
+}
+
+// preAggregate holds the plain encoded sum, minimum and maximum of values.
+type preAggregate struct {
+	Sum []byte `json:"sum"`
+	Min []byte `json:"min"`
+	Max []byte `json:"max"`
+}
+
+// PreAggregateColumns returns the columns the pre-aggregates of the blocks of
+// the table are bucketed by, or nil if the table doesn't pre-aggregate its
+// blocks.
+func (t *Table) PreAggregateColumns() []string {
+	config := t.config.Load()
+	if config == nil || config.PreAggregateSortingColumns == 0 || t.schema.UniquePrimaryIndex {
+		// Blocks of tables with a unique primary index are deduplicated
+		// when they are persisted, pre-aggregates would count duplicates.
+		return nil
+	}
+	sorting := t.schema.SortingColumns()
+	if config.PreAggregateSortingColumns > uint64(len(sorting)) {
+		return nil
+	}
+	columns := make([]string, 0, config.PreAggregateSortingColumns)
+	for _, col := range sorting[:config.PreAggregateSortingColumns] {
+		if col.Dynamic || col.StorageLayout.Repeated() {
+			return nil
+		}
+		switch col.StorageLayout.Type().Kind() {
+		case parquet.ByteArray, parquet.Int64:
+		default:
+			return nil
+		}
+		columns = append(columns, col.Name)
+	}
+	return columns
+}
+
+// preAggregates computes the pre-aggregates of the block. They are nil if
+// the table doesn't pre-aggregate its blocks or the block has too many
+// buckets.
+func (t *TableBlock) preAggregates(ctx context.Context) (*blockPreAggregates, error) {
+	keys := t.table.PreAggregateColumns()
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	a := &preAggregator{buckets: map[string]*preAggregateState{}}
+	projection := make([]logicalplan.Expr, 0, len(keys))
+	for _, name := range keys {
+		col, _ := t.table.schema.ColumnByName(name)
+		a.keys = append(a.keys, preAggregateKey{Name: name, Kind: col.StorageLayout.Type().Kind()})
+		projection = append(projection, logicalplan.Col(name))
+	}
+	for _, col := range t.table.schema.Columns() {
+		if col.Dynamic || col.StorageLayout.Repeated() || slices.Contains(keys, col.Name) {
+			continue
+		}
+		switch kind := col.StorageLayout.Type().Kind(); kind {
+		case parquet.Int64, parquet.Double:
+			a.columns = append(a.columns, preAggregatedColumn{name: col.Name, kind: kind})
+			projection = append(projection, logicalplan.Col(col.Name))
+		}
+	}
+
+	converter := pqarrow.NewParquetConverter(memory.DefaultAllocator, logicalplan.IterOptions{
+		PhysicalProjection: projection,
+	})
+	defer converter.Close()
+	if err := t.index.Scan(ctx, "", t.table.schema, nil, math.MaxUint64, func(ctx context.Context, v any) error {
+		switch v := v.(type) {
+		case arrow.Record:
+			defer v.Release()
+			return a.add(v)
+		case dynparquet.DynamicRowGroup:
+			err := converter.Convert(ctx, v)
+			releaseRowGroup(v)
+			if err != nil {
+				return err
+			}
+			r := converter.NewRecord()
+			converter.Reset()
+			if r == nil {
+				return nil
+			}
+			defer r.Release()
+			return a.add(r)
+		default:
+			return fmt.Errorf("unknown row group type: %T", v)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	return a.result(), nil
+}
+
+// preAggregator computes the pre-aggregates of records.
+type preAggregator struct {
+	keys    []preAggregateKey
+	columns []preAggregatedColumn
+	buckets map[string]*preAggregateState
+	// overflow is set once the records have more buckets than can be
+	// pre-aggregated.
+	overflow bool
+}
+
+type preAggregatedColumn struct {
+	name string
+	kind parquet.Kind
+	// nulls is set once a null value of the column was seen.
+	nulls bool
+}
+
+type preAggregateState struct {
+	key        [][]byte
+	rows       int64
+	aggregates []aggregateState
+}
+
+type aggregateState struct {
+	sumInt, minInt, maxInt       int64
+	sumFloat, minFloat, maxFloat float64
+}
+
+func (a *preAggregator) add(r arrow.Record) error {
+	if a.overflow {
+		return nil
+	}
+	keyArrays := make([]arrow.Array, len(a.keys))
+	for i, key := range a.keys {
+		if indices := r.Schema().FieldIndices(key.Name); len(indices) > 0 {
+			keyArrays[i] = r.Column(indices[0])
+		}
+	}
+	valueArrays := make([]arrow.Array, len(a.columns))
+	for i := range a.columns {
+		indices := r.Schema().FieldIndices(a.columns[i].name)
+		if len(indices) == 0 || r.Column(indices[0]).NullN() > 0 {
+			a.columns[i].nulls = true
+			continue
+		}
+		valueArrays[i] = r.Column(indices[0])
+	}
+
+	var (
+		id  []byte
+		key = make([][]byte, len(a.keys))
+	)
+	for row := 0; row < int(r.NumRows()); row++ {
+		id = id[:0]
+		for i, arr := range keyArrays {
+			v, err := preAggregateKeyValue(arr, row)
+			if err != nil {
+				return fmt.Errorf("key column %s: %w", a.keys[i].Name, err)
+			}
+			key[i] = v
+			if v == nil {
+				id = append(id, 0)
+				continue
+			}
+			id = append(id, 1)
+			id = binary.AppendUvarint(id, uint64(len(v)))
+			id = append(id, v...)
+		}
+		bucket, ok := a.buckets[string(id)]
+		if !ok {
+			if len(a.buckets) == maxPreAggregateBuckets {
+				a.overflow = true
+				return nil
+			}
+			bucket = &preAggregateState{
+				key:        make([][]byte, len(key)),
+				aggregates: make([]aggregateState, len(a.columns)),
+			}
+			for i, v := range key {
+				bucket.key[i] = bytes.Clone(v)
+			}
+			a.buckets[string(id)] = bucket
+		}
+
+		first := bucket.rows == 0
+		bucket.rows++
+		for i, arr := range valueArrays {
+			if a.columns[i].nulls {
+				continue
+			}
+			state := &bucket.aggregates[i]
+			switch arr := arr.(type) {
+			case *array.Int64:
+				v := arr.Value(row)
+				state.sumInt += v
+				if first || v < state.minInt {
+					state.minInt = v
+				}
+				if first || v > state.maxInt {
+					state.maxInt = v
+				}
+			case *array.Float64:
+				v := arr.Value(row)
+				state.sumFloat += v
+				if first || v < state.minFloat {
+					state.minFloat = v
+				}
+				if first || v > state.maxFloat {
+					state.maxFloat = v
+				}
+			default:
+				a.columns[i].nulls = true // not a numeric column after all
+			}
+		}
+	}
+	return nil
+}
+
+// preAggregateKeyValue returns the plain encoded value of the key column at
+// the given index, nil if it is null or the column is missing.
+func preAggregateKeyValue(arr arrow.Array, i int) ([]byte, error) {
+	if arr == nil || arr.IsNull(i) {
+		return nil, nil
+	}
+	switch arr := arr.(type) {
+	case *array.Binary:
+		return arr.Value(i), nil
+	case *array.String:
+		return []byte(arr.Value(i)), nil
+	case *array.Int64:
+		return parquet.Int64Value(arr.Value(i)).Bytes(), nil
+	case *array.Dictionary:
+		switch dict := arr.Dictionary().(type) {
+		case *array.Binary:
+			return dict.Value(arr.GetValueIndex(i)), nil
+		case *array.String:
+			return []byte(dict.Value(arr.GetValueIndex(i))), nil
+		default:
+			return nil, fmt.Errorf("unsupported dictionary type %T", dict)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %T", arr)
+	}
+}
+
+func (a *preAggregator) result() *blockPreAggregates {
+	if a.overflow || len(a.buckets) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(a.buckets))
+	for id := range a.buckets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	p := &blockPreAggregates{Keys: a.keys}
+	columns := make([]int, 0, len(a.columns))
+	for i, col := range a.columns {
+		if !col.nulls {
+			columns = append(columns, i)
+			p.Columns = append(p.Columns, tableManifestColumn{Name: col.name, Kind: col.kind})
+		}
+	}
+	for _, id := range ids {
+		state := a.buckets[id]
+		bucket := preAggregateBucket{
+			Key:        state.key,
+			NumRows:    state.rows,
+			Aggregates: make([]preAggregate, 0, len(columns)),
+		}
+		for j, i := range columns {
+			s := state.aggregates[i]
+			var sum, min, max parquet.Value
+			if a.columns[i].kind == parquet.Int64 {
+				sum, min, max = parquet.Int64Value(s.sumInt), parquet.Int64Value(s.minInt), parquet.Int64Value(s.maxInt)
+			} else {
+				sum, min, max = parquet.DoubleValue(s.sumFloat), parquet.DoubleValue(s.minFloat), parquet.DoubleValue(s.maxFloat)
+			}
+			bucket.Aggregates = append(bucket.Aggregates, preAggregate{
+				Sum: sum.Bytes(),
+				Min: min.Bytes(),
+				Max: max.Bytes(),
+			})
+
+			// Keep track of the statistics of the column over all buckets.
+			col := &p.Columns[j]
+			typ := kindTypes[col.Kind]
+			if col.NumValues == 0 || typ.Compare(min, col.Kind.Value(col.Min)) < 0 {
+				col.Min = min.Bytes()
+			}
+			if col.NumValues == 0 || typ.Compare(max, col.Kind.Value(col.Max)) > 0 {
+				col.Max = max.Bytes()
+			}
+			col.NumValues += state.rows
+		}
+		p.Buckets = append(p.Buckets, bucket)
+	}
+	return p
+}
+
+type preAggregationKey struct{}
+
+// preAggregationRequest is an aggregation whose partial results a scan reads
+// from the pre-aggregates of the blocks it scans where possible.
+type preAggregationRequest struct {
+	schema      *dynparquet.Schema
+	aggregation *logicalplan.Aggregation
+	filter      logicalplan.Expr
+}
+
+func withPreAggregation(ctx context.Context, req *preAggregationRequest) context.Context {
+	return context.WithValue(ctx, preAggregationKey{}, req)
+}
+
+func preAggregationFromContext(ctx context.Context) *preAggregationRequest {
+	req, _ := ctx.Value(preAggregationKey{}).(*preAggregationRequest)
+	return req
+}
+
+// record returns the partial results of the requested aggregation of the rows
+// of the block, one row per bucket, or nil if they can't be computed from the
+// pre-aggregates. The rows hold the values of the key columns of the buckets
+// and the partial results in columns named after the aggregation functions.
+// The filter of the request must match all rows of the block, except for
+// comparisons of key columns, so the rows hold the minimums of the other
+// columns the filter compares, which match the filter too.
+func (p *blockPreAggregates) record(pool memory.Allocator, req *preAggregationRequest) (arrow.Record, error) {
+	keys := make(map[string]int, len(p.Keys))
+	for i, key := range p.Keys {
+		keys[key.Name] = i
+	}
+	columns := make(map[string]int, len(p.Columns))
+	for i, col := range p.Columns {
+		columns[col.Name] = i
+	}
+	for _, groupExpr := range req.aggregation.GroupExprs {
+		col, ok := groupExpr.(*logicalplan.Column)
+		if !ok {
+			return nil, nil
+		}
+		if _, ok := keys[col.ColumnName]; !ok {
+			return nil, nil
+		}
+	}
+
+	var filled []int
+	if req.filter != nil {
+		particulate := tableManifestBlock{Columns: p.Columns}.particulate()
+		for _, conjunct := range conjuncts(req.filter, nil) {
+			keysOnly := true
+			for _, e := range conjunct.ColumnsUsedExprs() {
+				col, ok := e.(*logicalplan.Column)
+				if !ok {
+					return nil, nil
+				}
+				if _, ok := keys[col.ColumnName]; !ok {
+					keysOnly = false
+				}
+			}
+			if keysOnly {
+				// The filter is applied to the values of the key columns of
+				// the buckets.
+				continue
+			}
+			if !expr.AllRowsMatch(conjunct, particulate) {
+				return nil, nil
+			}
+			for _, e := range conjunct.ColumnsUsedExprs() {
+				i, ok := columns[e.(*logicalplan.Column).ColumnName]
+				if !ok {
+					return nil, nil
+				}
+				if !slices.Contains(filled, i) {
+					filled = append(filled, i)
+				}
+			}
+		}
+	}
+
+	fields := make([]arrow.Field, 0, len(p.Keys)+len(filled)+len(req.aggregation.AggExprs))
+	cols := make([]arrow.Array, 0, cap(fields))
+	defer func() {
+		for _, col := range cols {
+			col.Release()
+		}
+	}()
+
+	for i, key := range p.Keys {
+		definition, ok := req.schema.ColumnByName(key.Name)
+		if !ok || definition.StorageLayout.Type().Kind() != key.Kind {
+			return nil, nil
+		}
+		typ, err := convert.ParquetNodeToType(definition.StorageLayout)
+		if err != nil {
+			return nil, err
+		}
+		b := builder.NewBuilder(pool, typ)
+		for _, bucket := range p.Buckets {
+			var v any
+			switch value := bucket.Key[i]; {
+			case value == nil:
+			case key.Kind == parquet.Int64:
+				v = key.Kind.Value(value).Int64()
+			default:
+				v = value
+			}
+			if err := builder.AppendGoValue(b, v); err != nil {
+				b.Release()
+				return nil, err
+			}
+		}
+		fields = append(fields, arrow.Field{Name: key.Name, Type: typ, Nullable: true})
+		cols = append(cols, b.NewArray())
+		b.Release()
+	}
+
+	for _, i := range filled {
+		col := p.Columns[i]
+		values := make([]parquet.Value, len(p.Buckets))
+		for j := range values {
+			values[j] = col.Kind.Value(col.Min)
+		}
+		fields = append(fields, arrow.Field{Name: col.Name, Type: numericType(col.Kind), Nullable: true})
+		cols = append(cols, numericArray(pool, col.Kind, values))
+	}
+
+	added := map[string]struct{}{}
+	for _, aggExpr := range req.aggregation.AggExprs {
+		aggFunc := unaliasAggregation(aggExpr)
+		if aggFunc == nil {
+			return nil, nil
+		}
+		name := aggFunc.Name()
+		if _, ok := added[name]; ok {
+			continue
+		}
+		added[name] = struct{}{}
+
+		// Counts are only pre-aggregated for columns without null values,
+		// which is what pre-aggregated columns are.
+		col, ok := aggFunc.Expr.(*logicalplan.Column)
+		if !ok {
+			return nil, nil
+		}
+		i, ok := columns[col.ColumnName]
+		if !ok {
+			return nil, nil
+		}
+		if aggFunc.Func == logicalplan.AggFuncCount {
+			values := make([]parquet.Value, 0, len(p.Buckets))
+			for _, bucket := range p.Buckets {
+				values = append(values, parquet.Int64Value(bucket.NumRows))
+			}
+			fields = append(fields, arrow.Field{Name: name, Type: arrow.PrimitiveTypes.Int64, Nullable: true})
+			cols = append(cols, numericArray(pool, parquet.Int64, values))
+			continue
+		}
+
+		kind := p.Columns[i].Kind
+		values := make([]parquet.Value, 0, len(p.Buckets))
+		for _, bucket := range p.Buckets {
+			var v []byte
+			switch aggFunc.Func {
+			case logicalplan.AggFuncSum:
+				v = bucket.Aggregates[i].Sum
+			case logicalplan.AggFuncMin:
+				v = bucket.Aggregates[i].Min
+			case logicalplan.AggFuncMax:
+				v = bucket.Aggregates[i].Max
+			default:
+				return nil, nil
+			}
+			values = append(values, kind.Value(v))
+		}
+		fields = append(fields, arrow.Field{Name: name, Type: numericType(kind), Nullable: true})
+		cols = append(cols, numericArray(pool, kind, values))
+	}
+
+	return array.NewRecord(arrow.NewSchema(fields, nil), cols, int64(len(p.Buckets))), nil
+}
+
+// conjuncts appends the conjuncts of the filter to exprs.
+func conjuncts(filter logicalplan.Expr, exprs []logicalplan.Expr) []logicalplan.Expr {
+	if e, ok := filter.(*logicalplan.BinaryExpr); ok && e.Op == logicalplan.OpAnd {
+		return conjuncts(e.Right, conjuncts(e.Left, exprs))
+	}
+	return append(exprs, filter)
+}
+
+func numericType(kind parquet.Kind) arrow.DataType {
+	if kind == parquet.Int64 {
+		return arrow.PrimitiveTypes.Int64
+	}
+	return arrow.PrimitiveTypes.Float64
+}
+
+func numericArray(pool memory.Allocator, kind parquet.Kind, values []parquet.Value) arrow.Array {
+	if kind == parquet.Int64 {
+		b := array.NewInt64Builder(pool)
+		defer b.Release()
+		for _, v := range values {
+			b.Append(v.Int64())
+		}
+		return b.NewArray()
+	}
+	b := array.NewFloat64Builder(pool)
+	defer b.Release()
+	for _, v := range values {
+		b.Append(v.Double())
+	}
+	return b.NewArray()
+}
+
+func unaliasAggregation(e logicalplan.Expr) *logicalplan.AggregationFunction {
+	if alias, ok := e.(*logicalplan.AliasExpr); ok {
+		e = alias.Expr
+	}
+	aggFunc, _ := e.(*logicalplan.AggregationFunction)
+	return aggFunc
+}
+
+// preAggregationCallbacks wraps the given callbacks to be called with the
+// partial results of the given aggregation of the records they are called
+// with.
+func preAggregationCallbacks(pool memory.Allocator, aggregation *logicalplan.Aggregation, callbacks []logicalplan.Callback) []logicalplan.Callback {
+	wrapped := make([]logicalplan.Callback, 0, len(callbacks))
+	for _, callback := range callbacks {
+		callback := callback
+		wrapped = append(wrapped, func(ctx context.Context, r arrow.Record) error {
+			partial, err := partialResults(pool, aggregation, r)
+			if err != nil {
+				return err
+			}
+			defer partial.Release()
+			return callback(ctx, partial)
+		})
+	}
+	return wrapped
+}
+
+// partialResults returns the record with the partial results of the
+// aggregation of each of its rows added, in columns named after the
+// aggregation functions. The partial results of a row are its values, and one
+// or zero for counts of non-null and null values. Records that already hold
+// partial results, that were read from pre-aggregates, are returned as they
+// are.
+func partialResults(pool memory.Allocator, aggregation *logicalplan.Aggregation, r arrow.Record) (arrow.Record, error) {
+	schema := r.Schema()
+	fields := schema.Fields()
+	cols := append(make([]arrow.Array, 0, len(fields)+len(aggregation.AggExprs)), r.Columns()...)
+	var added []arrow.Array
+	defer func() {
+		for _, col := range added {
+			col.Release()
+		}
+	}()
+
+	for _, aggExpr := range aggregation.AggExprs {
+		aggFunc := unaliasAggregation(aggExpr)
+		if aggFunc == nil {
+			return nil, fmt.Errorf("not an aggregation function: %s", aggExpr)
+		}
+		name := aggFunc.Name()
+		if schema.HasField(name) {
+			continue
+		}
+		var (
+			col     arrow.Array
+			indices = schema.FieldIndices(aggFunc.Expr.Name())
+		)
+		switch {
+		case aggFunc.Func == logicalplan.AggFuncCount:
+			b := array.NewInt64Builder(pool)
+			for i := 0; i < int(r.NumRows()); i++ {
+				if len(indices) > 0 && r.Column(indices[0]).IsValid(i) {
+					b.Append(1)
+				} else {
+					b.Append(0)
+				}
+			}
+			col = b.NewArray()
+			b.Release()
+		case len(indices) == 0:
+			continue
+		default:
+			col = r.Column(indices[0])
+			col.Retain()
+		}
+		added = append(added, col)
+		fields = append(fields, arrow.Field{Name: name, Type: col.DataType(), Nullable: true})
+		cols = append(cols, col)
+		schema = arrow.NewSchema(fields, nil)
+	}
+
+	if len(added) == 0 {
+		r.Retain()
+		return r, nil
+	}
+	return array.NewRecord(schema, cols, r.NumRows()), nil
+}
+
+// scanPreAggregates calls the callback with the partial results of the
+// requested aggregation of the rows of the block, read from its
+// pre-aggregates. It returns false if the block has no pre-aggregates or they
+// can't be used for the aggregation, so that the block must be scanned.
+func (b *DefaultObjstoreBucket) scanPreAggregates(ctx context.Context, blockDir string, req *preAggregationRequest, callback func(context.Context, any) error) (bool, error) {
+	manifest, err := b.readBlockManifest(ctx, blockDir)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return false, nil
+	}
+	if manifest.PreAggregates == nil {
+		return false, nil
+	}
+
+	r, err := manifest.PreAggregates.record(memory.DefaultAllocator, req)
+	if err != nil {
+		level.Debug(b.logger).Log("msg", "failed to read pre-aggregates", "block", blockDir, "err", err)
+		return false, nil
+	}
+	if r == nil {
+		return false, nil
+	}
+	if stats := expr.PruningStatsFromContext(ctx); stats != nil {
+		stats.BlocksPreAggregated.Add(1)
+	}
+	if err := callback(ctx, r); err != nil {
+		r.Release()
+		return true, err
+	}
+	return true, nil
+}
@@ -0,0 +1,166 @@
+package frostdb
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/expr"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+func TestTablePreAggregates(t *testing.T) {
+	ctx := context.Background()
+	bucket := objstore.NewInMemBucket()
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithReadWriteStorage(NewDefaultObjstoreBucket(bucket)),
+		WithManualBlockRotation(),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition(), WithPreAggregates(1)))
+	require.NoError(t, err)
+	require.Equal(t, []string{"example_type"}, table.PreAggregateColumns())
+
+	insert := func(samples dynparquet.Samples) uint64 {
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		defer r.Release()
+		tx, err := table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+		return tx
+	}
+
+	samples := dynparquet.NewTestSamples()
+	samples = append(samples, dynparquet.Sample{
+		ExampleType: "memory",
+		Labels:      map[string]string{"node": "test3"},
+		Stacktrace:  samples[0].Stacktrace,
+		Timestamp:   4,
+		Value:       7,
+	})
+	writeTx := insert(samples)
+	blockDir := filepath.Join("test", "test", table.ActiveBlock().ulid.String())
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	db.Wait(writeTx + 2)
+
+	rc, err := bucket.Get(ctx, filepath.Join(blockDir, blockManifestFileName))
+	require.NoError(t, err)
+	manifest := &blockManifest{}
+	require.NoError(t, json.NewDecoder(rc).Decode(manifest))
+	require.NoError(t, rc.Close())
+	require.NotNil(t, manifest.PreAggregates)
+	require.Len(t, manifest.PreAggregates.Buckets, 2)
+
+	// Rows of the active block are aggregated from their values.
+	insert(dynparquet.Samples{{
+		ExampleType: "cpu",
+		Labels:      map[string]string{"node": "test3"},
+		Stacktrace:  samples[0].Stacktrace,
+		Timestamp:   6,
+		Value:       10,
+	}})
+
+	type result struct {
+		sum, count, min, max int64
+	}
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	for _, tc := range []struct {
+		name          string
+		filter        logicalplan.Expr
+		preAggregated int64
+		expected      map[string]result
+	}{
+		{
+			name:          "no_filter",
+			preAggregated: 1,
+			expected: map[string]result{
+				"cpu":    {sum: 21, count: 4, min: 3, max: 10},
+				"memory": {sum: 7, count: 1, min: 7, max: 7},
+			},
+		},
+		{
+			name:          "all_rows_match",
+			filter:        logicalplan.Col("timestamp").GtEq(logicalplan.Literal(int64(1))),
+			preAggregated: 1,
+			expected: map[string]result{
+				"cpu":    {sum: 21, count: 4, min: 3, max: 10},
+				"memory": {sum: 7, count: 1, min: 7, max: 7},
+			},
+		},
+		{
+			name:          "key_filter",
+			filter:        logicalplan.Col("example_type").Eq(logicalplan.Literal("memory")),
+			preAggregated: 1,
+			expected: map[string]result{
+				"memory": {sum: 7, count: 1, min: 7, max: 7},
+			},
+		},
+		{
+			name:   "some_rows_match",
+			filter: logicalplan.Col("timestamp").GtEq(logicalplan.Literal(int64(3))),
+			expected: map[string]result{
+				"cpu":    {sum: 10, count: 1, min: 10, max: 10},
+				"memory": {sum: 7, count: 1, min: 7, max: 7},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := engine.ScanTable("test")
+			if tc.filter != nil {
+				builder = builder.Filter(tc.filter)
+			}
+			stats := &expr.PruningStats{}
+			results := map[string]result{}
+			require.NoError(t, builder.Aggregate(
+				[]logicalplan.Expr{
+					logicalplan.Sum(logicalplan.Col("value")),
+					logicalplan.Count(logicalplan.Col("value")),
+					logicalplan.Min(logicalplan.Col("value")),
+					logicalplan.Max(logicalplan.Col("value")),
+				},
+				[]logicalplan.Expr{logicalplan.Col("example_type")},
+			).Execute(expr.WithPruningStats(ctx, stats), func(_ context.Context, r arrow.Record) error {
+				column := func(name string) *array.Int64 {
+					indices := r.Schema().FieldIndices(name)
+					require.Len(t, indices, 1)
+					return r.Column(indices[0]).(*array.Int64)
+				}
+				indices := r.Schema().FieldIndices("example_type")
+				require.Len(t, indices, 1)
+				exampleType := func(i int) string {
+					switch arr := r.Column(indices[0]).(type) {
+					case *array.Dictionary:
+						return string(arr.Dictionary().(*array.Binary).Value(arr.GetValueIndex(i)))
+					default:
+						return string(arr.(*array.Binary).Value(i))
+					}
+				}
+				for i := 0; i < int(r.NumRows()); i++ {
+					results[exampleType(i)] = result{
+						sum:   column("sum(value)").Value(i),
+						count: column("count(value)").Value(i),
+						min:   column("min(value)").Value(i),
+						max:   column("max(value)").Value(i),
+					}
+				}
+				return nil
+			}))
+			require.Equal(t, tc.expected, results)
+			require.Equal(t, tc.preAggregated, stats.BlocksPreAggregated.Load())
+		})
+	}
+}
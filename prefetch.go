@@ -0,0 +1,209 @@
+package frostdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/parquet-go/parquet-go"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/polarsignals/frostdb/accounting"
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// Prefetch reads the row groups of the blocks persisted by the table that may
+// contain rows matching the filter, e.g. the rows of a time range, the same
+// way scans read them. It warms up the caches of the data sources of the
+// table, e.g. the cache of storage.WithCache, before a heavy analysis session,
+// so that the first queries are not slowed down by cold reads from the object
+// store. Only the columns matching the projection are read, all columns if it
+// is empty. Data of the table that is still in memory is not read.
+func (t *Table) Prefetch(ctx context.Context, filter logicalplan.Expr, projection ...logicalplan.Expr) error {
+	ctx, span := t.tracer.Start(ctx, "Table/Prefetch")
+	defer span.End()
+
+	if err := t.admit(ctx, accounting.Cache); err != nil {
+		return err
+	}
+
+	var rowGroups atomic.Int64
+	for _, source := range t.db.sources {
+		if err := source.Scan(ctx, filepath.Join(t.db.prefix(), t.name), t.schema, filter, 0, func(ctx context.Context, v any) error {
+			switch v := v.(type) {
+			case arrow.Record:
+				v.Release()
+				return nil
+			case dynparquet.DynamicRowGroup:
+				rowGroups.Add(1)
+				return prefetchColumns(ctx, v, projection)
+			default:
+				return fmt.Errorf("unknown row group type: %T", v)
+			}
+		}); err != nil {
+			return fmt.Errorf("prefetch from %s: %w", source, err)
+		}
+	}
+	span.SetAttributes(attribute.Int64("row_groups", rowGroups.Load()))
+	return nil
+}
+
+// prefetchColumns reads the pages of the columns of the row group matching the
+// projection, or of all columns if it is empty.
+func prefetchColumns(ctx context.Context, rg dynparquet.DynamicRowGroup, projection []logicalplan.Expr) error {
+	for i, path := range rg.Schema().Columns() {
+		if len(projection) > 0 && !matchesProjection(path[0], projection) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := readPages(rg.ColumnChunks()[i].Pages()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchesProjection(column string, projection []logicalplan.Expr) bool {
+	for _, e := range projection {
+		if e.MatchColumn(column) {
+			return true
+		}
+	}
+	return false
+}
+
+// readPages reads and discards all pages.
+func readPages(pages parquet.Pages) error {
+	defer pages.Close()
+	for {
+		page, err := pages.ReadPage()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		parquet.Release(page)
+	}
+}
+
+// prefetchReader is an io.ReaderAt that reads byte ranges of the underlying
+// reader ahead of time, so that the IO of the next row groups of a scan
+// overlaps with decoding and filtering the current one. Reads that fall
+// within a prefetched range are served from memory, other reads go to the
+// underlying reader.
+type prefetchReader struct {
+	ctx context.Context
+	r   io.ReaderAt
+	// limit is the number of prefetched ranges kept in memory.
+	limit int
+
+	mtx    sync.Mutex
+	ranges []*prefetchedRange
+}
+
+type prefetchedRange struct {
+	off    int64
+	length int64
+	// data and err are set once done is closed.
+	data []byte
+	err  error
+	done chan struct{}
+}
+
+func newPrefetchReader(ctx context.Context, r io.ReaderAt, limit int) *prefetchReader {
+	return &prefetchReader{ctx: ctx, r: r, limit: limit}
+}
+
+// Prefetch starts reading the given range in the background. The oldest
+// prefetched range is dropped if more than the limit of ranges are prefetched.
+func (p *prefetchReader) Prefetch(off, length int64) {
+	if p == nil || length <= 0 {
+		return
+	}
+
+	p.mtx.Lock()
+	for _, r := range p.ranges {
+		if r.off == off && r.length == length {
+			p.mtx.Unlock()
+			return
+		}
+	}
+	pr := &prefetchedRange{
+		off:    off,
+		length: length,
+		done:   make(chan struct{}),
+	}
+	p.ranges = append(p.ranges, pr)
+	if len(p.ranges) > p.limit {
+		p.ranges = p.ranges[1:]
+	}
+	p.mtx.Unlock()
+
+	go func() {
+		defer close(pr.done)
+		data := make([]byte, length)
+		n, err := p.r.ReadAt(data, off)
+		if err == io.EOF && n == len(data) {
+			err = nil
+		}
+		pr.data, pr.err = data[:n], err
+	}()
+}
+
+// ReadAt implements the io.ReaderAt interface.
+func (p *prefetchReader) ReadAt(b []byte, off int64) (int, error) {
+	p.mtx.Lock()
+	var found *prefetchedRange
+	for _, r := range p.ranges {
+		if off >= r.off && off+int64(len(b)) <= r.off+r.length {
+			found = r
+			break
+		}
+	}
+	p.mtx.Unlock()
+
+	if found != nil {
+		select {
+		case <-found.done:
+		case <-p.ctx.Done():
+			return 0, p.ctx.Err()
+		}
+		if found.err == nil && off+int64(len(b)) <= found.off+int64(len(found.data)) {
+			return copy(b, found.data[off-found.off:]), nil
+		}
+	}
+	return p.r.ReadAt(b, off)
+}
+
+// prefetchRowGroup prefetches the byte range of the column chunks of the
+// given row group of the file.
+func prefetchRowGroup(p *prefetchReader, f *parquet.File, rowGroup int) {
+	if p == nil || rowGroup >= len(f.Metadata().RowGroups) {
+		return
+	}
+	start, end := int64(-1), int64(0)
+	for _, c := range f.Metadata().RowGroups[rowGroup].Columns {
+		off := c.MetaData.DataPageOffset
+		if c.MetaData.DictionaryPageOffset > 0 && c.MetaData.DictionaryPageOffset < off {
+			off = c.MetaData.DictionaryPageOffset
+		}
+		if start < 0 || off < start {
+			start = off
+		}
+		if e := off + c.MetaData.TotalCompressedSize; e > end {
+			end = e
+		}
+	}
+	if start >= 0 {
+		p.Prefetch(start, end-start)
+	}
+}
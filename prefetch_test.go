@@ -0,0 +1,186 @@
+package frostdb
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+	"github.com/polarsignals/frostdb/storage"
+)
+
+type countingReaderAt struct {
+	r     io.ReaderAt
+	reads atomic.Int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.reads.Add(1)
+	return c.r.ReadAt(p, off)
+}
+
+func TestPrefetchReader(t *testing.T) {
+	data := make([]byte, 100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	r := &countingReaderAt{r: bytes.NewReader(data)}
+	p := newPrefetchReader(context.Background(), r, 2)
+
+	p.Prefetch(10, 20)
+	p.Prefetch(10, 20) // Already prefetched.
+	b := make([]byte, 5)
+	n, err := p.ReadAt(b, 15)
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+	require.Equal(t, data[15:20], b)
+	require.Equal(t, int64(1), r.reads.Load())
+
+	// Reads outside of prefetched ranges go to the underlying reader.
+	n, err = p.ReadAt(b, 28)
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+	require.Equal(t, data[28:33], b)
+	require.Equal(t, int64(2), r.reads.Load())
+
+	// The oldest range is dropped when the limit is exceeded.
+	p.Prefetch(40, 10)
+	p.Prefetch(60, 10)
+	for _, off := range []int64{40, 60} {
+		_, err = p.ReadAt(b, off)
+		require.NoError(t, err)
+		require.Equal(t, data[off:off+5], b)
+	}
+	require.Equal(t, int64(4), r.reads.Load())
+	_, err = p.ReadAt(b, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), r.reads.Load())
+}
+
+func TestBucketPrefetch(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithReadWriteStorage(NewDefaultObjstoreBucket(objstore.NewInMemBucket(), StorageWithPrefetch(2))),
+		WithManualBlockRotation(),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	config := NewTableConfig(dynparquet.SampleDefinition())
+	config.RowGroupSize = 1
+	table, err := db.Table("test", config)
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	writeTx, err := table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	db.Wait(writeTx + 2)
+
+	var values []int64
+	err = query.NewEngine(memory.DefaultAllocator, db.TableProvider()).
+		ScanTable("test").
+		Execute(ctx, func(_ context.Context, r arrow.Record) error {
+			col := r.Column(r.Schema().FieldIndices("value")[0])
+			for i := 0; i < col.Len(); i++ {
+				values = append(values, col.(interface{ Value(int) int64 }).Value(i))
+			}
+			return nil
+		})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int64{5, 3, 3}, values)
+}
+
+func TestTablePrefetch(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewRegistry()
+	cache := storage.NewLRUCache(64 * MiB)
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithReadWriteStorage(NewDefaultObjstoreBucket(
+			objstore.NewInMemBucket(),
+			StorageWithReaderAtOptions(storage.WithCache(cache), storage.WithRegistry(reg)),
+		)),
+		WithManualBlockRotation(),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	config := NewTableConfig(dynparquet.SampleDefinition())
+	config.RowGroupSize = 1
+	table, err := db.Table("test", config)
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	writeTx, err := table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	db.Wait(writeTx + 2)
+
+	// Only the metadata of the block is read for a filter matching no rows.
+	require.NoError(t, table.Prefetch(ctx, logicalplan.Col("timestamp").Gt(logicalplan.Literal(int64(100)))))
+	metadataSize := cache.Size()
+
+	// Only the columns matching the projection are read.
+	require.NoError(t, table.Prefetch(ctx, logicalplan.Col("timestamp").GtEq(logicalplan.Literal(int64(2))), logicalplan.Col("value")))
+	projectedSize := cache.Size()
+	require.Greater(t, projectedSize, metadataSize)
+
+	require.NoError(t, table.Prefetch(ctx, logicalplan.Col("timestamp").GtEq(logicalplan.Literal(int64(2)))))
+	require.Greater(t, cache.Size(), projectedSize)
+	misses := cacheRequests(t, reg, "miss")
+
+	// Queries of the prefetched data are served from the cache.
+	rows := int64(0)
+	err = query.NewEngine(memory.DefaultAllocator, db.TableProvider()).
+		ScanTable("test").
+		Filter(logicalplan.Col("timestamp").GtEq(logicalplan.Literal(int64(2)))).
+		Execute(ctx, func(_ context.Context, r arrow.Record) error {
+			rows += r.NumRows()
+			return nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), rows)
+	require.Equal(t, misses, cacheRequests(t, reg, "miss"))
+	require.NotZero(t, cacheRequests(t, reg, "hit"))
+}
+
+// cacheRequests returns the number of bucket cache requests with the given
+// result.
+func cacheRequests(t *testing.T, reg *prometheus.Registry, result string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		if f.GetName() != "frostdb_bucket_cache_requests_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "result" && l.GetValue() == result {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
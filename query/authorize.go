@@ -0,0 +1,164 @@
+package query
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// AccessRequest describes the data a query accesses. It is passed to an
+// Authorizer at planning time.
+type AccessRequest struct {
+	// Table is the name of the table the query scans.
+	Table string
+	// Columns are the column expressions the query reads. A query that
+	// returns all columns of the table (i.e. has no projection) contains a
+	// logicalplan.AllExpr.
+	Columns []logicalplan.Expr
+	// SchemaOnly is true if the query only reads the schema of the table.
+	SchemaOnly bool
+}
+
+// Touches returns whether the query reads the given column.
+func (r AccessRequest) Touches(column string) bool {
+	for _, c := range r.Columns {
+		if c.MatchColumn(column) {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessPolicy is the policy an Authorizer enforces on an authorized query.
+type AccessPolicy struct {
+	// RowFilter is a mandatory filter that is applied to the table scan
+	// before any of the query's own operators, so rows that do not match it
+	// are never visible to the query. It may be nil.
+	RowFilter logicalplan.Expr
+}
+
+// Authorizer is invoked when a query is planned with the table and columns
+// the query touches. Returning an error denies the query. Otherwise the
+// returned policy is enforced on the query.
+type Authorizer interface {
+	Authorize(ctx context.Context, req AccessRequest) (AccessPolicy, error)
+}
+
+// AuthorizerFunc is an adapter to allow the use of ordinary functions as
+// Authorizers.
+type AuthorizerFunc func(ctx context.Context, req AccessRequest) (AccessPolicy, error)
+
+func (f AuthorizerFunc) Authorize(ctx context.Context, req AccessRequest) (AccessPolicy, error) {
+	return f(ctx, req)
+}
+
+// RowFilterFunc returns a filter that is applied to every scan of the given
+// table, typically derived from values attached to the context such as the
+// tenant issuing the query. Returning a nil filter scans the table
+// unfiltered; returning an error fails the query.
+type RowFilterFunc func(ctx context.Context, table string) (logicalplan.Expr, error)
+
+// rowFilter returns the filter returned by f for the scan of the given plan,
+// or nil if its rows are not filtered.
+func rowFilter(ctx context.Context, f RowFilterFunc, plan *logicalplan.LogicalPlan) (logicalplan.Expr, error) {
+	req := accessRequest(plan)
+	if req.SchemaOnly {
+		// Schema scans do not return rows.
+		return nil, nil
+	}
+	expr, err := f(ctx, req.Table)
+	if err != nil {
+		return nil, fmt.Errorf("row filter for table %q: %w", req.Table, err)
+	}
+	return expr, nil
+}
+
+// authorize invokes the authorizer for the given plan and returns the filter
+// the resulting policy applies to its scan, or nil if its rows are not
+// filtered.
+func authorize(ctx context.Context, authorizer Authorizer, plan *logicalplan.LogicalPlan) (logicalplan.Expr, error) {
+	req := accessRequest(plan)
+	policy, err := authorizer.Authorize(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("query on table %q denied: %w", req.Table, err)
+	}
+	if req.SchemaOnly {
+		// Schema scans do not return rows.
+		return nil, nil
+	}
+	return policy.RowFilter, nil
+}
+
+// applyScanFilters adds the given filters directly on top of the scan of the
+// given plan, the first one closest to the output.
+func applyScanFilters(plan *logicalplan.LogicalPlan, filters []logicalplan.Expr) (*logicalplan.LogicalPlan, error) {
+	for _, expr := range filters {
+		plan = injectScanFilter(plan, expr)
+		if err := logicalplan.Validate(plan); err != nil {
+			return nil, fmt.Errorf("invalid row filter: %w", err)
+		}
+	}
+	return plan, nil
+}
+
+// accessRequest collects the table and columns accessed by the given plan.
+func accessRequest(plan *logicalplan.LogicalPlan) AccessRequest {
+	var (
+		req       AccessRequest
+		projected bool
+	)
+	for p := plan; p != nil; p = p.Input {
+		switch {
+		case p.TableScan != nil:
+			req.Table = p.TableScan.TableName
+		case p.SchemaScan != nil:
+			req.Table = p.SchemaScan.TableName
+			req.SchemaOnly = true
+		case p.Filter != nil:
+			req.Columns = append(req.Columns, p.Filter.Expr.ColumnsUsedExprs()...)
+		case p.Distinct != nil:
+			projected = true
+			for _, e := range p.Distinct.Exprs {
+				req.Columns = append(req.Columns, e.ColumnsUsedExprs()...)
+			}
+		case p.Projection != nil:
+			projected = true
+			for _, e := range p.Projection.Exprs {
+				req.Columns = append(req.Columns, e.ColumnsUsedExprs()...)
+			}
+		case p.Aggregation != nil:
+			projected = true
+			for _, e := range p.Aggregation.AggExprs {
+				req.Columns = append(req.Columns, e.ColumnsUsedExprs()...)
+			}
+			for _, e := range p.Aggregation.GroupExprs {
+				req.Columns = append(req.Columns, e.ColumnsUsedExprs()...)
+			}
+		}
+	}
+	if !projected {
+		req.Columns = append(req.Columns, logicalplan.All())
+	}
+	return req
+}
+
+// injectScanFilter adds a filter directly on top of the scan of the given
+// plan.
+func injectScanFilter(plan *logicalplan.LogicalPlan, expr logicalplan.Expr) *logicalplan.LogicalPlan {
+	filter := func(input *logicalplan.LogicalPlan) *logicalplan.LogicalPlan {
+		return &logicalplan.LogicalPlan{
+			Input:  input,
+			Filter: &logicalplan.Filter{Expr: logicalplan.NormalizeFilterExpr(expr)},
+		}
+	}
+	if plan.Input == nil {
+		return filter(plan)
+	}
+	p := plan
+	for p.Input.Input != nil {
+		p = p.Input
+	}
+	p.Input = filter(p.Input)
+	return plan
+}
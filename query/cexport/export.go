@@ -0,0 +1,24 @@
+//go:build cgo
+
+package cexport
+
+import (
+	"context"
+
+	"github.com/apache/arrow/go/v14/arrow/cdata"
+
+	"github.com/polarsignals/frostdb/query"
+)
+
+// ExportQuery executes the given query and exports its results as an
+// ArrowArrayStream into out, which must be zero initialized. The query runs
+// until the consumer has read all results or releases the stream. See
+// NewRecordReader for the restrictions on the results of the query.
+func ExportQuery(ctx context.Context, b query.Builder, out *cdata.CArrowArrayStream) error {
+	reader, err := NewRecordReader(ctx, b)
+	if err != nil {
+		return err
+	}
+	cdata.ExportRecordReader(reader, out)
+	return nil
+}
@@ -0,0 +1,78 @@
+//go:build cgo
+
+package cexport
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow/cdata"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb"
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// newTestEngine returns an engine over a database with a table "test" holding
+// three copies of the test samples.
+func newTestEngine(t *testing.T) *query.LocalEngine {
+	t.Helper()
+	ctx := context.Background()
+	c, err := frostdb.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", frostdb.NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		r, err := dynparquet.NewTestSamples().ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(ctx, r)
+		r.Release()
+		require.NoError(t, err)
+	}
+	return query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+}
+
+func TestExportQuery(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestEngine(t)
+	b := engine.ScanTable("test").Project(logicalplan.Col("timestamp"), logicalplan.Col("value"))
+
+	stream := new(cdata.CArrowArrayStream)
+	require.NoError(t, ExportQuery(ctx, b, stream))
+	reader, err := cdata.ImportCRecordReader(stream, nil)
+	require.NoError(t, err)
+
+	var rows int64
+	for {
+		r, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, []string{"timestamp", "value"}, []string{r.Schema().Field(0).Name, r.Schema().Field(1).Name})
+		rows += r.NumRows()
+	}
+	require.Equal(t, int64(9), rows)
+}
+
+func TestRecordReaderRelease(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestEngine(t)
+	reader, err := NewRecordReader(ctx, engine.ScanTable("test").Project(logicalplan.Col("value")))
+	require.NoError(t, err)
+	require.Equal(t, "value", reader.Schema().Field(0).Name)
+	require.True(t, reader.Next())
+	// Releasing the reader before all records are read stops the query.
+	reader.Release()
+
+	reader, err = NewRecordReader(ctx, engine.ScanTable("missing"))
+	require.Error(t, err)
+	require.Nil(t, reader)
+}
@@ -0,0 +1,155 @@
+// Package cexport exports query results via the Arrow C data interface, so
+// that processes embedding frostdb as a shared library, e.g. Python or R
+// programs, can consume the results of queries without copying them.
+package cexport
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/apache/arrow/go/v14/arrow"
+
+	"github.com/polarsignals/frostdb/query"
+)
+
+// RecordReader is an array.RecordReader over the results of a query. The
+// query is executed concurrently and produces its next record only once the
+// previous one has been read.
+//
+// Arrow streams have a single schema, so the results of the query must all
+// have the same schema. If a record with a different schema is produced, the
+// reader stops and Err returns an error.
+type RecordReader struct {
+	refCount int64
+	cancel   context.CancelFunc
+	records  chan arrow.Record
+	// execErr is the error of the query. It is only set once records is
+	// closed.
+	execErr error
+
+	schema  *arrow.Schema
+	pending arrow.Record
+	cur     arrow.Record
+	err     error
+}
+
+// NewRecordReader starts executing the given query and returns a reader over
+// its results. It blocks until the query produces its first record, which
+// determines the schema of the reader. A query without results has an empty
+// schema.
+func NewRecordReader(ctx context.Context, b query.Builder) (*RecordReader, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &RecordReader{
+		refCount: 1,
+		cancel:   cancel,
+		records:  make(chan arrow.Record),
+	}
+
+	go func() {
+		defer close(r.records)
+		r.execErr = b.Execute(ctx, func(ctx context.Context, record arrow.Record) error {
+			if record.NumRows() == 0 {
+				return nil
+			}
+			record.Retain()
+			select {
+			case r.records <- record:
+				return nil
+			case <-ctx.Done():
+				record.Release()
+				return ctx.Err()
+			}
+		})
+	}()
+
+	first, ok := <-r.records
+	if !ok {
+		cancel()
+		if r.execErr != nil {
+			return nil, r.execErr
+		}
+		r.schema = arrow.NewSchema(nil, nil)
+		return r, nil
+	}
+	r.schema = first.Schema()
+	r.pending = first
+	return r, nil
+}
+
+// Retain increases the reference count of the reader.
+func (r *RecordReader) Retain() {
+	atomic.AddInt64(&r.refCount, 1)
+}
+
+// Release decreases the reference count of the reader. When it reaches zero,
+// the query is canceled and the records held by the reader are released.
+func (r *RecordReader) Release() {
+	if atomic.AddInt64(&r.refCount, -1) != 0 {
+		return
+	}
+	r.stop()
+	if r.cur != nil {
+		r.cur.Release()
+		r.cur = nil
+	}
+}
+
+// Schema returns the schema of the results.
+func (r *RecordReader) Schema() *arrow.Schema {
+	return r.schema
+}
+
+// Next advances the reader to the next record. The previous record is
+// released.
+func (r *RecordReader) Next() bool {
+	if r.cur != nil {
+		r.cur.Release()
+		r.cur = nil
+	}
+	if r.pending != nil {
+		r.cur = r.pending
+		r.pending = nil
+		return true
+	}
+	if r.err != nil {
+		return false
+	}
+
+	record, ok := <-r.records
+	if !ok {
+		r.err = r.execErr
+		return false
+	}
+	if !record.Schema().Equal(r.schema) {
+		record.Release()
+		r.err = fmt.Errorf("query result schema changed from %v to %v", r.schema, record.Schema())
+		r.stop()
+		return false
+	}
+	r.cur = record
+	return true
+}
+
+// Record returns the current record. It is only valid until the next call to
+// Next.
+func (r *RecordReader) Record() arrow.Record {
+	return r.cur
+}
+
+// Err returns the error that stopped the reader, if any.
+func (r *RecordReader) Err() error {
+	return r.err
+}
+
+// stop cancels the query and releases the records it has not delivered yet.
+func (r *RecordReader) stop() {
+	r.cancel()
+	if r.pending != nil {
+		r.pending.Release()
+		r.pending = nil
+	}
+	for record := range r.records {
+		record.Release()
+	}
+}
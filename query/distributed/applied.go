@@ -0,0 +1,156 @@
+package distributed
+
+import (
+	"context"
+	"sync"
+
+	"github.com/apache/arrow/go/v14/arrow"
+)
+
+type writeSequenceKey struct{}
+
+type writeSequence struct {
+	set string
+	seq uint64
+}
+
+func withWriteSequence(ctx context.Context, set string, seq uint64) context.Context {
+	return context.WithValue(ctx, writeSequenceKey{}, writeSequence{set: set, seq: seq})
+}
+
+// WriteSequence returns the name of the replica set and the sequence number of
+// the write a ShardedWriter inserts with the given context. Every write to a
+// replica set is assigned the next sequence number of the set, starting at 1,
+// so a replica that applied every write up to a sequence number applied the
+// same writes as every other replica that did. The sequence numbers are
+// assigned by a single writer, so a replica set must only be written to by a
+// single ShardedWriter.
+func WriteSequence(ctx context.Context) (set string, seq uint64, ok bool) {
+	s, ok := ctx.Value(writeSequenceKey{}).(writeSequence)
+	return s.set, s.seq, ok
+}
+
+// AppliedReporter is implemented by replicas that report the writes of a
+// ShardedWriter they applied, see TrackApplied.
+type AppliedReporter interface {
+	// Applied returns the sequence number up to which the replica applied
+	// every write to the replica set with the given name.
+	Applied(ctx context.Context, set string) (uint64, error)
+}
+
+// AppliedTracker is an Inserter that tracks the writes of a ShardedWriter
+// that the replica it inserts into applied, and reports them, see
+// TrackApplied.
+type AppliedTracker struct {
+	inserter Inserter
+
+	mtx  sync.Mutex
+	sets map[string]*appliedWrites
+}
+
+// appliedWrites are the writes to a replica set a replica applied.
+type appliedWrites struct {
+	// applied is the sequence number up to which every write was applied.
+	applied uint64
+	// ahead are the sequence numbers of the writes after the next write to
+	// apply that were applied, since concurrent writes may be applied out of
+	// order.
+	ahead map[uint64]struct{}
+}
+
+// TrackApplied returns an Inserter that inserts into the given inserter of a
+// replica and tracks the writes it applied. It is used on the node of the
+// replica, e.g. as the inserter of a Server, so that the replica measures its
+// own lag instead of the writer.
+//
+// A replica that missed a write, e.g. because it was unavailable while the
+// write succeeded with the write quorum, lags until it catches up, even if it
+// applies the later writes: the writes are not retried. The replica catches up
+// by copying the data of another replica of the set, after which SetApplied
+// records the sequence number that replica applied.
+func TrackApplied(inserter Inserter) *AppliedTracker {
+	return &AppliedTracker{
+		inserter: inserter,
+		sets:     map[string]*appliedWrites{},
+	}
+}
+
+// Insert inserts the record and records the write as applied once the insert
+// succeeded.
+func (t *AppliedTracker) Insert(ctx context.Context, table string, r arrow.Record) error {
+	if err := t.inserter.Insert(ctx, table, r); err != nil {
+		return err
+	}
+	if set, seq, ok := WriteSequence(ctx); ok {
+		t.apply(set, seq)
+	}
+	return nil
+}
+
+func (t *AppliedTracker) apply(set string, seq uint64) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	w := t.writes(set)
+	if seq <= w.applied {
+		return
+	}
+	w.ahead[seq] = struct{}{}
+	w.advance()
+}
+
+// Applied implements AppliedReporter.
+func (t *AppliedTracker) Applied(_ context.Context, set string) (uint64, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.writes(set).applied, nil
+}
+
+// SetApplied records that the replica applied every write to the replica set
+// with the given name up to the given sequence number, e.g. after it copied
+// the data of a replica that applied them.
+func (t *AppliedTracker) SetApplied(set string, seq uint64) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	w := t.writes(set)
+	w.applied = seq
+	for s := range w.ahead {
+		if s <= seq {
+			delete(w.ahead, s)
+		}
+	}
+	w.advance()
+}
+
+func (t *AppliedTracker) writes(set string) *appliedWrites {
+	w, ok := t.sets[set]
+	if !ok {
+		w = &appliedWrites{ahead: map[uint64]struct{}{}}
+		t.sets[set] = w
+	}
+	return w
+}
+
+// advance advances the applied sequence number over the writes applied ahead
+// of it.
+func (w *appliedWrites) advance() {
+	for {
+		if _, ok := w.ahead[w.applied+1]; !ok {
+			return
+		}
+		delete(w.ahead, w.applied+1)
+		w.applied++
+	}
+}
+
+// ReportApplied returns a shard that executes fragments on the given shard
+// and reports the writes the replica applied with the given reporter, usually
+// the AppliedTracker of the replica. It makes a local shard a replica of
+// ShardedWriter.Replicated.
+func ReportApplied(shard Shard, reporter AppliedReporter) Shard {
+	return reportingShard{Shard: shard, AppliedReporter: reporter}
+}
+
+type reportingShard struct {
+	Shard
+	AppliedReporter
+}
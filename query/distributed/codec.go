@@ -0,0 +1,58 @@
+package distributed
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	pb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/logicalplan/v1alpha1"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// marshalFragment encodes the given fragment, which must be a linear plan
+// starting with a table scan, in its protobuf representation.
+func marshalFragment(plan *logicalplan.LogicalPlan) ([]byte, error) {
+	if nodes := linearize(plan); nodes[0].TableScan == nil {
+		return nil, errors.New("fragment does not start with a table scan")
+	}
+
+	p, err := logicalplan.ToProto(plan)
+	if err != nil {
+		return nil, err
+	}
+	return p.MarshalVT()
+}
+
+// unmarshalFragment decodes a fragment encoded by marshalFragment. The table
+// scan of the returned plan has no table provider.
+func unmarshalFragment(data []byte) (*logicalplan.LogicalPlan, error) {
+	p := &pb.Plan{}
+	if err := p.UnmarshalVT(data); err != nil {
+		return nil, fmt.Errorf("decode fragment: %w", err)
+	}
+	plan, err := logicalplan.FromProto(p, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decode fragment: %w", err)
+	}
+	if plan == nil || linearize(plan)[0].TableScan == nil {
+		return nil, errors.New("fragment does not start with a table scan")
+	}
+	return plan, nil
+}
+
+// marshalWriteSequence encodes the sequence number of a write to the given
+// replica set, see WriteSequence, as the uvarint encoded sequence number
+// followed by the name of the set.
+func marshalWriteSequence(set string, seq uint64) []byte {
+	return append(binary.AppendUvarint(nil, seq), set...)
+}
+
+// unmarshalWriteSequence decodes a sequence number encoded by
+// marshalWriteSequence.
+func unmarshalWriteSequence(data []byte) (string, uint64, error) {
+	seq, n := binary.Uvarint(data)
+	if n <= 0 {
+		return "", 0, errors.New("decode write sequence: invalid sequence number")
+	}
+	return string(data[n:]), seq, nil
+}
@@ -0,0 +1,353 @@
+// Package distributed executes queries across multiple frostdb nodes, each
+// holding a shard of the data of a table.
+//
+// A coordinator Engine splits the plan of a query into a fragment, that is
+// executed by every shard, and a merge plan, that is executed by the
+// coordinator on the union of the shards' results. The fragment consists of
+// the table scan and all operators up to and including the first aggregation
+// or distinct of the query. Aggregations are executed as partial aggregations
+// on the shards and merged by a final aggregation on the coordinator, so only
+// the aggregated results, rather than the scanned rows, are sent over the
+// network.
+//
+// Shards are either local (see LocalShard) or remote. Remote shards are
+// served over Arrow Flight by a Server and accessed with a RemoteShard.
+//
+// A ShardedWriter distributes inserts across replica sets of nodes by
+// consistent hashing, and Replicated reads one replica of a replica set, so
+// data that is written with a ShardedWriter is read exactly once.
+package distributed
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/pqarrow"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+	"github.com/polarsignals/frostdb/query/physicalplan"
+)
+
+// Shard executes query fragments on the data of a single node.
+type Shard interface {
+	// Execute executes the given fragment and calls the callback with its
+	// results. The fragment is a linear plan that starts with a table scan.
+	// The scan's table provider is the coordinator's and must be ignored;
+	// the table is resolved by its name on the shard. Aggregations of the
+	// fragment must be executed as partial aggregations.
+	Execute(ctx context.Context, fragment *logicalplan.LogicalPlan, callback func(ctx context.Context, r arrow.Record) error) error
+}
+
+// Engine is a query engine that coordinates the execution of queries across
+// shards.
+type Engine struct {
+	pool          memory.Allocator
+	tracer        trace.Tracer
+	tableProvider logicalplan.TableProvider
+	shards        []Shard
+}
+
+type Option func(*Engine)
+
+func WithTracer(tracer trace.Tracer) Option {
+	return func(e *Engine) {
+		e.tracer = tracer
+	}
+}
+
+// NewEngine returns a coordinator that executes queries on the given shards.
+// The table provider is only used to plan queries, so it needs to know the
+// schemas of the queried tables but does not need to hold any data.
+func NewEngine(
+	pool memory.Allocator,
+	tableProvider logicalplan.TableProvider,
+	shards []Shard,
+	options ...Option,
+) *Engine {
+	e := &Engine{
+		pool:          pool,
+		tracer:        trace.NewNoopTracerProvider().Tracer(""),
+		tableProvider: tableProvider,
+		shards:        shards,
+	}
+
+	for _, option := range options {
+		option(e)
+	}
+
+	return e
+}
+
+type queryBuilder struct {
+	engine      *Engine
+	planBuilder logicalplan.Builder
+	outputTypes *pqarrow.OutputTypes
+}
+
+func (e *Engine) ScanTable(name string) query.Builder {
+	return queryBuilder{
+		engine:      e,
+		planBuilder: (&logicalplan.Builder{}).Scan(e.tableProvider, name),
+	}
+}
+
+func (b queryBuilder) Aggregate(
+	aggExpr []logicalplan.Expr,
+	groupExprs []logicalplan.Expr,
+) query.Builder {
+	return queryBuilder{
+		engine:      b.engine,
+		planBuilder: b.planBuilder.Aggregate(aggExpr, groupExprs),
+		outputTypes: b.outputTypes,
+	}
+}
+
+func (b queryBuilder) Filter(
+	expr logicalplan.Expr,
+) query.Builder {
+	return queryBuilder{
+		engine:      b.engine,
+		planBuilder: b.planBuilder.Filter(expr),
+		outputTypes: b.outputTypes,
+	}
+}
+
+func (b queryBuilder) Distinct(
+	expr ...logicalplan.Expr,
+) query.Builder {
+	return queryBuilder{
+		engine:      b.engine,
+		planBuilder: b.planBuilder.Distinct(expr...),
+		outputTypes: b.outputTypes,
+	}
+}
+
+func (b queryBuilder) Project(
+	projections ...logicalplan.Expr,
+) query.Builder {
+	return queryBuilder{
+		engine:      b.engine,
+		planBuilder: b.planBuilder.Project(projections...),
+		outputTypes: b.outputTypes,
+	}
+}
+
+// AsOfTx reads the table on every shard as of the given transaction. Note
+// that every shard has its own transactions, so the transaction is
+// interpreted by every shard independently.
+func (b queryBuilder) AsOfTx(tx uint64) query.Builder {
+	return queryBuilder{
+		engine:      b.engine,
+		planBuilder: b.planBuilder.AsOfTx(tx),
+		outputTypes: b.outputTypes,
+	}
+}
+
+// OutputTypes converts the merged results of the shards to the given types.
+func (b queryBuilder) OutputTypes(types pqarrow.OutputTypes) query.Builder {
+	return queryBuilder{
+		engine:      b.engine,
+		planBuilder: b.planBuilder,
+		outputTypes: &types,
+	}
+}
+
+func (b queryBuilder) Execute(ctx context.Context, callback func(ctx context.Context, r arrow.Record) error) error {
+	ctx, span := b.engine.tracer.Start(ctx, "distributed/Execute")
+	defer span.End()
+
+	if b.outputTypes != nil {
+		next := callback
+		callback = func(ctx context.Context, r arrow.Record) error {
+			r, err := pqarrow.ConvertOutputTypes(b.engine.pool, r, *b.outputTypes)
+			if err != nil {
+				return err
+			}
+			defer r.Release()
+			return next(ctx, r)
+		}
+	}
+
+	fragment, merge, err := b.plan()
+	if err != nil {
+		return err
+	}
+
+	input := &shardResults{}
+	if merge != nil {
+		// The aggregation that merges partial aggregations recomputes the
+		// hashes of the group by columns, since the hashes computed by the
+		// shards are not comparable.
+		input.dropHashes = fragment.Aggregation != nil
+		output, err := physicalplan.Build(
+			ctx,
+			b.engine.pool,
+			b.engine.tracer,
+			// The merge plan does not scan a table.
+			nil,
+			merge,
+			physicalplan.WithOverrideInput([]physicalplan.PhysicalPlan{input}),
+		)
+		if err != nil {
+			return err
+		}
+		output.SetNextCallback(callback)
+		defer input.Close()
+		if err := output.OpenOperators(ctx); err != nil {
+			return err
+		}
+	} else {
+		output := &physicalplan.OutputPlan{}
+		output.SetNextCallback(callback)
+		input.SetNext(output)
+	}
+
+	errg, ctx := errgroup.WithContext(ctx)
+	for i, shard := range b.engine.shards {
+		i, shard := i, shard
+		errg.Go(func() error {
+			if err := shard.Execute(ctx, fragment, input.Callback); err != nil {
+				return fmt.Errorf("shard %d: %w", i, err)
+			}
+			return nil
+		})
+	}
+	if err := errg.Wait(); err != nil {
+		return err
+	}
+
+	return input.Finish(ctx)
+}
+
+func (b queryBuilder) Explain(_ context.Context) (string, error) {
+	fragment, merge, err := b.plan()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Shards (%d):\n%s", len(b.engine.shards), indent(fragment.String()))
+	if merge != nil {
+		fmt.Fprintf(&sb, "\nMerge:\n%s", indent(merge.String()))
+	}
+	return sb.String(), nil
+}
+
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ")
+}
+
+// plan builds the logical plan of the query and splits it into the fragment
+// executed by the shards and the plan that merges their results. The merge
+// plan is nil if the results of the shards can be returned as is.
+func (b queryBuilder) plan() (*logicalplan.LogicalPlan, *logicalplan.LogicalPlan, error) {
+	plan, err := b.planBuilder.Build()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Averages need to be split into sums and counts before splitting the
+	// plan, since partial averages cannot be merged.
+	plan = (&logicalplan.AverageAggregationPushDown{}).Optimize(plan)
+
+	fragment, merge := split(plan)
+	return fragment, merge, nil
+}
+
+// split splits the given plan after its first aggregation or distinct. The
+// returned fragment is the part of the plan up to and including that node.
+// The returned merge plan consists of a copy of that node, which merges the
+// results of the fragments, followed by the rest of the plan.
+func split(plan *logicalplan.LogicalPlan) (*logicalplan.LogicalPlan, *logicalplan.LogicalPlan) {
+	nodes := linearize(plan)
+	for i, node := range nodes {
+		if node.Aggregation == nil && node.Distinct == nil {
+			continue
+		}
+
+		merge := &logicalplan.LogicalPlan{
+			Aggregation: node.Aggregation,
+			Distinct:    node.Distinct,
+		}
+		// Copy the nodes so the plan of the builder is not modified.
+		for _, n := range nodes[i+1:] {
+			n := *n
+			n.Input = merge
+			merge = &n
+		}
+		return node, merge
+	}
+	return plan, nil
+}
+
+// linearize returns the nodes of the given plan from the scan to the root.
+func linearize(plan *logicalplan.LogicalPlan) []*logicalplan.LogicalPlan {
+	var nodes []*logicalplan.LogicalPlan
+	for p := plan; p != nil; p = p.Input {
+		nodes = append(nodes, p)
+	}
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	return nodes
+}
+
+// shardResults is the input of the merge plan. It serializes the results
+// of all shards.
+type shardResults struct {
+	dropHashes bool
+
+	mtx  sync.Mutex
+	next physicalplan.PhysicalPlan
+}
+
+func (s *shardResults) Callback(ctx context.Context, r arrow.Record) error {
+	if s.dropHashes {
+		r = dropHashedColumns(r)
+		defer r.Release()
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.next.Callback(ctx, r)
+}
+
+func (s *shardResults) Finish(ctx context.Context) error {
+	return s.next.Finish(ctx)
+}
+
+func (s *shardResults) SetNext(next physicalplan.PhysicalPlan) {
+	s.next = next
+}
+
+func (s *shardResults) Draw() *physicalplan.Diagram {
+	return &physicalplan.Diagram{Details: "ShardResults", Child: s.next.Draw()}
+}
+
+func (s *shardResults) Close() {
+	s.next.Close()
+}
+
+// dropHashedColumns returns the given record without the hashed columns
+// added by partial aggregations.
+func dropHashedColumns(r arrow.Record) arrow.Record {
+	fields := make([]arrow.Field, 0, r.NumCols())
+	cols := make([]arrow.Array, 0, r.NumCols())
+	for i, field := range r.Schema().Fields() {
+		if dynparquet.IsHashedColumn(field.Name) {
+			continue
+		}
+		fields = append(fields, field)
+		cols = append(cols, r.Column(i))
+	}
+	return array.NewRecord(arrow.NewSchema(fields, nil), cols, r.NumRows())
+}
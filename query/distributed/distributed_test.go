@@ -0,0 +1,460 @@
+package distributed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/flight"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/arrow/scalar"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/polarsignals/frostdb"
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+func newShardDB(t *testing.T, samples dynparquet.Samples) *frostdb.DB {
+	t.Helper()
+	ctx := context.Background()
+
+	c, err := frostdb.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", frostdb.NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	if len(samples) == 0 {
+		return db
+	}
+
+	r, err := samples.ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	return db
+}
+
+func serveShard(t *testing.T, shard Shard, options ...ServerOption) flight.Client {
+	t.Helper()
+
+	srv := flight.NewServerWithMiddleware(nil)
+	require.NoError(t, srv.Init("127.0.0.1:0"))
+	srv.RegisterFlightService(NewServer(shard, memory.DefaultAllocator, options...))
+	go func() {
+		_ = srv.Serve()
+	}()
+	t.Cleanup(srv.Shutdown)
+
+	client, err := flight.NewClientWithMiddleware(
+		srv.Addr().String(), nil, nil,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// rows returns the rows of the query's results.
+func rows(t *testing.T, b query.Builder) []map[string]any {
+	t.Helper()
+	var res []map[string]any
+	require.NoError(t, b.Execute(context.Background(), func(_ context.Context, r arrow.Record) error {
+		for i := 0; i < int(r.NumRows()); i++ {
+			row := map[string]any{}
+			for j, field := range r.Schema().Fields() {
+				v := r.Column(j).GetOneForMarshal(i)
+				if b, ok := v.([]byte); ok {
+					v = string(b)
+				}
+				row[field.Name] = v
+			}
+			res = append(res, row)
+		}
+		return nil
+	}))
+	return res
+}
+
+func TestDistributedQuery(t *testing.T) {
+	samples := dynparquet.NewTestSamples()
+	local := newShardDB(t, samples)
+	for i := range samples {
+		samples[i].Value *= 10
+	}
+	remote := newShardDB(t, samples)
+
+	pool := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer pool.AssertSize(t, 0)
+
+	engine := NewEngine(pool, local.TableProvider(), []Shard{
+		NewLocalShard(pool, local.TableProvider()),
+		NewRemoteShard(serveShard(t, NewLocalShard(pool, remote.TableProvider())), pool),
+	})
+
+	t.Run("Aggregation", func(t *testing.T) {
+		res := rows(t, engine.ScanTable("test").
+			Aggregate(
+				[]logicalplan.Expr{
+					logicalplan.Max(logicalplan.Col("value")),
+					logicalplan.Count(logicalplan.Col("timestamp")),
+					logicalplan.Avg(logicalplan.Col("value")).Alias("avg"),
+				},
+				[]logicalplan.Expr{logicalplan.Col("labels.namespace")},
+			))
+		require.ElementsMatch(t, []map[string]any{{
+			"labels.namespace": nil,
+			"max(value)":       int64(50),
+			"count(timestamp)": int64(2),
+			"avg":              int64(27),
+		}, {
+			"labels.namespace": "default",
+			"max(value)":       int64(30),
+			"count(timestamp)": int64(4),
+			"avg":              int64(16),
+		}}, res)
+	})
+
+	t.Run("Distinct", func(t *testing.T) {
+		res := rows(t, engine.ScanTable("test").
+			Distinct(logicalplan.Col("labels.namespace")))
+		require.ElementsMatch(t, []map[string]any{
+			{"labels.namespace": nil},
+			{"labels.namespace": "default"},
+		}, res)
+	})
+
+	t.Run("FilterProject", func(t *testing.T) {
+		res := rows(t, engine.ScanTable("test").
+			Filter(logicalplan.Col("value").Gt(logicalplan.Literal(int64(4)))).
+			Project(logicalplan.Col("labels.pod"), logicalplan.Col("value")))
+		require.ElementsMatch(t, []map[string]any{
+			{"labels.pod": nil, "value": int64(5)},
+			{"labels.pod": nil, "value": int64(50)},
+			{"labels.pod": "test1", "value": int64(30)},
+			{"labels.pod": nil, "value": int64(30)},
+		}, res)
+	})
+
+	t.Run("Explain", func(t *testing.T) {
+		explain, err := engine.ScanTable("test").
+			Filter(logicalplan.Col("value").Gt(logicalplan.Literal(int64(4)))).
+			Aggregate(
+				[]logicalplan.Expr{logicalplan.Sum(logicalplan.Col("value"))},
+				[]logicalplan.Expr{logicalplan.Col("labels.namespace")},
+			).Explain(context.Background())
+		require.NoError(t, err)
+		require.Contains(t, explain, "Shards (2):")
+		require.Contains(t, explain, "Merge:")
+	})
+}
+
+func TestFragmentCodec(t *testing.T) {
+	scan := &logicalplan.LogicalPlan{
+		TableScan: &logicalplan.TableScan{TableName: "test", AsOfTx: 3},
+	}
+	filter := &logicalplan.LogicalPlan{
+		Input: scan,
+		Filter: &logicalplan.Filter{Expr: logicalplan.And(
+			logicalplan.Col("a").Eq(logicalplan.Literal("b")),
+			logicalplan.Col("c").Gt(logicalplan.Literal(1.5)),
+			logicalplan.Col("d").NotEq(logicalplan.Literal(true)),
+			logicalplan.Col("e").Eq(&logicalplan.LiteralExpr{Value: scalar.ScalarNull}),
+		)},
+	}
+	aggregation := &logicalplan.LogicalPlan{
+		Input: filter,
+		Aggregation: &logicalplan.Aggregation{
+			AggExprs: []logicalplan.Expr{
+				logicalplan.Sum(logicalplan.Col("value")).Alias("total"),
+			},
+			GroupExprs: []logicalplan.Expr{
+				logicalplan.DynCol("labels"),
+				logicalplan.Duration(time.Second),
+			},
+		},
+	}
+	plan := &logicalplan.LogicalPlan{
+		Input: aggregation,
+		Projection: &logicalplan.Projection{Exprs: []logicalplan.Expr{
+			logicalplan.Not(logicalplan.DynCol("labels")),
+			logicalplan.All(),
+		}},
+	}
+
+	data, err := marshalFragment(plan)
+	require.NoError(t, err)
+	decoded, err := unmarshalFragment(data)
+	require.NoError(t, err)
+	require.Equal(t, plan.String(), decoded.String())
+}
+
+type failingShard struct{}
+
+func (failingShard) Execute(context.Context, *logicalplan.LogicalPlan, func(context.Context, arrow.Record) error) error {
+	return errors.New("unavailable")
+}
+
+// concurrentShard calls the callback concurrently and then fails.
+type concurrentShard struct{}
+
+func (concurrentShard) Execute(ctx context.Context, _ *logicalplan.LogicalPlan, callback func(context.Context, arrow.Record) error) error {
+	var errg errgroup.Group
+	for i := 0; i < 4; i++ {
+		errg.Go(func() error {
+			return callback(ctx, nil)
+		})
+	}
+	if err := errg.Wait(); err != nil {
+		return err
+	}
+	return errors.New("failed after delivering results")
+}
+
+func TestReplicatedConcurrentCallbacks(t *testing.T) {
+	var executed []int
+	err := Replicated(concurrentShard{}, recordingShard{id: 1, executed: &executed}).Execute(
+		context.Background(), nil, func(context.Context, arrow.Record) error { return nil },
+	)
+	// The results were delivered, so the fragment is not retried.
+	require.ErrorContains(t, err, "failed after delivering results")
+	require.Empty(t, executed)
+}
+
+func TestShardedWriter(t *testing.T) {
+	ctx := context.Background()
+	pool := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer pool.AssertSize(t, 0)
+
+	dbs := make([]*frostdb.DB, 4)
+	inserters := make([]Inserter, 4)
+	for i := range dbs {
+		dbs[i] = newShardDB(t, nil)
+		db := dbs[i]
+		inserters[i] = InserterFunc(func(ctx context.Context, table string, r arrow.Record) error {
+			tbl, err := db.GetTable(table)
+			if err != nil {
+				return err
+			}
+			_, err = tbl.InsertRecord(ctx, r)
+			return err
+		})
+	}
+	// The second replica of the first set is remote.
+	remote := NewRemoteShard(serveShard(
+		t, NewLocalShard(pool, dbs[1].TableProvider()), WithInserter(inserters[1]),
+	), pool)
+
+	w, err := NewShardedWriter([]ReplicaSet{
+		{Name: "a", Replicas: []Inserter{inserters[0], remote}},
+		{Name: "b", Replicas: []Inserter{inserters[2], inserters[3]}},
+	}, []string{"labels.node"})
+	require.NoError(t, err)
+
+	samples := make(dynparquet.Samples, 0, 20)
+	for i := 0; i < 20; i++ {
+		samples = append(samples, dynparquet.Sample{
+			ExampleType: "cpu",
+			Labels:      map[string]string{"node": fmt.Sprintf("node%d", i)},
+			Timestamp:   int64(i),
+			Value:       int64(i),
+		})
+	}
+	r, err := samples.ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	require.NoError(t, w.Write(ctx, "test", r))
+
+	shard := func(i int) Shard {
+		return NewLocalShard(pool, dbs[i].TableProvider())
+	}
+	nodes := func(t *testing.T, s Shard) []string {
+		res := rows(t, NewEngine(pool, dbs[0].TableProvider(), []Shard{s}).
+			ScanTable("test").
+			Project(logicalplan.Col("labels.node")))
+		nodes := make([]string, 0, len(res))
+		for _, row := range res {
+			nodes = append(nodes, row["labels.node"].(string))
+		}
+		sort.Strings(nodes)
+		return nodes
+	}
+
+	// Both replicas of a set hold the same rows and the sets partition the
+	// rows.
+	a, b := nodes(t, shard(0)), nodes(t, shard(2))
+	require.NotEmpty(t, a)
+	require.NotEmpty(t, b)
+	require.Equal(t, a, nodes(t, shard(1)))
+	require.Equal(t, b, nodes(t, shard(3)))
+	require.Len(t, append(a, b...), len(samples))
+
+	// Writing the same rows again hashes them to the same sets.
+	require.NoError(t, w.Write(ctx, "test", r))
+	require.Len(t, nodes(t, shard(0)), 2*len(a))
+
+	engine := NewEngine(pool, dbs[0].TableProvider(), []Shard{
+		Replicated(failingShard{}, shard(0)),
+		Replicated(shard(3), shard(2)),
+	})
+	res := rows(t, engine.ScanTable("test").
+		Aggregate(
+			[]logicalplan.Expr{logicalplan.Sum(logicalplan.Col("value"))},
+			[]logicalplan.Expr{logicalplan.Col("example_type")},
+		))
+	require.Equal(t, []map[string]any{{
+		"example_type": "cpu",
+		"sum(value)":   int64(2 * 190),
+	}}, res)
+
+	err = NewEngine(pool, dbs[0].TableProvider(), []Shard{
+		Replicated(failingShard{}, failingShard{}),
+	}).ScanTable("test").Execute(ctx, func(context.Context, arrow.Record) error { return nil })
+	require.ErrorContains(t, err, "unavailable")
+}
+
+// recordingShard records that it executed a fragment and fails with err.
+type recordingShard struct {
+	id       int
+	err      error
+	executed *[]int
+}
+
+func (s recordingShard) Execute(context.Context, *logicalplan.LogicalPlan, func(context.Context, arrow.Record) error) error {
+	*s.executed = append(*s.executed, s.id)
+	return s.err
+}
+
+func TestReadConsistency(t *testing.T) {
+	ctx := context.Background()
+
+	var leaderDown atomic.Bool
+	leader := TrackApplied(InserterFunc(func(context.Context, string, arrow.Record) error {
+		if leaderDown.Load() {
+			return errors.New("unavailable")
+		}
+		return nil
+	}))
+	// The follower is a remote replica that reports the writes it applied
+	// over Flight.
+	follower := NewRemoteShard(serveShard(t, recordingShard{executed: new([]int)}, WithInserter(
+		TrackApplied(InserterFunc(func(context.Context, string, arrow.Record) error { return nil })),
+	)), memory.DefaultAllocator)
+	w, err := NewShardedWriter([]ReplicaSet{{
+		Name:     "a",
+		Replicas: []Inserter{leader, follower},
+	}}, []string{"labels.node"}, WithWriteQuorum(1))
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	require.NoError(t, w.Write(ctx, "test", r))
+	leaderDown.Store(true)
+	require.NoError(t, w.Write(ctx, "test", r))
+
+	lag, err := w.Lag(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 0}, lag)
+	_, err = w.Lag(ctx, "b")
+	require.Error(t, err)
+
+	var executed []int
+	shard, err := w.Replicated("a",
+		ReportApplied(recordingShard{id: 0, err: errors.New("unavailable"), executed: &executed}, leader),
+		ReportApplied(recordingShard{id: 1, executed: &executed}, follower),
+	)
+	require.NoError(t, err)
+	_, err = w.Replicated("a", recordingShard{executed: &executed})
+	require.Error(t, err)
+	_, err = w.Replicated("a", recordingShard{executed: &executed}, recordingShard{executed: &executed})
+	require.ErrorContains(t, err, "does not report")
+
+	execute := func(c ReadConsistency) error {
+		executed = nil
+		return shard.Execute(WithReadConsistency(ctx, c), nil, nil)
+	}
+
+	// Eventual reads fall back to the follower.
+	require.NoError(t, execute(ReadConsistency{}))
+	require.Equal(t, []int{0, 1}, executed)
+
+	// Leader reads do not fall back.
+	require.ErrorContains(t, execute(ReadConsistency{Level: ConsistencyLeader}), "unavailable")
+	require.Equal(t, []int{0}, executed)
+
+	// Bounded staleness reads skip the leader that missed a write.
+	require.NoError(t, execute(ReadConsistency{Level: ConsistencyBoundedStaleness}))
+	require.Equal(t, []int{1}, executed)
+	require.NoError(t, execute(ReadConsistency{Level: ConsistencyBoundedStaleness, MaxLag: 1}))
+	require.Equal(t, []int{0, 1}, executed)
+
+	// Missed writes are not retried, so the leader keeps lagging after it
+	// applies later writes, until it catches up with the follower.
+	leaderDown.Store(false)
+	require.NoError(t, w.Write(ctx, "test", r))
+	lag, err = w.Lag(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 0}, lag)
+	applied, err := follower.Applied(ctx, "a")
+	require.NoError(t, err)
+	leader.SetApplied("a", applied)
+	lag, err = w.Lag(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 0}, lag)
+	// Both replicas are read again, the leader's read fails.
+	require.NoError(t, execute(ReadConsistency{Level: ConsistencyBoundedStaleness}))
+	require.Equal(t, []int{0, 1}, executed)
+
+	// Without the sequence numbers of the writes the lag of the replicas is
+	// unknown.
+	err = Replicated(recordingShard{executed: &executed}).Execute(
+		WithReadConsistency(ctx, ReadConsistency{Level: ConsistencyBoundedStaleness}), nil, nil,
+	)
+	require.Error(t, err)
+}
+
+func TestAppliedTracker(t *testing.T) {
+	tracker := TrackApplied(InserterFunc(func(context.Context, string, arrow.Record) error { return nil }))
+	applied := func(set string) uint64 {
+		applied, err := tracker.Applied(context.Background(), set)
+		require.NoError(t, err)
+		return applied
+	}
+	insert := func(set string, seq uint64) {
+		require.NoError(t, tracker.Insert(withWriteSequence(context.Background(), set, seq), "test", nil))
+	}
+
+	// Writes applied out of order are applied once the writes before them
+	// are.
+	insert("a", 2)
+	require.Zero(t, applied("a"))
+	insert("a", 1)
+	require.Equal(t, uint64(2), applied("a"))
+
+	// Replica sets are tracked separately.
+	insert("b", 1)
+	require.Equal(t, uint64(1), applied("b"))
+	require.Equal(t, uint64(2), applied("a"))
+
+	// A missed write holds back the writes after it until the replica
+	// catches up.
+	insert("a", 4)
+	insert("a", 6)
+	require.Equal(t, uint64(2), applied("a"))
+	tracker.SetApplied("a", 5)
+	require.Equal(t, uint64(6), applied("a"))
+}
@@ -0,0 +1,299 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/flight"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// ExecuteFragmentAction is the Arrow Flight action type used to execute a
+// fragment on a remote shard. The body of the action is the encoded
+// fragment. Every result of the action is an Arrow IPC stream holding a
+// single record, since the schemas of the records of a fragment's results
+// may differ.
+const ExecuteFragmentAction = "frostdb.execute_fragment"
+
+// AppliedAction is the Arrow Flight action type used to get the sequence
+// number up to which a remote replica applied the writes to a replica set,
+// see AppliedReporter. The body of the action is the name of the replica set
+// and the body of its result the uvarint encoded sequence number.
+const AppliedAction = "frostdb.applied"
+
+// Server serves a shard over Arrow Flight. It is registered with a Flight
+// server:
+//
+//	s := flight.NewServerWithMiddleware(nil)
+//	s.RegisterFlightService(distributed.NewServer(shard, pool))
+type Server struct {
+	flight.BaseFlightServer
+	shard    Shard
+	pool     memory.Allocator
+	inserter Inserter
+}
+
+type ServerOption func(*Server)
+
+// WithInserter enables inserts into the shard with the Flight DoPut method.
+// The path of the flight descriptor of a put is the name of the table to
+// insert the records into, and its command the sequence number of the write,
+// see WriteSequence. If the inserter implements AppliedReporter, e.g. an
+// AppliedTracker, the writes it applied are served with AppliedAction.
+func WithInserter(inserter Inserter) ServerOption {
+	return func(s *Server) {
+		s.inserter = inserter
+	}
+}
+
+// NewServer returns a Flight service that executes fragments on the given
+// shard, usually a LocalShard.
+func NewServer(shard Shard, pool memory.Allocator, options ...ServerOption) *Server {
+	s := &Server{
+		shard: shard,
+		pool:  pool,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+func (s *Server) ListActions(_ *flight.Empty, stream flight.FlightService_ListActionsServer) error {
+	if err := stream.Send(&flight.ActionType{
+		Type:        ExecuteFragmentAction,
+		Description: "Execute a frostdb query fragment.",
+	}); err != nil {
+		return err
+	}
+	if _, ok := s.inserter.(AppliedReporter); !ok {
+		return nil
+	}
+	return stream.Send(&flight.ActionType{
+		Type:        AppliedAction,
+		Description: "Get the sequence number up to which the writes to a replica set were applied.",
+	})
+}
+
+func (s *Server) DoAction(action *flight.Action, stream flight.FlightService_DoActionServer) error {
+	switch action.Type {
+	case ExecuteFragmentAction:
+		return s.executeFragment(action, stream)
+	case AppliedAction:
+		reporter, ok := s.inserter.(AppliedReporter)
+		if !ok {
+			return status.Error(codes.Unimplemented, "the inserter does not report the writes it applied")
+		}
+		applied, err := reporter.Applied(stream.Context(), string(action.Body))
+		if err != nil {
+			return err
+		}
+		return stream.Send(&flight.Result{Body: binary.AppendUvarint(nil, applied)})
+	default:
+		return status.Errorf(codes.Unimplemented, "unknown action %q", action.Type)
+	}
+}
+
+func (s *Server) executeFragment(action *flight.Action, stream flight.FlightService_DoActionServer) error {
+
+	fragment, err := unmarshalFragment(action.Body)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var (
+		mtx sync.Mutex
+		buf bytes.Buffer
+	)
+	return s.shard.Execute(stream.Context(), fragment, func(_ context.Context, r arrow.Record) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		buf.Reset()
+		if err := writeRecord(&buf, r, s.pool); err != nil {
+			return err
+		}
+		return stream.Send(&flight.Result{Body: buf.Bytes()})
+	})
+}
+
+func (s *Server) DoPut(stream flight.FlightService_DoPutServer) error {
+	if s.inserter == nil {
+		return status.Error(codes.Unimplemented, "inserts are not enabled")
+	}
+
+	reader, err := flight.NewRecordReader(stream, ipc.WithAllocator(s.pool))
+	if err != nil {
+		return err
+	}
+	defer reader.Release()
+
+	desc := reader.LatestFlightDescriptor()
+	if desc == nil || len(desc.Path) != 1 {
+		return status.Error(codes.InvalidArgument, "flight descriptor must be the path of a table")
+	}
+	ctx := stream.Context()
+	if len(desc.Cmd) > 0 {
+		set, seq, err := unmarshalWriteSequence(desc.Cmd)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		ctx = withWriteSequence(ctx, set, seq)
+	}
+	for reader.Next() {
+		if err := s.inserter.Insert(ctx, desc.Path[0], reader.Record()); err != nil {
+			return err
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+	return stream.Send(&flight.PutResult{})
+}
+
+func writeRecord(w io.Writer, r arrow.Record, pool memory.Allocator) error {
+	writer := ipc.NewWriter(w, ipc.WithSchema(r.Schema()), ipc.WithAllocator(pool))
+	if err := writer.Write(r); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// RemoteShard is a shard that executes fragments on a remote node served by
+// a Server. It also implements Inserter and AppliedReporter, so it can be a
+// replica of a ShardedWriter.
+type RemoteShard struct {
+	client flight.Client
+	pool   memory.Allocator
+}
+
+// NewRemoteShard returns a shard that executes fragments using the given
+// Flight client.
+func NewRemoteShard(client flight.Client, pool memory.Allocator) *RemoteShard {
+	return &RemoteShard{
+		client: client,
+		pool:   pool,
+	}
+}
+
+func (s *RemoteShard) Execute(ctx context.Context, fragment *logicalplan.LogicalPlan, callback func(ctx context.Context, r arrow.Record) error) error {
+	body, err := marshalFragment(fragment)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := s.client.DoAction(ctx, &flight.Action{
+		Type: ExecuteFragmentAction,
+		Body: body,
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		res, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.readRecords(ctx, res.Body, callback); err != nil {
+			return err
+		}
+	}
+}
+
+// Insert inserts the record into the given table of the remote node, which
+// needs to be served by a Server with an inserter.
+func (s *RemoteShard) Insert(ctx context.Context, table string, r arrow.Record) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := s.client.DoPut(ctx)
+	if err != nil {
+		return err
+	}
+
+	desc := &flight.FlightDescriptor{
+		Type: flight.DescriptorPATH,
+		Path: []string{table},
+	}
+	if set, seq, ok := WriteSequence(ctx); ok {
+		desc.Cmd = marshalWriteSequence(set, seq)
+	}
+	w := flight.NewRecordWriter(stream, ipc.WithSchema(r.Schema()), ipc.WithAllocator(s.pool))
+	w.SetFlightDescriptor(desc)
+	if err := w.Write(r); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// Applied returns the sequence number up to which the remote replica applied
+// the writes to the given replica set. The remote node needs to be served by
+// a Server with an inserter that reports them, see TrackApplied.
+func (s *RemoteShard) Applied(ctx context.Context, set string) (uint64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := s.client.DoAction(ctx, &flight.Action{
+		Type: AppliedAction,
+		Body: []byte(set),
+	})
+	if err != nil {
+		return 0, err
+	}
+	res, err := stream.Recv()
+	if err != nil {
+		return 0, err
+	}
+	applied, n := binary.Uvarint(res.Body)
+	if n <= 0 {
+		return 0, errors.New("invalid applied sequence number")
+	}
+	return applied, nil
+}
+
+func (s *RemoteShard) readRecords(ctx context.Context, data []byte, callback func(ctx context.Context, r arrow.Record) error) error {
+	reader, err := ipc.NewReader(bytes.NewReader(data), ipc.WithAllocator(s.pool))
+	if err != nil {
+		return fmt.Errorf("read shard result: %w", err)
+	}
+	defer reader.Release()
+
+	for reader.Next() {
+		if err := callback(ctx, reader.Record()); err != nil {
+			return err
+		}
+	}
+	return reader.Err()
+}
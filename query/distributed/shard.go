@@ -0,0 +1,62 @@
+package distributed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+	"github.com/polarsignals/frostdb/query/physicalplan"
+)
+
+// LocalShard executes fragments on the tables of a local table provider,
+// e.g. a frostdb database.
+type LocalShard struct {
+	engine *query.LocalEngine
+}
+
+// NewLocalShard returns a shard that executes fragments on the tables of
+// the given table provider. The options configure the engine that executes
+// the fragments, so e.g. authorizers and row filters are enforced on the
+// shard.
+func NewLocalShard(
+	pool memory.Allocator,
+	tableProvider logicalplan.TableProvider,
+	options ...query.Option,
+) *LocalShard {
+	options = append(options, query.WithPhysicalplanOptions(physicalplan.WithPartialAggregations()))
+	return &LocalShard{
+		engine: query.NewEngine(pool, tableProvider, options...),
+	}
+}
+
+func (s *LocalShard) Execute(ctx context.Context, fragment *logicalplan.LogicalPlan, callback func(ctx context.Context, r arrow.Record) error) error {
+	nodes := linearize(fragment)
+	if nodes[0].TableScan == nil {
+		return errors.New("fragment does not start with a table scan")
+	}
+
+	b := s.engine.ScanTable(nodes[0].TableScan.TableName)
+	if tx := nodes[0].TableScan.AsOfTx; tx != 0 {
+		b = b.AsOfTx(tx)
+	}
+	for _, node := range nodes[1:] {
+		switch {
+		case node.Filter != nil:
+			b = b.Filter(node.Filter.Expr)
+		case node.Projection != nil:
+			b = b.Project(node.Projection.Exprs...)
+		case node.Distinct != nil:
+			b = b.Distinct(node.Distinct.Exprs...)
+		case node.Aggregation != nil:
+			b = b.Aggregate(node.Aggregation.AggExprs, node.Aggregation.GroupExprs)
+		default:
+			return fmt.Errorf("unsupported fragment node: %s", node)
+		}
+	}
+	return b.Execute(ctx, callback)
+}
@@ -0,0 +1,525 @@
+package distributed
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/pqarrow/builder"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// Inserter inserts records into the tables of a node.
+type Inserter interface {
+	Insert(ctx context.Context, table string, r arrow.Record) error
+}
+
+// InserterFunc is an adapter to allow the use of ordinary functions as
+// Inserters.
+type InserterFunc func(ctx context.Context, table string, r arrow.Record) error
+
+func (f InserterFunc) Insert(ctx context.Context, table string, r arrow.Record) error {
+	return f(ctx, table, r)
+}
+
+// ReplicaSet is a set of nodes that hold the same data. The replication
+// factor of the data is the number of replicas of the set.
+type ReplicaSet struct {
+	// Name identifies the replica set on the hash ring. It must not change
+	// when replica sets are added or removed, so that only the rows of the
+	// hash ranges of the added or removed replica sets move.
+	Name     string
+	Replicas []Inserter
+}
+
+// ShardedWriter shards inserts across replica sets. Every row is assigned to
+// a replica set by consistent hashing of the values of the sharding columns
+// and is inserted into all replicas of that set.
+//
+// Since every replica of a set holds the same rows, a query needs to read
+// exactly one replica of every set (see Replicated) to read every row exactly
+// once.
+type ShardedWriter struct {
+	pool         memory.Allocator
+	sets         []ReplicaSet
+	columns      []string
+	virtualNodes int
+	writeQuorum  int
+	ring         ring
+	// written is the sequence number of the last write to every replica set,
+	// see WriteSequence.
+	written []atomic.Uint64
+}
+
+type WriterOption func(*ShardedWriter)
+
+// WithVirtualNodes sets the number of positions every replica set has on the
+// hash ring. More virtual nodes distribute rows more evenly. The default is
+// 128.
+func WithVirtualNodes(n int) WriterOption {
+	return func(w *ShardedWriter) {
+		w.virtualNodes = n
+	}
+}
+
+// WithWriteQuorum sets the number of replicas of a set that need to
+// acknowledge an insert for it to succeed. By default all replicas need to
+// acknowledge an insert.
+func WithWriteQuorum(n int) WriterOption {
+	return func(w *ShardedWriter) {
+		w.writeQuorum = n
+	}
+}
+
+// WithWriterAllocator sets the allocator used to split records.
+func WithWriterAllocator(pool memory.Allocator) WriterOption {
+	return func(w *ShardedWriter) {
+		w.pool = pool
+	}
+}
+
+// NewShardedWriter returns a writer that shards inserts across the given
+// replica sets by the hash of the given columns. Rows that do not have a
+// column, e.g. a dynamic column that is not set, hash it as null.
+func NewShardedWriter(sets []ReplicaSet, columns []string, options ...WriterOption) (*ShardedWriter, error) {
+	if len(sets) == 0 {
+		return nil, errors.New("no replica sets")
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("no sharding columns")
+	}
+
+	w := &ShardedWriter{
+		pool:         memory.DefaultAllocator,
+		sets:         sets,
+		columns:      columns,
+		virtualNodes: 128,
+	}
+	for _, option := range options {
+		option(w)
+	}
+
+	names := make(map[string]struct{}, len(sets))
+	for _, set := range sets {
+		if _, ok := names[set.Name]; ok {
+			return nil, fmt.Errorf("duplicate replica set %q", set.Name)
+		}
+		names[set.Name] = struct{}{}
+		if len(set.Replicas) == 0 {
+			return nil, fmt.Errorf("replica set %q has no replicas", set.Name)
+		}
+		if w.writeQuorum > len(set.Replicas) {
+			return nil, fmt.Errorf("write quorum %d exceeds the replicas of set %q", w.writeQuorum, set.Name)
+		}
+	}
+	w.ring = newRing(sets, w.virtualNodes)
+	w.written = make([]atomic.Uint64, len(sets))
+	return w, nil
+}
+
+// Write inserts the rows of the given record into the replica sets they
+// hash to. The rows of the record that are inserted into the same replica set
+// keep their order.
+func (w *ShardedWriter) Write(ctx context.Context, table string, r arrow.Record) error {
+	indices := make([][]int64, len(w.sets))
+	for i, hash := range w.hashRows(r) {
+		set := w.ring.get(hash)
+		indices[set] = append(indices[set], int64(i))
+	}
+
+	records := make([]arrow.Record, len(w.sets))
+	defer func() {
+		for _, rec := range records {
+			if rec != nil {
+				rec.Release()
+			}
+		}
+	}()
+	for i, idx := range indices {
+		switch len(idx) {
+		case 0:
+		case int(r.NumRows()):
+			r.Retain()
+			records[i] = r
+		default:
+			rec, err := w.take(r, idx)
+			if err != nil {
+				return err
+			}
+			records[i] = rec
+		}
+	}
+
+	errg, ctx := errgroup.WithContext(ctx)
+	for i, rec := range records {
+		if rec == nil {
+			continue
+		}
+		i, rec := i, rec
+		errg.Go(func() error {
+			return w.write(ctx, i, table, rec)
+		})
+	}
+	return errg.Wait()
+}
+
+// write inserts the record into the replicas of the set with the given
+// index. The write is assigned the next sequence number of the set, see
+// WriteSequence.
+func (w *ShardedWriter) write(ctx context.Context, setIndex int, table string, r arrow.Record) error {
+	set := w.sets[setIndex]
+	ctx = withWriteSequence(ctx, set.Name, w.written[setIndex].Add(1))
+	quorum := w.writeQuorum
+	if quorum == 0 {
+		quorum = len(set.Replicas)
+	}
+
+	var (
+		acks atomic.Int64
+		errg errgroup.Group
+	)
+	errs := make([]error, len(set.Replicas))
+	for i, replica := range set.Replicas {
+		i, replica := i, replica
+		errg.Go(func() error {
+			if err := replica.Insert(ctx, table, r); err != nil {
+				errs[i] = err
+				return nil
+			}
+			acks.Add(1)
+			return nil
+		})
+	}
+	_ = errg.Wait()
+
+	if int(acks.Load()) < quorum {
+		return fmt.Errorf(
+			"insert into replica set %q: %d of %d replicas acknowledged, %d required: %w",
+			set.Name, acks.Load(), len(set.Replicas), quorum, errors.Join(errs...),
+		)
+	}
+	return nil
+}
+
+// take returns a record with the rows of r at the given indices.
+func (w *ShardedWriter) take(r arrow.Record, indices []int64) (arrow.Record, error) {
+	cols := make([]arrow.Array, 0, r.NumCols())
+	defer func() {
+		for _, col := range cols {
+			col.Release()
+		}
+	}()
+	for _, col := range r.Columns() {
+		b := builder.NewBuilder(w.pool, col.DataType())
+		for _, i := range indices {
+			if err := builder.AppendValue(b, col, int(i)); err != nil {
+				b.Release()
+				return nil, err
+			}
+		}
+		cols = append(cols, b.NewArray())
+		b.Release()
+	}
+	return array.NewRecord(r.Schema(), cols, int64(len(indices))), nil
+}
+
+// hashRows returns the hash of the sharding columns of every row of r.
+func (w *ShardedWriter) hashRows(r arrow.Record) []uint64 {
+	numRows := int(r.NumRows())
+	colHashes := make([][]uint64, 0, len(w.columns))
+	for _, name := range w.columns {
+		indices := r.Schema().FieldIndices(name)
+		if len(indices) == 0 {
+			colHashes = append(colHashes, nil)
+			continue
+		}
+		colHashes = append(colHashes, dynparquet.HashArray(r.Column(indices[0])))
+	}
+
+	hashes := make([]uint64, numRows)
+	buf := make([]byte, 8*len(colHashes))
+	for i := range hashes {
+		for j, h := range colHashes {
+			var v uint64
+			if h != nil {
+				v = h[i]
+			}
+			binary.LittleEndian.PutUint64(buf[j*8:], v)
+		}
+		hashes[i] = xxhash.Sum64(buf)
+	}
+	return hashes
+}
+
+// ring is a consistent hash ring of replica sets.
+type ring struct {
+	tokens []uint64
+	sets   []int
+}
+
+func newRing(sets []ReplicaSet, virtualNodes int) ring {
+	type token struct {
+		hash uint64
+		set  int
+	}
+	tokens := make([]token, 0, len(sets)*virtualNodes)
+	for i, set := range sets {
+		for v := 0; v < virtualNodes; v++ {
+			tokens = append(tokens, token{
+				hash: xxhash.Sum64String(set.Name + "/" + strconv.Itoa(v)),
+				set:  i,
+			})
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].hash < tokens[j].hash
+	})
+
+	r := ring{
+		tokens: make([]uint64, len(tokens)),
+		sets:   make([]int, len(tokens)),
+	}
+	for i, t := range tokens {
+		r.tokens[i] = t.hash
+		r.sets[i] = t.set
+	}
+	return r
+}
+
+// get returns the index of the replica set that owns the given hash, which is
+// the set of the first token that is greater or equal to the hash.
+func (r ring) get(hash uint64) int {
+	i := sort.Search(len(r.tokens), func(i int) bool {
+		return r.tokens[i] >= hash
+	})
+	if i == len(r.tokens) {
+		i = 0
+	}
+	return r.sets[i]
+}
+
+// Lag returns the number of writes to the replica set with the given name
+// every replica of the set did not apply, in the order of the replicas of the
+// set. The replicas report the writes they applied themselves, so they must
+// implement AppliedReporter, see TrackApplied. A replica lags when it did not
+// apply writes, e.g. because it was unavailable while writes succeeded with
+// the write quorum, until it catches up, see AppliedTracker.SetApplied.
+func (w *ShardedWriter) Lag(ctx context.Context, set string) ([]uint64, error) {
+	i, err := w.setIndex(set)
+	if err != nil {
+		return nil, err
+	}
+	lags := make([]uint64, len(w.sets[i].Replicas))
+	for j, replica := range w.sets[i].Replicas {
+		reporter, ok := replica.(AppliedReporter)
+		if !ok {
+			return nil, fmt.Errorf("replica %d of set %q does not report the writes it applied", j, set)
+		}
+		applied, err := reporter.Applied(ctx, set)
+		if err != nil {
+			return nil, fmt.Errorf("replica %d of set %q: %w", j, set, err)
+		}
+		lags[j] = lag(&w.written[i], applied)
+	}
+	return lags, nil
+}
+
+// lag returns the number of writes up to the last written sequence number a
+// replica that applied the writes up to the given sequence number did not
+// apply.
+func lag(written *atomic.Uint64, applied uint64) uint64 {
+	// The written sequence number is loaded after the replica reported the
+	// writes it applied, so the lag is never negative.
+	return written.Load() - applied
+}
+
+// Replicated returns a shard that executes fragments on the given replicas of
+// the replica set with the given name, see the Replicated function. The
+// replicas must be in the order of the replicas of the set, and must
+// implement AppliedReporter, see ReportApplied. Unlike shards returned by the
+// Replicated function, the shard supports bounded staleness reads, since the
+// replicas report the writes of the writer they applied.
+func (w *ShardedWriter) Replicated(set string, replicas ...Shard) (Shard, error) {
+	i, err := w.setIndex(set)
+	if err != nil {
+		return nil, err
+	}
+	if len(replicas) != len(w.sets[i].Replicas) {
+		return nil, fmt.Errorf(
+			"replica set %q has %d replicas, got %d shards",
+			set, len(w.sets[i].Replicas), len(replicas),
+		)
+	}
+	for j, replica := range replicas {
+		if _, ok := replica.(AppliedReporter); !ok {
+			return nil, fmt.Errorf("replica %d of set %q does not report the writes it applied", j, set)
+		}
+	}
+	return replicatedShard{replicas: replicas, set: set, written: &w.written[i]}, nil
+}
+
+func (w *ShardedWriter) setIndex(name string) (int, error) {
+	for i, set := range w.sets {
+		if set.Name == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown replica set %q", name)
+}
+
+// ConsistencyLevel determines which replicas of a replica set a query may
+// read.
+type ConsistencyLevel int
+
+const (
+	// ConsistencyEventual reads any replica, trying the replicas in order.
+	// Replicas that did not apply all writes may return stale results. This
+	// is the default.
+	ConsistencyEventual ConsistencyLevel = iota
+	// ConsistencyLeader only reads the first replica of a set, the leader.
+	// Queries fail if the leader is unavailable.
+	ConsistencyLeader
+	// ConsistencyBoundedStaleness reads the replicas that did not miss more
+	// than ReadConsistency.MaxLag writes, trying them in order. The lag of a
+	// replica is measured when the query is executed from the writes the
+	// replica reports it applied. Replicas that fail to report them are not
+	// read.
+	ConsistencyBoundedStaleness
+)
+
+func (l ConsistencyLevel) String() string {
+	switch l {
+	case ConsistencyEventual:
+		return "eventual"
+	case ConsistencyLeader:
+		return "leader"
+	case ConsistencyBoundedStaleness:
+		return "bounded staleness"
+	default:
+		return fmt.Sprintf("ConsistencyLevel(%d)", int(l))
+	}
+}
+
+// ReadConsistency is the consistency of the reads of a query.
+type ReadConsistency struct {
+	Level ConsistencyLevel
+	// MaxLag is the number of writes to a replica set a replica may not have
+	// applied to be read with ConsistencyBoundedStaleness.
+	MaxLag uint64
+}
+
+type readConsistencyKey struct{}
+
+// WithReadConsistency returns a context making the queries executed with it
+// read the replicas of replica sets with the given consistency.
+func WithReadConsistency(ctx context.Context, c ReadConsistency) context.Context {
+	return context.WithValue(ctx, readConsistencyKey{}, c)
+}
+
+func readConsistency(ctx context.Context) ReadConsistency {
+	c, _ := ctx.Value(readConsistencyKey{}).(ReadConsistency)
+	return c
+}
+
+// Replicated returns a shard that executes fragments on one of the given
+// replicas, which hold the same data. Replicas are tried in order. If a
+// replica fails before it returned any results, the fragment is executed on
+// the next replica. This makes sure that the rows of a replica set are read
+// exactly once. The first replica is the leader of the set, see
+// WithReadConsistency. Bounded staleness reads require the sequence numbers
+// of the writes to the set, so they fail on the returned shard, see
+// ShardedWriter.Replicated.
+func Replicated(replicas ...Shard) Shard {
+	return replicatedShard{replicas: replicas}
+}
+
+type replicatedShard struct {
+	replicas []Shard
+	// set is the name of the replica set of the replicas and written the
+	// sequence number of the last write to it. written is nil if the writes
+	// of the set are not tracked.
+	set     string
+	written *atomic.Uint64
+}
+
+func (s replicatedShard) Execute(ctx context.Context, fragment *logicalplan.LogicalPlan, callback func(ctx context.Context, r arrow.Record) error) error {
+	replicas, err := s.readable(ctx, readConsistency(ctx))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, i := range replicas {
+		// The callback may be called concurrently.
+		var delivered atomic.Bool
+		err := s.replicas[i].Execute(ctx, fragment, func(ctx context.Context, r arrow.Record) error {
+			delivered.Store(true)
+			return callback(ctx, r)
+		})
+		if err == nil {
+			return nil
+		}
+		if delivered.Load() || ctx.Err() != nil {
+			// Retrying would return the results that were already
+			// delivered again.
+			return fmt.Errorf("replica %d: %w", i, err)
+		}
+		errs = append(errs, fmt.Errorf("replica %d: %w", i, err))
+	}
+	return errors.Join(errs...)
+}
+
+// readable returns the indices of the replicas that may be read with the
+// given consistency, in the order they are tried.
+func (s replicatedShard) readable(ctx context.Context, c ReadConsistency) ([]int, error) {
+	if len(s.replicas) == 0 {
+		return nil, nil
+	}
+	switch c.Level {
+	case ConsistencyEventual:
+		replicas := make([]int, len(s.replicas))
+		for i := range replicas {
+			replicas[i] = i
+		}
+		return replicas, nil
+	case ConsistencyLeader:
+		return []int{0}, nil
+	case ConsistencyBoundedStaleness:
+		if s.written == nil {
+			return nil, errors.New("bounded staleness reads require the sequence numbers of the writes to the replica set")
+		}
+		var (
+			replicas []int
+			errs     []error
+		)
+		for i, replica := range s.replicas {
+			applied, err := replica.(AppliedReporter).Applied(ctx, s.set)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("replica %d: %w", i, err))
+				continue
+			}
+			if lag(s.written, applied) <= c.MaxLag {
+				replicas = append(replicas, i)
+			}
+		}
+		if len(replicas) == 0 {
+			return nil, errors.Join(append([]error{
+				fmt.Errorf("no replica is within the max lag of %d writes", c.MaxLag),
+			}, errs...)...)
+		}
+		return replicas, nil
+	default:
+		return nil, fmt.Errorf("unsupported read consistency: %s", c.Level)
+	}
+}
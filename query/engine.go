@@ -2,11 +2,16 @@ package query
 
 import (
 	"context"
+	"errors"
+	"runtime/pprof"
+	"time"
 
 	"github.com/apache/arrow/go/v14/arrow"
 	"github.com/apache/arrow/go/v14/arrow/memory"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/polarsignals/frostdb/clock"
+	"github.com/polarsignals/frostdb/pqarrow"
 	"github.com/polarsignals/frostdb/query/logicalplan"
 	"github.com/polarsignals/frostdb/query/physicalplan"
 )
@@ -16,6 +21,15 @@ type Builder interface {
 	Filter(expr logicalplan.Expr) Builder
 	Distinct(expr ...logicalplan.Expr) Builder
 	Project(projections ...logicalplan.Expr) Builder
+	// AsOfTx reads the table as of the given transaction, so the query does
+	// not see writes of later transactions. Compaction and persistence drop
+	// the transactions of the rows they write, so the query fails with
+	// logicalplan.ErrSnapshotUnavailable if they wrote rows of transactions
+	// after the given one.
+	AsOfTx(tx uint64) Builder
+	// OutputTypes converts the results of the query to the given types, e.g.
+	// to expand dictionaries for clients that don't support them.
+	OutputTypes(types pqarrow.OutputTypes) Builder
 	Execute(ctx context.Context, callback func(ctx context.Context, r arrow.Record) error) error
 	Explain(ctx context.Context) (string, error)
 }
@@ -25,6 +39,10 @@ type LocalEngine struct {
 	tracer        trace.Tracer
 	tableProvider logicalplan.TableProvider
 	execOpts      []physicalplan.Option
+	authorizer    Authorizer
+	rowFilter     RowFilterFunc
+	clock         clock.Clock
+	queries       *queryTracker
 }
 
 type Option func(*LocalEngine)
@@ -37,7 +55,34 @@ func WithTracer(tracer trace.Tracer) Option {
 
 func WithPhysicalplanOptions(opts ...physicalplan.Option) Option {
 	return func(e *LocalEngine) {
-		e.execOpts = opts
+		e.execOpts = append(e.execOpts, opts...)
+	}
+}
+
+// WithAuthorizer sets an Authorizer that is invoked for every query planned
+// by the engine with the table and columns the query touches.
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(e *LocalEngine) {
+		e.authorizer = authorizer
+	}
+}
+
+// WithRowFilter sets a function whose returned filter is applied to every
+// table scan of the engine's queries. The filter is applied directly on top of
+// the scan, below any operators of the query itself, so queries cannot read
+// rows that do not match it. This is meant for enforcing row-level security,
+// e.g. restricting a query to rows of the tenant stored in the context.
+func WithRowFilter(f RowFilterFunc) Option {
+	return func(e *LocalEngine) {
+		e.rowFilter = f
+	}
+}
+
+// WithClock sets the clock that now() of the engine's queries is bound to when
+// they are planned. Defaults to the real clock.
+func WithClock(c clock.Clock) Option {
+	return func(e *LocalEngine) {
+		e.clock = c
 	}
 }
 
@@ -50,6 +95,8 @@ func NewEngine(
 		pool:          pool,
 		tracer:        trace.NewNoopTracerProvider().Tracer(""),
 		tableProvider: tableProvider,
+		clock:         clock.Real(),
+		queries:       newQueryTracker(),
 	}
 
 	for _, option := range options {
@@ -64,6 +111,10 @@ type LocalQueryBuilder struct {
 	tracer      trace.Tracer
 	planBuilder logicalplan.Builder
 	execOpts    []physicalplan.Option
+	authorizer  Authorizer
+	rowFilter   RowFilterFunc
+	clock       clock.Clock
+	queries     *queryTracker
 }
 
 func (e *LocalEngine) ScanTable(name string) Builder {
@@ -72,6 +123,10 @@ func (e *LocalEngine) ScanTable(name string) Builder {
 		tracer:      e.tracer,
 		planBuilder: (&logicalplan.Builder{}).Scan(e.tableProvider, name),
 		execOpts:    e.execOpts,
+		authorizer:  e.authorizer,
+		rowFilter:   e.rowFilter,
+		clock:       e.clock,
+		queries:     e.queries,
 	}
 }
 
@@ -81,6 +136,10 @@ func (e *LocalEngine) ScanSchema(name string) Builder {
 		tracer:      e.tracer,
 		planBuilder: (&logicalplan.Builder{}).ScanSchema(e.tableProvider, name),
 		execOpts:    e.execOpts,
+		authorizer:  e.authorizer,
+		rowFilter:   e.rowFilter,
+		clock:       e.clock,
+		queries:     e.queries,
 	}
 }
 
@@ -93,6 +152,10 @@ func (b LocalQueryBuilder) Aggregate(
 		tracer:      b.tracer,
 		planBuilder: b.planBuilder.Aggregate(aggExpr, groupExprs),
 		execOpts:    b.execOpts,
+		authorizer:  b.authorizer,
+		rowFilter:   b.rowFilter,
+		clock:       b.clock,
+		queries:     b.queries,
 	}
 }
 
@@ -104,6 +167,10 @@ func (b LocalQueryBuilder) Filter(
 		tracer:      b.tracer,
 		planBuilder: b.planBuilder.Filter(expr),
 		execOpts:    b.execOpts,
+		authorizer:  b.authorizer,
+		rowFilter:   b.rowFilter,
+		clock:       b.clock,
+		queries:     b.queries,
 	}
 }
 
@@ -115,6 +182,10 @@ func (b LocalQueryBuilder) Distinct(
 		tracer:      b.tracer,
 		planBuilder: b.planBuilder.Distinct(expr...),
 		execOpts:    b.execOpts,
+		authorizer:  b.authorizer,
+		rowFilter:   b.rowFilter,
+		clock:       b.clock,
+		queries:     b.queries,
 	}
 }
 
@@ -126,39 +197,110 @@ func (b LocalQueryBuilder) Project(
 		tracer:      b.tracer,
 		planBuilder: b.planBuilder.Project(projections...),
 		execOpts:    b.execOpts,
+		authorizer:  b.authorizer,
+		rowFilter:   b.rowFilter,
+		clock:       b.clock,
+		queries:     b.queries,
+	}
+}
+
+func (b LocalQueryBuilder) AsOfTx(tx uint64) Builder {
+	return LocalQueryBuilder{
+		pool:        b.pool,
+		tracer:      b.tracer,
+		planBuilder: b.planBuilder.AsOfTx(tx),
+		execOpts:    b.execOpts,
+		authorizer:  b.authorizer,
+		rowFilter:   b.rowFilter,
+		clock:       b.clock,
+		queries:     b.queries,
+	}
+}
+
+func (b LocalQueryBuilder) OutputTypes(types pqarrow.OutputTypes) Builder {
+	execOpts := make([]physicalplan.Option, 0, len(b.execOpts)+1)
+	execOpts = append(execOpts, b.execOpts...)
+	return LocalQueryBuilder{
+		pool:        b.pool,
+		tracer:      b.tracer,
+		planBuilder: b.planBuilder,
+		execOpts:    append(execOpts, physicalplan.WithOutputTypes(types)),
+		authorizer:  b.authorizer,
+		rowFilter:   b.rowFilter,
+		clock:       b.clock,
+		queries:     b.queries,
 	}
 }
 
 func (b LocalQueryBuilder) Execute(ctx context.Context, callback func(ctx context.Context, r arrow.Record) error) error {
 	ctx, span := b.tracer.Start(ctx, "LocalQueryBuilder/Execute")
 	defer span.End()
+	tags := TagsFromContext(ctx)
+	span.SetAttributes(tagAttributes(tags)...)
 
-	phyPlan, err := b.buildPhysical(ctx)
+	logicalPlan, err := b.buildLogical(ctx)
 	if err != nil {
 		return err
 	}
+	return b.execute(ctx, logicalPlan, callback)
+}
+
+// execute executes the given plan, built by buildLogical.
+func (b LocalQueryBuilder) execute(
+	ctx context.Context,
+	logicalPlan *logicalplan.LogicalPlan,
+	callback func(ctx context.Context, r arrow.Record) error,
+) error {
+	phyPlan, err := b.buildPhysical(ctx, logicalPlan)
+	if err != nil {
+		return err
+	}
+	return b.run(ctx, fingerprint(logicalPlan), phyPlan.DrawString(), func(ctx context.Context) error {
+		return phyPlan.Execute(ctx, b.pool, callback)
+	})
+}
 
-	return phyPlan.Execute(ctx, b.pool, callback)
+// run runs an execution of a physical plan, of the logical plan with the
+// given fingerprint, and tracks it as an active query.
+func (b LocalQueryBuilder) run(ctx context.Context, fingerprint, plan string, exec func(ctx context.Context) error) error {
+	var err error
+	done := b.queries.start(TagsFromContext(ctx), plan)
+	// The goroutines executing the query inherit the label, so that CPU
+	// profiles attribute the time spent to the shape of the query.
+	pprof.Do(ctx, pprof.Labels("query_fingerprint", fingerprint), func(ctx context.Context) {
+		err = exec(ctx)
+	})
+	done(err)
+	return err
 }
 
 func (b LocalQueryBuilder) Explain(ctx context.Context) (string, error) {
-	phyPlan, err := b.buildPhysical(ctx)
+	logicalPlan, err := b.buildLogical(ctx)
+	if err != nil {
+		return "", err
+	}
+	phyPlan, err := b.buildPhysical(ctx, logicalPlan)
 	if err != nil {
 		return "", err
 	}
 	return phyPlan.DrawString(), nil
 }
 
-func (b LocalQueryBuilder) buildPhysical(ctx context.Context) (*physicalplan.OutputPlan, error) {
-	logicalPlan, err := b.planBuilder.Build()
-	if err != nil {
-		return nil, err
-	}
+// Prepare plans the query once and returns a prepared query that executes it
+// any number of times, also concurrently, see PreparedQuery. Queries with
+// now() can't be prepared, since now() is bound to the time a query is
+// planned at.
+func (b LocalQueryBuilder) Prepare(ctx context.Context) (*PreparedQuery, error) {
+	ctx, span := b.tracer.Start(ctx, "LocalQueryBuilder/Prepare")
+	defer span.End()
 
-	for _, optimizer := range logicalplan.DefaultOptimizers() {
-		logicalPlan = optimizer.Optimize(logicalPlan)
+	if b.planBuilder.UsesNow() {
+		return nil, errors.New("queries with now() cannot be prepared")
 	}
+	return prepare(ctx, b)
+}
 
+func (b LocalQueryBuilder) buildPhysical(ctx context.Context, logicalPlan *logicalplan.LogicalPlan) (*physicalplan.OutputPlan, error) {
 	return physicalplan.Build(
 		ctx,
 		b.pool,
@@ -168,3 +310,60 @@ func (b LocalQueryBuilder) buildPhysical(ctx context.Context) (*physicalplan.Out
 		b.execOpts...,
 	)
 }
+
+func (b LocalQueryBuilder) buildLogical(ctx context.Context) (*logicalplan.LogicalPlan, error) {
+	return b.buildLogicalAt(ctx, b.clock.Now())
+}
+
+// buildLogicalAt builds the logical plan with now() bound to the given time.
+func (b LocalQueryBuilder) buildLogicalAt(ctx context.Context, now time.Time) (*logicalplan.LogicalPlan, error) {
+	logicalPlan, err := b.planBuilder.BuildAt(now)
+	if err != nil {
+		return nil, err
+	}
+	filters, err := b.scanFilters(ctx, logicalPlan)
+	if err != nil {
+		return nil, err
+	}
+	return optimize(logicalPlan, filters)
+}
+
+// scanFilters returns the filters the authorizer and the row filter of the
+// engine apply to the scan of the given plan under the given context.
+func (b LocalQueryBuilder) scanFilters(ctx context.Context, logicalPlan *logicalplan.LogicalPlan) ([]logicalplan.Expr, error) {
+	var filters []logicalplan.Expr
+	if b.authorizer != nil {
+		expr, err := authorize(ctx, b.authorizer, logicalPlan)
+		if err != nil {
+			return nil, err
+		}
+		if expr != nil {
+			filters = append(filters, expr)
+		}
+	}
+
+	if b.rowFilter != nil {
+		expr, err := rowFilter(ctx, b.rowFilter, logicalPlan)
+		if err != nil {
+			return nil, err
+		}
+		if expr != nil {
+			filters = append(filters, expr)
+		}
+	}
+	return filters, nil
+}
+
+// optimize applies the given scan filters to the plan and optimizes it.
+func optimize(logicalPlan *logicalplan.LogicalPlan, filters []logicalplan.Expr) (*logicalplan.LogicalPlan, error) {
+	logicalPlan, err := applyScanFilters(logicalPlan, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, optimizer := range logicalplan.DefaultOptimizers() {
+		logicalPlan = optimizer.Optimize(logicalPlan)
+	}
+
+	return logicalPlan, nil
+}
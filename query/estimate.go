@@ -0,0 +1,71 @@
+package query
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// ScanStats is the estimate of the data a query reads from a table.
+type ScanStats struct {
+	Table string
+	logicalplan.ScanEstimate
+}
+
+// StatsOnlyExecutor is implemented by the Builders that can estimate what a
+// query scans without executing it.
+type StatsOnlyExecutor interface {
+	ExecuteStatsOnly(ctx context.Context) ([]ScanStats, error)
+}
+
+// ExecuteStatsOnly plans the query and returns what each of its table scans
+// would read, i.e. the blocks and row groups left after pruning and their rows
+// and bytes, without executing it. This allows warning users before very
+// expensive queries are run. The authorizer and row filter of the engine are
+// applied like for Execute.
+func (b LocalQueryBuilder) ExecuteStatsOnly(ctx context.Context) ([]ScanStats, error) {
+	ctx, span := b.tracer.Start(ctx, "LocalQueryBuilder/ExecuteStatsOnly")
+	defer span.End()
+
+	logicalPlan, err := b.buildLogical(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []ScanStats
+	for plan := logicalPlan; plan != nil; plan = plan.Input {
+		scan := plan.TableScan
+		if scan == nil {
+			continue
+		}
+		table, err := scan.TableProvider.GetTable(scan.TableName)
+		if err != nil {
+			return nil, fmt.Errorf("get table %q: %w", scan.TableName, err)
+		}
+		estimator, ok := table.(logicalplan.ScanEstimator)
+		if !ok {
+			return nil, fmt.Errorf("table %s cannot estimate its scans", scan.TableName)
+		}
+
+		opts := []logicalplan.Option{
+			logicalplan.WithPhysicalProjection(scan.PhysicalProjection...),
+			logicalplan.WithFilter(scan.Filter),
+		}
+		if scan.SkipSources {
+			opts = append(opts, logicalplan.WithInMemoryOnly())
+		}
+		var estimate logicalplan.ScanEstimate
+		if err := table.View(ctx, func(ctx context.Context, tx uint64) error {
+			if scan.AsOfTx != 0 && scan.AsOfTx < tx {
+				tx = scan.AsOfTx
+			}
+			estimate, err = estimator.EstimateScan(ctx, tx, opts...)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		stats = append(stats, ScanStats{Table: scan.TableName, ScanEstimate: estimate})
+	}
+	return stats, nil
+}
@@ -85,6 +85,7 @@ func BinaryScalarOperation(left parquet.ColumnChunk, right parquet.Value, operat
 	if err != nil {
 		return true, err
 	}
+	cmp := comparator(left.Type())
 	numNulls := NullCount(leftColumnIndex)
 	fullOfNulls := numNulls == left.NumValues()
 	if operator == logicalplan.OpEq {
@@ -100,7 +101,7 @@ func BinaryScalarOperation(left parquet.ColumnChunk, right parquet.Value, operat
 		bloomFilter := left.BloomFilter()
 		if bloomFilter == nil {
 			// If there is no bloom filter then we cannot make a statement about true negative, instead check the min max values of the column chunk
-			return compare(right, Max(leftColumnIndex)) <= 0 && compare(right, Min(leftColumnIndex)) >= 0, nil
+			return cmp(right, MaxOfType(left.Type(), leftColumnIndex)) <= 0 && cmp(right, MinOfType(left.Type(), leftColumnIndex)) >= 0, nil
 		}
 
 		ok, err := bloomFilter.Check(right)
@@ -133,41 +134,41 @@ func BinaryScalarOperation(left parquet.ColumnChunk, right parquet.Value, operat
 
 	switch operator {
 	case logicalplan.OpLtEq:
-		min := Min(leftColumnIndex)
+		min := MinOfType(left.Type(), leftColumnIndex)
 		if min.IsNull() {
 			// If min is null, we don't know what the non-null min value is, so
 			// we need to let the execution engine scan this column chunk
 			// further.
 			return true, nil
 		}
-		return compare(min, right) <= 0, nil
+		return cmp(min, right) <= 0, nil
 	case logicalplan.OpLt:
-		min := Min(leftColumnIndex)
+		min := MinOfType(left.Type(), leftColumnIndex)
 		if min.IsNull() {
 			// If min is null, we don't know what the non-null min value is, so
 			// we need to let the execution engine scan this column chunk
 			// further.
 			return true, nil
 		}
-		return compare(min, right) < 0, nil
+		return cmp(min, right) < 0, nil
 	case logicalplan.OpGt:
-		max := Max(leftColumnIndex)
+		max := MaxOfType(left.Type(), leftColumnIndex)
 		if max.IsNull() {
 			// If max is null, we don't know what the non-null max value is, so
 			// we need to let the execution engine scan this column chunk
 			// further.
 			return true, nil
 		}
-		return compare(max, right) > 0, nil
+		return cmp(max, right) > 0, nil
 	case logicalplan.OpGtEq:
-		max := Max(leftColumnIndex)
+		max := MaxOfType(left.Type(), leftColumnIndex)
 		if max.IsNull() {
 			// If max is null, we don't know what the non-null max value is, so
 			// we need to let the execution engine scan this column chunk
 			// further.
 			return true, nil
 		}
-		return compare(max, right) >= 0, nil
+		return cmp(max, right) >= 0, nil
 	default:
 		return true, nil
 	}
@@ -175,6 +176,16 @@ func BinaryScalarOperation(left parquet.ColumnChunk, right parquet.Value, operat
 
 // Min returns the minimum value found in the column chunk across all pages.
 func Min(columnIndex parquet.ColumnIndex) parquet.Value {
+	return minValue(columnIndex, compare)
+}
+
+// MinOfType is the same as Min but orders the values by the given column
+// type, e.g. as unsigned for unsigned int columns.
+func MinOfType(typ parquet.Type, columnIndex parquet.ColumnIndex) parquet.Value {
+	return minValue(columnIndex, comparator(typ))
+}
+
+func minValue(columnIndex parquet.ColumnIndex, compare func(v1, v2 parquet.Value) int) parquet.Value {
 	min := columnIndex.MinValue(0)
 	for i := 1; i < columnIndex.NumPages(); i++ {
 		v := columnIndex.MinValue(i)
@@ -201,6 +212,16 @@ func NullCount(columnIndex parquet.ColumnIndex) int64 {
 
 // Max returns the maximum value found in the column chunk across all pages.
 func Max(columnIndex parquet.ColumnIndex) parquet.Value {
+	return maxValue(columnIndex, compare)
+}
+
+// MaxOfType is the same as Max but orders the values by the given column
+// type, e.g. as unsigned for unsigned int columns.
+func MaxOfType(typ parquet.Type, columnIndex parquet.ColumnIndex) parquet.Value {
+	return maxValue(columnIndex, comparator(typ))
+}
+
+func maxValue(columnIndex parquet.ColumnIndex, compare func(v1, v2 parquet.Value) int) parquet.Value {
 	max := columnIndex.MaxValue(0)
 	for i := 1; i < columnIndex.NumPages(); i++ {
 		v := columnIndex.MaxValue(i)
@@ -217,6 +238,25 @@ func Max(columnIndex parquet.ColumnIndex) parquet.Value {
 	return max
 }
 
+// comparator returns the function comparing the values of a column of the
+// given type. Values only know their physical kind, so the values of unsigned
+// int columns are compared by the column type rather than as signed ints.
+func comparator(typ parquet.Type) func(v1, v2 parquet.Value) int {
+	if IsUnsigned(typ) {
+		return typ.Compare
+	}
+	return compare
+}
+
+// IsUnsigned returns whether the given type is an unsigned int type.
+func IsUnsigned(typ parquet.Type) bool {
+	if typ == nil {
+		return false
+	}
+	lt := typ.LogicalType()
+	return lt != nil && lt.Integer != nil && !lt.Integer.IsSigned
+}
+
 // compares two parquet values. 0 if they are equal, -1 if v1 < v2, 1 if v1 > v2.
 func compare(v1, v2 parquet.Value) int {
 	switch v1.Kind() {
@@ -0,0 +1,133 @@
+package expr
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// PruningStats counts the row groups and blocks considered by a scan and how
+// many of them were skipped, by the reason they were skipped. It is safe for
+// concurrent use.
+type PruningStats struct {
+	// RowGroupsConsidered is the number of row groups a filter was
+	// evaluated on.
+	RowGroupsConsidered atomic.Int64
+	// RowGroupsSkippedByStatistics is the number of row groups skipped
+	// because of their min/max and null count statistics.
+	RowGroupsSkippedByStatistics atomic.Int64
+	// RowGroupsSkippedByBloomFilter is the number of row groups skipped
+	// because a bloom filter ruled out a value.
+	RowGroupsSkippedByBloomFilter atomic.Int64
+	// RowGroupsReadFromStatistics is the number of row groups whose minimum
+	// and maximum values were read from their statistics rather than their
+	// data.
+	RowGroupsReadFromStatistics atomic.Int64
+	// BlocksConsidered is the number of persisted blocks considered.
+	BlocksConsidered atomic.Int64
+	// BlocksSkipped is the number of persisted blocks skipped without
+	// reading their row groups because of the time range they cover or the
+	// statistics of their columns.
+	BlocksSkipped atomic.Int64
+	// BlocksPreAggregated is the number of persisted blocks whose partial
+	// aggregation results were read from their pre-aggregates rather than
+	// their row groups.
+	BlocksPreAggregated atomic.Int64
+}
+
+// Add adds the counts of other to s.
+func (s *PruningStats) Add(other *PruningStats) {
+	s.RowGroupsConsidered.Add(other.RowGroupsConsidered.Load())
+	s.RowGroupsSkippedByStatistics.Add(other.RowGroupsSkippedByStatistics.Load())
+	s.RowGroupsSkippedByBloomFilter.Add(other.RowGroupsSkippedByBloomFilter.Load())
+	s.RowGroupsReadFromStatistics.Add(other.RowGroupsReadFromStatistics.Load())
+	s.BlocksConsidered.Add(other.BlocksConsidered.Load())
+	s.BlocksSkipped.Add(other.BlocksSkipped.Load())
+	s.BlocksPreAggregated.Add(other.BlocksPreAggregated.Load())
+}
+
+// Eval evaluates the filter on the given particulate and records the outcome.
+// A nil PruningStats evaluates the filter without recording anything.
+func (s *PruningStats) Eval(f TrueNegativeFilter, p Particulate) (bool, error) {
+	if s == nil {
+		return f.Eval(p)
+	}
+
+	r := &bloomFilterRecorder{Particulate: p}
+	ok, err := f.Eval(r)
+	if err != nil {
+		return ok, err
+	}
+	s.RowGroupsConsidered.Add(1)
+	switch {
+	case ok:
+	case r.rejected:
+		s.RowGroupsSkippedByBloomFilter.Add(1)
+	default:
+		s.RowGroupsSkippedByStatistics.Add(1)
+	}
+	return ok, nil
+}
+
+type pruningStatsKey struct{}
+
+// WithPruningStats returns a context recording the pruning of the scans of
+// queries executed with it in the given stats.
+func WithPruningStats(ctx context.Context, s *PruningStats) context.Context {
+	return context.WithValue(ctx, pruningStatsKey{}, s)
+}
+
+// PruningStatsFromContext returns the stats set with WithPruningStats, or nil.
+func PruningStatsFromContext(ctx context.Context) *PruningStats {
+	s, _ := ctx.Value(pruningStatsKey{}).(*PruningStats)
+	return s
+}
+
+// bloomFilterRecorder records whether a bloom filter of the particulate ruled
+// out a value.
+type bloomFilterRecorder struct {
+	Particulate
+	chunks   []parquet.ColumnChunk
+	rejected bool
+}
+
+func (p *bloomFilterRecorder) ColumnChunks() []parquet.ColumnChunk {
+	if p.chunks != nil {
+		return p.chunks
+	}
+	chunks := p.Particulate.ColumnChunks()
+	p.chunks = make([]parquet.ColumnChunk, len(chunks))
+	for i, c := range chunks {
+		if c != nil {
+			p.chunks[i] = &bloomFilterRecordingChunk{ColumnChunk: c, p: p}
+		}
+	}
+	return p.chunks
+}
+
+type bloomFilterRecordingChunk struct {
+	parquet.ColumnChunk
+	p *bloomFilterRecorder
+}
+
+func (c *bloomFilterRecordingChunk) BloomFilter() parquet.BloomFilter {
+	f := c.ColumnChunk.BloomFilter()
+	if f == nil {
+		return nil
+	}
+	return &recordingBloomFilter{BloomFilter: f, p: c.p}
+}
+
+type recordingBloomFilter struct {
+	parquet.BloomFilter
+	p *bloomFilterRecorder
+}
+
+func (f *recordingBloomFilter) Check(v parquet.Value) (bool, error) {
+	ok, err := f.BloomFilter.Check(v)
+	if err == nil && !ok {
+		f.p.rejected = true
+	}
+	return ok, err
+}
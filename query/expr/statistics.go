@@ -0,0 +1,165 @@
+package expr
+
+import (
+	"context"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/pqarrow"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// MinMaxRecord returns a record of two rows holding the minimum and the
+// maximum values of the columns of the row group read by a scan with the
+// given options, read from the statistics of the row group rather than its
+// data. The record can only be used to compute the minimums and maximums of
+// the columns, so it is returned only if the scan reads min/max columns and
+// every row of the row group matches the filter of the scan. The filter
+// matches both rows of the record since, for the conjunctions of comparisons
+// it is checked for, every value of a column matches the comparisons on that
+// column. The record is nil if the statistics can't be used, e.g. if the
+// columns aren't numeric.
+func MinMaxRecord(ctx context.Context, pool memory.Allocator, rg parquet.RowGroup, options logicalplan.IterOptions) (arrow.Record, error) {
+	if len(options.MinMaxColumns) == 0 || rg.NumRows() == 0 {
+		return nil, nil
+	}
+	if _, ok := rg.(*dynparquet.MergedRowGroup); ok {
+		return nil, nil // merged row groups don't have statistics
+	}
+	if options.Filter != nil && !AllRowsMatch(options.Filter, rg) {
+		return nil, nil
+	}
+
+	schema, err := pqarrow.ParquetRowGroupToArrowSchema(ctx, rg, options)
+	if err != nil {
+		return nil, err
+	}
+	if schema.NumFields() == 0 {
+		return nil, nil
+	}
+	values := make([][2]parquet.Value, schema.NumFields())
+	for i, field := range schema.Fields() {
+		var kind parquet.Kind
+		switch field.Type.ID() {
+		case arrow.INT64:
+			kind = parquet.Int64
+		case arrow.FLOAT64:
+			kind = parquet.Double
+		case arrow.FLOAT32:
+			kind = parquet.Float
+		default:
+			return nil, nil
+		}
+		min, max, _, ok := columnStatistics(rg, field.Name)
+		if !ok || min.Kind() != kind || max.Kind() != kind {
+			return nil, nil
+		}
+		values[i] = [2]parquet.Value{min, max}
+	}
+
+	b := array.NewRecordBuilder(pool, schema)
+	defer b.Release()
+	for i, fb := range b.Fields() {
+		switch fb := fb.(type) {
+		case *array.Int64Builder:
+			fb.AppendValues([]int64{values[i][0].Int64(), values[i][1].Int64()}, nil)
+		case *array.Float64Builder:
+			fb.AppendValues([]float64{values[i][0].Double(), values[i][1].Double()}, nil)
+		case *array.Float32Builder:
+			fb.AppendValues([]float32{values[i][0].Float(), values[i][1].Float()}, nil)
+		}
+	}
+	return b.NewRecord(), nil
+}
+
+// AllRowsMatch returns true if the statistics of the particulate prove that
+// every row of the particulate matches the filter. Only conjunctions of
+// comparisons of columns with literals are supported, false is returned for
+// any other filter.
+func AllRowsMatch(filter logicalplan.Expr, p Particulate) bool {
+	e, ok := filter.(*logicalplan.BinaryExpr)
+	if !ok {
+		return false
+	}
+	if e.Op == logicalplan.OpAnd {
+		return AllRowsMatch(e.Left, p) && AllRowsMatch(e.Right, p)
+	}
+
+	col, ok := e.Left.(*logicalplan.Column)
+	if !ok {
+		return false
+	}
+	lit, ok := e.Right.(*logicalplan.LiteralExpr)
+	if !ok {
+		return false
+	}
+	v, err := pqarrow.ArrowScalarToParquetValue(lit.Value)
+	if err != nil || v.IsNull() {
+		return false
+	}
+	min, max, nulls, ok := columnStatistics(p, col.ColumnName)
+	if !ok || min.Kind() != v.Kind() || max.Kind() != v.Kind() {
+		return false
+	}
+	if nulls > 0 {
+		return false // null values don't match comparisons
+	}
+
+	switch e.Op {
+	case logicalplan.OpEq:
+		return compare(min, v) == 0 && compare(max, v) == 0
+	case logicalplan.OpNotEq:
+		return compare(max, v) < 0 || compare(min, v) > 0
+	case logicalplan.OpLt:
+		return compare(max, v) < 0
+	case logicalplan.OpLtEq:
+		return compare(max, v) <= 0
+	case logicalplan.OpGt:
+		return compare(min, v) > 0
+	case logicalplan.OpGtEq:
+		return compare(min, v) >= 0
+	default:
+		return false
+	}
+}
+
+// columnStatistics returns the minimum and maximum values and the number of
+// null values of the column of the particulate according to its column index,
+// if the column exists and has numeric non-null values.
+func columnStatistics(p Particulate, columnName string) (min, max parquet.Value, nulls int64, ok bool) {
+	columnChunk, exists, _ := (&ColumnRef{ColumnName: columnName}).Column(p)
+	if !exists || columnChunk == nil {
+		return min, max, 0, false
+	}
+	switch columnChunk.Type().Kind() {
+	case parquet.Int32, parquet.Int64, parquet.Float, parquet.Double:
+	default:
+		// The statistics of byte arrays may be truncated.
+		return min, max, 0, false
+	}
+	if IsUnsigned(columnChunk.Type()) {
+		// The statistics are compared as signed values.
+		return min, max, 0, false
+	}
+	columnIndex, err := columnChunk.ColumnIndex()
+	if err != nil || columnIndex == nil {
+		return min, max, 0, false
+	}
+	for i := 0; i < columnIndex.NumPages(); i++ {
+		nulls += columnIndex.NullCount(i)
+		if columnIndex.NullPage(i) {
+			continue
+		}
+		if v := columnIndex.MinValue(i); min.IsNull() || compare(v, min) < 0 {
+			min = v
+		}
+		if v := columnIndex.MaxValue(i); max.IsNull() || compare(v, max) > 0 {
+			max = v
+		}
+	}
+	return min, max, nulls, !min.IsNull() && !max.IsNull()
+}
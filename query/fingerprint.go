@@ -0,0 +1,86 @@
+package query
+
+import (
+	"strconv"
+
+	"github.com/apache/arrow/go/v14/arrow/scalar"
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// fingerprint returns a fingerprint of the shape of the logical plan, that is
+// of the plan with the values of its literals left out, so that queries only
+// differing by e.g. the time range they select share a fingerprint.
+func fingerprint(plan *logicalplan.LogicalPlan) string {
+	h := xxhash.New()
+	for p := plan; p != nil; p = p.Input {
+		switch {
+		case p.SchemaScan != nil:
+			_, _ = h.WriteString("SchemaScan")
+		case p.TableScan != nil:
+			scan := *p.TableScan
+			scan.PhysicalProjection = withoutLiterals(scan.PhysicalProjection...)
+			scan.Projection = withoutLiterals(scan.Projection...)
+			scan.Filter = withoutLiterals(scan.Filter)[0]
+			scan.Distinct = withoutLiterals(scan.Distinct...)
+			scan.MinMax = withoutLiterals(scan.MinMax...)
+			if scan.PreAggregation != nil {
+				scan.PreAggregation = &logicalplan.Aggregation{
+					AggExprs:   withoutLiterals(scan.PreAggregation.AggExprs...),
+					GroupExprs: withoutLiterals(scan.PreAggregation.GroupExprs...),
+				}
+			}
+			scan.AsOfTx = 0
+			_, _ = h.WriteString(scan.String())
+		case p.Filter != nil:
+			_, _ = h.WriteString((&logicalplan.Filter{Expr: withoutLiterals(p.Filter.Expr)[0]}).String())
+		case p.Distinct != nil:
+			_, _ = h.WriteString("Distinct")
+			writeExprs(h, withoutLiterals(p.Distinct.Exprs...))
+		case p.Projection != nil:
+			_, _ = h.WriteString("Projection")
+			writeExprs(h, withoutLiterals(p.Projection.Exprs...))
+		case p.Aggregation != nil:
+			_, _ = h.WriteString((&logicalplan.Aggregation{
+				AggExprs:   withoutLiterals(p.Aggregation.AggExprs...),
+				GroupExprs: withoutLiterals(p.Aggregation.GroupExprs...),
+			}).String())
+		}
+		_, _ = h.WriteString("\n")
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+func writeExprs(h *xxhash.Digest, exprs []logicalplan.Expr) {
+	for _, e := range exprs {
+		_, _ = h.WriteString(" " + e.String())
+	}
+}
+
+// withoutLiterals returns copies of the expressions with the values of their
+// literals replaced by nulls of the same type. Nil expressions are kept.
+func withoutLiterals(exprs ...logicalplan.Expr) []logicalplan.Expr {
+	res := make([]logicalplan.Expr, len(exprs))
+	for i, e := range exprs {
+		if e == nil {
+			continue
+		}
+		res[i] = e.Clone()
+		res[i].Accept(literalEraser{})
+	}
+	return res
+}
+
+type literalEraser struct{}
+
+func (literalEraser) PreVisit(_ logicalplan.Expr) bool { return true }
+
+func (literalEraser) Visit(_ logicalplan.Expr) bool { return true }
+
+func (literalEraser) PostVisit(expr logicalplan.Expr) bool {
+	if l, ok := expr.(*logicalplan.LiteralExpr); ok {
+		l.Value = scalar.MakeNullScalar(l.Value.DataType())
+	}
+	return true
+}
@@ -2,6 +2,7 @@ package logicalplan
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/apache/arrow/go/v14/arrow"
 	"github.com/parquet-go/parquet-go"
@@ -39,6 +40,36 @@ func (b Builder) ScanSchema(
 	}
 }
 
+// AsOfTx makes the scan of the plan read the table as of the given
+// transaction instead of the latest committed transaction.
+func (b Builder) AsOfTx(tx uint64) Builder {
+	return Builder{
+		plan: withAsOfTx(b.plan, tx),
+	}
+}
+
+// withAsOfTx returns a copy of the plan whose scan reads the table as of the
+// given transaction. The plan is copied since builders share their inputs.
+func withAsOfTx(plan *LogicalPlan, tx uint64) *LogicalPlan {
+	if plan == nil {
+		return nil
+	}
+	p := *plan
+	switch {
+	case p.TableScan != nil:
+		scan := *p.TableScan
+		scan.AsOfTx = tx
+		p.TableScan = &scan
+	case p.SchemaScan != nil:
+		scan := *p.SchemaScan
+		scan.AsOfTx = tx
+		p.SchemaScan = &scan
+	default:
+		p.Input = withAsOfTx(p.Input, tx)
+	}
+	return &p
+}
+
 func (b Builder) Project(
 	exprs ...Expr,
 ) Builder {
@@ -89,6 +120,10 @@ type Expr interface {
 	Clone() Expr
 }
 
+// Filter filters the rows by the given expression. Comparisons of literals
+// with columns, e.g. `5 < value`, are normalized to compare the columns with
+// the literals, e.g. `value > 5`. Boolean columns can be used as predicates
+// directly, e.g. `Col("is_error")` or `Not(Col("is_error"))`.
 func (b Builder) Filter(expr Expr) Builder {
 	if expr == nil {
 		return b
@@ -98,12 +133,64 @@ func (b Builder) Filter(expr Expr) Builder {
 		plan: &LogicalPlan{
 			Input: b.plan,
 			Filter: &Filter{
-				Expr: expr,
+				Expr: NormalizeFilterExpr(expr),
 			},
 		},
 	}
 }
 
+// NormalizeFilterExpr returns the filter expression with the operands of the
+// comparisons of literals with other expressions swapped, so that literals are
+// always on the right side of comparisons. Boolean columns used as predicates
+// are rewritten to compare them with true, or with false if they are negated.
+func NormalizeFilterExpr(expr Expr) Expr {
+	switch e := expr.(type) {
+	case *Column:
+		return e.Eq(Literal(true))
+	case *NotExpr:
+		if col, ok := e.Expr.(*Column); ok {
+			return col.Eq(Literal(false))
+		}
+	}
+
+	e, ok := expr.(*BinaryExpr)
+	if !ok {
+		return expr
+	}
+
+	switch e.Op {
+	case OpAnd, OpOr:
+		left, right := NormalizeFilterExpr(e.Left), NormalizeFilterExpr(e.Right)
+		if left == e.Left && right == e.Right {
+			return e
+		}
+		return &BinaryExpr{Left: left, Op: e.Op, Right: right}
+	}
+
+	if _, ok := e.Left.(*LiteralExpr); !ok {
+		return e
+	}
+	if _, ok := e.Right.(*LiteralExpr); ok {
+		return e
+	}
+	op, ok := flippedOps[e.Op]
+	if !ok {
+		return e
+	}
+	return &BinaryExpr{Left: e.Right, Op: op, Right: e.Left}
+}
+
+// flippedOps maps comparison operators to the operators that compare the same
+// with their operands swapped.
+var flippedOps = map[Op]Op{
+	OpEq:    OpEq,
+	OpNotEq: OpNotEq,
+	OpLt:    OpGt,
+	OpLtEq:  OpGtEq,
+	OpGt:    OpLt,
+	OpGtEq:  OpLtEq,
+}
+
 func (b Builder) Distinct(
 	exprs ...Expr,
 ) Builder {
@@ -132,9 +219,31 @@ func (b Builder) Aggregate(
 	}
 }
 
+// String returns the plan of the builder with now() unbound, so that it
+// identifies the query regardless of the time the plan is built at.
+func (b Builder) String() string {
+	if b.plan == nil {
+		return ""
+	}
+	return b.plan.String()
+}
+
+// Build builds the plan with now() bound to the current time.
 func (b Builder) Build() (*LogicalPlan, error) {
-	if err := Validate(b.plan); err != nil {
+	return b.BuildAt(time.Now())
+}
+
+// BuildAt builds the plan with now() bound to the given time.
+func (b Builder) BuildAt(now time.Time) (*LogicalPlan, error) {
+	plan, err := BindTime(b.plan, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := CoerceTypes(plan); err != nil {
+		return nil, err
+	}
+	if err := Validate(plan); err != nil {
 		return nil, err
 	}
-	return b.plan, nil
+	return plan, nil
 }
@@ -75,3 +75,37 @@ func TestLogicalPlanBuilderWithoutProjection(t *testing.T) {
 		},
 	}, p)
 }
+
+func TestLogicalPlanBuilderNormalizesFilter(t *testing.T) {
+	tableProvider := &mockTableProvider{schema: dynparquet.NewSampleSchema()}
+	p, err := (&Builder{}).
+		Scan(tableProvider, "table1").
+		Filter(And(
+			&BinaryExpr{Left: Literal(int64(5)), Op: OpLt, Right: Col("value")},
+			Or(
+				&BinaryExpr{Left: Literal("abc"), Op: OpEq, Right: Col("labels.test")},
+				&BinaryExpr{Left: Literal(int64(10)), Op: OpGtEq, Right: Col("timestamp")},
+			),
+		)).
+		Build()
+	require.NoError(t, err)
+
+	require.Equal(t, And(
+		Col("value").Gt(Literal(int64(5))),
+		Or(
+			Col("labels.test").Eq(Literal("abc")),
+			Col("timestamp").LtEq(Literal(int64(10))),
+		),
+	), p.Filter.Expr)
+
+	p, err = (&Builder{}).
+		Scan(tableProvider, "table1").
+		Filter(Or(Col("is_error"), Not(Col("is_retry")))).
+		Build()
+	require.NoError(t, err)
+
+	require.Equal(t, Or(
+		Col("is_error").Eq(Literal(true)),
+		Col("is_retry").Eq(Literal(false)),
+	), p.Filter.Expr)
+}
@@ -0,0 +1,284 @@
+package logicalplan
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/apache/arrow/go/v14/arrow/scalar"
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+)
+
+// maxExactFloat64Int is the largest integer magnitude that float64 represents
+// exactly.
+const maxExactFloat64Int = 1 << 53
+
+// maxExactFloat32Int is the largest integer magnitude that float32 represents
+// exactly.
+const maxExactFloat32Int = 1 << 24
+
+// CoerceTypes rewrites the literals compared with columns in the filters,
+// projections and aggregations of the plan to the type of the columns, so
+// that e.g. an int literal can be compared with a float column. Int literals
+// are widened to float for float columns, float literals are rounded to the
+// nearest float32 for float32 columns, and float literals are narrowed to int
+// for int and uint64 columns, rounding them in the direction of the
+// comparison if they are fractional. Comparisons that would lose precision
+// are reported as errors instead.
+func CoerceTypes(plan *LogicalPlan) error {
+	var (
+		first *PlanValidationError
+		last  *PlanValidationError
+	)
+	for p := plan; p != nil; p = p.Input {
+		if err := coercePlanTypes(p); err != nil {
+			if first == nil {
+				first = err
+			} else {
+				last.input = err
+			}
+			last = err
+		}
+	}
+	if first != nil {
+		return first
+	}
+	return nil
+}
+
+// coercePlanTypes coerces the literals of a single step of the plan.
+func coercePlanTypes(plan *LogicalPlan) *PlanValidationError {
+	if plan.Filter == nil && plan.Projection == nil && plan.Aggregation == nil {
+		return nil
+	}
+	schema := plan.InputSchema()
+	if schema == nil {
+		return nil // cannot coerce literals if the column types are unknown
+	}
+
+	// The expressions may be shared with other plans, e.g. the fragments of a
+	// distributed query, so they are copied rather than modified in place.
+	var children []*ExprValidationError
+	coerceAll := func(exprs []Expr) ([]Expr, bool) {
+		coercedExprs := exprs
+		changed := false
+		for i, expr := range exprs {
+			coerced, err := coerceExpr(schema, expr)
+			if err != nil {
+				children = append(children, err)
+				continue
+			}
+			if coerced == expr {
+				continue
+			}
+			if !changed {
+				coercedExprs = append([]Expr(nil), exprs...)
+				changed = true
+			}
+			coercedExprs[i] = coerced
+		}
+		return coercedExprs, changed
+	}
+	switch {
+	case plan.Filter != nil:
+		if exprs, changed := coerceAll([]Expr{plan.Filter.Expr}); changed {
+			plan.Filter.Expr = exprs[0]
+		}
+	case plan.Projection != nil:
+		if exprs, changed := coerceAll(plan.Projection.Exprs); changed {
+			plan.Projection.Exprs = exprs
+		}
+	case plan.Aggregation != nil:
+		if exprs, changed := coerceAll(plan.Aggregation.AggExprs); changed {
+			plan.Aggregation.AggExprs = exprs
+		}
+		if exprs, changed := coerceAll(plan.Aggregation.GroupExprs); changed {
+			plan.Aggregation.GroupExprs = exprs
+		}
+	}
+
+	if len(children) > 0 {
+		return &PlanValidationError{
+			plan:     plan,
+			message:  "invalid comparison",
+			children: children,
+		}
+	}
+	return nil
+}
+
+// coerceExpr returns the expression with the literals compared with columns
+// coerced to the type of the columns. The expression is copied rather than
+// modified if literals are coerced.
+func coerceExpr(schema *dynparquet.Schema, expr Expr) (Expr, *ExprValidationError) {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		if e.Op == OpAnd || e.Op == OpOr {
+			left, err := coerceExpr(schema, e.Left)
+			if err != nil {
+				return nil, err
+			}
+			right, err := coerceExpr(schema, e.Right)
+			if err != nil {
+				return nil, err
+			}
+			if left == e.Left && right == e.Right {
+				return e, nil
+			}
+			return &BinaryExpr{Left: left, Op: e.Op, Right: right}, nil
+		}
+
+		col, ok := e.Left.(*Column)
+		if !ok {
+			return e, nil
+		}
+		lit, ok := e.Right.(*LiteralExpr)
+		if !ok {
+			return e, nil
+		}
+		column, found := findColumn(schema, col.ColumnName)
+		if !found {
+			return e, nil
+		}
+		var (
+			op    Op
+			value scalar.Scalar
+			err   error
+		)
+		typ := column.StorageLayout.Type()
+		if lt := typ.LogicalType(); lt != nil && lt.Integer != nil && !lt.Integer.IsSigned {
+			op, value, err = coerceUnsignedComparison(e.Op, lit.Value)
+		} else {
+			op, value, err = coerceComparison(typ.Kind(), e.Op, lit.Value)
+		}
+		if err != nil {
+			return nil, &ExprValidationError{
+				message: err.Error(),
+				expr:    e,
+			}
+		}
+		if value == lit.Value {
+			return e, nil
+		}
+		return &BinaryExpr{Left: e.Left, Op: op, Right: &LiteralExpr{Value: value}}, nil
+	case *AliasExpr:
+		inner, err := coerceExpr(schema, e.Expr)
+		if err != nil {
+			return nil, err
+		}
+		if inner == e.Expr {
+			return e, nil
+		}
+		return &AliasExpr{Expr: inner, Alias: e.Alias}, nil
+	case *AggregationFunction:
+		inner, err := coerceExpr(schema, e.Expr)
+		if err != nil {
+			return nil, err
+		}
+		if inner == e.Expr {
+			return e, nil
+		}
+		return &AggregationFunction{Func: e.Func, Expr: inner}, nil
+	default:
+		return expr, nil
+	}
+}
+
+// coerceComparison returns the operator and literal of a comparison with a
+// column of the given kind, with the literal converted to the type of the
+// column. Comparing an int column with a fractional float is rewritten to the
+// equivalent comparison with the nearest int, e.g. x > 1.5 to x >= 2, while
+// testing it for equality is reported as lossy as it can never match.
+func coerceComparison(kind parquet.Kind, op Op, literal scalar.Scalar) (Op, scalar.Scalar, error) {
+	switch kind {
+	case parquet.Int64:
+		f, ok := literal.(*scalar.Float64)
+		if !ok {
+			return op, literal, nil
+		}
+		if math.IsNaN(f.Value) || math.Abs(f.Value) > maxExactFloat64Int {
+			return op, nil, fmt.Errorf("lossy comparison: int64 column cannot be compared with float literal %v", f.Value)
+		}
+		if math.Trunc(f.Value) == f.Value {
+			return op, scalar.NewInt64Scalar(int64(f.Value)), nil
+		}
+		switch op {
+		case OpGt, OpGtEq:
+			return OpGtEq, scalar.NewInt64Scalar(int64(math.Ceil(f.Value))), nil
+		case OpLt, OpLtEq:
+			return OpLtEq, scalar.NewInt64Scalar(int64(math.Floor(f.Value))), nil
+		default:
+			return op, nil, fmt.Errorf("lossy comparison: int64 column cannot be compared with float literal %v", f.Value)
+		}
+	case parquet.Double:
+		i, ok := literal.(*scalar.Int64)
+		if !ok {
+			return op, literal, nil
+		}
+		if i.Value > maxExactFloat64Int || i.Value < -maxExactFloat64Int {
+			return op, nil, fmt.Errorf("lossy comparison: float column cannot be compared with int literal %d", i.Value)
+		}
+		return op, scalar.NewFloat64Scalar(float64(i.Value)), nil
+	case parquet.Float:
+		switch v := literal.(type) {
+		case *scalar.Int64:
+			if v.Value > maxExactFloat32Int || v.Value < -maxExactFloat32Int {
+				return op, nil, fmt.Errorf("lossy comparison: float32 column cannot be compared with int literal %d", v.Value)
+			}
+			return op, scalar.NewFloat32Scalar(float32(v.Value)), nil
+		case *scalar.Float64:
+			if math.Abs(v.Value) > math.MaxFloat32 {
+				return op, nil, fmt.Errorf("lossy comparison: float32 column cannot be compared with float literal %v", v.Value)
+			}
+			return op, scalar.NewFloat32Scalar(float32(v.Value)), nil
+		default:
+			return op, literal, nil
+		}
+	default:
+		return op, literal, nil
+	}
+}
+
+// coerceUnsignedComparison is the same as coerceComparison but for unsigned
+// int columns, whose literals are converted to uint64. As the values of the
+// column are never negative, comparing it with a negative literal is
+// rewritten to the equivalent comparison with zero, e.g. x > -1 to x >= 0 and
+// x < -1 to x < 0, which matches no values, while testing it for equality is
+// reported as lossy.
+func coerceUnsignedComparison(op Op, literal scalar.Scalar) (Op, scalar.Scalar, error) {
+	switch l := literal.(type) {
+	case *scalar.Int64:
+		if l.Value >= 0 {
+			return op, scalar.NewUint64Scalar(uint64(l.Value)), nil
+		}
+	case *scalar.Float64:
+		if math.IsNaN(l.Value) || math.Abs(l.Value) > maxExactFloat64Int {
+			return op, nil, fmt.Errorf("lossy comparison: uint64 column cannot be compared with float literal %v", l.Value)
+		}
+		if l.Value >= 0 {
+			if math.Trunc(l.Value) == l.Value {
+				return op, scalar.NewUint64Scalar(uint64(l.Value)), nil
+			}
+			switch op {
+			case OpGt, OpGtEq:
+				return OpGtEq, scalar.NewUint64Scalar(uint64(math.Ceil(l.Value))), nil
+			case OpLt, OpLtEq:
+				return OpLtEq, scalar.NewUint64Scalar(uint64(math.Floor(l.Value))), nil
+			default:
+				return op, nil, fmt.Errorf("lossy comparison: uint64 column cannot be compared with float literal %v", l.Value)
+			}
+		}
+	default:
+		return op, literal, nil
+	}
+
+	switch op {
+	case OpGt, OpGtEq:
+		return OpGtEq, scalar.NewUint64Scalar(0), nil
+	case OpLt, OpLtEq:
+		return OpLt, scalar.NewUint64Scalar(0), nil
+	default:
+		return op, nil, fmt.Errorf("lossy comparison: uint64 column cannot be compared with negative literal %v", literal)
+	}
+}
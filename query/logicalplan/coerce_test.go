@@ -0,0 +1,145 @@
+package logicalplan
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow/scalar"
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	schemapb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/schema/v1alpha1"
+)
+
+func floatSampleSchema(t *testing.T) *dynparquet.Schema {
+	t.Helper()
+	schema, err := dynparquet.SchemaFromDefinition(dynparquet.SampleDefinitionWithFloat())
+	require.NoError(t, err)
+	return schema
+}
+
+func unsignedSampleSchema(t *testing.T) *dynparquet.Schema {
+	t.Helper()
+	schema, err := dynparquet.SchemaFromDefinition(&schemapb.Schema{
+		Name: "unsigned",
+		Columns: []*schemapb.Column{{
+			Name: "hash",
+			StorageLayout: &schemapb.StorageLayout{
+				Type: schemapb.StorageLayout_TYPE_UINT64,
+			},
+		}},
+		SortingColumns: []*schemapb.SortingColumn{{
+			Name:      "hash",
+			Direction: schemapb.SortingColumn_DIRECTION_ASCENDING,
+		}},
+	})
+	require.NoError(t, err)
+	return schema
+}
+
+func TestCoerceIntLiteralToFloatColumn(t *testing.T) {
+	plan, err := (&Builder{}).
+		Scan(&mockTableProvider{floatSampleSchema(t)}, "table1").
+		Filter(And(
+			Col("floatvalue").Gt(Literal(2)),
+			Col("timestamp").Gt(Literal(1)),
+		)).
+		Build()
+	require.NoError(t, err)
+
+	and := plan.Filter.Expr.(*BinaryExpr)
+	floatLit := and.Left.(*BinaryExpr).Right.(*LiteralExpr)
+	require.Equal(t, scalar.NewFloat64Scalar(2), floatLit.Value)
+	intLit := and.Right.(*BinaryExpr).Right.(*LiteralExpr)
+	require.Equal(t, scalar.NewInt64Scalar(1), intLit.Value)
+}
+
+func TestCoerceIntegralFloatLiteralToIntColumn(t *testing.T) {
+	plan, err := (&Builder{}).
+		Scan(&mockTableProvider{dynparquet.NewSampleSchema()}, "table1").
+		Filter(Col("timestamp").GtEq(Literal(4.0))).
+		Build()
+	require.NoError(t, err)
+
+	lit := plan.Filter.Expr.(*BinaryExpr).Right.(*LiteralExpr)
+	require.Equal(t, scalar.NewInt64Scalar(4), lit.Value)
+}
+
+func TestCoerceFractionalFloatLiteralToIntColumn(t *testing.T) {
+	plan, err := (&Builder{}).
+		Scan(&mockTableProvider{dynparquet.NewSampleSchema()}, "table1").
+		Filter(And(
+			Col("timestamp").Gt(Literal(1.5)),
+			Col("timestamp").Lt(Literal(4.5)),
+		)).
+		Build()
+	require.NoError(t, err)
+
+	and := plan.Filter.Expr.(*BinaryExpr)
+	require.Equal(t, "timestamp >= 2", and.Left.String())
+	require.Equal(t, "timestamp <= 4", and.Right.String())
+}
+
+func TestCoerceLossyComparison(t *testing.T) {
+	_, err := (&Builder{}).
+		Scan(&mockTableProvider{dynparquet.NewSampleSchema()}, "table1").
+		Filter(Col("timestamp").Eq(Literal(4.6))).
+		Build()
+
+	require.NotNil(t, err)
+	planErr, ok := err.(*PlanValidationError)
+	require.True(t, ok)
+	require.True(t, strings.HasPrefix(planErr.message, "invalid comparison"))
+	require.Len(t, planErr.children, 1)
+	require.True(t, strings.HasPrefix(planErr.children[0].message, "lossy comparison: int64 column cannot be compared with float literal 4.6"))
+
+	_, err = (&Builder{}).
+		Scan(&mockTableProvider{floatSampleSchema(t)}, "table1").
+		Filter(Col("floatvalue").Eq(Literal(int64(1<<53 + 1)))).
+		Build()
+
+	require.NotNil(t, err)
+	planErr, ok = err.(*PlanValidationError)
+	require.True(t, ok)
+	require.Len(t, planErr.children, 1)
+	require.True(t, strings.HasPrefix(planErr.children[0].message, "lossy comparison: float column cannot be compared with int literal"))
+}
+
+func TestCoerceLiteralToUnsignedColumn(t *testing.T) {
+	plan, err := (&Builder{}).
+		Scan(&mockTableProvider{unsignedSampleSchema(t)}, "table1").
+		Filter(And(
+			Col("hash").Gt(Literal(2)),
+			Col("hash").Lt(Literal(4.5)),
+		)).
+		Build()
+	require.NoError(t, err)
+
+	and := plan.Filter.Expr.(*BinaryExpr)
+	require.Equal(t, scalar.NewUint64Scalar(2), and.Left.(*BinaryExpr).Right.(*LiteralExpr).Value)
+	require.Equal(t, "hash <= 4", and.Right.String())
+
+	plan, err = (&Builder{}).
+		Scan(&mockTableProvider{unsignedSampleSchema(t)}, "table1").
+		Filter(Or(
+			Col("hash").Gt(Literal(-1)),
+			Col("hash").LtEq(Literal(-1)),
+		)).
+		Build()
+	require.NoError(t, err)
+
+	or := plan.Filter.Expr.(*BinaryExpr)
+	require.Equal(t, "hash >= 0", or.Left.String())
+	require.Equal(t, "hash < 0", or.Right.String())
+
+	_, err = (&Builder{}).
+		Scan(&mockTableProvider{unsignedSampleSchema(t)}, "table1").
+		Filter(Col("hash").Eq(Literal(-1))).
+		Build()
+
+	require.NotNil(t, err)
+	planErr, ok := err.(*PlanValidationError)
+	require.True(t, ok)
+	require.Len(t, planErr.children, 1)
+	require.True(t, strings.HasPrefix(planErr.children[0].message, "lossy comparison: uint64 column cannot be compared with negative literal"))
+}
@@ -2,7 +2,9 @@ package logicalplan
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,6 +29,8 @@ const (
 	OpRegexNotMatch
 	OpAnd
 	OpOr
+	OpAdd
+	OpSub
 )
 
 func (o Op) String() string {
@@ -51,8 +55,12 @@ func (o Op) String() string {
 		return "&&"
 	case OpOr:
 		return "||"
+	case OpAdd:
+		return "+"
+	case OpSub:
+		return "-"
 	default:
-		panic("unknown operator")
+		return fmt.Sprintf("unknown operator %d", int(o))
 	}
 }
 
@@ -263,6 +271,17 @@ func (c *Column) LtEq(e Expr) *BinaryExpr {
 	}
 }
 
+// IsNull returns an expression matching the rows where the column is null.
+func (c *Column) IsNull() *BinaryExpr {
+	return c.Eq(Null())
+}
+
+// IsNotNull returns an expression matching the rows where the column is not
+// null.
+func (c *Column) IsNotNull() *BinaryExpr {
+	return c.NotEq(Null())
+}
+
 func (c *Column) RegexMatch(pattern string) *BinaryExpr {
 	return &BinaryExpr{
 		Left:  c,
@@ -406,6 +425,43 @@ func Literal(v interface{}) *LiteralExpr {
 	}
 }
 
+// Int returns an int64 literal.
+func Int(v int64) *LiteralExpr {
+	return &LiteralExpr{Value: scalar.NewInt64Scalar(v)}
+}
+
+// Float returns a float64 literal.
+func Float(v float64) *LiteralExpr {
+	return &LiteralExpr{Value: scalar.NewFloat64Scalar(v)}
+}
+
+// Bool returns a boolean literal.
+func Bool(v bool) *LiteralExpr {
+	return &LiteralExpr{Value: scalar.NewBooleanScalar(v)}
+}
+
+// String returns a string literal.
+func String(v string) *LiteralExpr {
+	return &LiteralExpr{Value: scalar.NewStringScalar(v)}
+}
+
+// Bytes returns a binary literal.
+func Bytes(v []byte) *LiteralExpr {
+	return Literal(v)
+}
+
+// Timestamp returns an int64 literal of the time in milliseconds since the
+// Unix epoch, the unit of timestamp columns, e.g. the one durations group.
+func Timestamp(t time.Time) *LiteralExpr {
+	return Int(t.UnixMilli())
+}
+
+// Null returns a null literal. Columns equal to it are null, columns not equal
+// to it are not null.
+func Null() *LiteralExpr {
+	return &LiteralExpr{Value: scalar.ScalarNull}
+}
+
 func (e *LiteralExpr) DataType(_ *parquet.Schema) (arrow.DataType, error) {
 	return e.Value.DataType(), nil
 }
@@ -438,16 +494,33 @@ func (e *LiteralExpr) MatchColumn(columnName string) bool {
 type AggregationFunction struct {
 	Func AggFunc
 	Expr Expr
+	// Boundaries are the upper bounds of the buckets of histogram
+	// aggregations, in increasing order.
+	Boundaries []float64
+	// OrderByExpr is the column first and last aggregations order the rows
+	// of a group by.
+	OrderByExpr Expr
 }
 
 func (f *AggregationFunction) Clone() Expr {
-	return &AggregationFunction{
-		Func: f.Func,
-		Expr: f.Expr.Clone(),
+	c := &AggregationFunction{
+		Func:       f.Func,
+		Expr:       f.Expr.Clone(),
+		Boundaries: f.Boundaries,
+	}
+	if f.OrderByExpr != nil {
+		c.OrderByExpr = f.OrderByExpr.Clone()
 	}
+	return c
 }
 
 func (f *AggregationFunction) DataType(s *parquet.Schema) (arrow.DataType, error) {
+	switch f.Func {
+	case AggFuncHistogram:
+		return arrow.ListOf(arrow.PrimitiveTypes.Int64), nil
+	case AggFuncStddev, AggFuncVariance:
+		return arrow.PrimitiveTypes.Float64, nil
+	}
 	return f.Expr.DataType(s)
 }
 
@@ -470,12 +543,25 @@ func (f *AggregationFunction) Computed() bool {
 }
 
 func (f *AggregationFunction) Name() string {
+	if f.Func == AggFuncHistogram {
+		boundaries := make([]string, 0, len(f.Boundaries))
+		for _, b := range f.Boundaries {
+			boundaries = append(boundaries, strconv.FormatFloat(b, 'g', -1, 64))
+		}
+		return f.Func.String() + "(" + f.Expr.Name() + ", [" + strings.Join(boundaries, ", ") + "])"
+	}
+	if f.OrderByExpr != nil {
+		return f.Func.String() + "(" + f.Expr.Name() + " order by " + f.OrderByExpr.Name() + ")"
+	}
 	return f.Func.String() + "(" + f.Expr.Name() + ")"
 }
 
 func (f *AggregationFunction) String() string { return f.Name() }
 
 func (f *AggregationFunction) ColumnsUsedExprs() []Expr {
+	if f.OrderByExpr != nil {
+		return append(f.Expr.ColumnsUsedExprs(), f.OrderByExpr.ColumnsUsedExprs()...)
+	}
 	return f.Expr.ColumnsUsedExprs()
 }
 
@@ -496,6 +582,12 @@ const (
 	AggFuncMax
 	AggFuncCount
 	AggFuncAvg
+	AggFuncHistogram
+	AggFuncFirst
+	AggFuncLast
+	AggFuncStddev
+	AggFuncVariance
+	AggFuncAnyValue
 )
 
 func (f AggFunc) String() string {
@@ -510,8 +602,20 @@ func (f AggFunc) String() string {
 		return "count"
 	case AggFuncAvg:
 		return "avg"
+	case AggFuncHistogram:
+		return "histogram"
+	case AggFuncFirst:
+		return "first"
+	case AggFuncLast:
+		return "last"
+	case AggFuncStddev:
+		return "stddev"
+	case AggFuncVariance:
+		return "variance"
+	case AggFuncAnyValue:
+		return "any_value"
 	default:
-		panic("unknown aggregation function")
+		return fmt.Sprintf("unknown aggregation function %d", int(f))
 	}
 }
 
@@ -550,6 +654,69 @@ func Avg(expr Expr) *AggregationFunction {
 	}
 }
 
+// Histogram counts the values of the expression in the buckets delimited by
+// the given increasing boundaries. The i-th bucket counts the values that are
+// greater than the (i-1)-th boundary and less than or equal to the i-th
+// boundary, and a last bucket counts the values greater than all boundaries.
+// The result is a list of the counts of the buckets.
+func Histogram(expr Expr, boundaries ...float64) *AggregationFunction {
+	return &AggregationFunction{
+		Func:       AggFuncHistogram,
+		Expr:       expr,
+		Boundaries: boundaries,
+	}
+}
+
+// First returns the value of the expression of the first row of each group in
+// the order of the timestamp column. Use OrderBy to order the rows by another
+// column.
+func First(expr Expr) *AggregationFunction {
+	return &AggregationFunction{
+		Func:        AggFuncFirst,
+		Expr:        expr,
+		OrderByExpr: Col("timestamp"),
+	}
+}
+
+// Last returns the value of the expression of the last row of each group in
+// the order of the timestamp column, e.g. the most recent value of a gauge.
+// Use OrderBy to order the rows by another column.
+func Last(expr Expr) *AggregationFunction {
+	return &AggregationFunction{
+		Func:        AggFuncLast,
+		Expr:        expr,
+		OrderByExpr: Col("timestamp"),
+	}
+}
+
+// Stddev returns the sample standard deviation of the values of the
+// expression of each group.
+func Stddev(expr Expr) *AggregationFunction {
+	return &AggregationFunction{
+		Func: AggFuncStddev,
+		Expr: expr,
+	}
+}
+
+// Variance returns the sample variance of the values of the expression of
+// each group.
+func Variance(expr Expr) *AggregationFunction {
+	return &AggregationFunction{
+		Func: AggFuncVariance,
+		Expr: expr,
+	}
+}
+
+// AnyValue returns an arbitrary non-null value of the expression of each
+// group, e.g. to carry along a label that is the same for all the rows of a
+// group without grouping by it.
+func AnyValue(expr Expr) *AggregationFunction {
+	return &AggregationFunction{
+		Func: AggFuncAnyValue,
+		Expr: expr,
+	}
+}
+
 type AliasExpr struct {
 	Expr  Expr
 	Alias string
@@ -602,6 +769,13 @@ func (e *AliasExpr) Accept(visitor Visitor) bool {
 	return visitor.PostVisit(e)
 }
 
+// OrderBy sets the column first and last aggregations order the rows of a
+// group by.
+func (f *AggregationFunction) OrderBy(expr Expr) *AggregationFunction {
+	f.OrderByExpr = expr
+	return f
+}
+
 func (f *AggregationFunction) Alias(alias string) *AliasExpr {
 	return &AliasExpr{
 		Expr:  f,
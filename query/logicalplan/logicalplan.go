@@ -2,7 +2,9 @@ package logicalplan
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/apache/arrow/go/v14/arrow"
@@ -35,7 +37,10 @@ type IterOptions struct {
 	Projection         []Expr
 	Filter             Expr
 	DistinctColumns    []Expr
+	MinMaxColumns      []Expr
+	PreAggregation     *Aggregation
 	InMemoryOnly       bool
+	UnifySchema        bool
 }
 
 type Option func(opts *IterOptions)
@@ -46,6 +51,15 @@ func WithInMemoryOnly() Option {
 	}
 }
 
+// WithUnifiedSchema makes the scan return all records with the same schema.
+// Columns missing from a record, e.g. dynamic columns not present in all
+// parts, are padded with nulls.
+func WithUnifiedSchema() Option {
+	return func(opts *IterOptions) {
+		opts.UnifySchema = true
+	}
+}
+
 func WithPhysicalProjection(e ...Expr) Option {
 	return func(opts *IterOptions) {
 		opts.PhysicalProjection = append(opts.PhysicalProjection, e...)
@@ -58,6 +72,24 @@ func WithProjection(e ...Expr) Option {
 	}
 }
 
+// WithMinMaxColumns indicates that only the minimum and maximum values of the
+// given columns are used, so that the scan can read them from the statistics
+// of the row groups rather than their data.
+func WithMinMaxColumns(e ...Expr) Option {
+	return func(opts *IterOptions) {
+		opts.MinMaxColumns = append(opts.MinMaxColumns, e...)
+	}
+}
+
+// WithPreAggregation makes the scan return the partial results of the given
+// aggregation, read from the pre-aggregates of the blocks of the table where
+// possible, in columns named after the unaliased aggregation functions.
+func WithPreAggregation(a *Aggregation) Option {
+	return func(opts *IterOptions) {
+		opts.PreAggregation = a
+	}
+}
+
 func WithFilter(e Expr) Option {
 	return func(opts *IterOptions) {
 		opts.Filter = e
@@ -163,6 +195,60 @@ type TableReader interface {
 	) error
 	Schema() *dynparquet.Schema
 }
+
+// RowCounter is implemented by tables that can count their rows visible at a
+// transaction from the metadata of the parts they are stored in, without
+// reading the rows.
+type RowCounter interface {
+	RowCount(ctx context.Context, tx uint64, options ...Option) (int64, error)
+}
+
+// ErrSnapshotUnavailable is returned by scans as of a transaction that is
+// older than the snapshot horizon of the table.
+var ErrSnapshotUnavailable = errors.New("table can no longer be read as of the transaction")
+
+// SnapshotHorizon is implemented by tables that drop the transactions of rows
+// when compacting or persisting them, after which the rows are visible to
+// every transaction. Reads as of transactions older than the horizon would
+// see rows written after them.
+type SnapshotHorizon interface {
+	SnapshotHorizon() uint64
+}
+
+// ScanEstimator is implemented by tables that can estimate what a scan would
+// read, pruning the data that does not match the filter of the scan like the
+// scan does, without reading the rows.
+type ScanEstimator interface {
+	EstimateScan(ctx context.Context, tx uint64, options ...Option) (ScanEstimate, error)
+}
+
+// ScanEstimate is an estimate of the data a scan of a table reads.
+type ScanEstimate struct {
+	// Blocks is the number of persisted blocks the scan reads.
+	Blocks int64
+	// BlocksSkipped is the number of persisted blocks the scan skips.
+	BlocksSkipped int64
+	// RowGroups is the number of row groups, including in-memory records,
+	// the scan reads.
+	RowGroups int64
+	// RowGroupsSkipped is the number of row groups the scan skips because of
+	// their statistics or bloom filters.
+	RowGroupsSkipped int64
+	// Rows is the number of rows of the row groups the scan reads. Rows that
+	// don't match the filter of the scan are only ruled out by the scan
+	// itself, so this is an upper bound.
+	Rows int64
+	// Bytes is the compressed size of all columns of the row groups, or the
+	// size of the in-memory records, the scan reads.
+	Bytes int64
+}
+
+// PreAggregator is implemented by tables that persist pre-aggregates of their
+// blocks, bucketed by the values of the returned columns.
+type PreAggregator interface {
+	PreAggregateColumns() []string
+}
+
 type TableProvider interface {
 	GetTable(name string) (TableReader, error)
 }
@@ -182,11 +268,33 @@ type TableScan struct {
 	// Distinct describes the columns that are to be distinct.
 	Distinct []Expr
 
+	// MinMax describes the columns of which only the minimum and maximum
+	// values are used by the query.
+	MinMax []Expr
+
+	// Count are count aggregations of all the rows of the table that the
+	// scan returns the results of, counting the rows from the metadata of
+	// the table rather than reading them.
+	Count []Expr
+
+	// PreAggregation is an aggregation of the rows returned by the scan
+	// whose partial results the scan returns instead, reading them from the
+	// pre-aggregates of the table where possible.
+	PreAggregation *Aggregation
+
 	// Projection is the list of columns that are to be projected.
 	Projection []Expr
 
 	// SkipSources indicates to skip scanning the tables sources.
 	SkipSources bool
+
+	// UnifySchema indicates to return all records of the scan with the same
+	// schema.
+	UnifySchema bool
+
+	// AsOfTx is the transaction the table is read as of. If zero, the table
+	// is read as of the latest committed transaction.
+	AsOfTx uint64
 }
 
 func (scan *TableScan) String() string {
@@ -194,7 +302,39 @@ func (scan *TableScan) String() string {
 		" Table: " + scan.TableName +
 		" Projection: " + fmt.Sprint(scan.Projection) +
 		" Filter: " + fmt.Sprint(scan.Filter) +
-		" Distinct: " + fmt.Sprint(scan.Distinct)
+		" Distinct: " + fmt.Sprint(scan.Distinct) +
+		minMaxString(scan.MinMax) +
+		countString(scan.Count) +
+		preAggregationString(scan.PreAggregation) +
+		asOfTxString(scan.AsOfTx)
+}
+
+func minMaxString(exprs []Expr) string {
+	if len(exprs) == 0 {
+		return ""
+	}
+	return " MinMax: " + fmt.Sprint(exprs)
+}
+
+func countString(exprs []Expr) string {
+	if len(exprs) == 0 {
+		return ""
+	}
+	return " Count: " + fmt.Sprint(exprs)
+}
+
+func preAggregationString(a *Aggregation) string {
+	if a == nil {
+		return ""
+	}
+	return " PreAggregation: " + fmt.Sprint(a.AggExprs) + " Group: " + fmt.Sprint(a.GroupExprs)
+}
+
+func asOfTxString(tx uint64) string {
+	if tx == 0 {
+		return ""
+	}
+	return " AsOfTx: " + strconv.FormatUint(tx, 10)
 }
 
 type SchemaScan struct {
@@ -217,10 +357,14 @@ type SchemaScan struct {
 
 	// SkipSources indicates to skip scanning the tables sources.
 	SkipSources bool
+
+	// AsOfTx is the transaction the table is read as of. If zero, the table
+	// is read as of the latest committed transaction.
+	AsOfTx uint64
 }
 
 func (s *SchemaScan) String() string {
-	return "SchemaScan"
+	return "SchemaScan" + asOfTxString(s.AsOfTx)
 }
 
 type Filter struct {
@@ -42,6 +42,10 @@ func (m *mockTableReader) SchemaIterator(
 	return nil
 }
 
+func (m *mockTableReader) RowCount(_ context.Context, _ uint64, _ ...Option) (int64, error) {
+	return 0, nil
+}
+
 type mockTableProvider struct {
 	schema *dynparquet.Schema
 }
@@ -0,0 +1,279 @@
+package logicalplan
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/scalar"
+	"github.com/parquet-go/parquet-go"
+)
+
+// Now returns an expression of the time the query is planned at, e.g. to
+// filter the rows of the last five minutes with
+// Col("timestamp").Gt(Sub(Now(), Duration(5*time.Minute))). It is bound to a
+// timestamp literal when the plan is built.
+func Now() *NowExpr {
+	return &NowExpr{}
+}
+
+type NowExpr struct{}
+
+func (n *NowExpr) Clone() Expr {
+	return &NowExpr{}
+}
+
+func (n *NowExpr) DataType(_ *parquet.Schema) (arrow.DataType, error) {
+	return arrow.PrimitiveTypes.Int64, nil
+}
+
+func (n *NowExpr) Name() string {
+	return "now()"
+}
+
+func (n *NowExpr) String() string { return n.Name() }
+
+func (n *NowExpr) Accept(visitor Visitor) bool {
+	continu := visitor.PreVisit(n)
+	if !continu {
+		return false
+	}
+
+	return visitor.PostVisit(n)
+}
+
+func (n *NowExpr) ColumnsUsedExprs() []Expr { return nil }
+
+func (n *NowExpr) MatchPath(_ string) bool { return false }
+
+func (n *NowExpr) MatchColumn(_ string) bool { return false }
+
+func (n *NowExpr) Computed() bool { return false }
+
+// Add returns the sum of a time and a duration, or of two durations.
+func Add(left, right Expr) *BinaryExpr {
+	return &BinaryExpr{Left: left, Op: OpAdd, Right: right}
+}
+
+// Sub returns the difference of a time and a duration, of two times or of two
+// durations.
+func Sub(left, right Expr) *BinaryExpr {
+	return &BinaryExpr{Left: left, Op: OpSub, Right: right}
+}
+
+// UsesNow returns whether the plan of the builder contains now(), i.e.
+// whether the plan depends on the time it is built at.
+func (b Builder) UsesNow() bool {
+	for p := b.plan; p != nil; p = p.Input {
+		var exprs []Expr
+		switch {
+		case p.Filter != nil:
+			exprs = []Expr{p.Filter.Expr}
+		case p.Projection != nil:
+			exprs = p.Projection.Exprs
+		case p.Aggregation != nil:
+			exprs = append(append(exprs, p.Aggregation.AggExprs...), p.Aggregation.GroupExprs...)
+		}
+		for _, expr := range exprs {
+			finder := newTypeFinder((*NowExpr)(nil))
+			expr.Accept(&finder)
+			if finder.result != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BindTime returns the plan with now() bound to the given time and the
+// arithmetic of times and durations in its filters, projections and
+// aggregations folded into int64 literals of milliseconds since the Unix
+// epoch, the unit of timestamp columns. Binding the time once when the query
+// is planned means that every part of the query, e.g. the fragments of a
+// distributed query, compares with the same time. The steps of the plan that
+// are bound are copied, so the plan can be bound again at a later time.
+func BindTime(plan *LogicalPlan, now time.Time) (*LogicalPlan, error) {
+	bound, err := bindTime(plan, now)
+	if err != nil {
+		return nil, err
+	}
+	return bound, nil
+}
+
+func bindTime(plan *LogicalPlan, now time.Time) (*LogicalPlan, *PlanValidationError) {
+	if plan == nil {
+		return nil, nil
+	}
+	input, inputErr := bindTime(plan.Input, now)
+	bound, err := bindPlanTime(plan, now)
+	if err != nil {
+		err.input = inputErr
+		return nil, err
+	}
+	if inputErr != nil {
+		return nil, inputErr
+	}
+	if input != plan.Input {
+		if bound == plan {
+			c := *plan
+			bound = &c
+		}
+		bound.Input = input
+	}
+	return bound, nil
+}
+
+// bindPlanTime binds the time of a single step of the plan. The step is
+// returned as is if it contains no time arithmetic.
+func bindPlanTime(plan *LogicalPlan, now time.Time) (*LogicalPlan, *PlanValidationError) {
+	var children []*ExprValidationError
+	bindAll := func(exprs []Expr) ([]Expr, bool) {
+		boundExprs := exprs
+		changed := false
+		for i, expr := range exprs {
+			b, err := bindExprTime(expr, now)
+			if err != nil {
+				children = append(children, err)
+				continue
+			}
+			if b == expr {
+				continue
+			}
+			if !changed {
+				boundExprs = append([]Expr(nil), exprs...)
+				changed = true
+			}
+			boundExprs[i] = b
+		}
+		return boundExprs, changed
+	}
+
+	bound := plan
+	switch {
+	case plan.Filter != nil:
+		if exprs, changed := bindAll([]Expr{plan.Filter.Expr}); changed {
+			c := *plan
+			c.Filter = &Filter{Expr: exprs[0]}
+			bound = &c
+		}
+	case plan.Projection != nil:
+		if exprs, changed := bindAll(plan.Projection.Exprs); changed {
+			c := *plan
+			c.Projection = &Projection{Exprs: exprs}
+			bound = &c
+		}
+	case plan.Aggregation != nil:
+		aggExprs, aggChanged := bindAll(plan.Aggregation.AggExprs)
+		groupExprs, groupChanged := bindAll(plan.Aggregation.GroupExprs)
+		if aggChanged || groupChanged {
+			c := *plan
+			c.Aggregation = &Aggregation{AggExprs: aggExprs, GroupExprs: groupExprs}
+			bound = &c
+		}
+	}
+
+	if len(children) > 0 {
+		return nil, &PlanValidationError{
+			plan:     plan,
+			message:  "invalid time arithmetic",
+			children: children,
+		}
+	}
+	return bound, nil
+}
+
+// bindExprTime returns the expression with now() and the arithmetic of times
+// and durations replaced by int64 literals. Durations that are not part of
+// arithmetic, e.g. the time buckets of an aggregation, are left as is.
+func bindExprTime(expr Expr, now time.Time) (Expr, *ExprValidationError) {
+	switch e := expr.(type) {
+	case *NowExpr:
+		return Timestamp(now), nil
+	case *BinaryExpr:
+		if e.Op == OpAdd || e.Op == OpSub {
+			v, _, err := evalTime(e, now)
+			if err != nil {
+				return nil, &ExprValidationError{
+					message: err.Error(),
+					expr:    e,
+				}
+			}
+			return Int(v), nil
+		}
+		left, err := bindExprTime(e.Left, now)
+		if err != nil {
+			return nil, err
+		}
+		right, err := bindExprTime(e.Right, now)
+		if err != nil {
+			return nil, err
+		}
+		if left == e.Left && right == e.Right {
+			return e, nil
+		}
+		return &BinaryExpr{Left: left, Op: e.Op, Right: right}, nil
+	case *AliasExpr:
+		inner, err := bindExprTime(e.Expr, now)
+		if err != nil {
+			return nil, err
+		}
+		if inner == e.Expr {
+			return e, nil
+		}
+		return &AliasExpr{Expr: inner, Alias: e.Alias}, nil
+	case *AggregationFunction:
+		inner, err := bindExprTime(e.Expr, now)
+		if err != nil {
+			return nil, err
+		}
+		if inner == e.Expr {
+			return e, nil
+		}
+		return &AggregationFunction{Func: e.Func, Expr: inner}, nil
+	default:
+		return expr, nil
+	}
+}
+
+// evalTime evaluates time arithmetic to milliseconds and reports whether the
+// result is a duration rather than a time. Int literals are times in
+// milliseconds since the Unix epoch.
+func evalTime(expr Expr, now time.Time) (int64, bool, error) {
+	switch e := expr.(type) {
+	case *NowExpr:
+		return now.UnixMilli(), false, nil
+	case *DurationExpr:
+		return e.Value().Milliseconds(), true, nil
+	case *LiteralExpr:
+		v, ok := e.Value.(*scalar.Int64)
+		if !ok || !v.IsValid() {
+			return 0, false, fmt.Errorf("unsupported operand of time arithmetic: %s", e)
+		}
+		return v.Value, false, nil
+	case *BinaryExpr:
+		if e.Op != OpAdd && e.Op != OpSub {
+			return 0, false, fmt.Errorf("unsupported operand of time arithmetic: %s", e)
+		}
+		left, leftDuration, err := evalTime(e.Left, now)
+		if err != nil {
+			return 0, false, err
+		}
+		right, rightDuration, err := evalTime(e.Right, now)
+		if err != nil {
+			return 0, false, err
+		}
+		if e.Op == OpAdd {
+			if !leftDuration && !rightDuration {
+				return 0, false, errors.New("cannot add two times")
+			}
+			return left + right, leftDuration && rightDuration, nil
+		}
+		if leftDuration && !rightDuration {
+			return 0, false, errors.New("cannot subtract a time from a duration")
+		}
+		return left - right, leftDuration == rightDuration, nil
+	default:
+		return 0, false, fmt.Errorf("unsupported operand of time arithmetic: %s", e)
+	}
+}
@@ -0,0 +1,93 @@
+package logicalplan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+)
+
+func TestBindTime(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	builder := (&Builder{}).
+		Scan(&mockTableProvider{dynparquet.NewSampleSchema()}, "table1").
+		Filter(And(
+			Col("timestamp").GtEq(Sub(Now(), Duration(5*time.Minute))),
+			Col("timestamp").Lt(Add(Sub(Now(), Duration(time.Minute)), Duration(30*time.Second))),
+		)).
+		Aggregate(
+			[]Expr{Sum(Col("value"))},
+			[]Expr{Duration(time.Second)},
+		)
+
+	plan, err := builder.BuildAt(now)
+	require.NoError(t, err)
+	and := plan.Input.Filter.Expr.(*BinaryExpr)
+	require.Equal(t, Int(700_000), and.Left.(*BinaryExpr).Right)
+	require.Equal(t, Int(970_000), and.Right.(*BinaryExpr).Right)
+	// Durations that are not part of time arithmetic are left as is.
+	require.Equal(t, Duration(time.Second), plan.Aggregation.GroupExprs[0])
+
+	// The plan of the builder is not modified, so it is bound to the time it
+	// is built at every time.
+	plan, err = builder.BuildAt(now.Add(time.Minute))
+	require.NoError(t, err)
+	and = plan.Input.Filter.Expr.(*BinaryExpr)
+	require.Equal(t, Int(760_000), and.Left.(*BinaryExpr).Right)
+}
+
+func TestUsesNow(t *testing.T) {
+	scan := (&Builder{}).Scan(&mockTableProvider{dynparquet.NewSampleSchema()}, "table1")
+	require.False(t, scan.Filter(Col("timestamp").Gt(Int(1))).UsesNow())
+	require.True(t, scan.Filter(Col("timestamp").Gt(Sub(Now(), Duration(time.Minute)))).UsesNow())
+	require.True(t, scan.Project(Sub(Now(), Duration(time.Minute)).Alias("start")).Filter(Col("value").Gt(Int(1))).UsesNow())
+}
+
+func TestBindTimeIntervals(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	for _, tc := range []struct {
+		name string
+		expr Expr
+		want int64
+	}{
+		{name: "now", expr: Now(), want: 1_000_000},
+		{name: "time minus duration", expr: Sub(Now(), Duration(time.Second)), want: 999_000},
+		{name: "duration plus time", expr: Add(Duration(time.Second), Now()), want: 1_001_000},
+		{name: "literal time", expr: Sub(Int(5_000), Duration(time.Second)), want: 4_000},
+		{name: "time minus time", expr: Sub(Now(), Int(400_000)), want: 600_000},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			plan, err := (&Builder{}).
+				Scan(&mockTableProvider{dynparquet.NewSampleSchema()}, "table1").
+				Filter(Col("timestamp").Gt(tc.expr)).
+				BuildAt(now)
+			require.NoError(t, err)
+			require.Equal(t, Int(tc.want), plan.Filter.Expr.(*BinaryExpr).Right)
+		})
+	}
+}
+
+func TestBindTimeInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		expr Expr
+		err  string
+	}{
+		{name: "add times", expr: Add(Now(), Now()), err: "cannot add two times"},
+		{name: "duration minus time", expr: Sub(Duration(time.Second), Now()), err: "cannot subtract a time from a duration"},
+		{name: "string operand", expr: Sub(Now(), String("5m")), err: "unsupported operand of time arithmetic"},
+		{name: "column operand", expr: Sub(Col("timestamp"), Duration(time.Second)), err: "unsupported operand of time arithmetic"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := (&Builder{}).
+				Scan(&mockTableProvider{dynparquet.NewSampleSchema()}, "table1").
+				Filter(Col("timestamp").Gt(tc.expr)).
+				Build()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid time arithmetic")
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
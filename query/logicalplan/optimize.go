@@ -18,6 +18,9 @@ func DefaultOptimizers() []Optimizer {
 		},
 		&FilterPushDown{},
 		&DistinctPushDown{},
+		&MinMaxPushDown{},
+		&CountPushDown{},
+		&PreAggregationPushDown{},
 		&ProjectionPushDown{},
 	}
 }
@@ -337,3 +340,232 @@ func (p *DistinctPushDown) optimize(plan *LogicalPlan, distinctColumns []Expr) {
 		p.optimize(plan.Input, distinctColumns)
 	}
 }
+
+// MinMaxPushDown optimizer pushes the columns of aggregations that only
+// compute minimums and maximums, without grouping, down to the table scan.
+// The storage engine can then read the minimum and maximum values of row
+// groups every row of which matches the filter from their statistics, rather
+// than decoding their data. It modifies the plan in place.
+type MinMaxPushDown struct{}
+
+func (p *MinMaxPushDown) Optimize(plan *LogicalPlan) *LogicalPlan {
+	for cur := plan; cur != nil; cur = cur.Input {
+		if cur.Aggregation == nil {
+			continue
+		}
+		columns, ok := minMaxColumns(cur.Aggregation)
+		if !ok {
+			return plan
+		}
+		// Only filters may be applied between the scan and the aggregation,
+		// as they are applied to the minimum and maximum values as well.
+		for input := cur.Input; input != nil; input = input.Input {
+			switch {
+			case input.Filter != nil:
+			case input.TableScan != nil:
+				input.TableScan.MinMax = columns
+			default:
+				return plan
+			}
+		}
+		return plan
+	}
+	return plan
+}
+
+// minMaxColumns returns the columns aggregated by the aggregation if it only
+// computes the minimums and maximums of columns without grouping.
+func minMaxColumns(aggregation *Aggregation) ([]Expr, bool) {
+	if len(aggregation.GroupExprs) > 0 {
+		return nil, false
+	}
+	columns := make([]Expr, 0, len(aggregation.AggExprs))
+	for _, aggExpr := range aggregation.AggExprs {
+		if alias, ok := aggExpr.(*AliasExpr); ok {
+			aggExpr = alias.Expr
+		}
+		aggFunc, ok := aggExpr.(*AggregationFunction)
+		if !ok || (aggFunc.Func != AggFuncMin && aggFunc.Func != AggFuncMax) {
+			return nil, false
+		}
+		column, ok := aggFunc.Expr.(*Column)
+		if !ok {
+			return nil, false
+		}
+		columns = append(columns, column)
+	}
+	return columns, len(columns) > 0
+}
+
+// CountPushDown optimizer replaces aggregations that only count all the rows
+// of a table, without filtering or grouping, by a table scan that counts the
+// rows from the metadata of the table if the table supports it, rather than
+// reading and counting every row.
+type CountPushDown struct{}
+
+func (p *CountPushDown) Optimize(plan *LogicalPlan) *LogicalPlan {
+	var parent *LogicalPlan
+	for cur := plan; cur != nil; parent, cur = cur, cur.Input {
+		if cur.Aggregation == nil {
+			continue
+		}
+		scan := cur.Input
+		if scan == nil || scan.TableScan == nil || scan.TableScan.Filter != nil ||
+			!countsRows(scan.TableScan, cur.Aggregation) {
+			return plan
+		}
+		scan.TableScan.Count = cur.Aggregation.AggExprs
+		if parent == nil {
+			return scan
+		}
+		parent.Input = scan
+		return plan
+	}
+	return plan
+}
+
+// countsRows returns true if the aggregation only counts the rows of the table
+// scanned by the scan, and the table can count its rows from its metadata.
+func countsRows(scan *TableScan, aggregation *Aggregation) bool {
+	if len(aggregation.GroupExprs) > 0 || len(aggregation.AggExprs) == 0 || scan.TableProvider == nil {
+		return false
+	}
+	table, err := scan.TableProvider.GetTable(scan.TableName)
+	if err != nil || table == nil {
+		return false
+	}
+	if _, ok := table.(RowCounter); !ok {
+		return false
+	}
+	schema := table.Schema()
+	for _, aggExpr := range aggregation.AggExprs {
+		if alias, ok := aggExpr.(*AliasExpr); ok {
+			aggExpr = alias.Expr
+		}
+		aggFunc, ok := aggExpr.(*AggregationFunction)
+		if !ok || aggFunc.Func != AggFuncCount {
+			return false
+		}
+		column, ok := aggFunc.Expr.(*Column)
+		if !ok {
+			return false
+		}
+		// Every row has a value, possibly null, for the concrete columns of
+		// the schema, whereas dynamic columns are only counted in the parts
+		// they are stored in.
+		if definition, found := schema.ColumnByName(column.ColumnName); !found || definition.Dynamic {
+			return false
+		}
+	}
+	return true
+}
+
+// PreAggregationPushDown optimizer pushes aggregations that only compute sums,
+// counts, minimums and maximums of columns, grouped by columns that the table
+// pre-aggregates its blocks by, down to the table scan. The scan returns the
+// partial results of the aggregation instead of the rows, read from the
+// pre-aggregates of the blocks where possible, and the aggregation is
+// rewritten to merge the partial results. It modifies the plan in place.
+type PreAggregationPushDown struct{}
+
+func (p *PreAggregationPushDown) Optimize(plan *LogicalPlan) *LogicalPlan {
+	for cur := plan; cur != nil; cur = cur.Input {
+		if cur.Aggregation == nil {
+			continue
+		}
+		// Only filters may be applied between the scan and the aggregation,
+		// as they are applied to the partial results as well.
+		var scan *TableScan
+		for input := cur.Input; input != nil; input = input.Input {
+			switch {
+			case input.Filter != nil:
+			case input.TableScan != nil:
+				scan = input.TableScan
+			default:
+				return plan
+			}
+		}
+		if scan == nil || len(scan.Distinct) > 0 || len(scan.MinMax) > 0 || !preAggregates(scan, cur.Aggregation) {
+			return plan
+		}
+
+		scan.PreAggregation = cur.Aggregation
+		aggExprs := make([]Expr, 0, len(cur.Aggregation.AggExprs))
+		for _, aggExpr := range cur.Aggregation.AggExprs {
+			aggFunc := unaliasAggregation(aggExpr)
+			// The partial results are in columns named after the
+			// aggregation functions. Partial counts are summed up.
+			partial := Col(aggFunc.Name())
+			merge := &AggregationFunction{Func: aggFunc.Func, Expr: partial}
+			if aggFunc.Func == AggFuncCount {
+				merge.Func = AggFuncSum
+			}
+			aggExprs = append(aggExprs, merge.Alias(aggExpr.Name()))
+			if len(scan.PhysicalProjection) > 0 {
+				scan.PhysicalProjection = append(scan.PhysicalProjection[:len(scan.PhysicalProjection):len(scan.PhysicalProjection)], partial)
+			}
+		}
+		cur.Aggregation = &Aggregation{
+			AggExprs:   aggExprs,
+			GroupExprs: cur.Aggregation.GroupExprs,
+		}
+		return plan
+	}
+	return plan
+}
+
+// preAggregates returns true if the table scanned by the scan pre-aggregates
+// its blocks and the aggregation can be computed from its pre-aggregates.
+func preAggregates(scan *TableScan, aggregation *Aggregation) bool {
+	if len(aggregation.AggExprs) == 0 || scan.TableProvider == nil {
+		return false
+	}
+	table, err := scan.TableProvider.GetTable(scan.TableName)
+	if err != nil || table == nil {
+		return false
+	}
+	preAggregator, ok := table.(PreAggregator)
+	if !ok {
+		return false
+	}
+	columns := preAggregator.PreAggregateColumns()
+	if len(columns) == 0 {
+		return false
+	}
+	for _, groupExpr := range aggregation.GroupExprs {
+		column, ok := groupExpr.(*Column)
+		if !ok || !slices.Contains(columns, column.ColumnName) {
+			return false
+		}
+	}
+	schema := table.Schema()
+	for _, aggExpr := range aggregation.AggExprs {
+		aggFunc := unaliasAggregation(aggExpr)
+		if aggFunc == nil {
+			return false
+		}
+		switch aggFunc.Func {
+		case AggFuncSum, AggFuncMin, AggFuncMax, AggFuncCount:
+		default:
+			return false
+		}
+		column, ok := aggFunc.Expr.(*Column)
+		if !ok {
+			return false
+		}
+		if definition, found := schema.ColumnByName(column.ColumnName); !found || definition.Dynamic {
+			return false
+		}
+	}
+	return true
+}
+
+// unaliasAggregation returns the aggregation function of the expression,
+// which may be aliased, or nil if it isn't an aggregation function.
+func unaliasAggregation(expr Expr) *AggregationFunction {
+	if alias, ok := expr.(*AliasExpr); ok {
+		expr = alias.Expr
+	}
+	aggFunc, _ := expr.(*AggregationFunction)
+	return aggFunc
+}
@@ -64,6 +64,211 @@ func TestOptimizeDistinctPushDown(t *testing.T) {
 	)
 }
 
+func TestOptimizeMinMaxPushDown(t *testing.T) {
+	tableProvider := &mockTableProvider{schema: dynparquet.NewSampleSchema()}
+	for _, tc := range []struct {
+		name    string
+		builder Builder
+		minMax  []Expr
+	}{
+		{
+			name: "min_max",
+			builder: (&Builder{}).
+				Scan(tableProvider, "table1").
+				Filter(Col("timestamp").Gt(Literal(int64(1)))).
+				Aggregate(
+					[]Expr{Max(Col("timestamp")), Min(Col("value")).Alias("min_value")},
+					nil,
+				).
+				Project(Col("min_value")),
+			minMax: []Expr{Col("timestamp"), Col("value")},
+		},
+		{
+			name: "group_by",
+			builder: (&Builder{}).
+				Scan(tableProvider, "table1").
+				Aggregate([]Expr{Max(Col("timestamp"))}, []Expr{Col("stacktrace")}),
+		},
+		{
+			name: "sum",
+			builder: (&Builder{}).
+				Scan(tableProvider, "table1").
+				Aggregate([]Expr{Max(Col("timestamp")), Sum(Col("value"))}, nil),
+		},
+		{
+			name: "distinct",
+			builder: (&Builder{}).
+				Scan(tableProvider, "table1").
+				Distinct(Col("value")).
+				Aggregate([]Expr{Max(Col("value"))}, nil),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := tc.builder.Build()
+			require.NoError(t, err)
+			p = (&MinMaxPushDown{}).Optimize(p)
+
+			scan := p
+			for scan.Input != nil {
+				scan = scan.Input
+			}
+			require.Equal(t, tc.minMax, scan.TableScan.MinMax)
+		})
+	}
+}
+
+func TestOptimizeCountPushDown(t *testing.T) {
+	tableProvider := &mockTableProvider{schema: dynparquet.NewSampleSchema()}
+	for _, tc := range []struct {
+		name    string
+		builder Builder
+		count   []Expr
+	}{
+		{
+			name: "count",
+			builder: (&Builder{}).
+				Scan(tableProvider, "table1").
+				Aggregate([]Expr{Count(Col("value")).Alias("count"), Count(Col("timestamp"))}, nil).
+				Project(Col("count")),
+			count: []Expr{Count(Col("value")).Alias("count"), Count(Col("timestamp"))},
+		},
+		{
+			name: "filter",
+			builder: (&Builder{}).
+				Scan(tableProvider, "table1").
+				Filter(Col("value").Gt(Literal(int64(1)))).
+				Aggregate([]Expr{Count(Col("value"))}, nil),
+		},
+		{
+			name: "group_by",
+			builder: (&Builder{}).
+				Scan(tableProvider, "table1").
+				Aggregate([]Expr{Count(Col("value"))}, []Expr{Col("stacktrace")}),
+		},
+		{
+			name: "dynamic_column",
+			builder: (&Builder{}).
+				Scan(tableProvider, "table1").
+				Aggregate([]Expr{Count(Col("labels.test"))}, nil),
+		},
+		{
+			name: "sum",
+			builder: (&Builder{}).
+				Scan(tableProvider, "table1").
+				Aggregate([]Expr{Count(Col("value")), Sum(Col("value"))}, nil),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := tc.builder.Build()
+			require.NoError(t, err)
+			p = (&CountPushDown{}).Optimize(p)
+
+			aggregated := false
+			scan := p
+			for scan.Input != nil {
+				aggregated = aggregated || scan.Aggregation != nil
+				scan = scan.Input
+			}
+			// The aggregation is replaced by counting the rows in the scan.
+			require.Equal(t, tc.count == nil, aggregated)
+			require.Equal(t, tc.count, scan.TableScan.Count)
+		})
+	}
+}
+
+type mockPreAggregatingTableReader struct {
+	mockTableReader
+	columns []string
+}
+
+func (m *mockPreAggregatingTableReader) PreAggregateColumns() []string {
+	return m.columns
+}
+
+type mockPreAggregatingTableProvider struct {
+	schema  *dynparquet.Schema
+	columns []string
+}
+
+func (m *mockPreAggregatingTableProvider) GetTable(_ string) (TableReader, error) {
+	return &mockPreAggregatingTableReader{
+		mockTableReader: mockTableReader{schema: m.schema},
+		columns:         m.columns,
+	}, nil
+}
+
+func TestOptimizePreAggregationPushDown(t *testing.T) {
+	tableProvider := &mockPreAggregatingTableProvider{
+		schema:  dynparquet.NewSampleSchema(),
+		columns: []string{"example_type"},
+	}
+	for _, tc := range []struct {
+		name          string
+		builder       Builder
+		preAggregated bool
+	}{
+		{
+			name: "group_by_key",
+			builder: (&Builder{}).
+				Scan(tableProvider, "table1").
+				Filter(Col("timestamp").Gt(Literal(int64(1)))).
+				Aggregate(
+					[]Expr{Sum(Col("value")), Count(Col("value")).Alias("count")},
+					[]Expr{Col("example_type")},
+				),
+			preAggregated: true,
+		},
+		{
+			name: "group_by_other_column",
+			builder: (&Builder{}).
+				Scan(tableProvider, "table1").
+				Aggregate([]Expr{Sum(Col("value"))}, []Expr{Col("stacktrace")}),
+		},
+		{
+			name: "unsupported_function",
+			builder: (&Builder{}).
+				Scan(tableProvider, "table1").
+				Aggregate([]Expr{Avg(Col("value"))}, []Expr{Col("example_type")}),
+		},
+		{
+			name: "dynamic_column",
+			builder: (&Builder{}).
+				Scan(tableProvider, "table1").
+				Aggregate([]Expr{Count(Col("labels.test"))}, []Expr{Col("example_type")}),
+		},
+		{
+			name: "no_pre_aggregates",
+			builder: (&Builder{}).
+				Scan(&mockTableProvider{schema: dynparquet.NewSampleSchema()}, "table1").
+				Aggregate([]Expr{Sum(Col("value"))}, []Expr{Col("example_type")}),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := tc.builder.Build()
+			require.NoError(t, err)
+			original := p.Aggregation
+			p = (&PreAggregationPushDown{}).Optimize(p)
+
+			scan := p
+			for scan.Input != nil {
+				scan = scan.Input
+			}
+			if !tc.preAggregated {
+				require.Nil(t, scan.TableScan.PreAggregation)
+				require.Equal(t, original, p.Aggregation)
+				return
+			}
+			require.Equal(t, original, scan.TableScan.PreAggregation)
+			// The aggregation merges the partial results of the scan.
+			require.Equal(t, []Expr{
+				Sum(Col("sum(value)")).Alias("sum(value)"),
+				Sum(Col("count(value)")).Alias("count"),
+			}, p.Aggregation.AggExprs)
+			require.Equal(t, original.GroupExprs, p.Aggregation.GroupExprs)
+		})
+	}
+}
+
 func TestOptimizeFilterPushDown(t *testing.T) {
 	tableProvider := &mockTableProvider{schema: dynparquet.NewSampleSchema()}
 	p, _ := (&Builder{}).
@@ -0,0 +1,427 @@
+package logicalplan
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/arrow/scalar"
+
+	pb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/logicalplan/v1alpha1"
+)
+
+// ToProto converts the plan to its protobuf representation, so it can be
+// executed by another process. The table providers of the scans of the plan
+// are not part of the representation.
+func ToProto(plan *LogicalPlan) (*pb.Plan, error) {
+	if plan == nil {
+		return nil, nil
+	}
+
+	input, err := ToProto(plan.Input)
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.Plan{Input: input}
+
+	switch {
+	case plan.TableScan != nil:
+		scan := plan.TableScan
+		s := &pb.TableScan{
+			TableName:   scan.TableName,
+			SkipSources: scan.SkipSources,
+			AsOfTx:      scan.AsOfTx,
+		}
+		if s.PhysicalProjection, err = exprsToProto(scan.PhysicalProjection); err != nil {
+			return nil, err
+		}
+		if s.Filter, err = ExprToProto(scan.Filter); err != nil {
+			return nil, err
+		}
+		if s.Distinct, err = exprsToProto(scan.Distinct); err != nil {
+			return nil, err
+		}
+		if s.Projection, err = exprsToProto(scan.Projection); err != nil {
+			return nil, err
+		}
+		res.Operator = &pb.Plan_TableScan{TableScan: s}
+	case plan.SchemaScan != nil:
+		scan := plan.SchemaScan
+		s := &pb.SchemaScan{
+			TableName:   scan.TableName,
+			SkipSources: scan.SkipSources,
+			AsOfTx:      scan.AsOfTx,
+		}
+		if s.PhysicalProjection, err = exprsToProto(scan.PhysicalProjection); err != nil {
+			return nil, err
+		}
+		if s.Filter, err = ExprToProto(scan.Filter); err != nil {
+			return nil, err
+		}
+		if s.Distinct, err = exprsToProto(scan.Distinct); err != nil {
+			return nil, err
+		}
+		if s.Projection, err = exprsToProto(scan.Projection); err != nil {
+			return nil, err
+		}
+		res.Operator = &pb.Plan_SchemaScan{SchemaScan: s}
+	case plan.Filter != nil:
+		expr, err := ExprToProto(plan.Filter.Expr)
+		if err != nil {
+			return nil, err
+		}
+		res.Operator = &pb.Plan_Filter{Filter: &pb.Filter{Expr: expr}}
+	case plan.Distinct != nil:
+		exprs, err := exprsToProto(plan.Distinct.Exprs)
+		if err != nil {
+			return nil, err
+		}
+		res.Operator = &pb.Plan_Distinct{Distinct: &pb.Distinct{Exprs: exprs}}
+	case plan.Projection != nil:
+		exprs, err := exprsToProto(plan.Projection.Exprs)
+		if err != nil {
+			return nil, err
+		}
+		res.Operator = &pb.Plan_Projection{Projection: &pb.Projection{Exprs: exprs}}
+	case plan.Aggregation != nil:
+		aggExprs, err := exprsToProto(plan.Aggregation.AggExprs)
+		if err != nil {
+			return nil, err
+		}
+		groupExprs, err := exprsToProto(plan.Aggregation.GroupExprs)
+		if err != nil {
+			return nil, err
+		}
+		res.Operator = &pb.Plan_Aggregation{Aggregation: &pb.Aggregation{
+			AggExprs:   aggExprs,
+			GroupExprs: groupExprs,
+		}}
+	default:
+		return nil, fmt.Errorf("plan node without operator: %s", plan)
+	}
+	return res, nil
+}
+
+// FromProto converts the protobuf representation of a plan to a plan whose
+// scans read the tables of the given table provider.
+func FromProto(plan *pb.Plan, provider TableProvider) (*LogicalPlan, error) {
+	if plan == nil {
+		return nil, nil
+	}
+
+	input, err := FromProto(plan.Input, provider)
+	if err != nil {
+		return nil, err
+	}
+	res := &LogicalPlan{Input: input}
+
+	switch op := plan.Operator.(type) {
+	case *pb.Plan_TableScan:
+		scan := op.TableScan
+		s := &TableScan{
+			TableProvider: provider,
+			TableName:     scan.TableName,
+			SkipSources:   scan.SkipSources,
+			AsOfTx:        scan.AsOfTx,
+		}
+		if s.PhysicalProjection, err = exprsFromProto(scan.PhysicalProjection); err != nil {
+			return nil, err
+		}
+		if s.Filter, err = optionalExprFromProto(scan.Filter); err != nil {
+			return nil, err
+		}
+		if s.Distinct, err = exprsFromProto(scan.Distinct); err != nil {
+			return nil, err
+		}
+		if s.Projection, err = exprsFromProto(scan.Projection); err != nil {
+			return nil, err
+		}
+		res.TableScan = s
+	case *pb.Plan_SchemaScan:
+		scan := op.SchemaScan
+		s := &SchemaScan{
+			TableProvider: provider,
+			TableName:     scan.TableName,
+			SkipSources:   scan.SkipSources,
+			AsOfTx:        scan.AsOfTx,
+		}
+		if s.PhysicalProjection, err = exprsFromProto(scan.PhysicalProjection); err != nil {
+			return nil, err
+		}
+		if s.Filter, err = optionalExprFromProto(scan.Filter); err != nil {
+			return nil, err
+		}
+		if s.Distinct, err = exprsFromProto(scan.Distinct); err != nil {
+			return nil, err
+		}
+		if s.Projection, err = exprsFromProto(scan.Projection); err != nil {
+			return nil, err
+		}
+		res.SchemaScan = s
+	case *pb.Plan_Filter:
+		expr, err := ExprFromProto(op.Filter.Expr)
+		if err != nil {
+			return nil, err
+		}
+		res.Filter = &Filter{Expr: NormalizeFilterExpr(expr)}
+	case *pb.Plan_Distinct:
+		exprs, err := exprsFromProto(op.Distinct.Exprs)
+		if err != nil {
+			return nil, err
+		}
+		res.Distinct = &Distinct{Exprs: exprs}
+	case *pb.Plan_Projection:
+		exprs, err := exprsFromProto(op.Projection.Exprs)
+		if err != nil {
+			return nil, err
+		}
+		res.Projection = &Projection{Exprs: exprs}
+	case *pb.Plan_Aggregation:
+		aggExprs, err := exprsFromProto(op.Aggregation.AggExprs)
+		if err != nil {
+			return nil, err
+		}
+		groupExprs, err := exprsFromProto(op.Aggregation.GroupExprs)
+		if err != nil {
+			return nil, err
+		}
+		res.Aggregation = &Aggregation{
+			AggExprs:   aggExprs,
+			GroupExprs: groupExprs,
+		}
+	default:
+		return nil, errors.New("plan node without operator")
+	}
+	return res, nil
+}
+
+func exprsToProto(exprs []Expr) ([]*pb.Expr, error) {
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+	res := make([]*pb.Expr, 0, len(exprs))
+	for _, expr := range exprs {
+		e, err := ExprToProto(expr)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// ExprToProto converts the expression to its protobuf representation.
+func ExprToProto(expr Expr) (*pb.Expr, error) {
+	if expr == nil {
+		return nil, nil
+	}
+
+	switch e := expr.(type) {
+	case *Column:
+		return &pb.Expr{Expr: &pb.Expr_Column{Column: &pb.Column{Name: e.ColumnName}}}, nil
+	case *DynamicColumn:
+		return &pb.Expr{Expr: &pb.Expr_DynamicColumn{DynamicColumn: &pb.DynamicColumn{Name: e.ColumnName}}}, nil
+	case *BinaryExpr:
+		left, err := ExprToProto(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := ExprToProto(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.Expr{Expr: &pb.Expr_Binary{Binary: &pb.BinaryExpr{
+			Left:  left,
+			Op:    pb.Op(e.Op),
+			Right: right,
+		}}}, nil
+	case *LiteralExpr:
+		literal, err := literalToProto(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.Expr{Expr: &pb.Expr_Literal{Literal: literal}}, nil
+	case *AliasExpr:
+		inner, err := ExprToProto(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.Expr{Expr: &pb.Expr_Alias{Alias: &pb.AliasExpr{Expr: inner, Alias: e.Alias}}}, nil
+	case *AggregationFunction:
+		inner, err := ExprToProto(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+		var orderBy *pb.Expr
+		if e.OrderByExpr != nil {
+			if orderBy, err = ExprToProto(e.OrderByExpr); err != nil {
+				return nil, err
+			}
+		}
+		return &pb.Expr{Expr: &pb.Expr_AggregationFunction{AggregationFunction: &pb.AggregationFunction{
+			Func:       pb.AggFunc(e.Func),
+			Expr:       inner,
+			Boundaries: e.Boundaries,
+			OrderBy:    orderBy,
+		}}}, nil
+	case *DurationExpr:
+		return &pb.Expr{Expr: &pb.Expr_Duration{Duration: &pb.DurationExpr{Duration: int64(e.duration)}}}, nil
+	case *AverageExpr:
+		inner, err := ExprToProto(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.Expr{Expr: &pb.Expr_Average{Average: &pb.AverageExpr{Expr: inner}}}, nil
+	case *RegexpColumnMatch:
+		return &pb.Expr{Expr: &pb.Expr_RegexpColumnMatch{RegexpColumnMatch: &pb.RegexpColumnMatch{
+			Pattern: e.match.String(),
+			Inverse: e.inverse,
+		}}}, nil
+	case *AllExpr:
+		return &pb.Expr{Expr: &pb.Expr_All{All: &pb.AllExpr{}}}, nil
+	case *NotExpr:
+		inner, err := ExprToProto(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.Expr{Expr: &pb.Expr_Not{Not: &pb.NotExpr{Expr: inner}}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported expression %T: %s", expr, expr)
+	}
+}
+
+func literalToProto(s scalar.Scalar) (*pb.Literal, error) {
+	if !s.IsValid() {
+		return &pb.Literal{}, nil
+	}
+	switch v := s.(type) {
+	case *scalar.Boolean:
+		return &pb.Literal{Value: &pb.Literal_BoolValue{BoolValue: v.Value}}, nil
+	case *scalar.Int64:
+		return &pb.Literal{Value: &pb.Literal_Int64Value{Int64Value: v.Value}}, nil
+	case *scalar.Float64:
+		return &pb.Literal{Value: &pb.Literal_Float64Value{Float64Value: v.Value}}, nil
+	case *scalar.String:
+		return &pb.Literal{Value: &pb.Literal_StringValue{StringValue: string(v.Data())}}, nil
+	case *scalar.Binary:
+		return &pb.Literal{Value: &pb.Literal_BinaryValue{BinaryValue: v.Data()}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported literal type %s", s.DataType())
+	}
+}
+
+func exprsFromProto(exprs []*pb.Expr) ([]Expr, error) {
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+	res := make([]Expr, 0, len(exprs))
+	for _, expr := range exprs {
+		e, err := ExprFromProto(expr)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func optionalExprFromProto(expr *pb.Expr) (Expr, error) {
+	if expr == nil {
+		return nil, nil
+	}
+	return ExprFromProto(expr)
+}
+
+// ExprFromProto converts the protobuf representation of an expression to an
+// expression.
+func ExprFromProto(expr *pb.Expr) (Expr, error) {
+	if expr == nil {
+		return nil, errors.New("missing expression")
+	}
+
+	switch e := expr.Expr.(type) {
+	case *pb.Expr_Column:
+		return Col(e.Column.Name), nil
+	case *pb.Expr_DynamicColumn:
+		return DynCol(e.DynamicColumn.Name), nil
+	case *pb.Expr_Binary:
+		left, err := ExprFromProto(e.Binary.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := ExprFromProto(e.Binary.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryExpr{Left: left, Op: Op(e.Binary.Op), Right: right}, nil
+	case *pb.Expr_Literal:
+		return &LiteralExpr{Value: literalFromProto(e.Literal)}, nil
+	case *pb.Expr_Alias:
+		inner, err := ExprFromProto(e.Alias.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &AliasExpr{Expr: inner, Alias: e.Alias.Alias}, nil
+	case *pb.Expr_AggregationFunction:
+		inner, err := ExprFromProto(e.AggregationFunction.Expr)
+		if err != nil {
+			return nil, err
+		}
+		var orderBy Expr
+		if e.AggregationFunction.OrderBy != nil {
+			if orderBy, err = ExprFromProto(e.AggregationFunction.OrderBy); err != nil {
+				return nil, err
+			}
+		}
+		return &AggregationFunction{
+			Func:        AggFunc(e.AggregationFunction.Func),
+			Expr:        inner,
+			Boundaries:  e.AggregationFunction.Boundaries,
+			OrderByExpr: orderBy,
+		}, nil
+	case *pb.Expr_Duration:
+		return Duration(time.Duration(e.Duration.Duration)), nil
+	case *pb.Expr_Average:
+		inner, err := ExprFromProto(e.Average.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &AverageExpr{Expr: inner}, nil
+	case *pb.Expr_RegexpColumnMatch:
+		match, err := regexp.Compile(e.RegexpColumnMatch.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile column regexp: %w", err)
+		}
+		return &RegexpColumnMatch{match: match, inverse: e.RegexpColumnMatch.Inverse}, nil
+	case *pb.Expr_All:
+		return All(), nil
+	case *pb.Expr_Not:
+		inner, err := ExprFromProto(e.Not.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return Not(inner), nil
+	default:
+		return nil, errors.New("expression without value")
+	}
+}
+
+func literalFromProto(literal *pb.Literal) scalar.Scalar {
+	switch v := literal.GetValue().(type) {
+	case *pb.Literal_BoolValue:
+		return scalar.NewBooleanScalar(v.BoolValue)
+	case *pb.Literal_Int64Value:
+		return scalar.NewInt64Scalar(v.Int64Value)
+	case *pb.Literal_Float64Value:
+		return scalar.NewFloat64Scalar(v.Float64Value)
+	case *pb.Literal_StringValue:
+		return scalar.NewStringScalar(v.StringValue)
+	case *pb.Literal_BinaryValue:
+		return scalar.NewBinaryScalar(memory.NewBufferBytes(v.BinaryValue), arrow.BinaryTypes.Binary)
+	default:
+		return scalar.ScalarNull
+	}
+}
@@ -0,0 +1,49 @@
+package logicalplan
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow/scalar"
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	pb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/logicalplan/v1alpha1"
+)
+
+func TestPlanProto(t *testing.T) {
+	tableProvider := &mockTableProvider{schema: dynparquet.NewSampleSchema()}
+	plan, err := (&Builder{}).
+		Scan(tableProvider, "table1").
+		Filter(And(
+			Col("labels.test").Eq(Literal("abc")),
+			Col("value").Gt(Literal(1.5)),
+			Col("labels.other").RegexMatch("a.*"),
+			Col("stacktrace").NotEq(&LiteralExpr{Value: scalar.ScalarNull}),
+		)).
+		Aggregate(
+			[]Expr{Sum(Col("value")).Alias("value_sum"), Avg(Col("value")), Histogram(Col("value"), 0.5, 1), Last(Col("value")), Stddev(Col("value")), AnyValue(Col("labels.label1"))},
+			[]Expr{DynCol("labels"), Duration(time.Second)},
+		).
+		Project(Not(DynCol("labels")), RegExpColumnMatch(regexp.MustCompile("^value"))).
+		AsOfTx(42).
+		Build()
+	require.NoError(t, err)
+
+	p, err := ToProto(plan)
+	require.NoError(t, err)
+	data, err := p.MarshalVT()
+	require.NoError(t, err)
+
+	decoded := &pb.Plan{}
+	require.NoError(t, decoded.UnmarshalVT(data))
+	res, err := FromProto(decoded, tableProvider)
+	require.NoError(t, err)
+	require.Equal(t, plan.String(), res.String())
+	require.Equal(t, uint64(42), res.Input.Input.Input.TableScan.AsOfTx)
+	require.Equal(t, tableProvider, res.Input.Input.Input.TableScan.TableProvider)
+
+	_, err = FromProto(&pb.Plan{}, tableProvider)
+	require.Error(t, err)
+}
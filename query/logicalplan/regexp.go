@@ -0,0 +1,194 @@
+package logicalplan
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"sync"
+)
+
+// regexpCacheSize is the number of compiled regexes kept by the regex cache.
+const regexpCacheSize = 1024
+
+// maxRegexpLiterals is the maximum number of strings a regex may match for it
+// to be matched by looking values up in the set of those strings.
+const maxRegexpLiterals = 256
+
+// regexps caches the regexes compiled for regex literals, so that they are
+// compiled once when the plan is built instead of on every query execution.
+var regexps = newRegexpCache(regexpCacheSize)
+
+// CompileRegexp compiles the pattern of a regex literal, returning the cached
+// regex if the pattern has already been compiled. Patterns that fail to
+// compile are reported with the position of the problem in the pattern, and
+// with a hint if they use a feature RE2 doesn't support.
+func CompileRegexp(pattern string) (*regexp.Regexp, error) {
+	return regexps.compile(pattern)
+}
+
+// RegexpLiterals returns the strings the pattern matches if it is anchored and
+// only matches a small set of literals, e.g. `^(foo|bar)$`, which is common for
+// the label selectors generated by UIs. Matching such regexes is equivalent to
+// looking values up in the set of the literals.
+func RegexpLiterals(pattern string) ([]string, bool) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, false
+	}
+	re = re.Simplify()
+	if re.Op != syntax.OpConcat || len(re.Sub) < 2 ||
+		re.Sub[0].Op != syntax.OpBeginText || re.Sub[len(re.Sub)-1].Op != syntax.OpEndText {
+		return nil, false
+	}
+	return regexpLiterals(&syntax.Regexp{Op: syntax.OpConcat, Sub: re.Sub[1 : len(re.Sub)-1]})
+}
+
+// regexpLiterals returns the strings the regex matches, if they are no more
+// than maxRegexpLiterals.
+func regexpLiterals(re *syntax.Regexp) ([]string, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		return []string{""}, true
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return nil, false
+		}
+		return []string{string(re.Rune)}, true
+	case syntax.OpCharClass:
+		var literals []string
+		for i := 0; i < len(re.Rune); i += 2 {
+			lo, hi := re.Rune[i], re.Rune[i+1]
+			if int(hi-lo) >= maxRegexpLiterals-len(literals) {
+				return nil, false
+			}
+			for r := lo; r <= hi; r++ {
+				literals = append(literals, string(r))
+			}
+		}
+		return literals, true
+	case syntax.OpCapture:
+		return regexpLiterals(re.Sub[0])
+	case syntax.OpAlternate:
+		var literals []string
+		for _, sub := range re.Sub {
+			subLiterals, ok := regexpLiterals(sub)
+			if !ok || len(literals)+len(subLiterals) > maxRegexpLiterals {
+				return nil, false
+			}
+			literals = append(literals, subLiterals...)
+		}
+		return literals, true
+	case syntax.OpConcat:
+		literals := []string{""}
+		for _, sub := range re.Sub {
+			subLiterals, ok := regexpLiterals(sub)
+			if !ok || len(literals)*len(subLiterals) > maxRegexpLiterals {
+				return nil, false
+			}
+			concatenated := make([]string, 0, len(literals)*len(subLiterals))
+			for _, prefix := range literals {
+				for _, suffix := range subLiterals {
+					concatenated = append(concatenated, prefix+suffix)
+				}
+			}
+			literals = concatenated
+		}
+		return literals, true
+	default:
+		return nil, false
+	}
+}
+
+// regexpCache is a cache of compiled regexes evicting the least recently used
+// regexes when it holds more than its capacity.
+type regexpCache struct {
+	capacity int
+
+	mtx     sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type regexpCacheEntry struct {
+	pattern string
+	regexp  *regexp.Regexp
+}
+
+func newRegexpCache(capacity int) *regexpCache {
+	return &regexpCache{
+		capacity: capacity,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+func (c *regexpCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.mtx.Lock()
+	if e, ok := c.entries[pattern]; ok {
+		c.lru.MoveToFront(e)
+		c.mtx.Unlock()
+		return e.Value.(*regexpCacheEntry).regexp, nil
+	}
+	c.mtx.Unlock()
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, regexpError(pattern, err)
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if e, ok := c.entries[pattern]; ok {
+		// Compiled concurrently.
+		c.lru.MoveToFront(e)
+		return e.Value.(*regexpCacheEntry).regexp, nil
+	}
+	c.entries[pattern] = c.lru.PushFront(&regexpCacheEntry{pattern: pattern, regexp: re})
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*regexpCacheEntry).pattern)
+	}
+	return re, nil
+}
+
+// regexpError annotates the error of compiling the pattern with the position
+// of the offending expression in the pattern and a hint for the features of
+// other regex engines that RE2 doesn't support.
+func regexpError(pattern string, err error) error {
+	var syntaxErr *syntax.Error
+	if !errors.As(err, &syntaxErr) {
+		return fmt.Errorf("invalid regex %q: %w", pattern, err)
+	}
+
+	msg := fmt.Sprintf("invalid regex %q", pattern)
+	if pos := strings.Index(pattern, syntaxErr.Expr); syntaxErr.Expr != "" && pos >= 0 {
+		msg += fmt.Sprintf(" at position %d", pos)
+	}
+	msg += ": " + syntaxErr.Code.String() + ": `" + syntaxErr.Expr + "`"
+	if hint := re2Hint(syntaxErr); hint != "" {
+		msg += " (" + hint + ")"
+	}
+	return errors.New(msg)
+}
+
+// re2Hint returns a hint for syntax errors caused by features of other regex
+// engines that RE2 doesn't support.
+func re2Hint(err *syntax.Error) string {
+	switch {
+	case strings.HasPrefix(err.Expr, "(?="), strings.HasPrefix(err.Expr, "(?!"),
+		strings.HasPrefix(err.Expr, "(?<="), strings.HasPrefix(err.Expr, "(?<!"):
+		return "lookaround assertions are not supported by RE2"
+	case strings.HasPrefix(err.Expr, "(?>"):
+		return "atomic groups are not supported by RE2"
+	case err.Code == syntax.ErrInvalidEscape && len(err.Expr) == 2 && err.Expr[1] >= '1' && err.Expr[1] <= '9':
+		return "backreferences are not supported by RE2"
+	case err.Code == syntax.ErrInvalidRepeatOp && len(err.Expr) == 2 && err.Expr[1] == '+':
+		return "possessive quantifiers are not supported by RE2"
+	default:
+		return ""
+	}
+}
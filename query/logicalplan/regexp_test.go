@@ -0,0 +1,104 @@
+package logicalplan
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+)
+
+func TestCompileRegexpErrors(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		err     string
+	}{
+		{pattern: "ab(c", err: "invalid regex \"ab(c\" at position 0: missing closing ): `ab(c`"},
+		{pattern: "a[z-a]", err: "invalid regex \"a[z-a]\" at position 2: invalid character class range: `z-a`"},
+		{pattern: "a(?=b)", err: "at position 1: invalid or unsupported Perl syntax: `(?=` (lookaround assertions are not supported by RE2)"},
+		{pattern: "x(?<!b)", err: "at position 1: invalid named capture: `(?<!b)` (lookaround assertions are not supported by RE2)"},
+		{pattern: "(?>a)", err: "(atomic groups are not supported by RE2)"},
+		{pattern: "(a)\\1", err: "at position 3: invalid escape sequence: `\\1` (backreferences are not supported by RE2)"},
+		{pattern: "a++", err: "at position 1: invalid nested repetition operator: `++` (possessive quantifiers are not supported by RE2)"},
+	} {
+		t.Run(tc.pattern, func(t *testing.T) {
+			_, err := CompileRegexp(tc.pattern)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestRegexpLiterals(t *testing.T) {
+	for _, tc := range []struct {
+		pattern  string
+		literals []string
+	}{
+		{pattern: "^foo$", literals: []string{"foo"}},
+		{pattern: "^(foo|bar|baz)$", literals: []string{"foo", "bar", "baz"}},
+		{pattern: "^(a|b|c)$", literals: []string{"a", "b", "c"}},
+		{pattern: "^(?:api|web)-(1|2)$", literals: []string{"api-1", "api-2", "web-1", "web-2"}},
+		{pattern: "^(|foo)$", literals: []string{"", "foo"}},
+		{pattern: "^$", literals: []string{""}},
+		{pattern: "foo|bar"},
+		{pattern: "^(foo|bar)"},
+		{pattern: "^(foo|bar).*$"},
+		{pattern: "^(?i)foo$"},
+		{pattern: "^[a-z]{3}$"},
+	} {
+		t.Run(tc.pattern, func(t *testing.T) {
+			literals, ok := RegexpLiterals(tc.pattern)
+			require.Equal(t, tc.literals != nil, ok)
+			require.ElementsMatch(t, tc.literals, literals)
+
+			re, err := CompileRegexp(tc.pattern)
+			require.NoError(t, err)
+			for _, literal := range literals {
+				require.True(t, re.MatchString(literal))
+			}
+		})
+	}
+}
+
+func TestCompileRegexpCache(t *testing.T) {
+	c := newRegexpCache(2)
+	a, err := c.compile("a.*")
+	require.NoError(t, err)
+	again, err := c.compile("a.*")
+	require.NoError(t, err)
+	require.Same(t, a, again)
+
+	_, err = c.compile("b.*")
+	require.NoError(t, err)
+	_, err = c.compile("c.*")
+	require.NoError(t, err)
+	require.Len(t, c.entries, 2)
+	_, ok := c.entries["a.*"]
+	require.False(t, ok, "least recently used regex should be evicted")
+
+	_, err = c.compile("d(")
+	require.Error(t, err)
+	require.Len(t, c.entries, 2)
+}
+
+func TestFilterInvalidRegexReportedAtBuild(t *testing.T) {
+	_, err := (&Builder{}).
+		Scan(&mockTableProvider{dynparquet.NewSampleSchema()}, "table1").
+		Filter(And(
+			Col("example_type").RegexMatch("cpu(?!_total)"),
+			Col("labels.label1").RegexNotMatch("value.*"),
+		)).
+		Build()
+
+	require.NotNil(t, err)
+	planErr, ok := err.(*PlanValidationError)
+	require.True(t, ok)
+	require.True(t, strings.HasPrefix(planErr.message, "invalid filter"))
+	require.Len(t, planErr.children, 1)
+	require.Len(t, planErr.children[0].children, 1)
+	require.Equal(t,
+		"invalid regex \"cpu(?!_total)\" at position 3: invalid or unsupported Perl syntax: `(?!` (lookaround assertions are not supported by RE2)",
+		planErr.children[0].children[0].message,
+	)
+}
@@ -6,7 +6,10 @@ import (
 	"strings"
 
 	"github.com/apache/arrow/go/v14/arrow/scalar"
+	"github.com/parquet-go/parquet-go"
 	"github.com/parquet-go/parquet-go/format"
+
+	"github.com/polarsignals/frostdb/dynparquet"
 )
 
 // PlanValidationError is the error representing a logical plan that is not valid.
@@ -153,13 +156,19 @@ func ValidateAggregation(plan *LogicalPlan) *PlanValidationError {
 		}
 	}
 
-	// check that the expression is valid
-	aggExprError := ValidateAggregationExpr(plan)
-	if aggExprError != nil {
+	// check that the expressions are valid, reporting all the invalid ones
+	aliases := map[string]struct{}{}
+	var children []*ExprValidationError
+	for _, expr := range plan.Aggregation.AggExprs {
+		if err := validateAggregationExpr(plan, expr, aliases); err != nil {
+			children = append(children, err)
+		}
+	}
+	if len(children) > 0 {
 		return &PlanValidationError{
 			plan:     plan,
 			message:  "invalid aggregation",
-			children: []*ExprValidationError{aggExprError},
+			children: children,
 		}
 	}
 
@@ -170,73 +179,117 @@ type Named interface {
 	Name() string
 }
 
+// ValidateAggregationExpr validates the expressions of the logical plan's
+// aggregation step, returning the error of the first invalid one.
 func ValidateAggregationExpr(plan *LogicalPlan) *ExprValidationError {
 	aliases := map[string]struct{}{}
-
 	for _, expr := range plan.Aggregation.AggExprs {
-		// check that the aggregation expression has the required structure
-		colFinder := newTypeFinder((*Column)(nil))
-		expr.Accept(&colFinder)
+		if err := validateAggregationExpr(plan, expr, aliases); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateAggregationExpr(plan *LogicalPlan, expr Expr, aliases map[string]struct{}) *ExprValidationError {
+	// check that the aggregation expression has the required structure
+	colFinder := newTypeFinder((*Column)(nil))
+	expr.Accept(&colFinder)
 
-		dynColFinder := newTypeFinder((*DynamicColumn)(nil))
-		expr.Accept(&dynColFinder)
+	dynColFinder := newTypeFinder((*DynamicColumn)(nil))
+	expr.Accept(&dynColFinder)
 
-		aggFuncFinder := newTypeFinder((*AggregationFunction)(nil))
-		expr.Accept(&aggFuncFinder)
+	aggFuncFinder := newTypeFinder((*AggregationFunction)(nil))
+	expr.Accept(&aggFuncFinder)
 
-		if (colFinder.result == nil && dynColFinder.result == nil) || aggFuncFinder.result == nil {
+	if (colFinder.result == nil && dynColFinder.result == nil) || aggFuncFinder.result == nil {
+		return &ExprValidationError{
+			message: "aggregation expression is invalid. must contain AggregationFunction and Column",
+			expr:    expr,
+		}
+	}
+
+	if f := aggFuncFinder.result.(*AggregationFunction); f.Func == AggFuncHistogram {
+		if len(f.Boundaries) == 0 {
 			return &ExprValidationError{
-				message: "aggregation expression is invalid. must contain AggregationFunction and Column",
+				message: "histogram requires at least one bucket boundary",
 				expr:    expr,
 			}
 		}
+		for i := 1; i < len(f.Boundaries); i++ {
+			if f.Boundaries[i] <= f.Boundaries[i-1] {
+				return &ExprValidationError{
+					message: "histogram bucket boundaries must be strictly increasing",
+					expr:    expr,
+				}
+			}
+		}
+	}
 
-		// check that column being aggregated on exists in the schema
-		schema := plan.InputSchema()
-		if schema == nil {
-			return nil // cannot check column type if there's no input schema
+	if f := aggFuncFinder.result.(*AggregationFunction); f.Func == AggFuncFirst || f.Func == AggFuncLast {
+		if _, ok := f.OrderByExpr.(*Column); !ok {
+			return &ExprValidationError{
+				message: fmt.Sprintf("%s must be ordered by a column", f.Func),
+				expr:    expr,
+			}
 		}
+	}
+
+	// check that column being aggregated on exists in the schema
+	schema := plan.InputSchema()
+	if schema == nil {
+		return nil // cannot check column type if there's no input schema
+	}
 
-		var named Named
-		named = colFinder.result
-		if named == nil {
-			named = dynColFinder.result
+	var named Named
+	named = colFinder.result
+	if named == nil {
+		named = dynColFinder.result
+	}
+
+	column, found := findColumn(schema, named.Name())
+	if !found {
+		return &ExprValidationError{
+			message: fmt.Sprintf("column not found: %s", named.Name()),
+			expr:    expr,
 		}
+	}
 
-		column, found := schema.ColumnByName(named.Name())
-		if !found {
+	if alias, ok := expr.(*AliasExpr); ok {
+		if _, found := aliases[alias.Alias]; found {
 			return &ExprValidationError{
-				message: fmt.Sprintf("column not found: %s", named.Name()),
+				message: fmt.Sprintf("alias used twice: %s", alias.Alias),
 				expr:    expr,
 			}
 		}
+		aliases[alias.Alias] = struct{}{}
+	}
 
-		if alias, ok := expr.(*AliasExpr); ok {
-			if _, found := aliases[alias.Alias]; found {
-				return &ExprValidationError{
-					message: fmt.Sprintf("alias used twice: %s", alias.Alias),
-					expr:    expr,
-				}
+	// check that the column type can be aggregated by the function type
+	columnType := column.StorageLayout.Type()
+	aggFuncExpr := aggFuncFinder.result.(*AggregationFunction)
+	logicalType := columnType.LogicalType()
+	if logicalType != nil && logicalType.UTF8 != nil {
+		switch aggFuncExpr.Func {
+		case AggFuncSum:
+			return &ExprValidationError{
+				message: "cannot sum text column",
+				expr:    expr,
 			}
-			aliases[alias.Alias] = struct{}{}
-		}
-
-		// check that the column type can be aggregated by the function type
-		columnType := column.StorageLayout.Type()
-		aggFuncExpr := aggFuncFinder.result.(*AggregationFunction)
-		logicalType := columnType.LogicalType()
-		if logicalType != nil && logicalType.UTF8 != nil {
-			switch aggFuncExpr.Func {
-			case AggFuncSum:
-				return &ExprValidationError{
-					message: "cannot sum text column",
-					expr:    expr,
-				}
-			case AggFuncMax:
-				return &ExprValidationError{
-					message: "cannot max text column",
-					expr:    expr,
-				}
+		case AggFuncMax:
+			return &ExprValidationError{
+				message: "cannot max text column",
+				expr:    expr,
+			}
+		case AggFuncHistogram:
+			return &ExprValidationError{
+				message: "cannot histogram text column",
+				expr:    expr,
+			}
+		case AggFuncStddev, AggFuncVariance:
+			return &ExprValidationError{
+				message: fmt.Sprintf("cannot %s text column", aggFuncExpr.Func),
+				expr:    expr,
 			}
 		}
 	}
@@ -244,6 +297,24 @@ func ValidateAggregationExpr(plan *LogicalPlan) *ExprValidationError {
 	return nil
 }
 
+// findColumn returns the definition of the named column of the schema. The
+// concrete columns of dynamic columns, e.g. "labels.namespace", are resolved
+// to the definition of their dynamic column.
+func findColumn(schema *dynparquet.Schema, name string) (dynparquet.ColumnDefinition, bool) {
+	if column, found := schema.ColumnByName(name); found {
+		return column, true
+	}
+	dynamic, _, ok := strings.Cut(name, ".")
+	if !ok {
+		return dynparquet.ColumnDefinition{}, false
+	}
+	column, found := schema.ColumnByName(dynamic)
+	if !found || !column.Dynamic {
+		return dynparquet.ColumnDefinition{}, false
+	}
+	return column, true
+}
+
 // ValidateInput validates that the current logical plans input is valid.
 // It returns nil if the plan has no input.
 func ValidateInput(plan *LogicalPlan) *PlanValidationError {
@@ -300,11 +371,25 @@ func ValidateFilterBinaryExpr(plan *LogicalPlan, expr *BinaryExpr) *ExprValidati
 		}
 	}
 
+	if expr.Op == OpRegexMatch || expr.Op == OpRegexNotMatch {
+		if err := validateRegexLiteral(expr); err != nil {
+			return err
+		}
+	}
+
 	// try to find the column in the schema
 	columnExpr := leftColumnFinder.result.(*Column)
 	schema := plan.InputSchema()
 	if schema != nil {
-		column, found := schema.ColumnByName(columnExpr.ColumnName)
+		column, found := findColumn(schema, columnExpr.ColumnName)
+		if found && (expr.Op == OpRegexMatch || expr.Op == OpRegexNotMatch) {
+			if logicalType := column.StorageLayout.Type().LogicalType(); logicalType == nil || logicalType.UTF8 == nil {
+				return &ExprValidationError{
+					message: fmt.Sprintf("incompatible types: regex cannot be matched against non-string column %s", columnExpr.ColumnName),
+					expr:    expr,
+				}
+			}
+		}
 		if found {
 			// try to find the literal on the other side of the expression
 			rightLiteralFinder := newTypeFinder((*LiteralExpr)(nil))
@@ -313,6 +398,30 @@ func ValidateFilterBinaryExpr(plan *LogicalPlan, expr *BinaryExpr) *ExprValidati
 				// ensure that the column type is compatible with the literal being compared to it
 				t := column.StorageLayout.Type()
 				literalExpr := rightLiteralFinder.result.(*LiteralExpr)
+				if t.Kind() == parquet.Double {
+					// float columns have no logical type, literals
+					// compared with them are coerced to float
+					switch literalExpr.Value.(type) {
+					case *scalar.Float64, *scalar.Null:
+						return nil
+					default:
+						return &ExprValidationError{
+							message: fmt.Sprintf("incompatible types: float column cannot be compared with %v", literalExpr.Value.DataType()),
+							expr:    expr,
+						}
+					}
+				}
+				if t.Kind() == parquet.Float {
+					switch literalExpr.Value.(type) {
+					case *scalar.Float32, *scalar.Null:
+						return nil
+					default:
+						return &ExprValidationError{
+							message: fmt.Sprintf("incompatible types: float32 column cannot be compared with %v", literalExpr.Value.DataType()),
+							expr:    expr,
+						}
+					}
+				}
 				if err := ValidateComparingTypes(t.LogicalType(), literalExpr.Value); err != nil {
 					err.expr = expr
 					return err
@@ -330,7 +439,7 @@ func ValidateComparingTypes(columnType *format.LogicalType, literal scalar.Scala
 	// if the columns logical type is nil, it may be of type bool
 	case columnType == nil:
 		switch t := literal.(type) {
-		case *scalar.Boolean:
+		case *scalar.Boolean, *scalar.Null:
 			return nil
 		default:
 			return &ExprValidationError{
@@ -350,14 +459,46 @@ func ValidateComparingTypes(columnType *format.LogicalType, literal scalar.Scala
 			return &ExprValidationError{
 				message: "incompatible types: string column cannot be compared with numeric literal",
 			}
+		case *scalar.Boolean:
+			return &ExprValidationError{
+				message: "incompatible types: string column cannot be compared with boolean literal",
+			}
 		}
 	// if the column is a numeric type, it shouldn't be compared to a string
-	case columnType.Integer != nil:
+	case columnType.Integer != nil || columnType.Timestamp != nil:
 		switch literal.(type) {
-		case *scalar.String:
+		case *scalar.String, *scalar.Binary:
 			return &ExprValidationError{
 				message: "incompatible types: numeric column cannot be compared with string literal",
 			}
+		case *scalar.Boolean:
+			return &ExprValidationError{
+				message: "incompatible types: numeric column cannot be compared with boolean literal",
+			}
+		}
+	}
+	return nil
+}
+
+// validateRegexLiteral compiles the regex the column is matched against, so
+// that invalid regexes are reported when the plan is built. The compiled regex
+// is cached for the execution of the plan.
+func validateRegexLiteral(expr *BinaryExpr) *ExprValidationError {
+	lit, ok := expr.Right.(*LiteralExpr)
+	if !ok {
+		return nil
+	}
+	pattern, ok := lit.Value.(*scalar.String)
+	if !ok {
+		return &ExprValidationError{
+			message: fmt.Sprintf("regex must be a string literal, got %v", lit.Value.DataType()),
+			expr:    expr,
+		}
+	}
+	if _, err := CompileRegexp(string(pattern.Data())); err != nil {
+		return &ExprValidationError{
+			message: err.Error(),
+			expr:    expr,
 		}
 	}
 	return nil
@@ -3,6 +3,7 @@ package logicalplan
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -245,3 +246,91 @@ func TestFilterAndExprEvaluatesEachAndedRule(t *testing.T) {
 	rightErr := exprErr.children[1]
 	require.True(t, strings.HasPrefix(rightErr.message, "left side of binary expression must be a column"))
 }
+
+func TestAggregationReportsAllInvalidExprs(t *testing.T) {
+	_, err := (&Builder{}).
+		Scan(&mockTableProvider{dynparquet.NewSampleSchema()}, "table1").
+		Aggregate([]Expr{
+			Sum(Col("bad_column")),
+			Sum(Col("value")),
+			Max(Col("example_type")),
+			Sum(Col("labels.label1")),
+		}, nil).
+		Build()
+
+	require.NotNil(t, err)
+	planErr, ok := err.(*PlanValidationError)
+	require.True(t, ok)
+	require.True(t, strings.HasPrefix(planErr.message, "invalid aggregation"))
+	require.Len(t, planErr.children, 3)
+	require.True(t, strings.HasPrefix(planErr.children[0].message, "column not found: bad_column"))
+	require.True(t, strings.HasPrefix(planErr.children[1].message, "cannot max text column"))
+	// Concrete dynamic columns are resolved to their dynamic column.
+	require.True(t, strings.HasPrefix(planErr.children[2].message, "cannot sum text column"))
+}
+
+func TestFilterRegexRequiresStringColumn(t *testing.T) {
+	_, err := (&Builder{}).
+		Scan(&mockTableProvider{dynparquet.NewSampleSchema()}, "table1").
+		Filter(Col("timestamp").RegexMatch("1.*")).
+		Build()
+
+	require.NotNil(t, err)
+	planErr, ok := err.(*PlanValidationError)
+	require.True(t, ok)
+	require.True(t, strings.HasPrefix(planErr.message, "invalid filter"))
+	require.Len(t, planErr.children, 1)
+	require.True(t, strings.HasPrefix(planErr.children[0].message, "incompatible types: regex cannot be matched against non-string column timestamp"))
+
+	_, err = (&Builder{}).
+		Scan(&mockTableProvider{dynparquet.NewSampleSchema()}, "table1").
+		Filter(And(
+			Col("example_type").RegexMatch("type.*"),
+			Col("labels.label1").RegexNotMatch("value.*"),
+		)).
+		Build()
+	require.NoError(t, err)
+}
+
+func TestFilterTypedLiterals(t *testing.T) {
+	ts := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, Literal(ts.UnixMilli()).Value, Timestamp(ts).Value)
+	require.Equal(t, Literal(1.5).Value, Float(1.5).Value)
+	require.Equal(t, Literal(true).Value, Bool(true).Value)
+	require.Equal(t, Literal("a").Value, String("a").Value)
+	require.Equal(t, Literal([]byte("a")).Value, Bytes([]byte("a")).Value)
+	require.False(t, Null().Value.IsValid())
+
+	for _, tc := range []struct {
+		name    string
+		filter  Expr
+		invalid bool
+	}{
+		{name: "timestamp", filter: Col("timestamp").GtEq(Timestamp(ts))},
+		{name: "float", filter: Col("timestamp").Lt(Float(1.5))},
+		{name: "string", filter: Col("example_type").Eq(String("cpu"))},
+		{name: "bytes", filter: Col("example_type").Eq(Bytes([]byte("cpu")))},
+		{name: "is_null", filter: Col("labels.node").IsNull()},
+		{name: "is_not_null", filter: Col("value").IsNotNull()},
+		{name: "bool_int", filter: Col("value").Eq(Bool(true)), invalid: true},
+		{name: "bool_string", filter: Col("example_type").Eq(Bool(true)), invalid: true},
+		{name: "bytes_int", filter: Col("timestamp").Eq(Bytes([]byte("1"))), invalid: true},
+		{name: "string_int", filter: Col("value").Eq(String("1")), invalid: true},
+		{name: "int_string", filter: Col("example_type").Eq(Int(1)), invalid: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := (&Builder{}).
+				Scan(&mockTableProvider{dynparquet.NewSampleSchema()}, "table1").
+				Filter(tc.filter).
+				Build()
+			if !tc.invalid {
+				require.NoError(t, err)
+				return
+			}
+			planErr, ok := err.(*PlanValidationError)
+			require.True(t, ok)
+			require.Len(t, planErr.children, 1)
+			require.True(t, strings.HasPrefix(planErr.children[0].message, "incompatible types"))
+		})
+	}
+}
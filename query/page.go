@@ -0,0 +1,568 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/pqarrow"
+	"github.com/polarsignals/frostdb/pqarrow/arrowutils"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// ErrInvalidCursor is returned by ExecutePage for cursors that were not
+// returned by a previous page, or whose pages can no longer be read as of the
+// transaction of the first page.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PagedExecutor is implemented by the Builders that can execute queries in
+// pages.
+type PagedExecutor interface {
+	ExecutePage(
+		ctx context.Context,
+		cursor string,
+		limit int,
+		callback func(ctx context.Context, r arrow.Record) error,
+	) (string, error)
+}
+
+// ExecutePage executes the query and calls the callback with a single record
+// of the limit rows following the position of the given cursor in the results,
+// or of the first rows if the cursor is empty. It returns the opaque cursor of
+// the next page, which is empty if this was the last one. The callback is not
+// called if no rows follow the cursor.
+//
+// The rows are ordered by the sorting columns of the table, followed by all
+// other columns of the results that can be sorted by in name order, so that
+// rows only tie if they are equal in all of them. The columns of the page are
+// ordered by name. The cursor holds the last row of the page and the
+// transaction the first page was read at, which all following pages are read
+// as of, so rows written after the first page do not shift the pages. If rows
+// written after the first page were compacted or persisted since, which drops
+// their transactions, ExecutePage returns ErrInvalidCursor and the query must
+// be paged from the start again.
+// The cursor also holds the time the first page was planned at, which now() is
+// bound to on all following pages, and a fingerprint of the query, and
+// ExecutePage returns ErrInvalidCursor for the cursors of other queries.
+//
+// Every page executes the query again. Only the rows following the cursor are
+// kept and sorted, and the scan skips the data before the cursor by the first
+// sorting column of the table, unless it is dynamic.
+//
+// Queries with aggregations or distinct can't be paged.
+func (b LocalQueryBuilder) ExecutePage(
+	ctx context.Context,
+	cursor string,
+	limit int,
+	callback func(ctx context.Context, r arrow.Record) error,
+) (string, error) {
+	ctx, span := b.tracer.Start(ctx, "LocalQueryBuilder/ExecutePage")
+	defer span.End()
+
+	if limit <= 0 {
+		return "", fmt.Errorf("page limit must be positive, got %d", limit)
+	}
+	page := &pageBuffer{
+		pool:  b.pool,
+		limit: int64(limit),
+
+		// The fingerprint is taken of the plan before now() is bound, so it
+		// doesn't depend on the time the pages are executed at.
+		fingerprint: xxhash.Sum64String(b.planBuilder.String()),
+		now:         b.clock.Now(),
+	}
+	defer page.release()
+	if cursor != "" {
+		if err := page.decodeCursor(cursor); err != nil {
+			return "", err
+		}
+	}
+
+	logicalPlan, err := b.buildLogicalAt(ctx, page.now)
+	if err != nil {
+		return "", err
+	}
+	scan, err := pagedScan(logicalPlan)
+	if err != nil {
+		return "", err
+	}
+	table, err := scan.TableProvider.GetTable(scan.TableName)
+	if err != nil {
+		return "", fmt.Errorf("get table %q: %w", scan.TableName, err)
+	}
+	page.schema = table.Schema()
+
+	if cursor == "" {
+		if err := table.View(ctx, func(_ context.Context, tx uint64) error {
+			page.tx = tx
+			return nil
+		}); err != nil {
+			return "", err
+		}
+		if scan.AsOfTx != 0 && scan.AsOfTx < page.tx {
+			page.tx = scan.AsOfTx
+		}
+	} else {
+		// The filter only prunes the data of the scan, the rows before the
+		// cursor are dropped when the page is collected. Filtering them in the
+		// plan would change the columns the scan reads.
+		if filter := page.seekFilter(); filter != nil {
+			if scan.Filter != nil {
+				filter = logicalplan.And(scan.Filter, filter)
+			}
+			scan.Filter = filter
+		}
+	}
+	scan.AsOfTx = page.tx
+	if err := b.execute(ctx, logicalPlan, page.add); err != nil {
+		if cursor != "" && errors.Is(err, logicalplan.ErrSnapshotUnavailable) {
+			return "", fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+		}
+		return "", err
+	}
+
+	r, next, err := page.finish()
+	if err != nil || r == nil {
+		return "", err
+	}
+	defer r.Release()
+	if err := callback(ctx, r); err != nil {
+		return "", err
+	}
+	return next, nil
+}
+
+// pagedScan returns the table scan of the plan if its results can be paged.
+func pagedScan(plan *logicalplan.LogicalPlan) (*logicalplan.TableScan, error) {
+	for ; plan != nil; plan = plan.Input {
+		switch {
+		case plan.Aggregation != nil, plan.Distinct != nil:
+			return nil, errors.New("queries with aggregations or distinct can't be paged")
+		case plan.TableScan != nil:
+			return plan.TableScan, nil
+		}
+	}
+	return nil, errors.New("paged queries must scan a table")
+}
+
+// pageBuffer collects the rows of a page. Whenever it holds more than twice as
+// many rows as can still make it into the page, it sorts them and drops the
+// others, so that its memory is bounded by the size of the page rather than by
+// the results of the query.
+type pageBuffer struct {
+	pool   memory.Allocator
+	schema *dynparquet.Schema
+	limit  int64
+
+	// tx is the transaction the pages are read at.
+	tx uint64
+	// now is the time now() is bound to on every page.
+	now time.Time
+	// fingerprint identifies the query the pages are read of.
+	fingerprint uint64
+	// key is the last row of the previous page, projected to the columns the
+	// rows are ordered by, or nil for the first page.
+	key arrow.Record
+	// skip is the number of rows equal to the key that were returned by the
+	// previous pages.
+	skip int64
+
+	mtx sync.Mutex
+	// fields is the union of the fields of the results.
+	fields  *arrow.Schema
+	records []arrow.Record
+	rows    int64
+}
+
+func (p *pageBuffer) add(_ context.Context, r arrow.Record) error {
+	if r.NumRows() == 0 {
+		return nil
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	r.Retain()
+	p.records = append(p.records, r)
+	p.rows += r.NumRows()
+	schemas := []*arrow.Schema{r.Schema()}
+	if p.fields != nil {
+		schemas = append(schemas, p.fields)
+	}
+	p.fields = pqarrow.MergeArrowSchemas(schemas)
+
+	if p.rows <= 2*(p.skip+p.limit) {
+		return nil
+	}
+	// The rows equal to the key are only skipped once all rows are collected.
+	_, err := p.compact(p.skip+p.limit, 0)
+	return err
+}
+
+func (p *pageBuffer) release() {
+	for _, r := range p.records {
+		r.Release()
+	}
+	p.records = nil
+	if p.key != nil {
+		p.key.Release()
+		p.key = nil
+	}
+}
+
+// finish returns the page and the cursor of the next page, or nil if no rows
+// follow the cursor.
+func (p *pageBuffer) finish() (arrow.Record, string, error) {
+	if p.rows == 0 {
+		return nil, "", nil
+	}
+	equal, err := p.compact(p.limit, p.skip)
+	if err != nil {
+		return nil, "", err
+	}
+	if p.rows == 0 {
+		return nil, "", nil
+	}
+	// Drop the columns that only the key has.
+	page, err := pqarrow.Unify(p.pool, p.records[0], p.fields)
+	if err != nil {
+		return nil, "", err
+	}
+	if page.NumRows() < p.limit {
+		return page, "", nil
+	}
+	next, err := p.nextCursor(page, equal)
+	if err != nil {
+		page.Release()
+		return nil, "", err
+	}
+	return page, next, nil
+}
+
+// compact sorts the collected rows into a single record, keeping the first n
+// rows following the key. Of the rows equal to the key, the first skip rows
+// are dropped. It returns the number of kept rows that are equal to the key.
+func (p *pageBuffer) compact(n, skip int64) (int64, error) {
+	records := p.records
+	schemas := make([]*arrow.Schema, 0, len(records)+1)
+	for _, r := range records {
+		schemas = append(schemas, r.Schema())
+	}
+	if p.key != nil {
+		// The key is sorted along with the rows to find the position of the
+		// cursor. It is sorted before the rows equal to it, since the sort is
+		// stable.
+		records = append([]arrow.Record{p.key}, records...)
+		schemas = append(schemas, p.key.Schema())
+	}
+	r, err := concatRecords(p.pool, pqarrow.MergeArrowSchemas(schemas), records)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Release()
+
+	cols := p.order(r)
+	indices, err := arrowutils.SortRecordByColumns(p.pool, r, cols)
+	if err != nil {
+		return 0, err
+	}
+	defer indices.Release()
+	compare, err := arrowutils.NewRowComparator(r, cols)
+	if err != nil {
+		return 0, err
+	}
+
+	sorted := indices.Int64Values()
+	var equal int64
+	if p.key != nil {
+		sorted = sorted[slices.Index(sorted, 0)+1:]
+		for ; skip > 0 && len(sorted) > 0 && compare(0, int(sorted[0])) == 0; skip-- {
+			sorted = sorted[1:]
+		}
+	}
+	sorted = sorted[:min(n, int64(len(sorted)))]
+	if p.key != nil {
+		for _, i := range sorted {
+			if compare(0, int(i)) != 0 {
+				break
+			}
+			equal++
+		}
+	}
+
+	for _, r := range p.records {
+		r.Release()
+	}
+	p.records = nil
+	p.rows = 0
+	if len(sorted) == 0 {
+		return 0, nil
+	}
+
+	b := array.NewInt64Builder(p.pool)
+	defer b.Release()
+	b.AppendValues(sorted, nil)
+	kept := b.NewInt64Array()
+	defer kept.Release()
+	compacted, err := arrowutils.TakeRecord(p.pool, r, kept)
+	if err != nil {
+		return 0, err
+	}
+	p.records = []arrow.Record{compacted}
+	p.rows = compacted.NumRows()
+	return equal, nil
+}
+
+// order returns the columns the rows of the record are ordered by: the sorting
+// columns of the table, followed by all other columns that can be sorted by.
+func (p *pageBuffer) order(r arrow.Record) []arrowutils.SortingColumn {
+	schema := r.Schema()
+	cols := make([]arrowutils.SortingColumn, 0, schema.NumFields())
+	ordered := make(map[int]struct{}, schema.NumFields())
+	for _, col := range p.schema.ParquetSortingColumns(pqarrow.RecordDynamicCols(r)) {
+		indices := schema.FieldIndices(col.Path()[0])
+		if len(indices) == 0 || !arrowutils.Sortable(r.Column(indices[0])) {
+			continue
+		}
+		cols = append(cols, arrowutils.SortingColumn{
+			Index:      indices[0],
+			Descending: col.Descending(),
+			NullsFirst: col.NullsFirst(),
+		})
+		ordered[indices[0]] = struct{}{}
+	}
+	for i := 0; i < schema.NumFields(); i++ {
+		if _, ok := ordered[i]; ok || !arrowutils.Sortable(r.Column(i)) {
+			continue
+		}
+		cols = append(cols, arrowutils.SortingColumn{Index: i})
+	}
+	return cols
+}
+
+// nextCursor returns the cursor of the page following the given one, of which
+// the first equal rows are equal to the key of the cursor of the page.
+func (p *pageBuffer) nextCursor(page arrow.Record, equal int64) (string, error) {
+	cols := p.order(page)
+	compare, err := arrowutils.NewRowComparator(page, cols)
+	if err != nil {
+		return "", err
+	}
+	last := int(page.NumRows()) - 1
+	var skip int64
+	for i := last; i >= 0 && compare(i, last) == 0; i-- {
+		skip++
+	}
+	if equal == page.NumRows() {
+		// The key is unchanged, so are the rows skipped before this page.
+		skip += p.skip
+	}
+
+	fields := make([]arrow.Field, 0, len(cols))
+	arrs := make([]arrow.Array, 0, len(cols))
+	for _, col := range cols {
+		fields = append(fields, page.Schema().Field(col.Index))
+		arrs = append(arrs, page.Column(col.Index))
+	}
+	projected := array.NewRecord(arrow.NewSchema(fields, nil), arrs, page.NumRows())
+	defer projected.Release()
+	b := array.NewInt64Builder(p.pool)
+	defer b.Release()
+	b.Append(int64(last))
+	indices := b.NewInt64Array()
+	defer indices.Release()
+	// The key is copied, rather than sliced, so that the cursor does not carry
+	// the dictionaries of the page.
+	key, err := arrowutils.TakeRecord(p.pool, projected, indices)
+	if err != nil {
+		return "", err
+	}
+	defer key.Release()
+
+	buf := binary.LittleEndian.AppendUint64(nil, p.fingerprint)
+	buf = binary.AppendUvarint(buf, p.tx)
+	buf = binary.AppendVarint(buf, p.now.UnixNano())
+	buf = binary.AppendUvarint(buf, uint64(skip))
+	w := bytes.NewBuffer(buf)
+	writer := ipc.NewWriter(w, ipc.WithSchema(key.Schema()), ipc.WithAllocator(p.pool))
+	if err := writer.Write(key); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(w.Bytes()), nil
+}
+
+func (p *pageBuffer) decodeCursor(cursor string) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if len(data) < 8 {
+		return ErrInvalidCursor
+	}
+	if binary.LittleEndian.Uint64(data) != p.fingerprint {
+		return fmt.Errorf("%w: the cursor is of a different query", ErrInvalidCursor)
+	}
+	r := bytes.NewReader(data[8:])
+	tx, err := binary.ReadUvarint(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	now, err := binary.ReadVarint(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	skip, err := binary.ReadUvarint(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	reader, err := ipc.NewReader(r, ipc.WithAllocator(p.pool))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	defer reader.Release()
+	if !reader.Next() || reader.Record().NumRows() != 1 {
+		return ErrInvalidCursor
+	}
+
+	p.tx = tx
+	p.now = time.Unix(0, now)
+	p.skip = int64(skip)
+	p.key = reader.Record()
+	p.key.Retain()
+	return nil
+}
+
+// seekFilter returns a filter of the rows that can follow the key by the first
+// sorting column of the table, or nil if the rows can't be filtered by it.
+func (p *pageBuffer) seekFilter() logicalplan.Expr {
+	defs := p.schema.SortingColumns()
+	if len(defs) == 0 || defs[0].Dynamic {
+		return nil
+	}
+	col := p.schema.ParquetSortingColumns(nil)[0]
+	name := col.Path()[0]
+	indices := p.key.Schema().FieldIndices(name)
+	if len(indices) == 0 || p.key.Column(indices[0]).IsNull(0) {
+		return nil
+	}
+
+	column := logicalplan.Col(name)
+	var value logicalplan.Expr
+	switch arr := p.key.Column(indices[0]).(type) {
+	case *array.Int64:
+		value = logicalplan.Int(arr.Value(0))
+	case *array.Uint64:
+		value = logicalplan.Literal(arr.Value(0))
+	case *array.Float64:
+		if !seekableFloat(arr.Value(0), col.Descending()) {
+			return nil
+		}
+		value = logicalplan.Float(arr.Value(0))
+	case *array.Float32:
+		if !seekableFloat(float64(arr.Value(0)), col.Descending()) {
+			return nil
+		}
+		value = logicalplan.Literal(arr.Value(0))
+	case *array.String:
+		value = logicalplan.String(arr.Value(0))
+	case *array.Binary:
+		// String literals are compared to binary, string and dictionary
+		// columns, depending on the encoding of the data.
+		value = logicalplan.String(string(arr.Value(0)))
+	case *array.Dictionary:
+		switch dict := arr.Dictionary().(type) {
+		case *array.String:
+			value = logicalplan.String(dict.Value(arr.GetValueIndex(0)))
+		case *array.Binary:
+			value = logicalplan.String(string(dict.Value(arr.GetValueIndex(0))))
+		default:
+			return nil
+		}
+	case *array.Boolean:
+		// Booleans only support equality, which only filters when the key is
+		// the last value in the order.
+		if arr.Value(0) == col.Descending() {
+			return nil
+		}
+		value = logicalplan.Bool(arr.Value(0))
+		return seekNulls(column.Eq(value), column, col.NullsFirst())
+	default:
+		return nil
+	}
+	filter := logicalplan.Expr(column.GtEq(value))
+	if col.Descending() {
+		filter = column.LtEq(value)
+	}
+	return seekNulls(filter, column, col.NullsFirst())
+}
+
+// seekNulls returns the seek filter that also keeps the null values of the
+// column if they sort after the key.
+func seekNulls(filter logicalplan.Expr, column *logicalplan.Column, nullsFirst bool) logicalplan.Expr {
+	if nullsFirst {
+		return filter
+	}
+	return logicalplan.Or(filter, column.IsNull())
+}
+
+// seekableFloat returns whether the rows following a float key can be filtered
+// by comparing them to it. NaN values sort first, so they follow every key of
+// a descending column, but no comparison keeps them.
+func seekableFloat(key float64, descending bool) bool {
+	return !math.IsNaN(key) && !descending
+}
+
+// concatRecords returns the rows of the records in a single record of the
+// given schema.
+func concatRecords(pool memory.Allocator, schema *arrow.Schema, records []arrow.Record) (arrow.Record, error) {
+	unified := make([]arrow.Record, 0, len(records))
+	defer func() {
+		for _, r := range unified {
+			r.Release()
+		}
+	}()
+	var rows int64
+	for _, r := range records {
+		u, err := pqarrow.Unify(pool, r, schema)
+		if err != nil {
+			return nil, err
+		}
+		unified = append(unified, u)
+		rows += u.NumRows()
+	}
+
+	cols := make([]arrow.Array, 0, schema.NumFields())
+	defer func() {
+		for _, col := range cols {
+			col.Release()
+		}
+	}()
+	arrs := make([]arrow.Array, len(unified))
+	for i := 0; i < schema.NumFields(); i++ {
+		for j, r := range unified {
+			arrs[j] = r.Column(i)
+		}
+		col, err := array.Concatenate(arrs, pool)
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, col)
+	}
+	return array.NewRecord(schema, cols, rows), nil
+}
@@ -1,17 +1,21 @@
 package physicalplan
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"hash/maphash"
+	gomath "math"
+	"math/bits"
+	"sort"
 	"strings"
 
 	"github.com/apache/arrow/go/v14/arrow"
 	"github.com/apache/arrow/go/v14/arrow/array"
 	"github.com/apache/arrow/go/v14/arrow/math"
 	"github.com/apache/arrow/go/v14/arrow/memory"
-	"github.com/apache/arrow/go/v14/arrow/scalar"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
@@ -28,6 +32,18 @@ func Aggregate(
 	ordered bool,
 	seed maphash.Seed,
 ) (PhysicalPlan, error) {
+	aggregations, err := planAggregations(agg)
+	if err != nil {
+		return nil, err
+	}
+	return newAggregate(pool, tracer, aggregations, agg.GroupExprs, final, ordered, seed)
+}
+
+// planAggregations returns the aggregations of the given aggregation plan,
+// without the builders of their groups. An aggregate operator takes ownership
+// of the aggregations it is created with, so they are copied for every
+// operator.
+func planAggregations(agg *logicalplan.Aggregation) ([]Aggregation, error) {
 	aggregations := make([]Aggregation, 0, len(agg.AggExprs))
 
 	for _, expr := range agg.AggExprs {
@@ -41,6 +57,8 @@ func Aggregate(
 			switch e := expr.(type) {
 			case *logicalplan.AggregationFunction:
 				aggFunc = e.Func
+				aggregation.boundaries = e.Boundaries
+				aggregation.orderBy = e.OrderByExpr
 				aggFuncFound = true
 			case *logicalplan.Column:
 				aggregation.expr = e
@@ -67,7 +85,18 @@ func Aggregate(
 
 		aggregations = append(aggregations, aggregation)
 	}
+	return aggregations, nil
+}
 
+func newAggregate(
+	pool memory.Allocator,
+	tracer trace.Tracer,
+	aggregations []Aggregation,
+	groupExprs []logicalplan.Expr,
+	final bool,
+	ordered bool,
+	seed maphash.Seed,
+) (PhysicalPlan, error) {
 	if ordered {
 		if len(aggregations) > 1 {
 			return nil, fmt.Errorf(
@@ -81,7 +110,7 @@ func Aggregate(
 			// supported. The planning code should already have planned a hash
 			// aggregation in this case.
 			aggregations[0],
-			agg.GroupExprs,
+			groupExprs,
 			final,
 		), nil
 	}
@@ -89,7 +118,7 @@ func Aggregate(
 		pool,
 		tracer,
 		aggregations,
-		agg.GroupExprs,
+		groupExprs,
 		seed,
 		final,
 	), nil
@@ -97,6 +126,7 @@ func Aggregate(
 
 func chooseAggregationFunction(
 	aggFunc logicalplan.AggFunc,
+	boundaries []float64,
 	_ arrow.DataType,
 ) (AggregationFunction, error) {
 	switch aggFunc {
@@ -108,6 +138,14 @@ func chooseAggregationFunction(
 		return &MaxAggregation{}, nil
 	case logicalplan.AggFuncCount:
 		return &CountAggregation{}, nil
+	case logicalplan.AggFuncHistogram:
+		return &HistogramAggregation{boundaries: boundaries}, nil
+	case logicalplan.AggFuncStddev:
+		return &VarianceAggregation{stddev: true}, nil
+	case logicalplan.AggFuncVariance:
+		return &VarianceAggregation{}, nil
+	case logicalplan.AggFuncAnyValue:
+		return &AnyValueAggregation{}, nil
 	default:
 		return nil, fmt.Errorf("unsupported aggregation function: %s", aggFunc.String())
 	}
@@ -119,7 +157,27 @@ type Aggregation struct {
 	dynamic    bool // dynamic indicates that this aggregation is performed against a dynamic column.
 	resultName string
 	function   logicalplan.AggFunc
+	boundaries []float64               // boundaries are the bucket boundaries of histogram aggregations.
+	orderBy    logicalplan.Expr        // orderBy is the column first and last aggregations pick their values by.
 	arrays     []builder.ColumnBuilder // TODO: These can actually live outside this struct and be shared. Only at the very end will they be read by each column and then aggregated separately.
+	// orderArrays hold the orders of the values in arrays of aggregations
+	// with an orderBy column.
+	orderArrays []builder.ColumnBuilder
+}
+
+// appendBuilders appends builders for a new group of the aggregation of the
+// given column, ordered by the given order column if it's not nil.
+func (a *Aggregation) appendBuilders(pool memory.Allocator, col, order arrow.Array) {
+	a.arrays = append(a.arrays, builder.NewBuilder(pool, col.DataType()))
+	if order != nil {
+		a.orderArrays = append(a.orderArrays, builder.NewBuilder(pool, order.DataType()))
+	}
+}
+
+// orderColumnName returns the name of the column partial first and last
+// aggregations pass the orders of their values forward in.
+func orderColumnName(name string) string {
+	return "order(" + name + ")"
 }
 
 type AggregationFunction interface {
@@ -130,17 +188,20 @@ type HashAggregate struct {
 	pool                  memory.Allocator
 	tracer                trace.Tracer
 	groupByColumnMatchers []logicalplan.Expr
-	hashSeed              maphash.Seed
+	hashKey               func(key []byte) uint64 // hashKey hashes the encoded group keys
 	next                  PhysicalPlan
 	// Indicate is this is the last aggregation or
 	// if this is a aggregation with another aggregation to follow after synchronizing.
 	finalStage bool
 
 	// Buffers that are reused across callback calls.
-	groupByFields      []arrow.Field
-	groupByFieldHashes []hashCombiner
-	groupByArrays      []arrow.Array
-	hashToAggregate    map[uint64]hashtuple
+	groupByFields     []arrow.Field
+	groupByKeyColumns []groupKeyColumn
+	groupByArrays     []arrow.Array
+	groupKey          []byte
+	// hashToAggregate maps the hashes of the group keys to the groups with
+	// the hash, which are told apart by their keys.
+	hashToAggregate map[uint64][]hashtuple
 
 	// aggregates are the collection of all the hash aggregates for this hash aggregation. This is useful when a single hash aggregate cannot fit
 	// into a single record and needs to be split into multiple records.
@@ -148,8 +209,9 @@ type HashAggregate struct {
 }
 
 type hashtuple struct {
-	aggregate int // aggregate is the index into the aggregates slice
-	array     int // array is the index into the aggregations array
+	key       []byte // key is the encoded group key
+	aggregate int    // aggregate is the index into the aggregates slice
+	array     int    // array is the index into the aggregations array
 }
 
 // hashAggregate represents a single hash aggregation.
@@ -193,13 +255,13 @@ func NewHashAggregate(
 		tracer: tracer,
 		// TODO: Matchers can be optimized to be something like a radix tree or just a fast-lookup datastructure for exact matches or prefix matches.
 		groupByColumnMatchers: groupByColumnMatchers,
-		hashSeed:              seed,
+		hashKey:               func(key []byte) uint64 { return maphash.Bytes(seed, key) },
 		finalStage:            finalStage,
 
-		groupByFields:      make([]arrow.Field, 0, 10),
-		groupByFieldHashes: make([]hashCombiner, 0, 10),
-		groupByArrays:      make([]arrow.Array, 0, 10),
-		hashToAggregate:    map[uint64]hashtuple{},
+		groupByFields:     make([]arrow.Field, 0, 10),
+		groupByKeyColumns: make([]groupKeyColumn, 0, 10),
+		groupByArrays:     make([]arrow.Array, 0, 10),
+		hashToAggregate:   map[uint64][]hashtuple{},
 		aggregates: []*hashAggregate{ // initialize a single hash aggregate; we expect this array to only every grow during very large aggregations.
 			{
 				dynamicAggregations:          dynamic,
@@ -222,6 +284,9 @@ func (a *HashAggregate) Close() {
 			for _, bldr := range aggregation.arrays {
 				bldr.Release()
 			}
+			for _, bldr := range aggregation.orderArrays {
+				bldr.Release()
+			}
 		}
 		for _, bldr := range aggregate.groupByCols {
 			bldr.Release()
@@ -259,35 +324,84 @@ func hashCombine(lhs, rhs uint64) uint64 {
 	return lhs ^ (rhs + 0x9e3779b9 + (lhs << 6) + (lhs >> 2))
 }
 
-// hashCombiner combines a given hash with another hash that is passed.
-type hashCombiner interface {
-	hashCombine(rhs uint64) uint64
+// groupKeyColumn encodes the values of a column that is grouped by into the
+// group keys of rows. The group key of a row is the concatenation of the
+// length prefixed names of its non-null group by columns, each followed by its
+// value: fixed-width values are packed in little-endian byte order and
+// variable length values are prefixed by their length. Group keys don't
+// depend on the schema of the record, so that rows of records with different
+// sets of dynamic columns are grouped together if their non-null values are
+// equal.
+type groupKeyColumn struct {
+	// name is the length prefixed name of the column.
+	name []byte
+	arr  arrow.Array
+	// hashes are the hashes of the values of the column, which are encoded
+	// rather than the values. The values of lists are hashed.
+	hashes []uint64
+	// milliseconds is the width of the buckets timestamps are grouped into by
+	// a duration, or zero.
+	milliseconds uint64
 }
 
-// uint64HashCombine combines a pre-defined uint64 hash with a given uint64 hash.
-type uint64HashCombine struct {
-	value uint64
+func newGroupKeyColumn(field arrow.Field, arr arrow.Array) groupKeyColumn {
+	name := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(field.Name)), uint64(len(field.Name)))
+	return groupKeyColumn{name: append(name, field.Name...), arr: arr}
 }
 
-func (u *uint64HashCombine) hashCombine(rhs uint64) uint64 {
-	return hashCombine(u.value, rhs)
-}
+// appendKey appends the encoded value of the column at index i to the group
+// key. Null values are not encoded.
+func (c *groupKeyColumn) appendKey(key []byte, i int) ([]byte, error) {
+	if c.arr.IsNull(i) {
+		return key, nil
+	}
+	key = append(key, c.name...)
+	if c.hashes != nil {
+		return binary.LittleEndian.AppendUint64(key, c.hashes[i]), nil
+	}
 
-// durationHashCombine hashes a given timestamp by dividing it through a given duration.
-// timestamp | duration | hash
-// 0 		 | 2		| 0
-// 1 		 | 2		| 0
-// 2 		 | 2		| 1
-// 3 		 | 2		| 1
-// 4 		 | 2		| 2
-// 5 		 | 2		| 2
-// Essentially hashing timestamps into buckets of durations.
-type durationHashCombine struct {
-	milliseconds uint64
+	switch arr := c.arr.(type) {
+	case *array.Int64:
+		v := uint64(arr.Value(i))
+		if c.milliseconds > 0 {
+			v /= c.milliseconds // floors by default
+		}
+		return binary.LittleEndian.AppendUint64(key, v), nil
+	case *array.Float64:
+		return append(key, arrow.Float64Traits.CastToBytes(arr.Float64Values()[i:i+1])...), nil
+	case *array.Float32:
+		return append(key, arrow.Float32Traits.CastToBytes(arr.Float32Values()[i:i+1])...), nil
+	case *array.Uint64:
+		return binary.LittleEndian.AppendUint64(key, arr.Value(i)), nil
+	case *array.Boolean:
+		if arr.Value(i) {
+			return append(key, 1), nil
+		}
+		return append(key, 0), nil
+	case *array.Binary:
+		return appendLengthPrefixed(key, arr.Value(i)), nil
+	case *array.String:
+		key = binary.AppendUvarint(key, uint64(arr.ValueLen(i)))
+		return append(key, arr.Value(i)...), nil
+	case *array.Dictionary:
+		switch dict := arr.Dictionary().(type) {
+		case *array.Binary:
+			return appendLengthPrefixed(key, dict.Value(arr.GetValueIndex(i))), nil
+		case *array.String:
+			v := dict.Value(arr.GetValueIndex(i))
+			key = binary.AppendUvarint(key, uint64(len(v)))
+			return append(key, v...), nil
+		default:
+			return nil, fmt.Errorf("unsupported dictionary type %T for group by", dict)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported array type %T for group by", arr)
+	}
 }
 
-func (d *durationHashCombine) hashCombine(rhs uint64) uint64 {
-	return rhs / d.milliseconds // floors by default
+func appendLengthPrefixed(key, v []byte) []byte {
+	key = binary.AppendUvarint(key, uint64(len(v)))
+	return append(key, v...)
 }
 
 func (a *HashAggregate) Callback(_ context.Context, r arrow.Record) error {
@@ -298,18 +412,18 @@ func (a *HashAggregate) Callback(_ context.Context, r arrow.Record) error {
 	// aggregate is the current aggregation
 	aggregate := a.aggregates[len(a.aggregates)-1]
 
-	fields := r.Schema().Fields() // NOTE: call Fields() once to avoid creating a copy each time
 	groupByFields := a.groupByFields
-	groupByFieldHashes := a.groupByFieldHashes
+	groupByKeyColumns := a.groupByKeyColumns
 	groupByArrays := a.groupByArrays
 
 	defer func() {
 		groupByFields = groupByFields[:0]
-		groupByFieldHashes = groupByFieldHashes[:0]
+		groupByKeyColumns = groupByKeyColumns[:0]
 		groupByArrays = groupByArrays[:0]
 	}()
 
 	columnToAggregate := make([]arrow.Array, len(aggregate.aggregations))
+	orderToAggregate := make([]arrow.Array, len(aggregate.aggregations))
 	concreteAggregateFieldsFound := 0
 	dynamicAggregateFieldsFound := 0
 
@@ -320,24 +434,13 @@ func (a *HashAggregate) Callback(_ context.Context, r arrow.Record) error {
 				groupByFields = append(groupByFields, field)
 				groupByArrays = append(groupByArrays, r.Column(i))
 
-				if a.finalStage { // in the final stage expect the hashes to already exist, so only need to combine them as normal hashes
-					groupByFieldHashes = append(groupByFieldHashes,
-						&uint64HashCombine{value: scalar.Hash(a.hashSeed, scalar.NewStringScalar(field.Name))},
-					)
-					continue
-				}
-
-				switch v := matcher.(type) {
-				case *logicalplan.DurationExpr:
-					duration := v.Value()
-					groupByFieldHashes = append(groupByFieldHashes,
-						&durationHashCombine{milliseconds: uint64(duration.Milliseconds())},
-					)
-				default:
-					groupByFieldHashes = append(groupByFieldHashes,
-						&uint64HashCombine{value: scalar.Hash(a.hashSeed, scalar.NewStringScalar(field.Name))},
-					)
+				col := newGroupKeyColumn(field, r.Column(i))
+				// In the final stage the timestamps are those of the first row
+				// of the groups, which fall into the same buckets.
+				if v, ok := matcher.(*logicalplan.DurationExpr); ok {
+					col.milliseconds = uint64(v.Value().Milliseconds())
 				}
+				groupByKeyColumns = append(groupByKeyColumns, col)
 			}
 		}
 
@@ -347,11 +450,14 @@ func (a *HashAggregate) Callback(_ context.Context, r arrow.Record) error {
 					if col.expr.MatchColumn(field.Name) {
 						// expand the aggregate.aggregations with a final concrete column aggregation.
 						columnToAggregate = append(columnToAggregate, nil)
+						orderToAggregate = append(orderToAggregate, nil)
 						aggregate.aggregations = append(aggregate.aggregations, Aggregation{
 							expr:       logicalplan.Col(field.Name),
 							dynamic:    true,
-							resultName: resultNameWithConcreteColumn(col.function, field.Name),
+							resultName: resultNameWithConcreteColumn(col.function, col.boundaries, col.orderBy, field.Name),
 							function:   col.function,
+							boundaries: col.boundaries,
+							orderBy:    col.orderBy,
 						})
 						aggregate.dynamicAggregationsConverted[field.Name] = struct{}{}
 					}
@@ -360,11 +466,14 @@ func (a *HashAggregate) Callback(_ context.Context, r arrow.Record) error {
 					if col.expr.MatchColumn(field.Name) {
 						// expand the aggregate.aggregations with a concrete column aggregation.
 						columnToAggregate = append(columnToAggregate, nil)
+						orderToAggregate = append(orderToAggregate, nil)
 						aggregate.aggregations = append(aggregate.aggregations, Aggregation{
 							expr:       logicalplan.Col(field.Name),
 							dynamic:    true,
 							resultName: field.Name, // Don't rename the column yet, we'll do that in the final stage. Dynamic aggregations can't match agains't the pre-computed name.
 							function:   col.function,
+							boundaries: col.boundaries,
+							orderBy:    col.orderBy,
 						})
 						aggregate.dynamicAggregationsConverted[field.Name] = struct{}{}
 					}
@@ -373,6 +482,15 @@ func (a *HashAggregate) Callback(_ context.Context, r arrow.Record) error {
 		}
 
 		for j, col := range aggregate.aggregations {
+			if col.orderBy != nil {
+				if a.finalStage {
+					if field.Name == orderColumnName(col.resultName) || (col.dynamic && field.Name == orderColumnName(col.expr.Name())) {
+						orderToAggregate[j] = r.Column(i)
+					}
+				} else if col.orderBy.MatchColumn(field.Name) {
+					orderToAggregate[j] = r.Column(i)
+				}
+			}
 			// If we're aggregating at the final stage we have previously
 			// renamed the pre-aggregated columns to their result names.
 			if a.finalStage {
@@ -404,48 +522,53 @@ func (a *HashAggregate) Callback(_ context.Context, r arrow.Record) error {
 		// or at least one dynamic column if performing dynamic aggregations.
 		return errors.New("aggregate field not found, aggregations are not possible without it")
 	}
+	for j, col := range aggregate.aggregations {
+		if col.orderBy != nil && columnToAggregate[j] != nil && orderToAggregate[j] == nil {
+			return fmt.Errorf("order by field of %s not found", col.resultName)
+		}
+	}
 
 	numRows := int(r.NumRows())
 
-	colHashes := make([][]uint64, len(groupByArrays))
+	// The groups are keyed by their values rather than the hashes passed
+	// forward by a previous stage, so that groups are only merged if their
+	// keys are equal. Lists can't be encoded, they are keyed by their hashes.
 	for i, arr := range groupByArrays {
-		col := dynparquet.FindHashedColumn(groupByFields[i].Name, fields)
-		if col != -1 {
-			vals := make([]uint64, 0, numRows)
-			for _, v := range r.Column(col).(*array.Int64).Int64Values() {
-				vals = append(vals, uint64(v))
-			}
-			colHashes[i] = vals
-		} else {
-			colHashes[i] = dynparquet.HashArray(arr)
+		if _, ok := arr.(*array.List); ok {
+			groupByKeyColumns[i].hashes = dynparquet.HashArray(arr)
 		}
 	}
 
 	for i := 0; i < numRows; i++ {
-		hash := uint64(0)
-		for j := range colHashes {
-			if colHashes[j][i] == 0 {
-				continue
+		key := a.groupKey[:0]
+		for j := range groupByKeyColumns {
+			var err error
+			if key, err = groupByKeyColumns[j].appendKey(key, i); err != nil {
+				return err
 			}
-
-			hash = hashCombine(
-				hash,
-				groupByFieldHashes[j].hashCombine(colHashes[j][i]),
-			)
 		}
+		a.groupKey = key
+		hash := a.hashKey(key)
 
-		tuple, ok := a.hashToAggregate[hash]
+		var (
+			tuple hashtuple
+			ok    bool
+		)
+		for _, t := range a.hashToAggregate[hash] {
+			if bytes.Equal(t.key, key) {
+				tuple, ok = t, true
+				break
+			}
+		}
 		if !ok {
 			aggregate = a.aggregates[len(a.aggregates)-1]
 			for j, col := range columnToAggregate {
-				agg := builder.NewBuilder(a.pool, col.DataType())
-				aggregate.aggregations[j].arrays = append(aggregate.aggregations[j].arrays, agg)
+				aggregate.aggregations[j].appendBuilders(a.pool, col, orderToAggregate[j])
 			}
 			tuple = hashtuple{
 				aggregate: len(a.aggregates) - 1, // always add new aggregates to the current aggregate
 				array:     len(aggregate.aggregations[0].arrays) - 1,
 			}
-			a.hashToAggregate[hash] = tuple
 			aggregate.rowCount++
 
 			// insert new row into columns grouped by and create new aggregate array to append to.
@@ -459,6 +582,10 @@ func (a *HashAggregate) Callback(_ context.Context, r arrow.Record) error {
 				for j := range columnToAggregate {
 					l := len(aggregate.aggregations[j].arrays)
 					aggregate.aggregations[j].arrays = aggregate.aggregations[j].arrays[:l-1]
+					if orderToAggregate[j] != nil {
+						l := len(aggregate.aggregations[j].orderArrays)
+						aggregate.aggregations[j].orderArrays = aggregate.aggregations[j].orderArrays[:l-1]
+					}
 				}
 
 				// Create new aggregation
@@ -468,6 +595,8 @@ func (a *HashAggregate) Callback(_ context.Context, r arrow.Record) error {
 						expr:       agg.expr,
 						resultName: agg.resultName,
 						function:   agg.function,
+						boundaries: agg.boundaries,
+						orderBy:    agg.orderBy,
 					})
 				}
 				a.aggregates = append(a.aggregates, &hashAggregate{
@@ -478,20 +607,22 @@ func (a *HashAggregate) Callback(_ context.Context, r arrow.Record) error {
 
 				aggregate = a.aggregates[len(a.aggregates)-1]
 				for j, col := range columnToAggregate {
-					agg := builder.NewBuilder(a.pool, col.DataType())
-					aggregate.aggregations[j].arrays = append(aggregate.aggregations[j].arrays, agg)
+					aggregate.aggregations[j].appendBuilders(a.pool, col, orderToAggregate[j])
 				}
 				tuple = hashtuple{
 					aggregate: len(a.aggregates) - 1, // always add new aggregates to the current aggregate
 					array:     len(aggregate.aggregations[0].arrays) - 1,
 				}
-				a.hashToAggregate[hash] = tuple
 				aggregate.rowCount++
 
 				if err := a.updateGroupByCols(i, groupByArrays, groupByFields); err != nil {
 					return err
 				}
 			}
+			// Groups with the same hash are chained, the key is copied since
+			// the buffer is reused for the next row.
+			tuple.key = bytes.Clone(key)
+			a.hashToAggregate[hash] = append(a.hashToAggregate[hash], tuple)
 		}
 
 		for j, col := range columnToAggregate {
@@ -503,12 +634,16 @@ func (a *HashAggregate) Callback(_ context.Context, r arrow.Record) error {
 				// This can happen with dynamic column aggregations without
 				// groupings. The group exists, but the array to append to does
 				// not.
-				agg := builder.NewBuilder(a.pool, col.DataType())
-				aggregate.aggregations[j].arrays = append(aggregate.aggregations[j].arrays, agg)
+				aggregate.aggregations[j].appendBuilders(a.pool, col, orderToAggregate[j])
 			}
 			if err := builder.AppendValue(a.aggregates[tuple.aggregate].aggregations[j].arrays[tuple.array], col, i); err != nil {
 				return err
 			}
+			if order := orderToAggregate[j]; order != nil {
+				if err := builder.AppendValue(a.aggregates[tuple.aggregate].aggregations[j].orderArrays[tuple.array], order, i); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
@@ -609,9 +744,11 @@ func (a *HashAggregate) finishAggregate(ctx context.Context, aggIdx int, aggrega
 				bldr := array.NewInt64Builder(a.pool)
 				defer bldr.Release()
 				sortedHashes := make([]int64, arr.Len())
-				for hash, tuple := range a.hashToAggregate {
-					if tuple.aggregate == aggIdx { // only append the hash for the current aggregate
-						sortedHashes[tuple.array] = int64(hash)
+				for hash, tuples := range a.hashToAggregate {
+					for _, tuple := range tuples {
+						if tuple.aggregate == aggIdx { // only append the hash for the current aggregate
+							sortedHashes[tuple.array] = int64(hash)
+						}
 					}
 				}
 				bldr.AppendValues(sortedHashes, nil)
@@ -624,12 +761,38 @@ func (a *HashAggregate) finishAggregate(ctx context.Context, aggIdx int, aggrega
 	aggregateFields := groupByFields
 
 	for _, aggregation := range aggregate.aggregations {
+		if aggregation.orderBy != nil {
+			valueArray, orderArray, err := a.finishOrderedAggregation(aggregation)
+			if err != nil {
+				return fmt.Errorf("aggregate batched arrays: %w", err)
+			}
+			groupByArrays = append(groupByArrays, valueArray)
+			aggregateFields = append(aggregateFields, arrow.Field{
+				Name: aggregation.resultName, Type: valueArray.DataType(),
+			})
+			if a.finalStage {
+				orderArray.Release()
+				continue
+			}
+			// Pass forward the orders of the values for the final stage to
+			// pick its values from the partial ones.
+			name := aggregation.resultName
+			if aggregation.dynamic {
+				name = aggregation.expr.Name()
+			}
+			groupByArrays = append(groupByArrays, orderArray)
+			aggregateFields = append(aggregateFields, arrow.Field{
+				Name: orderColumnName(name), Type: orderArray.DataType(),
+			})
+			continue
+		}
+
 		arr := make([]arrow.Array, 0, numRows)
 		for _, a := range aggregation.arrays {
 			arr = append(arr, a.NewArray())
 		}
 
-		aggregateArray, err := runAggregation(a.finalStage, aggregation.function, a.pool, arr)
+		aggregateArray, err := runAggregation(a.finalStage, aggregation.function, aggregation.boundaries, a.pool, arr)
 		for _, a := range arr {
 			a.Release()
 		}
@@ -643,11 +806,11 @@ func (a *HashAggregate) finishAggregate(ctx context.Context, aggIdx int, aggrega
 		})
 	}
 
-	r := array.NewRecord(
+	r := NewRefRecord(array.NewRecord(
 		arrow.NewSchema(aggregateFields, nil),
 		groupByArrays,
 		int64(numRows),
-	)
+	))
 	defer r.Release()
 	err := a.next.Callback(ctx, r)
 	if err != nil {
@@ -659,7 +822,10 @@ func (a *HashAggregate) finishAggregate(ctx context.Context, aggIdx int, aggrega
 
 type SumAggregation struct{}
 
-var ErrUnsupportedSumType = errors.New("unsupported type for sum aggregation, expected int64 or float64")
+var (
+	ErrUnsupportedSumType = errors.New("unsupported type for sum aggregation, expected int64, uint64, float32 or float64")
+	ErrSumOverflow        = errors.New("sum aggregation overflows")
+)
 
 func (a *SumAggregation) Aggregate(pool memory.Allocator, arrs []arrow.Array) (arrow.Array, error) {
 	if len(arrs) == 0 {
@@ -672,6 +838,10 @@ func (a *SumAggregation) Aggregate(pool memory.Allocator, arrs []arrow.Array) (a
 		return sumInt64arrays(pool, arrs), nil
 	case arrow.FLOAT64:
 		return sumFloat64arrays(pool, arrs), nil
+	case arrow.FLOAT32:
+		return sumFloat32arrays(pool, arrs), nil
+	case arrow.UINT64:
+		return sumUint64arrays(pool, arrs)
 	default:
 		return nil, fmt.Errorf("sum array of %s: %w", typ, ErrUnsupportedSumType)
 	}
@@ -691,6 +861,37 @@ func sumInt64array(arr *array.Int64) int64 {
 	return math.Int64.Sum(arr)
 }
 
+func sumUint64arrays(pool memory.Allocator, arrs []arrow.Array) (arrow.Array, error) {
+	res := array.NewUint64Builder(pool)
+	defer res.Release()
+	for _, arr := range arrs {
+		sum, err := sumUint64array(arr.(*array.Uint64))
+		if err != nil {
+			return nil, err
+		}
+		res.Append(sum)
+	}
+
+	return res.NewArray(), nil
+}
+
+// sumUint64array sums the values of arr. Unlike int64 sums, which wrap
+// around, an error is returned if the sum overflows uint64 as unsigned values
+// are typically counters, for which a wrapped sum would look valid.
+func sumUint64array(arr *array.Uint64) (uint64, error) {
+	var sum, carry uint64
+	for i, v := range arr.Uint64Values() {
+		if arr.IsNull(i) {
+			continue
+		}
+		sum, carry = bits.Add64(sum, v, 0)
+		if carry != 0 {
+			return 0, fmt.Errorf("sum of uint64 values: %w", ErrSumOverflow)
+		}
+	}
+	return sum, nil
+}
+
 func sumFloat64arrays(pool memory.Allocator, arrs []arrow.Array) arrow.Array {
 	res := array.NewFloat64Builder(pool)
 	defer res.Release()
@@ -705,7 +906,29 @@ func sumFloat64array(arr *array.Float64) float64 {
 	return math.Float64.Sum(arr)
 }
 
-var ErrUnsupportedMinType = errors.New("unsupported type for max aggregation, expected int64 or float64")
+func sumFloat32arrays(pool memory.Allocator, arrs []arrow.Array) arrow.Array {
+	res := array.NewFloat32Builder(pool)
+	defer res.Release()
+	for _, arr := range arrs {
+		res.Append(sumFloat32array(arr.(*array.Float32)))
+	}
+
+	return res.NewArray()
+}
+
+// sumFloat32array sums the values of arr. The sum is accumulated in float64 to
+// avoid losing precision on large arrays.
+func sumFloat32array(arr *array.Float32) float32 {
+	var sum float64
+	for i, v := range arr.Float32Values() {
+		if arr.IsValid(i) {
+			sum += float64(v)
+		}
+	}
+	return float32(sum)
+}
+
+var ErrUnsupportedMinType = errors.New("unsupported type for min aggregation, expected int64, uint64, float32 or float64")
 
 type MinAggregation struct{}
 
@@ -720,6 +943,10 @@ func (a *MinAggregation) Aggregate(pool memory.Allocator, arrs []arrow.Array) (a
 		return minInt64arrays(pool, arrs), nil
 	case arrow.FLOAT64:
 		return minFloat64arrays(pool, arrs), nil
+	case arrow.FLOAT32:
+		return minFloat32arrays(pool, arrs), nil
+	case arrow.UINT64:
+		return minUint64arrays(pool, arrs), nil
 	default:
 		return nil, fmt.Errorf("min array of %s: %w", typ, ErrUnsupportedMinType)
 	}
@@ -755,6 +982,34 @@ func minInt64array(arr *array.Int64) int64 {
 	return min
 }
 
+func minUint64arrays(pool memory.Allocator, arrs []arrow.Array) arrow.Array {
+	res := array.NewUint64Builder(pool)
+	defer res.Release()
+	for _, arr := range arrs {
+		if arr.Len() == 0 {
+			res.AppendNull()
+			continue
+		}
+		res.Append(minUint64array(arr.(*array.Uint64)))
+	}
+
+	return res.NewArray()
+}
+
+// Same as minInt64array but for Uint64.
+func minUint64array(arr *array.Uint64) uint64 {
+	// Note that the zero-length check must be performed before calling this
+	// function.
+	vals := arr.Uint64Values()
+	min := vals[0]
+	for _, v := range vals {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
+
 func minFloat64arrays(pool memory.Allocator, arrs []arrow.Array) arrow.Array {
 	res := array.NewFloat64Builder(pool)
 	defer res.Release()
@@ -783,9 +1038,37 @@ func minFloat64array(arr *array.Float64) float64 {
 	return min
 }
 
+func minFloat32arrays(pool memory.Allocator, arrs []arrow.Array) arrow.Array {
+	res := array.NewFloat32Builder(pool)
+	defer res.Release()
+	for _, arr := range arrs {
+		if arr.Len() == 0 {
+			res.AppendNull()
+			continue
+		}
+		res.Append(minFloat32array(arr.(*array.Float32)))
+	}
+
+	return res.NewArray()
+}
+
+// Same as minInt64array but for Float32.
+func minFloat32array(arr *array.Float32) float32 {
+	// Note that the zero-length check must be performed before calling this
+	// function.
+	vals := arr.Float32Values()
+	min := vals[0]
+	for _, v := range vals {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
+
 type MaxAggregation struct{}
 
-var ErrUnsupportedMaxType = errors.New("unsupported type for max aggregation, expected int64 or float64")
+var ErrUnsupportedMaxType = errors.New("unsupported type for max aggregation, expected int64, uint64, float32 or float64")
 
 func (a *MaxAggregation) Aggregate(pool memory.Allocator, arrs []arrow.Array) (arrow.Array, error) {
 	if len(arrs) == 0 {
@@ -798,6 +1081,10 @@ func (a *MaxAggregation) Aggregate(pool memory.Allocator, arrs []arrow.Array) (a
 		return maxInt64arrays(pool, arrs), nil
 	case arrow.FLOAT64:
 		return maxFloat64arrays(pool, arrs), nil
+	case arrow.FLOAT32:
+		return maxFloat32arrays(pool, arrs), nil
+	case arrow.UINT64:
+		return maxUint64arrays(pool, arrs), nil
 	default:
 		return nil, fmt.Errorf("max array of %s: %w", typ, ErrUnsupportedMaxType)
 	}
@@ -833,6 +1120,33 @@ func maxInt64array(arr *array.Int64) int64 {
 	return max
 }
 
+func maxUint64arrays(pool memory.Allocator, arrs []arrow.Array) arrow.Array {
+	res := array.NewUint64Builder(pool)
+	defer res.Release()
+	for _, arr := range arrs {
+		if arr.Len() == 0 {
+			res.AppendNull()
+			continue
+		}
+		res.Append(maxUint64array(arr.(*array.Uint64)))
+	}
+
+	return res.NewArray()
+}
+
+func maxUint64array(arr *array.Uint64) uint64 {
+	// Note that the zero-length check must be performed before calling this
+	// function.
+	vals := arr.Uint64Values()
+	max := vals[0]
+	for _, v := range vals {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
 func maxFloat64arrays(pool memory.Allocator, arrs []arrow.Array) arrow.Array {
 	res := array.NewFloat64Builder(pool)
 	defer res.Release()
@@ -860,6 +1174,33 @@ func maxFloat64array(arr *array.Float64) float64 {
 	return max
 }
 
+func maxFloat32arrays(pool memory.Allocator, arrs []arrow.Array) arrow.Array {
+	res := array.NewFloat32Builder(pool)
+	defer res.Release()
+	for _, arr := range arrs {
+		if arr.Len() == 0 {
+			res.AppendNull()
+			continue
+		}
+		res.Append(maxFloat32array(arr.(*array.Float32)))
+	}
+
+	return res.NewArray()
+}
+
+func maxFloat32array(arr *array.Float32) float32 {
+	// Note that the zero-length check must be performed before calling this
+	// function.
+	vals := arr.Float32Values()
+	max := vals[0]
+	for _, v := range vals {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
 type CountAggregation struct{}
 
 func (a *CountAggregation) Aggregate(pool memory.Allocator, arrs []arrow.Array) (arrow.Array, error) {
@@ -875,15 +1216,337 @@ func (a *CountAggregation) Aggregate(pool memory.Allocator, arrs []arrow.Array)
 	return res.NewArray(), nil
 }
 
+// HistogramAggregation counts the values of each group in the buckets
+// delimited by its boundaries. The counts of a group are a list with a count
+// per bucket. Lists of counts, e.g. the results of previous stages of the
+// aggregation, are merged by summing the counts of each bucket.
+type HistogramAggregation struct {
+	boundaries []float64
+}
+
+var ErrUnsupportedHistogramType = errors.New("unsupported type for histogram aggregation, expected int64, uint64, float32 or float64")
+
+func (a *HistogramAggregation) Aggregate(pool memory.Allocator, arrs []arrow.Array) (arrow.Array, error) {
+	res := array.NewListBuilder(pool, arrow.PrimitiveTypes.Int64)
+	defer res.Release()
+	values := res.ValueBuilder().(*array.Int64Builder)
+
+	counts := make([]int64, len(a.boundaries)+1)
+	for _, arr := range arrs {
+		clear(counts)
+		switch arr := arr.(type) {
+		case *array.Int64:
+			for i := 0; i < arr.Len(); i++ {
+				if arr.IsValid(i) {
+					counts[a.bucket(float64(arr.Value(i)))]++
+				}
+			}
+		case *array.Float64:
+			for i := 0; i < arr.Len(); i++ {
+				if arr.IsValid(i) {
+					counts[a.bucket(arr.Value(i))]++
+				}
+			}
+		case *array.Float32:
+			for i := 0; i < arr.Len(); i++ {
+				if arr.IsValid(i) {
+					counts[a.bucket(float64(arr.Value(i)))]++
+				}
+			}
+		case *array.Uint64:
+			for i := 0; i < arr.Len(); i++ {
+				if arr.IsValid(i) {
+					counts[a.bucket(float64(arr.Value(i)))]++
+				}
+			}
+		case *array.List:
+			if err := a.merge(counts, arr); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("histogram array of %s: %w", arr.DataType(), ErrUnsupportedHistogramType)
+		}
+		res.Append(true)
+		values.AppendValues(counts, nil)
+	}
+	return res.NewArray(), nil
+}
+
+// bucket returns the index of the bucket of the value.
+func (a *HistogramAggregation) bucket(v float64) int {
+	return sort.SearchFloat64s(a.boundaries, v)
+}
+
+// merge adds the counts of the lists of the array to counts.
+func (a *HistogramAggregation) merge(counts []int64, arr *array.List) error {
+	partial, ok := arr.ListValues().(*array.Int64)
+	if !ok {
+		return fmt.Errorf("histogram counts of %s: %w", arr.ListValues().DataType(), ErrUnsupportedHistogramType)
+	}
+	for i := 0; i < arr.Len(); i++ {
+		if arr.IsNull(i) {
+			continue
+		}
+		start, end := arr.ValueOffsets(i)
+		if int(end-start) != len(counts) {
+			return fmt.Errorf("histogram has %d buckets, expected %d", end-start, len(counts))
+		}
+		for j := range counts {
+			counts[j] += partial.Value(int(start) + j)
+		}
+	}
+	return nil
+}
+
+// AnyValueAggregation picks the first non-null value of each group. The value
+// is null if all the values of a group are null.
+type AnyValueAggregation struct{}
+
+func (a *AnyValueAggregation) Aggregate(pool memory.Allocator, arrs []arrow.Array) (arrow.Array, error) {
+	res := builder.NewBuilder(pool, arrs[0].DataType())
+	defer res.Release()
+	for _, arr := range arrs {
+		found := false
+		for i := 0; i < arr.Len(); i++ {
+			if arr.IsValid(i) {
+				if err := builder.AppendValue(res, arr, i); err != nil {
+					return nil, err
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			res.AppendNull()
+		}
+	}
+	return res.NewArray(), nil
+}
+
+// VarianceAggregation computes the sample variance, or the sample standard
+// deviation, of the values of each group. Values are accumulated with
+// Welford's algorithm. Stages that are not final return the count, mean and
+// sum of squared differences from the mean of each group as a list, which the
+// final stage merges with Chan's parallel algorithm before computing the
+// result. The result is null for groups of less than two values.
+type VarianceAggregation struct {
+	stddev bool
+	// partial is set if the aggregation is not the final stage.
+	partial bool
+}
+
+var ErrUnsupportedVarianceType = errors.New("unsupported type for variance aggregation, expected int64, uint64, float32 or float64")
+
+// varianceState is the state of a variance aggregation of a group.
+type varianceState struct {
+	count float64
+	mean  float64
+	m2    float64
+}
+
+func (s *varianceState) add(v float64) {
+	s.count++
+	delta := v - s.mean
+	s.mean += delta / s.count
+	s.m2 += delta * (v - s.mean)
+}
+
+func (s *varianceState) merge(o varianceState) {
+	if o.count == 0 {
+		return
+	}
+	count := s.count + o.count
+	delta := o.mean - s.mean
+	s.mean += delta * o.count / count
+	s.m2 += o.m2 + delta*delta*s.count*o.count/count
+	s.count = count
+}
+
+func (a *VarianceAggregation) Aggregate(pool memory.Allocator, arrs []arrow.Array) (arrow.Array, error) {
+	if a.partial {
+		res := array.NewListBuilder(pool, arrow.PrimitiveTypes.Float64)
+		defer res.Release()
+		values := res.ValueBuilder().(*array.Float64Builder)
+		for _, arr := range arrs {
+			state, err := a.aggregate(arr)
+			if err != nil {
+				return nil, err
+			}
+			res.Append(true)
+			values.AppendValues([]float64{state.count, state.mean, state.m2}, nil)
+		}
+		return res.NewArray(), nil
+	}
+
+	res := array.NewFloat64Builder(pool)
+	defer res.Release()
+	for _, arr := range arrs {
+		state, err := a.aggregate(arr)
+		if err != nil {
+			return nil, err
+		}
+		if state.count < 2 {
+			res.AppendNull()
+			continue
+		}
+		variance := state.m2 / (state.count - 1)
+		if a.stddev {
+			variance = gomath.Sqrt(variance)
+		}
+		res.Append(variance)
+	}
+	return res.NewArray(), nil
+}
+
+// aggregate returns the state of the values of a group, or of the merged
+// states of previous stages.
+func (a *VarianceAggregation) aggregate(arr arrow.Array) (varianceState, error) {
+	var state varianceState
+	switch arr := arr.(type) {
+	case *array.Int64:
+		for i := 0; i < arr.Len(); i++ {
+			if arr.IsValid(i) {
+				state.add(float64(arr.Value(i)))
+			}
+		}
+	case *array.Float64:
+		for i := 0; i < arr.Len(); i++ {
+			if arr.IsValid(i) {
+				state.add(arr.Value(i))
+			}
+		}
+	case *array.Float32:
+		for i := 0; i < arr.Len(); i++ {
+			if arr.IsValid(i) {
+				state.add(float64(arr.Value(i)))
+			}
+		}
+	case *array.Uint64:
+		for i := 0; i < arr.Len(); i++ {
+			if arr.IsValid(i) {
+				state.add(float64(arr.Value(i)))
+			}
+		}
+	case *array.List:
+		partial, ok := arr.ListValues().(*array.Float64)
+		if !ok {
+			return state, fmt.Errorf("variance states of %s: %w", arr.ListValues().DataType(), ErrUnsupportedVarianceType)
+		}
+		for i := 0; i < arr.Len(); i++ {
+			if arr.IsNull(i) {
+				continue
+			}
+			start, end := arr.ValueOffsets(i)
+			if end-start != 3 {
+				return state, fmt.Errorf("variance state has %d values, expected 3", end-start)
+			}
+			state.merge(varianceState{
+				count: partial.Value(int(start)),
+				mean:  partial.Value(int(start) + 1),
+				m2:    partial.Value(int(start) + 2),
+			})
+		}
+	default:
+		return state, fmt.Errorf("variance of %s: %w", arr.DataType(), ErrUnsupportedVarianceType)
+	}
+	return state, nil
+}
+
+// finishOrderedAggregation picks the value of each group of a first or last
+// aggregation. It returns the picked values and their orders.
+func (a *HashAggregate) finishOrderedAggregation(aggregation Aggregation) (arrow.Array, arrow.Array, error) {
+	values := make([]arrow.Array, 0, len(aggregation.arrays))
+	orders := make([]arrow.Array, 0, len(aggregation.orderArrays))
+	defer func() {
+		for _, arr := range values {
+			arr.Release()
+		}
+		for _, arr := range orders {
+			arr.Release()
+		}
+	}()
+	for _, b := range aggregation.arrays {
+		values = append(values, b.NewArray())
+	}
+	for _, b := range aggregation.orderArrays {
+		orders = append(orders, b.NewArray())
+	}
+	return pickByOrder(a.pool, aggregation.function == logicalplan.AggFuncLast, values, orders)
+}
+
+var ErrUnsupportedOrderType = errors.New("unsupported type for order of first or last aggregation, expected int64, uint64, float32 or float64")
+
+// pickByOrder picks the value of each of the given arrays with the lowest
+// order, or the highest order if last is true. Values with null orders are
+// skipped. Of values with equal orders the first one is picked, or the last
+// one if last is true. It returns the picked values and their orders.
+func pickByOrder(pool memory.Allocator, last bool, values, orders []arrow.Array) (arrow.Array, arrow.Array, error) {
+	if len(values) == 0 {
+		return array.NewInt64Builder(pool).NewArray(), array.NewInt64Builder(pool).NewArray(), nil
+	}
+	if len(values) != len(orders) {
+		return nil, nil, fmt.Errorf("expected %d order arrays, got %d", len(values), len(orders))
+	}
+
+	valueBuilder := builder.NewBuilder(pool, values[0].DataType())
+	defer valueBuilder.Release()
+	orderBuilder := builder.NewBuilder(pool, orders[0].DataType())
+	defer orderBuilder.Release()
+	for i, order := range orders {
+		var idx int
+		switch arr := order.(type) {
+		case *array.Int64:
+			idx = pickIndex(arr, arr.Value, last)
+		case *array.Float64:
+			idx = pickIndex(arr, arr.Value, last)
+		case *array.Float32:
+			idx = pickIndex(arr, arr.Value, last)
+		case *array.Uint64:
+			idx = pickIndex(arr, arr.Value, last)
+		default:
+			return nil, nil, ErrUnsupportedOrderType
+		}
+		if idx < 0 {
+			valueBuilder.AppendNull()
+			orderBuilder.AppendNull()
+			continue
+		}
+		if err := builder.AppendValue(valueBuilder, values[i], idx); err != nil {
+			return nil, nil, err
+		}
+		if err := builder.AppendValue(orderBuilder, order, idx); err != nil {
+			return nil, nil, err
+		}
+	}
+	return valueBuilder.NewArray(), orderBuilder.NewArray(), nil
+}
+
+// pickIndex returns the index of the lowest, or the highest if last is true,
+// non-null value of the array, or -1 if all its values are null.
+func pickIndex[T int64 | uint64 | float32 | float64](arr arrow.Array, value func(int) T, last bool) int {
+	idx := -1
+	var picked T
+	for i := 0; i < arr.Len(); i++ {
+		if arr.IsNull(i) {
+			continue
+		}
+		v := value(i)
+		if idx < 0 || (last && v >= picked) || (!last && v < picked) {
+			idx, picked = i, v
+		}
+	}
+	return idx
+}
+
 // runAggregation is a helper to run the given aggregation function given
 // the set of values. It is aware of the final stage and chooses the aggregation
 // function appropriately.
-func runAggregation(finalStage bool, fn logicalplan.AggFunc, pool memory.Allocator, arrs []arrow.Array) (arrow.Array, error) {
+func runAggregation(finalStage bool, fn logicalplan.AggFunc, boundaries []float64, pool memory.Allocator, arrs []arrow.Array) (arrow.Array, error) {
 	if len(arrs) == 0 {
 		return array.NewInt64Builder(pool).NewArray(), nil
 	}
 
-	aggFunc, err := chooseAggregationFunction(fn, arrs[0].DataType())
+	aggFunc, err := chooseAggregationFunction(fn, boundaries, arrs[0].DataType())
 	if err != nil {
 		return nil, err
 	}
@@ -893,10 +1556,13 @@ func runAggregation(finalStage bool, fn logicalplan.AggFunc, pool memory.Allocat
 		// previous steps, instead of counting the previous counts.
 		return (&SumAggregation{}).Aggregate(pool, arrs)
 	}
+	if v, ok := aggFunc.(*VarianceAggregation); ok {
+		v.partial = !finalStage
+	}
 	return aggFunc.Aggregate(pool, arrs)
 }
 
-func resultNameWithConcreteColumn(function logicalplan.AggFunc, col string) string {
+func resultNameWithConcreteColumn(function logicalplan.AggFunc, boundaries []float64, orderBy logicalplan.Expr, col string) string {
 	switch function {
 	case logicalplan.AggFuncSum:
 		return logicalplan.Sum(logicalplan.Col(col)).Name()
@@ -908,6 +1574,18 @@ func resultNameWithConcreteColumn(function logicalplan.AggFunc, col string) stri
 		return logicalplan.Count(logicalplan.Col(col)).Name()
 	case logicalplan.AggFuncAvg:
 		return logicalplan.Avg(logicalplan.Col(col)).Name()
+	case logicalplan.AggFuncHistogram:
+		return logicalplan.Histogram(logicalplan.Col(col), boundaries...).Name()
+	case logicalplan.AggFuncFirst:
+		return logicalplan.First(logicalplan.Col(col)).OrderBy(orderBy).Name()
+	case logicalplan.AggFuncLast:
+		return logicalplan.Last(logicalplan.Col(col)).OrderBy(orderBy).Name()
+	case logicalplan.AggFuncStddev:
+		return logicalplan.Stddev(logicalplan.Col(col)).Name()
+	case logicalplan.AggFuncVariance:
+		return logicalplan.Variance(logicalplan.Col(col)).Name()
+	case logicalplan.AggFuncAnyValue:
+		return logicalplan.AnyValue(logicalplan.Col(col)).Name()
 	default:
 		return ""
 	}
@@ -3,6 +3,7 @@ package physicalplan
 import (
 	"context"
 	"encoding/binary"
+	"fmt"
 	"hash/maphash"
 	"math/rand"
 	"testing"
@@ -115,3 +116,157 @@ func Test_Aggregate_ArrayOverflow(t *testing.T) {
 	require.NoError(t, agg.Finish(ctx))
 	require.Equal(t, int64(n*rows), totalRows)
 }
+
+func Test_Aggregate_GroupKeys(t *testing.T) {
+	ctx := context.Background()
+	allocator := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer allocator.AssertSize(t, 0)
+
+	agg := NewHashAggregate(
+		allocator,
+		trace.NewNoopTracerProvider().Tracer(""),
+		[]Aggregation{
+			{
+				expr:       logicalplan.Col("value"),
+				resultName: "result",
+				function:   logicalplan.AggFuncSum,
+			},
+		},
+		[]logicalplan.Expr{
+			logicalplan.Col("id"),
+			logicalplan.Col("a"),
+			logicalplan.Col("b"),
+		},
+		maphash.MakeSeed(),
+		false,
+	)
+
+	results := map[string]int64{}
+	agg.SetNext(&OutputPlan{
+		callback: func(_ context.Context, r arrow.Record) error {
+			ids := r.Column(r.Schema().FieldIndices("id")[0]).(*array.Int64)
+			as := r.Column(r.Schema().FieldIndices("a")[0]).(*array.Binary)
+			bs := r.Column(r.Schema().FieldIndices("b")[0]).(*array.Binary)
+			values := r.Column(r.Schema().FieldIndices("result")[0]).(*array.Int64)
+			for i := 0; i < int(r.NumRows()); i++ {
+				id := "null"
+				if ids.IsValid(i) {
+					id = fmt.Sprint(ids.Value(i))
+				}
+				results[fmt.Sprintf("%s/%s/%s", id, as.Value(i), bs.Value(i))] = values.Value(i)
+			}
+			return nil
+		},
+	})
+
+	fields := []arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+		{Name: "a", Type: arrow.BinaryTypes.Binary},
+		{Name: "b", Type: arrow.BinaryTypes.Binary},
+		{Name: "value", Type: arrow.PrimitiveTypes.Int64},
+	}
+	ids := array.NewInt64Builder(allocator)
+	defer ids.Release()
+	ids.AppendValues([]int64{0, 0, 0, 1, 0}, []bool{true, false, true, true, true})
+	as := array.NewBinaryBuilder(allocator, arrow.BinaryTypes.Binary)
+	defer as.Release()
+	as.AppendStringValues([]string{"ab", "ab", "ab", "ab", "a"}, nil)
+	bs := array.NewBinaryBuilder(allocator, arrow.BinaryTypes.Binary)
+	defer bs.Release()
+	bs.AppendStringValues([]string{"c", "c", "c", "c", "bc"}, nil)
+	values := array.NewInt64Builder(allocator)
+	defer values.Release()
+	values.AppendValues([]int64{1, 2, 3, 4, 5}, nil)
+
+	r := array.NewRecord(
+		arrow.NewSchema(fields, nil),
+		[]arrow.Array{ids.NewArray(), as.NewArray(), bs.NewArray(), values.NewArray()},
+		5,
+	)
+	for _, col := range r.Columns() {
+		col.Release()
+	}
+	defer r.Release()
+
+	require.NoError(t, agg.Callback(ctx, r))
+	require.NoError(t, agg.Finish(ctx))
+	agg.Close()
+
+	// Zero values are not grouped with null values, and the boundaries of
+	// variable length values are part of the group keys.
+	require.Equal(t, map[string]int64{
+		"0/ab/c":    4,
+		"null/ab/c": 2,
+		"1/ab/c":    4,
+		"0/a/bc":    5,
+	}, results)
+}
+
+func Test_Aggregate_HashCollision(t *testing.T) {
+	ctx := context.Background()
+	allocator := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer allocator.AssertSize(t, 0)
+
+	newAggregate := func(final bool) *HashAggregate {
+		agg := NewHashAggregate(
+			allocator,
+			trace.NewNoopTracerProvider().Tracer(""),
+			[]Aggregation{
+				{
+					expr:       logicalplan.Col("value"),
+					resultName: "result",
+					function:   logicalplan.AggFuncSum,
+				},
+			},
+			[]logicalplan.Expr{logicalplan.Col("id")},
+			maphash.MakeSeed(),
+			final,
+		)
+		// All groups collide, in the partial and in the final stage.
+		agg.hashKey = func([]byte) uint64 { return 0 }
+		return agg
+	}
+	partial, final := newAggregate(false), newAggregate(true)
+	defer partial.Close()
+	defer final.Close()
+	partial.SetNext(final)
+
+	results := map[int64]int64{}
+	final.SetNext(&OutputPlan{
+		callback: func(_ context.Context, r arrow.Record) error {
+			ids := r.Column(r.Schema().FieldIndices("id")[0]).(*array.Int64)
+			values := r.Column(r.Schema().FieldIndices("result")[0]).(*array.Int64)
+			for i := 0; i < int(r.NumRows()); i++ {
+				results[ids.Value(i)] = values.Value(i)
+			}
+			return nil
+		},
+	})
+
+	fields := []arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "value", Type: arrow.PrimitiveTypes.Int64},
+	}
+	ids := array.NewInt64Builder(allocator)
+	defer ids.Release()
+	ids.AppendValues([]int64{1, 2, 1, 3, 2}, nil)
+	values := array.NewInt64Builder(allocator)
+	defer values.Release()
+	values.AppendValues([]int64{1, 2, 3, 4, 5}, nil)
+
+	r := array.NewRecord(
+		arrow.NewSchema(fields, nil),
+		[]arrow.Array{ids.NewArray(), values.NewArray()},
+		5,
+	)
+	for _, col := range r.Columns() {
+		col.Release()
+	}
+	defer r.Release()
+
+	require.NoError(t, partial.Callback(ctx, r))
+	require.NoError(t, partial.Finish(ctx))
+
+	// Groups with the same hash are not merged.
+	require.Equal(t, map[int64]int64{1: 4, 2: 7, 3: 4}, results)
+}
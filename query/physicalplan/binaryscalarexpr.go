@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math/bits"
+	"strings"
 
 	"github.com/apache/arrow/go/v14/arrow"
 	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/bitutil"
 	"github.com/apache/arrow/go/v14/arrow/scalar"
 
 	"github.com/polarsignals/frostdb/query/logicalplan"
@@ -36,40 +39,69 @@ type BinaryScalarExpr struct {
 }
 
 func (e BinaryScalarExpr) Eval(r arrow.Record) (*Bitmap, error) {
+	res := NewBitmap()
+	if err := e.EvalInto(r, res, nil); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (e BinaryScalarExpr) EvalInto(r arrow.Record, res *Bitmap, _ *BitmapPool) error {
 	leftData, exists, err := e.Left.ArrowArray(r)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if !exists {
-		res := NewBitmap()
 		switch e.Op {
 		case logicalplan.OpEq:
 			if e.Right.IsValid() { // missing column; looking for == non-nil
 				switch t := e.Right.(type) {
 				case *scalar.Binary:
 					if t.String() != "" { // treat empty string equivalent to nil
-						return res, nil
+						return nil
 					}
 				case *scalar.String:
 					if t.String() != "" { // treat empty string equivalent to nil
-						return res, nil
+						return nil
 					}
 				}
 			}
 		case logicalplan.OpNotEq: // missing column; looking for != nil
 			if !e.Right.IsValid() {
-				return res, nil
+				return nil
 			}
 		case logicalplan.OpLt, logicalplan.OpLtEq, logicalplan.OpGt, logicalplan.OpGtEq:
-			return res, nil
+			return nil
 		}
 
 		res.AddRange(0, uint64(r.NumRows()))
-		return res, nil
+		return nil
 	}
 
-	return BinaryScalarOperation(leftData, e.Right, e.Op)
+	if leftData.Len() > 0 && leftData.NullN() == leftData.Len() {
+		return evalAllNull(res, leftData, func(res *Bitmap, arr arrow.Array) error {
+			return BinaryScalarOperation(res, arr, e.Right, e.Op)
+		})
+	}
+
+	return BinaryScalarOperation(res, leftData, e.Right, e.Op)
+}
+
+// evalAllNull evaluates a comparison on an array of null values, which is
+// common for sparse dynamic columns. The values all compare the same, so the
+// comparison is only evaluated on the first one and the result is either
+// empty or contains all rows.
+func evalAllNull(res *Bitmap, arr arrow.Array, eval func(*Bitmap, arrow.Array) error) error {
+	first := array.NewSlice(arr, 0, 1)
+	defer first.Release()
+	if err := eval(res, first); err != nil {
+		return err
+	}
+	if res.Contains(0) {
+		res.AddRange(0, uint64(arr.Len()))
+	}
+	return nil
 }
 
 func (e BinaryScalarExpr) String() string {
@@ -78,75 +110,188 @@ func (e BinaryScalarExpr) String() string {
 
 var ErrUnsupportedBinaryOperation = errors.New("unsupported binary operation")
 
-func BinaryScalarOperation(left arrow.Array, right scalar.Scalar, operator logicalplan.Op) (*Bitmap, error) {
+func BinaryScalarOperation(res *Bitmap, left arrow.Array, right scalar.Scalar, operator logicalplan.Op) error {
 	leftType := left.DataType()
+	unsupported := func() error {
+		return fmt.Errorf("%w: %s %s %v", ErrUnsupportedBinaryOperation, leftType, operator, right)
+	}
+	if right == scalar.ScalarNull {
+		switch operator {
+		case logicalplan.OpEq:
+			arrayNullEqual(res, left, true)
+			return nil
+		case logicalplan.OpNotEq:
+			arrayNullEqual(res, left, false)
+			return nil
+		default:
+			return unsupported()
+		}
+	}
 	switch leftType {
 	case arrow.FixedWidthTypes.Boolean:
+		r, ok := right.(*scalar.Boolean)
+		if !ok {
+			return unsupported()
+		}
 		switch operator {
 		case logicalplan.OpEq:
-			return BooleanArrayScalarEqual(left.(*array.Boolean), right.(*scalar.Boolean))
+			return BooleanArrayScalarEqual(res, left.(*array.Boolean), r)
 		case logicalplan.OpNotEq:
-			return BooleanArrayScalarNotEqual(left.(*array.Boolean), right.(*scalar.Boolean))
+			return BooleanArrayScalarNotEqual(res, left.(*array.Boolean), r)
 		default:
-			panic("something terrible has happened, this should have errored previously during validation")
+			return unsupported()
 		}
 	case &arrow.FixedSizeBinaryType{ByteWidth: 16}:
+		r, ok := right.(*scalar.FixedSizeBinary)
+		if !ok {
+			return unsupported()
+		}
 		switch operator {
 		case logicalplan.OpEq:
-			return FixedSizeBinaryArrayScalarEqual(left.(*array.FixedSizeBinary), right.(*scalar.FixedSizeBinary))
+			return FixedSizeBinaryArrayScalarEqual(res, left.(*array.FixedSizeBinary), r)
 		case logicalplan.OpNotEq:
-			return FixedSizeBinaryArrayScalarNotEqual(left.(*array.FixedSizeBinary), right.(*scalar.FixedSizeBinary))
+			return FixedSizeBinaryArrayScalarNotEqual(res, left.(*array.FixedSizeBinary), r)
 		default:
-			panic("something terrible has happened, this should have errored previously during validation")
+			return unsupported()
 		}
 	case arrow.BinaryTypes.String:
+		r, ok := right.(*scalar.String)
+		if !ok {
+			return unsupported()
+		}
 		switch operator {
 		case logicalplan.OpEq:
-			return StringArrayScalarEqual(left.(*array.String), right.(*scalar.String))
+			return StringArrayScalarEqual(res, left.(*array.String), r)
 		case logicalplan.OpNotEq:
-			return StringArrayScalarNotEqual(left.(*array.String), right.(*scalar.String))
+			return StringArrayScalarNotEqual(res, left.(*array.String), r)
+		case logicalplan.OpLt, logicalplan.OpLtEq, logicalplan.OpGt, logicalplan.OpGtEq:
+			stringArrayScalarCompare(res, left.(*array.String), r, orderedComparison(operator))
+			return nil
 		default:
-			panic("something terrible has happened, this should have errored previously during validation")
+			return unsupported()
 		}
 	case arrow.BinaryTypes.Binary:
+		var r *scalar.Binary
+		switch s := right.(type) {
+		case *scalar.Binary:
+			r = s
+		case *scalar.String:
+			r = s.Binary
+		default:
+			return unsupported()
+		}
 		switch operator {
 		case logicalplan.OpEq:
-			switch r := right.(type) {
-			case *scalar.Binary:
-				return BinaryArrayScalarEqual(left.(*array.Binary), r)
-			case *scalar.String:
-				return BinaryArrayScalarEqual(left.(*array.Binary), r.Binary)
-			default:
-				panic("something terrible has happened, this should have errored previously during validation")
-			}
+			return BinaryArrayScalarEqual(res, left.(*array.Binary), r)
 		case logicalplan.OpNotEq:
-			switch r := right.(type) {
-			case *scalar.Binary:
-				return BinaryArrayScalarNotEqual(left.(*array.Binary), r)
-			case *scalar.String:
-				return BinaryArrayScalarNotEqual(left.(*array.Binary), r.Binary)
-			default:
-				panic("something terrible has happened, this should have errored previously during validation")
-			}
+			return BinaryArrayScalarNotEqual(res, left.(*array.Binary), r)
+		case logicalplan.OpLt, logicalplan.OpLtEq, logicalplan.OpGt, logicalplan.OpGtEq:
+			binaryArrayScalarCompare(res, left.(*array.Binary), r, orderedComparison(operator))
+			return nil
 		default:
-			panic("something terrible has happened, this should have errored previously during validation")
+			return unsupported()
 		}
 	case arrow.PrimitiveTypes.Int64:
+		r, ok := right.(*scalar.Int64)
+		if !ok {
+			return unsupported()
+		}
+		switch operator {
+		case logicalplan.OpEq:
+			return Int64ArrayScalarEqual(res, left.(*array.Int64), r)
+		case logicalplan.OpNotEq:
+			return Int64ArrayScalarNotEqual(res, left.(*array.Int64), r)
+		case logicalplan.OpLt:
+			return Int64ArrayScalarLessThan(res, left.(*array.Int64), r)
+		case logicalplan.OpLtEq:
+			return Int64ArrayScalarLessThanOrEqual(res, left.(*array.Int64), r)
+		case logicalplan.OpGt:
+			return Int64ArrayScalarGreaterThan(res, left.(*array.Int64), r)
+		case logicalplan.OpGtEq:
+			return Int64ArrayScalarGreaterThanOrEqual(res, left.(*array.Int64), r)
+		default:
+			return unsupported()
+		}
+	case arrow.PrimitiveTypes.Uint64:
+		r, ok := right.(*scalar.Uint64)
+		if !ok {
+			return unsupported()
+		}
+		switch operator {
+		case logicalplan.OpEq:
+			uint64ArrayScalarCompare(res, left.(*array.Uint64), r, func(a, b uint64) bool { return a == b })
+			return nil
+		case logicalplan.OpNotEq:
+			uint64ArrayScalarNotEqual(res, left.(*array.Uint64), r)
+			return nil
+		case logicalplan.OpLt:
+			uint64ArrayScalarCompare(res, left.(*array.Uint64), r, func(a, b uint64) bool { return a < b })
+			return nil
+		case logicalplan.OpLtEq:
+			uint64ArrayScalarCompare(res, left.(*array.Uint64), r, func(a, b uint64) bool { return a <= b })
+			return nil
+		case logicalplan.OpGt:
+			uint64ArrayScalarCompare(res, left.(*array.Uint64), r, func(a, b uint64) bool { return a > b })
+			return nil
+		case logicalplan.OpGtEq:
+			uint64ArrayScalarCompare(res, left.(*array.Uint64), r, func(a, b uint64) bool { return a >= b })
+			return nil
+		default:
+			return unsupported()
+		}
+	case arrow.PrimitiveTypes.Float64:
+		r, ok := right.(*scalar.Float64)
+		if !ok {
+			return unsupported()
+		}
+		switch operator {
+		case logicalplan.OpEq:
+			float64ArrayScalarCompare(res, left.(*array.Float64), r, func(a, b float64) bool { return a == b })
+			return nil
+		case logicalplan.OpNotEq:
+			float64ArrayScalarNotEqual(res, left.(*array.Float64), r)
+			return nil
+		case logicalplan.OpLt:
+			float64ArrayScalarCompare(res, left.(*array.Float64), r, func(a, b float64) bool { return a < b })
+			return nil
+		case logicalplan.OpLtEq:
+			float64ArrayScalarCompare(res, left.(*array.Float64), r, func(a, b float64) bool { return a <= b })
+			return nil
+		case logicalplan.OpGt:
+			float64ArrayScalarCompare(res, left.(*array.Float64), r, func(a, b float64) bool { return a > b })
+			return nil
+		case logicalplan.OpGtEq:
+			float64ArrayScalarCompare(res, left.(*array.Float64), r, func(a, b float64) bool { return a >= b })
+			return nil
+		default:
+			return unsupported()
+		}
+	case arrow.PrimitiveTypes.Float32:
+		r, ok := right.(*scalar.Float32)
+		if !ok {
+			return unsupported()
+		}
 		switch operator {
 		case logicalplan.OpEq:
-			return Int64ArrayScalarEqual(left.(*array.Int64), right.(*scalar.Int64))
+			float32ArrayScalarCompare(res, left.(*array.Float32), r, func(a, b float32) bool { return a == b })
+			return nil
 		case logicalplan.OpNotEq:
-			return Int64ArrayScalarNotEqual(left.(*array.Int64), right.(*scalar.Int64))
+			float32ArrayScalarNotEqual(res, left.(*array.Float32), r)
+			return nil
 		case logicalplan.OpLt:
-			return Int64ArrayScalarLessThan(left.(*array.Int64), right.(*scalar.Int64))
+			float32ArrayScalarCompare(res, left.(*array.Float32), r, func(a, b float32) bool { return a < b })
+			return nil
 		case logicalplan.OpLtEq:
-			return Int64ArrayScalarLessThanOrEqual(left.(*array.Int64), right.(*scalar.Int64))
+			float32ArrayScalarCompare(res, left.(*array.Float32), r, func(a, b float32) bool { return a <= b })
+			return nil
 		case logicalplan.OpGt:
-			return Int64ArrayScalarGreaterThan(left.(*array.Int64), right.(*scalar.Int64))
+			float32ArrayScalarCompare(res, left.(*array.Float32), r, func(a, b float32) bool { return a > b })
+			return nil
 		case logicalplan.OpGtEq:
-			return Int64ArrayScalarGreaterThanOrEqual(left.(*array.Int64), right.(*scalar.Int64))
+			float32ArrayScalarCompare(res, left.(*array.Float32), r, func(a, b float32) bool { return a >= b })
+			return nil
 		default:
-			panic("something terrible has happened, this should have errored previously during validation")
+			return unsupported()
 		}
 	}
 
@@ -154,24 +299,40 @@ func BinaryScalarOperation(left arrow.Array, right scalar.Scalar, operator logic
 	case *array.Dictionary:
 		switch operator {
 		case logicalplan.OpEq:
-			return DictionaryArrayScalarEqual(arr, right)
+			return DictionaryArrayScalarEqual(res, arr, right)
 		case logicalplan.OpNotEq:
-			return DictionaryArrayScalarNotEqual(arr, right)
+			return DictionaryArrayScalarNotEqual(res, arr, right)
+		case logicalplan.OpLt, logicalplan.OpLtEq, logicalplan.OpGt, logicalplan.OpGtEq:
+			var data []byte
+			switch r := right.(type) {
+			case *scalar.Binary:
+				data = r.Data()
+			case *scalar.String:
+				data = r.Data()
+			default:
+				return unsupported()
+			}
+			return dictionaryArrayScalarCompare(res, arr, data, orderedComparison(operator))
 		default:
-			return nil, fmt.Errorf("unsupported operator: %v", operator)
+			return fmt.Errorf("unsupported operator: %v", operator)
 		}
 	}
 
-	switch leftType.(type) {
-	case *arrow.ListType:
-		panic("TODO: list comparisons unimplemented")
-	}
+	// List comparisons are not implemented.
+	return unsupported()
+}
 
-	return nil, ErrUnsupportedBinaryOperation
+// arrayNullEqual adds the indices of the null values of the array to the
+// bitmap, or of the non-null values if null is false.
+func arrayNullEqual(res *Bitmap, arr arrow.Array, null bool) {
+	for i := 0; i < arr.Len(); i++ {
+		if arr.IsNull(i) == null {
+			res.Add(uint32(i))
+		}
+	}
 }
 
-func DictionaryArrayScalarNotEqual(left *array.Dictionary, right scalar.Scalar) (*Bitmap, error) {
-	res := NewBitmap()
+func DictionaryArrayScalarNotEqual(res *Bitmap, left *array.Dictionary, right scalar.Scalar) error {
 	var data []byte
 	switch r := right.(type) {
 	case *scalar.Binary:
@@ -187,7 +348,7 @@ func DictionaryArrayScalarNotEqual(left *array.Dictionary, right scalar.Scalar)
 				res.Add(uint32(i))
 			}
 		}
-		return res, nil
+		return nil
 	}
 
 	for i := 0; i < left.Len(); i++ {
@@ -207,11 +368,10 @@ func DictionaryArrayScalarNotEqual(left *array.Dictionary, right scalar.Scalar)
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func DictionaryArrayScalarEqual(left *array.Dictionary, right scalar.Scalar) (*Bitmap, error) {
-	res := NewBitmap()
+func DictionaryArrayScalarEqual(res *Bitmap, left *array.Dictionary, right scalar.Scalar) error {
 	var data []byte
 	switch r := right.(type) {
 	case *scalar.Binary:
@@ -227,7 +387,7 @@ func DictionaryArrayScalarEqual(left *array.Dictionary, right scalar.Scalar) (*B
 				res.Add(uint32(i))
 			}
 		}
-		return res, nil
+		return nil
 	}
 
 	for i := 0; i < left.Len(); i++ {
@@ -247,11 +407,10 @@ func DictionaryArrayScalarEqual(left *array.Dictionary, right scalar.Scalar) (*B
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func FixedSizeBinaryArrayScalarEqual(left *array.FixedSizeBinary, right *scalar.FixedSizeBinary) (*Bitmap, error) {
-	res := NewBitmap()
+func FixedSizeBinaryArrayScalarEqual(res *Bitmap, left *array.FixedSizeBinary, right *scalar.FixedSizeBinary) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			continue
@@ -261,11 +420,10 @@ func FixedSizeBinaryArrayScalarEqual(left *array.FixedSizeBinary, right *scalar.
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func FixedSizeBinaryArrayScalarNotEqual(left *array.FixedSizeBinary, right *scalar.FixedSizeBinary) (*Bitmap, error) {
-	res := NewBitmap()
+func FixedSizeBinaryArrayScalarNotEqual(res *Bitmap, left *array.FixedSizeBinary, right *scalar.FixedSizeBinary) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			res.Add(uint32(i))
@@ -276,11 +434,10 @@ func FixedSizeBinaryArrayScalarNotEqual(left *array.FixedSizeBinary, right *scal
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func StringArrayScalarEqual(left *array.String, right *scalar.String) (*Bitmap, error) {
-	res := NewBitmap()
+func StringArrayScalarEqual(res *Bitmap, left *array.String, right *scalar.String) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			continue
@@ -290,11 +447,10 @@ func StringArrayScalarEqual(left *array.String, right *scalar.String) (*Bitmap,
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func StringArrayScalarNotEqual(left *array.String, right *scalar.String) (*Bitmap, error) {
-	res := NewBitmap()
+func StringArrayScalarNotEqual(res *Bitmap, left *array.String, right *scalar.String) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			res.Add(uint32(i))
@@ -305,11 +461,10 @@ func StringArrayScalarNotEqual(left *array.String, right *scalar.String) (*Bitma
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func BinaryArrayScalarEqual(left *array.Binary, right *scalar.Binary) (*Bitmap, error) {
-	res := NewBitmap()
+func BinaryArrayScalarEqual(res *Bitmap, left *array.Binary, right *scalar.Binary) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			continue
@@ -319,11 +474,10 @@ func BinaryArrayScalarEqual(left *array.Binary, right *scalar.Binary) (*Bitmap,
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func BinaryArrayScalarNotEqual(left *array.Binary, right *scalar.Binary) (*Bitmap, error) {
-	res := NewBitmap()
+func BinaryArrayScalarNotEqual(res *Bitmap, left *array.Binary, right *scalar.Binary) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			res.Add(uint32(i))
@@ -334,27 +488,91 @@ func BinaryArrayScalarNotEqual(left *array.Binary, right *scalar.Binary) (*Bitma
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func Int64ArrayScalarEqual(left *array.Int64, right *scalar.Int64) (*Bitmap, error) {
-	res := NewBitmap()
+// orderedComparison returns whether the result of comparing a value to a
+// scalar, as returned by bytes.Compare, satisfies the given ordered comparison
+// operator.
+func orderedComparison(operator logicalplan.Op) func(c int) bool {
+	switch operator {
+	case logicalplan.OpLt:
+		return func(c int) bool { return c < 0 }
+	case logicalplan.OpLtEq:
+		return func(c int) bool { return c <= 0 }
+	case logicalplan.OpGt:
+		return func(c int) bool { return c > 0 }
+	default:
+		return func(c int) bool { return c >= 0 }
+	}
+}
 
+func stringArrayScalarCompare(res *Bitmap, left *array.String, right *scalar.String, cmp func(c int) bool) {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			continue
 		}
-		if left.Value(i) == right.Value {
+		if cmp(strings.Compare(left.Value(i), string(right.Data()))) {
 			res.Add(uint32(i))
 		}
 	}
+}
 
-	return res, nil
+func binaryArrayScalarCompare(res *Bitmap, left *array.Binary, right *scalar.Binary, cmp func(c int) bool) {
+	for i := 0; i < left.Len(); i++ {
+		if left.IsNull(i) {
+			continue
+		}
+		if cmp(bytes.Compare(left.Value(i), right.Data())) {
+			res.Add(uint32(i))
+		}
+	}
 }
 
-func Int64ArrayScalarNotEqual(left *array.Int64, right *scalar.Int64) (*Bitmap, error) {
-	res := NewBitmap()
+// dictionaryArrayScalarCompare compares the values of a dictionary of binary
+// or string values to the scalar. Every value of the dictionary is only
+// compared once.
+func dictionaryArrayScalarCompare(res *Bitmap, left *array.Dictionary, right []byte, cmp func(c int) bool) error {
+	var matches []bool
+	switch dict := left.Dictionary().(type) {
+	case *array.Binary:
+		matches = make([]bool, dict.Len())
+		for i := range matches {
+			matches[i] = dict.IsValid(i) && cmp(bytes.Compare(dict.Value(i), right))
+		}
+	case *array.String:
+		matches = make([]bool, dict.Len())
+		for i := range matches {
+			matches[i] = dict.IsValid(i) && cmp(strings.Compare(dict.Value(i), string(right)))
+		}
+	default:
+		return fmt.Errorf("%w: dictionary of %s", ErrUnsupportedBinaryOperation, dict.DataType())
+	}
+	for i := 0; i < left.Len(); i++ {
+		if left.IsNull(i) {
+			continue
+		}
+		if matches[left.GetValueIndex(i)] {
+			res.Add(uint32(i))
+		}
+	}
+	return nil
+}
+
+func Int64ArrayScalarEqual(res *Bitmap, left *array.Int64, right *scalar.Int64) error {
+	for i := 0; i < left.Len(); i++ {
+		if left.IsNull(i) {
+			continue
+		}
+		if left.Value(i) == right.Value {
+			res.Add(uint32(i))
+		}
+	}
 
+	return nil
+}
+
+func Int64ArrayScalarNotEqual(res *Bitmap, left *array.Int64, right *scalar.Int64) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			res.Add(uint32(i))
@@ -365,12 +583,10 @@ func Int64ArrayScalarNotEqual(left *array.Int64, right *scalar.Int64) (*Bitmap,
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func Int64ArrayScalarLessThan(left *array.Int64, right *scalar.Int64) (*Bitmap, error) {
-	res := NewBitmap()
-
+func Int64ArrayScalarLessThan(res *Bitmap, left *array.Int64, right *scalar.Int64) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			continue
@@ -380,12 +596,10 @@ func Int64ArrayScalarLessThan(left *array.Int64, right *scalar.Int64) (*Bitmap,
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func Int64ArrayScalarLessThanOrEqual(left *array.Int64, right *scalar.Int64) (*Bitmap, error) {
-	res := NewBitmap()
-
+func Int64ArrayScalarLessThanOrEqual(res *Bitmap, left *array.Int64, right *scalar.Int64) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			continue
@@ -395,12 +609,10 @@ func Int64ArrayScalarLessThanOrEqual(left *array.Int64, right *scalar.Int64) (*B
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func Int64ArrayScalarGreaterThan(left *array.Int64, right *scalar.Int64) (*Bitmap, error) {
-	res := NewBitmap()
-
+func Int64ArrayScalarGreaterThan(res *Bitmap, left *array.Int64, right *scalar.Int64) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			continue
@@ -410,12 +622,10 @@ func Int64ArrayScalarGreaterThan(left *array.Int64, right *scalar.Int64) (*Bitma
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func Int64ArrayScalarGreaterThanOrEqual(left *array.Int64, right *scalar.Int64) (*Bitmap, error) {
-	res := NewBitmap()
-
+func Int64ArrayScalarGreaterThanOrEqual(res *Bitmap, left *array.Int64, right *scalar.Int64) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			continue
@@ -425,27 +635,86 @@ func Int64ArrayScalarGreaterThanOrEqual(left *array.Int64, right *scalar.Int64)
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func BooleanArrayScalarEqual(left *array.Boolean, right *scalar.Boolean) (*Bitmap, error) {
-	res := NewBitmap()
+// uint64ArrayScalarCompare returns the non-null values of left for which
+// cmp(value, right) is true.
+func uint64ArrayScalarCompare(res *Bitmap, left *array.Uint64, right *scalar.Uint64, cmp func(a, b uint64) bool) {
+	for i := 0; i < left.Len(); i++ {
+		if left.IsNull(i) {
+			continue
+		}
+		if cmp(left.Value(i), right.Value) {
+			res.Add(uint32(i))
+		}
+	}
+}
 
+func uint64ArrayScalarNotEqual(res *Bitmap, left *array.Uint64, right *scalar.Uint64) {
+	for i := 0; i < left.Len(); i++ {
+		if left.IsNull(i) || left.Value(i) != right.Value {
+			res.Add(uint32(i))
+		}
+	}
+}
+
+// float64ArrayScalarCompare returns the non-null values of left for which
+// cmp(value, right) is true.
+func float64ArrayScalarCompare(res *Bitmap, left *array.Float64, right *scalar.Float64, cmp func(a, b float64) bool) {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			continue
 		}
-		if left.Value(i) == right.Value {
+		if cmp(left.Value(i), right.Value) {
 			res.Add(uint32(i))
 		}
 	}
+}
 
-	return res, nil
+func float64ArrayScalarNotEqual(res *Bitmap, left *array.Float64, right *scalar.Float64) {
+	for i := 0; i < left.Len(); i++ {
+		if left.IsNull(i) || left.Value(i) != right.Value {
+			res.Add(uint32(i))
+		}
+	}
 }
 
-func BooleanArrayScalarNotEqual(left *array.Boolean, right *scalar.Boolean) (*Bitmap, error) {
-	res := NewBitmap()
+// float32ArrayScalarCompare is the same as float64ArrayScalarCompare but for
+// float32 arrays.
+func float32ArrayScalarCompare(res *Bitmap, left *array.Float32, right *scalar.Float32, cmp func(a, b float32) bool) {
+	for i := 0; i < left.Len(); i++ {
+		if left.IsNull(i) {
+			continue
+		}
+		if cmp(left.Value(i), right.Value) {
+			res.Add(uint32(i))
+		}
+	}
+}
+
+func float32ArrayScalarNotEqual(res *Bitmap, left *array.Float32, right *scalar.Float32) {
+	for i := 0; i < left.Len(); i++ {
+		if left.IsNull(i) || left.Value(i) != right.Value {
+			res.Add(uint32(i))
+		}
+	}
+}
+
+func BooleanArrayScalarEqual(res *Bitmap, left *array.Boolean, right *scalar.Boolean) error {
+	for i := 0; i < left.Len(); i++ {
+		if left.IsNull(i) {
+			continue
+		}
+		if left.Value(i) == right.Value {
+			res.Add(uint32(i))
+		}
+	}
 
+	return nil
+}
+
+func BooleanArrayScalarNotEqual(res *Bitmap, left *array.Boolean, right *scalar.Boolean) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			continue
@@ -455,5 +724,84 @@ func BooleanArrayScalarNotEqual(left *array.Boolean, right *scalar.Boolean) (*Bi
 		}
 	}
 
+	return nil
+}
+
+// BooleanColumnExpr matches the rows of a boolean column whose values equal
+// Value. Filters on boolean columns, e.g. `is_error` and `NOT is_error`, are
+// planned as such comparisons, which are evaluated on the values and validity
+// bitmaps of the column rather than row by row. Null values never match.
+type BooleanColumnExpr struct {
+	Column *ArrayRef
+	Value  bool
+}
+
+func (e *BooleanColumnExpr) Eval(r arrow.Record) (*Bitmap, error) {
+	res := NewBitmap()
+	if err := e.EvalInto(r, res, nil); err != nil {
+		return nil, err
+	}
 	return res, nil
 }
+
+func (e *BooleanColumnExpr) EvalInto(r arrow.Record, res *Bitmap, _ *BitmapPool) error {
+	arr, exists, err := e.Column.ArrowArray(r)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		// A missing column is all null, which doesn't match.
+		return nil
+	}
+
+	switch arr := arr.(type) {
+	case *array.Boolean:
+		booleanArrayEqual(res, arr, e.Value)
+		return nil
+	case *array.Null:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s == %t", ErrUnsupportedBinaryOperation, arr.DataType(), e.Value)
+	}
+}
+
+func (e *BooleanColumnExpr) String() string {
+	return fmt.Sprintf("%s == %t", e.Column, e.Value)
+}
+
+// booleanArrayEqual adds the rows of the array whose values equal value to
+// res. Byte-aligned groups of eight rows are matched by masking the values
+// with the validity bitmap, so that groups without matches are skipped.
+func booleanArrayEqual(res *Bitmap, arr *array.Boolean, value bool) {
+	var (
+		data     = arr.Data()
+		offset   = data.Offset()
+		values   = data.Buffers()[1].Bytes()
+		validity []byte
+	)
+	if arr.NullN() > 0 {
+		validity = data.Buffers()[0].Bytes()
+	}
+
+	for i := 0; i < arr.Len(); {
+		pos := offset + i
+		if pos%8 == 0 && i+8 <= arr.Len() {
+			b := values[pos/8]
+			if !value {
+				b = ^b
+			}
+			if validity != nil {
+				b &= validity[pos/8]
+			}
+			for ; b != 0; b &= b - 1 {
+				res.Add(uint32(i + bits.TrailingZeros8(b)))
+			}
+			i += 8
+			continue
+		}
+		if (validity == nil || bitutil.BitIsSet(validity, pos)) && bitutil.BitIsSet(values, pos) == value {
+			res.Add(uint32(i))
+		}
+		i++
+	}
+}
@@ -0,0 +1,149 @@
+package physicalplan
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+)
+
+// conjunctStatsDecayRows is the number of rows a conjunct is evaluated on
+// after which its statistics are halved, so that the evaluation order adapts
+// to changes of the data.
+const conjunctStatsDecayRows = 1 << 20
+
+// ConjunctionExpr evaluates a chain of AND expressions. It tracks the
+// selectivity and cost of each conjunct as records are evaluated and reorders
+// them so that the conjuncts that are cheapest and filter out the most rows
+// are evaluated first. Conjuncts are only evaluated on the rows the previous
+// ones match, and the evaluation of a record stops once no rows are left.
+type ConjunctionExpr struct {
+	and       *AndExpr
+	conjuncts []*conjunct
+
+	mtx sync.Mutex
+	// order is the order the conjuncts are evaluated in.
+	order []int
+}
+
+type conjunct struct {
+	expr BooleanExpression
+
+	// rows is the number of rows the conjunct was evaluated on, of which it
+	// selected selected rows in nanos nanoseconds.
+	rows     int64
+	selected int64
+	nanos    int64
+}
+
+// NewConjunctionExpr returns an expression that evaluates the conjuncts of the
+// given chain of AND expressions in an adaptive order.
+func NewConjunctionExpr(and *AndExpr) *ConjunctionExpr {
+	c := &ConjunctionExpr{and: and}
+	var flatten func(BooleanExpression)
+	flatten = func(expr BooleanExpression) {
+		if and, ok := expr.(*AndExpr); ok {
+			flatten(and.Left)
+			flatten(and.Right)
+			return
+		}
+		c.order = append(c.order, len(c.conjuncts))
+		c.conjuncts = append(c.conjuncts, &conjunct{expr: expr})
+	}
+	flatten(and)
+	return c
+}
+
+func (c *ConjunctionExpr) Eval(r arrow.Record) (*Bitmap, error) {
+	res := NewBitmap()
+	if err := c.EvalInto(r, res, nil); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *ConjunctionExpr) EvalInto(r arrow.Record, res *Bitmap, pool *BitmapPool) error {
+	c.mtx.Lock()
+	order := append(make([]int, 0, len(c.order)), c.order...)
+	c.mtx.Unlock()
+
+	evaluated := make([]conjunct, 0, len(order))
+	bitmap := pool.Get()
+	defer pool.Put(bitmap)
+	for j, i := range order {
+		var (
+			start = time.Now()
+			rows  = r.NumRows()
+			err   error
+		)
+		if j == 0 {
+			err = evalInto(c.conjuncts[i].expr, r, res, pool)
+		} else {
+			// Conjuncts only need to be evaluated on the rows the previous
+			// ones match.
+			bitmap.Clear()
+			rows, err = evalSelection(c.conjuncts[i].expr, r, res, bitmap, pool)
+		}
+		if err != nil {
+			return err
+		}
+		matched := res
+		if j > 0 {
+			matched = bitmap
+		}
+		evaluated = append(evaluated, conjunct{
+			rows:     rows,
+			selected: int64(matched.GetCardinality()),
+			nanos:    int64(time.Since(start)),
+		})
+
+		if j > 0 {
+			// This stores the result in place to avoid allocations.
+			res.And(bitmap)
+		}
+		if res.IsEmpty() {
+			break
+		}
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for j, stats := range evaluated {
+		conjunct := c.conjuncts[order[j]]
+		conjunct.rows += stats.rows
+		conjunct.selected += stats.selected
+		conjunct.nanos += stats.nanos
+		if conjunct.rows > conjunctStatsDecayRows {
+			conjunct.rows /= 2
+			conjunct.selected /= 2
+			conjunct.nanos /= 2
+		}
+	}
+	sort.SliceStable(c.order, func(a, b int) bool {
+		return c.conjuncts[c.order[a]].rank() < c.conjuncts[c.order[b]].rank()
+	})
+	return nil
+}
+
+// rank returns the cost of evaluating a row divided by the fraction of rows
+// the conjunct filters out. Evaluating conjuncts in ascending order of their
+// ranks minimizes the expected cost of evaluating the conjunction. Conjuncts
+// that were not evaluated yet rank first so that they are measured.
+func (c *conjunct) rank() float64 {
+	if c.rows == 0 {
+		return 0
+	}
+	filtered := 1 - float64(c.selected)/float64(c.rows)
+	if filtered <= 0 {
+		return math.Inf(1)
+	}
+	return float64(c.nanos) / float64(c.rows) / filtered
+}
+
+// String returns the chain of AND expressions in the order they were planned,
+// regardless of the order its conjuncts are evaluated in.
+func (c *ConjunctionExpr) String() string {
+	return c.and.String()
+}
@@ -0,0 +1,144 @@
+package physicalplan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/pqarrow/arrowutils"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// DedupSortedOperator removes rows that are equal to the row preceding them in
+// the key columns from a stream of records sorted by those columns, e.g. the
+// identical samples of replicated writers that are adjacent after merging
+// their streams. Rows are compared across records, and like group keys, rows
+// are equal if their non-null key values are equal regardless of the schemas
+// of their records. The operator must only be fed by a single input.
+type DedupSortedOperator struct {
+	pool    memory.Allocator
+	tracer  trace.Tracer
+	next    PhysicalPlan
+	columns []logicalplan.Expr
+
+	// last is the key of the last row that was passed on, if any.
+	last    []byte
+	hasLast bool
+	key     []byte
+	keyCols []groupKeyColumn
+	keep    []int64
+}
+
+// DedupSorted returns an operator that removes consecutive duplicate rows of a
+// sorted stream. Rows are compared by the columns matching the given
+// expressions, or by all columns if none are given.
+func DedupSorted(pool memory.Allocator, tracer trace.Tracer, columns []logicalplan.Expr) *DedupSortedOperator {
+	return &DedupSortedOperator{
+		pool:    pool,
+		tracer:  tracer,
+		columns: columns,
+	}
+}
+
+func (d *DedupSortedOperator) SetNext(next PhysicalPlan) {
+	d.next = next
+}
+
+func (d *DedupSortedOperator) Draw() *Diagram {
+	var child *Diagram
+	if d.next != nil {
+		child = d.next.Draw()
+	}
+
+	columns := make([]string, 0, len(d.columns))
+	for _, c := range d.columns {
+		columns = append(columns, c.Name())
+	}
+
+	return &Diagram{Details: fmt.Sprintf("DedupSorted (%s)", strings.Join(columns, ",")), Child: child}
+}
+
+func (d *DedupSortedOperator) Callback(ctx context.Context, r arrow.Record) error {
+	d.keyCols = d.keyCols[:0]
+	fields := r.Schema().Fields()
+	for i, field := range fields {
+		if dynparquet.IsHashedColumn(field.Name) || !d.matchColumn(field.Name) {
+			continue
+		}
+		col := newGroupKeyColumn(field, r.Column(i))
+		if hashed := dynparquet.FindHashedColumn(field.Name, fields); hashed != -1 {
+			vals := make([]uint64, 0, r.NumRows())
+			for _, v := range r.Column(hashed).(*array.Int64).Int64Values() {
+				vals = append(vals, uint64(v))
+			}
+			col.hashes = vals
+		} else if _, ok := r.Column(i).(*array.List); ok {
+			col.hashes = dynparquet.HashArray(r.Column(i))
+		}
+		d.keyCols = append(d.keyCols, col)
+	}
+
+	d.keep = d.keep[:0]
+	for i := 0; i < int(r.NumRows()); i++ {
+		d.key = d.key[:0]
+		for j := range d.keyCols {
+			var err error
+			if d.key, err = d.keyCols[j].appendKey(d.key, i); err != nil {
+				return err
+			}
+		}
+		if d.hasLast && bytes.Equal(d.key, d.last) {
+			continue
+		}
+		d.last = append(d.last[:0], d.key...)
+		d.hasLast = true
+		d.keep = append(d.keep, int64(i))
+	}
+
+	switch len(d.keep) {
+	case int(r.NumRows()):
+		return d.next.Callback(ctx, r)
+	case 0:
+		return nil
+	}
+
+	b := array.NewInt64Builder(d.pool)
+	defer b.Release()
+	b.AppendValues(d.keep, nil)
+	indices := b.NewInt64Array()
+	defer indices.Release()
+	deduped, err := arrowutils.TakeRecord(d.pool, r, indices)
+	if err != nil {
+		return err
+	}
+	defer deduped.Release()
+	return d.next.Callback(ctx, deduped)
+}
+
+// matchColumn returns whether the column is a key column.
+func (d *DedupSortedOperator) matchColumn(name string) bool {
+	if len(d.columns) == 0 {
+		return true
+	}
+	for _, c := range d.columns {
+		if c.MatchColumn(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *DedupSortedOperator) Finish(ctx context.Context) error {
+	return d.next.Finish(ctx)
+}
+
+func (d *DedupSortedOperator) Close() {
+	d.next.Close()
+}
@@ -0,0 +1,105 @@
+package physicalplan
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+func TestDedupSorted(t *testing.T) {
+	type row struct {
+		node      string
+		timestamp int64
+		value     float64
+	}
+	newRecord := func(pool memory.Allocator, rows ...row) arrow.Record {
+		nodes := array.NewBinaryBuilder(pool, arrow.BinaryTypes.Binary)
+		defer nodes.Release()
+		timestamps := array.NewInt64Builder(pool)
+		defer timestamps.Release()
+		values := array.NewFloat64Builder(pool)
+		defer values.Release()
+		for _, r := range rows {
+			if r.node == "" {
+				nodes.AppendNull()
+			} else {
+				nodes.AppendString(r.node)
+			}
+			timestamps.Append(r.timestamp)
+			values.Append(r.value)
+		}
+		cols := []arrow.Array{nodes.NewArray(), timestamps.NewArray(), values.NewArray()}
+		defer func() {
+			for _, c := range cols {
+				c.Release()
+			}
+		}()
+		return array.NewRecord(arrow.NewSchema([]arrow.Field{
+			{Name: "labels.node", Type: arrow.BinaryTypes.Binary, Nullable: true},
+			{Name: "timestamp", Type: arrow.PrimitiveTypes.Int64},
+			{Name: "value", Type: arrow.PrimitiveTypes.Float64},
+		}, nil), cols, int64(len(rows)))
+	}
+	input := [][]row{
+		{{"a", 1, 1}, {"a", 1, 1}, {"a", 2, 1}, {"b", 1, 2}},
+		// The first row duplicates the last row of the previous record.
+		{{"b", 1, 2}, {"b", 1, 3}, {"", 1, 3}, {"", 1, 3}},
+		{{"", 1, 3}},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		columns  []logicalplan.Expr
+		expected []row
+	}{
+		{
+			name: "all_columns",
+			expected: []row{
+				{"a", 1, 1}, {"a", 2, 1}, {"b", 1, 2}, {"b", 1, 3}, {"", 1, 3},
+			},
+		},
+		{
+			name:    "key_columns",
+			columns: []logicalplan.Expr{logicalplan.DynCol("labels"), logicalplan.Col("timestamp")},
+			expected: []row{
+				{"a", 1, 1}, {"a", 2, 1}, {"b", 1, 2}, {"", 1, 3},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := memory.NewCheckedAllocator(memory.DefaultAllocator)
+			defer pool.AssertSize(t, 0)
+
+			var output []row
+			d := DedupSorted(pool, trace.NewNoopTracerProvider().Tracer(""), tc.columns)
+			d.SetNext(&OutputPlan{
+				callback: func(_ context.Context, r arrow.Record) error {
+					nodes := r.Column(0).(*array.Binary)
+					for i := 0; i < int(r.NumRows()); i++ {
+						output = append(output, row{
+							node:      nodes.ValueString(i),
+							timestamp: r.Column(1).(*array.Int64).Value(i),
+							value:     r.Column(2).(*array.Float64).Value(i),
+						})
+					}
+					return nil
+				},
+			})
+
+			ctx := context.Background()
+			for _, rows := range input {
+				r := newRecord(pool, rows...)
+				require.NoError(t, d.Callback(ctx, r))
+				r.Release()
+			}
+			require.Equal(t, tc.expected, output)
+		})
+	}
+}
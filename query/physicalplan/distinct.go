@@ -159,11 +159,11 @@ func (d *Distinction) Callback(ctx context.Context, r arrow.Record) error {
 
 	schema := arrow.NewSchema(distinctFields, nil)
 
-	distinctRecord := array.NewRecord(
+	distinctRecord := NewRefRecord(array.NewRecord(
 		schema,
 		resArrays,
 		rows,
-	)
+	))
 
 	defer distinctRecord.Release()
 	return d.next.Callback(ctx, distinctRecord)
@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"regexp"
+	"sync"
 
 	"github.com/RoaringBitmap/roaring"
 	"github.com/apache/arrow/go/v14/arrow"
@@ -20,6 +20,7 @@ type PredicateFilter struct {
 	pool       memory.Allocator
 	tracer     trace.Tracer
 	filterExpr BooleanExpression
+	bitmaps    *BitmapPool
 	next       PhysicalPlan
 }
 
@@ -43,8 +44,66 @@ type BooleanExpression interface {
 	String() string
 }
 
+// InPlaceBooleanExpression is a BooleanExpression that can write the rows it
+// matches into a caller-provided empty bitmap, taking the bitmaps of
+// intermediate results from the given pool.
+type InPlaceBooleanExpression interface {
+	BooleanExpression
+	EvalInto(r arrow.Record, res *Bitmap, pool *BitmapPool) error
+}
+
+// evalInto writes the rows of the record the expression matches into the
+// empty bitmap.
+func evalInto(expr BooleanExpression, r arrow.Record, res *Bitmap, pool *BitmapPool) error {
+	if e, ok := expr.(InPlaceBooleanExpression); ok {
+		return e.EvalInto(r, res, pool)
+	}
+	bitmap, err := expr.Eval(r)
+	if err != nil {
+		return err
+	}
+	res.Or(bitmap)
+	return nil
+}
+
+// BitmapPool pools bitmaps so that they are reused across evaluations. A nil
+// pool allocates a new bitmap every time.
+type BitmapPool struct {
+	pool sync.Pool
+}
+
+func NewBitmapPool() *BitmapPool {
+	return &BitmapPool{}
+}
+
+// Get returns an empty bitmap.
+func (p *BitmapPool) Get() *Bitmap {
+	if p == nil {
+		return NewBitmap()
+	}
+	if b, ok := p.pool.Get().(*Bitmap); ok {
+		return b
+	}
+	return NewBitmap()
+}
+
+// Put returns the bitmap to the pool. It must not be used afterwards.
+func (p *BitmapPool) Put(b *Bitmap) {
+	if p == nil {
+		return
+	}
+	b.Clear()
+	p.pool.Put(b)
+}
+
 var ErrUnsupportedBooleanExpression = errors.New("unsupported boolean expression")
 
+// unsupportedBooleanExpr returns an ErrUnsupportedBooleanExpression for the
+// given expression.
+func unsupportedBooleanExpr(expr logicalplan.Expr, reason string) error {
+	return fmt.Errorf("%w: %s: %s", ErrUnsupportedBooleanExpression, reason, expr)
+}
+
 type ArrayReference struct{}
 
 type PreExprVisitorFunc func(expr logicalplan.Expr) bool
@@ -76,7 +135,7 @@ func binaryBooleanExpr(expr *logicalplan.BinaryExpr) (BooleanExpression, error)
 			return true
 		}))
 		if leftColumnRef == nil {
-			return nil, errors.New("left side of binary expression must be a column")
+			return nil, unsupportedBooleanExpr(expr, "left side of binary expression must be a column")
 		}
 
 		var rightScalar scalar.Scalar
@@ -89,25 +148,34 @@ func binaryBooleanExpr(expr *logicalplan.BinaryExpr) (BooleanExpression, error)
 			return true
 		}))
 
+		if rightScalar == nil {
+			return nil, unsupportedBooleanExpr(expr, "right side of binary expression must be a literal")
+		}
+
 		switch expr.Op {
-		case logicalplan.OpRegexMatch:
-			regexp, err := regexp.Compile(string(rightScalar.(*scalar.String).Data()))
-			if err != nil {
-				return nil, err
+		case logicalplan.OpRegexMatch, logicalplan.OpRegexNotMatch:
+			pattern, ok := rightScalar.(*scalar.String)
+			if !ok {
+				return nil, unsupportedBooleanExpr(expr, "regex must be a string literal")
 			}
-			return &RegExpFilter{
-				left:  leftColumnRef,
-				right: regexp,
-			}, nil
-		case logicalplan.OpRegexNotMatch:
-			regexp, err := regexp.Compile(string(rightScalar.(*scalar.String).Data()))
+			re, err := logicalplan.CompileRegexp(string(pattern.Data()))
 			if err != nil {
 				return nil, err
 			}
+			if literals, ok := logicalplan.RegexpLiterals(string(pattern.Data())); ok {
+				return newRegExpSetFilter(leftColumnRef, re, literals, expr.Op == logicalplan.OpRegexNotMatch), nil
+			}
 			return &RegExpFilter{
 				left:     leftColumnRef,
-				right:    regexp,
-				notMatch: true,
+				right:    re,
+				notMatch: expr.Op == logicalplan.OpRegexNotMatch,
+			}, nil
+		}
+
+		if b, ok := rightScalar.(*scalar.Boolean); ok && b.IsValid() && expr.Op == logicalplan.OpEq {
+			return &BooleanColumnExpr{
+				Column: leftColumnRef,
+				Value:  b.Value,
 			}, nil
 		}
 
@@ -117,20 +185,11 @@ func binaryBooleanExpr(expr *logicalplan.BinaryExpr) (BooleanExpression, error)
 			Right: rightScalar,
 		}, nil
 	case logicalplan.OpAnd:
-		left, err := booleanExpr(expr.Left)
-		if err != nil {
-			return nil, err
-		}
-
-		right, err := booleanExpr(expr.Right)
+		and, err := andBooleanExpr(expr)
 		if err != nil {
 			return nil, err
 		}
-
-		return &AndExpr{
-			Left:  left,
-			Right: right,
-		}, nil
+		return NewConjunctionExpr(and), nil
 	case logicalplan.OpOr:
 		left, err := booleanExpr(expr.Left)
 		if err != nil {
@@ -147,29 +206,106 @@ func binaryBooleanExpr(expr *logicalplan.BinaryExpr) (BooleanExpression, error)
 			Right: right,
 		}, nil
 	default:
-		panic("unsupported binary boolean expression")
+		return nil, unsupportedBooleanExpr(expr, "unsupported operator")
 	}
 }
 
+// andBooleanExpr returns the chain of AND expressions of the given AND
+// expression.
+func andBooleanExpr(expr *logicalplan.BinaryExpr) (*AndExpr, error) {
+	operand := func(expr logicalplan.Expr) (BooleanExpression, error) {
+		if e, ok := expr.(*logicalplan.BinaryExpr); ok && e.Op == logicalplan.OpAnd {
+			return andBooleanExpr(e)
+		}
+		return booleanExpr(expr)
+	}
+
+	left, err := operand(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := operand(expr.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AndExpr{
+		Left:  left,
+		Right: right,
+	}, nil
+}
+
 type AndExpr struct {
 	Left  BooleanExpression
 	Right BooleanExpression
 }
 
 func (a *AndExpr) Eval(r arrow.Record) (*Bitmap, error) {
-	left, err := a.Left.Eval(r)
-	if err != nil {
+	res := NewBitmap()
+	if err := a.EvalInto(r, res, nil); err != nil {
 		return nil, err
 	}
+	return res, nil
+}
 
-	right, err := a.Right.Eval(r)
-	if err != nil {
-		return nil, err
+func (a *AndExpr) EvalInto(r arrow.Record, res *Bitmap, pool *BitmapPool) error {
+	if err := evalInto(a.Left, r, res, pool); err != nil {
+		return err
+	}
+	if res.IsEmpty() {
+		return nil
+	}
+
+	// The right side only needs to be evaluated on the rows the left side
+	// matches.
+	right := pool.Get()
+	defer pool.Put(right)
+	if _, err := evalSelection(a.Right, r, res, right, pool); err != nil {
+		return err
 	}
 
 	// This stores the result in place to avoid allocations.
-	left.And(right)
-	return left, nil
+	res.And(right)
+	return nil
+}
+
+// minSelectionRangeRows is the minimum average number of rows of the
+// contiguous ranges of selected rows for an expression to be evaluated on the
+// ranges rather than the whole record.
+const minSelectionRangeRows = 16
+
+// evalSelection evaluates the expression on the rows of the record that are
+// set in the selection and writes the rows it matches into the empty bitmap,
+// which may contain rows that are not selected if the whole record was
+// evaluated because the selected rows are too many or too scattered. It
+// returns the number of rows evaluated.
+func evalSelection(expr BooleanExpression, r arrow.Record, selection, res *Bitmap, pool *BitmapPool) (int64, error) {
+	selected := selection.GetCardinality()
+	if selected*2 > uint64(r.NumRows()) {
+		return r.NumRows(), evalInto(expr, r, res, pool)
+	}
+	ranges := buildIndexRanges(selection.ToArray())
+	if uint64(len(ranges)*minSelectionRangeRows) > selected {
+		return r.NumRows(), evalInto(expr, r, res, pool)
+	}
+
+	bitmap := pool.Get()
+	defer pool.Put(bitmap)
+	for _, rng := range ranges {
+		slice := r.NewSlice(int64(rng.Start), int64(rng.End))
+		err := evalInto(expr, slice, bitmap, pool)
+		slice.Release()
+		if err != nil {
+			return 0, err
+		}
+		it := bitmap.Iterator()
+		for it.HasNext() {
+			res.Add(rng.Start + it.Next())
+		}
+		bitmap.Clear()
+	}
+	return int64(selected), nil
 }
 
 func (a *AndExpr) String() string {
@@ -182,19 +318,27 @@ type OrExpr struct {
 }
 
 func (a *OrExpr) Eval(r arrow.Record) (*Bitmap, error) {
-	left, err := a.Left.Eval(r)
-	if err != nil {
+	res := NewBitmap()
+	if err := a.EvalInto(r, res, nil); err != nil {
 		return nil, err
 	}
+	return res, nil
+}
 
-	right, err := a.Right.Eval(r)
-	if err != nil {
-		return nil, err
+func (a *OrExpr) EvalInto(r arrow.Record, res *Bitmap, pool *BitmapPool) error {
+	if err := evalInto(a.Left, r, res, pool); err != nil {
+		return err
+	}
+
+	right := pool.Get()
+	defer pool.Put(right)
+	if err := evalInto(a.Right, r, right, pool); err != nil {
+		return err
 	}
 
 	// This stores the result in place to avoid allocations.
-	left.Or(right)
-	return left, nil
+	res.Or(right)
+	return nil
 }
 
 func (a *OrExpr) String() string {
@@ -206,7 +350,7 @@ func booleanExpr(expr logicalplan.Expr) (BooleanExpression, error) {
 	case *logicalplan.BinaryExpr:
 		return binaryBooleanExpr(e)
 	default:
-		return nil, ErrUnsupportedBooleanExpression
+		return nil, unsupportedBooleanExpr(expr, "expression is not a binary expression")
 	}
 }
 
@@ -224,6 +368,7 @@ func newFilter(pool memory.Allocator, tracer trace.Tracer, filterExpr BooleanExp
 		pool:       pool,
 		tracer:     tracer,
 		filterExpr: filterExpr,
+		bitmaps:    NewBitmapPool(),
 	}
 }
 
@@ -240,7 +385,7 @@ func (f *PredicateFilter) Callback(ctx context.Context, r arrow.Record) error {
 	// ctx, span := f.tracer.Start(ctx, "PredicateFilter/Callback")
 	// defer span.End()
 
-	filtered, empty, err := filter(f.pool, f.filterExpr, r)
+	filtered, empty, err := filter(f.pool, f.bitmaps, f.filterExpr, r)
 	if err != nil {
 		return err
 	}
@@ -248,17 +393,19 @@ func (f *PredicateFilter) Callback(ctx context.Context, r arrow.Record) error {
 		return nil
 	}
 
-	defer filtered.Release()
-	return f.next.Callback(ctx, filtered)
+	rec := NewRefRecord(filtered)
+	defer rec.Release()
+	return f.next.Callback(ctx, rec)
 }
 
 func (f *PredicateFilter) Finish(ctx context.Context) error {
 	return f.next.Finish(ctx)
 }
 
-func filter(pool memory.Allocator, filterExpr BooleanExpression, ar arrow.Record) (arrow.Record, bool, error) {
-	bitmap, err := filterExpr.Eval(ar)
-	if err != nil {
+func filter(pool memory.Allocator, bitmaps *BitmapPool, filterExpr BooleanExpression, ar arrow.Record) (arrow.Record, bool, error) {
+	bitmap := bitmaps.Get()
+	defer bitmaps.Put(bitmap)
+	if err := evalInto(filterExpr, ar, bitmap, bitmaps); err != nil {
 		return nil, true, err
 	}
 
@@ -294,6 +441,7 @@ func filter(pool memory.Allocator, filterExpr BooleanExpression, ar arrow.Record
 			colRanges = append(colRanges, rr.Column(i))
 		}
 
+		var err error
 		cols[i], err = array.Concatenate(colRanges, pool)
 		if err != nil {
 			return nil, true, err
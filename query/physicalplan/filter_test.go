@@ -3,7 +3,13 @@ package physicalplan
 import (
 	"testing"
 
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/arrow/scalar"
 	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb/query/logicalplan"
 )
 
 func TestBuildIndexRanges(t *testing.T) {
@@ -47,3 +53,384 @@ func Test_BuildIndexRanges(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterUnsupportedExpressions(t *testing.T) {
+	for name, expr := range map[string]logicalplan.Expr{
+		"not binary":       logicalplan.Col("a"),
+		"unknown operator": &logicalplan.BinaryExpr{Left: logicalplan.Col("a"), Op: logicalplan.OpUnknown, Right: logicalplan.Literal(1)},
+		"non-string regex": &logicalplan.BinaryExpr{Left: logicalplan.Col("a"), Op: logicalplan.OpRegexMatch, Right: logicalplan.Literal(1)},
+		"no literal":       &logicalplan.BinaryExpr{Left: logicalplan.Col("a"), Op: logicalplan.OpEq, Right: logicalplan.Col("b")},
+		"nested":           logicalplan.And(logicalplan.Col("a").Eq(logicalplan.Literal(1)), logicalplan.Col("b")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Filter(memory.DefaultAllocator, nil, expr)
+			require.ErrorIs(t, err, ErrUnsupportedBooleanExpression)
+		})
+	}
+}
+
+func TestBinaryScalarOperationMismatchedTypes(t *testing.T) {
+	b := array.NewInt64Builder(memory.DefaultAllocator)
+	defer b.Release()
+	b.AppendValues([]int64{1, 2, 3}, nil)
+	arr := b.NewArray()
+	defer arr.Release()
+
+	err := BinaryScalarOperation(NewBitmap(), arr, scalar.NewStringScalar("a"), logicalplan.OpEq)
+	require.ErrorIs(t, err, ErrUnsupportedBinaryOperation)
+	err = BinaryScalarOperation(NewBitmap(), arr, scalar.NewInt64Scalar(1), logicalplan.OpRegexMatch)
+	require.ErrorIs(t, err, ErrUnsupportedBinaryOperation)
+}
+
+func TestBinaryScalarOperationNull(t *testing.T) {
+	b := array.NewInt64Builder(memory.DefaultAllocator)
+	defer b.Release()
+	b.AppendValues([]int64{1, 0, 3, 0}, []bool{true, false, true, false})
+	arr := b.NewArray()
+	defer arr.Release()
+
+	res := NewBitmap()
+	require.NoError(t, BinaryScalarOperation(res, arr, scalar.ScalarNull, logicalplan.OpEq))
+	require.Equal(t, []uint32{1, 3}, res.ToArray())
+	res = NewBitmap()
+	require.NoError(t, BinaryScalarOperation(res, arr, scalar.ScalarNull, logicalplan.OpNotEq))
+	require.Equal(t, []uint32{0, 2}, res.ToArray())
+	err := BinaryScalarOperation(NewBitmap(), arr, scalar.ScalarNull, logicalplan.OpLt)
+	require.ErrorIs(t, err, ErrUnsupportedBinaryOperation)
+}
+
+func TestBinaryScalarOperationOrderedBytes(t *testing.T) {
+	pool := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer pool.AssertSize(t, 0)
+
+	values := []string{"b", "", "a", "c", "b"}
+	valid := []bool{true, false, true, true, true}
+	sb := array.NewStringBuilder(pool)
+	defer sb.Release()
+	sb.AppendValues(values, valid)
+	str := sb.NewArray()
+	defer str.Release()
+	bb := array.NewBinaryBuilder(pool, arrow.BinaryTypes.Binary)
+	defer bb.Release()
+	for i, v := range values {
+		if valid[i] {
+			bb.Append([]byte(v))
+		} else {
+			bb.AppendNull()
+		}
+	}
+	bin := bb.NewArray()
+	defer bin.Release()
+	db := array.NewDictionaryBuilder(pool, &arrow.DictionaryType{
+		IndexType: arrow.PrimitiveTypes.Uint32,
+		ValueType: arrow.BinaryTypes.Binary,
+	}).(*array.BinaryDictionaryBuilder)
+	defer db.Release()
+	for i, v := range values {
+		if valid[i] {
+			require.NoError(t, db.AppendString(v))
+		} else {
+			db.AppendNull()
+		}
+	}
+	dict := db.NewArray()
+	defer dict.Release()
+
+	for _, tc := range []struct {
+		op       logicalplan.Op
+		expected []uint32
+	}{
+		{op: logicalplan.OpLt, expected: []uint32{2}},
+		{op: logicalplan.OpLtEq, expected: []uint32{0, 2, 4}},
+		{op: logicalplan.OpGt, expected: []uint32{3}},
+		{op: logicalplan.OpGtEq, expected: []uint32{0, 3, 4}},
+	} {
+		for _, arr := range []arrow.Array{str, bin, dict} {
+			res := NewBitmap()
+			require.NoError(t, BinaryScalarOperation(res, arr, scalar.NewStringScalar("b"), tc.op))
+			require.Equal(t, tc.expected, res.ToArray(), "%s %s", arr.DataType(), tc.op)
+		}
+	}
+}
+
+func TestConjunctionExprReordersConjuncts(t *testing.T) {
+	b := array.NewInt64Builder(memory.DefaultAllocator)
+	defer b.Release()
+	for i := 0; i < 100; i++ {
+		b.Append(int64(i))
+	}
+	arr := b.NewArray()
+	defer arr.Release()
+	r := array.NewRecord(
+		arrow.NewSchema([]arrow.Field{{Name: "a", Type: arrow.PrimitiveTypes.Int64}}, nil),
+		[]arrow.Array{arr},
+		int64(arr.Len()),
+	)
+	defer r.Release()
+
+	expr, err := booleanExpr(logicalplan.And(
+		logicalplan.Col("a").GtEq(logicalplan.Literal(int64(0))),
+		logicalplan.Col("a").Lt(logicalplan.Literal(int64(50))),
+		logicalplan.Col("a").Gt(logicalplan.Literal(int64(40))),
+	))
+	require.NoError(t, err)
+	conjunction, ok := expr.(*ConjunctionExpr)
+	require.True(t, ok)
+	require.Equal(t, "(a >= 0 AND (a < 50 AND a > 40))", conjunction.String())
+	require.Equal(t, []int{0, 1, 2}, conjunction.order)
+
+	for i := 0; i < 3; i++ {
+		bitmap, err := conjunction.Eval(r)
+		require.NoError(t, err)
+		require.Equal(t, []uint32{41, 42, 43, 44, 45, 46, 47, 48, 49}, bitmap.ToArray())
+	}
+	// The conjunct that matches all rows is evaluated last.
+	require.Equal(t, 0, conjunction.order[2])
+	require.Equal(t, "(a >= 0 AND (a < 50 AND a > 40))", conjunction.String())
+}
+
+func TestEvalSelection(t *testing.T) {
+	b := array.NewInt64Builder(memory.DefaultAllocator)
+	defer b.Release()
+	for i := 0; i < 1000; i++ {
+		b.Append(int64(i))
+	}
+	arr := b.NewArray()
+	defer arr.Release()
+	r := array.NewRecord(
+		arrow.NewSchema([]arrow.Field{{Name: "a", Type: arrow.PrimitiveTypes.Int64}}, nil),
+		[]arrow.Array{arr},
+		int64(arr.Len()),
+	)
+	defer r.Release()
+
+	expr, err := booleanExpr(logicalplan.Col("a").GtEq(logicalplan.Literal(int64(150))))
+	require.NoError(t, err)
+
+	// Contiguous ranges of rows are evaluated on their own.
+	selection := NewBitmap()
+	selection.AddRange(100, 200)
+	selection.AddRange(500, 600)
+	bitmap := NewBitmap()
+	rows, err := evalSelection(expr, r, selection, bitmap, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(200), rows)
+	expected := NewBitmap()
+	expected.AddRange(150, 200)
+	expected.AddRange(500, 600)
+	require.True(t, expected.Equals(bitmap))
+
+	// Scattered rows are evaluated on the whole record.
+	selection = NewBitmap()
+	for i := uint32(0); i < 1000; i += 4 {
+		selection.Add(i)
+	}
+	bitmap = NewBitmap()
+	rows, err = evalSelection(expr, r, selection, bitmap, NewBitmapPool())
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), rows)
+	bitmap.And(selection)
+	expected = NewBitmap()
+	for i := uint32(152); i < 1000; i += 4 {
+		expected.Add(i)
+	}
+	require.True(t, expected.Equals(bitmap))
+}
+
+func TestFilterReusesBitmaps(t *testing.T) {
+	b := array.NewInt64Builder(memory.DefaultAllocator)
+	defer b.Release()
+	for i := 0; i < 100; i++ {
+		b.Append(int64(i))
+	}
+	arr := b.NewArray()
+	defer arr.Release()
+	r := array.NewRecord(
+		arrow.NewSchema([]arrow.Field{{Name: "a", Type: arrow.PrimitiveTypes.Int64}}, nil),
+		[]arrow.Array{arr},
+		int64(arr.Len()),
+	)
+	defer r.Release()
+
+	expr, err := booleanExpr(logicalplan.Or(
+		logicalplan.And(
+			logicalplan.Col("a").GtEq(logicalplan.Literal(int64(10))),
+			logicalplan.Col("a").Lt(logicalplan.Literal(int64(20))),
+		),
+		logicalplan.Col("a").Gt(logicalplan.Literal(int64(95))),
+	))
+	require.NoError(t, err)
+
+	bitmaps := NewBitmapPool()
+	for i := 0; i < 3; i++ {
+		filtered, empty, err := filter(memory.DefaultAllocator, bitmaps, expr, r)
+		require.NoError(t, err)
+		require.False(t, empty)
+		require.Equal(t, int64(14), filtered.NumRows())
+		filtered.Release()
+	}
+
+	// Bitmaps returned to the pool are empty when they are reused.
+	bitmap := bitmaps.Get()
+	bitmap.Add(1)
+	bitmaps.Put(bitmap)
+	require.True(t, bitmaps.Get().IsEmpty())
+}
+
+func TestFilterAllNullColumns(t *testing.T) {
+	pool := memory.DefaultAllocator
+	strings := array.NewStringBuilder(pool)
+	defer strings.Release()
+	ints := array.NewInt64Builder(pool)
+	defer ints.Release()
+	dict := array.NewDictionaryBuilder(pool, &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Uint32, ValueType: arrow.BinaryTypes.Binary})
+	defer dict.Release()
+	for i := 0; i < 10; i++ {
+		strings.AppendNull()
+		ints.AppendNull()
+		dict.AppendNull()
+	}
+	r := array.NewRecord(
+		arrow.NewSchema([]arrow.Field{
+			{Name: "string", Type: arrow.BinaryTypes.String, Nullable: true},
+			{Name: "int", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+			{Name: "dict", Type: dict.Type(), Nullable: true},
+		}, nil),
+		[]arrow.Array{strings.NewArray(), ints.NewArray(), dict.NewArray()},
+		10,
+	)
+	for _, col := range r.Columns() {
+		col.Release()
+	}
+	defer r.Release()
+
+	for _, tc := range []struct {
+		expr logicalplan.Expr
+		rows uint64
+	}{
+		{expr: logicalplan.Col("string").Eq(logicalplan.Literal("a")), rows: 0},
+		{expr: logicalplan.Col("string").NotEq(logicalplan.Literal("a")), rows: 10},
+		{expr: logicalplan.Col("int").Gt(logicalplan.Literal(int64(1))), rows: 0},
+		{expr: logicalplan.Col("dict").Eq(logicalplan.Literal(nil)), rows: 10},
+		{expr: logicalplan.Col("dict").RegexMatch(".*"), rows: 0},
+	} {
+		t.Run(tc.expr.String(), func(t *testing.T) {
+			expr, err := booleanExpr(tc.expr)
+			require.NoError(t, err)
+			bitmap, err := expr.Eval(r)
+			require.NoError(t, err)
+			require.Equal(t, tc.rows, bitmap.GetCardinality())
+		})
+	}
+}
+
+func TestFilterBooleanColumns(t *testing.T) {
+	pool := memory.DefaultAllocator
+	b := array.NewBooleanBuilder(pool)
+	defer b.Release()
+	var expected [2][]uint32
+	for i := 0; i < 40; i++ {
+		if i%5 == 0 {
+			b.AppendNull()
+			continue
+		}
+		v := i%3 == 0
+		b.Append(v)
+		if i >= 3 {
+			// Rows are sliced by three below, so that bitmaps aren't
+			// byte-aligned.
+			if v {
+				expected[1] = append(expected[1], uint32(i-3))
+			} else {
+				expected[0] = append(expected[0], uint32(i-3))
+			}
+		}
+	}
+	arr := b.NewArray()
+	defer arr.Release()
+	sliced := array.NewSlice(arr, 3, int64(arr.Len()))
+	defer sliced.Release()
+	r := array.NewRecord(
+		arrow.NewSchema([]arrow.Field{{Name: "is_error", Type: arrow.FixedWidthTypes.Boolean, Nullable: true}}, nil),
+		[]arrow.Array{sliced},
+		int64(sliced.Len()),
+	)
+	defer r.Release()
+
+	for _, tc := range []struct {
+		expr     logicalplan.Expr
+		expected []uint32
+	}{
+		{expr: logicalplan.Col("is_error"), expected: expected[1]},
+		{expr: logicalplan.Not(logicalplan.Col("is_error")), expected: expected[0]},
+		{expr: logicalplan.Col("missing"), expected: []uint32{}},
+	} {
+		t.Run(tc.expr.String(), func(t *testing.T) {
+			expr, err := booleanExpr(logicalplan.NormalizeFilterExpr(tc.expr))
+			require.NoError(t, err)
+			require.IsType(t, &BooleanColumnExpr{}, expr)
+			bitmap, err := expr.Eval(r)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, bitmap.ToArray())
+		})
+	}
+}
+
+func TestFilterRegexAlternationSetLookup(t *testing.T) {
+	pool := memory.DefaultAllocator
+	values := []string{"api", "web", "", "db", "api-1", "web"}
+	binary := array.NewBinaryBuilder(pool, arrow.BinaryTypes.Binary)
+	defer binary.Release()
+	strs := array.NewStringBuilder(pool)
+	defer strs.Release()
+	dict := array.NewDictionaryBuilder(pool, &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Uint32, ValueType: arrow.BinaryTypes.Binary}).(*array.BinaryDictionaryBuilder)
+	defer dict.Release()
+	for _, v := range values {
+		binary.AppendString(v)
+		strs.Append(v)
+		require.NoError(t, dict.AppendString(v))
+	}
+	binary.AppendNull()
+	strs.AppendNull()
+	dict.AppendNull()
+	r := array.NewRecord(
+		arrow.NewSchema([]arrow.Field{
+			{Name: "binary", Type: arrow.BinaryTypes.Binary, Nullable: true},
+			{Name: "string", Type: arrow.BinaryTypes.String, Nullable: true},
+			{Name: "dict", Type: dict.Type(), Nullable: true},
+		}, nil),
+		[]arrow.Array{binary.NewArray(), strs.NewArray(), dict.NewArray()},
+		int64(len(values)+1),
+	)
+	for _, col := range r.Columns() {
+		col.Release()
+	}
+	defer r.Release()
+
+	for _, column := range []string{"binary", "string", "dict", "missing"} {
+		for _, pattern := range []string{"^(api|web)$", "^(|db)$"} {
+			for _, expr := range []*logicalplan.BinaryExpr{
+				logicalplan.Col(column).RegexMatch(pattern),
+				logicalplan.Col(column).RegexNotMatch(pattern),
+			} {
+				t.Run(expr.String(), func(t *testing.T) {
+					filter, err := booleanExpr(expr)
+					require.NoError(t, err)
+					require.IsType(t, &RegExpSetFilter{}, filter)
+					bitmap, err := filter.Eval(r)
+					require.NoError(t, err)
+
+					re, err := logicalplan.CompileRegexp(pattern)
+					require.NoError(t, err)
+					expected, err := (&RegExpFilter{
+						left:     &ArrayRef{ColumnName: column},
+						right:    re,
+						notMatch: expr.Op == logicalplan.OpRegexNotMatch,
+					}).Eval(r)
+					require.NoError(t, err)
+					require.Equal(t, expected.ToArray(), bitmap.ToArray())
+				})
+			}
+		}
+	}
+}
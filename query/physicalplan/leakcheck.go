@@ -0,0 +1,224 @@
+package physicalplan
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+)
+
+// maxLeakStackDepth is the number of frames recorded for each allocation of a
+// LeakCheckAllocator.
+const maxLeakStackDepth = 32
+
+var _ memory.Allocator = (*LeakCheckAllocator)(nil)
+
+// LeakCheckAllocator is a memory.Allocator that records the stack of every
+// allocation until it is freed, so that buffers that are never released can
+// be traced back to the code that allocated them. Recording the stacks is
+// expensive, it is meant for tests and debug builds.
+type LeakCheckAllocator struct {
+	allocator memory.Allocator
+
+	mtx    sync.Mutex
+	allocs map[unsafe.Pointer]allocation
+}
+
+type allocation struct {
+	size  int
+	stack []uintptr
+}
+
+// NewLeakCheckAllocator returns a LeakCheckAllocator allocating with the given
+// allocator.
+func NewLeakCheckAllocator(allocator memory.Allocator) *LeakCheckAllocator {
+	return &LeakCheckAllocator{
+		allocator: allocator,
+		allocs:    map[unsafe.Pointer]allocation{},
+	}
+}
+
+func (a *LeakCheckAllocator) Allocate(size int) []byte {
+	b := a.allocator.Allocate(size)
+	a.record(b)
+	return b
+}
+
+func (a *LeakCheckAllocator) Reallocate(size int, b []byte) []byte {
+	a.forget(b)
+	b = a.allocator.Reallocate(size, b)
+	a.record(b)
+	return b
+}
+
+func (a *LeakCheckAllocator) Free(b []byte) {
+	a.forget(b)
+	a.allocator.Free(b)
+}
+
+func (a *LeakCheckAllocator) record(b []byte) {
+	stack := make([]uintptr, maxLeakStackDepth)
+	// Skip runtime.Callers, record and the allocator method.
+	stack = stack[:runtime.Callers(3, stack)]
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.allocs[unsafe.Pointer(unsafe.SliceData(b))] = allocation{size: len(b), stack: stack}
+}
+
+func (a *LeakCheckAllocator) forget(b []byte) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	delete(a.allocs, unsafe.Pointer(unsafe.SliceData(b)))
+}
+
+// Allocated returns the number of bytes allocated and not yet freed.
+func (a *LeakCheckAllocator) Allocated() int {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	size := 0
+	for _, alloc := range a.allocs {
+		size += alloc.size
+	}
+	return size
+}
+
+// CheckLeaks returns an error reporting the buffers that have not been freed
+// with the stacks they were allocated at, or nil if all buffers were freed.
+func (a *LeakCheckAllocator) CheckLeaks() error {
+	return a.checkLeaks(nil)
+}
+
+// checkLeaks reports the buffers that have not been freed, except for the
+// given ones. Allocations with the same stack are reported together.
+func (a *LeakCheckAllocator) checkLeaks(except map[unsafe.Pointer]struct{}) error {
+	type leak struct {
+		stack string
+		count int
+		size  int
+	}
+	leaks := map[string]*leak{}
+
+	a.mtx.Lock()
+	for ptr, alloc := range a.allocs {
+		if _, ok := except[ptr]; ok {
+			continue
+		}
+		stack := formatStack(alloc.stack)
+		l, ok := leaks[stack]
+		if !ok {
+			l = &leak{stack: stack}
+			leaks[stack] = l
+		}
+		l.count++
+		l.size += alloc.size
+	}
+	a.mtx.Unlock()
+	if len(leaks) == 0 {
+		return nil
+	}
+
+	sorted := make([]*leak, 0, len(leaks))
+	for _, l := range leaks {
+		sorted = append(sorted, l)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].size != sorted[j].size {
+			return sorted[i].size > sorted[j].size
+		}
+		return sorted[i].stack < sorted[j].stack
+	})
+	errs := make([]error, 0, len(sorted))
+	for _, l := range sorted {
+		errs = append(errs, fmt.Errorf("%d bytes in %d unreleased buffers allocated at:\n%s", l.size, l.count, l.stack))
+	}
+	return fmt.Errorf("memory leak: %w", errors.Join(errs...))
+}
+
+func formatStack(stack []uintptr) string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(stack)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&sb, "\t%s\n\t\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// WithLeakChecks makes the execution of the plan fail if the operators of the
+// plan don't release all the memory they allocated once they are closed. The
+// error reports the stacks the unreleased buffers were allocated at. Buffers
+// of the records passed to the callback of the plan are not reported, as the
+// caller may retain them. Leak checks are always enabled in race builds.
+func WithLeakChecks() Option {
+	return func(o *execOptions) {
+		o.leakChecks = true
+	}
+}
+
+// leakChecks tracks the allocations of the executions of a plan.
+type leakChecks struct {
+	mtx        sync.Mutex
+	allocators []*LeakCheckAllocator
+	// output are the buffers of the records passed to the callback of the
+	// plan.
+	output map[unsafe.Pointer]struct{}
+}
+
+// wrap returns the allocator wrapped in a LeakCheckAllocator whose leaks are
+// checked.
+func (c *leakChecks) wrap(allocator memory.Allocator) *LeakCheckAllocator {
+	a := NewLeakCheckAllocator(allocator)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.allocators = append(c.allocators, a)
+	return a
+}
+
+// outputRecord records the buffers of a record passed to the callback of the
+// plan.
+func (c *leakChecks) outputRecord(r arrow.Record) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.output == nil {
+		c.output = map[unsafe.Pointer]struct{}{}
+	}
+	for _, col := range r.Columns() {
+		c.outputData(col.Data())
+	}
+}
+
+func (c *leakChecks) outputData(data arrow.ArrayData) {
+	if d, ok := data.(*array.Data); data == nil || (ok && d == nil) {
+		return
+	}
+	for _, buf := range data.Buffers() {
+		if buf != nil && buf.Buf() != nil {
+			c.output[unsafe.Pointer(unsafe.SliceData(buf.Buf()))] = struct{}{}
+		}
+	}
+	for _, child := range data.Children() {
+		c.outputData(child)
+	}
+	c.outputData(data.Dictionary())
+}
+
+// verify returns the leaks of the allocators.
+func (c *leakChecks) verify() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	errs := make([]error, 0, len(c.allocators))
+	for _, a := range c.allocators {
+		errs = append(errs, a.checkLeaks(c.output))
+	}
+	return errors.Join(errs...)
+}
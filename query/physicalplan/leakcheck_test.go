@@ -0,0 +1,56 @@
+package physicalplan
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeakCheckAllocator(t *testing.T) {
+	mem := NewLeakCheckAllocator(memory.DefaultAllocator)
+
+	b := array.NewInt64Builder(mem)
+	b.AppendValues([]int64{1, 2, 3}, nil)
+	arr := b.NewArray()
+	b.Release()
+	require.Greater(t, mem.Allocated(), 0)
+
+	err := mem.CheckLeaks()
+	require.ErrorContains(t, err, "memory leak")
+	require.ErrorContains(t, err, "TestLeakCheckAllocator")
+
+	arr.Release()
+	require.Equal(t, 0, mem.Allocated())
+	require.NoError(t, mem.CheckLeaks())
+}
+
+func TestLeakChecksOutputRecord(t *testing.T) {
+	checks := &leakChecks{}
+	mem := checks.wrap(memory.DefaultAllocator)
+
+	b := array.NewInt64Builder(mem)
+	defer b.Release()
+	b.AppendValues([]int64{1, 2, 3}, nil)
+	arr := b.NewArray()
+	defer arr.Release()
+	r := array.NewRecord(
+		arrow.NewSchema([]arrow.Field{{Name: "a", Type: arrow.PrimitiveTypes.Int64}}, nil),
+		[]arrow.Array{arr},
+		int64(arr.Len()),
+	)
+	defer r.Release()
+
+	require.ErrorContains(t, checks.verify(), "memory leak")
+
+	// The caller may retain the records passed to the callback of the plan.
+	output := &OutputPlan{
+		leaks:    checks,
+		callback: func(context.Context, arrow.Record) error { return nil },
+	}
+	require.NoError(t, output.Callback(context.Background(), r))
+	require.NoError(t, checks.verify())
+}
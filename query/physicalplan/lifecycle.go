@@ -0,0 +1,163 @@
+package physicalplan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/apache/arrow/go/v14/arrow"
+)
+
+// Opener is implemented by operators that need to acquire resources before
+// they receive records. The executor opens all operators of a plan that
+// implement Opener, the operators closer to the output first, before the scan
+// of the plan starts. If opening an operator fails the plan isn't executed and
+// its operators are closed.
+type Opener interface {
+	Open(ctx context.Context) error
+}
+
+// WithLifecycleChecks enforces the lifecycle of the operators of the plan,
+// which is meant for testing plans and custom operators. Every operator must
+// be opened, receive records in Callback, be finished and be closed by each of
+// its inputs, in that order. Callbacks before Open or after Finish or Close,
+// and repeated Open, Finish or Close calls, fail the execution. Operators that
+// are not closed, which would leak the memory they hold, are reported once the
+// plan is executed.
+func WithLifecycleChecks() Option {
+	return func(o *execOptions) {
+		o.lifecycleChecks = true
+	}
+}
+
+// lifecycleChecks tracks the lifecycle of the operators of a plan.
+type lifecycleChecks struct {
+	operators []*lifecycleChecker
+
+	mtx        sync.Mutex
+	violations []error
+}
+
+// wrap returns the operator wrapped in a checker of its lifecycle. The
+// operator expects Finish and Close calls from each of its inputs.
+func (c *lifecycleChecks) wrap(op PhysicalPlan, inputs int) PhysicalPlan {
+	checker := &lifecycleChecker{
+		op:     op,
+		name:   fmt.Sprintf("%T", op),
+		inputs: inputs,
+		checks: c,
+	}
+	c.operators = append(c.operators, checker)
+	return checker
+}
+
+func (c *lifecycleChecks) violation(format string, args ...any) error {
+	err := fmt.Errorf("operator lifecycle violation: "+format, args...)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.violations = append(c.violations, err)
+	return err
+}
+
+// verify returns the violations of the execution of the plan. Unless the
+// execution failed all operators must have been finished, and all operators
+// must have been closed regardless.
+func (c *lifecycleChecks) verify(executed bool) error {
+	for _, op := range c.operators {
+		op.mtx.Lock()
+		if executed && op.finished != op.inputs {
+			_ = c.violation("%s finished %d times by %d inputs", op.name, op.finished, op.inputs)
+		}
+		if op.closed != op.inputs {
+			_ = c.violation("%s closed %d times by %d inputs", op.name, op.closed, op.inputs)
+		}
+		op.mtx.Unlock()
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return errors.Join(c.violations...)
+}
+
+// lifecycleChecker wraps an operator to check its lifecycle.
+type lifecycleChecker struct {
+	op     PhysicalPlan
+	name   string
+	inputs int
+	checks *lifecycleChecks
+
+	mtx      sync.Mutex
+	opened   bool
+	finished int
+	closed   int
+}
+
+func (l *lifecycleChecker) Open(ctx context.Context) error {
+	l.mtx.Lock()
+	if l.opened {
+		l.mtx.Unlock()
+		return l.checks.violation("%s opened more than once", l.name)
+	}
+	l.opened = true
+	l.mtx.Unlock()
+
+	if opener, ok := l.op.(Opener); ok {
+		return opener.Open(ctx)
+	}
+	return nil
+}
+
+func (l *lifecycleChecker) Callback(ctx context.Context, r arrow.Record) error {
+	l.mtx.Lock()
+	switch {
+	case !l.opened:
+		l.mtx.Unlock()
+		return l.checks.violation("%s received a record before it was opened", l.name)
+	case l.closed > 0:
+		l.mtx.Unlock()
+		return l.checks.violation("%s received a record after it was closed", l.name)
+	case l.finished == l.inputs:
+		l.mtx.Unlock()
+		return l.checks.violation("%s received a record after it was finished", l.name)
+	}
+	l.mtx.Unlock()
+
+	return l.op.Callback(ctx, r)
+}
+
+func (l *lifecycleChecker) Finish(ctx context.Context) error {
+	l.mtx.Lock()
+	switch {
+	case l.closed > 0:
+		l.mtx.Unlock()
+		return l.checks.violation("%s finished after it was closed", l.name)
+	case l.finished == l.inputs:
+		l.mtx.Unlock()
+		return l.checks.violation("%s finished more than once per input", l.name)
+	}
+	l.finished++
+	l.mtx.Unlock()
+
+	return l.op.Finish(ctx)
+}
+
+func (l *lifecycleChecker) Close() {
+	l.mtx.Lock()
+	if l.closed == l.inputs {
+		l.mtx.Unlock()
+		_ = l.checks.violation("%s closed more than once per input", l.name)
+		return
+	}
+	l.closed++
+	l.mtx.Unlock()
+
+	l.op.Close()
+}
+
+func (l *lifecycleChecker) SetNext(next PhysicalPlan) {
+	l.op.SetNext(next)
+}
+
+func (l *lifecycleChecker) Draw() *Diagram {
+	return l.op.Draw()
+}
@@ -0,0 +1,99 @@
+package physicalplan
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+type mockOpener struct {
+	mockPhysicalPlan
+	err    error
+	opened bool
+}
+
+func (m *mockOpener) Open(_ context.Context) error {
+	m.opened = true
+	return m.err
+}
+
+func TestLifecycleChecksExecute(t *testing.T) {
+	concurrency := concurrencyHardcoded
+	concurrencyHardcoded = 4
+	defer func() { concurrencyHardcoded = concurrency }()
+
+	p, err := (&logicalplan.Builder{}).
+		Scan(&mockTableProvider{schema: dynparquet.NewSampleSchema()}, "table1").
+		Filter(logicalplan.Col("labels.test").Eq(logicalplan.Literal("abc"))).
+		Distinct(logicalplan.Col("stacktrace")).
+		Build()
+	require.NoError(t, err)
+
+	output, err := Build(
+		context.Background(),
+		memory.DefaultAllocator,
+		trace.NewNoopTracerProvider().Tracer(""),
+		dynparquet.NewSampleSchema(),
+		p,
+		WithLifecycleChecks(),
+	)
+	require.NoError(t, err)
+	require.NoError(t, output.Execute(context.Background(), memory.DefaultAllocator, func(context.Context, arrow.Record) error {
+		return nil
+	}))
+
+	// Scan outputs, filters and distincts for each input, a synchronizer,
+	// the final distinct and the output.
+	require.Len(t, output.checks.operators, 4*3+3)
+	for _, op := range output.checks.operators {
+		require.Equal(t, op.inputs, op.finished, op.name)
+		require.Equal(t, op.inputs, op.closed, op.name)
+	}
+}
+
+func TestLifecycleChecksViolations(t *testing.T) {
+	ctx := context.Background()
+	checks := &lifecycleChecks{}
+	op := checks.wrap(&noopOperator{next: &OutputPlan{
+		callback: func(context.Context, arrow.Record) error { return nil },
+	}}, 1)
+
+	require.ErrorContains(t, op.Callback(ctx, nil), "received a record before it was opened")
+	require.NoError(t, op.(Opener).Open(ctx))
+	require.ErrorContains(t, op.(Opener).Open(ctx), "opened more than once")
+	require.NoError(t, op.Callback(ctx, nil))
+	require.NoError(t, op.Finish(ctx))
+	require.ErrorContains(t, op.Callback(ctx, nil), "received a record after it was finished")
+	require.ErrorContains(t, op.Finish(ctx), "finished more than once per input")
+
+	err := checks.verify(true)
+	require.ErrorContains(t, err, "closed 0 times by 1 inputs")
+
+	op.Close()
+	op.Close()
+	require.ErrorContains(t, checks.verify(true), "closed more than once per input")
+	require.ErrorContains(t, op.Callback(ctx, nil), "received a record after it was closed")
+}
+
+func TestOpenerErrorClosesPlan(t *testing.T) {
+	opener := &mockOpener{err: errors.New("open failed")}
+	scan := &TableScan{plans: []PhysicalPlan{opener}}
+	output := &OutputPlan{scan: scan}
+	opener.SetNext(output)
+	output.operators = []PhysicalPlan{opener, output}
+	output.checks = &lifecycleChecks{}
+
+	err := output.Execute(context.Background(), memory.DefaultAllocator, func(context.Context, arrow.Record) error {
+		return nil
+	})
+	require.ErrorIs(t, err, opener.err)
+	require.True(t, opener.opened)
+}
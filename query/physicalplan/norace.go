@@ -0,0 +1,5 @@
+//go:build !race
+
+package physicalplan
+
+const raceEnabled = false
@@ -47,6 +47,7 @@ type OrderedAggregate struct {
 	resultColumnName      string
 	groupByColumnMatchers []logicalplan.Expr
 	aggregationFunction   logicalplan.AggFunc
+	boundaries            []float64
 	next                  PhysicalPlan
 	columnToAggregate     logicalplan.Expr
 	// Indicate is this is the last aggregation or if this is an aggregation
@@ -120,6 +121,7 @@ func NewOrderedAggregate(
 		// matches.
 		groupByColumnMatchers: groupByColumnMatchers,
 		aggregationFunction:   aggregation.function,
+		boundaries:            aggregation.boundaries,
 		finalStage:            finalStage,
 		curGroup:              make(map[string]any, 10),
 
@@ -134,6 +136,21 @@ func NewOrderedAggregate(
 }
 
 func (a *OrderedAggregate) Close() {
+	for _, groups := range a.groupResults {
+		for _, arr := range groups {
+			arr.Release()
+		}
+	}
+	for _, arr := range a.aggregationResults {
+		arr.Release()
+	}
+	a.aggResultBuilder.Release()
+	for _, b := range a.groupBuilders {
+		b.Release()
+	}
+	if a.arrayToAggCarry != nil {
+		a.arrayToAggCarry.Release()
+	}
 	a.next.Close()
 }
 
@@ -306,10 +323,10 @@ func (a *OrderedAggregate) Callback(_ context.Context, r arrow.Record) error {
 			// for now. We should eventually care about this.
 			// TODO(asubiotto): Instead of doing this copy, what would the
 			// performance difference be if we just merged the aggregation?
-			if err := builder.AppendArray(
-				a.arrayToAggCarry,
-				array.NewSlice(columnToAggregate, groupStart, int64(columnToAggregate.Len())),
-			); err != nil {
+			carry := array.NewSlice(columnToAggregate, groupStart, int64(columnToAggregate.Len()))
+			err := builder.AppendArray(a.arrayToAggCarry, carry)
+			carry.Release()
+			if err != nil {
 				return err
 			}
 			break
@@ -324,7 +341,9 @@ func (a *OrderedAggregate) Callback(_ context.Context, r arrow.Record) error {
 		} else {
 			toAgg = array.NewSlice(columnToAggregate, groupStart, groupEnd)
 			if a.arrayToAggCarry.Len() > 0 {
-				if err := builder.AppendArray(a.arrayToAggCarry, toAgg); err != nil {
+				err := builder.AppendArray(a.arrayToAggCarry, toAgg)
+				toAgg.Release()
+				if err != nil {
 					return err
 				}
 				toAgg = a.arrayToAggCarry.NewArray()
@@ -364,6 +383,7 @@ func (a *OrderedAggregate) Callback(_ context.Context, r arrow.Record) error {
 				// Since we're accumulating the group results until the call
 				// to Finish, it is unsafe to reuse this builder since the
 				// underlying buffers are reused, so allocate a new one.
+				a.groupBuilders[field.Name].Release()
 				a.groupBuilders[field.Name] = builder.NewBuilder(a.pool, arr.DataType())
 				a.groupResults[n] = append(a.groupResults[n], arr)
 			}
@@ -377,10 +397,14 @@ func (a *OrderedAggregate) Callback(_ context.Context, r arrow.Record) error {
 		return nil
 	}
 
-	results, err := runAggregation(a.finalStage, a.aggregationFunction, a.pool, arraysToAggregate)
+	results, err := runAggregation(a.finalStage, a.aggregationFunction, a.boundaries, a.pool, arraysToAggregate)
+	for _, arr := range arraysToAggregate {
+		arr.Release()
+	}
 	if err != nil {
 		return err
 	}
+	defer results.Release()
 
 	// Supporting partial ordering implies the need to accumulate all the
 	// results since any group might reoccur at any point in future records.
@@ -431,9 +455,11 @@ func (a *OrderedAggregate) Finish(ctx context.Context) error {
 			a.groupResults[n] = append(a.groupResults[n], b.NewArray())
 		}
 
+		carry := a.arrayToAggCarry.NewArray()
 		results, err := runAggregation(
-			a.finalStage, a.aggregationFunction, a.pool, []arrow.Array{a.arrayToAggCarry.NewArray()},
+			a.finalStage, a.aggregationFunction, a.boundaries, a.pool, []arrow.Array{carry},
 		)
+		carry.Release()
 		if err != nil {
 			return err
 		}
@@ -462,17 +488,22 @@ func (a *OrderedAggregate) Finish(ctx context.Context) error {
 	)
 
 	records := make([]arrow.Record, 0, len(a.groupResults))
+	defer func() {
+		for _, r := range records {
+			r.Release()
+		}
+	}()
 	for i := range a.groupResults {
 		records = append(
 			records,
-			array.NewRecord(
+			NewRefRecord(array.NewRecord(
 				schema,
 				append(
 					a.groupResults[i],
 					a.aggregationResults[i],
 				),
 				int64(a.aggregationResults[i].Len()),
-			),
+			)),
 		)
 	}
 
@@ -486,10 +517,12 @@ func (a *OrderedAggregate) Finish(ctx context.Context) error {
 		for i := range orderByCols {
 			orderByCols[i] = i
 		}
-		mergedRecord, err := arrowutils.MergeRecords(a.pool, records, orderByCols)
+		merged, err := arrowutils.MergeRecords(a.pool, records, orderByCols)
 		if err != nil {
 			return err
 		}
+		mergedRecord := NewRefRecord(merged)
+		defer mergedRecord.Release()
 		firstGroup := make([]any, len(a.groupColOrdering))
 		groupArrs := mergedRecord.Columns()[:len(a.groupColOrdering)]
 		for i, arr := range groupArrs {
@@ -526,7 +559,10 @@ func (a *OrderedAggregate) Finish(ctx context.Context) error {
 			start = end
 		}
 
-		result, err := runAggregation(true, a.aggregationFunction, a.pool, toAggregate)
+		result, err := runAggregation(true, a.aggregationFunction, a.boundaries, a.pool, toAggregate)
+		for _, arr := range toAggregate {
+			arr.Release()
+		}
 		if err != nil {
 			return err
 		}
@@ -535,14 +571,14 @@ func (a *OrderedAggregate) Finish(ctx context.Context) error {
 		for _, field := range a.groupColOrdering {
 			groups = append(groups, a.groupBuilders[field.Name].NewArray())
 		}
-		if err := a.next.Callback(
-			ctx,
-			array.NewRecord(
-				schema,
-				append(groups, result),
-				int64(result.Len()),
-			),
-		); err != nil {
+		cols := append(groups, result)
+		r := NewRefRecord(array.NewRecord(schema, cols, int64(result.Len())))
+		for _, col := range cols {
+			col.Release()
+		}
+		err = a.next.Callback(ctx, r)
+		r.Release()
+		if err != nil {
 			return err
 		}
 	}
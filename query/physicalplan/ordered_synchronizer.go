@@ -37,6 +37,8 @@ type OrderedSynchronizer struct {
 		// inputsRunning is an integer that keeps track of the number of inputs
 		// that have not called Finish yet.
 		inputsRunning int
+		// inputsOpen is the number of inputs that have not called Close yet.
+		inputsOpen int
 	}
 	wait chan struct{}
 	next PhysicalPlan
@@ -49,14 +51,34 @@ func NewOrderedSynchronizer(pool memory.Allocator, inputs int, orderByExprs []lo
 		wait:         make(chan struct{}),
 	}
 	o.sync.inputsRunning = inputs
+	o.sync.inputsOpen = inputs
 	return o
 }
 
 func (o *OrderedSynchronizer) Close() {
+	o.sync.mtx.Lock()
+	o.sync.inputsOpen--
+	open := o.sync.inputsOpen
+	o.sync.mtx.Unlock()
+	if open < 0 {
+		panic("too many OrderedSynchronizer Close calls")
+	}
+	if open > 0 {
+		return
+	}
+	o.sync.mtx.Lock()
+	for _, r := range o.sync.data {
+		r.Release()
+	}
+	o.sync.data = nil
+	o.sync.mtx.Unlock()
 	o.next.Close()
 }
 
 func (o *OrderedSynchronizer) Callback(ctx context.Context, r arrow.Record) error {
+	// The record is merged once all inputs pushed a record, possibly by
+	// another input.
+	r.Retain()
 	o.sync.mtx.Lock()
 	o.sync.data = append(o.sync.data, r)
 	o.sync.inputsWaiting++
@@ -82,6 +104,8 @@ func (o *OrderedSynchronizer) Callback(ctx context.Context, r arrow.Record) erro
 		return err
 	}
 
+	defer mergedRecord.Release()
+
 	// Note that we hold the mutex while calling Callback because we want to
 	// ensure that Callback is called in an ordered fashion since we could race
 	// with a call to Callback in Finish.
@@ -100,6 +124,7 @@ func (o *OrderedSynchronizer) Finish(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
+		defer mergedRecord.Release()
 		return o.next.Callback(ctx, mergedRecord)
 	}
 	if running < 0 {
@@ -117,7 +142,7 @@ func (o *OrderedSynchronizer) mergeRecordsLocked() (arrow.Record, error) {
 	if err := o.ensureSameSchema(o.sync.data); err != nil {
 		return nil, err
 	}
-	mergedRecord, err := arrowutils.MergeRecords(o.pool, o.sync.data, o.orderByCols)
+	merged, err := arrowutils.MergeRecords(o.pool, o.sync.data, o.orderByCols)
 	if err != nil {
 		return nil, err
 	}
@@ -132,14 +157,18 @@ func (o *OrderedSynchronizer) mergeRecordsLocked() (arrow.Record, error) {
 	}
 	// Reset inputsWaiting.
 	o.sync.inputsWaiting = 0
+	for _, r := range o.sync.data {
+		r.Release()
+	}
 	o.sync.data = o.sync.data[:0]
-	return mergedRecord, nil
+	return NewRefRecord(merged), nil
 }
 
 // ensureSameSchema ensures that all the records have the same schema. In cases
 // where the schema is not equal, virtual null columns are inserted in the
 // records with the missing column. When we have static schemas in the execution
-// engine, steps like these should be unnecessary.
+// engine, steps like these should be unnecessary. The records that are
+// replaced are released.
 func (o *OrderedSynchronizer) ensureSameSchema(records []arrow.Record) error {
 	var needSchemaRecalculation bool
 	for i := range records {
@@ -234,7 +263,9 @@ func (o *OrderedSynchronizer) ensureSameSchema(records []arrow.Record) error {
 			}
 		}
 
-		records[i] = array.NewRecord(schema, columns, records[i].NumRows())
+		converted := array.NewRecord(schema, columns, records[i].NumRows())
+		records[i].Release()
+		records[i] = converted
 	}
 	o.sync.lastSchema = schema
 	return nil
@@ -245,5 +276,9 @@ func (o *OrderedSynchronizer) SetNext(next PhysicalPlan) {
 }
 
 func (o *OrderedSynchronizer) Draw() *Diagram {
-	return &Diagram{Details: "OrderedSynchronizer", Child: o.next.Draw()}
+	var child *Diagram
+	if o.next != nil {
+		child = o.next.Draw()
+	}
+	return &Diagram{Details: "OrderedSynchronizer", Child: child}
 }
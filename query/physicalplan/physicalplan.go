@@ -2,17 +2,21 @@ package physicalplan
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"hash/maphash"
 	"runtime"
+	"slices"
 
 	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
 	"github.com/apache/arrow/go/v14/arrow/memory"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/pqarrow"
 	"github.com/polarsignals/frostdb/query/logicalplan"
 	"github.com/polarsignals/frostdb/recovery"
 )
@@ -20,6 +24,17 @@ import (
 // TODO: Make this smarter.
 var concurrencyHardcoded = runtime.GOMAXPROCS(0)
 
+// PhysicalPlan is an operator of a physical plan. Operators are opened if
+// they implement Opener, then receive records in Callback, are finished once
+// by each of their inputs when no more records follow, and are closed once by
+// each of their inputs to release the memory they hold, also if the execution
+// fails. Operators pass Finish and Close on to the next operator.
+//
+// The record passed to Callback is owned by the caller, which releases it
+// once Callback returns. Operators that keep the record, or its columns, for
+// longer must Retain it. Operators pass the records they create on as a
+// RefRecord, which reports records released more than once or used after
+// they were released.
 type PhysicalPlan interface {
 	Callback(ctx context.Context, r arrow.Record) error
 	Finish(ctx context.Context) error
@@ -56,6 +71,24 @@ func (f PostPlanVisitorFunc) PostVisit(plan *logicalplan.LogicalPlan) bool {
 type OutputPlan struct {
 	callback func(ctx context.Context, r arrow.Record) error
 	scan     ScanPhysicalPlan
+
+	// stableColumnOrder orders the columns of the output records by the
+	// schema and adds a schema fingerprint.
+	stableColumnOrder bool
+	schema            *dynparquet.Schema
+	// outputTypes are the types the output records are converted to, if
+	// any.
+	outputTypes *pqarrow.OutputTypes
+	pool        memory.Allocator
+
+	// operators are the operators of the plan in the order they were
+	// planned, from the scan to the output.
+	operators []PhysicalPlan
+	// checks enforces the lifecycle of the operators, if enabled.
+	checks *lifecycleChecks
+	// leaks checks that the operators release the memory they allocate, if
+	// enabled.
+	leaks *leakChecks
 }
 
 func (e *OutputPlan) Draw() *Diagram {
@@ -68,6 +101,22 @@ func (e *OutputPlan) DrawString() string {
 }
 
 func (e *OutputPlan) Callback(ctx context.Context, r arrow.Record) error {
+	if e.outputTypes != nil {
+		var err error
+		converted, err := pqarrow.ConvertOutputTypes(e.pool, r, *e.outputTypes)
+		if err != nil {
+			return err
+		}
+		r = NewRefRecord(converted)
+		defer r.Release()
+	}
+	if e.stableColumnOrder {
+		r = NewRefRecord(pqarrow.OrderColumns(r, e.schema))
+		defer r.Release()
+	}
+	if e.leaks != nil {
+		e.leaks.outputRecord(r)
+	}
 	return e.callback(ctx, r)
 }
 
@@ -89,7 +138,50 @@ func (e *OutputPlan) SetNext(_ PhysicalPlan) {
 
 func (e *OutputPlan) Execute(ctx context.Context, pool memory.Allocator, callback func(ctx context.Context, r arrow.Record) error) error {
 	e.callback = callback
-	return e.scan.Execute(ctx, pool)
+	if err := e.OpenOperators(ctx); err != nil {
+		if closer, ok := e.scan.(planCloser); ok {
+			closer.closePlans()
+		}
+		if e.checks != nil {
+			return errors.Join(err, e.checks.verify(false))
+		}
+		return err
+	}
+
+	if e.leaks != nil {
+		pool = e.leaks.wrap(pool)
+	}
+	err := e.scan.Execute(ctx, pool)
+	if e.checks != nil {
+		err = errors.Join(err, e.checks.verify(err == nil))
+	}
+	if e.leaks != nil {
+		// All operators are closed once the scan returns, they must have
+		// released the memory they allocated.
+		err = errors.Join(err, e.leaks.verify())
+	}
+	return err
+}
+
+// OpenOperators opens the operators of the plan, the ones closer to the
+// output first so that they are ready before records are pushed to them.
+// Execute opens the operators, plans built on overridden inputs must be opened
+// before records are pushed to their inputs.
+func (e *OutputPlan) OpenOperators(ctx context.Context) error {
+	for i := len(e.operators) - 1; i >= 0; i-- {
+		if opener, ok := e.operators[i].(Opener); ok {
+			if err := opener.Open(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// planCloser is implemented by scans to close the operators they push to
+// without executing the scan.
+type planCloser interface {
+	closePlans()
 }
 
 type TableScan struct {
@@ -123,26 +215,35 @@ func (s *TableScan) Execute(ctx context.Context, pool memory.Allocator) error {
 	for _, plan := range s.plans {
 		callbacks = append(callbacks, plan.Callback)
 	}
-	defer func() { // Close all plans to ensure memory cleanup.
-		for _, plan := range s.plans {
-			plan.Close()
-		}
-	}()
+	defer s.closePlans() // Close all plans to ensure memory cleanup.
 
 	opts := []logicalplan.Option{
 		logicalplan.WithPhysicalProjection(s.options.PhysicalProjection...),
 		logicalplan.WithProjection(s.options.Projection...),
 		logicalplan.WithFilter(s.options.Filter),
 		logicalplan.WithDistinctColumns(s.options.Distinct...),
+		logicalplan.WithMinMaxColumns(s.options.MinMax...),
+		logicalplan.WithPreAggregation(s.options.PreAggregation),
 	}
 
 	if s.options.SkipSources {
 		opts = append(opts, logicalplan.WithInMemoryOnly())
 	}
+	if s.options.UnifySchema {
+		opts = append(opts, logicalplan.WithUnifiedSchema())
+	}
+
+	if err := checkSnapshot(table, s.options.AsOfTx); err != nil {
+		return err
+	}
+	if len(s.options.Count) > 0 {
+		return s.executeCount(ctx, pool, table, opts)
+	}
 
 	errg, _ := errgroup.WithContext(ctx)
 	errg.Go(recovery.Do(func() error {
 		return table.View(ctx, func(ctx context.Context, tx uint64) error {
+			tx = asOfTx(tx, s.options.AsOfTx)
 			return table.Iterator(
 				ctx,
 				tx,
@@ -155,18 +256,96 @@ func (s *TableScan) Execute(ctx context.Context, pool memory.Allocator) error {
 	if err := errg.Wait(); err != nil {
 		return err
 	}
+	if err := checkSnapshot(table, s.options.AsOfTx); err != nil {
+		return err
+	}
 
-	errg, _ = errgroup.WithContext(ctx)
+	// Finish with the context of the group, so that inputs waiting for each
+	// other, e.g. in an ordered synchronizer, are canceled if one fails.
+	errg, finishCtx := errgroup.WithContext(ctx)
 	for _, plan := range s.plans {
 		plan := plan
 		errg.Go(recovery.Do(func() (err error) {
-			return plan.Finish(ctx)
+			return plan.Finish(finishCtx)
 		}))
 	}
 
 	return errg.Wait()
 }
 
+// executeCount counts the rows of the table from its metadata and passes the
+// results of the count aggregations of the scan to its single plan.
+func (s *TableScan) executeCount(ctx context.Context, pool memory.Allocator, table logicalplan.TableReader, opts []logicalplan.Option) error {
+	counter, ok := table.(logicalplan.RowCounter)
+	if !ok {
+		return fmt.Errorf("table %s cannot count its rows", s.options.TableName)
+	}
+	var rows int64
+	if err := table.View(ctx, func(ctx context.Context, tx uint64) error {
+		var err error
+		rows, err = counter.RowCount(ctx, asOfTx(tx, s.options.AsOfTx), opts...)
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := checkSnapshot(table, s.options.AsOfTx); err != nil {
+		return err
+	}
+
+	// Aggregations of no rows have no results.
+	if rows > 0 {
+		fields := make([]arrow.Field, 0, len(s.options.Count))
+		cols := make([]arrow.Array, 0, len(s.options.Count))
+		defer func() {
+			for _, col := range cols {
+				col.Release()
+			}
+		}()
+		b := array.NewInt64Builder(pool)
+		defer b.Release()
+		for _, count := range s.options.Count {
+			fields = append(fields, arrow.Field{Name: count.Name(), Type: arrow.PrimitiveTypes.Int64})
+			b.Append(rows)
+			cols = append(cols, b.NewArray())
+		}
+		r := NewRefRecord(array.NewRecord(arrow.NewSchema(fields, nil), cols, 1))
+		defer r.Release()
+		if err := s.plans[0].Callback(ctx, r); err != nil {
+			return err
+		}
+	}
+	return s.plans[0].Finish(ctx)
+}
+
+func (s *TableScan) closePlans() {
+	for _, plan := range s.plans {
+		plan.Close()
+	}
+}
+
+// asOfTx returns the transaction a scan reads a table as of, given the
+// transaction of the read and the AsOfTx of the scan.
+func asOfTx(tx, asOf uint64) uint64 {
+	if asOf != 0 && asOf < tx {
+		return asOf
+	}
+	return tx
+}
+
+// checkSnapshot returns ErrSnapshotUnavailable if the table can no longer be
+// read as of the AsOfTx of a scan. It is checked both before and after the
+// table is read, since compaction or persistence may run concurrently.
+func checkSnapshot(table logicalplan.TableReader, asOf uint64) error {
+	horizon, ok := table.(logicalplan.SnapshotHorizon)
+	if !ok || asOf == 0 {
+		return nil
+	}
+	if h := horizon.SnapshotHorizon(); asOf < h {
+		return fmt.Errorf("%w: tx %d is older than the snapshot horizon %d", logicalplan.ErrSnapshotUnavailable, asOf, h)
+	}
+	return nil
+}
+
 type SchemaScan struct {
 	tracer  trace.Tracer
 	options *logicalplan.SchemaScan
@@ -191,6 +370,7 @@ func (s *SchemaScan) Execute(ctx context.Context, pool memory.Allocator) error {
 	for _, plan := range s.plans {
 		callbacks = append(callbacks, plan.Callback)
 	}
+	defer s.closePlans() // Close all plans to ensure memory cleanup.
 
 	opts := []logicalplan.Option{
 		logicalplan.WithPhysicalProjection(s.options.PhysicalProjection...),
@@ -203,9 +383,13 @@ func (s *SchemaScan) Execute(ctx context.Context, pool memory.Allocator) error {
 		opts = append(opts, logicalplan.WithInMemoryOnly())
 	}
 
+	if err := checkSnapshot(table, s.options.AsOfTx); err != nil {
+		return err
+	}
 	errg, _ := errgroup.WithContext(ctx)
 	errg.Go(recovery.Do(func() error {
 		return table.View(ctx, func(ctx context.Context, tx uint64) error {
+			tx = asOfTx(tx, s.options.AsOfTx)
 			return table.SchemaIterator(
 				ctx,
 				tx,
@@ -218,6 +402,9 @@ func (s *SchemaScan) Execute(ctx context.Context, pool memory.Allocator) error {
 	if err := errg.Wait(); err != nil {
 		return err
 	}
+	if err := checkSnapshot(table, s.options.AsOfTx); err != nil {
+		return err
+	}
 
 	errg, _ = errgroup.WithContext(ctx)
 	for _, plan := range s.plans {
@@ -230,6 +417,12 @@ func (s *SchemaScan) Execute(ctx context.Context, pool memory.Allocator) error {
 	return errg.Wait()
 }
 
+func (s *SchemaScan) closePlans() {
+	for _, plan := range s.plans {
+		plan.Close()
+	}
+}
+
 type noopOperator struct {
 	next PhysicalPlan
 }
@@ -259,8 +452,14 @@ func (p *noopOperator) Draw() *Diagram {
 
 type execOptions struct {
 	orderedAggregations bool
+	partialAggregations bool
 	overrideInput       []PhysicalPlan
 	skipSources         bool
+	unifySchema         bool
+	stableColumnOrder   bool
+	outputTypes         *pqarrow.OutputTypes
+	lifecycleChecks     bool
+	leakChecks          bool
 }
 
 type Option func(o *execOptions)
@@ -271,12 +470,47 @@ func WithInMemoryOnly() Option {
 	}
 }
 
+// WithUnifiedSchema makes table scans return all records with the same schema,
+// padding the columns missing from the parts they were read from with nulls.
+func WithUnifiedSchema() Option {
+	return func(o *execOptions) {
+		o.unifySchema = true
+	}
+}
+
+// WithStableColumnOrder makes the output records of the plan have their
+// columns in a deterministic order, the schema defined columns first with
+// dynamic columns ordered by name, and their schema carry a fingerprint under
+// pqarrow.SchemaFingerprintKey so that clients can cache column mappings.
+func WithStableColumnOrder() Option {
+	return func(o *execOptions) {
+		o.stableColumnOrder = true
+	}
+}
+
+// WithOutputTypes converts the output records of the plan to the given types.
+func WithOutputTypes(types pqarrow.OutputTypes) Option {
+	return func(o *execOptions) {
+		o.outputTypes = &types
+	}
+}
+
 func WithOrderedAggregations() Option {
 	return func(o *execOptions) {
 		o.orderedAggregations = true
 	}
 }
 
+// WithPartialAggregations plans aggregations without their final stage, so
+// the plan outputs the partial aggregation results, that are to be merged by
+// a final stage aggregation elsewhere. This is used to execute aggregations
+// of distributed queries.
+func WithPartialAggregations() Option {
+	return func(o *execOptions) {
+		o.partialAggregations = true
+	}
+}
+
 // WithOverrideInput can be used to provide an input stage on top of which the
 // Build function can build the physical plan.
 func WithOverrideInput(input []PhysicalPlan) Option {
@@ -296,13 +530,69 @@ func Build(
 	_, span := tracer.Start(ctx, "PhysicalPlan/Build")
 	defer span.End()
 
+	def, err := define(tracer, s, plan, options...)
+	if err != nil {
+		return nil, err
+	}
+	outputPlan, err := def.instantiate(pool)
+	if err != nil {
+		return nil, err
+	}
+
+	if def.opts.overrideInput == nil {
+		span.SetAttributes(attribute.String("plan", outputPlan.scan.Draw().String()))
+	}
+	return outputPlan, nil
+}
+
+// definition is a physical plan whose operators are not created yet. The
+// operators are planned once, when the definition is built, e.g. the
+// expressions of filters and projections are compiled and aggregations are
+// planned as ordered or hash aggregations. The operators themselves hold the
+// state of a single execution, so they are created by instantiate for every
+// execution of the plan.
+type definition struct {
+	tracer trace.Tracer
+	schema *dynparquet.Schema
+	opts   execOptions
+	// stages create the operators of the plan, from the scan to the output.
+	stages []func(in *instance) error
+}
+
+// instance is a physical plan whose operators are being created.
+type instance struct {
+	pool   memory.Allocator
+	tracer trace.Tracer
+	output *OutputPlan
+	// prev are the operators the next stage pushes records to.
+	prev []PhysicalPlan
+}
+
+// track records an operator of the plan that receives records from the
+// given number of inputs.
+func (in *instance) track(op PhysicalPlan, inputs int) PhysicalPlan {
+	if in.output.checks != nil {
+		op = in.output.checks.wrap(op, inputs)
+	}
+	in.output.operators = append(in.output.operators, op)
+	return op
+}
+
+func define(
+	tracer trace.Tracer,
+	s *dynparquet.Schema,
+	plan *logicalplan.LogicalPlan,
+	options ...Option,
+) (*definition, error) {
 	execOpts := execOptions{}
 	for _, o := range options {
 		o(&execOpts)
 	}
-	prev := execOpts.overrideInput
-
-	outputPlan := &OutputPlan{}
+	def := &definition{
+		tracer: tracer,
+		schema: s,
+		opts:   execOpts,
+	}
 	oInfo := &planOrderingInfo{
 		state: planOrderingInfoStateInit,
 	}
@@ -317,87 +607,125 @@ func Build(
 		oInfo.newNode()
 		switch {
 		case plan.SchemaScan != nil:
-			// Create noop operators since we don't know what to push the scan
-			// results to. In a following node visit, these noops will have
-			// SetNext called on them and push to the correct operator.
-			plans := make([]PhysicalPlan, concurrencyHardcoded)
-			for i := range plans {
-				plans[i] = &noopOperator{}
-			}
-			plan.SchemaScan.SkipSources = execOpts.skipSources
-			outputPlan.scan = &SchemaScan{
-				tracer:  tracer,
-				options: plan.SchemaScan,
-				plans:   plans,
-			}
-			prev = append(prev[:0], plans...)
+			// Copy the scan options, the logical plan may be built
+			// concurrently with different options.
+			scanOpts := *plan.SchemaScan
+			scanOpts.SkipSources = execOpts.skipSources
+			def.stages = append(def.stages, func(in *instance) error {
+				// Create noop operators since we don't know what to push the
+				// scan results to. In a following stage, these noops will
+				// have SetNext called on them and push to the correct
+				// operator.
+				plans := make([]PhysicalPlan, concurrencyHardcoded)
+				for i := range plans {
+					plans[i] = in.track(&noopOperator{}, 1)
+				}
+				scanOpts := scanOpts
+				in.output.scan = &SchemaScan{
+					tracer:  in.tracer,
+					options: &scanOpts,
+					plans:   plans,
+				}
+				in.prev = append(in.prev[:0], plans...)
+				return nil
+			})
 		case plan.TableScan != nil:
-			// Create noop operators since we don't know what to push the scan
-			// results to. In a following node visit, these noops will have
-			// SetNext called on them and push to the correct operator.
-			plans := make([]PhysicalPlan, concurrencyHardcoded)
-			for i := range plans {
-				plans[i] = &noopOperator{}
+			concurrency := concurrencyHardcoded
+			if len(plan.TableScan.Count) > 0 {
+				// Counting the rows results in a single record.
+				concurrency = 1
 			}
-			plan.TableScan.SkipSources = execOpts.skipSources
-			outputPlan.scan = &TableScan{
-				tracer:  tracer,
-				options: plan.TableScan,
-				plans:   plans,
+			scanOpts := *plan.TableScan
+			scanOpts.SkipSources = execOpts.skipSources
+			scanOpts.UnifySchema = execOpts.unifySchema
+			def.stages = append(def.stages, func(in *instance) error {
+				// Create noop operators since we don't know what to push the
+				// scan results to. In a following stage, these noops will
+				// have SetNext called on them and push to the correct
+				// operator.
+				plans := make([]PhysicalPlan, concurrency)
+				for i := range plans {
+					plans[i] = in.track(&noopOperator{}, 1)
+				}
+				scanOpts := scanOpts
+				in.output.scan = &TableScan{
+					tracer:  in.tracer,
+					options: &scanOpts,
+					plans:   plans,
+				}
+				in.prev = append(in.prev[:0], plans...)
+				return nil
+			})
+			if plan.TableScan.PreAggregation == nil {
+				// Partial results read from pre-aggregates are not in
+				// the order of the rows.
+				oInfo.nodeMaintainsOrdering()
 			}
-			prev = append(prev[:0], plans...)
-			oInfo.nodeMaintainsOrdering()
 		case plan.Projection != nil:
 			for _, e := range plan.Projection.Exprs { // Don't build the projection if it's a wildcard, the projection pushdown optimization will handle it.
 				if e.Name() == "all" {
 					return true
 				}
 			}
-			// For each previous physical plan create one Projection
-			for i := range prev {
-				p, err := Project(pool, tracer, plan.Projection.Exprs)
-				if err != nil {
-					visitErr = err
-					return false
-				}
-				prev[i].SetNext(p)
-				prev[i] = p
+			colProjections, err := projections(plan.Projection.Exprs)
+			if err != nil {
+				visitErr = err
+				return false
 			}
+			def.stages = append(def.stages, func(in *instance) error {
+				// For each previous physical plan create one Projection
+				for i := range in.prev {
+					next := in.track(newProjection(in.pool, in.tracer, colProjections), 1)
+					in.prev[i].SetNext(next)
+					in.prev[i] = next
+				}
+				return nil
+			})
 		case plan.Distinct != nil:
-			var sync *Synchronizer
-			if len(prev) > 1 {
-				// These distinct operators need to be synchronized.
-				sync = Synchronize(len(prev))
-			}
-			for i := 0; i < len(prev); i++ {
-				d := Distinct(pool, tracer, plan.Distinct.Exprs)
-				prev[i].SetNext(d)
-				prev[i] = d
+			exprs := plan.Distinct.Exprs
+			def.stages = append(def.stages, func(in *instance) error {
+				var sync PhysicalPlan
+				if len(in.prev) > 1 {
+					// These distinct operators need to be synchronized.
+					sync = in.track(Synchronize(len(in.prev)), len(in.prev))
+				}
+				for i := 0; i < len(in.prev); i++ {
+					d := in.track(Distinct(in.pool, in.tracer, exprs), 1)
+					in.prev[i].SetNext(d)
+					in.prev[i] = d
+					if sync != nil {
+						d.SetNext(sync)
+					}
+				}
 				if sync != nil {
-					d.SetNext(sync)
+					// Plan a distinct operator to run a distinct on all the
+					// synchronized distincts.
+					d := in.track(Distinct(in.pool, in.tracer, exprs), 1)
+					sync.SetNext(d)
+					in.prev = in.prev[0:1]
+					in.prev[0] = d
 				}
-			}
-			if sync != nil {
-				// Plan a distinct operator to run a distinct on all the
-				// synchronized distincts.
-				d := Distinct(pool, tracer, plan.Distinct.Exprs)
-				sync.SetNext(d)
-				prev = prev[0:1]
-				prev[0] = d
-			}
+				return nil
+			})
 		case plan.Filter != nil:
-			// Create a filter for each previous plan.
-			// Can be multiple filters or just a single
-			// filter depending on the previous concurrency.
-			for i := range prev {
-				f, err := Filter(pool, tracer, plan.Filter.Expr)
-				if err != nil {
-					visitErr = err
-					return false
-				}
-				prev[i].SetNext(f)
-				prev[i] = f
+			// The filter expression holds no state of an execution, so the
+			// filters of all executions share it.
+			expr, err := booleanExpr(plan.Filter.Expr)
+			if err != nil {
+				visitErr = err
+				return false
 			}
+			def.stages = append(def.stages, func(in *instance) error {
+				// Create a filter for each previous plan.
+				// Can be multiple filters or just a single
+				// filter depending on the previous concurrency.
+				for i := range in.prev {
+					next := in.track(newFilter(in.pool, in.tracer, expr), 1)
+					in.prev[i].SetNext(next)
+					in.prev[i] = next
+				}
+				return nil
+			})
 			oInfo.applyFilter(plan.Filter.Expr)
 			oInfo.nodeMaintainsOrdering()
 		case plan.Aggregation != nil:
@@ -406,69 +734,115 @@ func Build(
 				// TODO(asubiotto): Log the error.
 				ordered = false
 			}
-			var sync PhysicalPlan
-			if len(prev) > 1 {
-				// These aggregate operators need to be synchronized.
-				if ordered && len(plan.Aggregation.GroupExprs) > 0 {
-					sync = NewOrderedSynchronizer(pool, len(prev), plan.Aggregation.GroupExprs)
-				} else {
-					sync = Synchronize(len(prev))
-				}
+			if execOpts.partialAggregations {
+				// Partial results are merged by a hash aggregation.
+				ordered = false
+			}
+			aggregations, err := planAggregations(plan.Aggregation)
+			if err != nil {
+				visitErr = err
+				return false
 			}
-			seed := maphash.MakeSeed()
-			for i := 0; i < len(prev); i++ {
-				a, err := Aggregate(pool, tracer, plan.Aggregation, sync == nil, ordered, seed)
-				if err != nil {
-					visitErr = err
-					return false
+			groupExprs := plan.Aggregation.GroupExprs
+			def.stages = append(def.stages, func(in *instance) error {
+				var sync PhysicalPlan
+				if len(in.prev) > 1 {
+					// These aggregate operators need to be synchronized.
+					if ordered && len(groupExprs) > 0 {
+						sync = NewOrderedSynchronizer(in.pool, len(in.prev), groupExprs)
+					} else {
+						sync = Synchronize(len(in.prev))
+					}
+					sync = in.track(sync, len(in.prev))
 				}
-				prev[i].SetNext(a)
-				prev[i] = a
-				if sync != nil {
-					a.SetNext(sync)
+				seed := maphash.MakeSeed()
+				for i := 0; i < len(in.prev); i++ {
+					final := sync == nil && !execOpts.partialAggregations
+					a, err := newAggregate(in.pool, in.tracer, slices.Clone(aggregations), groupExprs, final, ordered, seed)
+					if err != nil {
+						return err
+					}
+					next := in.track(a, 1)
+					in.prev[i].SetNext(next)
+					in.prev[i] = next
+					if sync != nil {
+						next.SetNext(sync)
+					}
 				}
-			}
-			if sync != nil {
-				// Plan an aggregate operator to run an aggregation on all the
-				// aggregations.
-				a, err := Aggregate(pool, tracer, plan.Aggregation, true, ordered, seed)
-				if err != nil {
-					visitErr = err
-					return false
+				if sync != nil && execOpts.partialAggregations {
+					// The partial aggregations are the output of this plan.
+					in.prev = in.prev[0:1]
+					in.prev[0] = sync
+				} else if sync != nil {
+					// Plan an aggregate operator to run an aggregation on all
+					// the aggregations.
+					a, err := newAggregate(in.pool, in.tracer, slices.Clone(aggregations), groupExprs, true, ordered, seed)
+					if err != nil {
+						return err
+					}
+					next := in.track(a, 1)
+					sync.SetNext(next)
+					in.prev = in.prev[0:1]
+					in.prev[0] = next
 				}
-				sync.SetNext(a)
-				prev = prev[0:1]
-				prev[0] = a
-			}
+				return nil
+			})
 			if ordered {
 				oInfo.nodeMaintainsOrdering()
 			}
 		default:
-			panic("Unsupported plan")
+			visitErr = fmt.Errorf("unsupported plan: %s", plan)
+			return false
 		}
 		return visitErr == nil
 	}))
 	if visitErr != nil {
 		return nil, visitErr
 	}
+	return def, nil
+}
+
+// instantiate creates the operators of the plan for a single execution.
+func (d *definition) instantiate(pool memory.Allocator) (*OutputPlan, error) {
+	var leaks *leakChecks
+	if d.opts.leakChecks || raceEnabled {
+		leaks = &leakChecks{}
+		pool = leaks.wrap(pool)
+	}
 
-	if execOpts.overrideInput == nil {
-		span.SetAttributes(attribute.String("plan", outputPlan.scan.Draw().String()))
+	in := &instance{
+		pool:   pool,
+		tracer: d.tracer,
+		output: &OutputPlan{
+			leaks:             leaks,
+			stableColumnOrder: d.opts.stableColumnOrder,
+			schema:            d.schema,
+			outputTypes:       d.opts.outputTypes,
+			pool:              pool,
+		},
+		prev: d.opts.overrideInput,
+	}
+	if d.opts.lifecycleChecks {
+		in.output.checks = &lifecycleChecks{}
+	}
+	for _, stage := range d.stages {
+		if err := stage(in); err != nil {
+			return nil, err
+		}
 	}
 
 	// Synchronize the last stage if necessary.
-	var sync *Synchronizer
-	if len(prev) > 1 {
-		sync = Synchronize(len(prev))
-		for i := range prev {
-			prev[i].SetNext(sync)
+	if len(in.prev) > 1 {
+		sync := in.track(Synchronize(len(in.prev)), len(in.prev))
+		for i := range in.prev {
+			in.prev[i].SetNext(sync)
 		}
-		sync.SetNext(outputPlan)
+		sync.SetNext(in.track(in.output, 1))
 	} else {
-		prev[0].SetNext(outputPlan)
+		in.prev[0].SetNext(in.track(in.output, 1))
 	}
 
-	return outputPlan, nil
+	return in.output, nil
 }
 
 func shouldPlanOrderedAggregate(
@@ -482,6 +856,19 @@ func shouldPlanOrderedAggregate(
 		// More than one aggregation is not yet supported.
 		return false, nil
 	}
+	orderedBy := false
+	for _, expr := range agg.AggExprs {
+		expr.Accept(PreExprVisitorFunc(func(expr logicalplan.Expr) bool {
+			if f, ok := expr.(*logicalplan.AggregationFunction); ok && f.OrderByExpr != nil {
+				orderedBy = true
+			}
+			return true
+		}))
+	}
+	if orderedBy {
+		// First and last aggregations are not yet supported.
+		return false, nil
+	}
 	if !oInfo.orderingMaintained() {
 		return false, nil
 	}
@@ -0,0 +1,437 @@
+package physicalplan
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/pqarrow/arrowutils"
+	"github.com/polarsignals/frostdb/pqarrow/builder"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// UnpivotOperator turns the value columns of each row into rows of a name
+// and a value column, e.g. the columns "cpu" and "memory" of a row into a row
+// with the name "cpu" and its value, and one with the name "memory" and its
+// value. The other columns of a row are repeated for each of its value
+// columns. Null values don't produce rows. All value columns of a record must
+// have the same type.
+type UnpivotOperator struct {
+	pool         memory.Allocator
+	tracer       trace.Tracer
+	next         PhysicalPlan
+	valueColumns []logicalplan.Expr
+	nameColumn   string
+	valueColumn  string
+}
+
+// Unpivot returns an operator that unpivots the columns matching the given
+// expressions into rows with the name of the column in the nameColumn and
+// its value in the valueColumn.
+func Unpivot(
+	pool memory.Allocator,
+	tracer trace.Tracer,
+	valueColumns []logicalplan.Expr,
+	nameColumn string,
+	valueColumn string,
+) *UnpivotOperator {
+	return &UnpivotOperator{
+		pool:         pool,
+		tracer:       tracer,
+		valueColumns: valueColumns,
+		nameColumn:   nameColumn,
+		valueColumn:  valueColumn,
+	}
+}
+
+func (u *UnpivotOperator) SetNext(next PhysicalPlan) {
+	u.next = next
+}
+
+func (u *UnpivotOperator) Draw() *Diagram {
+	var child *Diagram
+	if u.next != nil {
+		child = u.next.Draw()
+	}
+
+	columns := make([]string, 0, len(u.valueColumns))
+	for _, c := range u.valueColumns {
+		columns = append(columns, c.Name())
+	}
+
+	return &Diagram{Details: fmt.Sprintf(
+		"Unpivot (%s into %s, %s)", strings.Join(columns, ","), u.nameColumn, u.valueColumn,
+	), Child: child}
+}
+
+func (u *UnpivotOperator) Callback(ctx context.Context, r arrow.Record) error {
+	var (
+		keyFields  []arrow.Field
+		keyArrays  []arrow.Array
+		valueNames []string
+		values     []arrow.Array
+	)
+	for i, field := range r.Schema().Fields() {
+		if u.matchColumn(field.Name) {
+			if len(values) > 0 && !arrow.TypeEqual(values[0].DataType(), field.Type) {
+				return fmt.Errorf("unpivot column %s of type %s, expected %s", field.Name, field.Type, values[0].DataType())
+			}
+			valueNames = append(valueNames, field.Name)
+			values = append(values, r.Column(i))
+			continue
+		}
+		if dynparquet.IsHashedColumn(field.Name) {
+			// The hashes of the columns of the rows don't change, but
+			// unpivoting may change the columns.
+			continue
+		}
+		keyFields = append(keyFields, field)
+		keyArrays = append(keyArrays, r.Column(i))
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	indices := array.NewInt64Builder(u.pool)
+	defer indices.Release()
+	names := array.NewBinaryBuilder(u.pool, arrow.BinaryTypes.Binary)
+	defer names.Release()
+	valueBuilder := builder.NewBuilder(u.pool, values[0].DataType())
+	defer valueBuilder.Release()
+	for i := 0; i < int(r.NumRows()); i++ {
+		for j, arr := range values {
+			if arr.IsNull(i) {
+				continue
+			}
+			indices.Append(int64(i))
+			names.AppendString(valueNames[j])
+			if err := builder.AppendValue(valueBuilder, arr, i); err != nil {
+				return err
+			}
+		}
+	}
+	if indices.Len() == 0 {
+		return nil
+	}
+
+	nameArr := names.NewArray()
+	defer nameArr.Release()
+	valueArr := valueBuilder.NewArray()
+	defer valueArr.Release()
+
+	fields := make([]arrow.Field, 0, len(keyFields)+2)
+	cols := make([]arrow.Array, 0, len(keyFields)+2)
+	if len(keyFields) > 0 {
+		keys := array.NewRecord(arrow.NewSchema(keyFields, nil), keyArrays, r.NumRows())
+		defer keys.Release()
+		indicesArr := indices.NewInt64Array()
+		defer indicesArr.Release()
+		taken, err := arrowutils.TakeRecord(u.pool, keys, indicesArr)
+		if err != nil {
+			return err
+		}
+		defer taken.Release()
+		fields = append(fields, taken.Schema().Fields()...)
+		cols = append(cols, taken.Columns()...)
+	}
+	fields = append(fields,
+		arrow.Field{Name: u.nameColumn, Type: nameArr.DataType()},
+		arrow.Field{Name: u.valueColumn, Type: valueArr.DataType(), Nullable: true},
+	)
+	cols = append(cols, nameArr, valueArr)
+	out := array.NewRecord(arrow.NewSchema(fields, nil), cols, int64(nameArr.Len()))
+	defer out.Release()
+	return u.next.Callback(ctx, out)
+}
+
+// matchColumn returns whether the column is a value column.
+func (u *UnpivotOperator) matchColumn(name string) bool {
+	for _, c := range u.valueColumns {
+		if c.MatchColumn(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *UnpivotOperator) Finish(ctx context.Context) error {
+	return u.next.Finish(ctx)
+}
+
+func (u *UnpivotOperator) Close() {
+	u.next.Close()
+}
+
+// PivotOperator is the inverse of the UnpivotOperator. It turns the rows with
+// the same values in all columns but the name and value column into a single
+// row with a column per name holding the value of the row with that name. The
+// names are a bounded set that is known upfront, rows with other names are
+// dropped. Columns of names without a row are null, of multiple rows with the
+// same name the value of the last one is kept. The rows are passed on in a
+// single record once the input finished. The operator must only be fed by a
+// single input.
+type PivotOperator struct {
+	pool        memory.Allocator
+	tracer      trace.Tracer
+	next        PhysicalPlan
+	nameColumn  string
+	valueColumn string
+	names       []string
+	nameIndices map[string]int
+
+	groups      map[string]int
+	keyCols     map[string]builder.ColumnBuilder
+	keyOrdering []string
+	// valueType is the type of the value column, set by the first record.
+	valueType arrow.DataType
+	// values are the value columns of the records with values in the pivoted
+	// rows.
+	values []arrow.Array
+	// cells are the locations of the values of each pivoted row by the index
+	// of their name.
+	cells [][]pivotCell
+	key   []byte
+}
+
+// pivotCell is the location of a value in the values of a PivotOperator.
+type pivotCell struct {
+	array int
+	row   int
+}
+
+// Pivot returns an operator that pivots the values of the valueColumn into
+// columns named by the values of the nameColumn. Only the given names become
+// columns.
+func Pivot(
+	pool memory.Allocator,
+	tracer trace.Tracer,
+	nameColumn string,
+	valueColumn string,
+	names []string,
+) *PivotOperator {
+	nameIndices := make(map[string]int, len(names))
+	for i, name := range names {
+		nameIndices[name] = i
+	}
+	return &PivotOperator{
+		pool:        pool,
+		tracer:      tracer,
+		nameColumn:  nameColumn,
+		valueColumn: valueColumn,
+		names:       names,
+		nameIndices: nameIndices,
+		groups:      map[string]int{},
+		keyCols:     map[string]builder.ColumnBuilder{},
+	}
+}
+
+func (p *PivotOperator) SetNext(next PhysicalPlan) {
+	p.next = next
+}
+
+func (p *PivotOperator) Draw() *Diagram {
+	var child *Diagram
+	if p.next != nil {
+		child = p.next.Draw()
+	}
+	return &Diagram{Details: fmt.Sprintf(
+		"Pivot (%s, %s into %s)", p.nameColumn, p.valueColumn, strings.Join(p.names, ","),
+	), Child: child}
+}
+
+func (p *PivotOperator) Callback(_ context.Context, r arrow.Record) error {
+	var (
+		names     arrow.Array
+		values    arrow.Array
+		keyFields []arrow.Field
+		keyArrays []arrow.Array
+		keyCols   []groupKeyColumn
+	)
+	fields := r.Schema().Fields()
+	for i, field := range fields {
+		switch {
+		case field.Name == p.nameColumn:
+			names = r.Column(i)
+		case field.Name == p.valueColumn:
+			values = r.Column(i)
+		case dynparquet.IsHashedColumn(field.Name):
+		default:
+			col := newGroupKeyColumn(field, r.Column(i))
+			if hashed := dynparquet.FindHashedColumn(field.Name, fields); hashed != -1 {
+				vals := make([]uint64, 0, r.NumRows())
+				for _, v := range r.Column(hashed).(*array.Int64).Int64Values() {
+					vals = append(vals, uint64(v))
+				}
+				col.hashes = vals
+			} else if _, ok := r.Column(i).(*array.List); ok {
+				col.hashes = dynparquet.HashArray(r.Column(i))
+			}
+			keyFields = append(keyFields, field)
+			keyArrays = append(keyArrays, r.Column(i))
+			keyCols = append(keyCols, col)
+		}
+	}
+	if names == nil || values == nil {
+		return fmt.Errorf("pivot columns %s and %s not found", p.nameColumn, p.valueColumn)
+	}
+	if p.valueType == nil {
+		p.valueType = values.DataType()
+	}
+	if !arrow.TypeEqual(p.valueType, values.DataType()) {
+		return fmt.Errorf("pivot value column of type %s, expected %s", values.DataType(), p.valueType)
+	}
+
+	valuesIdx := -1
+	for i := 0; i < int(r.NumRows()); i++ {
+		if names.IsNull(i) {
+			continue
+		}
+		nameIdx, ok := p.nameIndices[pivotName(names, i)]
+		if !ok {
+			continue
+		}
+
+		p.key = p.key[:0]
+		for j := range keyCols {
+			var err error
+			if p.key, err = keyCols[j].appendKey(p.key, i); err != nil {
+				return err
+			}
+		}
+		group, ok := p.groups[string(p.key)]
+		if !ok {
+			group = len(p.cells)
+			p.groups[string(p.key)] = group
+			cells := make([]pivotCell, len(p.names))
+			for j := range cells {
+				cells[j].array = -1
+			}
+			p.cells = append(p.cells, cells)
+			if err := p.appendKey(i, keyFields, keyArrays); err != nil {
+				return err
+			}
+		}
+
+		if valuesIdx == -1 {
+			values.Retain()
+			p.values = append(p.values, values)
+			valuesIdx = len(p.values) - 1
+		}
+		p.cells[group][nameIdx] = pivotCell{array: valuesIdx, row: i}
+	}
+	return nil
+}
+
+// pivotName returns the name of the i-th row.
+func pivotName(names arrow.Array, i int) string {
+	switch arr := names.(type) {
+	case *array.Binary:
+		return arr.ValueString(i)
+	case *array.String:
+		return arr.Value(i)
+	case *array.Dictionary:
+		switch dict := arr.Dictionary().(type) {
+		case *array.Binary:
+			return dict.ValueString(arr.GetValueIndex(i))
+		case *array.String:
+			return dict.Value(arr.GetValueIndex(i))
+		}
+	}
+	return ""
+}
+
+// appendKey appends the values of the key columns of the i-th row as a new
+// pivoted row.
+func (p *PivotOperator) appendKey(i int, fields []arrow.Field, arrs []arrow.Array) error {
+	for j, field := range fields {
+		col, ok := p.keyCols[field.Name]
+		if !ok {
+			col = builder.NewBuilder(p.pool, field.Type)
+			p.keyCols[field.Name] = col
+			p.keyOrdering = append(p.keyOrdering, field.Name)
+		}
+		// Backfill the rows of records without the column.
+		for col.Len() < len(p.cells)-1 {
+			col.AppendNull()
+		}
+		if err := builder.AppendValue(col, arrs[j], i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *PivotOperator) Finish(ctx context.Context) error {
+	if err := p.finish(ctx); err != nil {
+		return err
+	}
+	return p.next.Finish(ctx)
+}
+
+func (p *PivotOperator) finish(ctx context.Context) error {
+	numRows := len(p.cells)
+	if numRows == 0 {
+		return nil
+	}
+
+	fields := make([]arrow.Field, 0, len(p.keyOrdering)+len(p.names))
+	cols := make([]arrow.Array, 0, len(p.keyOrdering)+len(p.names))
+	defer func() {
+		for _, col := range cols {
+			col.Release()
+		}
+	}()
+	for _, name := range p.keyOrdering {
+		col := p.keyCols[name]
+		for col.Len() < numRows {
+			col.AppendNull()
+		}
+		arr := col.NewArray()
+		fields = append(fields, arrow.Field{Name: name, Type: arr.DataType(), Nullable: true})
+		cols = append(cols, arr)
+	}
+	for j, name := range p.names {
+		if err := func() error {
+			b := builder.NewBuilder(p.pool, p.valueType)
+			defer b.Release()
+			for _, cells := range p.cells {
+				cell := cells[j]
+				if cell.array == -1 {
+					b.AppendNull()
+					continue
+				}
+				if err := builder.AppendValue(b, p.values[cell.array], cell.row); err != nil {
+					return err
+				}
+			}
+			arr := b.NewArray()
+			fields = append(fields, arrow.Field{Name: name, Type: arr.DataType(), Nullable: true})
+			cols = append(cols, arr)
+			return nil
+		}(); err != nil {
+			return err
+		}
+	}
+
+	r := array.NewRecord(arrow.NewSchema(fields, nil), cols, int64(numRows))
+	defer r.Release()
+	return p.next.Callback(ctx, r)
+}
+
+func (p *PivotOperator) Close() {
+	for _, arr := range p.values {
+		arr.Release()
+	}
+	p.values = nil
+	for _, col := range p.keyCols {
+		col.Release()
+	}
+	p.keyCols = map[string]builder.ColumnBuilder{}
+	p.next.Close()
+}
@@ -0,0 +1,115 @@
+package physicalplan
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+func TestUnpivotPivot(t *testing.T) {
+	pool := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer pool.AssertSize(t, 0)
+	tracer := trace.NewNoopTracerProvider().Tracer("")
+
+	newRecord := func(nodes []string, cpu, memory []int64, valid []bool) arrow.Record {
+		nodeBuilder := array.NewBinaryBuilder(pool, arrow.BinaryTypes.Binary)
+		defer nodeBuilder.Release()
+		cpuBuilder := array.NewInt64Builder(pool)
+		defer cpuBuilder.Release()
+		memoryBuilder := array.NewInt64Builder(pool)
+		defer memoryBuilder.Release()
+		for _, n := range nodes {
+			nodeBuilder.AppendString(n)
+		}
+		cpuBuilder.AppendValues(cpu, nil)
+		memoryBuilder.AppendValues(memory, valid)
+		cols := []arrow.Array{nodeBuilder.NewArray(), cpuBuilder.NewArray(), memoryBuilder.NewArray()}
+		defer func() {
+			for _, c := range cols {
+				c.Release()
+			}
+		}()
+		return array.NewRecord(arrow.NewSchema([]arrow.Field{
+			{Name: "labels.node", Type: arrow.BinaryTypes.Binary},
+			{Name: "cpu", Type: arrow.PrimitiveTypes.Int64},
+			{Name: "memory", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+		}, nil), cols, int64(len(nodes)))
+	}
+
+	type unpivoted struct {
+		node, name string
+		value      int64
+	}
+	var long []unpivoted
+	type pivoted struct {
+		node        string
+		cpu, memory int64
+		hasMemory   bool
+	}
+	var wide []pivoted
+
+	unpivot := Unpivot(pool, tracer, []logicalplan.Expr{logicalplan.Col("cpu"), logicalplan.Col("memory")}, "name", "value")
+	pivot := Pivot(pool, tracer, "name", "value", []string{"cpu", "memory"})
+	unpivot.SetNext(&OutputPlan{
+		callback: func(ctx context.Context, r arrow.Record) error {
+			require.Equal(t, []string{"labels.node", "name", "value"}, []string{
+				r.Schema().Field(0).Name, r.Schema().Field(1).Name, r.Schema().Field(2).Name,
+			})
+			for i := 0; i < int(r.NumRows()); i++ {
+				long = append(long, unpivoted{
+					node:  r.Column(0).(*array.Binary).ValueString(i),
+					name:  r.Column(1).(*array.Binary).ValueString(i),
+					value: r.Column(2).(*array.Int64).Value(i),
+				})
+			}
+			return pivot.Callback(ctx, r)
+		},
+	})
+	pivot.SetNext(&OutputPlan{
+		callback: func(_ context.Context, r arrow.Record) error {
+			require.Equal(t, []string{"labels.node", "cpu", "memory"}, []string{
+				r.Schema().Field(0).Name, r.Schema().Field(1).Name, r.Schema().Field(2).Name,
+			})
+			for i := 0; i < int(r.NumRows()); i++ {
+				memory := r.Column(2).(*array.Int64)
+				wide = append(wide, pivoted{
+					node:      r.Column(0).(*array.Binary).ValueString(i),
+					cpu:       r.Column(1).(*array.Int64).Value(i),
+					memory:    memory.Value(i),
+					hasMemory: memory.IsValid(i),
+				})
+			}
+			return nil
+		},
+	})
+	defer pivot.Close()
+
+	ctx := context.Background()
+	for _, r := range []arrow.Record{
+		newRecord([]string{"a", "b"}, []int64{1, 2}, []int64{10, 0}, []bool{true, false}),
+		newRecord([]string{"c"}, []int64{3}, []int64{30}, nil),
+	} {
+		require.NoError(t, unpivot.Callback(ctx, r))
+		r.Release()
+	}
+	require.NoError(t, unpivot.Finish(ctx))
+	require.NoError(t, pivot.Finish(ctx))
+
+	require.Equal(t, []unpivoted{
+		{"a", "cpu", 1}, {"a", "memory", 10},
+		{"b", "cpu", 2},
+		{"c", "cpu", 3}, {"c", "memory", 30},
+	}, long)
+	require.Equal(t, []pivoted{
+		{"a", 1, 10, true},
+		{"b", 2, 0, false},
+		{"c", 3, 30, true},
+	}, wide)
+}
@@ -0,0 +1,73 @@
+package physicalplan
+
+import (
+	"context"
+	"errors"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// PreparedPlan is a physical plan that can be executed any number of times,
+// concurrently. The operators of the plan are planned once, when the plan is
+// prepared, while every execution creates the operators it runs, as they
+// hold the state of a single execution, e.g. the groups of an aggregation.
+type PreparedPlan struct {
+	pool    memory.Allocator
+	def     *definition
+	diagram string
+}
+
+// Prepare plans the operators of the logical plan and returns a PreparedPlan
+// executing them. The logical plan must not be modified afterwards. Plans
+// with overridden inputs can't be prepared, as their input operators can only
+// be used by a single execution.
+func Prepare(
+	ctx context.Context,
+	pool memory.Allocator,
+	tracer trace.Tracer,
+	s *dynparquet.Schema,
+	plan *logicalplan.LogicalPlan,
+	options ...Option,
+) (*PreparedPlan, error) {
+	_, span := tracer.Start(ctx, "PhysicalPlan/Prepare")
+	defer span.End()
+
+	def, err := define(tracer, s, plan, options...)
+	if err != nil {
+		return nil, err
+	}
+	if def.opts.overrideInput != nil {
+		return nil, errors.New("plans with overridden inputs cannot be prepared")
+	}
+	// The operators are created once to report the errors of the plan when
+	// it is prepared rather than when it is executed.
+	output, err := def.instantiate(pool)
+	if err != nil {
+		return nil, err
+	}
+	return &PreparedPlan{
+		pool:    pool,
+		def:     def,
+		diagram: output.DrawString(),
+	}, nil
+}
+
+// Execute creates the operators of the plan and executes them, calling the
+// callback with the results. It is safe to call Execute concurrently.
+func (p *PreparedPlan) Execute(ctx context.Context, callback func(ctx context.Context, r arrow.Record) error) error {
+	output, err := p.def.instantiate(p.pool)
+	if err != nil {
+		return err
+	}
+	return output.Execute(ctx, p.pool, callback)
+}
+
+// DrawString returns the diagram of the physical plan.
+func (p *PreparedPlan) DrawString() string {
+	return p.diagram
+}
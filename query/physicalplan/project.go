@@ -47,7 +47,7 @@ func (a aliasProjection) Project(mem memory.Allocator, ar arrow.Record) ([]arrow
 	case *logicalplan.Column:
 		for i := 0; i < ar.Schema().NumFields(); i++ {
 			field := ar.Schema().Field(i)
-			if a.expr.MatchColumn(field.Name) {
+			if a.expr.Expr.MatchColumn(field.Name) {
 				field.Name = a.name
 				ar.Column(i).Retain() // Retain the column since we're keeping it.
 				return []arrow.Field{field}, []arrow.Array{ar.Column(i)}, nil
@@ -211,21 +211,33 @@ type Projection struct {
 }
 
 func Project(mem memory.Allocator, tracer trace.Tracer, exprs []logicalplan.Expr) (*Projection, error) {
-	p := &Projection{
-		pool:           mem,
-		tracer:         tracer,
-		colProjections: make([]columnProjection, 0, len(exprs)),
+	colProjections, err := projections(exprs)
+	if err != nil {
+		return nil, err
 	}
+	return newProjection(mem, tracer, colProjections), nil
+}
 
+// projections returns the column projections of the given expressions. They
+// hold no state, so projection operators can share them.
+func projections(exprs []logicalplan.Expr) ([]columnProjection, error) {
+	colProjections := make([]columnProjection, 0, len(exprs))
 	for _, e := range exprs {
 		proj, err := projectionFromExpr(e)
 		if err != nil {
 			return nil, err
 		}
-		p.colProjections = append(p.colProjections, proj)
+		colProjections = append(colProjections, proj)
 	}
+	return colProjections, nil
+}
 
-	return p, nil
+func newProjection(mem memory.Allocator, tracer trace.Tracer, colProjections []columnProjection) *Projection {
+	return &Projection{
+		pool:           mem,
+		tracer:         tracer,
+		colProjections: colProjections,
+	}
 }
 
 func (p *Projection) Close() {
@@ -258,11 +270,11 @@ func (p *Projection) Callback(ctx context.Context, r arrow.Record) error {
 		rows = int64(resArrays[0].Len())
 	}
 
-	ar := array.NewRecord(
+	ar := NewRefRecord(array.NewRecord(
 		arrow.NewSchema(resFields, nil),
 		resArrays,
 		rows,
-	)
+	))
 	defer ar.Release()
 	for _, arr := range resArrays {
 		arr.Release()
@@ -354,6 +366,18 @@ func (a *averageProjection) Project(mem memory.Allocator, r arrow.Record) ([]arr
 			Type: &arrow.Float64Type{},
 		})
 		columns = append(columns, avgFloat64arrays(mem, sums, counts))
+	case arrow.FLOAT32:
+		fields = append(fields, arrow.Field{
+			Name: resultName,
+			Type: &arrow.Float32Type{},
+		})
+		columns = append(columns, avgFloat32arrays(mem, sums, counts))
+	case arrow.UINT64:
+		fields = append(fields, arrow.Field{
+			Name: resultName,
+			Type: &arrow.Uint64Type{},
+		})
+		columns = append(columns, avgUint64arrays(mem, sums, counts))
 	default:
 		return nil, nil, fmt.Errorf("Datatype %s is not supported for average projection", sums.DataType().ID())
 	}
@@ -374,6 +398,19 @@ func avgInt64arrays(pool memory.Allocator, sums, counts arrow.Array) arrow.Array
 	return res.NewArray()
 }
 
+func avgUint64arrays(pool memory.Allocator, sums, counts arrow.Array) arrow.Array {
+	sumsUints := sums.(*array.Uint64)
+	countsInts := counts.(*array.Int64)
+
+	res := array.NewUint64Builder(pool)
+	defer res.Release()
+	for i := 0; i < sumsUints.Len(); i++ {
+		res.Append(sumsUints.Value(i) / uint64(countsInts.Value(i)))
+	}
+
+	return res.NewArray()
+}
+
 func avgFloat64arrays(pool memory.Allocator, sums, counts arrow.Array) arrow.Array {
 	sumsFloats := sums.(*array.Float64)
 	countsInts := counts.(*array.Int64)
@@ -387,6 +424,19 @@ func avgFloat64arrays(pool memory.Allocator, sums, counts arrow.Array) arrow.Arr
 	return res.NewArray()
 }
 
+func avgFloat32arrays(pool memory.Allocator, sums, counts arrow.Array) arrow.Array {
+	sumsFloats := sums.(*array.Float32)
+	countsInts := counts.(*array.Int64)
+
+	res := array.NewFloat32Builder(pool)
+	defer res.Release()
+	for i := 0; i < sumsFloats.Len(); i++ {
+		res.Append(sumsFloats.Value(i) / float32(countsInts.Value(i)))
+	}
+
+	return res.NewArray()
+}
+
 type allProjection struct{}
 
 func (a allProjection) Name() string { return "all" }
@@ -0,0 +1,7 @@
+//go:build race
+
+package physicalplan
+
+// raceEnabled enables the leak checks of all plans in race builds, which the
+// tests are run with.
+const raceEnabled = true
@@ -0,0 +1,109 @@
+package physicalplan
+
+import (
+	"sync/atomic"
+
+	"github.com/apache/arrow/go/v14/arrow"
+)
+
+// RefRecord is an arrow.Record whose references are counted explicitly as it
+// moves through the operators of a plan. The operator that creates a record
+// owns its only reference and releases it once the next operator returns from
+// Callback. An operator that keeps a record, or any of its columns, after
+// Callback returns must Retain it and Release it once it is done with it.
+//
+// Unlike plain arrow records, which silently free their buffers when the
+// reference count drops to zero, a RefRecord panics when it is released more
+// than once or used after its last reference was released, so that operators
+// that don't respect the convention fail where the bug is rather than reading
+// freed memory further down the plan.
+type RefRecord struct {
+	arrow.Record
+	refs atomic.Int64
+}
+
+// NewRefRecord returns a RefRecord taking over the reference of the caller to
+// the record. Records that are already a RefRecord are returned as is.
+func NewRefRecord(r arrow.Record) *RefRecord {
+	if ref, ok := r.(*RefRecord); ok {
+		return ref
+	}
+	ref := &RefRecord{Record: r}
+	ref.refs.Store(1)
+	return ref
+}
+
+// Retain adds a reference to the record.
+func (r *RefRecord) Retain() {
+	if r.refs.Add(1) <= 1 {
+		panic("RefRecord retained after it was released")
+	}
+}
+
+// Release removes a reference to the record. The record is released once its
+// last reference is released.
+func (r *RefRecord) Release() {
+	refs := r.refs.Add(-1)
+	switch {
+	case refs < 0:
+		panic("RefRecord released more than once")
+	case refs == 0:
+		r.Record.Release()
+	}
+}
+
+// Refs returns the number of references to the record.
+func (r *RefRecord) Refs() int64 {
+	return r.refs.Load()
+}
+
+func (r *RefRecord) checkNotReleased() {
+	if r.refs.Load() <= 0 {
+		panic("RefRecord used after it was released")
+	}
+}
+
+func (r *RefRecord) Schema() *arrow.Schema {
+	r.checkNotReleased()
+	return r.Record.Schema()
+}
+
+func (r *RefRecord) NumRows() int64 {
+	r.checkNotReleased()
+	return r.Record.NumRows()
+}
+
+func (r *RefRecord) NumCols() int64 {
+	r.checkNotReleased()
+	return r.Record.NumCols()
+}
+
+func (r *RefRecord) Columns() []arrow.Array {
+	r.checkNotReleased()
+	return r.Record.Columns()
+}
+
+func (r *RefRecord) Column(i int) arrow.Array {
+	r.checkNotReleased()
+	return r.Record.Column(i)
+}
+
+func (r *RefRecord) ColumnName(i int) string {
+	r.checkNotReleased()
+	return r.Record.ColumnName(i)
+}
+
+func (r *RefRecord) SetColumn(i int, col arrow.Array) (arrow.Record, error) {
+	r.checkNotReleased()
+	return r.Record.SetColumn(i, col)
+}
+
+func (r *RefRecord) NewSlice(i, j int64) arrow.Record {
+	r.checkNotReleased()
+	return r.Record.NewSlice(i, j)
+}
+
+func (r *RefRecord) MarshalJSON() ([]byte, error) {
+	r.checkNotReleased()
+	return r.Record.MarshalJSON()
+}
@@ -0,0 +1,51 @@
+package physicalplan
+
+import (
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRefRecord(t *testing.T, mem memory.Allocator) *RefRecord {
+	t.Helper()
+	b := array.NewInt64Builder(mem)
+	defer b.Release()
+	b.AppendValues([]int64{1, 2, 3}, nil)
+	arr := b.NewArray()
+	defer arr.Release()
+	return NewRefRecord(array.NewRecord(
+		arrow.NewSchema([]arrow.Field{{Name: "a", Type: arrow.PrimitiveTypes.Int64}}, nil),
+		[]arrow.Array{arr},
+		int64(arr.Len()),
+	))
+}
+
+func TestRefRecord(t *testing.T) {
+	mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+
+	r := newTestRefRecord(t, mem)
+	require.Same(t, r, NewRefRecord(r))
+
+	r.Retain()
+	require.Equal(t, int64(2), r.Refs())
+	r.Release()
+	require.Equal(t, int64(3), r.NumRows())
+	require.Greater(t, mem.CurrentAlloc(), 0)
+
+	r.Release()
+	require.Equal(t, 0, mem.CurrentAlloc())
+}
+
+func TestRefRecordMisuse(t *testing.T) {
+	r := newTestRefRecord(t, memory.DefaultAllocator)
+	r.Release()
+
+	require.PanicsWithValue(t, "RefRecord released more than once", r.Release)
+	require.PanicsWithValue(t, "RefRecord used after it was released", func() { r.Column(0) })
+	require.PanicsWithValue(t, "RefRecord used after it was released", func() { r.NumRows() })
+	require.PanicsWithValue(t, "RefRecord retained after it was released", r.Retain)
+}
@@ -15,28 +15,41 @@ type RegExpFilter struct {
 }
 
 func (f *RegExpFilter) Eval(r arrow.Record) (*Bitmap, error) {
+	res := NewBitmap()
+	if err := f.EvalInto(r, res, nil); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (f *RegExpFilter) EvalInto(r arrow.Record, res *Bitmap, _ *BitmapPool) error {
 	leftData, exists, err := f.left.ArrowArray(r)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if !exists {
-		res := NewBitmap()
 		emptyMatch := f.right.Match(nil)
 		if (f.notMatch && !emptyMatch) || (!f.notMatch && emptyMatch) {
-			for i := uint32(0); i < uint32(r.NumRows()); i++ {
-				res.Add(i)
-			}
-			return res, nil
+			res.AddRange(0, uint64(r.NumRows()))
 		}
-		return res, nil
+		return nil
+	}
+
+	if leftData.Len() > 0 && leftData.NullN() == leftData.Len() {
+		return evalAllNull(res, leftData, func(res *Bitmap, arr arrow.Array) error {
+			if f.notMatch {
+				return ArrayScalarRegexNotMatch(res, arr, f.right)
+			}
+			return ArrayScalarRegexMatch(res, arr, f.right)
+		})
 	}
 
 	if f.notMatch {
-		return ArrayScalarRegexNotMatch(leftData, f.right)
+		return ArrayScalarRegexNotMatch(res, leftData, f.right)
 	}
 
-	return ArrayScalarRegexMatch(leftData, f.right)
+	return ArrayScalarRegexMatch(res, leftData, f.right)
 }
 
 func (f *RegExpFilter) String() string {
@@ -46,44 +59,138 @@ func (f *RegExpFilter) String() string {
 	return fmt.Sprintf("%s =~ \"%s\"", f.left.String(), f.right.String())
 }
 
-func ArrayScalarRegexMatch(left arrow.Array, right *regexp.Regexp) (*Bitmap, error) {
+// RegExpSetFilter matches the values of a column against a regex that only
+// matches a set of literals, e.g. `^(foo|bar)$`, by looking the values up in
+// the set instead of matching the regex. Values of dictionary columns are
+// only looked up once per dictionary entry.
+type RegExpSetFilter struct {
+	left     *ArrayRef
+	notMatch bool
+	right    *regexp.Regexp
+	values   map[string]struct{}
+}
+
+func newRegExpSetFilter(left *ArrayRef, right *regexp.Regexp, literals []string, notMatch bool) *RegExpSetFilter {
+	values := make(map[string]struct{}, len(literals))
+	for _, literal := range literals {
+		values[literal] = struct{}{}
+	}
+	return &RegExpSetFilter{
+		left:     left,
+		notMatch: notMatch,
+		right:    right,
+		values:   values,
+	}
+}
+
+func (f *RegExpSetFilter) Eval(r arrow.Record) (*Bitmap, error) {
+	res := NewBitmap()
+	if err := f.EvalInto(r, res, nil); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (f *RegExpSetFilter) EvalInto(r arrow.Record, res *Bitmap, _ *BitmapPool) error {
+	leftData, exists, err := f.left.ArrowArray(r)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		if f.contains("") != f.notMatch {
+			res.AddRange(0, uint64(r.NumRows()))
+		}
+		return nil
+	}
+
+	if leftData.NullN() == leftData.Len() {
+		// Null values match neither the regex nor its negation.
+		return nil
+	}
+
+	switch arr := leftData.(type) {
+	case *array.Binary:
+		for i := 0; i < arr.Len(); i++ {
+			if !arr.IsNull(i) && f.contains(string(arr.Value(i))) != f.notMatch {
+				res.Add(uint32(i))
+			}
+		}
+	case *array.String:
+		for i := 0; i < arr.Len(); i++ {
+			if !arr.IsNull(i) && f.contains(arr.Value(i)) != f.notMatch {
+				res.Add(uint32(i))
+			}
+		}
+	case *array.Dictionary:
+		dict, ok := arr.Dictionary().(*array.Binary)
+		if !ok {
+			return fmt.Errorf("RegExpSetFilter: unsupported dictionary type: %T", arr.Dictionary())
+		}
+		matches := make([]bool, dict.Len())
+		for i := range matches {
+			matches[i] = f.contains(string(dict.Value(i))) != f.notMatch
+		}
+		for i := 0; i < arr.Len(); i++ {
+			if !arr.IsNull(i) && matches[arr.GetValueIndex(i)] {
+				res.Add(uint32(i))
+			}
+		}
+	default:
+		return fmt.Errorf("RegExpSetFilter: unsupported type: %T", arr)
+	}
+	return nil
+}
+
+func (f *RegExpSetFilter) contains(value string) bool {
+	_, ok := f.values[value]
+	return ok
+}
+
+func (f *RegExpSetFilter) String() string {
+	if f.notMatch {
+		return fmt.Sprintf("%s !~ \"%s\"", f.left.String(), f.right.String())
+	}
+	return fmt.Sprintf("%s =~ \"%s\"", f.left.String(), f.right.String())
+}
+
+func ArrayScalarRegexMatch(res *Bitmap, left arrow.Array, right *regexp.Regexp) error {
 	switch arr := left.(type) {
 	case *array.Binary:
-		return BinaryArrayScalarRegexMatch(arr, right)
+		return BinaryArrayScalarRegexMatch(res, arr, right)
 	case *array.String:
-		return StringArrayScalarRegexMatch(arr, right)
+		return StringArrayScalarRegexMatch(res, arr, right)
 	case *array.Dictionary:
 		switch dict := arr.Dictionary().(type) {
 		case *array.Binary:
-			return BinaryDictionaryArrayScalarRegexMatch(arr, dict, right)
+			return BinaryDictionaryArrayScalarRegexMatch(res, arr, dict, right)
 		default:
-			return nil, fmt.Errorf("ArrayScalarRegexMatch: unsupported dictionary type: %T", dict)
+			return fmt.Errorf("ArrayScalarRegexMatch: unsupported dictionary type: %T", dict)
 		}
 	default:
-		return nil, fmt.Errorf("ArrayScalarRegexMatch: unsupported type: %T", arr)
+		return fmt.Errorf("ArrayScalarRegexMatch: unsupported type: %T", arr)
 	}
 }
 
-func ArrayScalarRegexNotMatch(left arrow.Array, right *regexp.Regexp) (*Bitmap, error) {
+func ArrayScalarRegexNotMatch(res *Bitmap, left arrow.Array, right *regexp.Regexp) error {
 	switch arr := left.(type) {
 	case *array.Binary:
-		return BinaryArrayScalarRegexNotMatch(arr, right)
+		return BinaryArrayScalarRegexNotMatch(res, arr, right)
 	case *array.String:
-		return StringArrayScalarRegexNotMatch(arr, right)
+		return StringArrayScalarRegexNotMatch(res, arr, right)
 	case *array.Dictionary:
 		switch dict := arr.Dictionary().(type) {
 		case *array.Binary:
-			return BinaryDictionaryArrayScalarRegexNotMatch(arr, dict, right)
+			return BinaryDictionaryArrayScalarRegexNotMatch(res, arr, dict, right)
 		default:
-			return nil, fmt.Errorf("ArrayScalarRegexNotMatch: unsupported dictionary type: %T", dict)
+			return fmt.Errorf("ArrayScalarRegexNotMatch: unsupported dictionary type: %T", dict)
 		}
 	default:
-		return nil, fmt.Errorf("ArrayScalarRegexNotMatch: unsupported type: %T", arr)
+		return fmt.Errorf("ArrayScalarRegexNotMatch: unsupported type: %T", arr)
 	}
 }
 
-func BinaryArrayScalarRegexMatch(left *array.Binary, right *regexp.Regexp) (*Bitmap, error) {
-	res := NewBitmap()
+func BinaryArrayScalarRegexMatch(res *Bitmap, left *array.Binary, right *regexp.Regexp) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			continue
@@ -93,11 +200,10 @@ func BinaryArrayScalarRegexMatch(left *array.Binary, right *regexp.Regexp) (*Bit
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func BinaryArrayScalarRegexNotMatch(left *array.Binary, right *regexp.Regexp) (*Bitmap, error) {
-	res := NewBitmap()
+func BinaryArrayScalarRegexNotMatch(res *Bitmap, left *array.Binary, right *regexp.Regexp) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			continue
@@ -107,11 +213,10 @@ func BinaryArrayScalarRegexNotMatch(left *array.Binary, right *regexp.Regexp) (*
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func StringArrayScalarRegexMatch(left *array.String, right *regexp.Regexp) (*Bitmap, error) {
-	res := NewBitmap()
+func StringArrayScalarRegexMatch(res *Bitmap, left *array.String, right *regexp.Regexp) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			continue
@@ -121,11 +226,10 @@ func StringArrayScalarRegexMatch(left *array.String, right *regexp.Regexp) (*Bit
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func StringArrayScalarRegexNotMatch(left *array.String, right *regexp.Regexp) (*Bitmap, error) {
-	res := NewBitmap()
+func StringArrayScalarRegexNotMatch(res *Bitmap, left *array.String, right *regexp.Regexp) error {
 	for i := 0; i < left.Len(); i++ {
 		if left.IsNull(i) {
 			continue
@@ -135,11 +239,10 @@ func StringArrayScalarRegexNotMatch(left *array.String, right *regexp.Regexp) (*
 		}
 	}
 
-	return res, nil
+	return nil
 }
 
-func BinaryDictionaryArrayScalarRegexMatch(dict *array.Dictionary, left *array.Binary, right *regexp.Regexp) (*Bitmap, error) {
-	res := NewBitmap()
+func BinaryDictionaryArrayScalarRegexMatch(res *Bitmap, dict *array.Dictionary, left *array.Binary, right *regexp.Regexp) error {
 	for i := 0; i < dict.Len(); i++ {
 		if dict.IsNull(i) {
 			continue
@@ -148,11 +251,10 @@ func BinaryDictionaryArrayScalarRegexMatch(dict *array.Dictionary, left *array.B
 			res.Add(uint32(i))
 		}
 	}
-	return res, nil
+	return nil
 }
 
-func BinaryDictionaryArrayScalarRegexNotMatch(dict *array.Dictionary, left *array.Binary, right *regexp.Regexp) (*Bitmap, error) {
-	res := NewBitmap()
+func BinaryDictionaryArrayScalarRegexNotMatch(res *Bitmap, dict *array.Dictionary, left *array.Binary, right *regexp.Regexp) error {
 	for i := 0; i < dict.Len(); i++ {
 		if dict.IsNull(i) {
 			continue
@@ -162,5 +264,5 @@ func BinaryDictionaryArrayScalarRegexNotMatch(dict *array.Dictionary, left *arra
 		}
 	}
 
-	return res, nil
+	return nil
 }
@@ -61,7 +61,11 @@ func (m *Synchronizer) SetNextPlan(nextPlan PhysicalPlan) {
 }
 
 func (m *Synchronizer) Draw() *Diagram {
-	return &Diagram{Details: "Synchronizer", Child: m.next.Draw()}
+	var child *Diagram
+	if m.next != nil {
+		child = m.next.Draw()
+	}
+	return &Diagram{Details: "Synchronizer", Child: child}
 }
 
 func (m *Synchronizer) Close() {
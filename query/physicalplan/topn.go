@@ -0,0 +1,415 @@
+package physicalplan
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/pqarrow/arrowutils"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// topNCompactionMinRows is the number of retained rows below which the
+// records of a TopNPerGroupOperator are not compacted.
+const topNCompactionMinRows = 1024
+
+var ErrUnsupportedTopNOrderType = errors.New("unsupported type for order of top-n, expected int64, uint64, float32 or float64")
+
+// TopNPerGroupOperator passes on the n rows of each group with the highest, or
+// the lowest, values of the order column, e.g. the five pods with the highest
+// CPU usage per namespace. Each group keeps its rows in a heap bounded by n,
+// and only the rows that are in a heap are retained from the input records.
+// Rows with a null order are dropped, and of rows with equal orders the ones
+// received first are kept. The rows are passed on once the input finished, in
+// records of the schemas of the records they were received in. Within each
+// record the rows of a group are adjacent and ordered by their rank. The
+// operator must only be fed by a single input.
+type TopNPerGroupOperator struct {
+	pool         memory.Allocator
+	tracer       trace.Tracer
+	next         PhysicalPlan
+	groupColumns []logicalplan.Expr
+	orderBy      logicalplan.Expr
+	n            int
+	desc         bool
+
+	// orderType is the type of the order column, set by the first record.
+	orderType arrow.DataType
+	groups    map[string]*topNGroup
+	// groupOrder are the groups in the order they were first seen in.
+	groupOrder []*topNGroup
+	records    []*topNRecord
+	retained   int
+	live       int
+	seq        uint64
+
+	key     []byte
+	keyCols []groupKeyColumn
+}
+
+// topNRecord is a record holding the rows of the heaps.
+type topNRecord struct {
+	r arrow.Record
+	// live is the number of rows of the record that are in a heap.
+	live int
+}
+
+type topNRow struct {
+	// rec is nil for rows of the record being processed.
+	rec        *topNRecord
+	row        int
+	intOrder   int64
+	uintOrder  uint64
+	floatOrder float64
+	// seq is the position the row was received in.
+	seq     uint64
+	evicted bool
+}
+
+// topNGroup is a heap of the rows of a group with the worst ranked row at
+// its root.
+type topNGroup struct {
+	op   *TopNPerGroupOperator
+	rows []*topNRow
+}
+
+func (g *topNGroup) Len() int { return len(g.rows) }
+
+func (g *topNGroup) Less(i, j int) bool { return g.op.better(g.rows[j], g.rows[i]) }
+
+func (g *topNGroup) Swap(i, j int) { g.rows[i], g.rows[j] = g.rows[j], g.rows[i] }
+
+func (g *topNGroup) Push(x any) { g.rows = append(g.rows, x.(*topNRow)) }
+
+func (g *topNGroup) Pop() any {
+	row := g.rows[len(g.rows)-1]
+	g.rows = g.rows[:len(g.rows)-1]
+	return row
+}
+
+// TopNPerGroup returns an operator that passes on the n rows of each group
+// with the highest values of the orderBy column if desc is true, or with the
+// lowest values otherwise. Groups are formed by the columns matching the given
+// expressions, all rows form a single group if none are given.
+func TopNPerGroup(
+	pool memory.Allocator,
+	tracer trace.Tracer,
+	groupColumns []logicalplan.Expr,
+	orderBy logicalplan.Expr,
+	n int,
+	desc bool,
+) *TopNPerGroupOperator {
+	return &TopNPerGroupOperator{
+		pool:         pool,
+		tracer:       tracer,
+		groupColumns: groupColumns,
+		orderBy:      orderBy,
+		n:            n,
+		desc:         desc,
+		groups:       map[string]*topNGroup{},
+	}
+}
+
+func (t *TopNPerGroupOperator) SetNext(next PhysicalPlan) {
+	t.next = next
+}
+
+func (t *TopNPerGroupOperator) Draw() *Diagram {
+	var child *Diagram
+	if t.next != nil {
+		child = t.next.Draw()
+	}
+
+	columns := make([]string, 0, len(t.groupColumns))
+	for _, c := range t.groupColumns {
+		columns = append(columns, c.Name())
+	}
+	direction := "asc"
+	if t.desc {
+		direction = "desc"
+	}
+
+	return &Diagram{Details: fmt.Sprintf(
+		"TopNPerGroup (%d by %s %s, group: %s)", t.n, t.orderBy.Name(), direction, strings.Join(columns, ","),
+	), Child: child}
+}
+
+// better returns whether row a ranks before row b.
+func (t *TopNPerGroupOperator) better(a, b *topNRow) bool {
+	var cmp int
+	switch t.orderType.ID() {
+	case arrow.FLOAT64, arrow.FLOAT32:
+		cmp = compareOrdered(a.floatOrder, b.floatOrder)
+	case arrow.UINT64:
+		cmp = compareOrdered(a.uintOrder, b.uintOrder)
+	default:
+		cmp = compareOrdered(a.intOrder, b.intOrder)
+	}
+	if t.desc {
+		cmp = -cmp
+	}
+	if cmp != 0 {
+		return cmp < 0
+	}
+	return a.seq < b.seq
+}
+
+func compareOrdered[T int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (t *TopNPerGroupOperator) Callback(_ context.Context, r arrow.Record) error {
+	if t.n <= 0 {
+		return nil
+	}
+
+	t.keyCols = t.keyCols[:0]
+	var order arrow.Array
+	fields := r.Schema().Fields()
+	for i, field := range fields {
+		if t.orderBy.MatchColumn(field.Name) {
+			order = r.Column(i)
+		}
+		if dynparquet.IsHashedColumn(field.Name) || !t.matchColumn(field.Name) {
+			continue
+		}
+		col := newGroupKeyColumn(field, r.Column(i))
+		if hashed := dynparquet.FindHashedColumn(field.Name, fields); hashed != -1 {
+			vals := make([]uint64, 0, r.NumRows())
+			for _, v := range r.Column(hashed).(*array.Int64).Int64Values() {
+				vals = append(vals, uint64(v))
+			}
+			col.hashes = vals
+		} else if _, ok := r.Column(i).(*array.List); ok {
+			col.hashes = dynparquet.HashArray(r.Column(i))
+		}
+		t.keyCols = append(t.keyCols, col)
+	}
+	if order == nil {
+		return fmt.Errorf("top-n order by column %s not found", t.orderBy.Name())
+	}
+	if t.orderType == nil {
+		t.orderType = order.DataType()
+	}
+	if !arrow.TypeEqual(t.orderType, order.DataType()) {
+		return fmt.Errorf("top-n order by column of type %s, expected %s", order.DataType(), t.orderType)
+	}
+
+	var pending []*topNRow
+	for i := 0; i < int(r.NumRows()); i++ {
+		if order.IsNull(i) {
+			continue
+		}
+		row := &topNRow{row: i, seq: t.seq}
+		t.seq++
+		switch arr := order.(type) {
+		case *array.Int64:
+			row.intOrder = arr.Value(i)
+		case *array.Float64:
+			row.floatOrder = arr.Value(i)
+		case *array.Float32:
+			row.floatOrder = float64(arr.Value(i))
+		case *array.Uint64:
+			row.uintOrder = arr.Value(i)
+		default:
+			return ErrUnsupportedTopNOrderType
+		}
+
+		t.key = t.key[:0]
+		for j := range t.keyCols {
+			var err error
+			if t.key, err = t.keyCols[j].appendKey(t.key, i); err != nil {
+				return err
+			}
+		}
+		group, ok := t.groups[string(t.key)]
+		if !ok {
+			group = &topNGroup{op: t}
+			t.groups[string(t.key)] = group
+			t.groupOrder = append(t.groupOrder, group)
+		}
+
+		if group.Len() < t.n {
+			heap.Push(group, row)
+			pending = append(pending, row)
+			continue
+		}
+		if !t.better(row, group.rows[0]) {
+			continue
+		}
+		t.evict(group.rows[0])
+		group.rows[0] = row
+		heap.Fix(group, 0)
+		pending = append(pending, row)
+	}
+
+	indices := make([]int64, 0, len(pending))
+	kept := pending[:0]
+	for _, row := range pending {
+		if !row.evicted {
+			indices = append(indices, int64(row.row))
+			kept = append(kept, row)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	rec, err := t.take(r, indices)
+	if err != nil {
+		return err
+	}
+	for j, row := range kept {
+		row.rec = rec
+		row.row = j
+	}
+
+	if t.retained > topNCompactionMinRows && t.retained > 2*t.live {
+		return t.compact()
+	}
+	return nil
+}
+
+// evict marks a row that was replaced in its heap.
+func (t *TopNPerGroupOperator) evict(row *topNRow) {
+	row.evicted = true
+	if row.rec == nil {
+		return
+	}
+	t.live--
+	row.rec.live--
+	if row.rec.live == 0 {
+		t.retained -= int(row.rec.r.NumRows())
+		row.rec.r.Release()
+		row.rec.r = nil
+	}
+}
+
+// take retains the rows at the given indices of the record.
+func (t *TopNPerGroupOperator) take(r arrow.Record, indices []int64) (*topNRecord, error) {
+	b := array.NewInt64Builder(t.pool)
+	defer b.Release()
+	b.AppendValues(indices, nil)
+	arr := b.NewInt64Array()
+	defer arr.Release()
+	taken, err := arrowutils.TakeRecord(t.pool, r, arr)
+	if err != nil {
+		return nil, err
+	}
+	rec := &topNRecord{r: taken, live: len(indices)}
+	t.records = append(t.records, rec)
+	t.retained += len(indices)
+	t.live += len(indices)
+	return rec, nil
+}
+
+// compact rewrites the retained records to only hold the rows that are in a
+// heap.
+func (t *TopNPerGroupOperator) compact() error {
+	records := t.records
+	rows := t.recordRows()
+	t.records = nil
+	t.retained = 0
+	t.live = 0
+	for _, rec := range records {
+		if rec.r == nil {
+			continue
+		}
+		live := rows[rec]
+		indices := make([]int64, 0, len(live))
+		for _, row := range live {
+			indices = append(indices, int64(row.row))
+		}
+		compacted, err := t.take(rec.r, indices)
+		rec.r.Release()
+		rec.r = nil
+		if err != nil {
+			return err
+		}
+		for j, row := range live {
+			row.rec = compacted
+			row.row = j
+		}
+	}
+	return nil
+}
+
+// recordRows returns the rows in the heaps of each record, ordered by group
+// and rank.
+func (t *TopNPerGroupOperator) recordRows() map[*topNRecord][]*topNRow {
+	rows := map[*topNRecord][]*topNRow{}
+	for _, group := range t.groupOrder {
+		ranked := append([]*topNRow(nil), group.rows...)
+		sort.Slice(ranked, func(i, j int) bool { return t.better(ranked[i], ranked[j]) })
+		for _, row := range ranked {
+			rows[row.rec] = append(rows[row.rec], row)
+		}
+	}
+	return rows
+}
+
+// matchColumn returns whether the column is a group column.
+func (t *TopNPerGroupOperator) matchColumn(name string) bool {
+	for _, c := range t.groupColumns {
+		if c.MatchColumn(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *TopNPerGroupOperator) Finish(ctx context.Context) error {
+	rows := t.recordRows()
+	for _, rec := range t.records {
+		if rec.r == nil {
+			continue
+		}
+		live := rows[rec]
+		indices := make([]int64, 0, len(live))
+		for _, row := range live {
+			indices = append(indices, int64(row.row))
+		}
+		if err := func() error {
+			b := array.NewInt64Builder(t.pool)
+			defer b.Release()
+			b.AppendValues(indices, nil)
+			arr := b.NewInt64Array()
+			defer arr.Release()
+			r, err := arrowutils.TakeRecord(t.pool, rec.r, arr)
+			if err != nil {
+				return err
+			}
+			defer r.Release()
+			return t.next.Callback(ctx, r)
+		}(); err != nil {
+			return err
+		}
+	}
+	return t.next.Finish(ctx)
+}
+
+func (t *TopNPerGroupOperator) Close() {
+	for _, rec := range t.records {
+		if rec.r != nil {
+			rec.r.Release()
+			rec.r = nil
+		}
+	}
+	t.records = nil
+	t.next.Close()
+}
@@ -0,0 +1,119 @@
+package physicalplan
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+func TestTopNPerGroup(t *testing.T) {
+	type row struct {
+		namespace string
+		pod       string
+		cpu       float64
+	}
+	newRecord := func(pool memory.Allocator, rows ...row) arrow.Record {
+		namespaces := array.NewBinaryBuilder(pool, arrow.BinaryTypes.Binary)
+		defer namespaces.Release()
+		pods := array.NewBinaryBuilder(pool, arrow.BinaryTypes.Binary)
+		defer pods.Release()
+		cpus := array.NewFloat64Builder(pool)
+		defer cpus.Release()
+		for _, r := range rows {
+			namespaces.AppendString(r.namespace)
+			pods.AppendString(r.pod)
+			cpus.Append(r.cpu)
+		}
+		cols := []arrow.Array{namespaces.NewArray(), pods.NewArray(), cpus.NewArray()}
+		defer func() {
+			for _, c := range cols {
+				c.Release()
+			}
+		}()
+		return array.NewRecord(arrow.NewSchema([]arrow.Field{
+			{Name: "labels.namespace", Type: arrow.BinaryTypes.Binary},
+			{Name: "labels.pod", Type: arrow.BinaryTypes.Binary},
+			{Name: "cpu", Type: arrow.PrimitiveTypes.Float64},
+		}, nil), cols, int64(len(rows)))
+	}
+	run := func(t *testing.T, n int, desc bool, input [][]row) []row {
+		pool := memory.NewCheckedAllocator(memory.DefaultAllocator)
+		defer pool.AssertSize(t, 0)
+
+		var output []row
+		op := TopNPerGroup(
+			pool,
+			trace.NewNoopTracerProvider().Tracer(""),
+			[]logicalplan.Expr{logicalplan.Col("labels.namespace")},
+			logicalplan.Col("cpu"),
+			n,
+			desc,
+		)
+		op.SetNext(&OutputPlan{
+			callback: func(_ context.Context, r arrow.Record) error {
+				for i := 0; i < int(r.NumRows()); i++ {
+					output = append(output, row{
+						namespace: r.Column(0).(*array.Binary).ValueString(i),
+						pod:       r.Column(1).(*array.Binary).ValueString(i),
+						cpu:       r.Column(2).(*array.Float64).Value(i),
+					})
+				}
+				return nil
+			},
+		})
+		defer op.Close()
+
+		ctx := context.Background()
+		for _, rows := range input {
+			r := newRecord(pool, rows...)
+			require.NoError(t, op.Callback(ctx, r))
+			r.Release()
+		}
+		require.NoError(t, op.Finish(ctx))
+		sort.SliceStable(output, func(i, j int) bool { return output[i].namespace < output[j].namespace })
+		return output
+	}
+
+	input := [][]row{
+		{{"a", "a1", 1}, {"a", "a2", 5}, {"b", "b1", 2}},
+		{{"a", "a3", 3}, {"b", "b2", 7}, {"a", "a4", 4}},
+		{{"b", "b3", 7}, {"a", "a5", 0}, {"c", "c1", 1}},
+	}
+	require.Equal(t, []row{
+		{"a", "a2", 5}, {"a", "a4", 4},
+		{"b", "b2", 7}, {"b", "b3", 7},
+		{"c", "c1", 1},
+	}, run(t, 2, true, input))
+	require.Equal(t, []row{
+		{"a", "a5", 0},
+		{"b", "b1", 2},
+		{"c", "c1", 1},
+	}, run(t, 1, false, input))
+
+	// Most retained rows of every record are replaced by the following
+	// records while one row of each record stays, which compacts the records.
+	var replaced [][]row
+	var expected []row
+	for i := 0; i < 20; i++ {
+		rows := []row{{fmt.Sprintf("u%02d", i), "u", 0}}
+		expected = append(expected, rows[0])
+		for k := 0; k < 100; k++ {
+			rows = append(rows, row{fmt.Sprintf("k%02d", k), fmt.Sprint(i), float64(i)})
+		}
+		replaced = append(replaced, rows)
+	}
+	for k := 0; k < 100; k++ {
+		expected = append(expected, row{fmt.Sprintf("k%02d", k), "19", 19})
+	}
+	sort.SliceStable(expected, func(i, j int) bool { return expected[i].namespace < expected[j].namespace })
+	require.Equal(t, expected, run(t, 1, true, replaced))
+}
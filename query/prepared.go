@@ -0,0 +1,148 @@
+package query
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/apache/arrow/go/v14/arrow"
+
+	"github.com/polarsignals/frostdb/query/logicalplan"
+	"github.com/polarsignals/frostdb/query/physicalplan"
+)
+
+// maxPreparedFilters is the number of sets of scan filters a prepared query
+// keeps a plan for. Executions under other filters plan the query again.
+const maxPreparedFilters = 64
+
+// PreparedQuery is a query that is planned once and can be executed any
+// number of times, also concurrently. The authorizer and row filter of the
+// engine depend on the context of the query, so they are invoked under the
+// context of every execution, and the query is planned once for every set of
+// scan filters they return, e.g. once for every tenant executing it.
+type PreparedQuery struct {
+	builder LocalQueryBuilder
+	// plan is the logical plan of the query as built, that the authorizer
+	// and the row filter are invoked with. It is not modified, the plans
+	// that are executed are built from the builder.
+	plan *logicalplan.LogicalPlan
+	// diagram is the diagram of the physical plan of the query under the
+	// context it was prepared with.
+	diagram string
+
+	mtx   sync.Mutex
+	plans map[string]*preparedPlan
+}
+
+// preparedPlan is a prepared query planned with a set of scan filters.
+type preparedPlan struct {
+	fingerprint string
+	plan        *physicalplan.PreparedPlan
+}
+
+func prepare(ctx context.Context, b LocalQueryBuilder) (*PreparedQuery, error) {
+	plan, err := b.planBuilder.Build()
+	if err != nil {
+		return nil, err
+	}
+	q := &PreparedQuery{
+		builder: b,
+		plan:    plan,
+		plans:   map[string]*preparedPlan{},
+	}
+	// The query is planned under the context it is prepared with to report
+	// the errors of the plan when it is prepared rather than when it is
+	// executed.
+	p, err := q.prepared(ctx)
+	if err != nil {
+		return nil, err
+	}
+	q.diagram = p.plan.DrawString()
+	return q, nil
+}
+
+// prepared returns the plan of the query under the scan filters of the given
+// context.
+func (q *PreparedQuery) prepared(ctx context.Context) (*preparedPlan, error) {
+	filters, err := q.builder.scanFilters(ctx, q.plan)
+	if err != nil {
+		return nil, err
+	}
+	key := filtersKey(filters)
+
+	q.mtx.Lock()
+	p, ok := q.plans[key]
+	q.mtx.Unlock()
+	if ok {
+		return p, nil
+	}
+
+	// Scan filters are added to the plan, and the plan is optimized, in
+	// place, so the plan is built again rather than modifying the plan of
+	// the query.
+	logicalPlan, err := q.builder.planBuilder.Build()
+	if err != nil {
+		return nil, err
+	}
+	if logicalPlan, err = optimize(logicalPlan, filters); err != nil {
+		return nil, err
+	}
+	phyPlan, err := physicalplan.Prepare(
+		ctx,
+		q.builder.pool,
+		q.builder.tracer,
+		logicalPlan.InputSchema(),
+		logicalPlan,
+		q.builder.execOpts...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	p = &preparedPlan{
+		fingerprint: fingerprint(logicalPlan),
+		plan:        phyPlan,
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if existing, ok := q.plans[key]; ok {
+		// Planned concurrently by another execution.
+		return existing, nil
+	}
+	if len(q.plans) < maxPreparedFilters {
+		q.plans[key] = p
+	}
+	return p, nil
+}
+
+// filtersKey returns the key of the plan of the query under the given scan
+// filters.
+func filtersKey(filters []logicalplan.Expr) string {
+	names := make([]string, 0, len(filters))
+	for _, f := range filters {
+		names = append(names, f.String())
+	}
+	return strings.Join(names, "\n")
+}
+
+// Execute executes the query, calling the callback with the results. It is
+// safe to call Execute concurrently.
+func (q *PreparedQuery) Execute(ctx context.Context, callback func(ctx context.Context, r arrow.Record) error) error {
+	ctx, span := q.builder.tracer.Start(ctx, "PreparedQuery/Execute")
+	defer span.End()
+	span.SetAttributes(tagAttributes(TagsFromContext(ctx))...)
+
+	p, err := q.prepared(ctx)
+	if err != nil {
+		return err
+	}
+	return q.builder.run(ctx, p.fingerprint, p.plan.DrawString(), func(ctx context.Context) error {
+		return p.plan.Execute(ctx, callback)
+	})
+}
+
+// DrawString returns the diagram of the physical plan of the query, planned
+// under the context it was prepared with.
+func (q *PreparedQuery) DrawString() string {
+	return q.diagram
+}
@@ -0,0 +1,396 @@
+// Package substrait translates Substrait plans into frostdb queries, so
+// external planners and front-ends producing Substrait can use frostdb as an
+// execution backend.
+//
+// Only the subset of Substrait that frostdb can execute is supported: read,
+// filter, project and aggregate relations with field references, literals,
+// comparisons, conjunctions and the sum, min, max, count and avg aggregations.
+// Anything else results in an error wrapping ErrUnsupported.
+package substrait
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	substraitpb "github.com/substrait-io/substrait-go/proto"
+	"github.com/substrait-io/substrait-go/proto/extensions"
+
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// ErrUnsupported is returned when a plan uses a Substrait feature frostdb
+// cannot execute.
+var ErrUnsupported = errors.New("unsupported substrait feature")
+
+// Scanner starts queries on tables. It is implemented by the query engines,
+// e.g. query.LocalEngine.
+type Scanner interface {
+	ScanTable(name string) query.Builder
+}
+
+// Translate translates the given Substrait plan into a query started on the
+// given scanner. The plan must have exactly one relation. The columns of the
+// query's results are named after the names of the plan's root relation, if
+// any.
+func Translate(scanner Scanner, plan *substraitpb.Plan) (query.Builder, error) {
+	if len(plan.GetRelations()) != 1 {
+		return nil, fmt.Errorf("%w: plan with %d relations", ErrUnsupported, len(plan.GetRelations()))
+	}
+
+	t := &translator{
+		scanner:   scanner,
+		functions: map[uint32]string{},
+	}
+	for _, ext := range plan.GetExtensions() {
+		if f := ext.GetExtensionFunction(); f != nil {
+			t.functions[f.GetFunctionAnchor()] = functionName(f)
+		}
+	}
+
+	var (
+		rel   *substraitpb.Rel
+		names []string
+	)
+	switch r := plan.GetRelations()[0].GetRelType().(type) {
+	case *substraitpb.PlanRel_Root:
+		rel = r.Root.GetInput()
+		names = r.Root.GetNames()
+	case *substraitpb.PlanRel_Rel:
+		rel = r.Rel
+	default:
+		return nil, errors.New("plan relation has no type")
+	}
+
+	b, fields, err := t.rel(rel)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) != 0 && len(names) != len(fields) {
+		return nil, fmt.Errorf("root relation has %d names for %d fields", len(names), len(fields))
+	}
+
+	projections := make([]logicalplan.Expr, 0, len(fields))
+	for i, field := range fields {
+		if len(names) != 0 && names[i] != field.Name() {
+			field = &logicalplan.AliasExpr{Expr: field, Alias: names[i]}
+		}
+		projections = append(projections, field)
+	}
+	return b.Project(projections...), nil
+}
+
+// functionName returns the name of the function without its signature, e.g.
+// "equal" for "equal:any_any".
+func functionName(f *extensions.SimpleExtensionDeclaration_ExtensionFunction) string {
+	name, _, _ := strings.Cut(f.GetName(), ":")
+	return name
+}
+
+type translator struct {
+	scanner   Scanner
+	functions map[uint32]string
+}
+
+// rel translates the given relation. It returns the query and the
+// expressions of the relation's output fields in terms of the query's
+// results.
+func (t *translator) rel(rel *substraitpb.Rel) (query.Builder, []logicalplan.Expr, error) {
+	switch r := rel.GetRelType().(type) {
+	case *substraitpb.Rel_Read:
+		return t.read(r.Read)
+	case *substraitpb.Rel_Filter:
+		return t.filter(r.Filter)
+	case *substraitpb.Rel_Project:
+		return t.project(r.Project)
+	case *substraitpb.Rel_Aggregate:
+		return t.aggregate(r.Aggregate)
+	case nil:
+		return nil, nil, errors.New("relation has no type")
+	default:
+		return nil, nil, fmt.Errorf("%w: relation %T", ErrUnsupported, r)
+	}
+}
+
+func (t *translator) read(rel *substraitpb.ReadRel) (query.Builder, []logicalplan.Expr, error) {
+	table := rel.GetNamedTable()
+	if table == nil || len(table.GetNames()) == 0 {
+		return nil, nil, fmt.Errorf("%w: read of anything but a named table", ErrUnsupported)
+	}
+	b := t.scanner.ScanTable(table.GetNames()[len(table.GetNames())-1])
+
+	names := rel.GetBaseSchema().GetNames()
+	fields := make([]logicalplan.Expr, 0, len(names))
+	for _, name := range names {
+		fields = append(fields, logicalplan.Col(name))
+	}
+
+	if rel.GetFilter() != nil {
+		expr, err := t.expr(rel.GetFilter(), fields)
+		if err != nil {
+			return nil, nil, err
+		}
+		b = b.Filter(expr)
+	}
+
+	if rel.GetProjection() != nil {
+		items := rel.GetProjection().GetSelect().GetStructItems()
+		projected := make([]logicalplan.Expr, 0, len(items))
+		for _, item := range items {
+			if item.GetChild() != nil {
+				return nil, nil, fmt.Errorf("%w: nested projection", ErrUnsupported)
+			}
+			field, err := fieldAt(fields, item.GetField())
+			if err != nil {
+				return nil, nil, err
+			}
+			projected = append(projected, field)
+		}
+		fields = projected
+	}
+
+	return emit(b, fields, rel.GetCommon())
+}
+
+func (t *translator) filter(rel *substraitpb.FilterRel) (query.Builder, []logicalplan.Expr, error) {
+	b, fields, err := t.rel(rel.GetInput())
+	if err != nil {
+		return nil, nil, err
+	}
+	expr, err := t.expr(rel.GetCondition(), fields)
+	if err != nil {
+		return nil, nil, err
+	}
+	return emit(b.Filter(expr), fields, rel.GetCommon())
+}
+
+// project translates a project relation. Projected expressions are not
+// projected right away but substituted into the expressions of the following
+// relations, so that e.g. filters on projected columns are still applied to
+// the columns of the table.
+func (t *translator) project(rel *substraitpb.ProjectRel) (query.Builder, []logicalplan.Expr, error) {
+	b, fields, err := t.rel(rel.GetInput())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	output := make([]logicalplan.Expr, 0, len(fields)+len(rel.GetExpressions()))
+	output = append(output, fields...)
+	for _, e := range rel.GetExpressions() {
+		expr, err := t.expr(e, fields)
+		if err != nil {
+			return nil, nil, err
+		}
+		output = append(output, expr)
+	}
+	return emit(b, output, rel.GetCommon())
+}
+
+func (t *translator) aggregate(rel *substraitpb.AggregateRel) (query.Builder, []logicalplan.Expr, error) {
+	b, fields, err := t.rel(rel.GetInput())
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rel.GetGroupings()) > 1 {
+		return nil, nil, fmt.Errorf("%w: multiple groupings", ErrUnsupported)
+	}
+
+	var groups []logicalplan.Expr
+	if len(rel.GetGroupings()) == 1 {
+		for _, e := range rel.GetGroupings()[0].GetGroupingExpressions() {
+			expr, err := t.expr(e, fields)
+			if err != nil {
+				return nil, nil, err
+			}
+			groups = append(groups, expr)
+		}
+	}
+
+	aggs := make([]logicalplan.Expr, 0, len(rel.GetMeasures()))
+	for _, measure := range rel.GetMeasures() {
+		if measure.GetFilter() != nil {
+			return nil, nil, fmt.Errorf("%w: filtered measure", ErrUnsupported)
+		}
+		agg, err := t.aggregateFunction(measure.GetMeasure(), fields)
+		if err != nil {
+			return nil, nil, err
+		}
+		aggs = append(aggs, agg)
+	}
+
+	output := make([]logicalplan.Expr, 0, len(groups)+len(aggs))
+	for _, e := range groups {
+		output = append(output, logicalplan.Col(e.Name()))
+	}
+	for _, e := range aggs {
+		output = append(output, logicalplan.Col(e.Name()))
+	}
+	return emit(b.Aggregate(aggs, groups), output, rel.GetCommon())
+}
+
+func (t *translator) aggregateFunction(f *substraitpb.AggregateFunction, fields []logicalplan.Expr) (logicalplan.Expr, error) {
+	if f.GetInvocation() == substraitpb.AggregateFunction_AGGREGATION_INVOCATION_DISTINCT {
+		return nil, fmt.Errorf("%w: distinct aggregation", ErrUnsupported)
+	}
+	if len(f.GetSorts()) != 0 {
+		return nil, fmt.Errorf("%w: sorted aggregation", ErrUnsupported)
+	}
+	name, ok := t.functions[f.GetFunctionReference()]
+	if !ok {
+		return nil, fmt.Errorf("unknown function reference %d", f.GetFunctionReference())
+	}
+	args, err := t.args(f.GetArguments(), fields)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%w: aggregation %s with %d arguments", ErrUnsupported, name, len(args))
+	}
+
+	switch name {
+	case "sum":
+		return logicalplan.Sum(args[0]), nil
+	case "min":
+		return logicalplan.Min(args[0]), nil
+	case "max":
+		return logicalplan.Max(args[0]), nil
+	case "count":
+		return logicalplan.Count(args[0]), nil
+	case "avg":
+		return logicalplan.Avg(args[0]), nil
+	default:
+		return nil, fmt.Errorf("%w: aggregation %s", ErrUnsupported, name)
+	}
+}
+
+var binaryOps = map[string]logicalplan.Op{
+	"equal":     logicalplan.OpEq,
+	"not_equal": logicalplan.OpNotEq,
+	"lt":        logicalplan.OpLt,
+	"lte":       logicalplan.OpLtEq,
+	"gt":        logicalplan.OpGt,
+	"gte":       logicalplan.OpGtEq,
+}
+
+// expr translates the given expression whose field references refer to the
+// given fields.
+func (t *translator) expr(expr *substraitpb.Expression, fields []logicalplan.Expr) (logicalplan.Expr, error) {
+	switch e := expr.GetRexType().(type) {
+	case *substraitpb.Expression_Selection:
+		return selection(e.Selection, fields)
+	case *substraitpb.Expression_Literal_:
+		return literal(e.Literal)
+	case *substraitpb.Expression_ScalarFunction_:
+		name, ok := t.functions[e.ScalarFunction.GetFunctionReference()]
+		if !ok {
+			return nil, fmt.Errorf("unknown function reference %d", e.ScalarFunction.GetFunctionReference())
+		}
+		args, err := t.args(e.ScalarFunction.GetArguments(), fields)
+		if err != nil {
+			return nil, err
+		}
+
+		switch name {
+		case "and":
+			return logicalplan.And(args...), nil
+		case "or":
+			return logicalplan.Or(args...), nil
+		}
+		op, ok := binaryOps[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: function %s", ErrUnsupported, name)
+		}
+		if len(args) != 2 {
+			return nil, fmt.Errorf("function %s with %d arguments", name, len(args))
+		}
+		return &logicalplan.BinaryExpr{Left: args[0], Op: op, Right: args[1]}, nil
+	case nil:
+		return nil, errors.New("expression has no type")
+	default:
+		return nil, fmt.Errorf("%w: expression %T", ErrUnsupported, e)
+	}
+}
+
+func (t *translator) args(args []*substraitpb.FunctionArgument, fields []logicalplan.Expr) ([]logicalplan.Expr, error) {
+	exprs := make([]logicalplan.Expr, 0, len(args))
+	for _, arg := range args {
+		value := arg.GetValue()
+		if value == nil {
+			return nil, fmt.Errorf("%w: non-value function argument", ErrUnsupported)
+		}
+		expr, err := t.expr(value, fields)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+func selection(ref *substraitpb.Expression_FieldReference, fields []logicalplan.Expr) (logicalplan.Expr, error) {
+	if ref.GetRootReference() == nil {
+		return nil, fmt.Errorf("%w: field reference not relative to the input", ErrUnsupported)
+	}
+	field := ref.GetDirectReference().GetStructField()
+	if field == nil {
+		return nil, fmt.Errorf("%w: field reference other than a struct field", ErrUnsupported)
+	}
+	if field.GetChild() != nil {
+		return nil, fmt.Errorf("%w: nested field reference", ErrUnsupported)
+	}
+	return fieldAt(fields, field.GetField())
+}
+
+func fieldAt(fields []logicalplan.Expr, i int32) (logicalplan.Expr, error) {
+	if i < 0 || int(i) >= len(fields) {
+		return nil, fmt.Errorf("field %d out of range, relation has %d fields", i, len(fields))
+	}
+	return fields[i], nil
+}
+
+func literal(lit *substraitpb.Expression_Literal) (logicalplan.Expr, error) {
+	var v any
+	switch l := lit.GetLiteralType().(type) {
+	case *substraitpb.Expression_Literal_Boolean:
+		v = l.Boolean
+	case *substraitpb.Expression_Literal_I8:
+		v = int64(l.I8)
+	case *substraitpb.Expression_Literal_I16:
+		v = int64(l.I16)
+	case *substraitpb.Expression_Literal_I32:
+		v = int64(l.I32)
+	case *substraitpb.Expression_Literal_I64:
+		v = l.I64
+	case *substraitpb.Expression_Literal_Fp32:
+		v = float64(l.Fp32)
+	case *substraitpb.Expression_Literal_Fp64:
+		v = l.Fp64
+	case *substraitpb.Expression_Literal_String_:
+		v = l.String_
+	case *substraitpb.Expression_Literal_Binary:
+		v = l.Binary
+	case *substraitpb.Expression_Literal_Null:
+		v = nil
+	default:
+		return nil, fmt.Errorf("%w: literal %T", ErrUnsupported, l)
+	}
+	return logicalplan.Literal(v), nil
+}
+
+// emit applies the output mapping of the relation to its fields.
+func emit(b query.Builder, fields []logicalplan.Expr, common *substraitpb.RelCommon) (query.Builder, []logicalplan.Expr, error) {
+	mapping := common.GetEmit()
+	if mapping == nil {
+		return b, fields, nil
+	}
+	emitted := make([]logicalplan.Expr, 0, len(mapping.GetOutputMapping()))
+	for _, i := range mapping.GetOutputMapping() {
+		field, err := fieldAt(fields, i)
+		if err != nil {
+			return nil, nil, err
+		}
+		emitted = append(emitted, field)
+	}
+	return b, emitted, nil
+}
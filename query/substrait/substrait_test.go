@@ -0,0 +1,172 @@
+package substrait
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+	substraitpb "github.com/substrait-io/substrait-go/proto"
+	"github.com/substrait-io/substrait-go/proto/extensions"
+
+	"github.com/polarsignals/frostdb"
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query"
+)
+
+func field(i int32) *substraitpb.Expression {
+	return &substraitpb.Expression{RexType: &substraitpb.Expression_Selection{
+		Selection: &substraitpb.Expression_FieldReference{
+			ReferenceType: &substraitpb.Expression_FieldReference_DirectReference{
+				DirectReference: &substraitpb.Expression_ReferenceSegment{
+					ReferenceType: &substraitpb.Expression_ReferenceSegment_StructField_{
+						StructField: &substraitpb.Expression_ReferenceSegment_StructField{Field: i},
+					},
+				},
+			},
+			RootType: &substraitpb.Expression_FieldReference_RootReference_{
+				RootReference: &substraitpb.Expression_FieldReference_RootReference{},
+			},
+		},
+	}}
+}
+
+func args(exprs ...*substraitpb.Expression) []*substraitpb.FunctionArgument {
+	args := make([]*substraitpb.FunctionArgument, 0, len(exprs))
+	for _, e := range exprs {
+		args = append(args, &substraitpb.FunctionArgument{
+			ArgType: &substraitpb.FunctionArgument_Value{Value: e},
+		})
+	}
+	return args
+}
+
+func function(anchor uint32, name string) *extensions.SimpleExtensionDeclaration {
+	return &extensions.SimpleExtensionDeclaration{
+		MappingType: &extensions.SimpleExtensionDeclaration_ExtensionFunction_{
+			ExtensionFunction: &extensions.SimpleExtensionDeclaration_ExtensionFunction{
+				FunctionAnchor: anchor,
+				Name:           name,
+			},
+		},
+	}
+}
+
+func root(rel *substraitpb.Rel, names ...string) *substraitpb.Plan {
+	return &substraitpb.Plan{
+		Extensions: []*extensions.SimpleExtensionDeclaration{
+			function(1, "equal:any_any"),
+			function(2, "gt:any_any"),
+			function(3, "and:bool"),
+			function(4, "sum:i64"),
+		},
+		Relations: []*substraitpb.PlanRel{{
+			RelType: &substraitpb.PlanRel_Root{Root: &substraitpb.RelRoot{Input: rel, Names: names}},
+		}},
+	}
+}
+
+func read() *substraitpb.Rel {
+	return &substraitpb.Rel{RelType: &substraitpb.Rel_Read{Read: &substraitpb.ReadRel{
+		BaseSchema: &substraitpb.NamedStruct{
+			Names: []string{"labels.namespace", "timestamp", "value"},
+		},
+		ReadType: &substraitpb.ReadRel_NamedTable_{
+			NamedTable: &substraitpb.ReadRel_NamedTable{Names: []string{"test"}},
+		},
+	}}}
+}
+
+func TestTranslate(t *testing.T) {
+	ctx := context.Background()
+	c, err := frostdb.New()
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", frostdb.NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+
+	// SELECT value AS v FROM test WHERE namespace = 'default' AND value > 2,
+	// with the value projected by a project relation that emits only it.
+	filter := &substraitpb.Rel{RelType: &substraitpb.Rel_Filter{Filter: &substraitpb.FilterRel{
+		Input: read(),
+		Condition: &substraitpb.Expression{RexType: &substraitpb.Expression_ScalarFunction_{
+			ScalarFunction: &substraitpb.Expression_ScalarFunction{
+				FunctionReference: 3,
+				Arguments: args(
+					&substraitpb.Expression{RexType: &substraitpb.Expression_ScalarFunction_{
+						ScalarFunction: &substraitpb.Expression_ScalarFunction{
+							FunctionReference: 1,
+							Arguments: args(field(0), &substraitpb.Expression{RexType: &substraitpb.Expression_Literal_{
+								Literal: &substraitpb.Expression_Literal{LiteralType: &substraitpb.Expression_Literal_String_{String_: "default"}},
+							}}),
+						},
+					}},
+					&substraitpb.Expression{RexType: &substraitpb.Expression_ScalarFunction_{
+						ScalarFunction: &substraitpb.Expression_ScalarFunction{
+							FunctionReference: 2,
+							Arguments: args(field(2), &substraitpb.Expression{RexType: &substraitpb.Expression_Literal_{
+								Literal: &substraitpb.Expression_Literal{LiteralType: &substraitpb.Expression_Literal_I64{I64: 2}},
+							}}),
+						},
+					}},
+				),
+			},
+		}},
+	}}}
+	project := &substraitpb.Rel{RelType: &substraitpb.Rel_Project{Project: &substraitpb.ProjectRel{
+		Common: &substraitpb.RelCommon{EmitKind: &substraitpb.RelCommon_Emit_{
+			Emit: &substraitpb.RelCommon_Emit{OutputMapping: []int32{3}},
+		}},
+		Input:       filter,
+		Expressions: []*substraitpb.Expression{field(2)},
+	}}}
+
+	b, err := Translate(engine, root(project, "v"))
+	require.NoError(t, err)
+
+	var values []int64
+	require.NoError(t, b.Execute(ctx, func(_ context.Context, r arrow.Record) error {
+		if r.NumRows() == 0 {
+			return nil
+		}
+		require.Equal(t, "v", r.Schema().Field(0).Name)
+		for i := 0; i < int(r.NumRows()); i++ {
+			values = append(values, r.Column(0).(interface{ Value(int) int64 }).Value(i))
+		}
+		return nil
+	}))
+	require.Equal(t, []int64{3, 3}, values)
+
+	// SELECT namespace, sum(value) FROM test GROUP BY namespace.
+	aggregate := &substraitpb.Rel{RelType: &substraitpb.Rel_Aggregate{Aggregate: &substraitpb.AggregateRel{
+		Input: read(),
+		Groupings: []*substraitpb.AggregateRel_Grouping{{
+			GroupingExpressions: []*substraitpb.Expression{field(0)},
+		}},
+		Measures: []*substraitpb.AggregateRel_Measure{{
+			Measure: &substraitpb.AggregateFunction{FunctionReference: 4, Arguments: args(field(2))},
+		}},
+	}}}
+	b, err = Translate(engine, root(aggregate, "namespace", "total"))
+	require.NoError(t, err)
+	explain, err := b.Explain(ctx)
+	require.NoError(t, err)
+	require.Contains(t, explain, "sum(value)")
+	require.Contains(t, explain, "labels.namespace")
+
+	// Sort relations are not supported.
+	sort := &substraitpb.Rel{RelType: &substraitpb.Rel_Sort{Sort: &substraitpb.SortRel{Input: read()}}}
+	_, err = Translate(engine, root(sort))
+	require.True(t, errors.Is(err, ErrUnsupported))
+}
@@ -0,0 +1,167 @@
+package query
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type tagsKey struct{}
+
+// WithTags returns a context that attaches the given key/value tags to the
+// queries executed with it, e.g. the dashboard or user issuing them. The tags
+// are added to the spans of the queries, logged by the query logger, reported
+// for active queries and used as labels of the query metrics. They are merged
+// with the tags already attached to the context, overriding tags of the same
+// key.
+func WithTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := make(map[string]string, len(tags))
+	for k, v := range TagsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, tagsKey{}, merged)
+}
+
+// TagsFromContext returns the tags attached to the context. The returned map
+// must not be modified.
+func TagsFromContext(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(tagsKey{}).(map[string]string)
+	return tags
+}
+
+// WithQueryLogger sets a logger every query executed by the engine is logged
+// to once it finished, with its tags, duration and error if any.
+func WithQueryLogger(logger log.Logger) Option {
+	return func(e *LocalEngine) {
+		e.queries.logger = logger
+	}
+}
+
+// WithTagMetrics registers metrics of the queries executed by the engine,
+// labeled by the values of the given tag keys. Tags with other keys are not
+// used as labels to bound the cardinality of the metrics. Queries without a
+// tag of a key have an empty label value.
+func WithTagMetrics(reg prometheus.Registerer, tagKeys ...string) Option {
+	return func(e *LocalEngine) {
+		e.queries.tagKeys = tagKeys
+		e.queries.executed = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "frostdb_query_executed_total",
+			Help: "Number of queries executed, by tags and whether they failed.",
+		}, append(append([]string(nil), tagKeys...), "result"))
+		e.queries.duration = promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "frostdb_query_duration_seconds",
+			Help:    "Duration of query executions, by tags.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+		}, tagKeys)
+	}
+}
+
+// ActiveQuery describes a query that is being executed.
+type ActiveQuery struct {
+	// ID identifies the query among the queries of its engine.
+	ID    uint64
+	Tags  map[string]string
+	Plan  string
+	Start time.Time
+}
+
+// ActiveQueries returns the queries the engine is executing, ordered by the
+// time they started.
+func (e *LocalEngine) ActiveQueries() []ActiveQuery {
+	return e.queries.activeQueries()
+}
+
+// queryTracker tracks the queries of an engine.
+type queryTracker struct {
+	logger   log.Logger
+	tagKeys  []string
+	executed *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	mtx    sync.Mutex
+	nextID uint64
+	active map[uint64]ActiveQuery
+}
+
+func newQueryTracker() *queryTracker {
+	return &queryTracker{
+		logger: log.NewNopLogger(),
+		active: map[uint64]ActiveQuery{},
+	}
+}
+
+// start registers a query as active. The returned function must be called
+// with the result of the query once it finished.
+func (t *queryTracker) start(tags map[string]string, plan string) func(error) {
+	t.mtx.Lock()
+	id := t.nextID
+	t.nextID++
+	q := ActiveQuery{
+		ID:    id,
+		Tags:  tags,
+		Plan:  plan,
+		Start: time.Now(),
+	}
+	t.active[id] = q
+	t.mtx.Unlock()
+
+	return func(err error) {
+		t.mtx.Lock()
+		delete(t.active, id)
+		t.mtx.Unlock()
+
+		duration := time.Since(q.Start)
+		if t.executed != nil {
+			labels := make([]string, 0, len(t.tagKeys)+1)
+			for _, k := range t.tagKeys {
+				labels = append(labels, tags[k])
+			}
+			t.duration.WithLabelValues(labels...).Observe(duration.Seconds())
+			result := "success"
+			if err != nil {
+				result = "error"
+			}
+			t.executed.WithLabelValues(append(labels, result)...).Inc()
+		}
+
+		keyvals := []interface{}{"msg", "query executed", "id", id, "duration", duration}
+		for _, attr := range tagAttributes(tags) {
+			keyvals = append(keyvals, string(attr.Key), attr.Value.AsString())
+		}
+		if err != nil {
+			keyvals = append(keyvals, "err", err)
+		}
+		level.Info(t.logger).Log(keyvals...)
+	}
+}
+
+func (t *queryTracker) activeQueries() []ActiveQuery {
+	t.mtx.Lock()
+	queries := make([]ActiveQuery, 0, len(t.active))
+	for _, q := range t.active {
+		queries = append(queries, q)
+	}
+	t.mtx.Unlock()
+	sort.Slice(queries, func(i, j int) bool { return queries[i].ID < queries[j].ID })
+	return queries
+}
+
+// tagAttributes returns the tags as span attributes ordered by key.
+func tagAttributes(tags map[string]string) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(tags))
+	for k, v := range tags {
+		attrs = append(attrs, attribute.String("tag."+k, v))
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	return attrs
+}
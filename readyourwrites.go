@@ -0,0 +1,50 @@
+package frostdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type minTxKey struct{}
+
+// WaitForTx returns a context making the queries executed with it observe the
+// write of the database with the given transaction, e.g. the transaction
+// returned by Table.InsertRecord. Writes become visible in the order of their
+// transactions, so a write is only visible once all writes with lower
+// transactions completed. Queries of the tables of the database wait until the
+// write is visible or fail once the context is done. Transactions are local to
+// a column store, so the context only applies to queries executed on the node
+// the write was made on.
+func (db *DB) WaitForTx(ctx context.Context, tx uint64) context.Context {
+	prev, _ := ctx.Value(minTxKey{}).(map[*DB]uint64)
+	if prev[db] >= tx {
+		return ctx
+	}
+	minTx := make(map[*DB]uint64, len(prev)+1)
+	for d, t := range prev {
+		minTx[d] = t
+	}
+	minTx[db] = tx
+	return context.WithValue(ctx, minTxKey{}, minTx)
+}
+
+// waitForMinTx waits until the transaction queries executed with the given
+// context must observe is visible, see WaitForTx.
+func (db *DB) waitForMinTx(ctx context.Context) error {
+	minTx, _ := ctx.Value(minTxKey{}).(map[*DB]uint64)
+	tx, ok := minTx[db]
+	if !ok || db.highWatermark.Load() >= tx {
+		return nil
+	}
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for db.highWatermark.Load() < tx {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for tx %d: %w", tx, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package frostdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb/clock"
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query"
+)
+
+func TestWaitForTx(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx := context.Background()
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	// A write with a lower transaction that did not complete yet keeps the
+	// insert from becoming visible.
+	_, _, commit := db.begin()
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	tx, err := table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	rows := func(ctx context.Context) (int64, error) {
+		var n int64
+		err := engine.ScanTable("test").Execute(ctx, func(_ context.Context, r arrow.Record) error {
+			n += r.NumRows()
+			return nil
+		})
+		return n, err
+	}
+	n, err := rows(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	timeoutCtx, cancel := context.WithTimeout(db.WaitForTx(ctx, tx), 20*time.Millisecond)
+	defer cancel()
+	_, err = rows(timeoutCtx)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	type result struct {
+		n   int64
+		err error
+	}
+	done := make(chan result)
+	go func() {
+		n, err := rows(db.WaitForTx(ctx, tx))
+		done <- result{n: n, err: err}
+	}()
+	select {
+	case <-done:
+		t.Fatal("query did not wait for the write")
+	case <-time.After(20 * time.Millisecond):
+	}
+	commit()
+	res := <-done
+	require.NoError(t, res.err)
+	require.Equal(t, r.NumRows(), res.n)
+}
+
+// TestWaitForTxWriteBuffer checks that a committed write that is still staged
+// in the write buffer is read by a query waiting for it.
+func TestWaitForTxWriteBuffer(t *testing.T) {
+	c, err := New(
+		WithClock(clock.NewManual(time.Unix(0, 0))),
+		WithWriteBuffer(time.Hour, 0),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx := context.Background()
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	_, _, commit := db.begin()
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	tx, err := table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	commit()
+
+	var n int64
+	require.NoError(t, query.NewEngine(memory.DefaultAllocator, db.TableProvider()).
+		ScanTable("test").
+		Execute(db.WaitForTx(ctx, tx), func(_ context.Context, r arrow.Record) error {
+			n += r.NumRows()
+			return nil
+		}))
+	require.Equal(t, r.NumRows(), n)
+	// The write was read from the write buffer, not from a flushed part.
+	require.Greater(t, table.ActiveBlock().Index().StagedSize(), int64(0))
+}
@@ -0,0 +1,296 @@
+package frostdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-kit/log/level"
+)
+
+// StorageWithReplica replicates the blocks persisted to the bucket to the
+// given replica bucket, e.g. a bucket in a different region for disaster
+// recovery. Blocks are copied asynchronously after they are persisted, and
+// deleted from the replica after they are deleted from the bucket, so the
+// replica lags behind the bucket. Blocks that failed to replicate are
+// replicated by ReconcileReplica. If the bucket is lost, the column store is
+// opened against the replica after preparing it with Failover.
+func StorageWithReplica(replica *DefaultObjstoreBucket) DefaultObjstoreBucketOption {
+	return func(b *DefaultObjstoreBucket) {
+		b.replica = &bucketReplica{bucket: replica}
+	}
+}
+
+// bucketReplica replicates the blocks of a bucket to a replica bucket in the
+// background.
+type bucketReplica struct {
+	bucket *DefaultObjstoreBucket
+
+	mtx     sync.Mutex
+	pending []replicationOp
+	// idle is closed once the pending operations are done. It is nil if no
+	// operations are pending.
+	idle chan struct{}
+}
+
+type replicationOp struct {
+	blockDir string
+	delete   bool
+}
+
+// blockReplicator is implemented by sinks that replicate the blocks persisted
+// to them.
+type blockReplicator interface {
+	replicateBlock(blockDir string)
+}
+
+// replicateBlock copies the persisted block in the given directory to the
+// replica in the background.
+func (b *DefaultObjstoreBucket) replicateBlock(blockDir string) {
+	if b.replica != nil {
+		b.replica.enqueue(b, replicationOp{blockDir: blockDir})
+	}
+}
+
+func (r *bucketReplica) enqueue(from *DefaultObjstoreBucket, op replicationOp) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.pending = append(r.pending, op)
+	if r.idle == nil {
+		r.idle = make(chan struct{})
+		go r.run(from)
+	}
+}
+
+// run applies the pending operations in order until none are left.
+func (r *bucketReplica) run(from *DefaultObjstoreBucket) {
+	for {
+		r.mtx.Lock()
+		if len(r.pending) == 0 {
+			close(r.idle)
+			r.idle = nil
+			r.mtx.Unlock()
+			return
+		}
+		op := r.pending[0]
+		r.pending = r.pending[1:]
+		r.mtx.Unlock()
+
+		if err := r.apply(context.Background(), from, op); err != nil {
+			level.Warn(from.logger).Log(
+				"msg", "failed to replicate block, it is replicated once the replica is reconciled",
+				"block", op.blockDir, "replica", r.bucket, "err", err,
+			)
+		}
+	}
+}
+
+func (r *bucketReplica) apply(ctx context.Context, from *DefaultObjstoreBucket, op replicationOp) error {
+	if op.delete {
+		return r.bucket.DeleteBlock(ctx, op.blockDir)
+	}
+	if err := from.CopyBlock(ctx, op.blockDir, r.bucket); err != nil {
+		return err
+	}
+	return r.bucket.indexBlock(ctx, op.blockDir)
+}
+
+// WaitForReplication waits until the blocks persisted to the bucket so far are
+// replicated, see StorageWithReplica.
+func (b *DefaultObjstoreBucket) WaitForReplication(ctx context.Context) error {
+	if b.replica == nil {
+		return nil
+	}
+	b.replica.mtx.Lock()
+	idle := b.replica.idle
+	b.replica.mtx.Unlock()
+	if idle == nil {
+		return nil
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ReconcileReplica makes the blocks of the tables of the database with the
+// given prefix in the replica match the blocks in the bucket, see
+// StorageWithReplica. Blocks that are missing in the replica or whose manifest
+// does not match are copied to the replica, and blocks that only exist in the
+// replica are deleted from it. Replication must be reconciled periodically or
+// after failures, e.g. when the replica was unavailable while blocks were
+// persisted.
+func (b *DefaultObjstoreBucket) ReconcileReplica(ctx context.Context, prefix string) error {
+	if b.replica == nil {
+		return errors.New("bucket has no replica")
+	}
+	replica := b.replica.bucket
+
+	tables, err := b.Prefixes(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	replicaTables, err := replica.Prefixes(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]struct{}, len(tables))
+	for _, table := range append(tables, replicaTables...) {
+		if _, ok := seen[table]; ok {
+			continue
+		}
+		seen[table] = struct{}{}
+		if err := b.reconcileTable(ctx, filepath.Join(prefix, table)); err != nil {
+			return fmt.Errorf("table %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func (b *DefaultObjstoreBucket) reconcileTable(ctx context.Context, prefix string) error {
+	replica := b.replica.bucket
+	blocks, err := b.Blocks(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	replicaBlocks, err := replica.Blocks(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	primary := make(map[string]struct{}, len(blocks))
+	for _, blockDir := range blocks {
+		primary[blockDir] = struct{}{}
+		replicated, err := b.isReplicated(ctx, blockDir)
+		if err != nil {
+			return fmt.Errorf("block %s: %w", blockDir, err)
+		}
+		if replicated {
+			continue
+		}
+		level.Info(b.logger).Log("msg", "replicating block", "block", blockDir, "replica", replica)
+		if err := b.replica.apply(ctx, b, replicationOp{blockDir: blockDir}); err != nil {
+			return fmt.Errorf("replicate block %s: %w", blockDir, err)
+		}
+	}
+	for _, blockDir := range replicaBlocks {
+		if _, ok := primary[blockDir]; ok {
+			continue
+		}
+		level.Info(b.logger).Log("msg", "deleting block from replica", "block", blockDir, "replica", replica)
+		if err := b.replica.apply(ctx, b, replicationOp{blockDir: blockDir, delete: true}); err != nil {
+			return fmt.Errorf("delete block %s from replica: %w", blockDir, err)
+		}
+	}
+	return nil
+}
+
+// isReplicated reports whether the block in the given directory was copied
+// to the replica completely, which is the case if the manifests of both
+// copies match. Blocks without a manifest are replicated if the replica holds
+// a data file of the same size.
+func (b *DefaultObjstoreBucket) isReplicated(ctx context.Context, blockDir string) (bool, error) {
+	replica := b.replica.bucket
+	manifest, err := b.readBlockManifest(ctx, blockDir)
+	if errors.Is(err, ErrBlockUnverifiable) {
+		dataFile := filepath.Join(blockDir, blockDataFileName)
+		attribs, err := b.Attributes(ctx, dataFile)
+		if err != nil {
+			return false, err
+		}
+		replicaAttribs, err := replica.Attributes(ctx, dataFile)
+		if err != nil {
+			if replica.IsObjNotFoundErr(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return attribs.Size == replicaAttribs.Size, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	replicaManifest, err := replica.readBlockManifest(ctx, blockDir)
+	if err != nil {
+		if errors.Is(err, ErrBlockUnverifiable) || errors.Is(err, ErrBlockCorrupt) {
+			return false, nil
+		}
+		return false, err
+	}
+	return manifest.Size == replicaManifest.Size && manifest.SHA256 == replicaManifest.SHA256, nil
+}
+
+// Failover prepares the replica of the bucket to replace the bucket, e.g.
+// after the region of the bucket became unavailable, and returns it, see
+// StorageWithReplica. Replication is asynchronous, so the replica may hold
+// blocks whose copy was interrupted. These blocks are deleted from the
+// replica and the manifests of its tables are rebuilt from the remaining
+// blocks. The tables of the database with the given prefix are then opened
+// against the replica by creating a column store with the returned bucket as
+// its storage. Blocks that were not replicated yet are lost.
+func (b *DefaultObjstoreBucket) Failover(ctx context.Context, prefix string) (*DefaultObjstoreBucket, error) {
+	if b.replica == nil {
+		return nil, errors.New("bucket has no replica")
+	}
+	replica := b.replica.bucket
+
+	tables, err := replica.Prefixes(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		if err := replica.discardIncompleteBlocks(ctx, filepath.Join(prefix, table)); err != nil {
+			return nil, fmt.Errorf("table %s: %w", table, err)
+		}
+	}
+	return replica, nil
+}
+
+// discardIncompleteBlocks deletes the blocks of the table with the given
+// prefix whose data does not match their manifest, and rebuilds the manifest
+// of the table.
+func (b *DefaultObjstoreBucket) discardIncompleteBlocks(ctx context.Context, prefix string) error {
+	blocks, err := b.Blocks(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, blockDir := range blocks {
+		err := b.VerifyBlock(ctx, blockDir)
+		if errors.Is(err, ErrBlockUnverifiable) {
+			// The block has no manifest, either because its copy was
+			// interrupted before the manifest was copied, or because it
+			// was persisted without one. Keep it if its data is readable.
+			_, err = b.tableManifestBlock(ctx, blockDir)
+			if err != nil && !b.IsObjNotFoundErr(err) {
+				err = fmt.Errorf("%w: %v", ErrBlockCorrupt, err)
+			}
+		}
+		switch {
+		case err == nil:
+			continue
+		case errors.Is(err, ErrBlockCorrupt) || b.IsObjNotFoundErr(err):
+			level.Warn(b.logger).Log("msg", "discarding incompletely replicated block", "block", blockDir, "err", err)
+			if err := b.deleteBlock(ctx, blockDir, "incomplete"); err != nil {
+				return fmt.Errorf("delete block %s: %w", blockDir, err)
+			}
+		default:
+			return fmt.Errorf("verify block %s: %w", blockDir, err)
+		}
+	}
+
+	if !b.tableManifest {
+		return nil
+	}
+	b.tableManifestMtx.Lock()
+	defer b.tableManifestMtx.Unlock()
+	manifest, err := b.buildTableManifest(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	return b.uploadTableManifest(ctx, prefix, manifest)
+}
@@ -0,0 +1,116 @@
+package frostdb
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/oklog/ulid"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query"
+)
+
+func TestStorageWithReplica(t *testing.T) {
+	ctx := context.Background()
+	primary := objstore.NewInMemBucket()
+	secondary := objstore.NewInMemBucket()
+	replica := NewDefaultObjstoreBucket(secondary, StorageWithTableManifest())
+	storage := NewDefaultObjstoreBucket(primary, StorageWithTableManifest(), StorageWithReplica(replica))
+
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithReadWriteStorage(storage),
+		WithManualBlockRotation(),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	writeTx, err := table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	blockDir := filepath.Join("test", "test", table.ActiveBlock().ulid.String())
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	db.Wait(writeTx + 2)
+	require.NoError(t, storage.WaitForReplication(ctx))
+
+	objects := func(t *testing.T, bucket objstore.Bucket) map[string]int {
+		t.Helper()
+		objects := map[string]int{}
+		require.NoError(t, bucket.Iter(ctx, "", func(name string) error {
+			attribs, err := bucket.Attributes(ctx, name)
+			if err != nil {
+				return err
+			}
+			objects[name] = int(attribs.Size)
+			return nil
+		}, objstore.WithRecursiveIter))
+		return objects
+	}
+	expected := objects(t, primary)
+	require.Contains(t, expected, filepath.Join(blockDir, blockDataFileName))
+	require.Equal(t, expected, objects(t, secondary))
+
+	t.Run("Reconcile", func(t *testing.T) {
+		// The replica misses the block and holds a block deleted from the
+		// bucket.
+		for _, name := range []string{blockDataFileName, blockManifestFileName} {
+			require.NoError(t, secondary.Delete(ctx, filepath.Join(blockDir, name)))
+		}
+		stale := filepath.Join("test", "test", ulid.MustNew(1, nil).String())
+		require.NoError(t, secondary.Upload(ctx, filepath.Join(stale, blockDataFileName), bytes.NewReader(nil)))
+
+		require.NoError(t, storage.ReconcileReplica(ctx, "test"))
+		require.Equal(t, expected, objects(t, secondary))
+	})
+
+	t.Run("Failover", func(t *testing.T) {
+		// The copy of a block was interrupted after part of its data was
+		// uploaded.
+		incomplete := filepath.Join("test", "test", ulid.MustNew(2, nil).String())
+		data, err := primary.Get(ctx, filepath.Join(blockDir, blockDataFileName))
+		require.NoError(t, err)
+		partial := make([]byte, expected[filepath.Join(blockDir, blockDataFileName)]/2)
+		_, err = data.Read(partial)
+		require.NoError(t, err)
+		require.NoError(t, data.Close())
+		require.NoError(t, secondary.Upload(ctx, filepath.Join(incomplete, blockDataFileName), bytes.NewReader(partial)))
+
+		failover, err := storage.Failover(ctx, "test")
+		require.NoError(t, err)
+		require.Equal(t, expected, objects(t, secondary))
+
+		c, err := New(
+			WithLogger(newTestLogger(t)),
+			WithReadWriteStorage(failover),
+			WithManualBlockRotation(),
+		)
+		require.NoError(t, err)
+		defer c.Close()
+		db, err := c.DB(ctx, "test")
+		require.NoError(t, err)
+		_, err = db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+		require.NoError(t, err)
+
+		var rows int64
+		require.NoError(t, query.NewEngine(memory.DefaultAllocator, db.TableProvider()).
+			ScanTable("test").
+			Execute(ctx, func(_ context.Context, r arrow.Record) error {
+				rows += r.NumRows()
+				return nil
+			}))
+		require.Equal(t, r.NumRows(), rows)
+	})
+}
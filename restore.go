@@ -0,0 +1,372 @@
+package frostdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/go-kit/log/level"
+
+	"github.com/polarsignals/frostdb/audit"
+	walpb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/wal/v1alpha1"
+	"github.com/polarsignals/frostdb/index"
+)
+
+// OpenAtTx restores the database to the state as of the given transaction
+// once it is opened, see DB.RestoreToTx. If the database is already open, it
+// is restored in place.
+func OpenAtTx(tx uint64) DBOption {
+	return func(db *DB) error {
+		db.restoreTx = &tx
+		return nil
+	}
+}
+
+// restoreIfRequested restores the database if the OpenAtTx option was given.
+// It returns the audit events of the restore, that the caller emits once the
+// column store is unlocked.
+func (db *DB) restoreIfRequested(ctx context.Context) ([]audit.Event, error) {
+	db.mtx.Lock()
+	tx := db.restoreTx
+	db.restoreTx = nil
+	db.mtx.Unlock()
+	if tx == nil {
+		return nil, nil
+	}
+	return db.restoreToTx(ctx, *tx)
+}
+
+// RestoreToTx rolls the data of the database back to the state as of the given
+// transaction, e.g. to recover from a bad ingestion batch. The inserts of
+// later transactions are discarded and a snapshot of the restored state is
+// taken, so the database is recovered to the restored state when it is
+// reopened. Transaction ids keep increasing, the restore is committed in a new
+// transaction.
+//
+// The state is rebuilt from the latest snapshot before the transaction and
+// the WAL, so restoring requires the WAL. Blocks that were persisted to
+// storage are not rolled back, so the transaction must not be older than the
+// active block of any table. Writes to the database wait for the restore to
+// finish, and the restore waits for pending writes. Tables that do not use the
+// WAL cannot be restored.
+//
+// The state as of the transaction is rebuilt into new blocks that replace the
+// active blocks of the tables once it is rebuilt, so the tables are left as
+// they are if the restore fails. The restore is audited and passed to the
+// watchers of the database as a Change of every table.
+func (db *DB) RestoreToTx(ctx context.Context, tx uint64) error {
+	events, err := db.restoreToTx(ctx, tx)
+	if err != nil {
+		return err
+	}
+	// The restore is audited once writes are unblocked, so that audit sinks
+	// can write to the database.
+	for _, event := range events {
+		db.audit(ctx, event)
+	}
+	return nil
+}
+
+// restoreToTx restores the database and returns the audit events of the
+// restore: the restore of the database and the truncation of every table.
+func (db *DB) restoreToTx(ctx context.Context, tx uint64) ([]audit.Event, error) {
+	if !db.columnStore.enableWAL {
+		return nil, errors.New("restoring a database requires the WAL")
+	}
+	db.writes.Lock()
+	defer db.writes.Unlock()
+	if watermark := db.HighWatermark(); tx > watermark {
+		return nil, fmt.Errorf("transaction %d is after the high watermark %d", tx, watermark)
+	}
+	if err := db.waitForWAL(ctx, db.HighWatermark()); err != nil {
+		return nil, err
+	}
+
+	db.mtx.RLock()
+	tables := make(map[string]*Table, len(db.tables))
+	for name, table := range db.tables {
+		tables[name] = table
+	}
+	db.mtx.RUnlock()
+
+	// The state of a table as of tx is the state of its active block, unless
+	// the active block was created after tx. This is only the case for tables
+	// that were created after tx, which are empty as of tx.
+	var (
+		replayFrom  uint64 = 1
+		minBlockTx  uint64
+		blockMinTxs = make(map[string]uint64, len(tables))
+	)
+	for name, table := range tables {
+		if table.config.Load().DisableWal {
+			return nil, fmt.Errorf("table %q does not use the WAL", name)
+		}
+		block := table.ActiveBlock()
+		if block.minTx > tx {
+			if block.prevTx != 0 {
+				return nil, fmt.Errorf("table %q rotated its active block after transaction %d", name, tx)
+			}
+			continue
+		}
+		blockMinTxs[name] = block.minTx
+		if minBlockTx == 0 || block.minTx < minBlockTx {
+			minBlockTx = block.minTx
+		}
+		if block.minTx > replayFrom {
+			replayFrom = block.minTx
+		}
+	}
+
+	// A snapshot can be used if all active blocks were created before it.
+	snapshotTx, snapshotFile, err := db.findSnapshot(ctx, replayFrom, tx)
+	if err != nil {
+		return nil, err
+	}
+	if snapshotFile != "" {
+		replayFrom = snapshotTx + 1
+	} else if minBlockTx != 0 {
+		replayFrom = minBlockTx
+	}
+
+	if replayFrom <= tx {
+		firstIndex, err := db.wal.FirstIndex()
+		if err != nil {
+			return nil, fmt.Errorf("read first WAL index: %w", err)
+		}
+		if firstIndex == 0 || firstIndex > replayFrom {
+			return nil, fmt.Errorf("transaction %d is not recoverable: WAL starts at transaction %d", tx, firstIndex)
+		}
+	}
+
+	blocks := make(map[string]*TableBlock, len(tables))
+	restored := false
+	defer func() {
+		if !restored {
+			for _, block := range blocks {
+				block.index.Release()
+			}
+		}
+	}()
+	for name, table := range tables {
+		block, err := table.newRestoreBlock()
+		if err != nil {
+			return nil, err
+		}
+		blocks[name] = block
+	}
+
+	if snapshotFile != "" {
+		if err := db.loadSnapshotBlocks(ctx, snapshotFile, blocks); err != nil {
+			return nil, fmt.Errorf("load snapshot %d: %w", snapshotTx, err)
+		}
+	}
+
+	if replayFrom <= tx {
+		if err := db.replayWrites(ctx, blocks, blockMinTxs, replayFrom, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, table := range tables {
+		block := blocks[name]
+		block.uncompressedInsertsSize.Store(block.Index().LevelSize(index.L0))
+		table.replaceActiveBlock(block)
+	}
+	restored = true
+
+	restoreTx, err := db.snapshotRestore(ctx, tables, tx)
+	if err != nil {
+		return nil, err
+	}
+	level.Info(db.logger).Log("msg", "restored db", "tx", tx)
+
+	events := []audit.Event{{
+		Type: audit.EventDBRestored,
+		Attributes: map[string]string{
+			"tx":         strconv.FormatUint(tx, 10),
+			"restore_tx": strconv.FormatUint(restoreTx, 10),
+		},
+	}}
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		events = append(events, audit.Event{
+			Type:       audit.EventTableTruncated,
+			Table:      name,
+			Attributes: map[string]string{"tx": strconv.FormatUint(tx, 10)},
+		})
+	}
+	return events, nil
+}
+
+// findSnapshot returns the tx and file name of the latest snapshot taken at a
+// transaction between minTx and maxTx. The returned file name is empty if no
+// such snapshot exists.
+func (db *DB) findSnapshot(ctx context.Context, minTx, maxTx uint64) (uint64, string, error) {
+	var (
+		snapshotTx uint64
+		fileName   string
+	)
+	err := db.snapshotsDo(ctx, db.snapshotsDir(), func(tx uint64, entry os.DirEntry) (bool, error) {
+		if tx > maxTx {
+			return true, nil
+		}
+		if tx >= minTx {
+			snapshotTx, fileName = tx, filepath.Join(db.snapshotsDir(), entry.Name())
+		}
+		return false, nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, "", err
+	}
+	return snapshotTx, fileName, nil
+}
+
+// loadSnapshotBlocks loads the active blocks of the tables of the given
+// snapshot into the given blocks. Tables of the snapshot without a block are
+// skipped.
+func (db *DB) loadSnapshotBlocks(ctx context.Context, fileName string, blocks map[string]*TableBlock) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	r, size, err := db.snapshotReaderAt(f, info.Size(), filepath.Base(fileName))
+	if err != nil {
+		return err
+	}
+	footer, err := readFooter(r, size)
+	if err != nil {
+		return err
+	}
+	for _, tableMeta := range footer.TableMetadata {
+		block, ok := blocks[tableMeta.Name]
+		if !ok {
+			continue
+		}
+		if err := block.ulid.UnmarshalBinary(tableMeta.ActiveBlock.Ulid); err != nil {
+			return err
+		}
+		// Store the last snapshot size so a snapshot is not triggered right
+		// after loading this snapshot.
+		block.lastSnapshotSize.Store(tableMeta.ActiveBlock.Size)
+		block.minTx = tableMeta.ActiveBlock.MinTx
+		block.prevTx = tableMeta.ActiveBlock.PrevTx
+		if err := loadSnapshotParts(ctx, r, tableMeta, block.table.schema, block.index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayWrites inserts the writes of the transactions from fromTx to toTx
+// that are logged in the WAL into the given blocks of the tables. Writes that
+// precede the active block of a table are skipped.
+func (db *DB) replayWrites(ctx context.Context, blocks map[string]*TableBlock, blockMinTxs map[string]uint64, fromTx, toTx uint64) error {
+	errStop := errors.New("stop replay")
+	err := db.wal.Replay(fromTx, func(tx uint64, record *walpb.Record) error {
+		if tx > toTx {
+			return errStop
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		write, ok := record.Entry.EntryType.(*walpb.Entry_Write_)
+		if !ok || !write.Write.Arrow {
+			return nil
+		}
+		block, ok := blocks[write.Write.TableName]
+		if minTx, exists := blockMinTxs[write.Write.TableName]; !ok || !exists || tx < minTx {
+			return nil
+		}
+
+		reader, err := ipc.NewReader(bytes.NewReader(write.Write.Data))
+		if err != nil {
+			return fmt.Errorf("create ipc reader: %w", err)
+		}
+		defer reader.Release()
+		r, err := reader.Read()
+		if err != nil {
+			return fmt.Errorf("read record: %w", err)
+		}
+		if err := block.InsertRecord(ctx, tx, r); err != nil {
+			return fmt.Errorf("insert record into block: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, errStop) {
+		return nil
+	}
+	return err
+}
+
+// snapshotRestore takes a snapshot of the restored state in a new
+// transaction, that is returned. Recovery starts from the latest snapshot, so
+// the discarded writes that are still in the WAL are not replayed. The
+// restore of every table is passed to the watchers of the database in the
+// transaction.
+func (db *DB) snapshotRestore(ctx context.Context, tables map[string]*Table, restoredTx uint64) (uint64, error) {
+	tx, _, commit := db.begin()
+	defer commit()
+
+	if err := db.wal.Log(tx, &walpb.Record{
+		Entry: &walpb.Entry{
+			EntryType: &walpb.Entry_Snapshot_{Snapshot: &walpb.Entry_Snapshot{Tx: tx}},
+		},
+	}); err != nil {
+		return 0, fmt.Errorf("append snapshot record to WAL: %w", err)
+	}
+	for name := range tables {
+		db.changes.publishRestore(tx, name, restoredTx)
+	}
+	if err := db.snapshotAtTX(ctx, tx, db.snapshotWriter(tx)); err != nil {
+		return 0, fmt.Errorf("snapshot restored db: %w", err)
+	}
+	return tx, db.reclaimDiskSpace(ctx, nil)
+}
+
+// newRestoreBlock returns an empty block of the same id and transactions as
+// the active block of the table, to rebuild the state of the block in.
+func (t *Table) newRestoreBlock() (*TableBlock, error) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return newTableBlock(t, t.active.prevTx, t.active.minTx, t.active.ulid)
+}
+
+// resetActiveIndex discards all data of the active block of the table. The
+// block is replaced with an empty block of the same id and transactions.
+func (t *Table) resetActiveIndex() error {
+	block, err := t.newRestoreBlock()
+	if err != nil {
+		return err
+	}
+	t.replaceActiveBlock(block)
+	return nil
+}
+
+// replaceActiveBlock replaces the active block of the table with the given
+// block. The index of the replaced block is released once its readers and
+// writers are done with it.
+func (t *Table) replaceActiveBlock(block *TableBlock) {
+	t.mtx.Lock()
+	old := t.active
+	t.active = block
+	t.mtx.Unlock()
+
+	old.pendingReadersWg.Wait()
+	old.pendingWritersWg.Wait()
+	old.index.Release()
+}
@@ -0,0 +1,291 @@
+package frostdb
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb/audit"
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+func TestRestoreToTx(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	config := NewTableConfig(dynparquet.SampleDefinition())
+
+	open := func(options ...DBOption) (*ColumnStore, *Table) {
+		c, err := New(
+			WithLogger(newTestLogger(t)),
+			WithWAL(),
+			WithStoragePath(dir),
+		)
+		require.NoError(t, err)
+		db, err := c.DB(ctx, "test", options...)
+		require.NoError(t, err)
+		table, err := db.Table("test", config)
+		require.NoError(t, err)
+		return c, table
+	}
+	insert := func(table *Table) uint64 {
+		r, err := dynparquet.NewTestSamples().ToRecord()
+		require.NoError(t, err)
+		defer r.Release()
+		tx, err := table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+		return tx
+	}
+	numRows := func(table *Table) int64 {
+		var rows int64
+		require.NoError(t, table.View(ctx, func(ctx context.Context, tx uint64) error {
+			return table.Iterator(ctx, tx, memory.DefaultAllocator, []logicalplan.Callback{
+				func(_ context.Context, r arrow.Record) error {
+					rows += r.NumRows()
+					return nil
+				},
+			})
+		}))
+		return rows
+	}
+
+	c, table := open()
+	good := insert(table)
+	insert(table)
+	require.Equal(t, int64(6), numRows(table))
+
+	require.NoError(t, table.db.RestoreToTx(ctx, good))
+	require.Equal(t, int64(3), numRows(table))
+
+	// New writes continue on the restored state and the restored state is
+	// recovered when the database is reopened.
+	afterRestore := insert(table)
+	require.Greater(t, afterRestore, good)
+	require.Equal(t, int64(6), numRows(table))
+	require.NoError(t, c.Close())
+
+	c, table = open()
+	require.Equal(t, int64(6), numRows(table))
+	insert(table)
+	require.NoError(t, c.Close())
+
+	c, table = open(OpenAtTx(afterRestore))
+	defer c.Close()
+	require.Equal(t, int64(6), numRows(table))
+
+	require.Error(t, table.db.RestoreToTx(ctx, table.db.HighWatermark()+1))
+}
+
+func TestRestoreToTxIndexOptions(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithWAL(),
+		WithStoragePath(t.TempDir()),
+		WithWriteBuffer(time.Hour, 0),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	insert := func() uint64 {
+		r, err := dynparquet.NewTestSamples().ToRecord()
+		require.NoError(t, err)
+		defer r.Release()
+		tx, err := table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+		return tx
+	}
+
+	good := insert()
+	insert()
+	restored := table.ActiveBlock()
+	require.NoError(t, db.RestoreToTx(ctx, good))
+
+	// The block is replaced by a block that is built like every other block,
+	// e.g. with the write buffer of the column store.
+	block := table.ActiveBlock()
+	require.NotSame(t, restored, block)
+	require.Equal(t, restored.ulid, block.ulid)
+	insert()
+	require.Greater(t, block.Index().StagedSize(), int64(0))
+}
+
+func TestRestoreToTxBlocksWrites(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithWAL(),
+		WithStoragePath(t.TempDir()),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	insert := func() (uint64, error) {
+		r, err := dynparquet.NewTestSamples().ToRecord()
+		require.NoError(t, err)
+		defer r.Release()
+		return table.InsertRecord(ctx, r)
+	}
+
+	good, err := insert()
+	require.NoError(t, err)
+	_, err = insert()
+	require.NoError(t, err)
+
+	// The restore waits for pending writes.
+	db.writes.RLock()
+	restored := make(chan error, 1)
+	go func() {
+		restored <- db.RestoreToTx(ctx, good)
+	}()
+	require.Never(t, func() bool { return len(restored) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+	db.writes.RUnlock()
+	require.NoError(t, <-restored)
+
+	// Writes wait for the restore.
+	db.writes.Lock()
+	written := make(chan error, 1)
+	go func() {
+		_, err := insert()
+		written <- err
+	}()
+	require.Never(t, func() bool { return len(written) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+	db.writes.Unlock()
+	require.NoError(t, <-written)
+}
+
+func TestRestoreToTxFailure(t *testing.T) {
+	ctx := context.Background()
+	sink := &testAuditSink{}
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithWAL(),
+		WithStoragePath(t.TempDir()),
+		WithAuditSink(sink),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	insert := func() uint64 {
+		r, err := dynparquet.NewTestSamples().ToRecord()
+		require.NoError(t, err)
+		defer r.Release()
+		tx, err := table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+		return tx
+	}
+	numRows := func() int64 {
+		var rows int64
+		require.NoError(t, table.View(ctx, func(ctx context.Context, tx uint64) error {
+			return table.Iterator(ctx, tx, memory.DefaultAllocator, []logicalplan.Callback{
+				func(_ context.Context, r arrow.Record) error {
+					rows += r.NumRows()
+					return nil
+				},
+			})
+		}))
+		return rows
+	}
+
+	good := insert()
+	insert()
+	require.NoError(t, db.waitForWAL(ctx, db.HighWatermark()))
+	block := table.ActiveBlock()
+
+	// The replay of the WAL fails, the table is left as it was.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	require.ErrorIs(t, db.RestoreToTx(canceled, good), context.Canceled)
+	require.Same(t, block, table.ActiveBlock())
+	require.Equal(t, int64(6), numRows())
+	require.NotContains(t, sink.types(), audit.EventDBRestored)
+	require.NotContains(t, sink.types(), audit.EventTableTruncated)
+}
+
+func TestRestoreToTxAuditAndChanges(t *testing.T) {
+	ctx := context.Background()
+	sink := &testAuditSink{}
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithWAL(),
+		WithStoragePath(t.TempDir()),
+		WithAuditSink(sink),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	insert := func() uint64 {
+		r, err := dynparquet.NewTestSamples().ToRecord()
+		require.NoError(t, err)
+		defer r.Release()
+		tx, err := table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+		return tx
+	}
+
+	good := insert()
+	insert()
+
+	var (
+		mtx     sync.Mutex
+		changes []Change
+	)
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watched := make(chan error, 1)
+	go func() {
+		watched <- db.WatchChanges(watchCtx, 1, func(_ context.Context, c Change) error {
+			mtx.Lock()
+			defer mtx.Unlock()
+			changes = append(changes, Change{Tx: c.Tx, Table: c.Table, RestoredTx: c.RestoredTx})
+			return nil
+		})
+	}()
+	numChanges := func() int {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return len(changes)
+	}
+	// The watcher is running once it delivered the inserts.
+	require.Eventually(t, func() bool { return numChanges() == 2 }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, db.RestoreToTx(ctx, good))
+	require.Eventually(t, func() bool { return numChanges() == 3 }, time.Second, 10*time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-watched, context.Canceled)
+
+	restore := changes[2]
+	require.Equal(t, "test", restore.Table)
+	require.Equal(t, good, restore.RestoredTx)
+	require.Greater(t, restore.Tx, good)
+
+	events := sink.events[len(sink.events)-2:]
+	require.Equal(t, audit.EventDBRestored, events[0].Type)
+	require.Equal(t, "test", events[0].Database)
+	require.Equal(t, map[string]string{
+		"tx":         strconv.FormatUint(good, 10),
+		"restore_tx": strconv.FormatUint(restore.Tx, 10),
+	}, events[0].Attributes)
+	require.Equal(t, audit.EventTableTruncated, events[1].Type)
+	require.Equal(t, "test", events[1].Database)
+	require.Equal(t, "test", events[1].Table)
+	require.Equal(t, map[string]string{"tx": strconv.FormatUint(good, 10)}, events[1].Attributes)
+}
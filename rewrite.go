@@ -0,0 +1,398 @@
+package frostdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/go-kit/log/level"
+	"github.com/parquet-go/parquet-go"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/polarsignals/frostdb/audit"
+	"github.com/polarsignals/frostdb/dynparquet"
+	schemapb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/schema/v1alpha1"
+	schemav2pb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/schema/v1alpha2"
+	tablepb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/table/v1alpha1"
+	walpb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/wal/v1alpha1"
+	"github.com/polarsignals/frostdb/index"
+	"github.com/polarsignals/frostdb/parts"
+)
+
+// ErrRewriteConflict is returned by Table.Rewrite when the active block of the
+// table was rotated while it was being rewritten.
+var ErrRewriteConflict = errors.New("active block rotated during rewrite")
+
+// ErrRewriteSorting is returned by Table.Rewrite when the sorting columns are
+// changed while the table has blocks that are not rewritten.
+var ErrRewriteSorting = errors.New("sorting columns can't be changed while the table has persisted blocks")
+
+// rewriteLog collects the records inserted into a block while it is being
+// rewritten, so they can be added to the rewritten block at cutover.
+type rewriteLog struct {
+	// tx is the transaction the block is rewritten as of. The records of
+	// earlier transactions are rewritten with the block.
+	tx uint64
+
+	mtx     sync.Mutex
+	txs     []uint64
+	records []arrow.Record
+	// into is the index of the rewritten block once it replaced the block,
+	// the records are added to it directly from then on.
+	into *index.LSM
+}
+
+func (l *rewriteLog) add(tx uint64, record arrow.Record) {
+	if tx <= l.tx {
+		return
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.into != nil {
+		l.into.Add(tx, record)
+		return
+	}
+	record.Retain()
+	l.txs = append(l.txs, tx)
+	l.records = append(l.records, record)
+}
+
+// cutover adds the collected records to the given index of the rewritten
+// block, as well as the records inserted into the block afterwards by the
+// writers that joined it before it was replaced.
+func (l *rewriteLog) cutover(into *index.LSM) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	for i, r := range l.records {
+		into.Add(l.txs[i], r)
+		r.Release()
+	}
+	l.txs, l.records = nil, nil
+	l.into = into
+}
+
+func (l *rewriteLog) release() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	for _, r := range l.records {
+		r.Release()
+	}
+	l.txs, l.records = nil, nil
+}
+
+// Rewrite rewrites the active block of the table into the physical layout of
+// the given config, e.g. new sorting columns, encodings or row group sizes, and
+// makes the config the config of the table. Only the active block is
+// rewritten: blocks that were already rotated or persisted are not read and
+// keep their layout, and later blocks are written with the new config. The
+// data of the active block is merge sorted into a new block while reads are
+// served from the current block until cutover. Writes received during the
+// rewrite are carried over to the new block at cutover. The config change is
+// audited like replacing the config of a table.
+//
+// The columns of the given config must match the columns of the current
+// config. Since the blocks that are not rewritten stay sorted by the current
+// sorting columns, ErrRewriteSorting is returned if the sorting columns are
+// changed while the table has such blocks, so sorting columns can only be
+// changed while all the data of the table is in the active block.
+// ErrRewriteConflict is returned if the active block was rotated during the
+// rewrite.
+func (t *Table) Rewrite(ctx context.Context, config *tablepb.TableConfig) error {
+	shared, err := t.db.columnStore.schemas.acquire(config)
+	if err != nil {
+		return err
+	}
+	if shared == nil {
+		return fmt.Errorf("rewrite table %s: config has no schema", t.name)
+	}
+	used := false
+	defer func() {
+		// Released unless it became the schema of the table.
+		if !used {
+			t.db.columnStore.schemas.release(shared)
+		}
+	}()
+	schema := shared.schema
+	if err := compatibleColumns(t.schema, schema); err != nil {
+		return fmt.Errorf("rewrite table %s: %w", t.name, err)
+	}
+	resort := !sameSorting(t.schema, schema)
+	if resort {
+		persisted, err := t.hasPersistedBlocks(ctx)
+		if err != nil {
+			return err
+		}
+		if persisted {
+			return fmt.Errorf("rewrite table %s: %w", t.name, ErrRewriteSorting)
+		}
+	}
+
+	// Log the writes after the snapshot tx. Writers begin their transaction
+	// under the table lock, so the writes into the block after the lock is
+	// released are logged.
+	t.mtx.Lock()
+	if t.closing {
+		t.mtx.Unlock()
+		return ErrTableClosing
+	}
+	if resort && len(t.pendingBlocks) > 0 {
+		t.mtx.Unlock()
+		return fmt.Errorf("rewrite table %s: %w", t.name, ErrRewriteSorting)
+	}
+	block := t.active
+	tx := t.db.tx.Load()
+	log := &rewriteLog{tx: tx}
+	block.rewrite.Store(log)
+	t.mtx.Unlock()
+	defer func() {
+		block.rewrite.Store(nil)
+		log.release()
+	}()
+	// Wait for the writes up to the snapshot tx to be in the index.
+	if err := t.db.waitForMinTx(t.db.WaitForTx(ctx, tx)); err != nil {
+		return err
+	}
+
+	sorted, err := t.rewriteBlock(ctx, block, schema, config, tx)
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	defer func() {
+		if replaced {
+			// Like a dropped block, the replaced block is released once its
+			// readers and writers are done with it.
+			block.pendingReadersWg.Wait()
+			block.pendingWritersWg.Wait()
+			block.index.Release()
+			t.db.audit(context.Background(), audit.Event{
+				Type:  audit.EventTableConfigChanged,
+				Table: t.name,
+			})
+		}
+	}()
+	t.db.writes.RLock()
+	defer t.db.writes.RUnlock()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.active != block {
+		return ErrRewriteConflict
+	}
+
+	newTx, _, commit := t.db.begin()
+	defer commit()
+
+	id := t.db.columnStore.nextBlockULID(block.ulid)
+	buf, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	// Log the new block with the new config. On replay, the writes before
+	// it are persisted in the old layout with the block they were written to.
+	if err := t.wal.Log(newTx, &walpb.Record{
+		Entry: &walpb.Entry{
+			EntryType: &walpb.Entry_NewTableBlock_{
+				NewTableBlock: &walpb.Entry_NewTableBlock{
+					TableName: t.name,
+					BlockId:   buf,
+					Config:    config,
+				},
+			},
+		},
+	}); err != nil {
+		return err
+	}
+
+	// Compactions of the new block use the schema of the table.
+	t.config.Store(config)
+	t.useSchema(shared)
+	used = true
+
+	tb, err := newTableBlock(t, block.prevTx, block.minTx, id)
+	if err != nil {
+		return err
+	}
+	if sorted != nil {
+		// Like compacted parts, the part is visible to all transactions.
+		maxLevel := tb.index.MaxLevel()
+		tb.index.InsertPart(maxLevel, parts.NewParquetPart(0, sorted, parts.WithCompactionLevel(int(maxLevel))))
+	}
+	// The writers still inserting into the block are waited for once the
+	// lock is released, their records are added to the new block meanwhile.
+	log.cutover(tb.index)
+	// The rewritten rows are visible to every transaction.
+	t.advanceSnapshotHorizon(tx)
+	tb.uncompressedInsertsSize.Store(block.uncompressedInsertsSize.Load())
+	tb.lastSnapshotSize.Store(block.lastSnapshotSize.Load())
+	t.active = tb
+	replaced = true
+
+	level.Info(t.logger).Log("msg", "rewrote table", "tx", newTx)
+	return nil
+}
+
+// rewriteBlock sorts the data of the block visible at the given tx by the
+// given schema and writes it into a single buffer laid out as configured.
+func (t *Table) rewriteBlock(
+	ctx context.Context,
+	block *TableBlock,
+	schema *dynparquet.Schema,
+	config *tablepb.TableConfig,
+	tx uint64,
+) (*dynparquet.SerializedBuffer, error) {
+	var rowGroups []dynparquet.DynamicRowGroup
+	defer func() {
+		for _, rg := range rowGroups {
+			releaseRowGroup(rg)
+		}
+	}()
+	if err := block.index.Scan(ctx, "", t.schema, nil, tx, func(_ context.Context, v any) error {
+		switch v := v.(type) {
+		case arrow.Record:
+			defer v.Release()
+			buf, err := parts.NewArrowPart(tx, v, 0, schema).AsSerializedBuffer(schema)
+			if err != nil {
+				return err
+			}
+			rowGroups = append(rowGroups, buf.MultiDynamicRowGroup())
+		case dynparquet.DynamicRowGroup:
+			rowGroups = append(rowGroups, v)
+		default:
+			return fmt.Errorf("unexpected type %T in index scan", v)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(rowGroups) == 0 {
+		return nil, nil
+	}
+
+	// Merging adapts the row groups to the union of their dynamic columns,
+	// the sorting writer sorts the rows by the new sorting columns.
+	merged, err := schema.MergeDynamicRowGroups(rowGroups)
+	if err != nil {
+		return nil, err
+	}
+	var sorted bytes.Buffer
+	if err := func() error {
+		pw, err := schema.GetWriter(&sorted, merged.DynamicColumns(), true)
+		if err != nil {
+			return err
+		}
+		defer schema.PutWriter(pw)
+		rows := merged.Rows()
+		defer rows.Close()
+		if _, err := parquet.CopyRows(pw, rows); err != nil {
+			return err
+		}
+		return pw.Close()
+	}(); err != nil {
+		return nil, fmt.Errorf("sort rows: %w", err)
+	}
+	sortedBuf, err := dynparquet.ReaderFromBytes(sorted.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the sorted rows again to apply the configured row group size.
+	var b bytes.Buffer
+	if err := func() error {
+		pw, err := schema.GetWriter(&b, sortedBuf.DynamicColumns(), false)
+		if err != nil {
+			return err
+		}
+		defer schema.PutWriter(pw)
+		buffSize := 256
+		if config.RowGroupSize > 0 {
+			buffSize = int(config.RowGroupSize)
+		}
+		p := &parquetRowWriter{
+			w:            pw,
+			schema:       schema,
+			rowsBuf:      make([]parquet.Row, buffSize),
+			rowGroupSize: int(config.RowGroupSize),
+		}
+		rg := sortedBuf.MultiDynamicRowGroup()
+		rows := rg.Rows()
+		defer rows.Close()
+		var rowReader parquet.RowReader = rows
+		if schema.UniquePrimaryIndex {
+			rowReader = parquet.DedupeRowReader(rows, rg.Schema().Comparator(rg.SortingColumns()...))
+		}
+		if _, err := p.writeRows(rowReader); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return p.close()
+	}(); err != nil {
+		return nil, fmt.Errorf("write rows: %w", err)
+	}
+	return dynparquet.ReaderFromBytes(b.Bytes())
+}
+
+// hasPersistedBlocks returns true if the table has blocks in memory other than
+// the active block, or blocks in any of the sources of the database.
+func (t *Table) hasPersistedBlocks(ctx context.Context) (bool, error) {
+	t.mtx.RLock()
+	pending := len(t.pendingBlocks)
+	t.mtx.RUnlock()
+	if pending > 0 {
+		return true, nil
+	}
+	for _, source := range t.db.sources {
+		prefixes, err := source.Prefixes(ctx, filepath.Join(t.db.prefix(), t.name))
+		if err != nil {
+			return false, err
+		}
+		if len(prefixes) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sameSorting returns true if the given schemas have the same sorting columns.
+func sameSorting(a, b *dynparquet.Schema) bool {
+	switch def := a.Definition().(type) {
+	case *schemapb.Schema:
+		other, ok := b.Definition().(*schemapb.Schema)
+		return ok && slices.EqualFunc(def.SortingColumns, other.SortingColumns, func(x, y *schemapb.SortingColumn) bool {
+			return proto.Equal(x, y)
+		})
+	case *schemav2pb.Schema:
+		other, ok := b.Definition().(*schemav2pb.Schema)
+		return ok && slices.EqualFunc(def.SortingColumns, other.SortingColumns, func(x, y *schemav2pb.SortingColumn) bool {
+			return proto.Equal(x, y)
+		})
+	default:
+		return false
+	}
+}
+
+// compatibleColumns returns an error if the columns of the given schemas
+// differ in anything but their encoding and compression.
+func compatibleColumns(a, b *dynparquet.Schema) error {
+	if len(a.Columns()) != len(b.Columns()) {
+		return fmt.Errorf("number of columns changed from %d to %d", len(a.Columns()), len(b.Columns()))
+	}
+	for _, col := range a.Columns() {
+		other, ok := b.ColumnByName(col.Name)
+		if !ok {
+			return fmt.Errorf("column %s removed", col.Name)
+		}
+		if col.Dynamic != other.Dynamic ||
+			col.StorageLayout.Type().Kind() != other.StorageLayout.Type().Kind() ||
+			col.StorageLayout.Optional() != other.StorageLayout.Optional() ||
+			col.StorageLayout.Repeated() != other.StorageLayout.Repeated() {
+			return fmt.Errorf("column %s changed type", col.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package frostdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/oklog/ulid"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// TestRotationReplay inserts concurrently with block rotations, closes the
+// column store right after the last rotation without persisting the active
+// block and checks that recovery reads every row exactly once. Writes must be
+// replayed into the block they were written to, and persisted blocks must not
+// be replayed.
+func TestRotationReplay(t *testing.T) {
+	dir := t.TempDir()
+	bucket := objstore.NewInMemBucket()
+	open := func() (*ColumnStore, *Table) {
+		c, err := New(
+			WithLogger(newTestLogger(t)),
+			WithWAL(),
+			WithStoragePath(dir),
+			WithReadWriteStorage(NewDefaultObjstoreBucket(bucket)),
+			WithManualBlockRotation(),
+		)
+		require.NoError(t, err)
+		db, err := c.DB(context.Background(), "test")
+		require.NoError(t, err)
+		table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+		require.NoError(t, err)
+		return c, table
+	}
+
+	c, table := open()
+	ctx := context.Background()
+	const (
+		writers = 4
+		inserts = 50
+	)
+	errg := errgroup.Group{}
+	insert := func(id int64) error {
+		r, err := dynparquet.Samples{{
+			ExampleType: "test",
+			Labels:      map[string]string{"label1": "value1"},
+			Timestamp:   id,
+			Value:       id,
+		}}.ToRecord()
+		if err != nil {
+			return err
+		}
+		defer r.Release()
+		_, err = table.InsertRecord(ctx, r)
+		return err
+	}
+	for w := 0; w < writers; w++ {
+		w := w
+		errg.Go(func() error {
+			for i := 0; i < inserts; i++ {
+				if err := insert(int64(w*inserts + i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	for i := 0; i < 5; i++ {
+		time.Sleep(time.Millisecond)
+		require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	}
+	require.NoError(t, errg.Wait())
+
+	// Write into the next block before the persistence of the rotated block
+	// is recorded, so that the writes have lower transactions than the
+	// persistence record, and crash without waiting for the rotated block to
+	// be written. Closing waits for it, so that its persistence is recorded.
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	const last = 10
+	for i := 0; i < last; i++ {
+		require.NoError(t, insert(int64(writers*inserts+i)))
+	}
+	require.NoError(t, c.Close())
+
+	c, table = open()
+	defer c.Close()
+	require.NoError(t, table.View(ctx, func(ctx context.Context, tx uint64) error {
+		ids, err := readIDs(ctx, table, tx)
+		if err != nil {
+			return err
+		}
+		require.Len(t, ids, writers*inserts+last)
+		return nil
+	}))
+}
+
+// TestDropPendingBlockOrder checks that pending blocks are dropped in the
+// order they were rotated, even if they finish persisting out of order.
+func TestDropPendingBlockOrder(t *testing.T) {
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	now := ulid.Now()
+	older, err := newTableBlock(table, 0, 1, ulid.MustNew(now, nil))
+	require.NoError(t, err)
+	newer, err := newTableBlock(table, 1, 2, ulid.MustNew(now+1, nil))
+	require.NoError(t, err)
+	table.mtx.Lock()
+	table.pendingBlocks[older] = struct{}{}
+	table.pendingBlocks[newer] = struct{}{}
+	table.mtx.Unlock()
+
+	dropped := make(chan struct{})
+	go func() {
+		defer close(dropped)
+		table.dropPendingBlock(newer)
+	}()
+	require.Never(t, func() bool {
+		select {
+		case <-dropped:
+			return true
+		default:
+			return false
+		}
+	}, 50*time.Millisecond, time.Millisecond, "newer block dropped before older block")
+
+	table.dropPendingBlock(older)
+	<-dropped
+	table.mtx.RLock()
+	defer table.mtx.RUnlock()
+	require.Empty(t, table.pendingBlocks)
+}
+
+// readIDs reads the IDs of the rows of the table visible at the given
+// transaction and returns an error if a row is read twice.
+func readIDs(ctx context.Context, table *Table, tx uint64) (map[int64]struct{}, error) {
+	ids := map[int64]struct{}{}
+	var mtx sync.Mutex
+	err := table.Iterator(ctx, tx, memory.DefaultAllocator, []logicalplan.Callback{
+		func(_ context.Context, r arrow.Record) error {
+			indices := r.Schema().FieldIndices("value")
+			if len(indices) != 1 {
+				return fmt.Errorf("value column not found in %s", r.Schema())
+			}
+			values := r.Column(indices[0]).(*array.Int64)
+			mtx.Lock()
+			defer mtx.Unlock()
+			for i := 0; i < values.Len(); i++ {
+				id := values.Value(i)
+				if _, ok := ids[id]; ok {
+					return fmt.Errorf("row %d read twice", id)
+				}
+				ids[id] = struct{}{}
+			}
+			return nil
+		},
+	})
+	return ids, err
+}
@@ -0,0 +1,82 @@
+package frostdb
+
+import (
+	"context"
+
+	"github.com/oklog/ulid"
+
+	"github.com/polarsignals/frostdb/parts"
+)
+
+// ScanHook lets embedders skip blocks and parts during scans based on
+// metadata maintained outside of frostdb, e.g. an index of the blocks holding
+// the data of each tenant. The context passed to the hook is the context of
+// the query, so hooks can e.g. read the tenant of the query from it. Hooks
+// must only skip data that the query would filter out anyway, since skipped
+// data is not evaluated against the filter of the query.
+type ScanHook interface {
+	// ScanBlock returns whether the block, in memory or persisted, is
+	// scanned.
+	ScanBlock(ctx context.Context, block ScanBlock) bool
+	// ScanPart returns whether the part of the index of the in-memory block
+	// is scanned. The part must not be retained after ScanPart returned.
+	ScanPart(ctx context.Context, block ScanBlock, part parts.Part) bool
+}
+
+// ScanBlock describes a block a scan is about to read.
+type ScanBlock struct {
+	Database string
+	Table    string
+	ID       ulid.ULID
+	// Persisted is whether the block is read from the storage rather than
+	// from memory.
+	Persisted bool
+}
+
+// WithScanHook sets a hook that decides which blocks and parts scans read.
+// Scans are not shared between queries if a hook is set, see
+// WithScanSharing, since the decisions of the hook depend on the query.
+func WithScanHook(hook ScanHook) Option {
+	return func(s *ColumnStore) error {
+		s.scanHook = hook
+		return nil
+	}
+}
+
+// scanHookRequest is the scan hook of a scan of a table.
+type scanHookRequest struct {
+	hook     ScanHook
+	database string
+	table    string
+}
+
+type scanHookKey struct{}
+
+// withScanHook returns a context making the scans of the table executed with
+// it consult the scan hook of the column store, if any.
+func (t *Table) withScanHook(ctx context.Context) context.Context {
+	hook := t.db.columnStore.scanHook
+	if hook == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, scanHookKey{}, &scanHookRequest{
+		hook:     hook,
+		database: t.db.name,
+		table:    t.name,
+	})
+}
+
+func scanHookFromContext(ctx context.Context) *scanHookRequest {
+	req, _ := ctx.Value(scanHookKey{}).(*scanHookRequest)
+	return req
+}
+
+// scanBlock returns whether the block with the given ID is scanned.
+func (r *scanHookRequest) scanBlock(ctx context.Context, id ulid.ULID, persisted bool) bool {
+	return r == nil || r.hook.ScanBlock(ctx, ScanBlock{
+		Database:  r.database,
+		Table:     r.table,
+		ID:        id,
+		Persisted: persisted,
+	})
+}
@@ -0,0 +1,102 @@
+package frostdb
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/oklog/ulid"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/parts"
+	"github.com/polarsignals/frostdb/query"
+)
+
+type tenantKey struct{}
+
+// testScanHook skips the blocks and the parts of the transactions that don't
+// hold data of the tenant of the query.
+type testScanHook struct {
+	mtx       sync.Mutex
+	blocks    map[ulid.ULID]string
+	txs       map[uint64]string
+	persisted []ulid.ULID
+}
+
+func (h *testScanHook) ScanBlock(ctx context.Context, block ScanBlock) bool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if block.Persisted {
+		h.persisted = append(h.persisted, block.ID)
+	}
+	tenant, ok := h.blocks[block.ID]
+	return !ok || tenant == ctx.Value(tenantKey{})
+}
+
+func (h *testScanHook) ScanPart(ctx context.Context, _ ScanBlock, part parts.Part) bool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.txs[part.TX()] == ctx.Value(tenantKey{})
+}
+
+func TestScanHook(t *testing.T) {
+	ctx := context.Background()
+	hook := &testScanHook{
+		blocks: map[ulid.ULID]string{},
+		txs:    map[uint64]string{},
+	}
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithReadWriteStorage(NewDefaultObjstoreBucket(objstore.NewInMemBucket())),
+		WithManualBlockRotation(),
+		WithScanHook(hook),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	insert := func(tenant string) uint64 {
+		tx, err := table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+		hook.mtx.Lock()
+		hook.txs[tx] = tenant
+		hook.mtx.Unlock()
+		return tx
+	}
+
+	// The persisted block only holds data of tenant a.
+	insert("a")
+	persisted := table.ActiveBlock()
+	hook.blocks[persisted.ulid] = "a"
+	require.NoError(t, table.RotateBlock(ctx, persisted, false))
+	db.Wait(insert("b") + 1)
+	insert("a")
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	rows := func(tenant string) int64 {
+		var rows int64
+		require.NoError(t, engine.ScanTable("test").Execute(
+			context.WithValue(ctx, tenantKey{}, tenant),
+			func(_ context.Context, r arrow.Record) error {
+				rows += r.NumRows()
+				return nil
+			},
+		))
+		return rows
+	}
+	require.Equal(t, 2*r.NumRows(), rows("a"))
+	require.Equal(t, r.NumRows(), rows("b"))
+	require.Equal(t, int64(0), rows("c"))
+	require.Contains(t, hook.persisted, persisted.ulid)
+}
@@ -0,0 +1,248 @@
+package frostdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/polarsignals/frostdb/clock"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// WithScanSharing makes concurrent scans of a table that read the same data
+// share a single scan: every row group is decoded once and the resulting
+// records are passed to the callbacks of all the scans. A scan waits for the
+// given window for other scans to attach to it before it starts reading.
+// Scans share a scan if they read the table at the same transaction with the
+// same projections and distinct columns. Their filters may differ, the shared
+// scan only prunes the row groups none of the filters may match and each
+// query applies its own filter to the records downstream. Pruning statistics
+// are only recorded for the query that started the shared scan.
+func WithScanSharing(window time.Duration) Option {
+	return func(s *ColumnStore) error {
+		s.scanSharing = true
+		s.scanSharingWindow = window
+		return nil
+	}
+}
+
+// errScanDetached is returned by the callbacks of a shared scan once none of
+// the scans attached to it consume its records anymore.
+var errScanDetached = errors.New("all scans detached from shared scan")
+
+// sharedScans tracks the shared scans of a table that still accept scans.
+type sharedScans struct {
+	window time.Duration
+	clock  clock.Clock
+	shared prometheus.Counter
+
+	mtx     sync.Mutex
+	pending map[string]*sharedScan
+}
+
+func newSharedScans(window time.Duration, clock clock.Clock, shared prometheus.Counter) *sharedScans {
+	return &sharedScans{
+		window:  window,
+		clock:   clock,
+		shared:  shared,
+		pending: map[string]*sharedScan{},
+	}
+}
+
+// sharedScan is a scan whose records are passed to the callbacks of all the
+// consumers attached to it.
+type sharedScan struct {
+	// consumers are only modified before the scan starts.
+	consumers []*scanConsumer
+	done      chan struct{}
+	err       error
+}
+
+// scanConsumer is a scan attached to a shared scan.
+type scanConsumer struct {
+	ctx       context.Context
+	filter    logicalplan.Expr
+	callbacks []logicalplan.Callback
+	// callbackMtxs serialize the calls of each callback, since the shared
+	// scan may call more callbacks concurrently than the consumer has.
+	callbackMtxs []sync.Mutex
+
+	mtx sync.Mutex
+	err error
+}
+
+// sharedScanKey returns the key of the scans that can share a scan with a scan
+// with the given options, or false if the scan can't be shared.
+func sharedScanKey(tx uint64, opts *logicalplan.IterOptions) (string, bool) {
+	if opts.UnifySchema {
+		return "", false
+	}
+	if len(opts.MinMaxColumns) > 0 || opts.PreAggregation != nil {
+		// Whether row groups are read from their statistics, or blocks from
+		// their pre-aggregates, depends on the filter, which shared scans
+		// combine.
+		return "", false
+	}
+	exprs := func(exprs []logicalplan.Expr) string {
+		s := make([]string, 0, len(exprs))
+		for _, e := range exprs {
+			s = append(s, e.String())
+		}
+		return strings.Join(s, ",")
+	}
+	return fmt.Sprintf(
+		"%d/%s/%s/%s/%t/%t",
+		tx,
+		exprs(opts.PhysicalProjection),
+		exprs(opts.Projection),
+		exprs(opts.DistinctColumns),
+		opts.Filter == nil, // Conversion differs for unfiltered distinct scans.
+		opts.InMemoryOnly,
+	), true
+}
+
+// iterate attaches the scan with the given key to a pending shared scan, or
+// starts a shared scan with the given iterate function if there is none, and
+// waits for the scan to finish. The iterate function is called with the
+// union of the filters of the attached scans.
+func (s *sharedScans) iterate(
+	ctx context.Context,
+	key string,
+	filter logicalplan.Expr,
+	callbacks []logicalplan.Callback,
+	iterate func(context.Context, logicalplan.Expr, []logicalplan.Callback) error,
+) error {
+	c := &scanConsumer{
+		ctx:          ctx,
+		filter:       filter,
+		callbacks:    callbacks,
+		callbackMtxs: make([]sync.Mutex, len(callbacks)),
+	}
+
+	s.mtx.Lock()
+	scan, ok := s.pending[key]
+	if !ok {
+		scan = &sharedScan{done: make(chan struct{})}
+		s.pending[key] = scan
+		// Values of the context, e.g. pruning stats, are those of the first
+		// scan, but the shared scan must not be canceled with it.
+		go s.run(context.WithoutCancel(ctx), key, scan, iterate)
+	} else {
+		s.shared.Inc()
+	}
+	scan.consumers = append(scan.consumers, c)
+	s.mtx.Unlock()
+
+	select {
+	case <-scan.done:
+	case <-ctx.Done():
+		c.detach(ctx.Err())
+		c.wait()
+		return ctx.Err()
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	if errors.Is(scan.err, errScanDetached) {
+		return nil
+	}
+	return scan.err
+}
+
+func (s *sharedScans) run(
+	ctx context.Context,
+	key string,
+	scan *sharedScan,
+	iterate func(context.Context, logicalplan.Expr, []logicalplan.Callback) error,
+) {
+	defer close(scan.done)
+
+	<-s.clock.After(s.window)
+	s.mtx.Lock()
+	delete(s.pending, key)
+	s.mtx.Unlock()
+
+	// The consumers can't change anymore.
+	filter := scan.consumers[0].filter
+	concurrency := 0
+	for _, c := range scan.consumers {
+		if filter != nil {
+			if c.filter == nil {
+				filter = nil
+			} else if c != scan.consumers[0] {
+				filter = logicalplan.Or(filter, c.filter)
+			}
+		}
+		concurrency = max(concurrency, len(c.callbacks))
+	}
+
+	callbacks := make([]logicalplan.Callback, 0, concurrency)
+	for i := 0; i < concurrency; i++ {
+		i := i
+		callbacks = append(callbacks, func(_ context.Context, r arrow.Record) error {
+			attached := false
+			for _, c := range scan.consumers {
+				if c.consume(i, r) {
+					attached = true
+				}
+			}
+			if !attached {
+				return errScanDetached
+			}
+			return nil
+		})
+	}
+	scan.err = iterate(ctx, filter, callbacks)
+}
+
+// consume passes the record to the i-th callback of the consumer. It returns
+// false if the consumer detached from the scan.
+func (c *scanConsumer) consume(i int, r arrow.Record) bool {
+	i %= len(c.callbacks)
+	c.callbackMtxs[i].Lock()
+	defer c.callbackMtxs[i].Unlock()
+	if c.detached() {
+		return false
+	}
+	if err := c.ctx.Err(); err != nil {
+		c.detach(err)
+		return false
+	}
+	if err := c.callbacks[i](c.ctx, r); err != nil {
+		c.detach(err)
+		return false
+	}
+	return true
+}
+
+func (c *scanConsumer) detached() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.err != nil
+}
+
+func (c *scanConsumer) detach(err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// wait waits for the callbacks of the consumer being called to return. No
+// callbacks are called after the consumer detached.
+func (c *scanConsumer) wait() {
+	for i := range c.callbackMtxs {
+		c.callbackMtxs[i].Lock()
+		c.callbackMtxs[i].Unlock() //nolint:staticcheck
+	}
+}
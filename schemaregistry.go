@@ -0,0 +1,104 @@
+package frostdb
+
+import (
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	tablepb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/table/v1alpha1"
+)
+
+// schemaRegistry shares the schemas of the tables of a column store, so that
+// tables with the same schema definition, e.g. one table per tenant, use a
+// single parsed schema and its pooled writers and buffers instead of parsing
+// and pooling their own. Schemas are immutable, a table whose definition
+// diverges uses the schema of its new definition and leaves the shared one
+// untouched. Schemas are dropped once no table uses them.
+type schemaRegistry struct {
+	mtx     sync.Mutex
+	schemas map[string]*sharedSchema
+}
+
+// sharedSchema is a schema of the registry, with the number of tables using
+// it.
+type sharedSchema struct {
+	key    string
+	schema *dynparquet.Schema
+	refs   int
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		schemas: map[string]*sharedSchema{},
+	}
+}
+
+// acquire returns the schema of the given table config, parsing its
+// definition only if no table uses it yet. It returns nil if the config has
+// no schema. The schema must be released once it isn't used anymore.
+func (r *schemaRegistry) acquire(config *tablepb.TableConfig) (*sharedSchema, error) {
+	var def proto.Message
+	switch schema := config.Schema.(type) {
+	case *tablepb.TableConfig_DeprecatedSchema:
+		def = schema.DeprecatedSchema
+	case *tablepb.TableConfig_SchemaV2:
+		def = schema.SchemaV2
+	default:
+		// No schema defined for table; read/only table
+		return nil, nil
+	}
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(def)
+	if err != nil {
+		return nil, fmt.Errorf("marshal schema definition: %w", err)
+	}
+	key := fmt.Sprintf("%T/%s", def, b)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if s, ok := r.schemas[key]; ok {
+		s.refs++
+		return s, nil
+	}
+	schema, err := dynparquet.SchemaFromDefinition(def)
+	if err != nil {
+		return nil, err
+	}
+	s := &sharedSchema{key: key, schema: schema, refs: 1}
+	r.schemas[key] = s
+	return s, nil
+}
+
+// release releases a schema returned by acquire. It is a no-op for nil
+// schemas.
+func (r *schemaRegistry) release(s *sharedSchema) {
+	if s == nil {
+		return
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	s.refs--
+	if s.refs == 0 {
+		delete(r.schemas, s.key)
+	}
+}
+
+// len returns the number of schemas in the registry.
+func (r *schemaRegistry) len() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return len(r.schemas)
+}
+
+// useSchema makes the given shared schema the schema of the table, releasing
+// the schema the table used before.
+func (t *Table) useSchema(s *sharedSchema) {
+	t.db.columnStore.schemas.release(t.sharedSchema)
+	t.sharedSchema = s
+	t.schema = nil
+	if s != nil {
+		t.schema = s.schema
+	}
+}
@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/polarsignals/frostdb"
+	logicalplanpb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/logicalplan/v1alpha1"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+	"github.com/polarsignals/frostdb/sqlparse"
+)
+
+// ErrorTrailer is the HTTP trailer holding the error of a query that failed
+// after its results started streaming.
+const ErrorTrailer = "X-Frostdb-Error"
+
+// HTTPRequest is the JSON body of a query posted to an HTTPHandler. A query is
+// either SQL scanning a table, or a logical plan in the protobuf JSON format.
+type HTTPRequest struct {
+	// Database is the name of the database to query.
+	Database string `json:"database"`
+	// Table is the name of the table scanned by the SQL query.
+	Table string `json:"table,omitempty"`
+	// SQL is the SQL query.
+	SQL string `json:"sql,omitempty"`
+	// Plan is the logical plan of the query.
+	Plan json.RawMessage `json:"plan,omitempty"`
+}
+
+// HTTPHandler serves queries of the databases of a column store over HTTP.
+// Queries are posted as an HTTPRequest and their results are streamed as JSON
+// lines, one object per row, or as CSV if the format query parameter is
+// "csv". Since the schemas of the records of a query's results may differ, a
+// CSV header is written whenever the schema changes.
+type HTTPHandler struct {
+	s *Server
+}
+
+// NewHTTPHandler returns a handler serving queries of the databases of the
+// given column store. It accepts the same options as New.
+func NewHTTPHandler(store *frostdb.ColumnStore, options ...Option) *HTTPHandler {
+	return &HTTPHandler{s: New(store, options...)}
+}
+
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var enc recordEncoder
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "jsonl":
+		enc = &jsonEncoder{}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	case "csv":
+		enc = &csvEncoder{}
+		w.Header().Set("Content-Type", "text/csv")
+	default:
+		http.Error(w, fmt.Sprintf("unknown format %q", format), http.StatusBadRequest)
+		return
+	}
+
+	var req HTTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+	db, err := h.s.store.GetDB(req.Database)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	b, explain, err := h.builder(db, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if explain {
+		s, err := b.Explain(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, s)
+		return
+	}
+
+	w.Header().Set("Trailer", ErrorTrailer)
+	flusher, _ := w.(http.Flusher)
+	var mtx sync.Mutex
+	err = b.Execute(r.Context(), func(_ context.Context, r arrow.Record) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		if err := enc.encode(w, r); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		w.Header().Set(ErrorTrailer, err.Error())
+	}
+}
+
+// builder returns the query of the given request and whether it is to be
+// explained rather than executed.
+func (h *HTTPHandler) builder(db *frostdb.DB, req HTTPRequest) (query.Builder, bool, error) {
+	engine := query.NewEngine(h.s.pool, db.TableProvider(), h.s.engineOptions...)
+	switch {
+	case req.SQL != "" && req.Plan != nil:
+		return nil, false, errors.New("only one of sql and plan may be set")
+	case req.SQL != "":
+		table, err := db.GetTable(req.Table)
+		if err != nil {
+			return nil, false, err
+		}
+		var dynColNames []string
+		for _, col := range table.Schema().Columns() {
+			if col.Dynamic {
+				dynColNames = append(dynColNames, col.Name)
+			}
+		}
+		res, err := sqlparse.NewParser().ExperimentalParse(engine.ScanTable(req.Table), dynColNames, req.SQL)
+		if err != nil {
+			return nil, false, err
+		}
+		return res.Plan, res.Explain, nil
+	case req.Plan != nil:
+		p := &logicalplanpb.Plan{}
+		if err := protojson.Unmarshal(req.Plan, p); err != nil {
+			return nil, false, fmt.Errorf("decode plan: %w", err)
+		}
+		plan, err := logicalplan.FromProto(p, db.TableProvider())
+		if err != nil {
+			return nil, false, err
+		}
+		if plan == nil {
+			return nil, false, errors.New("empty plan")
+		}
+		b, err := builder(engine, plan)
+		return b, false, err
+	default:
+		return nil, false, errors.New("one of sql and plan must be set")
+	}
+}
+
+// recordEncoder encodes the rows of records.
+type recordEncoder interface {
+	encode(w io.Writer, r arrow.Record) error
+}
+
+// jsonEncoder encodes every row as a JSON object.
+type jsonEncoder struct{}
+
+func (e *jsonEncoder) encode(w io.Writer, r arrow.Record) error {
+	enc := json.NewEncoder(w)
+	row := make(map[string]any, r.NumCols())
+	for i := 0; i < int(r.NumRows()); i++ {
+		for j, field := range r.Schema().Fields() {
+			v := value(r.Column(j), i)
+			// JSON has no representation of NaN and infinities.
+			switch f := v.(type) {
+			case float64:
+				if math.IsNaN(f) || math.IsInf(f, 0) {
+					v = strconv.FormatFloat(f, 'g', -1, 64)
+				}
+			case float32:
+				if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+					v = strconv.FormatFloat(float64(f), 'g', -1, 32)
+				}
+			}
+			row[field.Name] = v
+		}
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvEncoder encodes every row as a CSV record. A header is written whenever
+// the schema changes.
+type csvEncoder struct {
+	schema *arrow.Schema
+}
+
+func (e *csvEncoder) encode(w io.Writer, r arrow.Record) error {
+	cw := csv.NewWriter(w)
+	if e.schema == nil || !e.schema.Equal(r.Schema()) {
+		e.schema = r.Schema()
+		header := make([]string, 0, r.NumCols())
+		for _, field := range r.Schema().Fields() {
+			header = append(header, field.Name)
+		}
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+
+	row := make([]string, r.NumCols())
+	for i := 0; i < int(r.NumRows()); i++ {
+		for j := range row {
+			s, err := csvValue(value(r.Column(j), i))
+			if err != nil {
+				return err
+			}
+			row[j] = s
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvValue(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	default:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+}
+
+// value returns the value at index i of the given array. Binary values are
+// returned as strings, since frostdb stores strings as binary.
+func value(arr arrow.Array, i int) any {
+	if arr.IsNull(i) {
+		return nil
+	}
+	switch arr := arr.(type) {
+	case *array.Dictionary:
+		return value(arr.Dictionary(), arr.GetValueIndex(i))
+	case *array.Binary:
+		return string(arr.Value(i))
+	case *array.String:
+		return arr.Value(i)
+	case *array.Boolean:
+		return arr.Value(i)
+	case *array.Int64:
+		return arr.Value(i)
+	case *array.Uint64:
+		return arr.Value(i)
+	case *array.Float64:
+		return arr.Value(i)
+	case *array.Float32:
+		return arr.Value(i)
+	case *array.List:
+		start, end := arr.ValueOffsets(i)
+		values := make([]any, 0, end-start)
+		for j := start; j < end; j++ {
+			values = append(values, value(arr.ListValues(), int(j)))
+		}
+		return values
+	default:
+		return arr.GetOneForMarshal(i)
+	}
+}
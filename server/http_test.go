@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+
+	"github.com/polarsignals/frostdb"
+	"github.com/polarsignals/frostdb/dynparquet"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	ctx := context.Background()
+	store, err := frostdb.New()
+	require.NoError(t, err)
+	defer store.Close()
+	db, err := store.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", frostdb.NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(NewHTTPHandler(store))
+	defer srv.Close()
+
+	post := func(t *testing.T, format, body string) (int, string, http.Header) {
+		t.Helper()
+		resp, err := http.Post(srv.URL+"?format="+format, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(b), resp.Trailer
+	}
+
+	sql := `{"database": "test", "table": "test", "sql": "select labels.namespace, value where labels.namespace = 'default'"}`
+	code, body, trailer := post(t, "jsonl", sql)
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, trailer.Get(ErrorTrailer))
+	require.Equal(t, ""+
+		`{"labels.namespace":"default","value":3}`+"\n"+
+		`{"labels.namespace":"default","value":3}`+"\n",
+		body,
+	)
+
+	code, body, _ = post(t, "csv", sql)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "labels.namespace,value\ndefault,3\ndefault,3\n", body)
+
+	plan := `{"database": "test", "plan": {
+		"input": {"input": {"tableScan": {"tableName": "test"}}, "filter": {"expr": {"binary": {
+			"left": {"column": {"name": "value"}}, "op": "OP_GT", "right": {"literal": {"int64Value": "4"}}
+		}}}},
+		"projection": {"exprs": [{"column": {"name": "value"}}]}
+	}}`
+	code, body, _ = post(t, "csv", plan)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "value\n5\n", body)
+
+	code, _, _ = post(t, "jsonl", `{"database": "missing", "table": "test", "sql": "select value"}`)
+	require.Equal(t, http.StatusNotFound, code)
+	code, _, _ = post(t, "xml", sql)
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestEncodeFloat32(t *testing.T) {
+	b := array.NewFloat32Builder(memory.DefaultAllocator)
+	defer b.Release()
+	b.AppendValues([]float32{1.5, float32(math.NaN()), float32(math.Inf(1))}, nil)
+	values := b.NewArray()
+	defer values.Release()
+	r := array.NewRecord(arrow.NewSchema([]arrow.Field{{Name: "value", Type: arrow.PrimitiveTypes.Float32}}, nil), []arrow.Array{values}, 3)
+	defer r.Release()
+
+	var buf bytes.Buffer
+	require.NoError(t, (&jsonEncoder{}).encode(&buf, r))
+	require.Equal(t, `{"value":1.5}`+"\n"+`{"value":"NaN"}`+"\n"+`{"value":"+Inf"}`+"\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, (&csvEncoder{}).encode(&buf, r))
+	require.Equal(t, "value\n1.5\nNaN\n+Inf\n", buf.String())
+}
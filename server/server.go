@@ -0,0 +1,219 @@
+// Package server implements the frostdb gRPC service, which inserts into and
+// queries the databases of a column store with Arrow IPC payloads. It is
+// registered with a gRPC server:
+//
+//	s := grpc.NewServer()
+//	rpcpb.RegisterFrostDBServiceServer(s, server.New(store))
+//
+// Queries may also be served over HTTP by an HTTPHandler.
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/polarsignals/frostdb"
+	rpcpb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/rpc/v1alpha1"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// Server implements the FrostDBService on the databases of a column store.
+type Server struct {
+	rpcpb.UnimplementedFrostDBServiceServer
+
+	store         *frostdb.ColumnStore
+	pool          memory.Allocator
+	engineOptions []query.Option
+}
+
+type Option func(*Server)
+
+// WithAllocator sets the allocator used to decode inserted records and to
+// execute queries. Defaults to memory.DefaultAllocator.
+func WithAllocator(pool memory.Allocator) Option {
+	return func(s *Server) {
+		s.pool = pool
+	}
+}
+
+// WithEngineOptions sets the options of the engines executing queries, so
+// e.g. authorizers and row filters are enforced on the queries of the
+// service.
+func WithEngineOptions(options ...query.Option) Option {
+	return func(s *Server) {
+		s.engineOptions = append(s.engineOptions, options...)
+	}
+}
+
+// New returns a service serving the databases of the given column store.
+func New(store *frostdb.ColumnStore, options ...Option) *Server {
+	s := &Server{
+		store: store,
+		pool:  memory.DefaultAllocator,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+func (s *Server) Insert(stream rpcpb.FrostDBService_InsertServer) error {
+	ctx := stream.Context()
+	var tx uint64
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&rpcpb.InsertResponse{Tx: tx})
+		}
+		if err != nil {
+			return err
+		}
+
+		table, err := s.table(ctx, req)
+		if err != nil {
+			return err
+		}
+		reqTx, err := s.insert(ctx, table, req.Records)
+		if err != nil {
+			return err
+		}
+		if reqTx != 0 {
+			tx = reqTx
+		}
+	}
+}
+
+// table returns the table the given request inserts into. The database and
+// table are created if they do not exist yet.
+func (s *Server) table(ctx context.Context, req *rpcpb.InsertRequest) (*frostdb.Table, error) {
+	if req.Database == "" || req.Table == "" {
+		return nil, status.Error(codes.InvalidArgument, "database and table must be set")
+	}
+	db, err := s.store.DB(ctx, req.Database)
+	if err != nil {
+		return nil, err
+	}
+	if req.TableConfig != nil {
+		table, err := db.Table(req.Table, req.TableConfig)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return table, nil
+	}
+	table, err := db.GetTable(req.Table)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return table, nil
+}
+
+// insert inserts the records of the given Arrow IPC stream and returns the
+// transaction of the last record.
+func (s *Server) insert(ctx context.Context, table *frostdb.Table, records []byte) (uint64, error) {
+	reader, err := ipc.NewReader(bytes.NewReader(records), ipc.WithAllocator(s.pool))
+	if err != nil {
+		return 0, status.Errorf(codes.InvalidArgument, "decode records: %v", err)
+	}
+	defer reader.Release()
+
+	var tx uint64
+	for reader.Next() {
+		tx, err = table.InsertRecord(ctx, reader.Record())
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return 0, status.Errorf(codes.InvalidArgument, "decode records: %v", err)
+	}
+	return tx, nil
+}
+
+func (s *Server) Query(req *rpcpb.QueryRequest, stream rpcpb.FrostDBService_QueryServer) error {
+	db, err := s.store.GetDB(req.Database)
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	plan, err := logicalplan.FromProto(req.Plan, db.TableProvider())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if plan == nil {
+		return status.Error(codes.InvalidArgument, "empty plan")
+	}
+	b, err := builder(query.NewEngine(s.pool, db.TableProvider(), s.engineOptions...), plan)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var (
+		mtx sync.Mutex
+		buf bytes.Buffer
+	)
+	return b.Execute(stream.Context(), func(_ context.Context, r arrow.Record) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		buf.Reset()
+		writer := ipc.NewWriter(&buf, ipc.WithSchema(r.Schema()), ipc.WithAllocator(s.pool))
+		if err := writer.Write(r); err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		return stream.Send(&rpcpb.QueryResponse{Record: buf.Bytes()})
+	})
+}
+
+// builder returns a query of the given engine executing the given plan,
+// which must be a linear plan starting with a table or schema scan.
+func builder(engine *query.LocalEngine, plan *logicalplan.LogicalPlan) (query.Builder, error) {
+	var nodes []*logicalplan.LogicalPlan
+	for p := plan; p != nil; p = p.Input {
+		nodes = append([]*logicalplan.LogicalPlan{p}, nodes...)
+	}
+
+	var b query.Builder
+	switch scan := nodes[0]; {
+	case scan.TableScan != nil:
+		b = engine.ScanTable(scan.TableScan.TableName)
+		if tx := scan.TableScan.AsOfTx; tx != 0 {
+			b = b.AsOfTx(tx)
+		}
+	case scan.SchemaScan != nil:
+		b = engine.ScanSchema(scan.SchemaScan.TableName)
+		if tx := scan.SchemaScan.AsOfTx; tx != 0 {
+			b = b.AsOfTx(tx)
+		}
+	default:
+		return nil, errors.New("plan does not start with a scan")
+	}
+
+	for _, node := range nodes[1:] {
+		switch {
+		case node.Filter != nil:
+			b = b.Filter(node.Filter.Expr)
+		case node.Projection != nil:
+			b = b.Project(node.Projection.Exprs...)
+		case node.Distinct != nil:
+			b = b.Distinct(node.Distinct.Exprs...)
+		case node.Aggregation != nil:
+			b = b.Aggregate(node.Aggregation.AggExprs, node.Aggregation.GroupExprs)
+		default:
+			return nil, fmt.Errorf("unsupported plan node: %s", node)
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/polarsignals/frostdb"
+	"github.com/polarsignals/frostdb/dynparquet"
+	rpcpb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/rpc/v1alpha1"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+func newTestClient(t *testing.T) rpcpb.FrostDBServiceClient {
+	t.Helper()
+
+	store, err := frostdb.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	rpcpb.RegisterFrostDBServiceServer(srv, New(store))
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return rpcpb.NewFrostDBServiceClient(conn)
+}
+
+func encodeSamples(t *testing.T) []byte {
+	t.Helper()
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+
+	var buf bytes.Buffer
+	w := ipc.NewWriter(&buf, ipc.WithSchema(r.Schema()))
+	require.NoError(t, w.Write(r))
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	insert, err := client.Insert(ctx)
+	require.NoError(t, err)
+	require.NoError(t, insert.Send(&rpcpb.InsertRequest{
+		Database:    "test",
+		Table:       "test",
+		TableConfig: frostdb.NewTableConfig(dynparquet.SampleDefinition()),
+		Records:     encodeSamples(t),
+	}))
+	// The table exists now, so the config may be omitted.
+	require.NoError(t, insert.Send(&rpcpb.InsertRequest{
+		Database: "test",
+		Table:    "test",
+		Records:  encodeSamples(t),
+	}))
+	res, err := insert.CloseAndRecv()
+	require.NoError(t, err)
+	require.NotZero(t, res.Tx)
+
+	p, err := logicalplan.ToProto(&logicalplan.LogicalPlan{
+		Projection: &logicalplan.Projection{Exprs: []logicalplan.Expr{logicalplan.Col("value")}},
+		Input: &logicalplan.LogicalPlan{
+			Filter: &logicalplan.Filter{Expr: logicalplan.Col("labels.namespace").Eq(logicalplan.Literal("default"))},
+			Input: &logicalplan.LogicalPlan{
+				TableScan: &logicalplan.TableScan{TableName: "test"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	stream, err := client.Query(ctx, &rpcpb.QueryRequest{Database: "test", Plan: p})
+	require.NoError(t, err)
+	var rows int64
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		reader, err := ipc.NewReader(bytes.NewReader(resp.Record), ipc.WithAllocator(memory.DefaultAllocator))
+		require.NoError(t, err)
+		for reader.Next() {
+			require.Equal(t, "value", reader.Schema().Field(0).Name)
+			rows += reader.Record().NumRows()
+		}
+		reader.Release()
+	}
+	require.Equal(t, int64(4), rows)
+
+	// Inserting into a table that does not exist requires its config.
+	insert, err = client.Insert(ctx)
+	require.NoError(t, err)
+	require.NoError(t, insert.Send(&rpcpb.InsertRequest{
+		Database: "test",
+		Table:    "missing",
+		Records:  encodeSamples(t),
+	}))
+	_, err = insert.CloseAndRecv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	stream, err = client.Query(ctx, &rpcpb.QueryRequest{Database: "missing", Plan: p})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"hash"
 	"hash/crc32"
@@ -23,6 +24,7 @@ import (
 	"github.com/go-kit/log/level"
 
 	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/encryption"
 	snapshotpb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/snapshot/v1alpha1"
 	tablepb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/table/v1alpha1"
 	walpb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/wal/v1alpha1"
@@ -198,7 +200,7 @@ func (db *DB) snapshotAtTX(ctx context.Context, tx uint64, writeSnapshot func(co
 		defer f.Close()
 
 		if err := func() error {
-			if err := writeSnapshot(ctx, f); err != nil {
+			if err := db.writeSnapshotFile(ctx, f, snapshotFileName(tx), writeSnapshot); err != nil {
 				return err
 			}
 			if err := f.Sync(); err != nil {
@@ -236,6 +238,44 @@ func (db *DB) snapshotAtTX(ctx context.Context, tx uint64, writeSnapshot func(co
 	return nil
 }
 
+// writeSnapshotFile writes a snapshot to the given file, encrypting it under
+// the file name if encryption is enabled.
+func (db *DB) writeSnapshotFile(ctx context.Context, f io.Writer, name string, writeSnapshot func(context.Context, io.Writer) error) error {
+	keys := db.columnStore.encryptionKeys
+	if keys == nil {
+		return writeSnapshot(ctx, f)
+	}
+	w, err := encryption.NewWriter(f, keys, name)
+	if err != nil {
+		return err
+	}
+	if err := writeSnapshot(ctx, w); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// snapshotReaderAt returns a reader over the snapshot file with the given name
+// in r and its size, decrypting it if it is encrypted.
+func (db *DB) snapshotReaderAt(r io.ReaderAt, size int64, name string) (io.ReaderAt, int64, error) {
+	keys := db.columnStore.encryptionKeys
+	if keys == nil {
+		if _, err := encryption.KeyID(r); err == nil {
+			return nil, 0, fmt.Errorf("snapshot %s is encrypted but no encryption keys were provided", name)
+		}
+		return r, size, nil
+	}
+	er, err := encryption.NewReaderAt(r, size, keys, name)
+	if err != nil {
+		if errors.Is(err, encryption.ErrNotEncrypted) {
+			// Snapshot was taken before encryption was enabled.
+			return r, size, nil
+		}
+		return nil, 0, err
+	}
+	return er, er.Size(), nil
+}
+
 // loadLatestSnapshot loads the latest snapshot (i.e. the snapshot with the
 // highest txn) from the snapshots dir into the database.
 func (db *DB) loadLatestSnapshot(ctx context.Context) (uint64, error) {
@@ -259,7 +299,11 @@ func (db *DB) loadLatestSnapshotFromDir(ctx context.Context, dir string) (uint64
 			if err != nil {
 				return err
 			}
-			watermark, err := LoadSnapshot(ctx, db, parsedTx, f, info.Size(), false)
+			r, size, err := db.snapshotReaderAt(f, info.Size(), entry.Name())
+			if err != nil {
+				return err
+			}
+			watermark, err := LoadSnapshot(ctx, db, parsedTx, r, size, false)
 			if err != nil {
 				return err
 			}
@@ -317,8 +361,12 @@ func (db *DB) getLatestValidSnapshotTxn(ctx context.Context) (uint64, error) {
 			if err != nil {
 				return err
 			}
+			r, size, err := db.snapshotReaderAt(f, info.Size(), entry.Name())
+			if err != nil {
+				return err
+			}
 			// readFooter validates the checksum.
-			if _, err := readFooter(f, info.Size()); err != nil {
+			if _, err := readFooter(r, size); err != nil {
 				return err
 			}
 			return nil
@@ -426,6 +474,9 @@ func WriteSnapshot(ctx context.Context, _ uint64, db *DB, w io.Writer, offline b
 				},
 			}
 
+			// Staged records are not part of the index until they are
+			// flushed.
+			block.Index().FlushAll(t.db.HighWatermark())
 			var ascendErr error
 			block.Index().Iterate(func(node *index.Node) bool {
 				granuleMeta := &snapshotpb.Granule{}
@@ -611,59 +662,7 @@ func loadSnapshot(ctx context.Context, db *DB, r io.ReaderAt, size int64) error
 			block.mtx.Unlock()
 			table.mtx.Unlock()
 
-			for _, granuleMeta := range tableMeta.GranuleMetadata {
-				resultParts := make([]parts.Part, 0, len(granuleMeta.PartMetadata))
-				for _, partMeta := range granuleMeta.PartMetadata {
-					if err := ctx.Err(); err != nil {
-						return err
-					}
-					startOffset := partMeta.StartOffset
-					endOffset := partMeta.EndOffset
-					partBytes := make([]byte, endOffset-startOffset)
-					if _, err := r.ReadAt(partBytes, startOffset); err != nil {
-						return err
-					}
-					partOptions := parts.WithCompactionLevel(int(partMeta.CompactionLevel))
-					switch partMeta.Encoding {
-					case snapshotpb.Part_ENCODING_PARQUET:
-						serBuf, err := dynparquet.ReaderFromBytes(partBytes)
-						if err != nil {
-							return err
-						}
-						resultParts = append(resultParts, parts.NewParquetPart(partMeta.Tx, serBuf, partOptions))
-					case snapshotpb.Part_ENCODING_ARROW:
-						if err := func() error {
-							arrowReader, err := ipc.NewReader(bytes.NewReader(partBytes))
-							if err != nil {
-								return err
-							}
-							defer arrowReader.Release()
-
-							record, err := arrowReader.Read()
-							if err != nil {
-								return err
-							}
-
-							record.Retain()
-							resultParts = append(
-								resultParts,
-								parts.NewArrowPart(partMeta.Tx, record, uint64(util.TotalRecordSize(record)), table.schema, partOptions),
-							)
-							return nil
-						}(); err != nil {
-							return err
-						}
-					default:
-						return fmt.Errorf("unknown part encoding: %s", partMeta.Encoding)
-					}
-				}
-
-				for _, part := range resultParts {
-					newIdx.InsertPart(index.SentinelType(part.CompactionLevel()), part)
-				}
-			}
-
-			return nil
+			return loadSnapshotParts(ctx, r, tableMeta, table.schema, newIdx)
 		}(); err != nil {
 			db.mtx.Lock()
 			for _, cleanupTable := range footer.TableMetadata[:i] {
@@ -677,6 +676,63 @@ func loadSnapshot(ctx context.Context, db *DB, r io.ReaderAt, size int64) error
 	return nil
 }
 
+// loadSnapshotParts inserts the parts of the given table of a snapshot into
+// the given index.
+func loadSnapshotParts(ctx context.Context, r io.ReaderAt, tableMeta *snapshotpb.Table, schema *dynparquet.Schema, idx *index.LSM) error {
+	for _, granuleMeta := range tableMeta.GranuleMetadata {
+		resultParts := make([]parts.Part, 0, len(granuleMeta.PartMetadata))
+		for _, partMeta := range granuleMeta.PartMetadata {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			startOffset := partMeta.StartOffset
+			endOffset := partMeta.EndOffset
+			partBytes := make([]byte, endOffset-startOffset)
+			if _, err := r.ReadAt(partBytes, startOffset); err != nil {
+				return err
+			}
+			partOptions := parts.WithCompactionLevel(int(partMeta.CompactionLevel))
+			switch partMeta.Encoding {
+			case snapshotpb.Part_ENCODING_PARQUET:
+				serBuf, err := dynparquet.ReaderFromBytes(partBytes)
+				if err != nil {
+					return err
+				}
+				resultParts = append(resultParts, parts.NewParquetPart(partMeta.Tx, serBuf, partOptions))
+			case snapshotpb.Part_ENCODING_ARROW:
+				if err := func() error {
+					arrowReader, err := ipc.NewReader(bytes.NewReader(partBytes))
+					if err != nil {
+						return err
+					}
+					defer arrowReader.Release()
+
+					record, err := arrowReader.Read()
+					if err != nil {
+						return err
+					}
+
+					record.Retain()
+					resultParts = append(
+						resultParts,
+						parts.NewArrowPart(partMeta.Tx, record, uint64(util.TotalRecordSize(record)), schema, partOptions),
+					)
+					return nil
+				}(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown part encoding: %s", partMeta.Encoding)
+			}
+		}
+
+		for _, part := range resultParts {
+			idx.InsertPart(index.SentinelType(part.CompactionLevel()), part)
+		}
+	}
+	return nil
+}
+
 // cleanupSnapshotDir should be called with a tx at which the caller is certain
 // a valid snapshot exists (e.g. the tx returned from
 // getLatestValidSnapshotTxn). This method deletes all snapshots taken at any
@@ -1,6 +1,7 @@
 package frostdb
 
 import (
+	"bytes"
 	"context"
 	"math"
 	"os"
@@ -16,6 +17,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/polarsignals/frostdb/dynparquet"
+	"github.com/polarsignals/frostdb/encryption"
 	"github.com/polarsignals/frostdb/query"
 	"github.com/polarsignals/frostdb/query/logicalplan"
 )
@@ -405,3 +407,25 @@ func TestSnapshotIsTakenOnUncompressedInserts(t *testing.T) {
 		"expected snapshot to be taken",
 	)
 }
+
+func TestSnapshotEncryptedWithoutKeys(t *testing.T) {
+	keys, err := encryption.NewStaticKeyProvider("key1", map[string][]byte{
+		"key1": make([]byte, 32),
+	})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	w, err := encryption.NewWriter(&buf, keys, snapshotFileName(1))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("snapshot"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	c, err := New(WithLogger(newTestLogger(t)))
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+
+	_, _, err = db.snapshotReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()), snapshotFileName(1))
+	require.ErrorContains(t, err, "no encryption keys")
+}
@@ -0,0 +1,247 @@
+package frostdb
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+)
+
+var (
+	soakDuration = flag.Duration("soak.duration", 0, "duration of TestSoak, a short smoke run if 0")
+	soakSeed     = flag.Int64("soak.seed", 0, "seed of the workload of TestSoak, random if 0")
+)
+
+// TestSoak runs randomized concurrent inserts, queries, compactions and block
+// rotations against a table, crashing and recovering the column store between
+// epochs, and checks that:
+//   - no row whose insert returned is lost, neither by queries nor by recovery,
+//   - no row is read twice,
+//   - the high watermark of the database never decreases.
+//
+// Crashes close the column store without snapshotting or persisting the active
+// block, so it is recovered from the WAL and the persisted blocks. Run it for
+// longer, e.g. nightly, with:
+//
+//	go test -run TestSoak -soak.duration=1h
+func TestSoak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping soak test in short mode")
+	}
+	seed := *soakSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	t.Logf("seed: %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+
+	epochs, epoch := 3, 300*time.Millisecond
+	if *soakDuration > 0 {
+		epoch = 5 * time.Second
+		epochs = int(*soakDuration / epoch)
+	}
+
+	s := &soak{
+		dir:       t.TempDir(),
+		bucket:    objstore.NewInMemBucket(),
+		committed: map[int64]uint64{},
+	}
+	for i := 0; i < epochs; i++ {
+		s.runEpoch(t, rng.Int63(), epoch)
+	}
+	t.Logf("inserted %d rows in %d epochs", len(s.committed), epochs)
+}
+
+type soak struct {
+	dir    string
+	bucket objstore.Bucket
+
+	mtx sync.Mutex
+	// committed are the transactions of the rows whose inserts returned, by
+	// the ID of the row.
+	committed map[int64]uint64
+	nextID    int64
+	// watermark is the highest watermark observed.
+	watermark uint64
+}
+
+// runEpoch opens the column store, checks that all committed rows were
+// recovered, runs the workload for the given duration and crashes.
+func (s *soak) runEpoch(t *testing.T, seed int64, d time.Duration) {
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithWAL(),
+		WithStoragePath(s.dir),
+		WithReadWriteStorage(NewDefaultObjstoreBucket(s.bucket)),
+		WithManualBlockRotation(),
+	)
+	require.NoError(t, err)
+	db, err := c.DB(context.Background(), "soak")
+	require.NoError(t, err)
+	table, err := db.Table("soak", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	require.NoError(t, s.observeWatermark(db))
+	s.check(t, table)
+
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	errg, ctx := errgroup.WithContext(ctx)
+	worker := func(seed int64, op func(rng *rand.Rand) error) {
+		rng := rand.New(rand.NewSource(seed))
+		errg.Go(func() error {
+			for ctx.Err() == nil {
+				if err := op(rng); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	sleep := func(rng *rand.Rand, max time.Duration) {
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Duration(rng.Int63n(int64(max)))):
+		}
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	for i := 0; i < 3; i++ {
+		worker(rng.Int63(), func(rng *rand.Rand) error { return s.insert(ctx, table, rng) })
+	}
+	for i := 0; i < 2; i++ {
+		worker(rng.Int63(), func(*rand.Rand) error { return s.query(ctx, t, table) })
+	}
+	worker(rng.Int63(), func(rng *rand.Rand) error {
+		sleep(rng, 20*time.Millisecond)
+		return table.EnsureCompaction()
+	})
+	worker(rng.Int63(), func(rng *rand.Rand) error {
+		sleep(rng, 100*time.Millisecond)
+		return table.RotateBlock(ctx, table.ActiveBlock(), false)
+	})
+	worker(rng.Int63(), func(rng *rand.Rand) error {
+		sleep(rng, time.Millisecond)
+		return s.observeWatermark(db)
+	})
+	require.NoError(t, errg.Wait())
+
+	// Crash.
+	require.NoError(t, c.Close())
+}
+
+// insert inserts a batch of rows with new IDs and records them as committed.
+func (s *soak) insert(ctx context.Context, table *Table, rng *rand.Rand) error {
+	s.mtx.Lock()
+	first := s.nextID
+	n := 1 + rng.Intn(10)
+	s.nextID += int64(n)
+	s.mtx.Unlock()
+
+	samples := make(dynparquet.Samples, 0, n)
+	for id := first; id < first+int64(n); id++ {
+		samples = append(samples, dynparquet.Sample{
+			ExampleType: "soak",
+			Labels:      map[string]string{fmt.Sprintf("label%d", rng.Intn(3)): fmt.Sprint(rng.Intn(5))},
+			Timestamp:   id,
+			Value:       id,
+		})
+	}
+	r, err := samples.ToRecord()
+	if err != nil {
+		return err
+	}
+	defer r.Release()
+	tx, err := table.InsertRecord(ctx, r)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for id := first; id < first+int64(n); id++ {
+		s.committed[id] = tx
+	}
+	return nil
+}
+
+// query reads the table and checks that no row is read twice and that all
+// rows committed before the query with a transaction visible to it are read.
+func (s *soak) query(ctx context.Context, t *testing.T, table *Table) error {
+	s.mtx.Lock()
+	committed := make(map[int64]uint64, len(s.committed))
+	for id, tx := range s.committed {
+		committed[id] = tx
+	}
+	s.mtx.Unlock()
+
+	var (
+		read   map[int64]struct{}
+		readTx uint64
+	)
+	err := table.View(ctx, func(ctx context.Context, tx uint64) error {
+		readTx = tx
+		var err error
+		read, err = readIDs(ctx, table, tx)
+		return err
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for id, tx := range committed {
+		if _, ok := read[id]; !ok && tx <= readTx {
+			t.Errorf("row %d committed at tx %d not read at tx %d", id, tx, readTx)
+		}
+	}
+	return nil
+}
+
+// check checks that every committed row is read exactly once and that the
+// high watermark covers the transactions of all committed rows.
+func (s *soak) check(t *testing.T, table *Table) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for id, tx := range s.committed {
+		require.LessOrEqual(t, tx, s.watermark, "row %d committed at tx %d above high watermark", id, tx)
+	}
+	require.NoError(t, table.View(context.Background(), func(ctx context.Context, tx uint64) error {
+		read, err := readIDs(ctx, table, tx)
+		if err != nil {
+			return err
+		}
+		for id := range s.committed {
+			if _, ok := read[id]; !ok {
+				t.Errorf("committed row %d lost", id)
+			}
+		}
+		return nil
+	}))
+}
+
+// observeWatermark checks that the high watermark of the database did not
+// decrease since it was last observed, also across crashes.
+func (s *soak) observeWatermark(db *DB) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	watermark := db.HighWatermark()
+	if watermark < s.watermark {
+		return fmt.Errorf("high watermark decreased from %d to %d", s.watermark, watermark)
+	}
+	s.watermark = watermark
+	return nil
+}
@@ -135,6 +135,23 @@ func (v *astVisitor) leaveImpl(n ast.Node) error {
 		case opcode.LogicOr:
 			v.exprStack = append(v.exprStack, logicalplan.Or(leftExpr, rightExpr))
 			return nil
+		case opcode.Plus, opcode.Minus:
+			// String literals of time arithmetic are durations, e.g.
+			// now() - '5m'.
+			left, err := durationOperand(leftExpr)
+			if err != nil {
+				return err
+			}
+			right, err := durationOperand(rightExpr)
+			if err != nil {
+				return err
+			}
+			if expr.Op == opcode.Plus {
+				v.exprStack = append(v.exprStack, logicalplan.Add(left, right))
+			} else {
+				v.exprStack = append(v.exprStack, logicalplan.Sub(left, right))
+			}
+			return nil
 		}
 		v.exprStack = append(v.exprStack, &logicalplan.BinaryExpr{
 			Left:  logicalplan.Col(leftExpr.Name()),
@@ -179,10 +196,27 @@ func (v *astVisitor) leaveImpl(n ast.Node) error {
 		}
 		v.exprStack = append(v.exprStack, e)
 	case *ast.FieldList, *ast.ColumnNameExpr, *ast.GroupByClause, *ast.ByItem, *ast.RowExpr,
-		*ast.ParenthesesExpr:
+		*ast.ParenthesesExpr, *ast.TimeUnitExpr:
 		// Deliberate pass-through nodes.
 	case *ast.FuncCallExpr:
-		switch expr.FnName.String() {
+		switch expr.FnName.L {
+		case ast.Now:
+			v.exprStack = append(v.exprStack, logicalplan.Now())
+		case ast.DateAdd, ast.DateSub:
+			// E.g. now() - interval 5 minute, the time unit is not pushed to
+			// the stack.
+			n, newExprs := pop(v.exprStack)
+			t, newExprs := pop(newExprs)
+			v.exprStack = newExprs
+			d, err := intervalDuration(n, expr.Args[2].(*ast.TimeUnitExpr).Unit)
+			if err != nil {
+				return err
+			}
+			if expr.FnName.L == ast.DateAdd {
+				v.exprStack = append(v.exprStack, logicalplan.Add(t, d))
+			} else {
+				v.exprStack = append(v.exprStack, logicalplan.Sub(t, d))
+			}
 		case ast.Second:
 			// This is pretty hacky and only fine because it's in the test only.
 			left, right := pop(v.exprStack)
@@ -204,6 +238,55 @@ func (v *astVisitor) leaveImpl(n ast.Node) error {
 	return nil
 }
 
+// durationOperand returns the duration of a string literal operand of time
+// arithmetic, e.g. '5m', and any other operand as is.
+func durationOperand(e logicalplan.Expr) (logicalplan.Expr, error) {
+	l, ok := e.(*logicalplan.LiteralExpr)
+	if !ok {
+		return e, nil
+	}
+	s, ok := l.Value.(*scalar.String)
+	if !ok {
+		return e, nil
+	}
+	d, err := time.ParseDuration(string(s.Value.Bytes()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration %s: %w", l, err)
+	}
+	return logicalplan.Duration(d), nil
+}
+
+// intervalDuration returns the duration of an interval of the given number of
+// time units.
+func intervalDuration(n logicalplan.Expr, unit ast.TimeUnitType) (logicalplan.Expr, error) {
+	l, ok := n.(*logicalplan.LiteralExpr)
+	if !ok {
+		return nil, fmt.Errorf("unhandled interval %s", n)
+	}
+	val, ok := l.Value.(*scalar.Int64)
+	if !ok {
+		return nil, fmt.Errorf("unhandled interval %s", n)
+	}
+	var d time.Duration
+	switch unit {
+	case ast.TimeUnitMicrosecond:
+		d = time.Microsecond
+	case ast.TimeUnitSecond:
+		d = time.Second
+	case ast.TimeUnitMinute:
+		d = time.Minute
+	case ast.TimeUnitHour:
+		d = time.Hour
+	case ast.TimeUnitDay:
+		d = 24 * time.Hour
+	case ast.TimeUnitWeek:
+		d = 7 * 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("unhandled interval unit %s", unit)
+	}
+	return logicalplan.Duration(time.Duration(val.Value) * d), nil
+}
+
 func columnNameToString(c *ast.ColumnName) string {
 	// Note that in SQL labels.label2 is interpreted as referencing
 	// the label2 column of a table called labels. In our case,
@@ -6,10 +6,13 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"io"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/thanos-io/objstore"
 )
 
@@ -24,30 +27,236 @@ type FileReaderAt struct {
 	objstore.Bucket
 	name string
 	ctx  context.Context
+
+	policy  ReadPolicy
+	metrics *readMetrics
+
+	cache        Cache
+	cacheMetrics *cacheMetrics
+}
+
+// ReadPolicy configures retries and hedging of range reads, to keep the
+// latency of reads stable on flaky object stores.
+type ReadPolicy struct {
+	// MaxRetries is the number of times a failed range read is retried.
+	MaxRetries int
+	// MinBackoff is the backoff before the first retry. It doubles with
+	// every retry up to MaxBackoff. Defaults to 10ms.
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum backoff between retries. Defaults to 1s.
+	MaxBackoff time.Duration
+	// HedgeAfter is the duration after which a range read that did not
+	// complete is hedged by a second identical read, the first one to
+	// complete being used. Disabled if 0.
+	HedgeAfter time.Duration
+}
+
+type readMetrics struct {
+	retries   prometheus.Counter
+	hedges    prometheus.Counter
+	hedgeWins prometheus.Counter
+}
+
+func newReadMetrics(reg prometheus.Registerer) *readMetrics {
+	return &readMetrics{
+		retries: register(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "frostdb_bucket_read_retries_total",
+			Help: "Number of retried bucket range reads.",
+		})),
+		hedges: register(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "frostdb_bucket_read_hedges_total",
+			Help: "Number of hedged bucket range reads.",
+		})),
+		hedgeWins: register(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "frostdb_bucket_read_hedge_wins_total",
+			Help: "Number of hedged bucket range reads that completed before the read they hedged.",
+		})),
+	}
+}
+
+// register registers the collector with the registry, if any. The metrics
+// of buckets are labeled by the name of the bucket, so the collector of a
+// bucket with the same name that was already registered is shared instead.
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	if reg == nil {
+		return c
+	}
+	if err := reg.Register(c); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			if existing, ok := registered.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
 }
 
 // BucketReaderAt implements the Bucket interface.
 type BucketReaderAt struct {
 	objstore.Bucket
+
+	policy  ReadPolicy
+	reg     prometheus.Registerer
+	metrics *readMetrics
+
+	cache        Cache
+	cacheMetrics *cacheMetrics
+}
+
+type BucketReaderAtOption func(*BucketReaderAt)
+
+// WithReadPolicy sets the retry and hedging policy of range reads.
+func WithReadPolicy(policy ReadPolicy) BucketReaderAtOption {
+	return func(b *BucketReaderAt) {
+		if policy.MinBackoff <= 0 {
+			policy.MinBackoff = 10 * time.Millisecond
+		}
+		if policy.MaxBackoff <= 0 {
+			policy.MaxBackoff = time.Second
+		}
+		b.policy = policy
+	}
+}
+
+// WithRegistry sets the registry the read metrics are registered with. The
+// metrics are labeled by the name of the bucket.
+func WithRegistry(reg prometheus.Registerer) BucketReaderAtOption {
+	return func(b *BucketReaderAt) {
+		b.reg = reg
+	}
 }
 
 // NewBucketReaderAt returns a new Bucket.
-func NewBucketReaderAt(bucket objstore.Bucket) *BucketReaderAt {
-	return &BucketReaderAt{Bucket: bucket}
+func NewBucketReaderAt(bucket objstore.Bucket, options ...BucketReaderAtOption) *BucketReaderAt {
+	b := &BucketReaderAt{Bucket: bucket}
+	for _, option := range options {
+		option(b)
+	}
+	reg := b.reg
+	if reg != nil {
+		reg = prometheus.WrapRegistererWith(prometheus.Labels{"bucket": bucket.Name()}, reg)
+	}
+	b.metrics = newReadMetrics(reg)
+	if b.cache != nil {
+		b.cacheMetrics = newCacheMetrics(reg)
+	}
+	return b
 }
 
 // GetReaderAt returns a io.ReaderAt for the given filename.
 func (b *BucketReaderAt) GetReaderAt(ctx context.Context, name string) (io.ReaderAt, error) {
 	return &FileReaderAt{
-		Bucket: b.Bucket,
-		name:   name,
-		ctx:    ctx,
+		Bucket:       b.Bucket,
+		name:         name,
+		ctx:          ctx,
+		policy:       b.policy,
+		metrics:      b.metrics,
+		cache:        b.cache,
+		cacheMetrics: b.cacheMetrics,
 	}, nil
 }
 
 // ReadAt implements the io.ReaderAt interface.
-func (b *FileReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
-	rc, err := b.GetRange(b.ctx, b.name, off, int64(len(p)))
+func (b *FileReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if b.cache == nil {
+		return b.retryReadAt(p, off)
+	}
+
+	key := rangeCacheKey(b.Bucket.Name(), b.name, off, len(p))
+	value, ok, err := b.cache.Get(b.ctx, key)
+	switch {
+	case err != nil:
+		b.cacheMetrics.requests.WithLabelValues("error").Inc()
+	case ok && len(value) <= len(p):
+		b.cacheMetrics.requests.WithLabelValues("hit").Inc()
+		return copy(p, value), nil
+	default:
+		b.cacheMetrics.requests.WithLabelValues("miss").Inc()
+	}
+
+	n, err := b.retryReadAt(p, off)
+	if err != nil {
+		return n, err
+	}
+	if err := b.cache.Set(b.ctx, key, bytes.Clone(p[:n])); err != nil {
+		b.cacheMetrics.requests.WithLabelValues("error").Inc()
+	}
+	return n, nil
+}
+
+// retryReadAt reads the range, retrying failed reads according to the policy.
+func (b *FileReaderAt) retryReadAt(p []byte, off int64) (int, error) {
+	backoff := b.policy.MinBackoff
+	for retry := 0; ; retry++ {
+		n, err := b.hedgedReadAt(p, off)
+		if err == nil || retry >= b.policy.MaxRetries || b.ctx.Err() != nil {
+			return n, err
+		}
+		b.metrics.retries.Inc()
+		select {
+		case <-b.ctx.Done():
+			return n, err
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, b.policy.MaxBackoff)
+	}
+}
+
+// hedgedReadAt reads the range, starting a second read of it if the first one
+// did not complete after the hedge duration of the policy.
+func (b *FileReaderAt) hedgedReadAt(p []byte, off int64) (int, error) {
+	if b.policy.HedgeAfter <= 0 {
+		return b.readAt(b.ctx, p, off)
+	}
+
+	type result struct {
+		buf    []byte
+		n      int
+		err    error
+		hedged bool
+	}
+	ctx, cancel := context.WithCancel(b.ctx)
+	defer cancel()
+	results := make(chan result, 2)
+	read := func(hedged bool) {
+		buf := make([]byte, len(p))
+		n, err := b.readAt(ctx, buf, off)
+		results <- result{buf: buf, n: n, err: err, hedged: hedged}
+	}
+
+	go read(false)
+	pending := 1
+	timer := time.NewTimer(b.policy.HedgeAfter)
+	defer timer.Stop()
+	var res result
+	for {
+		select {
+		case <-timer.C:
+			b.metrics.hedges.Inc()
+			pending++
+			go read(true)
+			continue
+		case res = <-results:
+			pending--
+		}
+		if res.err == nil || pending == 0 {
+			break
+		}
+		// Wait for the other read.
+	}
+	if res.err != nil {
+		return 0, res.err
+	}
+	if res.hedged {
+		b.metrics.hedgeWins.Inc()
+	}
+	return copy(p, res.buf[:res.n]), nil
+}
+
+func (b *FileReaderAt) readAt(ctx context.Context, p []byte, off int64) (n int, err error) {
+	rc, err := b.GetRange(ctx, b.name, off, int64(len(p)))
 	if err != nil {
 		return 0, err
 	}
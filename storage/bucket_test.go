@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+)
+
+// unreliableBucket fails the first failures range reads and blocks the
+// first stalls range reads until their context is done.
+type unreliableBucket struct {
+	objstore.Bucket
+	failures atomic.Int64
+	stalls   atomic.Int64
+}
+
+func (b *unreliableBucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	if b.failures.Add(-1) >= 0 {
+		return nil, errors.New("unavailable")
+	}
+	if b.stalls.Add(-1) >= 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return b.Bucket.GetRange(ctx, name, off, length)
+}
+
+func TestReadPolicy(t *testing.T) {
+	ctx := context.Background()
+	bucket := &unreliableBucket{Bucket: objstore.NewInMemBucket()}
+	require.NoError(t, bucket.Upload(ctx, "data", bytes.NewReader([]byte("hello world"))))
+
+	t.Run("Retry", func(t *testing.T) {
+		b := NewBucketReaderAt(bucket, WithReadPolicy(ReadPolicy{MaxRetries: 2, MinBackoff: time.Millisecond}), WithRegistry(prometheus.NewRegistry()))
+		r, err := b.GetReaderAt(ctx, "data")
+		require.NoError(t, err)
+
+		buf := make([]byte, 5)
+		bucket.failures.Store(2)
+		n, err := r.ReadAt(buf, 6)
+		require.NoError(t, err)
+		require.Equal(t, "world", string(buf[:n]))
+		require.Equal(t, float64(2), testutil.ToFloat64(b.metrics.retries))
+
+		bucket.failures.Store(3)
+		_, err = r.ReadAt(buf, 6)
+		require.Error(t, err)
+		bucket.failures.Store(0)
+	})
+
+	t.Run("Hedge", func(t *testing.T) {
+		b := NewBucketReaderAt(bucket, WithReadPolicy(ReadPolicy{HedgeAfter: time.Millisecond}), WithRegistry(prometheus.NewRegistry()))
+		r, err := b.GetReaderAt(ctx, "data")
+		require.NoError(t, err)
+
+		buf := make([]byte, 5)
+		bucket.stalls.Store(1)
+		n, err := r.ReadAt(buf, 0)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(buf[:n]))
+		require.Equal(t, float64(1), testutil.ToFloat64(b.metrics.hedges))
+		require.Equal(t, float64(1), testutil.ToFloat64(b.metrics.hedgeWins))
+	})
+}
+
+type namedBucket struct {
+	objstore.Bucket
+	name string
+}
+
+func (b namedBucket) Name() string { return b.name }
+
+func TestBucketReaderAtSharedRegistry(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	cache := WithCache(NewLRUCache(1024))
+	primary := NewBucketReaderAt(namedBucket{Bucket: objstore.NewInMemBucket(), name: "primary"}, cache, WithRegistry(reg))
+	secondary := NewBucketReaderAt(namedBucket{Bucket: objstore.NewInMemBucket(), name: "secondary"}, cache, WithRegistry(reg))
+	require.NotSame(t, primary.metrics.retries, secondary.metrics.retries)
+
+	// Buckets with the same name share their metrics.
+	again := NewBucketReaderAt(namedBucket{Bucket: objstore.NewInMemBucket(), name: "primary"}, cache, WithRegistry(reg))
+	require.Same(t, primary.metrics.retries, again.metrics.retries)
+	require.Same(t, primary.cacheMetrics.requests, again.cacheMetrics.requests)
+}
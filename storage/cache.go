@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Cache caches the data of range reads of a bucket, so that repeated reads of
+// cold blocks are served without reading from the object store. Caches may be
+// in-process, like LRUCache, or shared by many processes, like MemcachedCache
+// and RedisCache. Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value of the given key and whether it was found.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set sets the value of the given key. The cache may evict it anytime.
+	Set(ctx context.Context, key string, value []byte) error
+}
+
+// WithCache makes range reads go through the given cache. The data of the
+// objects of the bucket must not change, which holds for blocks.
+func WithCache(cache Cache) BucketReaderAtOption {
+	return func(b *BucketReaderAt) {
+		b.cache = cache
+	}
+}
+
+type cacheMetrics struct {
+	requests *prometheus.CounterVec
+}
+
+func newCacheMetrics(reg prometheus.Registerer) *cacheMetrics {
+	return &cacheMetrics{
+		requests: register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "frostdb_bucket_cache_requests_total",
+			Help: "Number of bucket range reads looked up in the cache, by result.",
+		}, []string{"result"})),
+	}
+}
+
+// rangeCacheKey returns the cache key of the given range of the object.
+func rangeCacheKey(bucket, name string, off int64, length int) string {
+	return fmt.Sprintf("frostdb/%s/%s/%d/%d", bucket, name, off, length)
+}
+
+// LRUCache is an in-process Cache evicting the least recently used values
+// when the size of the values exceeds its capacity.
+type LRUCache struct {
+	maxBytes int64
+
+	mtx     sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type lruEntry struct {
+	key   string
+	value []byte
+}
+
+// NewLRUCache returns an LRUCache holding up to maxBytes bytes of values.
+func NewLRUCache(maxBytes int64) *LRUCache {
+	return &LRUCache{
+		maxBytes: maxBytes,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+// Get implements the Cache interface.
+func (c *LRUCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true, nil
+}
+
+// Set implements the Cache interface.
+func (c *LRUCache) Set(_ context.Context, key string, value []byte) error {
+	if int64(len(value)) > c.maxBytes {
+		return nil
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+	c.entries[key] = c.lru.PushFront(&lruEntry{key: key, value: value})
+	c.size += int64(len(value))
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+	return nil
+}
+
+func (c *LRUCache) remove(e *list.Element) {
+	entry := c.lru.Remove(e).(*lruEntry)
+	delete(c.entries, entry.key)
+	c.size -= int64(len(entry.value))
+}
+
+// Evict evicts the least recently read values until at least the given
+// number of bytes is freed or the cache is empty, and returns the number of
+// bytes freed. It allows to reclaim the memory of the cache under memory
+// pressure, see frostdb.WithEvictableCache.
+func (c *LRUCache) Evict(bytes int64) int64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	var freed int64
+	for freed < bytes && c.lru.Len() > 0 {
+		e := c.lru.Back()
+		freed += int64(len(e.Value.(*lruEntry).value))
+		c.remove(e)
+	}
+	return freed
+}
+
+// Size returns the size in bytes of the cached values.
+func (c *LRUCache) Size() int64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.size
+}
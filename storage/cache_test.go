@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+)
+
+func TestLRUCache(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRUCache(10)
+	require.NoError(t, c.Set(ctx, "a", []byte("aaaa")))
+	require.NoError(t, c.Set(ctx, "b", []byte("bbbb")))
+	_, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// b is the least recently used value.
+	require.NoError(t, c.Set(ctx, "c", []byte("cccc")))
+	_, ok, _ = c.Get(ctx, "b")
+	require.False(t, ok)
+	v, ok, _ := c.Get(ctx, "a")
+	require.True(t, ok)
+	require.Equal(t, "aaaa", string(v))
+	require.Equal(t, int64(8), c.Size())
+
+	// Values larger than the cache are not cached.
+	require.NoError(t, c.Set(ctx, "d", make([]byte, 11)))
+	_, ok, _ = c.Get(ctx, "d")
+	require.False(t, ok)
+}
+
+func TestLRUCacheEvict(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRUCache(100)
+	require.NoError(t, c.Set(ctx, "a", []byte("aaaa")))
+	require.NoError(t, c.Set(ctx, "b", []byte("bbbb")))
+	require.NoError(t, c.Set(ctx, "c", []byte("cccc")))
+	_, ok, _ := c.Get(ctx, "a")
+	require.True(t, ok)
+
+	// The least recently read values are evicted first.
+	require.Equal(t, int64(8), c.Evict(5))
+	_, ok, _ = c.Get(ctx, "b")
+	require.False(t, ok)
+	_, ok, _ = c.Get(ctx, "c")
+	require.False(t, ok)
+	_, ok, _ = c.Get(ctx, "a")
+	require.True(t, ok)
+	require.Equal(t, int64(4), c.Size())
+
+	require.Equal(t, int64(4), c.Evict(100))
+	require.Zero(t, c.Size())
+}
+
+func TestBucketCache(t *testing.T) {
+	ctx := context.Background()
+	bucket := &unreliableBucket{Bucket: objstore.NewInMemBucket()}
+	require.NoError(t, bucket.Upload(ctx, "data", bytes.NewReader([]byte("hello world"))))
+
+	b := NewBucketReaderAt(bucket, WithCache(NewLRUCache(1024)), WithRegistry(prometheus.NewRegistry()))
+	r, err := b.GetReaderAt(ctx, "data")
+	require.NoError(t, err)
+
+	buf := make([]byte, 5)
+	n, err := r.ReadAt(buf, 6)
+	require.NoError(t, err)
+	require.Equal(t, "world", string(buf[:n]))
+
+	// The second read is served from the cache.
+	bucket.failures.Store(1)
+	buf = make([]byte, 5)
+	n, err = r.ReadAt(buf, 6)
+	require.NoError(t, err)
+	require.Equal(t, "world", string(buf[:n]))
+	require.Equal(t, float64(1), testutil.ToFloat64(b.cacheMetrics.requests.WithLabelValues("hit")))
+	require.Equal(t, float64(1), testutil.ToFloat64(b.cacheMetrics.requests.WithLabelValues("miss")))
+
+	_, err = r.ReadAt(buf, 0)
+	require.Error(t, err)
+}
+
+func TestRemoteCaches(t *testing.T) {
+	for name, tc := range map[string]struct {
+		serve    func(*bufio.Reader, io.Writer, *cacheValues) error
+		newCache func(RemoteCacheConfig) Cache
+	}{
+		"Memcached": {
+			serve:    serveMemcached,
+			newCache: func(c RemoteCacheConfig) Cache { return NewMemcachedCache(c) },
+		},
+		"Redis": {
+			serve:    serveRedis,
+			newCache: func(c RemoteCacheConfig) Cache { return NewRedisCache(c) },
+		},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			addr := startCacheServer(t, tc.serve)
+			ctx := context.Background()
+			c := tc.newCache(RemoteCacheConfig{Addr: addr})
+
+			_, ok, err := c.Get(ctx, "key with spaces")
+			require.NoError(t, err)
+			require.False(t, ok)
+
+			value := []byte("value\r\nwith\r\nnewlines")
+			require.NoError(t, c.Set(ctx, "key with spaces", value))
+			v, ok, err := c.Get(ctx, "key with spaces")
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, value, v)
+		})
+	}
+}
+
+func TestMemcachedExptime(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for _, tc := range []struct {
+		ttl      time.Duration
+		expected int64
+	}{
+		{ttl: 0, expected: 0},
+		{ttl: time.Millisecond, expected: 1},
+		{ttl: 1500 * time.Millisecond, expected: 2},
+		{ttl: time.Minute, expected: 60},
+		{ttl: 30 * 24 * time.Hour, expected: 30 * 24 * 60 * 60},
+		{ttl: 31 * 24 * time.Hour, expected: now.Unix() + 31*24*60*60},
+	} {
+		require.Equal(t, tc.expected, memcachedExptime(tc.ttl, now), tc.ttl)
+	}
+}
+
+// startCacheServer starts a cache server serving connections with the given
+// function and returns its address.
+func startCacheServer(t *testing.T, serve func(*bufio.Reader, io.Writer, *cacheValues) error) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	values := &cacheValues{values: map[string][]byte{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					if err := serve(r, conn, values); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+type cacheValues struct {
+	mtx    sync.Mutex
+	values map[string][]byte
+}
+
+func (c *cacheValues) get(key string) ([]byte, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	v, ok := c.values[key]
+	return v, ok
+}
+
+func (c *cacheValues) set(key string, value []byte) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.values[key] = value
+}
+
+// serveMemcached serves a get or set request of the memcached text protocol.
+func serveMemcached(r *bufio.Reader, w io.Writer, values *cacheValues) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case "get":
+		if v, ok := values.get(fields[1]); ok {
+			fmt.Fprintf(w, "VALUE %s 0 %d\r\n%s\r\n", fields[1], len(v), v)
+		}
+		_, err = io.WriteString(w, "END\r\n")
+		return err
+	case "set":
+		size, _ := strconv.Atoi(fields[4])
+		v := make([]byte, size+2)
+		if _, err := io.ReadFull(r, v); err != nil {
+			return err
+		}
+		values.set(fields[1], v[:size])
+		_, err = io.WriteString(w, "STORED\r\n")
+		return err
+	default:
+		return errors.New("unknown command")
+	}
+}
+
+// serveRedis serves a GET or SET command of the Redis protocol.
+func serveRedis(r *bufio.Reader, w io.Writer, values *cacheValues) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return err
+		}
+		args = append(args, arg[:size])
+	}
+	switch string(args[0]) {
+	case "GET":
+		v, ok := values.get(string(args[1]))
+		if !ok {
+			_, err = io.WriteString(w, "$-1\r\n")
+			return err
+		}
+		_, err = fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+		return err
+	case "SET":
+		values.set(string(args[1]), args[2])
+		_, err = io.WriteString(w, "+OK\r\n")
+		return err
+	default:
+		return errors.New("unknown command")
+	}
+}
@@ -0,0 +1,373 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RemoteCacheConfig configures a cache server shared by many processes.
+type RemoteCacheConfig struct {
+	// Addr is the address of the cache server.
+	Addr string
+	// TTL is the time after which cached values expire. Values don't
+	// expire if 0.
+	TTL time.Duration
+	// Timeout is the timeout of the requests to the cache server.
+	// Defaults to 1s.
+	Timeout time.Duration
+	// MaxIdleConns is the number of idle connections kept open to the cache
+	// server. Defaults to 10.
+	MaxIdleConns int
+	// Password authenticates the connections to the cache server. Only
+	// supported by Redis.
+	Password string
+}
+
+func (c RemoteCacheConfig) withDefaults() RemoteCacheConfig {
+	if c.Timeout <= 0 {
+		c.Timeout = time.Second
+	}
+	if c.MaxIdleConns <= 0 {
+		c.MaxIdleConns = 10
+	}
+	return c
+}
+
+// remoteKey returns the key of a value in a remote cache. Keys are hashed to
+// respect the key length and character restrictions of cache servers.
+func remoteKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "frostdb:" + hex.EncodeToString(sum[:])
+}
+
+// cacheConn is a connection to a cache server.
+type cacheConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// connPool is a pool of connections to a cache server.
+type connPool struct {
+	config RemoteCacheConfig
+	init   func(*cacheConn) error
+	idle   chan *cacheConn
+}
+
+func newConnPool(config RemoteCacheConfig, init func(*cacheConn) error) *connPool {
+	return &connPool{
+		config: config,
+		init:   init,
+		idle:   make(chan *cacheConn, config.MaxIdleConns),
+	}
+}
+
+// do runs the request on a connection of the pool. The connection is closed
+// if the request fails, since its state is unknown.
+func (p *connPool) do(ctx context.Context, req func(*cacheConn) error) error {
+	conn, err := p.get(ctx)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(p.config.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	if err := req(conn); err != nil {
+		conn.Close()
+		return err
+	}
+	select {
+	case p.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return nil
+}
+
+func (p *connPool) get(ctx context.Context) (*cacheConn, error) {
+	select {
+	case conn := <-p.idle:
+		return conn, nil
+	default:
+	}
+	d := net.Dialer{Timeout: p.config.Timeout}
+	c, err := d.DialContext(ctx, "tcp", p.config.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &cacheConn{Conn: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+	if p.init != nil {
+		if err := conn.SetDeadline(time.Now().Add(p.config.Timeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if err := p.init(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Close closes the idle connections of the pool.
+func (p *connPool) Close() error {
+	for {
+		select {
+		case conn := <-p.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// readLine reads a line terminated by \r\n, without the terminator.
+func (c *cacheConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+// readValue reads a value of the given size followed by \r\n.
+func (c *cacheConn) readValue(size int) ([]byte, error) {
+	value := make([]byte, size+2)
+	if _, err := io.ReadFull(c.r, value); err != nil {
+		return nil, err
+	}
+	return value[:size], nil
+}
+
+// MemcachedCache is a Cache storing values in a memcached server.
+type MemcachedCache struct {
+	config RemoteCacheConfig
+	pool   *connPool
+}
+
+// NewMemcachedCache returns a Cache storing values in the memcached server
+// with the given config.
+func NewMemcachedCache(config RemoteCacheConfig) *MemcachedCache {
+	config = config.withDefaults()
+	return &MemcachedCache{
+		config: config,
+		pool:   newConnPool(config, nil),
+	}
+}
+
+// Get implements the Cache interface.
+func (c *MemcachedCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var (
+		value []byte
+		found bool
+	)
+	err := c.pool.do(ctx, func(conn *cacheConn) error {
+		key := remoteKey(key)
+		if _, err := fmt.Fprintf(conn.w, "get %s\r\n", key); err != nil {
+			return err
+		}
+		if err := conn.w.Flush(); err != nil {
+			return err
+		}
+		for {
+			line, err := conn.readLine()
+			if err != nil {
+				return err
+			}
+			switch fields := strings.Fields(line); {
+			case line == "END":
+				return nil
+			case len(fields) == 4 && fields[0] == "VALUE" && fields[1] == key:
+				size, err := strconv.Atoi(fields[3])
+				if err != nil {
+					return fmt.Errorf("invalid memcached response: %q", line)
+				}
+				if value, err = conn.readValue(size); err != nil {
+					return err
+				}
+				found = true
+			default:
+				return fmt.Errorf("unexpected memcached response: %q", line)
+			}
+		}
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return value, found, nil
+}
+
+// Set implements the Cache interface.
+func (c *MemcachedCache) Set(ctx context.Context, key string, value []byte) error {
+	return c.pool.do(ctx, func(conn *cacheConn) error {
+		exptime := memcachedExptime(c.config.TTL, time.Now())
+		if _, err := fmt.Fprintf(conn.w, "set %s 0 %d %d\r\n", remoteKey(key), exptime, len(value)); err != nil {
+			return err
+		}
+		if _, err := conn.w.Write(value); err != nil {
+			return err
+		}
+		if _, err := conn.w.WriteString("\r\n"); err != nil {
+			return err
+		}
+		if err := conn.w.Flush(); err != nil {
+			return err
+		}
+		line, err := conn.readLine()
+		if err != nil {
+			return err
+		}
+		if line != "STORED" {
+			return fmt.Errorf("unexpected memcached response: %q", line)
+		}
+		return nil
+	})
+}
+
+// maxMemcachedRelativeExptime is the largest expiration time memcached reads
+// as relative to the current time, larger ones are read as a Unix time.
+const maxMemcachedRelativeExptime = 30 * 24 * 60 * 60
+
+// memcachedExptime returns the memcached expiration time of values with the
+// given TTL that are set at the given time. TTLs are rounded up to whole
+// seconds, since an expiration time of 0 means that values don't expire, and
+// TTLs longer than 30 days are converted to a Unix time.
+func memcachedExptime(ttl time.Duration, now time.Time) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+	if seconds > maxMemcachedRelativeExptime {
+		return now.Unix() + seconds
+	}
+	return seconds
+}
+
+// Close closes the connections to the memcached server.
+func (c *MemcachedCache) Close() error {
+	return c.pool.Close()
+}
+
+// RedisCache is a Cache storing values in a Redis server.
+type RedisCache struct {
+	config RemoteCacheConfig
+	pool   *connPool
+}
+
+// NewRedisCache returns a Cache storing values in the Redis server with the
+// given config.
+func NewRedisCache(config RemoteCacheConfig) *RedisCache {
+	config = config.withDefaults()
+	var init func(*cacheConn) error
+	if config.Password != "" {
+		init = func(conn *cacheConn) error {
+			_, _, err := redisDo(conn, "AUTH", []byte(config.Password))
+			return err
+		}
+	}
+	return &RedisCache{
+		config: config,
+		pool:   newConnPool(config, init),
+	}
+}
+
+// Get implements the Cache interface.
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var (
+		value []byte
+		found bool
+	)
+	err := c.pool.do(ctx, func(conn *cacheConn) error {
+		var err error
+		value, found, err = redisDo(conn, "GET", []byte(remoteKey(key)))
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return value, found, nil
+}
+
+// Set implements the Cache interface.
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte) error {
+	args := [][]byte{[]byte(remoteKey(key)), value}
+	if ttl := c.config.TTL; ttl > 0 {
+		// Round up, so that sub-millisecond TTLs still expire.
+		ms := int64((ttl + time.Millisecond - 1) / time.Millisecond)
+		args = append(args, []byte("PX"), []byte(strconv.FormatInt(ms, 10)))
+	}
+	return c.pool.do(ctx, func(conn *cacheConn) error {
+		_, _, err := redisDo(conn, "SET", args...)
+		return err
+	})
+}
+
+// Close closes the connections to the Redis server.
+func (c *RedisCache) Close() error {
+	return c.pool.Close()
+}
+
+// redisDo sends the command to the Redis server and reads its reply. It
+// returns the bulk string of the reply and false if the reply is nil.
+func redisDo(conn *cacheConn, cmd string, args ...[]byte) ([]byte, bool, error) {
+	if _, err := fmt.Fprintf(conn.w, "*%d\r\n$%d\r\n%s\r\n", len(args)+1, len(cmd), cmd); err != nil {
+		return nil, false, err
+	}
+	for _, arg := range args {
+		if _, err := fmt.Fprintf(conn.w, "$%d\r\n", len(arg)); err != nil {
+			return nil, false, err
+		}
+		if _, err := conn.w.Write(arg); err != nil {
+			return nil, false, err
+		}
+		if _, err := conn.w.WriteString("\r\n"); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := conn.w.Flush(); err != nil {
+		return nil, false, err
+	}
+
+	line, err := conn.readLine()
+	if err != nil {
+		return nil, false, err
+	}
+	if line == "" {
+		return nil, false, errors.New("empty redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return []byte(line[1:]), true, nil
+	case '-':
+		return nil, false, fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid redis reply: %q", line)
+		}
+		if size < 0 {
+			return nil, false, nil
+		}
+		value, err := conn.readValue(size)
+		if err != nil {
+			return nil, false, err
+		}
+		return value, true, nil
+	default:
+		return nil, false, fmt.Errorf("unexpected redis reply: %q", line)
+	}
+}
@@ -2,9 +2,14 @@ package frostdb
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -15,6 +20,7 @@ import (
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/polarsignals/frostdb/audit"
 	"github.com/polarsignals/frostdb/dynparquet"
 	"github.com/polarsignals/frostdb/query/expr"
 	"github.com/polarsignals/frostdb/query/logicalplan"
@@ -29,7 +35,17 @@ func (t *TableBlock) Persist() error {
 	if len(t.table.db.sinks) == 0 {
 		return nil
 	}
+	start := time.Now()
 
+	// Pre-aggregates are computed before the block is serialized, which
+	// empties its index.
+	preAggregates, err := t.preAggregates(context.Background())
+	if err != nil {
+		level.Warn(t.logger).Log("msg", "failed to pre-aggregate block, persisting it without pre-aggregates", "err", err)
+		preAggregates = nil
+	}
+
+	var size int64
 	for i, sink := range t.table.db.sinks {
 		if i > 0 {
 			return fmt.Errorf("multiple sinks not supported")
@@ -42,8 +58,11 @@ func (t *TableBlock) Persist() error {
 		}()
 		defer r.Close()
 
-		fileName := filepath.Join(t.table.db.name, t.table.name, t.ulid.String(), "data.parquet")
-		if err := sink.Upload(context.Background(), fileName, r); err != nil {
+		blockDir := filepath.Join(t.table.db.prefix(), t.table.name, t.ulid.String())
+		fileName := filepath.Join(blockDir, blockDataFileName)
+		hash := sha256.New()
+		accountant := &accountingWriter{w: hash}
+		if err := sink.Upload(context.Background(), fileName, io.TeeReader(r, accountant)); err != nil {
 			return fmt.Errorf("failed to upload block %v", err)
 		}
 
@@ -53,9 +72,32 @@ func (t *TableBlock) Persist() error {
 			}
 			return fmt.Errorf("failed to serialize block: %w", err)
 		}
+
+		if err := uploadBlockManifest(context.Background(), sink, blockDir, blockManifest{
+			Size:          accountant.n,
+			SHA256:        hex.EncodeToString(hash.Sum(nil)),
+			PreAggregates: preAggregates,
+		}); err != nil {
+			return fmt.Errorf("failed to upload block manifest: %w", err)
+		}
+		size = accountant.n
+
+		if indexer, ok := sink.(blockIndexer); ok {
+			if err := indexer.indexBlock(context.Background(), blockDir); err != nil {
+				return fmt.Errorf("failed to index block: %w", err)
+			}
+		}
+		if cataloger, ok := sink.(blockCataloger); ok {
+			cataloger.catalogBlock(context.Background(), blockDir)
+		}
+		if replicator, ok := sink.(blockReplicator); ok {
+			replicator.replicateBlock(blockDir)
+		}
 	}
 
 	t.table.metrics.blockPersisted.Inc()
+	level.Info(t.logger).Log("msg", "persisted block", "size", size, "duration", time.Since(start))
+	t.table.emitPartEvent(PartEvent{Type: PartPersisted, Block: t.ulid, Size: size})
 	return nil
 }
 
@@ -66,16 +108,52 @@ type DefaultObjstoreBucket struct {
 	logger log.Logger
 
 	blockReaderLimit int
+	readerAtOptions  []storage.BucketReaderAtOption
+	prefetch         int
+
+	tableManifest    bool
+	tableManifestMtx sync.Mutex
+
+	replica    *bucketReplica
+	catalog    BlockCatalog
+	auditSinks []audit.Sink
 }
 
 type DefaultObjstoreBucketOption func(*DefaultObjstoreBucket)
 
+// StorageWithReaderAtOptions sets the options of the range reader of buckets
+// created with NewDefaultObjstoreBucket, e.g. storage.WithReadPolicy to retry
+// and hedge range reads during scans.
+func StorageWithReaderAtOptions(options ...storage.BucketReaderAtOption) DefaultObjstoreBucketOption {
+	return func(b *DefaultObjstoreBucket) {
+		b.readerAtOptions = append(b.readerAtOptions, options...)
+	}
+}
+
+// StorageWithPrefetch makes scans of a block read the byte ranges of the next
+// n selected row groups in the background while the current row group is
+// processed, overlapping bucket IO with decoding and filtering. Prefetching is
+// disabled by default.
+func StorageWithPrefetch(n int) DefaultObjstoreBucketOption {
+	return func(b *DefaultObjstoreBucket) {
+		b.prefetch = n
+	}
+}
+
 func StorageWithBlockReaderLimit(limit int) DefaultObjstoreBucketOption {
 	return func(b *DefaultObjstoreBucket) {
 		b.blockReaderLimit = limit
 	}
 }
 
+// StorageWithAuditSink registers sinks that receive an audit.EventBlockDeleted
+// event for every block deleted from the bucket.
+func StorageWithAuditSink(sinks ...audit.Sink) DefaultObjstoreBucketOption {
+	return func(b *DefaultObjstoreBucket) {
+		b.auditSinks = append(b.auditSinks, sinks...)
+	}
+}
+
 func StorageWithTracer(tracer trace.Tracer) DefaultObjstoreBucketOption {
 	return func(b *DefaultObjstoreBucket) {
 		b.tracer = tracer
@@ -105,7 +183,6 @@ func NewDefaultBucket(b storage.Bucket, options ...DefaultObjstoreBucketOption)
 
 func NewDefaultObjstoreBucket(b objstore.Bucket, options ...DefaultObjstoreBucketOption) *DefaultObjstoreBucket {
 	d := &DefaultObjstoreBucket{
-		Bucket:           storage.NewBucketReaderAt(b),
 		tracer:           trace.NewNoopTracerProvider().Tracer(""),
 		logger:           log.NewNopLogger(),
 		blockReaderLimit: DefaultBlockReaderLimit,
@@ -114,6 +191,7 @@ func NewDefaultObjstoreBucket(b objstore.Bucket, options ...DefaultObjstoreBucke
 	for _, option := range options {
 		option(d)
 	}
+	d.Bucket = storage.NewBucketReaderAt(b, d.readerAtOptions...)
 
 	return d
 }
@@ -148,10 +226,20 @@ func (b *DefaultObjstoreBucket) Scan(ctx context.Context, prefix string, _ *dynp
 		return err
 	}
 
+	if b.tableManifest {
+		if ok, err := b.scanTableManifest(ctx, prefix, f, lastBlockTimestamp, callback); ok {
+			return err
+		}
+	}
+
 	n := 0
 	errg := &errgroup.Group{}
 	errg.SetLimit(int(b.blockReaderLimit))
 	err = b.Iter(ctx, prefix, func(blockDir string) error {
+		if !strings.HasSuffix(blockDir, "/") {
+			// Not a block, e.g. the manifest of the table.
+			return nil
+		}
 		n++
 		errg.Go(func() error { return b.ProcessFile(ctx, blockDir, lastBlockTimestamp, f, callback) })
 		return nil
@@ -164,12 +252,21 @@ func (b *DefaultObjstoreBucket) Scan(ctx context.Context, prefix string, _ *dynp
 	return errg.Wait()
 }
 
-func (b *DefaultObjstoreBucket) openBlockFile(ctx context.Context, blockName string, size int64) (*parquet.File, error) {
-	ctx, span := b.tracer.Start(ctx, "Source/IterateBucketBlocks/Iter/OpenFile")
+// openBlockFile opens the given parquet file. If prefetching is enabled, the
+// file is read through the returned prefetchReader, otherwise it is nil.
+func (b *DefaultObjstoreBucket) openBlockFile(ctx context.Context, blockName string, size int64) (*parquet.File, *prefetchReader, error) {
+	spanCtx, span := b.tracer.Start(ctx, "Source/IterateBucketBlocks/Iter/OpenFile")
 	defer span.End()
-	r, err := b.GetReaderAt(ctx, blockName)
+	r, err := b.GetReaderAt(spanCtx, blockName)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	var prefetcher *prefetchReader
+	if b.prefetch > 0 {
+		// Keep the row group being read in addition to the next ones.
+		prefetcher = newPrefetchReader(ctx, r, b.prefetch+1)
+		r = prefetcher
 	}
 
 	file, err := parquet.OpenFile(
@@ -180,14 +277,20 @@ func (b *DefaultObjstoreBucket) openBlockFile(ctx context.Context, blockName str
 		parquet.FileReadMode(parquet.ReadModeAsync),
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return file, nil
+	return file, prefetcher, nil
 }
 
 // ProcessFile will process a bucket block parquet file.
 func (b *DefaultObjstoreBucket) ProcessFile(ctx context.Context, blockDir string, lastBlockTimestamp uint64, filter expr.TrueNegativeFilter, callback func(context.Context, any) error) error {
+	return b.processBlock(ctx, blockDir, -1, lastBlockTimestamp, filter, callback)
+}
+
+// processBlock processes the block in the given directory. The size of its
+// data file is looked up in the bucket if it is negative.
+func (b *DefaultObjstoreBucket) processBlock(ctx context.Context, blockDir string, size int64, lastBlockTimestamp uint64, filter expr.TrueNegativeFilter, callback func(context.Context, any) error) error {
 	ctx, span := b.tracer.Start(ctx, "Source/IterateBucketBlocks/Iter/ProcessFile")
 	defer span.End()
 
@@ -198,7 +301,14 @@ func (b *DefaultObjstoreBucket) ProcessFile(ctx context.Context, blockDir string
 
 	span.SetAttributes(attribute.String("ulid", blockUlid.String()))
 
+	stats := expr.PruningStatsFromContext(ctx)
+	if stats != nil {
+		stats.BlocksConsidered.Add(1)
+	}
 	if lastBlockTimestamp != 0 && blockUlid.Time() >= lastBlockTimestamp {
+		if stats != nil {
+			stats.BlocksSkipped.Add(1)
+		}
 		level.Debug(b.logger).Log(
 			"msg", "ignoring block due to last block timestamp",
 			"blockTime", blockUlid.Time(),
@@ -207,52 +317,113 @@ func (b *DefaultObjstoreBucket) ProcessFile(ctx context.Context, blockDir string
 		return nil
 	}
 
-	blockName := filepath.Join(blockDir, "data.parquet")
-	attribs, err := b.Attributes(ctx, blockName)
+	if !scanHookFromContext(ctx).scanBlock(ctx, blockUlid, true) {
+		if stats != nil {
+			stats.BlocksSkipped.Add(1)
+		}
+		return nil
+	}
+
+	if req := preAggregationFromContext(ctx); req != nil {
+		if ok, err := b.scanPreAggregates(ctx, blockDir, req, callback); ok || err != nil {
+			return err
+		}
+	}
+
+	policy := blockFailurePolicyFromContext(ctx)
+	var (
+		buf        *dynparquet.SerializedBuffer
+		prefetcher *prefetchReader
+	)
+	for attempt := 0; ; attempt++ {
+		buf, prefetcher, err = b.openBlock(ctx, blockDir, size)
+		if err == nil || ctx.Err() != nil || attempt >= policy.Retries {
+			break
+		}
+		level.Debug(b.logger).Log("msg", "retrying to open block", "block", blockDir, "attempt", attempt+1, "err", err)
+	}
 	if err != nil {
-		return err
+		if !policy.Skip || ctx.Err() != nil {
+			return err
+		}
+		level.Warn(b.logger).Log("msg", "skipping unreadable block", "block", blockDir, "err", err)
+		if policy.Skipped != nil {
+			policy.Skipped.add(SkippedBlock{BlockDir: blockDir, Err: err})
+		}
+		return nil
+	}
+	if buf == nil {
+		return nil
 	}
 
-	span.SetAttributes(attribute.Int64("size", attribs.Size))
+	return b.filterRowGroups(ctx, buf, prefetcher, filter, callback)
+}
 
-	if attribs.Size == 0 {
+// openBlock opens the data file of the given block. It returns nil if the
+// block is empty. The size of the data file is looked up in the bucket if it is
+// negative.
+func (b *DefaultObjstoreBucket) openBlock(ctx context.Context, blockDir string, size int64) (*dynparquet.SerializedBuffer, *prefetchReader, error) {
+	span := trace.SpanFromContext(ctx)
+	blockName := filepath.Join(blockDir, blockDataFileName)
+	if size < 0 {
+		attribs, err := b.Attributes(ctx, blockName)
+		if err != nil {
+			return nil, nil, err
+		}
+		size = attribs.Size
+	}
+
+	span.SetAttributes(attribute.Int64("size", size))
+
+	if size == 0 {
 		level.Debug(b.logger).Log(
 			"msg", "ignoring empty block",
-			"blockTime", blockUlid.Time(),
+			"block", blockDir,
 		)
-		return nil
+		return nil, nil, nil
 	}
 
-	file, err := b.openBlockFile(ctx, blockName, attribs.Size)
+	file, prefetcher, err := b.openBlockFile(ctx, blockName, size)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	// Get a reader from the file bytes
 	buf, err := dynparquet.NewSerializedBuffer(file)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-
-	return b.filterRowGroups(ctx, buf, filter, callback)
+	return buf, prefetcher, nil
 }
 
-func (b *DefaultObjstoreBucket) filterRowGroups(ctx context.Context, buf *dynparquet.SerializedBuffer, filter expr.TrueNegativeFilter, callback func(context.Context, any) error) error {
+func (b *DefaultObjstoreBucket) filterRowGroups(ctx context.Context, buf *dynparquet.SerializedBuffer, prefetcher *prefetchReader, filter expr.TrueNegativeFilter, callback func(context.Context, any) error) error {
 	_, span := b.tracer.Start(ctx, "Source/filterRowGroups")
 	defer span.End()
 	span.SetAttributes(attribute.Int("row_groups", buf.NumRowGroups()))
 
+	// Select the row groups first so that only the ones that are read are
+	// prefetched.
+	stats := expr.PruningStatsFromContext(ctx)
+	selected := make([]int, 0, buf.NumRowGroups())
 	for i := 0; i < buf.NumRowGroups(); i++ {
-		rg := buf.DynamicRowGroup(i)
-		mayContainUsefulData, err := filter.Eval(rg)
+		mayContainUsefulData, err := stats.Eval(filter, buf.DynamicRowGroup(i))
 		if err != nil {
 			return err
 		}
 		if mayContainUsefulData {
-			if err := callback(ctx, rg); err != nil {
-				return err
+			selected = append(selected, i)
+		}
+	}
+
+	for i, rg := range selected {
+		if prefetcher != nil {
+			for _, next := range selected[i+1 : min(i+1+b.prefetch, len(selected))] {
+				prefetchRowGroup(prefetcher, buf.ParquetFile(), next)
 			}
 		}
+		if err := callback(ctx, buf.DynamicRowGroup(rg)); err != nil {
+			return err
+		}
 	}
 
 	return nil
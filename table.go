@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"runtime/pprof"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -31,14 +32,18 @@ import (
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/polarsignals/frostdb/accounting"
 	"github.com/polarsignals/frostdb/dynparquet"
 	schemapb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/schema/v1alpha1"
 	schemav2pb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/schema/v1alpha2"
 	tablepb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/table/v1alpha1"
 	walpb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/wal/v1alpha1"
 	"github.com/polarsignals/frostdb/index"
+	"github.com/polarsignals/frostdb/mmap"
 	"github.com/polarsignals/frostdb/parts"
 	"github.com/polarsignals/frostdb/pqarrow"
+	"github.com/polarsignals/frostdb/pqarrow/arrowutils"
+	"github.com/polarsignals/frostdb/query/expr"
 	"github.com/polarsignals/frostdb/query/logicalplan"
 	"github.com/polarsignals/frostdb/query/physicalplan"
 	"github.com/polarsignals/frostdb/recovery"
@@ -69,6 +74,18 @@ type ErrReadRow struct{ err error }
 
 func (e ErrReadRow) Error() string { return "failed to read row: " + e.err.Error() }
 
+// ErrUnsortedInsert is returned by inserts into tables verifying the insert
+// order, see WithInsertOrderVerification, whose rows are not sorted by the
+// sorting columns of the schema.
+type ErrUnsortedInsert struct {
+	// Row is the index of the first row that sorts before the previous row.
+	Row int64
+}
+
+func (e ErrUnsortedInsert) Error() string {
+	return fmt.Sprintf("inserted rows are not sorted by the sorting columns: row %d sorts before the previous row", e.Row)
+}
+
 type ErrCreateSchemaWriter struct{ err error }
 
 func (e ErrCreateSchemaWriter) Error() string {
@@ -101,6 +118,67 @@ func WithoutWAL() TableOption {
 	}
 }
 
+// WithMaxPartRows splits parts compacted into in-memory or mapped Parquet buffers,
+// as well as inserted records, into parts of at most the given number of rows.
+// Smaller parts give finer grained pruning of scans. A <= 0 value indicates no
+// limit.
+func WithMaxPartRows(numRows int) TableOption {
+	return func(config *tablepb.TableConfig) error {
+		if numRows > 0 {
+			config.MaxPartRows = uint64(numRows)
+		}
+		return nil
+	}
+}
+
+// WithMaxPartBytes splits parts compacted into in-memory or mapped Parquet buffers,
+// as well as inserted records, into parts of at most roughly the given size in
+// bytes. A <= 0 value indicates no limit.
+func WithMaxPartBytes(size int64) TableOption {
+	return func(config *tablepb.TableConfig) error {
+		if size > 0 {
+			config.MaxPartBytes = uint64(size)
+		}
+		return nil
+	}
+}
+
+// WithPreAggregates persists the sum, count, minimum and maximum of the
+// numeric columns of every block in its manifest, per bucket of rows with the
+// same values of the first sortingColumns sorting columns of the schema, which
+// must be concrete columns. Aggregations of blocks whose rows all match the
+// filter of a query are computed from the pre-aggregates instead of reading
+// the blocks, if they aggregate by no other columns than those.
+func WithPreAggregates(sortingColumns int) TableOption {
+	return func(config *tablepb.TableConfig) error {
+		if sortingColumns > 0 {
+			config.PreAggregateSortingColumns = uint64(sortingColumns)
+		}
+		return nil
+	}
+}
+
+// WithInsertOrderVerification rejects inserts whose rows are not sorted by the
+// sorting columns of the schema with ErrUnsortedInsert. Unsorted inserts are
+// slower to merge when the index is compacted. Only the leading sorting
+// columns that can be sorted in Arrow are verified.
+func WithInsertOrderVerification() TableOption {
+	return func(config *tablepb.TableConfig) error {
+		config.VerifyInsertOrder = true
+		return nil
+	}
+}
+
+// WithAutoSort sorts the rows of inserts that are not sorted by the sorting
+// columns of the schema before they are inserted. Only the leading sorting
+// columns that can be sorted in Arrow are sorted by.
+func WithAutoSort() TableOption {
+	return func(config *tablepb.TableConfig) error {
+		config.AutoSort = true
+		return nil
+	}
+}
+
 func WithUniquePrimaryIndex(unique bool) TableOption {
 	return func(config *tablepb.TableConfig) error {
 		switch e := config.Schema.(type) {
@@ -114,6 +192,31 @@ func WithUniquePrimaryIndex(unique bool) TableOption {
 }
 
 // FromConfig sets the table configuration from the given config.
+// WithMinPartRows merges adjacent parts compacted into the same level if they
+// all have fewer than the given number of rows, so that compaction does not
+// leave behind many small parts, e.g. after splitting. A <= 0 value indicates
+// no minimum.
+func WithMinPartRows(numRows int) TableOption {
+	return func(config *tablepb.TableConfig) error {
+		if numRows > 0 {
+			config.MinPartRows = uint64(numRows)
+		}
+		return nil
+	}
+}
+
+// WithMinPartBytes merges adjacent parts compacted into the same level if they
+// are all smaller than the given size in bytes. A <= 0 value indicates no
+// minimum.
+func WithMinPartBytes(size int64) TableOption {
+	return func(config *tablepb.TableConfig) error {
+		if size > 0 {
+			config.MinPartBytes = uint64(size)
+		}
+		return nil
+	}
+}
+
 // NOTE: that this does not override the schema even though that is included in the passed in config.
 func FromConfig(config *tablepb.TableConfig) TableOption {
 	return func(cfg *tablepb.TableConfig) error {
@@ -122,6 +225,13 @@ func FromConfig(config *tablepb.TableConfig) TableOption {
 		}
 		cfg.DisableWal = config.DisableWal
 		cfg.RowGroupSize = config.RowGroupSize
+		cfg.MaxPartRows = config.MaxPartRows
+		cfg.MaxPartBytes = config.MaxPartBytes
+		cfg.MinPartRows = config.MinPartRows
+		cfg.MinPartBytes = config.MinPartBytes
+		cfg.PreAggregateSortingColumns = config.PreAggregateSortingColumns
+		cfg.VerifyInsertOrder = config.VerifyInsertOrder
+		cfg.AutoSort = config.AutoSort
 		return nil
 	}
 }
@@ -132,6 +242,23 @@ func defaultTableConfig() *tablepb.TableConfig {
 	}
 }
 
+// ValidateTableConfig checks the sanity of a table config and its schema
+// definition, returning all the problems found at once. See
+// dynparquet.ValidateDefinition for the checks done on the schema.
+func ValidateTableConfig(config *tablepb.TableConfig) error {
+	if config == nil {
+		return errors.New("table config cannot be nil")
+	}
+	switch schema := config.Schema.(type) {
+	case *tablepb.TableConfig_DeprecatedSchema:
+		return dynparquet.ValidateDefinition(schema.DeprecatedSchema)
+	case *tablepb.TableConfig_SchemaV2:
+		return dynparquet.ValidateDefinition(schema.SchemaV2)
+	default:
+		return errors.New("table config has no schema")
+	}
+}
+
 func NewTableConfig(
 	schema proto.Message,
 	options ...TableOption,
@@ -178,6 +305,8 @@ func (t *GenericTable[T]) Write(ctx context.Context, values ...T) error {
 	defer t.mu.Unlock()
 	err := t.build.Append(values...)
 	if err != nil {
+		// Discard the values appended before the error.
+		t.build.Reset()
 		return err
 	}
 	_, err = t.InsertRecord(ctx, t.build.NewRecord())
@@ -199,13 +328,23 @@ type Table struct {
 	metrics *tableMetrics
 	logger  log.Logger
 	tracer  trace.Tracer
+	// insertLogger and scanLogger sample the logs of inserts and scans.
+	insertLogger log.Logger
+	scanLogger   log.Logger
 
 	config atomic.Pointer[tablepb.TableConfig]
 	schema *dynparquet.Schema
+	// sharedSchema is the entry of the schema in the schema registry.
+	sharedSchema *sharedSchema
 
-	pendingBlocks   map[*TableBlock]struct{}
+	pendingBlocks map[*TableBlock]struct{}
+	// pendingDropped is signaled when a pending block is dropped.
+	pendingDropped  *sync.Cond
 	completedBlocks []completedBlock
 	lastCompleted   uint64
+	// blockWrites tracks the blocks being written in the background, so that
+	// closing the database does not close the WAL under them.
+	blockWrites sync.WaitGroup
 
 	mtx    *sync.RWMutex
 	active *TableBlock
@@ -213,6 +352,17 @@ type Table struct {
 	wal     WAL
 	closing bool
 	closers []io.Closer
+
+	// usage records the predicates of queries for the sorting advisor.
+	// Disabled if nil.
+	usage *predicateUsage
+	// scans are the shared scans of the table. Disabled if nil.
+	scans *sharedScans
+
+	// snapshotHorizon is the highest transaction of the rows that were
+	// compacted, rewritten or persisted, which are visible to every
+	// transaction from then on.
+	snapshotHorizon atomic.Uint64
 }
 
 type WAL interface {
@@ -254,6 +404,9 @@ type TableBlock struct {
 
 	index *index.LSM
 
+	// rewrite logs the inserted records while the block is rewritten.
+	rewrite atomic.Pointer[rewriteLog]
+
 	pendingWritersWg sync.WaitGroup
 	pendingReadersWg sync.WaitGroup
 
@@ -269,20 +422,35 @@ type tableMetrics struct {
 	rowInsertSize        prometheus.Histogram
 	lastCompletedBlockTx prometheus.Gauge
 	numParts             prometheus.Gauge
+	blocksVerified       *prometheus.CounterVec
+	backpressure         *prometheus.CounterVec
+	partSplits           prometheus.Counter
+	insertSplits         prometheus.Counter
+	unsortedInserts      prometheus.Counter
+	rowGroupsConsidered  prometheus.Counter
+	rowGroupsPruned      *prometheus.CounterVec
+	blocksConsidered     prometheus.Counter
+	blocksPruned         prometheus.Counter
+	partMerges           prometheus.Counter
+	partConcats          prometheus.Counter
+	scansShared          prometheus.Counter
+	insertDuration       prometheus.Histogram
+	scanDuration         prometheus.Histogram
 
 	indexMetrics *index.LSMMetrics
 }
 
-func schemaFromTableConfig(tableConfig *tablepb.TableConfig) (*dynparquet.Schema, error) {
-	switch schema := tableConfig.Schema.(type) {
-	case *tablepb.TableConfig_DeprecatedSchema:
-		return dynparquet.SchemaFromDefinition(schema.DeprecatedSchema)
-	case *tablepb.TableConfig_SchemaV2:
-		return dynparquet.SchemaFromDefinition(schema.SchemaV2)
-	default:
-		// No schema defined for table; read/only table
-		return nil, nil
+// observeWithTraceExemplar observes the value, with the ID of the sampled
+// trace of the context as exemplar, if any, so that e.g. a slow query can be
+// looked up from the latency of scans.
+func observeWithTraceExemplar(ctx context.Context, h prometheus.Histogram, v float64) {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsSampled() {
+		if o, ok := h.(prometheus.ExemplarObserver); ok {
+			o.ObserveWithExemplar(v, prometheus.Labels{"trace_id": sc.TraceID().String()})
+			return
+		}
 	}
+	h.Observe(v)
 }
 
 func newTable(
@@ -305,24 +473,27 @@ func newTable(
 	}
 
 	reg = prometheus.WrapRegistererWith(prometheus.Labels{"table": name}, reg)
+	logger = log.WithPrefix(logger, "table", name)
+	burst, interval := db.columnStore.logSamplingBurst, db.columnStore.logSamplingInterval
 
 	if tableConfig == nil {
 		tableConfig = defaultTableConfig()
 	}
 
-	s, err := schemaFromTableConfig(tableConfig)
+	shared, err := db.columnStore.schemas.acquire(tableConfig)
 	if err != nil {
 		return nil, err
 	}
 
 	t := &Table{
-		db:     db,
-		name:   name,
-		logger: logger,
-		tracer: tracer,
-		mtx:    &sync.RWMutex{},
-		wal:    wal,
-		schema: s,
+		db:           db,
+		name:         name,
+		logger:       logger,
+		tracer:       tracer,
+		insertLogger: sampledLogger(logger, burst, interval),
+		scanLogger:   sampledLogger(logger, burst, interval),
+		mtx:          &sync.RWMutex{},
+		wal:          wal,
 		metrics: &tableMetrics{
 			numParts: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
 				Name: "frostdb_table_num_parts",
@@ -357,10 +528,72 @@ func newTable(
 				Name: "frostdb_table_last_completed_block_tx",
 				Help: "Last completed block transaction.",
 			}),
+			blocksVerified: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+				Name: "frostdb_table_blocks_verified_total",
+				Help: "Number of persisted blocks whose integrity was verified, by result.",
+			}, []string{"result"}),
+			backpressure: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+				Name: "frostdb_table_inserts_backpressured_total",
+				Help: "Number of inserts subject to backpressure, by whether they were rejected or blocked.",
+			}, []string{"action"}),
+			partSplits: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+				Name: "frostdb_table_part_splits_total",
+				Help: "Number of compacted parts that were split because they exceeded the max part rows or bytes.",
+			}),
+			insertSplits: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+				Name: "frostdb_table_insert_splits_total",
+				Help: "Number of inserts split into several parts because they exceeded the max part rows or bytes.",
+			}),
+			unsortedInserts: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+				Name: "frostdb_table_unsorted_inserts_total",
+				Help: "Number of inserts whose rows were not sorted by the sorting columns, counted if the insert order is verified or inserts are sorted.",
+			}),
+			partMerges: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+				Name: "frostdb_table_part_merges_total",
+				Help: "Number of times adjacent compacted parts were merged because they were below the min part rows or bytes.",
+			}),
+			partConcats: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+				Name: "frostdb_table_part_concats_total",
+				Help: "Number of times compaction concatenated the row groups of non-overlapping parts instead of merging them.",
+			}),
+			rowGroupsConsidered: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+				Name: "frostdb_table_row_groups_considered_total",
+				Help: "Number of row groups a scan filter was evaluated on.",
+			}),
+			rowGroupsPruned: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+				Name: "frostdb_table_row_groups_pruned_total",
+				Help: "Number of row groups skipped by scan filters, by whether statistics or bloom filters ruled them out.",
+			}, []string{"reason"}),
+			blocksConsidered: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+				Name: "frostdb_table_blocks_considered_total",
+				Help: "Number of persisted blocks considered by scans.",
+			}),
+			scansShared: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+				Name: "frostdb_table_scans_shared_total",
+				Help: "Number of scans served by the shared scan of another query.",
+			}),
+			blocksPruned: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+				Name: "frostdb_table_blocks_pruned_total",
+				Help: "Number of persisted blocks skipped by scans because of the time range they cover.",
+			}),
+			insertDuration: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+				Name:                        "frostdb_table_insert_duration_seconds",
+				Help:                        "Duration of inserts into the table, with the trace IDs of sampled inserts as exemplars.",
+				Buckets:                     prometheus.DefBuckets,
+				NativeHistogramBucketFactor: 1.1,
+			}),
+			scanDuration: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+				Name:                        "frostdb_table_scan_duration_seconds",
+				Help:                        "Duration of scans of the table by queries, with the trace IDs of sampled queries as exemplars.",
+				Buckets:                     prometheus.DefBuckets,
+				NativeHistogramBucketFactor: 1.1,
+			}),
 			indexMetrics: index.NewLSMMetrics(reg),
 		},
 	}
 
+	t.useSchema(shared)
+
 	// Store the table config
 	t.config.Store(tableConfig)
 
@@ -370,6 +603,18 @@ func newTable(
 	}
 
 	t.pendingBlocks = make(map[*TableBlock]struct{})
+	t.pendingDropped = sync.NewCond(t.mtx)
+	if len(db.sources) > 0 {
+		// Blocks persisted before the table was opened carry no transaction.
+		t.snapshotHorizon.Store(db.HighWatermark())
+	}
+
+	if db.columnStore.sortingAdvisor {
+		t.usage = newPredicateUsage()
+	}
+	if db.columnStore.scanSharing {
+		t.scans = newSharedScans(db.columnStore.scanSharingWindow, db.columnStore.clock, t.metrics.scansShared)
+	}
 
 	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
 		Name: "frostdb_table_active_block_size",
@@ -415,22 +660,53 @@ func (t *Table) newTableBlock(prevTx, tx uint64, id ulid.ULID) error {
 func (t *Table) dropPendingBlock(block *TableBlock) {
 	t.mtx.Lock()
 	defer t.mtx.Unlock()
+	// Scans skip the persisted blocks that are not older than the oldest block
+	// in memory, so blocks are dropped in the order they were rotated even if
+	// they were persisted out of order.
+	for t.hasOlderPendingBlock(block) {
+		t.pendingDropped.Wait()
+	}
 	delete(t.pendingBlocks, block)
+	defer t.pendingDropped.Broadcast()
 
 	// Wait for outstanding readers/writers to finish with the block before releasing underlying resources.
 	block.pendingReadersWg.Wait()
 	block.pendingWritersWg.Wait()
 
-	// TODO thor call Release on all records in the block...
+	block.index.Release()
+}
+
+// hasOlderPendingBlock returns true if a block older than the given one is
+// pending. The table lock must be held.
+func (t *Table) hasOlderPendingBlock(block *TableBlock) bool {
+	for b := range t.pendingBlocks {
+		if b.ulid.Time() < block.ulid.Time() {
+			return true
+		}
+	}
+	return false
 }
 
 func (t *Table) writeBlock(block *TableBlock, skipPersist, snapshotDB bool) {
-	level.Debug(t.logger).Log("msg", "syncing block")
+	level.Debug(block.logger).Log("msg", "syncing block")
 	block.pendingWritersWg.Wait()
+	block.index.FlushAll(t.db.HighWatermark())
 
 	// from now on, the block will no longer be modified, we can persist it to disk
 
-	level.Debug(t.logger).Log("msg", "done syncing block")
+	level.Debug(block.logger).Log("msg", "done syncing block")
+	evicted := PartEvent{Type: PartEvicted, Block: block.ulid, Size: block.Size()}
+	var maxTx uint64
+	block.index.Iterate(func(node *index.Node) bool {
+		if p := node.Part(); p != nil {
+			evicted.Parts++
+			evicted.Rows += p.NumRows()
+			maxTx = max(maxTx, p.TX())
+		}
+		return true
+	})
+	// The persisted block is read by every transaction once it is dropped.
+	t.advanceSnapshotHorizon(maxTx)
 
 	// Persist the block
 	var err error
@@ -438,9 +714,9 @@ func (t *Table) writeBlock(block *TableBlock, skipPersist, snapshotDB bool) {
 		err = block.Persist()
 	}
 	t.dropPendingBlock(block)
+	t.emitPartEvent(evicted)
 	if err != nil {
-		level.Error(t.logger).Log("msg", "failed to persist block")
-		level.Error(t.logger).Log("msg", err.Error())
+		level.Error(block.logger).Log("msg", "failed to persist block", "err", err)
 		return
 	}
 
@@ -531,19 +807,15 @@ func (t *Table) RotateBlock(_ context.Context, block *TableBlock, skipPersist bo
 		return nil
 	}
 
-	level.Debug(t.logger).Log("msg", "rotating block", "blockSize", block.Size(), "skipPersist", skipPersist)
+	level.Debug(block.logger).Log("msg", "rotating block", "blockSize", block.Size(), "skipPersist", skipPersist)
 	defer func() {
-		level.Debug(t.logger).Log("msg", "done rotating block")
+		level.Debug(block.logger).Log("msg", "done rotating block")
 	}()
 
 	tx, _, commit := t.db.begin()
 	defer commit()
 
-	id := generateULID()
-	for id.Time() == block.ulid.Time() { // Ensure the new block has a different timestamp.
-		runtime.Gosched()
-		id = generateULID()
-	}
+	id := t.db.columnStore.nextBlockULID(block.ulid)
 	if err := t.newTableBlock(t.active.minTx, tx, id); err != nil {
 		return err
 	}
@@ -554,7 +826,12 @@ func (t *Table) RotateBlock(_ context.Context, block *TableBlock, skipPersist bo
 	// We don't check t.db.columnStore.manualBlockRotation here because this is
 	// the entry point for users to trigger a manual block rotation and they
 	// will specify through skipPersist if they want the block to be persisted.
-	go t.writeBlock(block, skipPersist, true)
+	t.blockWrites.Add(1)
+	go t.doWithProfileLabels(context.Background(), func(context.Context) error {
+		defer t.blockWrites.Done()
+		t.writeBlock(block, skipPersist, true)
+		return nil
+	})
 
 	return nil
 }
@@ -590,18 +867,64 @@ func (t *Table) EnsureCompaction() error {
 }
 
 func (t *Table) InsertRecord(ctx context.Context, record arrow.Record) (uint64, error) {
-	block, finish, err := t.appender(ctx)
+	start := time.Now()
+	defer func() {
+		observeWithTraceExemplar(ctx, t.metrics.insertDuration, time.Since(start).Seconds())
+	}()
+	if err := t.admit(ctx, accounting.ActiveParts); err != nil {
+		return 0, err
+	}
+	if err := t.applyBackpressure(ctx); err != nil {
+		return 0, err
+	}
+	record, err := t.orderInsert(record)
 	if err != nil {
-		return 0, fmt.Errorf("get appender: %w", err)
+		return 0, err
 	}
-	defer finish()
+	defer record.Release()
 
-	tx, _, commit := t.db.begin()
-	defer commit()
+	t.db.writes.RLock()
+	defer t.db.writes.RUnlock()
+
+	var (
+		block  *TableBlock
+		finish func()
+		tx     uint64
+		commit func()
+	)
+	for {
+		var err error
+		block, err = t.appender(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("get appender: %w", err)
+		}
+		tx, finish, commit, err = t.beginWrite(block)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errBlockRotated) {
+			return 0, err
+		}
+		// The block was rotated since it was returned, retry with the next
+		// block.
+	}
+	defer finish()
+	defer func() {
+		commit()
+		// The flush is requested once the write is committed, since only
+		// the records of completed transactions are flushed.
+		if size := t.db.columnStore.writeBufferSize; size > 0 && block.index.StagedSize() >= size {
+			t.db.requestWriteFlush()
+		}
+	}()
 
 	if err := t.wal.LogRecord(tx, t.name, record); err != nil {
 		return tx, fmt.Errorf("append to log: %w", err)
 	}
+	// The change is published once it is logged, so that watchers receive
+	// the same changes as when they are replayed from the WAL, which is also
+	// what recovery inserts.
+	t.db.changes.publish(tx, t.name, record)
 
 	if err := block.InsertRecord(ctx, tx, record); err != nil {
 		return tx, fmt.Errorf("insert buffer into block: %w", err)
@@ -610,13 +933,40 @@ func (t *Table) InsertRecord(ctx context.Context, record arrow.Record) (uint64,
 	return tx, nil
 }
 
-func (t *Table) appender(ctx context.Context) (*TableBlock, func(), error) {
+// errBlockRotated is returned by beginWrite if the block is no longer active.
+var errBlockRotated = errors.New("block rotated")
+
+// beginWrite joins the pending writers of the given block and begins the
+// transaction of a write into it, unless the block is no longer active. The
+// transaction begins under the lock of block rotations, so that the
+// transactions of the writes into a block are between the transactions
+// creating the block and the next one, which replaying the WAL relies on to
+// insert the writes into the right blocks. Since the writer joins under the
+// same lock, waiting for the pending writers of the active block with the lock
+// held doesn't wait for a writer that waits for the lock.
+func (t *Table) beginWrite(block *TableBlock) (uint64, func(), func(), error) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	if t.closing {
+		return 0, nil, nil, ErrTableClosing
+	}
+	if t.active != block {
+		return 0, nil, nil, errBlockRotated
+	}
+	block.pendingWritersWg.Add(1)
+	tx, _, commit := t.db.begin()
+	return tx, block.pendingWritersWg.Done, commit, nil
+}
+
+// appender returns the active block to write into, after triggering a
+// snapshot or rotating the block if they are due.
+func (t *Table) appender(ctx context.Context) (*TableBlock, error) {
 	for {
 		// Using active write block is important because it ensures that we don't
 		// miss pending writers when synchronizing the block.
 		block, finish, err := t.ActiveWriteBlock()
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		uncompressedInsertsSize := block.uncompressedInsertsSize.Load()
@@ -647,22 +997,55 @@ func (t *Table) appender(ctx context.Context) (*TableBlock, func(), error) {
 			})
 		}
 		blockSize := block.Size()
+		// The writer joins the block again once it begins its transaction.
+		finish()
 		if blockSize < t.db.columnStore.activeMemorySize || t.db.columnStore.manualBlockRotation {
-			return block, finish, nil
+			return block, nil
 		}
 
-		// We need to rotate the block and the writer won't actually be used.
-		finish()
-
 		err = t.RotateBlock(ctx, block, false)
 		if err != nil {
-			return nil, nil, fmt.Errorf("rotate block: %w", err)
+			return nil, fmt.Errorf("rotate block: %w", err)
 		}
 	}
 }
 
+// SnapshotHorizon returns the oldest transaction the table can still be read
+// as of. Compaction, rewrites and persistence drop the transactions of the
+// rows they write, so reads as of older transactions would see rows written
+// after them.
+func (t *Table) SnapshotHorizon() uint64 {
+	return t.snapshotHorizon.Load()
+}
+
+// advanceSnapshotHorizon advances the snapshot horizon to the given tx. It must
+// be called before the rows of the tx are made visible to every transaction,
+// so that scans checking the horizon after reading them fail.
+func (t *Table) advanceSnapshotHorizon(tx uint64) {
+	for {
+		horizon := t.snapshotHorizon.Load()
+		if tx <= horizon || t.snapshotHorizon.CompareAndSwap(horizon, tx) {
+			return
+		}
+	}
+}
+
+// advanceSnapshotHorizonOf advances the snapshot horizon to the highest
+// transaction of the given parts.
+func (t *Table) advanceSnapshotHorizonOf(compact []parts.Part) {
+	var tx uint64
+	for _, p := range compact {
+		tx = max(tx, p.TX())
+	}
+	t.advanceSnapshotHorizon(tx)
+}
+
 func (t *Table) View(ctx context.Context, fn func(ctx context.Context, tx uint64) error) error {
 	ctx, span := t.tracer.Start(ctx, "Table/View")
+	if err := t.db.waitForMinTx(ctx); err != nil {
+		span.End()
+		return err
+	}
 	tx := t.db.beginRead()
 	span.SetAttributes(attribute.Int64("tx", int64(tx))) // Attributes don't support uint64...
 	defer span.End()
@@ -690,6 +1073,53 @@ func (t *Table) Iterator(
 	if len(callbacks) == 0 {
 		return errors.New("no callbacks provided")
 	}
+	if err := t.admit(ctx, accounting.Query); err != nil {
+		return err
+	}
+	t.recordPredicates(iterOpts.Filter)
+	start := time.Now()
+	defer func() {
+		observeWithTraceExemplar(ctx, t.metrics.scanDuration, time.Since(start).Seconds())
+	}()
+
+	return t.doWithProfileLabels(ctx, func(ctx context.Context) error {
+		if key, ok := sharedScanKey(tx, iterOpts); ok && t.scans != nil && t.db.columnStore.scanHook == nil {
+			return t.scans.iterate(ctx, key, iterOpts.Filter, callbacks, func(ctx context.Context, filter logicalplan.Expr, callbacks []logicalplan.Callback) error {
+				opts := *iterOpts
+				opts.Filter = filter
+				return t.iterator(ctx, tx, pool, callbacks, &opts)
+			})
+		}
+		return t.iterator(ctx, tx, pool, callbacks, iterOpts)
+	})
+}
+
+// profileLabels returns the pprof labels, as key/value pairs, of the
+// goroutines working on the table.
+func (t *Table) profileLabels() []string {
+	return []string{"db", t.db.name, "table", t.name}
+}
+
+// doWithProfileLabels calls f with the pprof labels of the table, which the
+// goroutines started by f inherit, so that CPU profiles attribute the time
+// spent to the database and table.
+func (t *Table) doWithProfileLabels(ctx context.Context, f func(context.Context) error) error {
+	var err error
+	pprof.Do(ctx, pprof.Labels(t.profileLabels()...), func(ctx context.Context) {
+		err = f(ctx)
+	})
+	return err
+}
+
+// iterator iterates over all granules in the table visible at the given
+// transaction, passing them to the given callbacks.
+func (t *Table) iterator(
+	ctx context.Context,
+	tx uint64,
+	pool memory.Allocator,
+	callbacks []logicalplan.Callback,
+	iterOpts *logicalplan.IterOptions,
+) error {
 	rowGroups := make(chan any, len(callbacks)*4) // buffer up to 4 row groups per callback
 
 	// Previously we sorted all row groups into a single row group here,
@@ -702,6 +1132,30 @@ func (t *Table) Iterator(
 	// buffered results are flushed to the next operator.
 	const bufferSize = 1024
 
+	if iterOpts.PreAggregation != nil && !iterOpts.UnifySchema {
+		// Persisted blocks may be read from their pre-aggregates, so all
+		// records are passed on with partial aggregation results.
+		ctx = withPreAggregation(ctx, &preAggregationRequest{
+			schema:      t.schema,
+			aggregation: iterOpts.PreAggregation,
+			filter:      iterOpts.Filter,
+		})
+		callbacks = preAggregationCallbacks(pool, iterOpts.PreAggregation, callbacks)
+	}
+
+	var collected []any
+	if iterOpts.UnifySchema {
+		var (
+			schema *arrow.Schema
+			err    error
+		)
+		collected, schema, err = t.collectUnifiedRowGroups(ctx, tx, iterOpts)
+		if err != nil {
+			return err
+		}
+		callbacks = unifiedCallbacks(pool, schema, callbacks)
+	}
+
 	errg, ctx := errgroup.WithContext(ctx)
 	for _, callback := range callbacks {
 		callback := callback
@@ -736,7 +1190,26 @@ func (t *Table) Iterator(
 							return err
 						}
 					case dynparquet.DynamicRowGroup:
-						if err := converter.Convert(ctx, t); err != nil {
+						r, err := expr.MinMaxRecord(ctx, pool, t, *iterOpts)
+						if err != nil {
+							releaseRowGroup(t)
+							return err
+						}
+						if r != nil {
+							if stats := expr.PruningStatsFromContext(ctx); stats != nil {
+								stats.RowGroupsReadFromStatistics.Add(1)
+							}
+							releaseRowGroup(t)
+							err := callback(ctx, r)
+							r.Release()
+							if err != nil {
+								return err
+							}
+							continue
+						}
+						err = converter.Convert(ctx, t)
+						releaseRowGroup(t)
+						if err != nil {
 							return fmt.Errorf("failed to convert row group to arrow record: %v", err)
 						}
 						// This RowGroup had no relevant data. Ignore it.
@@ -763,6 +1236,18 @@ func (t *Table) Iterator(
 	}
 
 	errg.Go(func() error {
+		if iterOpts.UnifySchema {
+			defer close(rowGroups)
+			for i, rg := range collected {
+				select {
+				case rowGroups <- rg:
+				case <-ctx.Done():
+					releaseRowGroups(collected[i:])
+					return ctx.Err()
+				}
+			}
+			return nil
+		}
 		if err := t.collectRowGroups(ctx, tx, iterOpts.Filter, iterOpts.InMemoryOnly, rowGroups); err != nil {
 			return err
 		}
@@ -770,7 +1255,102 @@ func (t *Table) Iterator(
 		return nil
 	})
 
-	return errg.Wait()
+	err := errg.Wait()
+	drainRowGroups(rowGroups)
+	return err
+}
+
+// collectUnifiedRowGroups collects all the row groups of a scan and returns
+// them with the union of the schemas of the records they are converted to.
+func (t *Table) collectUnifiedRowGroups(
+	ctx context.Context,
+	tx uint64,
+	iterOpts *logicalplan.IterOptions,
+) ([]any, *arrow.Schema, error) {
+	var rowGroups []any
+	ch := make(chan any, 4)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for rg := range ch {
+			rowGroups = append(rowGroups, rg)
+		}
+	}()
+	err := t.collectRowGroups(ctx, tx, iterOpts.Filter, iterOpts.InMemoryOnly, ch)
+	close(ch)
+	<-done
+	if err != nil {
+		releaseRowGroups(rowGroups)
+		return nil, nil, err
+	}
+
+	schemas := make([]*arrow.Schema, 0, len(rowGroups))
+	for _, rg := range rowGroups {
+		switch rg := rg.(type) {
+		case arrow.Record:
+			r := pqarrow.Project(rg, iterOpts.PhysicalProjection)
+			schemas = append(schemas, r.Schema())
+			r.Release()
+		case dynparquet.DynamicRowGroup:
+			schema, err := pqarrow.ParquetRowGroupToArrowSchema(ctx, rg, *iterOpts)
+			if err != nil {
+				releaseRowGroups(rowGroups)
+				return nil, nil, err
+			}
+			schemas = append(schemas, schema)
+		}
+	}
+	return rowGroups, pqarrow.MergeArrowSchemas(schemas), nil
+}
+
+// unifiedCallbacks wraps the given callbacks to be called with records of the
+// given schema.
+func unifiedCallbacks(pool memory.Allocator, schema *arrow.Schema, callbacks []logicalplan.Callback) []logicalplan.Callback {
+	unified := make([]logicalplan.Callback, 0, len(callbacks))
+	for _, callback := range callbacks {
+		callback := callback
+		unified = append(unified, func(ctx context.Context, r arrow.Record) error {
+			u, err := pqarrow.Unify(pool, r, schema)
+			if err != nil {
+				return err
+			}
+			defer u.Release()
+			return callback(ctx, u)
+		})
+	}
+	return unified
+}
+
+// drainRowGroups releases the row groups left in the channel once its
+// consumers returned, e.g. because of an error.
+func drainRowGroups(rowGroups chan any) {
+	for {
+		select {
+		case rg, ok := <-rowGroups:
+			if !ok {
+				return
+			}
+			releaseRowGroup(rg)
+		default:
+			return
+		}
+	}
+}
+
+// releaseRowGroups releases the given records and row groups.
+func releaseRowGroups(rowGroups []any) {
+	for _, rg := range rowGroups {
+		releaseRowGroup(rg)
+	}
+}
+
+// releaseRowGroup releases a record, or the reference to its part held by a
+// row group of an index scan. Row groups read from other sources hold no
+// references.
+func releaseRowGroup(rg any) {
+	if r, ok := rg.(interface{ Release() }); ok {
+		r.Release()
+	}
 }
 
 // SchemaIterator iterates in order over all granules in the table and returns
@@ -795,6 +1375,9 @@ func (t *Table) SchemaIterator(
 	if len(callbacks) == 0 {
 		return errors.New("no callbacks provided")
 	}
+	if err := t.admit(ctx, accounting.Query); err != nil {
+		return err
+	}
 
 	rowGroups := make(chan any, len(callbacks)*4) // buffer up to 4 row groups per callback
 
@@ -842,6 +1425,7 @@ func (t *Table) SchemaIterator(
 							fieldNames = append(fieldNames, f.Name())
 						}
 
+						releaseRowGroup(t)
 						b.Field(0).(*array.StringBuilder).AppendValues(fieldNames, nil)
 
 						record := b.NewRecord()
@@ -866,32 +1450,179 @@ func (t *Table) SchemaIterator(
 		return nil
 	})
 
-	return errg.Wait()
+	err := errg.Wait()
+	drainRowGroups(rowGroups)
+	return err
 }
 
-func generateULID() ulid.ULID {
-	t := time.Now()
+// RowCount returns the number of rows of the table visible at the given
+// transaction. The rows are counted from the metadata of the parts of the
+// table, without reading the rows.
+func (t *Table) RowCount(
+	ctx context.Context,
+	tx uint64,
+	options ...logicalplan.Option,
+) (int64, error) {
+	iterOpts := &logicalplan.IterOptions{}
+	for _, opt := range options {
+		opt(iterOpts)
+	}
+	ctx, span := t.tracer.Start(ctx, "Table/RowCount")
+	defer span.End()
+
+	if err := t.admit(ctx, accounting.Query); err != nil {
+		return 0, err
+	}
+
+	rowGroups := make(chan any, 16)
+	errg, ctx := errgroup.WithContext(ctx)
+	errg.Go(func() error {
+		defer close(rowGroups)
+		return t.collectRowGroups(ctx, tx, nil, iterOpts.InMemoryOnly, rowGroups)
+	})
+
+	var rows int64
+	for rg := range rowGroups {
+		switch rg := rg.(type) {
+		case arrow.Record:
+			rows += rg.NumRows()
+			rg.Release()
+		case dynparquet.DynamicRowGroup:
+			rows += rg.NumRows()
+			releaseRowGroup(rg)
+		}
+	}
+	if err := errg.Wait(); err != nil {
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int64("rows", rows))
+	return rows, nil
+}
+
+// EstimateScan estimates what a scan of the table visible at the given
+// transaction reads. The row groups are pruned by the filter of the options,
+// like in Iterator, but not read.
+func (t *Table) EstimateScan(
+	ctx context.Context,
+	tx uint64,
+	options ...logicalplan.Option,
+) (logicalplan.ScanEstimate, error) {
+	iterOpts := &logicalplan.IterOptions{}
+	for _, opt := range options {
+		opt(iterOpts)
+	}
+	ctx, span := t.tracer.Start(ctx, "Table/EstimateScan")
+	defer span.End()
+
+	stats := &expr.PruningStats{}
+	ctx = expr.WithPruningStats(ctx, stats)
+	rowGroups := make(chan any, 16)
+	errg, ctx := errgroup.WithContext(ctx)
+	errg.Go(func() error {
+		defer close(rowGroups)
+		return t.collectRowGroups(ctx, tx, iterOpts.Filter, iterOpts.InMemoryOnly, rowGroups)
+	})
+
+	var estimate logicalplan.ScanEstimate
+	for rg := range rowGroups {
+		estimate.RowGroups++
+		switch rg := rg.(type) {
+		case arrow.Record:
+			estimate.Rows += rg.NumRows()
+			estimate.Bytes += util.TotalRecordSize(rg)
+			rg.Release()
+		case dynparquet.DynamicRowGroup:
+			estimate.Rows += rg.NumRows()
+			estimate.Bytes += compressedSize(rg)
+			releaseRowGroup(rg)
+		}
+	}
+	if err := errg.Wait(); err != nil {
+		return logicalplan.ScanEstimate{}, err
+	}
+	estimate.BlocksSkipped = stats.BlocksSkipped.Load()
+	estimate.Blocks = stats.BlocksConsidered.Load() - estimate.BlocksSkipped
+	estimate.RowGroupsSkipped = stats.RowGroupsSkippedByStatistics.Load() + stats.RowGroupsSkippedByBloomFilter.Load()
+	span.SetAttributes(
+		attribute.Int64("rows", estimate.Rows),
+		attribute.Int64("bytes", estimate.Bytes),
+	)
+	return estimate, nil
+}
+
+// compressedSize returns the compressed size of the pages of the given row
+// group as recorded in the offset indexes of its column chunks. Column chunks
+// without an offset index are not counted.
+func compressedSize(rg parquet.RowGroup) int64 {
+	var size int64
+	for _, chunk := range rg.ColumnChunks() {
+		index, err := chunk.OffsetIndex()
+		if err != nil {
+			continue
+		}
+		for i := 0; i < index.NumPages(); i++ {
+			size += index.CompressedPageSize(i)
+		}
+	}
+	return size
+}
+
+func generateULID(t time.Time) ulid.ULID {
 	entropy := ulid.Monotonic(rand.New(rand.NewSource(t.UnixNano())), 0)
 	return ulid.MustNew(ulid.Timestamp(t), entropy)
 }
 
+// nextBlockULID returns the ID of the block following the given block. Its
+// timestamp is later than the timestamp of the given block, even if the clock
+// did not advance.
+func (s *ColumnStore) nextBlockULID(prev ulid.ULID) ulid.ULID {
+	now := s.clock.Now()
+	if ulid.Timestamp(now) <= prev.Time() {
+		now = ulid.Time(prev.Time() + 1)
+	}
+	return generateULID(now)
+}
+
 func newTableBlock(table *Table, prevTx, tx uint64, id ulid.ULID) (*TableBlock, error) {
 	tb := &TableBlock{
 		table:  table,
 		mtx:    &sync.RWMutex{},
 		ulid:   id,
-		logger: table.logger,
+		logger: log.WithPrefix(table.logger, "block", id),
 		tracer: table.tracer,
 		minTx:  tx,
 		prevTx: prevTx,
 	}
 
+	lsmOptions := []index.LSMOption{
+		index.LSMWithMetrics(table.metrics.indexMetrics),
+		index.LSMWithProfileLabels(table.profileLabels()...),
+		index.LSMWithLogger(tb.logger),
+		index.LSMWithUndersizedParts(table.undersizedPart, table.metrics.partMerges.Inc),
+	}
+	if len(table.db.columnStore.partEventCallbacks) > 0 {
+		lsmOptions = append(lsmOptions, index.LSMWithCompactionCallback(tb.partCompactionCallback()))
+	}
+	if table.db.columnStore.mergePolicy != nil {
+		lsmOptions = append(lsmOptions, index.LSMWithMergePolicy(table.db.columnStore.mergePolicy))
+	}
+	if table.db.columnStore.writeBufferInterval > 0 {
+		lsmOptions = append(lsmOptions, index.LSMWithWriteBuffer(table.parquetCompaction))
+	}
+	if table.db.columnStore.activePartCompression {
+		schema, err := table.schema.LightweightEncoded()
+		if err != nil {
+			return nil, fmt.Errorf("active part compression: %w", err)
+		}
+		lsmOptions = append(lsmOptions, index.LSMWithCompression(schema))
+	}
+
 	var err error
 	tb.index, err = index.NewLSM(
 		table.name,
 		table.schema,
 		table.configureLSMLevels(table.db.columnStore.indexConfig),
-		index.LSMWithMetrics(table.metrics.indexMetrics),
+		lsmOptions...,
 	)
 	if err != nil {
 		return nil, err
@@ -902,6 +1633,7 @@ func newTableBlock(table *Table, prevTx, tx uint64, id ulid.ULID) (*TableBlock,
 
 // EnsureCompaction forces a TableBlock compaction.
 func (t *TableBlock) EnsureCompaction() error {
+	t.index.FlushAll(t.table.db.HighWatermark())
 	return t.index.EnsureCompaction()
 }
 
@@ -921,9 +1653,36 @@ func (t *TableBlock) InsertRecord(_ context.Context, tx uint64, record arrow.Rec
 	record = dynparquet.PrehashColumns(t.table.schema, record)
 	defer record.Release()
 
-	t.index.Add(tx, record)
-	t.table.metrics.numParts.Inc()
+	chunks := t.table.splitRecord(record, recordSize)
+	for _, chunk := range chunks {
+		t.index.Stage(tx, chunk)
+	}
+	if len(chunks) > 1 {
+		for _, chunk := range chunks {
+			chunk.Release()
+		}
+	}
+	if log := t.rewrite.Load(); log != nil {
+		log.add(tx, record)
+	}
+	t.table.metrics.numParts.Add(float64(len(chunks)))
 	t.uncompressedInsertsSize.Add(recordSize)
+	level.Debug(t.table.insertLogger).Log(
+		"msg", "inserted record",
+		"block", t.ulid,
+		"tx", tx,
+		"rows", record.NumRows(),
+		"size", recordSize,
+		"parts", len(chunks),
+	)
+	t.table.emitPartEvent(PartEvent{
+		Type:  PartCreated,
+		Block: t.ulid,
+		Tx:    tx,
+		Parts: len(chunks),
+		Rows:  record.NumRows(),
+		Size:  recordSize,
+	})
 	return nil
 }
 
@@ -963,18 +1722,25 @@ type parquetRowWriter struct {
 
 type parquetRowWriterOption func(p *parquetRowWriter)
 
+// withMaxRows limits the number of rows written by the writer.
+func withMaxRows(numRows int) parquetRowWriterOption {
+	return func(p *parquetRowWriter) {
+		p.maxNumRows = numRows
+	}
+}
+
 // rowWriter returns a new Parquet row writer with the given dynamic columns.
 // TODO(asubiotto): Can we delete this parquetRowWriter?
-func (t *TableBlock) rowWriter(w ParquetWriter, options ...parquetRowWriterOption) (*parquetRowWriter, error) {
+func (t *Table) rowWriter(w ParquetWriter, options ...parquetRowWriterOption) (*parquetRowWriter, error) {
 	buffSize := 256
-	config := t.table.config.Load()
+	config := t.config.Load()
 	if config.RowGroupSize > 0 {
 		buffSize = int(config.RowGroupSize)
 	}
 
 	p := &parquetRowWriter{
 		w:            w,
-		schema:       t.table.schema,
+		schema:       t.schema,
 		rowsBuf:      make([]parquet.Row, buffSize),
 		rowGroupSize: int(config.RowGroupSize),
 	}
@@ -1064,6 +1830,24 @@ func (t *Table) collectRowGroups(
 	ctx, span := t.tracer.Start(ctx, "Table/collectRowGroups")
 	defer span.End()
 
+	stats := &expr.PruningStats{}
+	defer t.recordPruning(ctx, stats)
+	start := time.Now()
+	defer func() {
+		level.Debug(t.scanLogger).Log(
+			"msg", "collected row groups",
+			"tx", tx,
+			"duration", time.Since(start),
+			"row_groups_considered", stats.RowGroupsConsidered.Load(),
+			"row_groups_skipped", stats.RowGroupsSkippedByStatistics.Load()+stats.RowGroupsSkippedByBloomFilter.Load(),
+			"blocks_considered", stats.BlocksConsidered.Load(),
+			"blocks_skipped", stats.BlocksSkipped.Load(),
+		)
+	}()
+	ctx = expr.WithPruningStats(ctx, stats)
+	ctx = t.withScanHook(ctx)
+	hook := scanHookFromContext(ctx)
+
 	// pending blocks could be uploaded to the bucket while we iterate on them.
 	// to avoid to iterate on them again while reading the block file
 	// we keep the last block timestamp to be read from the bucket and pass it to the IterateBucketBlocks() function
@@ -1075,11 +1859,22 @@ func (t *Table) collectRowGroups(
 		}
 	}()
 	for _, block := range memoryBlocks {
+		ctx := ctx
+		if hook != nil {
+			if !hook.scanBlock(ctx, block.ulid, false) {
+				continue
+			}
+			scanBlock := ScanBlock{Database: hook.database, Table: hook.table, ID: block.ulid}
+			ctx = index.WithPartFilter(ctx, func(p parts.Part) bool {
+				return hook.hook.ScanPart(ctx, scanBlock, p)
+			})
+		}
 		if err := block.index.Scan(ctx, "", t.schema, filterExpr, tx, func(ctx context.Context, v any) error {
 			select {
 			case rowGroups <- v:
 				return nil
 			case <-ctx.Done():
+				releaseRowGroup(v)
 				return ctx.Err()
 			}
 		}); err != nil {
@@ -1094,11 +1889,12 @@ func (t *Table) collectRowGroups(
 	// Collect from all other data sources.
 	for _, source := range t.db.sources {
 		span.AddEvent(fmt.Sprintf("source/%s", source.String()))
-		if err := source.Scan(ctx, filepath.Join(t.db.name, t.name), t.schema, filterExpr, lastBlockTimestamp, func(ctx context.Context, v any) error {
+		if err := source.Scan(ctx, filepath.Join(t.db.prefix(), t.name), t.schema, filterExpr, lastBlockTimestamp, func(ctx context.Context, v any) error {
 			select {
 			case rowGroups <- v:
 				return nil
 			case <-ctx.Done():
+				releaseRowGroup(v)
 				return ctx.Err()
 			}
 		}); err != nil {
@@ -1109,6 +1905,19 @@ func (t *Table) collectRowGroups(
 	return nil
 }
 
+// recordPruning adds the pruning stats of a scan to the table metrics and to
+// the stats of the query set on the given context, if any.
+func (t *Table) recordPruning(ctx context.Context, stats *expr.PruningStats) {
+	t.metrics.rowGroupsConsidered.Add(float64(stats.RowGroupsConsidered.Load()))
+	t.metrics.rowGroupsPruned.WithLabelValues("statistics").Add(float64(stats.RowGroupsSkippedByStatistics.Load()))
+	t.metrics.rowGroupsPruned.WithLabelValues("bloom_filter").Add(float64(stats.RowGroupsSkippedByBloomFilter.Load()))
+	t.metrics.blocksConsidered.Add(float64(stats.BlocksConsidered.Load()))
+	t.metrics.blocksPruned.Add(float64(stats.BlocksSkipped.Load()))
+	if queryStats := expr.PruningStatsFromContext(ctx); queryStats != nil {
+		queryStats.Add(stats)
+	}
+}
+
 // close notifies a table to stop accepting writes.
 func (t *Table) close() {
 	t.mtx.Lock()
@@ -1123,6 +1932,9 @@ func (t *Table) close() {
 			level.Error(t.logger).Log("msg", "table closer", "err", err)
 		}
 	}
+	// The schema is kept, blocks being persisted may still use it.
+	t.db.columnStore.schemas.release(t.sharedSchema)
+	t.sharedSchema = nil
 }
 
 type CompactionType int
@@ -1131,12 +1943,20 @@ const (
 	CompactionTypeUnknown CompactionType = iota
 	CompactionTypeParquet
 	CompactionTypeParquetDisk
+	// CompactionTypeParquetMmap compacts parts into a Parquet file that is
+	// mapped into memory outside of the Go heap. This keeps large compacted
+	// parts from inflating GC pause times.
+	CompactionTypeParquetMmap
 )
 
 type IndexConfig struct {
 	Level   int
 	MaxSize int64
 	Type    CompactionType
+	// HotReads is the number of part reads by queries after which the most
+	// read parts of the level are compacted even if it has not reached its
+	// max size. Disabled if 0.
+	HotReads int64
 }
 
 // configureLSMLevels configures the level configs for this table.
@@ -1145,8 +1965,9 @@ func (t *Table) configureLSMLevels(levels []*IndexConfig) []*index.LevelConfig {
 
 	for i, level := range levels {
 		cfg := &index.LevelConfig{
-			Level:   index.SentinelType(level.Level),
-			MaxSize: level.MaxSize,
+			Level:    index.SentinelType(level.Level),
+			MaxSize:  level.MaxSize,
+			HotReads: level.HotReads,
 		}
 		switch level.Type {
 		case CompactionTypeParquet:
@@ -1155,6 +1976,8 @@ func (t *Table) configureLSMLevels(levels []*IndexConfig) []*index.LevelConfig {
 			fileCompaction := t.parquetFileCompaction(i + 1)
 			t.closers = append(t.closers, fileCompaction) // Append to closers so that the underlying files are closed on table close.
 			cfg.Compact = fileCompaction.writeRecordsToParquetFile
+		case CompactionTypeParquetMmap:
+			cfg.Compact = t.parquetMmapCompaction
 		default:
 			if i != len(levels)-1 { // Compaction type should not be set for last level
 				panic(fmt.Sprintf("unknown compaction type: %v", level.Type))
@@ -1167,6 +1990,7 @@ func (t *Table) configureLSMLevels(levels []*IndexConfig) []*index.LevelConfig {
 }
 
 func (t *Table) parquetCompaction(compact []parts.Part, options ...parts.Option) ([]parts.Part, int64, int64, error) {
+	t.advanceSnapshotHorizonOf(compact)
 	var (
 		buf                                   *dynparquet.SerializedBuffer
 		preCompactionSize, postCompactionSize int64
@@ -1196,7 +2020,157 @@ func (t *Table) parquetCompaction(compact []parts.Part, options ...parts.Option)
 		postCompactionSize = buf.ParquetFile().Size()
 	}
 
-	return []parts.Part{parts.NewParquetPart(0, buf, options...)}, preCompactionSize, postCompactionSize, nil
+	bufs, _, err := t.splitPart(buf, heapPartWriter)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("split compacted part: %w", err)
+	}
+	compacted := make([]parts.Part, 0, len(bufs))
+	if len(bufs) > 1 {
+		postCompactionSize = 0
+		for _, buf := range bufs {
+			postCompactionSize += buf.ParquetFile().Size()
+		}
+	}
+	for _, buf := range bufs {
+		compacted = append(compacted, parts.NewParquetPart(0, buf, options...))
+	}
+	return compacted, preCompactionSize, postCompactionSize, nil
+}
+
+// splitRecord splits the given inserted record of the given size into slices
+// of at most the max part rows and roughly the max part bytes of the table
+// config, so that a large insert is added to the index as several parts of
+// the same transaction instead of a single giant part. The record is returned
+// as is if it is within the limits, otherwise the caller must release the
+// returned slices.
+func (t *Table) splitRecord(record arrow.Record, size int64) []arrow.Record {
+	config := t.config.Load()
+	numRows := record.NumRows()
+	rowsPerPart := numRows
+	if config.MaxPartRows > 0 && rowsPerPart > int64(config.MaxPartRows) {
+		rowsPerPart = int64(config.MaxPartRows)
+	}
+	if config.MaxPartBytes > 0 && size > int64(config.MaxPartBytes) {
+		pieces := (size + int64(config.MaxPartBytes) - 1) / int64(config.MaxPartBytes)
+		rowsPerPart = min(rowsPerPart, max((numRows+pieces-1)/pieces, 1))
+	}
+	if rowsPerPart >= numRows {
+		return []arrow.Record{record}
+	}
+	t.metrics.insertSplits.Inc()
+
+	chunks := make([]arrow.Record, 0, (numRows+rowsPerPart-1)/rowsPerPart)
+	for i := int64(0); i < numRows; i += rowsPerPart {
+		chunks = append(chunks, record.NewSlice(i, min(i+rowsPerPart, numRows)))
+	}
+	return chunks
+}
+
+// undersizedPart returns whether the given compacted part is below the min part
+// rows or bytes of the table config, in which case it is merged with adjacent
+// undersized parts of its level.
+func (t *Table) undersizedPart(p parts.Part) bool {
+	config := t.config.Load()
+	return (config.MinPartRows > 0 && p.NumRows() < int64(config.MinPartRows)) ||
+		(config.MinPartBytes > 0 && p.Size() < int64(config.MinPartBytes))
+}
+
+// partWriter writes a Parquet file with the given function and returns it as
+// a serialized buffer, along with a function releasing the resources backing
+// the buffer, if any.
+type partWriter func(write func(io.Writer) error) (*dynparquet.SerializedBuffer, func(), error)
+
+// heapPartWriter writes parts to memory on the Go heap.
+func heapPartWriter(write func(io.Writer) error) (*dynparquet.SerializedBuffer, func(), error) {
+	var b bytes.Buffer
+	if err := write(&b); err != nil {
+		return nil, nil, err
+	}
+	buf, err := dynparquet.ReaderFromBytes(b.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	return buf, nil, nil
+}
+
+// splitPart splits the given compacted buffer in halves at the median of the
+// sort key until every half is within the max part rows and bytes of the table
+// config. Since the buffer is sorted, the resulting parts cover disjoint
+// ranges of the sort key. The halves are written with newPart and returned
+// with their release functions; the buffer is returned as is if it is within
+// the limits.
+func (t *Table) splitPart(buf *dynparquet.SerializedBuffer, newPart partWriter) ([]*dynparquet.SerializedBuffer, []func(), error) {
+	config := t.config.Load()
+	numRows, size := buf.NumRows(), buf.ParquetFile().Size()
+	pieces := int64(1)
+	for numRows/pieces > 1 &&
+		((config.MaxPartRows > 0 && numRows/pieces > int64(config.MaxPartRows)) ||
+			(config.MaxPartBytes > 0 && size/pieces > int64(config.MaxPartBytes))) {
+		pieces *= 2
+	}
+	if pieces == 1 {
+		return []*dynparquet.SerializedBuffer{buf}, nil, nil
+	}
+	t.metrics.partSplits.Inc()
+
+	rows := buf.MultiDynamicRowGroup().Rows()
+	defer rows.Close()
+	rowsPerPart := int((numRows + pieces - 1) / pieces)
+	bufs := make([]*dynparquet.SerializedBuffer, 0, pieces)
+	releases := make([]func(), 0, pieces)
+	releaseAll := func() {
+		for _, release := range releases {
+			if release != nil {
+				release()
+			}
+		}
+	}
+	for written := int64(0); written < numRows; {
+		b, release, n, err := t.writePart(rows, buf.DynamicColumns(), rowsPerPart, newPart)
+		if err != nil {
+			releaseAll()
+			return nil, nil, err
+		}
+		if n == 0 {
+			break
+		}
+		written += int64(n)
+		bufs = append(bufs, b)
+		releases = append(releases, release)
+	}
+	return bufs, releases, nil
+}
+
+// writePart writes up to the given number of rows read from rows into a new
+// serialized buffer written with newPart.
+func (t *Table) writePart(rows parquet.RowReader, dynamicColumns map[string][]string, maxRows int, newPart partWriter) (*dynparquet.SerializedBuffer, func(), int, error) {
+	n := 0
+	buf, release, err := newPart(func(w io.Writer) error {
+		pw, err := t.schema.GetWriter(w, dynamicColumns, false)
+		if err != nil {
+			return err
+		}
+		defer t.schema.PutWriter(pw)
+		p, err := t.rowWriter(pw, withMaxRows(maxRows))
+		if err != nil {
+			return err
+		}
+		n, err = p.writeRows(rows)
+		if err != nil {
+			return err
+		}
+		return p.close()
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if n == 0 {
+		if release != nil {
+			release()
+		}
+		return nil, nil, 0, nil
+	}
+	return buf, release, n, nil
 }
 
 func (t *Table) externalParquetCompaction(writer io.Writer) func(compact []parts.Part) (parts.Part, int64, int64, error) {
@@ -1236,6 +2210,18 @@ func (t *Table) compactParts(w io.Writer, compact []parts.Part) (int64, error) {
 		}
 	}
 
+	sorted, err := t.sortedRecordForCompaction(compact)
+	if err != nil {
+		return 0, err
+	}
+	if sorted != nil {
+		defer sorted.Release()
+		if rowGroupSize := t.concurrentCompaction(); rowGroupSize > 0 {
+			return preCompactionSize, t.writeRecordConcurrently(w, sorted, rowGroupSize)
+		}
+		return preCompactionSize, t.writeRecordsToParquet(w, []arrow.Record{sorted}, false)
+	}
+
 	bufs, err := t.buffersForCompaction(w, compact)
 	if err != nil {
 		return 0, err
@@ -1249,13 +2235,18 @@ func (t *Table) compactParts(w io.Writer, compact []parts.Part) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	if rowGroupSize := t.concurrentCompaction(); rowGroupSize > 0 {
+		rows := merged.Rows()
+		defer rows.Close()
+		return preCompactionSize, t.writeRowGroupsConcurrently(w, t.dedupeRows(merged, rows), merged.DynamicColumns(), rowGroupSize)
+	}
 	err = func() error {
 		pw, err := t.schema.GetWriter(w, merged.DynamicColumns(), false)
 		if err != nil {
 			return err
 		}
 		defer t.schema.PutWriter(pw)
-		p, err := t.active.rowWriter(pw)
+		p, err := t.rowWriter(pw)
 		if err != nil {
 			return err
 		}
@@ -1264,15 +2255,7 @@ func (t *Table) compactParts(w io.Writer, compact []parts.Part) (int64, error) {
 		rows := merged.Rows()
 		defer rows.Close()
 
-		var rowReader parquet.RowReader = rows
-		if t.schema.UniquePrimaryIndex {
-			// Given all inputs are sorted, we can deduplicate the rows using
-			// DedupeRowReader, which deduplicates consecutive rows that are
-			// equal on the sorting columns.
-			rowReader = parquet.DedupeRowReader(rows, merged.Schema().Comparator(merged.SortingColumns()...))
-		}
-
-		if _, err := p.writeRows(rowReader); err != nil {
+		if _, err := p.writeRows(t.dedupeRows(merged, rows)); err != nil {
 			return err
 		}
 
@@ -1285,6 +2268,39 @@ func (t *Table) compactParts(w io.Writer, compact []parts.Part) (int64, error) {
 	return preCompactionSize, nil
 }
 
+// concatenableFiles returns the Parquet files of the given parts if they can
+// be concatenated, i.e. all the parts are Parquet and have the same schema, or
+// nil otherwise.
+func concatenableFiles(schema *dynparquet.Schema, inputParts []parts.Part) ([]*parquet.File, error) {
+	files := make([]*parquet.File, 0, len(inputParts))
+	for _, p := range inputParts {
+		if p.Record() != nil {
+			return nil, nil
+		}
+		buf, err := p.AsSerializedBuffer(schema)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) > 0 && buf.ParquetFile().Schema().String() != files[0].Schema().String() {
+			return nil, nil
+		}
+		files = append(files, buf.ParquetFile())
+	}
+	return files, nil
+}
+
+// dedupeRows returns a reader of the merged rows that deduplicates them if the
+// table has a unique primary index.
+func (t *Table) dedupeRows(merged dynparquet.DynamicRowGroup, rows parquet.RowReader) parquet.RowReader {
+	if !t.schema.UniquePrimaryIndex {
+		return rows
+	}
+	// Given all inputs are sorted, we can deduplicate the rows using
+	// DedupeRowReader, which deduplicates consecutive rows that are equal on
+	// the sorting columns.
+	return parquet.DedupeRowReader(rows, merged.Schema().Comparator(merged.SortingColumns()...))
+}
+
 // buffersForCompaction, given a slice of possibly overlapping parts, returns
 // the minimum slice of dynamic row groups to be merged together for compaction.
 // If nil, nil is returned, the resulting serialized buffer is written directly
@@ -1294,6 +2310,24 @@ func (t *Table) buffersForCompaction(w io.Writer, inputParts []parts.Part) ([]dy
 	if err != nil {
 		return nil, err
 	}
+	if len(overlappingParts) == 0 && len(nonOverlappingParts) > 1 && !t.schema.UniquePrimaryIndex {
+		// The parts are already sorted and don't overlap, so if they are all
+		// Parquet with the same schema, their row groups are concatenated as
+		// is rather than encoded again. Tables with a unique primary index
+		// are merged to deduplicate rows at the boundaries of parts.
+		files, err := concatenableFiles(t.schema, nonOverlappingParts)
+		if err != nil {
+			return nil, err
+		}
+		if files != nil {
+			if err := dynparquet.ConcatFiles(w, files); err != nil {
+				return nil, err
+			}
+			t.metrics.partConcats.Inc()
+			return nil, nil
+		}
+	}
+
 	result := make([]dynparquet.DynamicRowGroup, 0, len(inputParts))
 	for _, p := range overlappingParts {
 		buf, err := p.AsSerializedBuffer(t.schema)
@@ -1434,8 +2468,232 @@ func (t *Table) distinctRecordsForCompaction(compact []parts.Part) ([]arrow.Reco
 	return newRecords, nil
 }
 
+// sortedRecordForCompaction merges the records of the given parts, which are
+// possibly overlapping runs of rows, into a single record sorted by the
+// sorting columns of the table. This avoids serializing every part to Parquet
+// to merge them, the merged record being converted to Parquet only once. If at
+// least one non-arrow part is found, the records don't have the same schema,
+// or a sorting column can't be sorted in Arrow, nil, nil is returned in which
+// case the caller should fall back to merging Parquet buffers. On success, the
+// caller is responsible for releasing the returned record.
+func (t *Table) sortedRecordForCompaction(compact []parts.Part) (arrow.Record, error) {
+	records := make([]arrow.Record, 0, len(compact))
+	for _, p := range compact {
+		r := p.Record()
+		if r == nil || (len(records) > 0 && !r.Schema().Equal(records[0].Schema())) {
+			return nil, nil
+		}
+		records = append(records, r)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	schema := records[0].Schema()
+	cols := t.arrowSortingColumns(records[0])
+
+	mem := memory.NewGoAllocator()
+	merged := records[0]
+	if len(records) == 1 {
+		merged.Retain()
+	} else {
+		columns := make([]arrow.Array, 0, schema.NumFields())
+		defer func() {
+			for _, c := range columns {
+				c.Release()
+			}
+		}()
+		numRows := int64(0)
+		for _, r := range records {
+			numRows += r.NumRows()
+		}
+		for i := 0; i < schema.NumFields(); i++ {
+			arrs := make([]arrow.Array, 0, len(records))
+			for _, r := range records {
+				arrs = append(arrs, r.Column(i))
+			}
+			c, err := array.Concatenate(arrs, mem)
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, c)
+		}
+		merged = array.NewRecord(schema, columns, numRows)
+	}
+	defer merged.Release()
+
+	indices, err := arrowutils.SortRecordByColumns(mem, merged, cols)
+	if err != nil {
+		// Caller should fall back to merging Parquet buffers.
+		return nil, nil
+	}
+	defer indices.Release()
+	sorted, err := arrowutils.TakeRecord(mem, merged, indices)
+	if err != nil {
+		// Caller should fall back to merging Parquet buffers.
+		return nil, nil
+	}
+	return sorted, nil
+}
+
+// arrowSortingColumns returns the sorting columns of the table in the given
+// record. Sorting columns that are null in all rows are not in the record and
+// skipped.
+func (t *Table) arrowSortingColumns(r arrow.Record) []arrowutils.SortingColumn {
+	schema := r.Schema()
+	sortingCols := t.schema.ParquetSortingColumns(pqarrow.RecordDynamicCols(r))
+	cols := make([]arrowutils.SortingColumn, 0, len(sortingCols))
+	for _, col := range sortingCols {
+		indices := schema.FieldIndices(col.Path()[0])
+		if len(indices) == 0 {
+			// The column is null in all rows.
+			continue
+		}
+		cols = append(cols, arrowutils.SortingColumn{
+			Index:      indices[0],
+			Descending: col.Descending(),
+			NullsFirst: col.NullsFirst(),
+		})
+	}
+	return cols
+}
+
+// orderInsert verifies that the rows of the given inserted record are sorted
+// by the sorting columns of the table, or sorts them, as configured. Only the
+// leading sorting columns that can be sorted in Arrow are considered, rows
+// that are equal by them count as sorted. The caller must release the
+// returned record.
+func (t *Table) orderInsert(record arrow.Record) (arrow.Record, error) {
+	config := t.config.Load()
+	if !config.VerifyInsertOrder && !config.AutoSort {
+		record.Retain()
+		return record, nil
+	}
+
+	cols := t.arrowSortingColumns(record)
+	for i, col := range cols {
+		if !arrowutils.Sortable(record.Column(col.Index)) {
+			cols = cols[:i]
+			break
+		}
+	}
+	row, err := arrowutils.FirstUnsortedRow(record, cols)
+	if err != nil {
+		return nil, fmt.Errorf("verify insert order: %w", err)
+	}
+	if row < 0 {
+		record.Retain()
+		return record, nil
+	}
+	t.metrics.unsortedInserts.Inc()
+	if !config.AutoSort {
+		return nil, ErrUnsortedInsert{Row: row}
+	}
+
+	mem := memory.NewGoAllocator()
+	indices, err := arrowutils.SortRecordByColumns(mem, record, cols)
+	if err != nil {
+		return nil, fmt.Errorf("sort insert: %w", err)
+	}
+	defer indices.Release()
+	sorted, err := arrowutils.TakeRecord(mem, record, indices)
+	if err != nil {
+		return nil, fmt.Errorf("sort insert: %w", err)
+	}
+	return sorted, nil
+}
+
+// parquetMmapCompaction compacts the given parts into Parquet files in the
+// parts directory of the table that are mapped into memory, splitting them like
+// parquetCompaction does. Reads copy the data out of the mappings, which are
+// unmapped when the parts are released.
+func (t *Table) parquetMmapCompaction(compact []parts.Part, options ...parts.Option) ([]parts.Part, int64, int64, error) {
+	t.advanceSnapshotHorizonOf(compact)
+	var preCompactionSize int64
+	buf, release, err := t.mmapPartWriter(func(w io.Writer) error {
+		var err error
+		preCompactionSize, err = t.compactParts(w, compact)
+		return err
+	})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	bufs, releases, err := t.splitPart(buf, t.mmapPartWriter)
+	if err != nil {
+		release()
+		return nil, 0, 0, fmt.Errorf("split compacted part: %w", err)
+	}
+	if releases == nil {
+		releases = []func(){release}
+	} else {
+		// The halves were copied out of the compacted part.
+		release()
+	}
+
+	compacted := make([]parts.Part, 0, len(bufs))
+	postCompactionSize := int64(0)
+	for i, buf := range bufs {
+		postCompactionSize += buf.ParquetFile().Size()
+		compacted = append(compacted, parts.NewParquetPart(0, buf, append(options, parts.WithRelease(releases[i]))...))
+	}
+	return compacted, preCompactionSize, postCompactionSize, nil
+}
+
+// partsDir returns the directory the files of the mapped parts of the table are
+// written to. Without a storage path, they are written to the default
+// directory for temporary files.
+func (t *Table) partsDir() string {
+	if t.db.columnStore.storagePath == "" {
+		return ""
+	}
+	return filepath.Join(t.db.storagePath, "parts", t.name)
+}
+
+// mmapPartWriter writes a part to a file in the parts directory of the table
+// that is mapped into memory. The file is removed as soon as it is mapped, so
+// that it does not outlive the process, and its disk space is freed once the
+// returned release function unmaps it.
+func (t *Table) mmapPartWriter(write func(io.Writer) error) (*dynparquet.SerializedBuffer, func(), error) {
+	dir := t.partsDir()
+	if dir != "" {
+		if err := os.MkdirAll(dir, dirPerms); err != nil {
+			return nil, nil, err
+		}
+	}
+	file, err := os.CreateTemp(dir, "part-*.parquet")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := write(file); err != nil {
+		return nil, nil, err
+	}
+
+	m, err := mmap.Map(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("map compacted part: %w", err)
+	}
+	pf, err := parquet.OpenFile(m, int64(m.Len()))
+	if err != nil {
+		m.Close()
+		return nil, nil, err
+	}
+	buf, err := dynparquet.NewSerializedBuffer(pf)
+	if err != nil {
+		m.Close()
+		return nil, nil, err
+	}
+	return buf, func() { m.Close() }, nil
+}
+
 type fileCompaction struct {
-	t      *Table
+	t *Table
+	// mtx protects offset and ref, as the parts written to the file are
+	// released by the last scan reading them.
+	mtx    sync.Mutex
 	file   *os.File
 	offset int64 // Writing offsets into the file
 	ref    int64 // Number of references to file.
@@ -1474,34 +2732,47 @@ func (a *accountingWriter) Write(p []byte) (int, error) {
 
 // writeRecordsToParquetFile will compact the given parts into a Parquet file written to the next level file.
 func (f *fileCompaction) writeRecordsToParquetFile(compact []parts.Part, options ...parts.Option) ([]parts.Part, int64, int64, error) {
+	f.t.advanceSnapshotHorizonOf(compact)
+	// Reference the file while writing to it, so that it is not truncated by
+	// the release of the last part previously written to it.
+	f.mtx.Lock()
+	f.ref++
+	prevOffset := f.offset
+	f.mtx.Unlock()
+	release := f.release()
+
 	accountant := &accountingWriter{w: f.file}
 	preCompactionSize, err := f.t.compactParts(accountant, compact) // compact into the next level
 	if err != nil {
+		release()
 		return nil, 0, 0, err
 	}
 
 	// Record the writing offset into the file.
-	prevOffset := f.offset
+	f.mtx.Lock()
 	f.offset += accountant.n
-	f.ref++
+	f.mtx.Unlock()
 
 	pf, err := parquet.OpenFile(io.NewSectionReader(f.file, prevOffset, accountant.n), accountant.n)
 	if err != nil {
+		release()
 		return nil, 0, 0, err
 	}
 
 	buf, err := dynparquet.NewSerializedBuffer(pf)
 	if err != nil {
+		release()
 		return nil, 0, 0, err
 	}
 
-	return []parts.Part{parts.NewParquetPart(0, buf, append(options, parts.WithRelease(f.release()))...)}, preCompactionSize, accountant.n, nil
+	return []parts.Part{parts.NewParquetPart(0, buf, append(options, parts.WithRelease(release))...)}, preCompactionSize, accountant.n, nil
 }
 
 // release will account for all the Parts currently pointing to this file. Once the last one has been released it will truncate the file.
-// release is not safe to call concurrently.
 func (f *fileCompaction) release() func() {
 	return func() {
+		f.mtx.Lock()
+		defer f.mtx.Unlock()
 		f.ref--
 		if f.ref == 0 {
 			level.Info(f.t.logger).Log("msg", "truncating file", "file", f.file.Name())
@@ -3,6 +3,7 @@ package frostdb
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
@@ -19,14 +20,26 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/google/uuid"
+	"github.com/oklog/ulid"
 	"github.com/parquet-go/parquet-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/polarsignals/frostdb/audit"
+	"github.com/polarsignals/frostdb/clock"
 	"github.com/polarsignals/frostdb/dynparquet"
 	schemapb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/schema/v1alpha1"
+	tablepb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/table/v1alpha1"
 	"github.com/polarsignals/frostdb/index"
 	"github.com/polarsignals/frostdb/pqarrow"
+	"github.com/polarsignals/frostdb/pqarrow/arrowutils"
 	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/expr"
 	"github.com/polarsignals/frostdb/query/logicalplan"
 )
 
@@ -744,7 +757,7 @@ func Test_RowWriter(t *testing.T) {
 	}, false)
 	defer table.schema.PutWriter(pw)
 	require.NoError(t, err)
-	rowWriter, err := table.ActiveBlock().rowWriter(pw)
+	rowWriter, err := table.rowWriter(pw)
 	require.NoError(t, err)
 
 	// Write 17(8,9) rows, expect 3 row groups of 5 rows and 1 row group of 2 rows
@@ -1160,6 +1173,266 @@ func TestTableUniquePrimaryIndex(t *testing.T) {
 	require.Equal(t, 1, rowsRead)
 }
 
+func TestTableMaxPartRows(t *testing.T) {
+	c, err := New(WithIndexConfig([]*IndexConfig{
+		{Level: int(index.L0), MaxSize: 1 * TiB, Type: CompactionTypeParquet},
+		{Level: int(index.L1), MaxSize: 1 * TiB},
+	}))
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(
+		dynparquet.SampleDefinition(),
+		WithMaxPartRows(8),
+	))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		samples := dynparquet.NewTestSamples()
+		for j := range samples {
+			samples[j].Timestamp = int64(i*len(samples) + j)
+		}
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+		r.Release()
+	}
+	require.NoError(t, table.EnsureCompaction())
+
+	// The 30 compacted rows are split in halves until every part has at most
+	// 8 rows.
+	var numRows []int64
+	table.ActiveBlock().Index().Iterate(func(node *index.Node) bool {
+		if p := node.Part(); p != nil {
+			numRows = append(numRows, p.NumRows())
+		}
+		return true
+	})
+	require.Equal(t, []int64{8, 8, 8, 6}, numRows)
+
+	rowsRead := int64(0)
+	require.NoError(t, query.NewEngine(
+		memory.DefaultAllocator,
+		db.TableProvider()).ScanTable("test").Execute(
+		ctx, func(ctx context.Context, r arrow.Record) error {
+			rowsRead += r.NumRows()
+			return nil
+		}))
+	require.Equal(t, int64(30), rowsRead)
+}
+
+func TestTableInsertSplit(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(
+		dynparquet.SampleDefinition(),
+		WithMaxPartRows(4),
+	))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	samples := make(dynparquet.Samples, 0, 10)
+	for i := 0; i < 10; i++ {
+		samples = append(samples, dynparquet.Sample{
+			ExampleType: "cpu",
+			Labels:      map[string]string{"node": "a"},
+			Timestamp:   int64(i),
+		})
+	}
+	r, err := samples.ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	tx, err := table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+
+	// The insert is added as parts of at most 4 rows of its transaction.
+	var numRows []int64
+	table.ActiveBlock().Index().Iterate(func(node *index.Node) bool {
+		if p := node.Part(); p != nil {
+			require.Equal(t, tx, p.TX())
+			numRows = append(numRows, p.NumRows())
+		}
+		return true
+	})
+	require.ElementsMatch(t, []int64{4, 4, 2}, numRows)
+
+	var timestamps []int64
+	require.NoError(t, query.NewEngine(memory.DefaultAllocator, db.TableProvider()).
+		ScanTable("test").
+		Project(logicalplan.Col("timestamp")).
+		Execute(ctx, func(_ context.Context, r arrow.Record) error {
+			for i := 0; i < int(r.NumRows()); i++ {
+				timestamps = append(timestamps, r.Column(0).(*array.Int64).Value(i))
+			}
+			return nil
+		}))
+	require.ElementsMatch(t, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, timestamps)
+}
+
+func TestTableInsertOrder(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	record := func(nodes ...string) arrow.Record {
+		samples := make(dynparquet.Samples, 0, len(nodes))
+		for _, node := range nodes {
+			samples = append(samples, dynparquet.Sample{
+				ExampleType: "cpu",
+				Labels:      map[string]string{"node": node},
+			})
+		}
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		return r
+	}
+	sorted := record("a", "b", "b", "c")
+	defer sorted.Release()
+	unsorted := record("b", "c", "a")
+	defer unsorted.Release()
+
+	verified, err := db.Table("verified", NewTableConfig(
+		dynparquet.SampleDefinition(),
+		WithInsertOrderVerification(),
+	))
+	require.NoError(t, err)
+	_, err = verified.InsertRecord(ctx, sorted)
+	require.NoError(t, err)
+	_, err = verified.InsertRecord(ctx, unsorted)
+	var unsortedErr ErrUnsortedInsert
+	require.True(t, errors.As(err, &unsortedErr))
+	require.Equal(t, int64(2), unsortedErr.Row)
+
+	autoSorted, err := db.Table("auto_sorted", NewTableConfig(
+		dynparquet.SampleDefinition(),
+		WithAutoSort(),
+	))
+	require.NoError(t, err)
+	_, err = autoSorted.InsertRecord(ctx, unsorted)
+	require.NoError(t, err)
+	var nodes []string
+	autoSorted.ActiveBlock().Index().Iterate(func(node *index.Node) bool {
+		if p := node.Part(); p != nil {
+			r := p.Record()
+			col := r.Column(r.Schema().FieldIndices("labels.node")[0])
+			for i := 0; i < col.Len(); i++ {
+				nodes = append(nodes, string(col.GetOneForMarshal(i).([]byte)))
+			}
+		}
+		return true
+	})
+	require.Equal(t, []string{"a", "b", "c"}, nodes)
+}
+
+func TestTableCloseDuringInserts(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				r, err := dynparquet.NewTestSamples().ToRecord()
+				require.NoError(t, err)
+				_, err = table.InsertRecord(ctx, r)
+				r.Release()
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// Closing waits for the inserts in flight, which must not wait for the
+	// table lock held by the close.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		require.NoError(t, c.Close())
+		wg.Wait()
+	}()
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("close deadlocked with inserts")
+	}
+}
+
+func TestTableMinPartRows(t *testing.T) {
+	c, err := New(WithIndexConfig([]*IndexConfig{
+		{Level: int(index.L0), MaxSize: 1 * TiB, Type: CompactionTypeParquet},
+		{Level: int(index.L1), MaxSize: 1 * TiB},
+	}))
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(
+		dynparquet.SampleDefinition(),
+		WithMinPartRows(10),
+	))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	partRows := func() []int64 {
+		var numRows []int64
+		table.ActiveBlock().Index().Iterate(func(node *index.Node) bool {
+			if p := node.Part(); p != nil {
+				numRows = append(numRows, p.NumRows())
+			}
+			return true
+		})
+		return numRows
+	}
+	for i, expected := range [][]int64{{3}, {6}, {9}, {12}, {3, 12}} {
+		samples := dynparquet.NewTestSamples()
+		for j := range samples {
+			samples[j].Timestamp = int64(i*len(samples) + j)
+		}
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+		r.Release()
+		require.NoError(t, table.EnsureCompaction())
+
+		// Every compacted part is merged with the adjacent part of L1 as
+		// long as both have less than 10 rows.
+		require.Equal(t, expected, partRows())
+	}
+	require.Equal(t, float64(3), testutil.ToFloat64(table.metrics.partMerges))
+
+	rowsRead := int64(0)
+	require.NoError(t, query.NewEngine(
+		memory.DefaultAllocator,
+		db.TableProvider()).ScanTable("test").Execute(
+		ctx, func(ctx context.Context, r arrow.Record) error {
+			rowsRead += r.NumRows()
+			return nil
+		}))
+	require.Equal(t, int64(15), rowsRead)
+}
+
 func TestTable_write_ptr_struct(t *testing.T) {
 	columnstore, err := New()
 	require.Nil(t, err)
@@ -1179,3 +1452,1123 @@ func TestTable_write_ptr_struct(t *testing.T) {
 	})
 	require.Nil(t, err)
 }
+
+func TestTablePruningStats(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	// Compact the records into a Parquet part with statistics and bloom
+	// filters.
+	require.NoError(t, table.EnsureCompaction())
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	for _, tc := range []struct {
+		name        string
+		filter      logicalplan.Expr
+		statistics  int64
+		bloomFilter int64
+	}{
+		{
+			name:   "match",
+			filter: logicalplan.Col("labels.namespace").Eq(logicalplan.Literal("default")),
+		},
+		{
+			name:        "bloom_filter",
+			filter:      logicalplan.Col("labels.namespace").Eq(logicalplan.Literal("dev")),
+			bloomFilter: 1,
+		},
+		{
+			name:       "statistics",
+			filter:     logicalplan.Col("value").Gt(logicalplan.Literal(int64(100))),
+			statistics: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stats := &expr.PruningStats{}
+			require.NoError(t, engine.ScanTable("test").Filter(tc.filter).Execute(
+				expr.WithPruningStats(ctx, stats),
+				func(context.Context, arrow.Record) error { return nil },
+			))
+			require.Equal(t, int64(1), stats.RowGroupsConsidered.Load())
+			require.Equal(t, tc.statistics, stats.RowGroupsSkippedByStatistics.Load())
+			require.Equal(t, tc.bloomFilter, stats.RowGroupsSkippedByBloomFilter.Load())
+		})
+	}
+}
+
+func TestTableMinMaxStatistics(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	// Compact the records into a Parquet part with statistics.
+	require.NoError(t, table.EnsureCompaction())
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	for _, tc := range []struct {
+		name       string
+		filter     logicalplan.Expr
+		statistics int64
+		minValue   int64
+	}{
+		{
+			name:       "no_filter",
+			statistics: 1,
+			minValue:   3,
+		},
+		{
+			name:       "all_rows_match",
+			filter:     logicalplan.Col("timestamp").GtEq(logicalplan.Literal(int64(2))),
+			statistics: 1,
+			minValue:   3,
+		},
+		{
+			name:     "some_rows_match",
+			filter:   logicalplan.Col("value").Gt(logicalplan.Literal(int64(3))),
+			minValue: 5,
+		},
+		{
+			name:     "string_filter",
+			filter:   logicalplan.Col("labels.namespace").Eq(logicalplan.Literal("default")),
+			minValue: 3,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stats := &expr.PruningStats{}
+			builder := engine.ScanTable("test")
+			if tc.filter != nil {
+				builder = builder.Filter(tc.filter)
+			}
+			var maxTimestamp, minValue int64
+			require.NoError(t, builder.Aggregate(
+				[]logicalplan.Expr{
+					logicalplan.Max(logicalplan.Col("timestamp")),
+					logicalplan.Min(logicalplan.Col("value")),
+				},
+				nil,
+			).Execute(
+				expr.WithPruningStats(ctx, stats),
+				func(_ context.Context, r arrow.Record) error {
+					require.Equal(t, int64(1), r.NumRows())
+					maxTimestamp = r.Column(0).(*array.Int64).Value(0)
+					minValue = r.Column(1).(*array.Int64).Value(0)
+					return nil
+				},
+			))
+			require.Equal(t, tc.statistics, stats.RowGroupsReadFromStatistics.Load())
+			require.Equal(t, int64(2), maxTimestamp)
+			require.Equal(t, tc.minValue, minValue)
+		})
+	}
+}
+
+func TestTableRowCount(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	samples := dynparquet.NewTestSamples()
+	for i := 0; i < 2; i++ {
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(ctx, r)
+		r.Release()
+		require.NoError(t, err)
+		if i == 0 {
+			// Count the rows of both Parquet parts and in-memory records.
+			require.NoError(t, table.EnsureCompaction())
+		}
+	}
+
+	require.NoError(t, table.View(ctx, func(ctx context.Context, tx uint64) error {
+		rows, err := table.RowCount(ctx, tx)
+		require.NoError(t, err)
+		require.Equal(t, int64(2*len(samples)), rows)
+		return nil
+	}))
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	for _, tc := range []struct {
+		name   string
+		filter logicalplan.Expr
+		count  int64
+	}{
+		{
+			name:  "no_filter",
+			count: int64(2 * len(samples)),
+		},
+		{
+			name:   "filter",
+			filter: logicalplan.Col("value").Gt(logicalplan.Literal(int64(3))),
+			count:  2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := engine.ScanTable("test")
+			if tc.filter != nil {
+				builder = builder.Filter(tc.filter)
+			}
+			var count int64
+			require.NoError(t, builder.Aggregate(
+				[]logicalplan.Expr{logicalplan.Count(logicalplan.Col("value")).Alias("count")},
+				nil,
+			).Execute(ctx, func(_ context.Context, r arrow.Record) error {
+				require.Equal(t, int64(1), r.NumRows())
+				require.Equal(t, "count", r.Schema().Field(0).Name)
+				count = r.Column(0).(*array.Int64).Value(0)
+				return nil
+			}))
+			require.Equal(t, tc.count, count)
+		})
+	}
+}
+
+func TestTableEstimateScan(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	samples := dynparquet.NewTestSamples()
+	for i := 0; i < 2; i++ {
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(ctx, r)
+		r.Release()
+		require.NoError(t, err)
+		if i == 0 {
+			// Estimate both Parquet parts and in-memory records.
+			require.NoError(t, table.EnsureCompaction())
+		}
+	}
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	stats, err := engine.ScanTable("test").
+		Project(logicalplan.Col("value")).(query.StatsOnlyExecutor).
+		ExecuteStatsOnly(ctx)
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.Equal(t, "test", stats[0].Table)
+	require.Equal(t, int64(2), stats[0].RowGroups)
+	require.Equal(t, int64(2*len(samples)), stats[0].Rows)
+	require.Greater(t, stats[0].Bytes, int64(0))
+
+	// The compacted part is pruned by its statistics, the in-memory record
+	// is always read.
+	stats, err = engine.ScanTable("test").
+		Filter(logicalplan.Col("timestamp").Gt(logicalplan.Literal(int64(100)))).(query.StatsOnlyExecutor).
+		ExecuteStatsOnly(ctx)
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.Equal(t, int64(1), stats[0].RowGroups)
+	require.Equal(t, int64(1), stats[0].RowGroupsSkipped)
+	require.Equal(t, int64(len(samples)), stats[0].Rows)
+}
+
+func TestTableExecutePage(t *testing.T) {
+	// Seeking to the cursor by a string first sorting column, which is
+	// dictionary encoded in memory and binary once compacted.
+	sample := dynparquet.SampleDefinition()
+	// Seeking to the cursor by a numeric first sorting column, descending
+	// with nulls last.
+	byTimestamp := dynparquet.SampleDefinition()
+	byTimestamp.SortingColumns = append([]*schemapb.SortingColumn{{
+		Name:      "timestamp",
+		Direction: schemapb.SortingColumn_DIRECTION_DESCENDING,
+	}}, byTimestamp.SortingColumns...)
+	// Seeking to the cursor by a binary first sorting column, descending with
+	// nulls first.
+	byStacktrace := dynparquet.SampleDefinition()
+	byStacktrace.SortingColumns = append([]*schemapb.SortingColumn{{
+		Name:       "stacktrace",
+		Direction:  schemapb.SortingColumn_DIRECTION_DESCENDING,
+		NullsFirst: true,
+	}}, byStacktrace.SortingColumns...)
+
+	for name, def := range map[string]*schemapb.Schema{
+		"sample":       sample,
+		"byTimestamp":  byTimestamp,
+		"byStacktrace": byStacktrace,
+	} {
+		t.Run(name, func(t *testing.T) {
+			testTableExecutePage(t, def)
+		})
+	}
+}
+
+func testTableExecutePage(t *testing.T, def *schemapb.Schema) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(def))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	insert := func(timestamp int64) {
+		samples := dynparquet.NewTestSamples()
+		for i := range samples {
+			samples[i].Timestamp = timestamp
+		}
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		defer r.Release()
+		_, err = table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+	}
+	// The first and the last insert are equal, so rows tie across pages.
+	for i := 0; i < 4; i++ {
+		insert(int64(i % 3))
+	}
+	require.NoError(t, table.EnsureCompaction())
+	insert(3)
+
+	rows := func(r arrow.Record) []string {
+		rows := make([]string, r.NumRows())
+		for i := range rows {
+			for j, field := range r.Schema().Fields() {
+				if r.Column(j).IsValid(i) {
+					rows[i] += fmt.Sprintf("%s=%s ", field.Name, r.Column(j).ValueStr(i))
+				}
+			}
+		}
+		return rows
+	}
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	page := func(cursor string, limit int) ([]string, string) {
+		var page []string
+		next, err := engine.ScanTable("test").(query.PagedExecutor).
+			ExecutePage(ctx, cursor, limit, func(_ context.Context, r arrow.Record) error {
+				page = append(page, rows(r)...)
+				return nil
+			})
+		require.NoError(t, err)
+		return page, next
+	}
+
+	all, next := page("", 100)
+	require.Len(t, all, 15)
+	require.Empty(t, next)
+
+	for _, limit := range []int{1, 4, 15} {
+		t.Run(fmt.Sprintf("limit=%d", limit), func(t *testing.T) {
+			var paged []string
+			p, cursor := page("", limit)
+			for cursor != "" {
+				require.Len(t, p, limit)
+				paged = append(paged, p...)
+				p, cursor = page(cursor, limit)
+			}
+			paged = append(paged, p...)
+			require.Equal(t, all, paged)
+		})
+	}
+
+	// Writes after the first page are not paged through.
+	first, cursor := page("", 10)
+	insert(4)
+	rest, cursor := page(cursor, 10)
+	require.Empty(t, cursor)
+	require.Equal(t, all, append(first, rest...))
+
+	// Once writes after the first page are compacted, the pages can't be read
+	// as of its transaction anymore.
+	_, cursor = page("", 10)
+	insert(5)
+	require.NoError(t, table.EnsureCompaction())
+	_, err = engine.ScanTable("test").(query.PagedExecutor).
+		ExecutePage(ctx, cursor, 10, func(context.Context, arrow.Record) error { return nil })
+	require.ErrorIs(t, err, query.ErrInvalidCursor)
+	require.ErrorIs(t, err, logicalplan.ErrSnapshotUnavailable)
+
+	_, err = engine.ScanTable("test").(query.PagedExecutor).
+		ExecutePage(ctx, "invalid", 10, func(context.Context, arrow.Record) error { return nil })
+	require.ErrorIs(t, err, query.ErrInvalidCursor)
+
+	// The cursor of a query can't page through another query.
+	_, cursor = page("", 1)
+	_, err = engine.ScanTable("test").
+		Filter(logicalplan.Col("timestamp").Gt(logicalplan.Literal(int64(0)))).(query.PagedExecutor).
+		ExecutePage(ctx, cursor, 10, func(context.Context, arrow.Record) error { return nil })
+	require.ErrorIs(t, err, query.ErrInvalidCursor)
+}
+
+func TestTableExecutePageNow(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for timestamp := int64(8); timestamp <= 10; timestamp++ {
+		samples := dynparquet.NewTestSamples()
+		for i := range samples {
+			samples[i].Timestamp = timestamp
+		}
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(ctx, r)
+		r.Release()
+		require.NoError(t, err)
+	}
+
+	clk := clock.NewManual(time.UnixMilli(10))
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider(), query.WithClock(clk))
+	// All rows are written within the last 2ms when the first page is read.
+	filter := logicalplan.Col("timestamp").GtEq(logicalplan.Sub(logicalplan.Now(), logicalplan.Duration(2*time.Millisecond)))
+
+	rows := 0
+	cursor := ""
+	for pages := 0; pages == 0 || cursor != ""; pages++ {
+		require.Less(t, pages, 9)
+		cursor, err = engine.ScanTable("test").Filter(filter).(query.PagedExecutor).
+			ExecutePage(ctx, cursor, 2, func(_ context.Context, r arrow.Record) error {
+				rows += int(r.NumRows())
+				return nil
+			})
+		require.NoError(t, err)
+		// now() of the following pages is bound to the time of the first.
+		clk.Advance(time.Millisecond)
+	}
+	require.Equal(t, 9, rows)
+}
+
+func TestTableSortingAdvice(t *testing.T) {
+	c, err := New(WithSortingAdvisor(0))
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	advice := table.SortingAdvice()
+	require.Equal(t, []string{"example_type", "labels", "timestamp", "stacktrace"}, advice.Current)
+	require.False(t, advice.Differs())
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	for _, filter := range []logicalplan.Expr{
+		logicalplan.And(
+			logicalplan.Col("labels.namespace").Eq(logicalplan.Literal("default")),
+			logicalplan.Col("timestamp").Gt(logicalplan.Literal(int64(1))),
+		),
+		logicalplan.Col("labels.pod").Eq(logicalplan.Literal("pod-1")),
+		logicalplan.Col("timestamp").LtEq(logicalplan.Literal(int64(10))),
+		logicalplan.Col("stacktrace").RegexMatch("foo"),
+	} {
+		require.NoError(t, engine.ScanTable("test").Filter(filter).Execute(
+			context.Background(),
+			func(context.Context, arrow.Record) error { return nil },
+		))
+	}
+
+	advice = table.SortingAdvice()
+	require.Equal(t, int64(4), advice.Queries)
+	require.Equal(t, []string{"labels", "timestamp", "example_type", "stacktrace"}, advice.Recommended)
+	require.True(t, advice.Differs())
+	require.Equal(t, []ColumnUsage{
+		{Column: "labels", Equality: 2},
+		{Column: "timestamp", Range: 2},
+		{Column: "stacktrace", Other: 1},
+	}, advice.Usage)
+}
+
+func TestTableRewrite(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	require.NoError(t, table.EnsureCompaction())
+	// The uncompacted record is retained by the block until it is released.
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	indices := array.NewInt64Builder(mem)
+	indices.AppendValues([]int64{0, 1, 2}, nil)
+	all := indices.NewInt64Array()
+	indices.Release()
+	uncompacted, err := arrowutils.TakeRecord(mem, r, all)
+	all.Release()
+	require.NoError(t, err)
+	_, err = table.InsertRecord(ctx, uncompacted)
+	uncompacted.Release()
+	require.NoError(t, err)
+	require.NotZero(t, mem.CurrentAlloc())
+
+	def := dynparquet.SampleDefinition()
+	def.SortingColumns = []*schemapb.SortingColumn{{
+		Name:      "value",
+		Direction: schemapb.SortingColumn_DIRECTION_DESCENDING,
+	}}
+	require.NoError(t, table.Rewrite(ctx, NewTableConfig(def, WithRowGroupSize(2))))
+	require.Equal(t, "value", table.Schema().SortingColumns()[0].Name)
+	// The rewritten block is released.
+	mem.AssertSize(t, 0)
+
+	var values []int64
+	numParts := 0
+	table.ActiveBlock().Index().Iterate(func(node *index.Node) bool {
+		if node.Part() == nil {
+			return true
+		}
+		numParts++
+		buf, err := node.Part().AsSerializedBuffer(nil)
+		require.NoError(t, err)
+		require.Equal(t, 3, buf.NumRowGroups())
+		leaf, ok := buf.ParquetFile().Schema().Lookup("value")
+		require.True(t, ok)
+		rows := buf.MultiDynamicRowGroup().Rows()
+		defer rows.Close()
+		rowBuf := make([]parquet.Row, 10)
+		for {
+			n, err := rows.ReadRows(rowBuf)
+			for _, row := range rowBuf[:n] {
+				for _, v := range row {
+					if v.Column() == leaf.ColumnIndex {
+						values = append(values, v.Int64())
+					}
+				}
+			}
+			if err == io.EOF || n == 0 {
+				return true
+			}
+			require.NoError(t, err)
+		}
+	})
+	require.Equal(t, 1, numParts)
+	require.Equal(t, []int64{5, 5, 3, 3, 3, 3}, values)
+
+	// Writes after the rewrite use the new config.
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	rows := int64(0)
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	require.NoError(t, engine.ScanTable("test").Execute(ctx, func(_ context.Context, r arrow.Record) error {
+		rows += r.NumRows()
+		return nil
+	}))
+	require.Equal(t, int64(9), rows)
+
+	// Columns can't be changed by a rewrite.
+	def.Columns = def.Columns[1:]
+	require.Error(t, table.Rewrite(ctx, NewTableConfig(def)))
+	require.Error(t, table.Rewrite(ctx, &tablepb.TableConfig{}))
+}
+
+func TestTableRewriteDuringInserts(t *testing.T) {
+	sink := &testAuditSink{}
+	c, err := New(WithAuditSink(sink))
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	const writers, inserts = 4, 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < inserts; j++ {
+				r, err := dynparquet.NewTestSamples().ToRecord()
+				require.NoError(t, err)
+				_, err = table.InsertRecord(ctx, r)
+				r.Release()
+				require.NoError(t, err)
+			}
+		}()
+	}
+
+	// Rewrites wait for the writers that joined the block without holding
+	// the table lock, and neither lose nor duplicate their rows.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 5; i++ {
+			// Rewrite while the writers are inserting.
+			for db.HighWatermark() < uint64(i*30) {
+				time.Sleep(time.Millisecond)
+			}
+			require.NoError(t, table.Rewrite(ctx, NewTableConfig(dynparquet.SampleDefinition(), WithRowGroupSize(i*10))))
+		}
+		wg.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("rewrite deadlocked with inserts")
+	}
+
+	// The scan calls the callback concurrently.
+	var rows atomic.Int64
+	require.NoError(t, query.NewEngine(memory.DefaultAllocator, db.TableProvider()).ScanTable("test").Execute(ctx, func(_ context.Context, r arrow.Record) error {
+		rows.Add(r.NumRows())
+		return nil
+	}))
+	require.Equal(t, int64(writers*inserts*3), rows.Load())
+	require.Equal(t, uint64(50), table.config.Load().RowGroupSize)
+
+	changed := 0
+	for _, typ := range sink.types() {
+		if typ == audit.EventTableConfigChanged {
+			changed++
+		}
+	}
+	require.Equal(t, 5, changed)
+}
+
+func TestTableRewritePersistedBlocks(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(
+		WithReadWriteStorage(NewDefaultObjstoreBucket(objstore.NewInMemBucket())),
+		WithManualBlockRotation(),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	writeTx, err := table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	db.Wait(writeTx + 2)
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+
+	// The persisted block is sorted by the current sorting columns.
+	def := dynparquet.SampleDefinition()
+	def.SortingColumns = []*schemapb.SortingColumn{{
+		Name:      "value",
+		Direction: schemapb.SortingColumn_DIRECTION_DESCENDING,
+	}}
+	require.ErrorIs(t, table.Rewrite(ctx, NewTableConfig(def)), ErrRewriteSorting)
+	require.Equal(t, "example_type", table.Schema().SortingColumns()[0].Name)
+
+	// Other changes of the layout are fine.
+	require.NoError(t, table.Rewrite(ctx, NewTableConfig(dynparquet.SampleDefinition(), WithRowGroupSize(2))))
+
+	rows := int64(0)
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	require.NoError(t, engine.ScanTable("test").Execute(ctx, func(_ context.Context, r arrow.Record) error {
+		rows += r.NumRows()
+		return nil
+	}))
+	require.Equal(t, 2*r.NumRows(), rows)
+}
+
+func TestTableIteratorUnifiedSchema(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	// Compact the first record into a Parquet part so that the scan reads
+	// both a Parquet and an Arrow part.
+	require.NoError(t, table.EnsureCompaction())
+
+	samples := dynparquet.NewTestSamples()[:1]
+	samples[0].Labels = map[string]string{"region": "eu"}
+	r, err = samples.ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+
+	var (
+		schemas []*arrow.Schema
+		rows    int64
+	)
+	require.NoError(t, table.View(ctx, func(ctx context.Context, tx uint64) error {
+		return table.Iterator(ctx, tx, memory.DefaultAllocator, []logicalplan.Callback{
+			func(_ context.Context, r arrow.Record) error {
+				schemas = append(schemas, r.Schema())
+				rows += r.NumRows()
+				return nil
+			},
+		}, logicalplan.WithUnifiedSchema())
+	}))
+	require.Equal(t, int64(4), rows)
+	require.Len(t, schemas, 2)
+	require.True(t, schemas[0].Equal(schemas[1]), "%s != %s", schemas[0], schemas[1])
+	for _, name := range []string{"labels.container", "labels.namespace", "labels.node", "labels.pod", "labels.region"} {
+		require.True(t, schemas[0].HasField(name), name)
+	}
+}
+
+func TestTableScanSharing(t *testing.T) {
+	c, err := New(WithScanSharing(100 * time.Millisecond))
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	errQuery := fmt.Errorf("query failed")
+	var wg sync.WaitGroup
+	for _, tc := range []struct {
+		filter logicalplan.Expr
+		rows   int64
+		err    error
+	}{
+		{filter: logicalplan.Col("value").Eq(logicalplan.Literal(int64(5))), rows: 1},
+		{filter: logicalplan.Col("value").Eq(logicalplan.Literal(int64(3))), rows: 2},
+		{filter: logicalplan.Col("value").Gt(logicalplan.Literal(int64(0))), err: errQuery},
+	} {
+		tc := tc
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var rows int64
+			err := engine.ScanTable("test").Filter(tc.filter).Execute(ctx, func(_ context.Context, r arrow.Record) error {
+				if tc.err != nil {
+					return tc.err
+				}
+				rows += r.NumRows()
+				return nil
+			})
+			require.ErrorIs(t, err, tc.err)
+			require.Equal(t, tc.rows, rows, tc.filter.String())
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, float64(2), testutil.ToFloat64(table.metrics.scansShared))
+}
+
+func TestTableScanSharingWindowClock(t *testing.T) {
+	const window = time.Minute
+	clk := clock.NewManual(time.Now())
+	c, err := New(WithScanSharing(window), WithClock(clk))
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	const scans = 2
+	done := make(chan int64, scans)
+	for i := 0; i < scans; i++ {
+		go func() {
+			var rows int64
+			err := engine.ScanTable("test").Execute(ctx, func(_ context.Context, r arrow.Record) error {
+				rows += r.NumRows()
+				return nil
+			})
+			require.NoError(t, err)
+			done <- rows
+		}()
+	}
+
+	// The scans wait for the window on the clock of the column store, however
+	// long it takes in real time.
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(table.metrics.scansShared) == scans-1
+	}, 5*time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	require.Empty(t, done)
+
+	require.Eventually(t, func() bool {
+		clk.Advance(window)
+		return len(done) == scans
+	}, 5*time.Second, time.Millisecond)
+	for i := 0; i < scans; i++ {
+		require.Equal(t, int64(3), <-done)
+	}
+}
+
+func TestTableActivePartCompression(t *testing.T) {
+	ctx := context.Background()
+	newTable := func(options ...Option) *Table {
+		c, err := New(options...)
+		require.NoError(t, err)
+		t.Cleanup(func() { c.Close() })
+		db, err := c.DB(ctx, "test")
+		require.NoError(t, err)
+		table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+		require.NoError(t, err)
+
+		samples := make(dynparquet.Samples, 0, 1000)
+		for i := 0; i < 1000; i++ {
+			samples = append(samples, dynparquet.NewTestSamples()...)
+		}
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		defer r.Release()
+		_, err = table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+		return table
+	}
+	uncompressed := newTable()
+	table := newTable(WithActivePartCompression())
+	require.Less(t, table.ActiveBlock().Size(), uncompressed.ActiveBlock().Size())
+
+	sum := func() int64 {
+		var sum int64
+		engine := query.NewEngine(memory.DefaultAllocator, table.db.TableProvider())
+		err := engine.ScanTable("test").
+			Project(logicalplan.Col("value")).
+			Execute(ctx, func(_ context.Context, r arrow.Record) error {
+				values := r.Column(0).(*array.Int64)
+				for i := 0; i < values.Len(); i++ {
+					sum += values.Value(i)
+				}
+				return nil
+			})
+		require.NoError(t, err)
+		return sum
+	}
+	require.Equal(t, int64(11000), sum())
+	require.NoError(t, table.EnsureCompaction())
+	require.Equal(t, int64(11000), sum())
+}
+
+func TestTableCompactionSortsRecords(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for _, timestamps := range [][]int64{{3, 1}, {2, 0}} {
+		samples := make(dynparquet.Samples, 0, len(timestamps))
+		for _, ts := range timestamps {
+			samples = append(samples, dynparquet.Sample{
+				ExampleType: "cpu",
+				Labels:      map[string]string{"node": "test"},
+				Timestamp:   ts,
+				Value:       ts,
+			})
+		}
+		r, err := samples.ToRecord()
+		require.NoError(t, err)
+		_, err = table.InsertRecord(ctx, r)
+		require.NoError(t, err)
+		r.Release()
+	}
+	require.NoError(t, table.EnsureCompaction())
+
+	var timestamps []int64
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	err = engine.ScanTable("test").
+		Project(logicalplan.Col("timestamp")).
+		Execute(ctx, func(_ context.Context, r arrow.Record) error {
+			timestamps = append(timestamps, r.Column(0).(*array.Int64).Int64Values()...)
+			return nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, []int64{0, 1, 2, 3}, timestamps)
+}
+
+func TestTableInsertColumns(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = table.InsertColumns(ctx, map[string]ColumnData{
+		"example_type":     {Strings: []string{"cpu", "cpu", "cpu"}},
+		"labels.namespace": {Strings: []string{"default", "", "default"}, Valid: []bool{true, false, true}},
+		"labels.pod":       {Strings: []string{"", "test1", ""}, Valid: []bool{false, true, false}},
+		"stacktrace":       {Strings: []string{"a", "b", "c"}},
+		"timestamp":        {Int64s: []int64{1, 2, 3}},
+		"value":            {Int64s: []int64{5, 3, 3}},
+	})
+	require.NoError(t, err)
+
+	var rows int64
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	err = engine.ScanTable("test").
+		Filter(logicalplan.Col("labels.namespace").Eq(logicalplan.Literal("default"))).
+		Project(logicalplan.Col("value")).
+		Execute(ctx, func(_ context.Context, r arrow.Record) error {
+			rows += r.NumRows()
+			require.Equal(t, []int64{5, 3}, r.Column(0).(*array.Int64).Int64Values())
+			return nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), rows)
+
+	for name, columns := range map[string]map[string]ColumnData{
+		"unknown column": {
+			"unknown": {Int64s: []int64{1}},
+		},
+		"wrong type": {
+			"example_type": {Strings: []string{"cpu"}},
+			"stacktrace":   {Strings: []string{"a"}},
+			"timestamp":    {Strings: []string{"1"}},
+			"value":        {Int64s: []int64{1}},
+		},
+		"different lengths": {
+			"example_type": {Strings: []string{"cpu"}},
+			"stacktrace":   {Strings: []string{"a"}},
+			"timestamp":    {Int64s: []int64{1, 2}},
+			"value":        {Int64s: []int64{1}},
+		},
+		"missing column": {
+			"example_type": {Strings: []string{"cpu"}},
+			"stacktrace":   {Strings: []string{"a"}},
+			"timestamp":    {Int64s: []int64{1}},
+		},
+	} {
+		_, err := table.InsertColumns(ctx, columns)
+		require.Error(t, err, name)
+	}
+}
+
+func TestTableSchemaRegistry(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx := context.Background()
+	tables := make([]*Table, 0, 3)
+	for _, tenant := range []string{"a", "b"} {
+		db, err := c.DB(ctx, tenant)
+		require.NoError(t, err)
+		table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+		require.NoError(t, err)
+		tables = append(tables, table)
+	}
+	require.Same(t, tables[0].Schema(), tables[1].Schema())
+	require.Equal(t, 1, c.schemas.len())
+
+	// A diverging schema doesn't change the shared schema.
+	db, err := c.DB(ctx, "c")
+	require.NoError(t, err)
+	def := dynparquet.SampleDefinition()
+	def.SortingColumns = def.SortingColumns[:1]
+	table, err := db.Table("test", NewTableConfig(def))
+	require.NoError(t, err)
+	require.NotSame(t, tables[0].Schema(), table.Schema())
+	require.Len(t, tables[0].Schema().SortingColumns(), 4)
+	require.Equal(t, 2, c.schemas.len())
+
+	// The schema is dropped once no table uses it.
+	require.NoError(t, c.DropDB("c"))
+	require.Equal(t, 1, c.schemas.len())
+}
+
+func TestTableDryRun(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+
+	require.NoError(t, db.TableDryRun("test", NewTableConfig(dynparquet.SampleDefinition())))
+
+	def := dynparquet.SampleDefinition()
+	def.SortingColumns = append(def.SortingColumns, &schemapb.SortingColumn{Name: "unknown"})
+	err = db.TableDryRun("test/table", NewTableConfig(def))
+	require.ErrorContains(t, err, "invalid table name")
+	require.ErrorContains(t, err, `sorting column "unknown" is not a column of the schema`)
+	require.ErrorContains(t, err, `sorting column "unknown" has no direction`)
+
+	// The dry run doesn't create the table.
+	_, err = db.GetTable("test")
+	require.Error(t, err)
+	require.Error(t, ValidateTableConfig(&tablepb.TableConfig{}))
+}
+
+func TestGetOrCreateTable(t *testing.T) {
+	c, err := New()
+	require.NoError(t, err)
+	defer c.Close()
+	db, err := c.DB(context.Background(), "test")
+	require.NoError(t, err)
+
+	config := NewTableConfig(dynparquet.SampleDefinition())
+	table, err := db.GetOrCreateTable("test", config)
+	require.NoError(t, err)
+
+	// Same config returns the existing table.
+	same, err := db.GetOrCreateTable("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+	require.Same(t, table, same)
+
+	// A different config is reported instead of replacing the table's config.
+	_, err = db.GetOrCreateTable("test", NewTableConfig(dynparquet.SampleDefinition(), WithRowGroupSize(10)))
+	require.ErrorIs(t, err, ErrTableConfigMismatch{TableName: "test"})
+	require.True(t, proto.Equal(config, table.config.Load()))
+
+	// Invalid configs are reported before creating the table.
+	def := dynparquet.SampleDefinition()
+	def.SortingColumns[0].Name = "unknown"
+	_, err = db.GetOrCreateTable("invalid", NewTableConfig(def))
+	require.Error(t, err)
+	_, err = db.GetTable("invalid")
+	require.ErrorIs(t, err, ErrTableNotFound{TableName: "invalid"})
+}
+
+func TestTableDurationExemplars(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c, table := basicTable(t, WithRegistry(reg))
+	defer c.Close()
+
+	traceID := oteltrace.TraceID{0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0x9, 0xa, 0xb, 0xc, 0xd, 0xe, 0xf, 0x10}
+	ctx := oteltrace.ContextWithSpanContext(context.Background(), oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     oteltrace.SpanID{0x1},
+		TraceFlags: oteltrace.FlagsSampled,
+	}))
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	_, err = table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	// Inserts without a sampled trace are observed without exemplar.
+	_, err = table.InsertRecord(context.Background(), r)
+	require.NoError(t, err)
+	require.NoError(t, table.View(ctx, func(ctx context.Context, tx uint64) error {
+		return table.Iterator(ctx, tx, memory.DefaultAllocator, []logicalplan.Callback{
+			func(context.Context, arrow.Record) error { return nil },
+		})
+	}))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	counts := map[string]uint64{}
+	for _, family := range families {
+		switch family.GetName() {
+		case "frostdb_table_insert_duration_seconds", "frostdb_table_scan_duration_seconds":
+		default:
+			continue
+		}
+		h := family.GetMetric()[0].GetHistogram()
+		counts[family.GetName()] = h.GetSampleCount()
+		var exemplars []*dto.Exemplar
+		for _, b := range h.GetBucket() {
+			if b.GetExemplar() != nil {
+				exemplars = append(exemplars, b.GetExemplar())
+			}
+		}
+		require.NotEmpty(t, exemplars, family.GetName())
+		for _, e := range exemplars {
+			require.Equal(t, "trace_id", e.GetLabel()[0].GetName())
+			require.Equal(t, traceID.String(), e.GetLabel()[0].GetValue())
+		}
+	}
+	require.Equal(t, map[string]uint64{
+		"frostdb_table_insert_duration_seconds": 2,
+		"frostdb_table_scan_duration_seconds":   1,
+	}, counts)
+}
+
+func TestTableClock(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := clock.NewManual(start)
+	store, table := basicTable(t, WithClock(c), WithManualBlockRotation())
+	defer store.Close()
+
+	ctx := context.Background()
+	first := table.ActiveBlock().ulid
+	require.Equal(t, ulid.Timestamp(start), first.Time())
+
+	// Blocks rotated without the clock advancing still get later timestamps.
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), true))
+	second := table.ActiveBlock().ulid
+	require.Equal(t, first.Time()+1, second.Time())
+
+	c.Advance(24 * time.Hour)
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), true))
+	require.Equal(t, ulid.Timestamp(start.Add(24*time.Hour)), table.ActiveBlock().ulid.Time())
+}
@@ -0,0 +1,463 @@
+package frostdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/polarsignals/frostdb/audit"
+	"github.com/polarsignals/frostdb/query/expr"
+)
+
+// tableManifestFileName is the name of the manifest of the persisted blocks of
+// a table, uploaded next to the block directories of the table.
+const tableManifestFileName = "blocks.json"
+
+// tableManifest lists the persisted blocks of a table together with the
+// statistics of their columns, so that scans can prune blocks without listing
+// the bucket and reading the footer of every block.
+type tableManifest struct {
+	Blocks []tableManifestBlock `json:"blocks"`
+}
+
+type tableManifestBlock struct {
+	// ULID is the id of the block, which is also the name of its directory.
+	ULID string `json:"ulid"`
+	// Size is the size in bytes of the block's data file.
+	Size int64 `json:"size"`
+	// NumRows is the number of rows of the block.
+	NumRows int64 `json:"num_rows"`
+	// Columns are the columns of the block and their statistics. Blocks
+	// without columns can't be pruned by their statistics.
+	Columns []tableManifestColumn `json:"columns,omitempty"`
+}
+
+type tableManifestColumn struct {
+	Name      string       `json:"name"`
+	Kind      parquet.Kind `json:"kind"`
+	NumValues int64        `json:"num_values"`
+	NullCount int64        `json:"null_count"`
+	// Unsigned is set for unsigned int columns, whose bounds are ordered as
+	// unsigned values.
+	Unsigned bool `json:"unsigned,omitempty"`
+	// Min and Max are the plain encoded bounds of the non-null values of the
+	// column. They are unset if all values are null.
+	Min []byte `json:"min,omitempty"`
+	Max []byte `json:"max,omitempty"`
+}
+
+// StorageWithTableManifest makes the bucket maintain a manifest of the blocks
+// of every table, updated when blocks are persisted or deleted, which scans use
+// instead of listing the bucket and which holds the statistics to prune
+// blocks without reading them. Tables without a manifest, e.g. because their
+// blocks were persisted without this option, are scanned by listing the bucket
+// until a block is persisted and the manifest is created. The manifest
+// assumes a single writer per table.
+func StorageWithTableManifest() DefaultObjstoreBucketOption {
+	return func(b *DefaultObjstoreBucket) {
+		b.tableManifest = true
+	}
+}
+
+// readTableManifest reads the manifest of the table with the given prefix. It
+// returns nil if the table has no manifest.
+func (b *DefaultObjstoreBucket) readTableManifest(ctx context.Context, prefix string) (*tableManifest, error) {
+	rc, err := b.Get(ctx, filepath.Join(prefix, tableManifestFileName))
+	if err != nil {
+		if b.IsObjNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rc.Close()
+
+	manifest := &tableManifest{}
+	if err := json.NewDecoder(rc).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("invalid table manifest: %w", err)
+	}
+	for _, block := range manifest.Blocks {
+		if err := block.validate(); err != nil {
+			return nil, fmt.Errorf("invalid table manifest: block %s: %w", block.ULID, err)
+		}
+	}
+	return manifest, nil
+}
+
+func (b *DefaultObjstoreBucket) uploadTableManifest(ctx context.Context, prefix string, manifest *tableManifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return b.Upload(ctx, filepath.Join(prefix, tableManifestFileName), bytes.NewReader(data))
+}
+
+// updateTableManifest applies fn to the manifest of the table the given block
+// belongs to. If the table has no manifest yet, it is built from the blocks
+// in the bucket first. If the manifest can't be updated, it is deleted so that
+// scans fall back to listing the bucket instead of missing blocks.
+func (b *DefaultObjstoreBucket) updateTableManifest(ctx context.Context, blockDir string, fn func(*tableManifest) error) error {
+	if !b.tableManifest {
+		return nil
+	}
+	prefix := filepath.Dir(strings.TrimSuffix(blockDir, "/"))
+
+	b.tableManifestMtx.Lock()
+	defer b.tableManifestMtx.Unlock()
+
+	err := func() error {
+		manifest, err := b.readTableManifest(ctx, prefix)
+		if err != nil {
+			return err
+		}
+		if manifest == nil {
+			if manifest, err = b.buildTableManifest(ctx, prefix); err != nil {
+				return err
+			}
+		}
+		if err := fn(manifest); err != nil {
+			return err
+		}
+		return b.uploadTableManifest(ctx, prefix, manifest)
+	}()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to update table manifest", "prefix", prefix, "err", err)
+		if deleteErr := b.Delete(ctx, filepath.Join(prefix, tableManifestFileName)); deleteErr != nil && !b.IsObjNotFoundErr(deleteErr) {
+			return fmt.Errorf("%v failed to delete table manifest: %w", err, deleteErr)
+		}
+	}
+	return nil
+}
+
+// buildTableManifest builds the manifest of the table with the given prefix
+// from the blocks in the bucket.
+func (b *DefaultObjstoreBucket) buildTableManifest(ctx context.Context, prefix string) (*tableManifest, error) {
+	blocks, err := b.Blocks(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &tableManifest{}
+	for _, blockDir := range blocks {
+		block, err := b.tableManifestBlock(ctx, blockDir)
+		if err != nil {
+			return nil, fmt.Errorf("block %s: %w", blockDir, err)
+		}
+		manifest.add(block)
+	}
+	return manifest, nil
+}
+
+// tableManifestBlock reads the manifest entry of the given block from its
+// footer.
+func (b *DefaultObjstoreBucket) tableManifestBlock(ctx context.Context, blockDir string) (tableManifestBlock, error) {
+	blockName := filepath.Join(blockDir, blockDataFileName)
+	attribs, err := b.Attributes(ctx, blockName)
+	if err != nil {
+		return tableManifestBlock{}, err
+	}
+	block := tableManifestBlock{
+		ULID: filepath.Base(blockDir),
+		Size: attribs.Size,
+	}
+	if attribs.Size == 0 {
+		return block, nil
+	}
+
+	file, _, err := b.openBlockFile(ctx, blockName, attribs.Size)
+	if err != nil {
+		return tableManifestBlock{}, err
+	}
+	block.NumRows = file.NumRows()
+	columns, err := tableManifestColumns(file)
+	if err != nil {
+		level.Debug(b.logger).Log("msg", "no statistics for block", "block", blockDir, "err", err)
+	}
+	block.Columns = columns
+	return block, nil
+}
+
+// tableManifestColumns returns the statistics of the columns of the given
+// file, aggregated over its row groups.
+func tableManifestColumns(file *parquet.File) ([]tableManifestColumn, error) {
+	fields := file.Schema().Fields()
+	columns := make([]tableManifestColumn, 0, len(fields))
+	for i, field := range fields {
+		if !field.Leaf() {
+			return nil, fmt.Errorf("column %s is not a leaf column", field.Name())
+		}
+		typ := field.Type()
+		col := tableManifestColumn{
+			Name:     field.Name(),
+			Kind:     typ.Kind(),
+			Unsigned: expr.IsUnsigned(typ),
+		}
+		var min, max parquet.Value
+		for _, rg := range file.RowGroups() {
+			chunk := rg.ColumnChunks()[i]
+			index, err := chunk.ColumnIndex()
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", field.Name(), err)
+			}
+			col.NumValues += chunk.NumValues()
+			col.NullCount += expr.NullCount(index)
+			if v := expr.MinOfType(typ, index); !v.IsNull() && (min.IsNull() || typ.Compare(v, min) < 0) {
+				min = v
+			}
+			if v := expr.MaxOfType(typ, index); !v.IsNull() && (max.IsNull() || typ.Compare(v, max) > 0) {
+				max = v
+			}
+		}
+		if !min.IsNull() && !max.IsNull() {
+			col.Min = bytes.Clone(min.Bytes())
+			col.Max = bytes.Clone(max.Bytes())
+		}
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+// add adds the block to the manifest, replacing an existing entry of the same
+// block.
+func (m *tableManifest) add(block tableManifestBlock) {
+	m.remove(block.ULID)
+	m.Blocks = append(m.Blocks, block)
+	sort.Slice(m.Blocks, func(i, j int) bool {
+		return m.Blocks[i].ULID < m.Blocks[j].ULID
+	})
+}
+
+func (m *tableManifest) remove(id string) {
+	for i, block := range m.Blocks {
+		if block.ULID == id {
+			m.Blocks = append(m.Blocks[:i], m.Blocks[i+1:]...)
+			return
+		}
+	}
+}
+
+// indexBlock adds the persisted block in the given directory to the manifest
+// of its table.
+func (b *DefaultObjstoreBucket) indexBlock(ctx context.Context, blockDir string) error {
+	return b.updateTableManifest(ctx, blockDir, func(m *tableManifest) error {
+		block, err := b.tableManifestBlock(ctx, blockDir)
+		if err != nil {
+			return err
+		}
+		m.add(block)
+		return nil
+	})
+}
+
+// DeleteBlock deletes the block in the given directory and removes it from the
+// manifest of its table and the catalog of the bucket. If the bucket has a
+// replica, the block is deleted from the replica in the background.
+func (b *DefaultObjstoreBucket) DeleteBlock(ctx context.Context, blockDir string) error {
+	return b.deleteBlock(ctx, blockDir, "deleted")
+}
+
+// deleteBlock deletes the block like DeleteBlock and audits the deletion for
+// the given reason, see audit.EventBlockDeleted.
+func (b *DefaultObjstoreBucket) deleteBlock(ctx context.Context, blockDir, reason string) error {
+	if err := b.updateTableManifest(ctx, blockDir, func(m *tableManifest) error {
+		m.remove(filepath.Base(blockDir))
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, name := range []string{blockDataFileName, blockManifestFileName} {
+		if err := b.Delete(ctx, filepath.Join(blockDir, name)); err != nil && !b.IsObjNotFoundErr(err) {
+			return fmt.Errorf("delete %s: %w", name, err)
+		}
+	}
+	b.uncatalogBlock(ctx, blockDir)
+	if b.replica != nil {
+		b.replica.enqueue(b, replicationOp{blockDir: blockDir, delete: true})
+	}
+
+	tableDir := filepath.Dir(strings.TrimSuffix(blockDir, "/"))
+	emitAudit(ctx, b.logger, b.auditSinks, audit.Event{
+		Time:     time.Now(),
+		Type:     audit.EventBlockDeleted,
+		Database: filepath.Dir(tableDir),
+		Table:    filepath.Base(tableDir),
+		Attributes: map[string]string{
+			"block":  filepath.Base(strings.TrimSuffix(blockDir, "/")),
+			"reason": reason,
+		},
+	})
+	return nil
+}
+
+// blockIndexer is implemented by sinks that index the blocks persisted to
+// them.
+type blockIndexer interface {
+	indexBlock(ctx context.Context, blockDir string) error
+}
+
+// scanTableManifest scans the blocks listed in the manifest. It returns false
+// if the table has no manifest.
+func (b *DefaultObjstoreBucket) scanTableManifest(ctx context.Context, prefix string, filter expr.TrueNegativeFilter, lastBlockTimestamp uint64, callback func(context.Context, any) error) (bool, error) {
+	manifest, err := b.readTableManifest(ctx, prefix)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to read table manifest, listing blocks", "prefix", prefix, "err", err)
+		return false, nil
+	}
+	if manifest == nil {
+		return false, nil
+	}
+
+	stats := expr.PruningStatsFromContext(ctx)
+	errg := &errgroup.Group{}
+	errg.SetLimit(b.blockReaderLimit)
+	for _, block := range manifest.Blocks {
+		block := block
+		blockUlid, err := ulid.Parse(block.ULID)
+		if err != nil {
+			return true, err
+		}
+		if lastBlockTimestamp != 0 && blockUlid.Time() >= lastBlockTimestamp {
+			// Blocks newer than the last block timestamp are still in memory.
+			if stats != nil {
+				stats.BlocksConsidered.Add(1)
+				stats.BlocksSkipped.Add(1)
+			}
+			continue
+		}
+		if block.Size == 0 {
+			continue
+		}
+		if block.Columns != nil {
+			ok, err := filter.Eval(block.particulate())
+			if err != nil {
+				return true, err
+			}
+			if !ok {
+				if stats != nil {
+					stats.BlocksConsidered.Add(1)
+					stats.BlocksSkipped.Add(1)
+				}
+				continue
+			}
+		}
+		blockDir := filepath.Join(prefix, block.ULID) + "/"
+		errg.Go(func() error {
+			return b.processBlock(ctx, blockDir, block.Size, lastBlockTimestamp, filter, callback)
+		})
+	}
+	return true, errg.Wait()
+}
+
+func (b tableManifestBlock) validate() error {
+	for _, col := range b.Columns {
+		if _, ok := kindTypes[col.Kind]; !ok {
+			return fmt.Errorf("column %s has unsupported kind %v", col.Name, col.Kind)
+		}
+		if col.Unsigned && col.Kind != parquet.Int32 && col.Kind != parquet.Int64 {
+			return fmt.Errorf("column %s of kind %v cannot be unsigned", col.Name, col.Kind)
+		}
+		if size, ok := kindSizes[col.Kind]; ok && !col.allNull() && (len(col.Min) != size || len(col.Max) != size) {
+			return fmt.Errorf("column %s has invalid bounds", col.Name)
+		}
+	}
+	return nil
+}
+
+func (c tableManifestColumn) allNull() bool {
+	return c.NullCount >= c.NumValues
+}
+
+// kindSizes are the sizes of plain encoded values of fixed size kinds.
+var kindSizes = map[parquet.Kind]int{
+	parquet.Boolean: 1,
+	parquet.Int32:   4,
+	parquet.Int64:   8,
+	parquet.Int96:   12,
+	parquet.Float:   4,
+	parquet.Double:  8,
+}
+
+var kindTypes = map[parquet.Kind]parquet.Type{
+	parquet.Boolean:           parquet.BooleanType,
+	parquet.Int32:             parquet.Int32Type,
+	parquet.Int64:             parquet.Int64Type,
+	parquet.Int96:             parquet.Int96Type,
+	parquet.Float:             parquet.FloatType,
+	parquet.Double:            parquet.DoubleType,
+	parquet.ByteArray:         parquet.ByteArrayType,
+	parquet.FixedLenByteArray: parquet.ByteArrayType,
+}
+
+// particulate returns the block as a particulate with a single page per
+// column, so that filters can be evaluated on its statistics.
+func (b tableManifestBlock) particulate() expr.Particulate {
+	group := make(parquet.Group, len(b.Columns))
+	columns := make(map[string]tableManifestColumn, len(b.Columns))
+	for _, col := range b.Columns {
+		node := parquet.Leaf(kindTypes[col.Kind])
+		if col.Unsigned {
+			node = parquet.Uint(8 * kindSizes[col.Kind])
+		}
+		group[col.Name] = parquet.Optional(node)
+		columns[col.Name] = col
+	}
+	schema := parquet.NewSchema("block", group)
+	chunks := make([]parquet.ColumnChunk, 0, len(b.Columns))
+	for i, field := range schema.Fields() {
+		chunks = append(chunks, &manifestColumnChunk{
+			column: i,
+			typ:    field.Type(),
+			stats:  columns[field.Name()],
+		})
+	}
+	return &manifestParticulate{schema: schema, chunks: chunks}
+}
+
+type manifestParticulate struct {
+	schema *parquet.Schema
+	chunks []parquet.ColumnChunk
+}
+
+func (p *manifestParticulate) Schema() *parquet.Schema { return p.schema }
+
+func (p *manifestParticulate) ColumnChunks() []parquet.ColumnChunk { return p.chunks }
+
+// manifestColumnChunk is a column chunk exposing the statistics of a column
+// of a block. It has no pages.
+type manifestColumnChunk struct {
+	column int
+	typ    parquet.Type
+	stats  tableManifestColumn
+}
+
+func (c *manifestColumnChunk) Type() parquet.Type { return c.typ }
+
+func (c *manifestColumnChunk) Column() int { return c.column }
+
+func (c *manifestColumnChunk) Pages() parquet.Pages { return nil }
+
+func (c *manifestColumnChunk) ColumnIndex() (parquet.ColumnIndex, error) {
+	return parquet.NewColumnIndex(c.stats.Kind, &format.ColumnIndex{
+		NullPages:  []bool{c.stats.allNull()},
+		MinValues:  [][]byte{c.stats.Min},
+		MaxValues:  [][]byte{c.stats.Max},
+		NullCounts: []int64{c.stats.NullCount},
+	}), nil
+}
+
+func (c *manifestColumnChunk) OffsetIndex() (parquet.OffsetIndex, error) {
+	return nil, parquet.ErrMissingOffsetIndex
+}
+
+func (c *manifestColumnChunk) BloomFilter() parquet.BloomFilter { return nil }
+
+func (c *manifestColumnChunk) NumValues() int64 { return c.stats.NumValues }
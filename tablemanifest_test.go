@@ -0,0 +1,166 @@
+package frostdb
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/polarsignals/frostdb/dynparquet"
+	schemapb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/schema/v1alpha1"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/expr"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+// listingBucket counts the listings of the bucket.
+type listingBucket struct {
+	objstore.Bucket
+	iters atomic.Int64
+}
+
+func (b *listingBucket) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	b.iters.Add(1)
+	return b.Bucket.Iter(ctx, dir, f, options...)
+}
+
+func TestTableManifest(t *testing.T) {
+	ctx := context.Background()
+	bucket := &listingBucket{Bucket: objstore.NewInMemBucket()}
+	storage := NewDefaultObjstoreBucket(bucket, StorageWithTableManifest())
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithReadWriteStorage(storage),
+		WithManualBlockRotation(),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(dynparquet.SampleDefinition()))
+	require.NoError(t, err)
+
+	r, err := dynparquet.NewTestSamples().ToRecord()
+	require.NoError(t, err)
+	defer r.Release()
+	writeTx, err := table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	blockDir := filepath.Join("test", "test", table.ActiveBlock().ulid.String())
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	db.Wait(writeTx + 2)
+
+	rc, err := bucket.Get(ctx, filepath.Join("test", "test", tableManifestFileName))
+	require.NoError(t, err)
+	manifest := &tableManifest{}
+	require.NoError(t, json.NewDecoder(rc).Decode(manifest))
+	require.NoError(t, rc.Close())
+	require.Len(t, manifest.Blocks, 1)
+	require.Equal(t, filepath.Base(blockDir), manifest.Blocks[0].ULID)
+	require.Equal(t, int64(3), manifest.Blocks[0].NumRows)
+	require.NotEmpty(t, manifest.Blocks[0].Columns)
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	rows := func(filter logicalplan.Expr, stats *expr.PruningStats) int64 {
+		var n int64
+		require.NoError(t, engine.ScanTable("test").Filter(filter).Execute(
+			expr.WithPruningStats(ctx, stats),
+			func(_ context.Context, r arrow.Record) error {
+				n += r.NumRows()
+				return nil
+			},
+		))
+		return n
+	}
+
+	bucket.iters.Store(0)
+	stats := &expr.PruningStats{}
+	require.Equal(t, int64(1), rows(logicalplan.Col("value").Eq(logicalplan.Literal(int64(5))), stats))
+	require.Equal(t, int64(0), stats.BlocksSkipped.Load())
+	require.Zero(t, bucket.iters.Load())
+
+	stats = &expr.PruningStats{}
+	require.Zero(t, rows(logicalplan.Col("value").Gt(logicalplan.Literal(int64(100))), stats))
+	require.Equal(t, int64(1), stats.BlocksConsidered.Load())
+	require.Equal(t, int64(1), stats.BlocksSkipped.Load())
+	require.Zero(t, stats.RowGroupsConsidered.Load())
+
+	stats = &expr.PruningStats{}
+	require.Zero(t, rows(logicalplan.Col("labels.namespace").Eq(logicalplan.Literal("prod")), stats))
+	require.Equal(t, int64(1), stats.BlocksSkipped.Load())
+
+	require.NoError(t, storage.DeleteBlock(ctx, blockDir))
+	require.Zero(t, rows(logicalplan.Col("value").Eq(logicalplan.Literal(int64(5))), &expr.PruningStats{}))
+	require.Zero(t, bucket.iters.Load())
+}
+
+func TestTableManifestUnsigned(t *testing.T) {
+	ctx := context.Background()
+	storage := NewDefaultObjstoreBucket(objstore.NewInMemBucket(), StorageWithTableManifest())
+	c, err := New(
+		WithLogger(newTestLogger(t)),
+		WithReadWriteStorage(storage),
+		WithManualBlockRotation(),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	db, err := c.DB(ctx, "test")
+	require.NoError(t, err)
+	table, err := db.Table("test", NewTableConfig(&schemapb.Schema{
+		Name: "test",
+		Columns: []*schemapb.Column{{
+			Name: "hash",
+			StorageLayout: &schemapb.StorageLayout{
+				Type: schemapb.StorageLayout_TYPE_UINT64,
+			},
+		}},
+		SortingColumns: []*schemapb.SortingColumn{{
+			Name:      "hash",
+			Direction: schemapb.SortingColumn_DIRECTION_ASCENDING,
+		}},
+	}))
+	require.NoError(t, err)
+
+	// The values straddle the sign bit, so the block would be pruned if its
+	// bounds were compared as signed values.
+	b := array.NewRecordBuilder(memory.DefaultAllocator, arrow.NewSchema([]arrow.Field{
+		{Name: "hash", Type: arrow.PrimitiveTypes.Uint64},
+	}, nil))
+	defer b.Release()
+	b.Field(0).(*array.Uint64Builder).AppendValues([]uint64{1, 1 << 63}, nil)
+	r := b.NewRecord()
+	defer r.Release()
+	writeTx, err := table.InsertRecord(ctx, r)
+	require.NoError(t, err)
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	db.Wait(writeTx + 2)
+
+	engine := query.NewEngine(memory.DefaultAllocator, db.TableProvider())
+	rows := func(filter logicalplan.Expr, stats *expr.PruningStats) int64 {
+		var n int64
+		require.NoError(t, engine.ScanTable("test").Filter(filter).Execute(
+			expr.WithPruningStats(ctx, stats),
+			func(_ context.Context, r arrow.Record) error {
+				n += r.NumRows()
+				return nil
+			},
+		))
+		return n
+	}
+
+	stats := &expr.PruningStats{}
+	require.Equal(t, int64(1), rows(logicalplan.Col("hash").Gt(logicalplan.Literal(uint64(2))), stats))
+	require.Zero(t, stats.BlocksSkipped.Load())
+
+	stats = &expr.PruningStats{}
+	require.Zero(t, rows(logicalplan.Col("hash").Gt(logicalplan.Literal(uint64(1<<63))), stats))
+	require.Equal(t, int64(1), stats.BlocksSkipped.Load())
+}
@@ -16,6 +16,19 @@ type TxPool struct {
 	tail   *atomic.Pointer[TxNode]
 	cancel context.CancelFunc
 	drain  chan interface{}
+	// advanced is called whenever the cleaner advanced the watermark.
+	advanced func()
+}
+
+// TxPoolOption configures a TxPool.
+type TxPoolOption func(*TxPool)
+
+// TxPoolWithWatermarkCallback calls the given function whenever the pool
+// cleaner advanced the watermark.
+func TxPoolWithWatermarkCallback(f func()) TxPoolOption {
+	return func(p *TxPool) {
+		p.advanced = f
+	}
 }
 
 // NewTxPool returns a new TxPool and starts the pool cleaner routine.
@@ -52,7 +65,7 @@ type TxPool struct {
 //
 // TxPool is a sorted lockless linked-list described in
 // https://timharris.uk/papers/2001-disc.pdf
-func NewTxPool(watermark *atomic.Uint64) *TxPool {
+func NewTxPool(watermark *atomic.Uint64, options ...TxPoolOption) *TxPool {
 	tail := &TxNode{
 		next:     &atomic.Pointer[TxNode]{},
 		original: &atomic.Pointer[TxNode]{},
@@ -66,6 +79,9 @@ func NewTxPool(watermark *atomic.Uint64) *TxPool {
 		tail:  &atomic.Pointer[TxNode]{},
 		drain: make(chan interface{}, 1),
 	}
+	for _, option := range options {
+		option(txpool)
+	}
 
 	// [head] -> [tail]
 	head.next.Store(tail)
@@ -190,6 +206,7 @@ func (l *TxPool) cleaner(ctx context.Context, watermark *atomic.Uint64) {
 		case <-ctx.Done():
 			return
 		case <-l.drain:
+			before := watermark.Load()
 			l.delete(func(txn uint64) bool {
 				mark := watermark.Load()
 				switch {
@@ -202,6 +219,9 @@ func (l *TxPool) cleaner(ctx context.Context, watermark *atomic.Uint64) {
 					return false
 				}
 			})
+			if l.advanced != nil && watermark.Load() != before {
+				l.advanced()
+			}
 		}
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
@@ -19,6 +20,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 
+	"github.com/polarsignals/frostdb/encryption"
 	walpb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/wal/v1alpha1"
 )
 
@@ -84,6 +86,8 @@ type FileWAL struct {
 	protected      struct {
 		sync.Mutex
 		queue logRequestQueue
+		// queueBytes is the size of the data of the requests in the queue.
+		queueBytes int64
 		// truncateTx is set when the caller wishes to perform a truncation. The
 		// WAL will keep on logging records up to and including this txn and
 		// then perform a truncation. If another truncate call occurs in the
@@ -104,6 +108,20 @@ type FileWAL struct {
 	cancel       func()
 	shutdownCh   chan struct{}
 	closeTimeout time.Duration
+
+	// keys is set if WAL records should be encrypted at rest.
+	keys encryption.KeyProvider
+}
+
+type Option func(*FileWAL)
+
+// WithEncryption encrypts WAL records with keys provided by the given
+// KeyProvider. Unencrypted records written before encryption was enabled can
+// still be replayed.
+func WithEncryption(keys encryption.KeyProvider) Option {
+	return func(w *FileWAL) {
+		w.keys = keys
+	}
 }
 
 type logRequest struct {
@@ -141,6 +159,7 @@ func Open(
 	logger log.Logger,
 	reg prometheus.Registerer,
 	path string,
+	opts ...Option,
 ) (*FileWAL, error) {
 	if err := os.MkdirAll(path, dirPerms); err != nil {
 		return nil, err
@@ -206,6 +225,10 @@ func Open(
 		shutdownCh:  make(chan struct{}),
 	}
 
+	for _, opt := range opts {
+		opt(w)
+	}
+
 	w.protected.nextTx = lastIndex + 1
 
 	return w, nil
@@ -275,8 +298,7 @@ func (w *FileWAL) run(ctx context.Context) {
 							"expected", w.protected.nextTx,
 							"found", minTx,
 						)
-						w.logRequestPool.Put(heap.Pop(&w.protected.queue))
-						w.metrics.walQueueSize.Sub(1)
+						w.logRequestPool.Put(w.popRequest())
 						// Keep on going since there might be other transactions
 						// below this one.
 						continue
@@ -292,8 +314,7 @@ func (w *FileWAL) run(ctx context.Context) {
 					// Next expected tx has not yet been seen.
 					break
 				}
-				r := heap.Pop(&w.protected.queue).(*logRequest)
-				w.metrics.walQueueSize.Sub(1)
+				r := w.popRequest()
 				batch = append(batch, r)
 				batchSize += len(r.data)
 				w.protected.nextTx++
@@ -357,8 +378,7 @@ func (w *FileWAL) run(ctx context.Context) {
 							if minTx := w.protected.queue[0].tx; minTx >= w.protected.nextTx {
 								break
 							}
-							w.logRequestPool.Put(heap.Pop(&w.protected.queue))
-							w.metrics.walQueueSize.Sub(1)
+							w.logRequestPool.Put(w.popRequest())
 						}
 					}
 					w.protected.Unlock()
@@ -401,7 +421,7 @@ func (w *FileWAL) Reset(nextTx uint64) error {
 	defer w.protected.Unlock()
 	// Drain any pending records.
 	for w.protected.queue.Len() > 0 {
-		_ = heap.Pop(&w.protected.queue)
+		_ = w.popRequest()
 	}
 	// Set the next expected transaction.
 	w.protected.nextTx = nextTx
@@ -433,15 +453,62 @@ func (w *FileWAL) Log(tx uint64, record *walpb.Record) error {
 	if err != nil {
 		return err
 	}
+	if err := w.encrypt(r); err != nil {
+		return err
+	}
 
 	w.protected.Lock()
+	w.pushRequest(r)
+	w.protected.Unlock()
+
+	return nil
+}
+
+// pushRequest adds the given request to the queue. The protected mutex must be
+// held.
+func (w *FileWAL) pushRequest(r *logRequest) {
 	heap.Push(&w.protected.queue, r)
+	w.protected.queueBytes += int64(len(r.data))
 	w.metrics.walQueueSize.Add(1)
-	w.protected.Unlock()
+}
+
+// popRequest removes the request with the lowest txn from the queue. The
+// protected mutex must be held.
+func (w *FileWAL) popRequest() *logRequest {
+	r := heap.Pop(&w.protected.queue).(*logRequest)
+	w.protected.queueBytes -= int64(len(r.data))
+	w.metrics.walQueueSize.Sub(1)
+	return r
+}
 
+// QueueBytes returns the size of the records waiting to be written.
+func (w *FileWAL) QueueBytes() int64 {
+	w.protected.Lock()
+	defer w.protected.Unlock()
+	return w.protected.queueBytes
+}
+
+// encrypt replaces the data of the given request with its encrypted form if
+// encryption is enabled.
+func (w *FileWAL) encrypt(r *logRequest) error {
+	if w.keys == nil {
+		return nil
+	}
+	data, err := encryption.Encrypt(w.keys, recordEncryptionName(r.tx), r.data)
+	if err != nil {
+		return fmt.Errorf("encrypt WAL record: %w", err)
+	}
+	r.data = data
 	return nil
 }
 
+// recordEncryptionName returns the name the WAL record of the given
+// transaction is encrypted under, so that encrypted records can't be moved to
+// another transaction.
+func recordEncryptionName(tx uint64) string {
+	return "wal/" + strconv.FormatUint(tx, 10)
+}
+
 func (w *FileWAL) getArrowBuf() *bytes.Buffer {
 	return w.arrowBufPool.Get().(*bytes.Buffer)
 }
@@ -504,10 +571,12 @@ func (w *FileWAL) LogRecord(tx uint64, table string, record arrow.Record) error
 	if err != nil {
 		return err
 	}
+	if err := w.encrypt(r); err != nil {
+		return err
+	}
 
 	w.protected.Lock()
-	heap.Push(&w.protected.queue, r)
-	w.metrics.walQueueSize.Add(1)
+	w.pushRequest(r)
 	w.protected.Unlock()
 
 	return nil
@@ -575,8 +644,28 @@ func (w *FileWAL) Replay(tx uint64, handler ReplayHandlerFunc) (err error) {
 			panic(fmt.Sprintf("read index %d: %v", tx, err))
 		}
 
+		data := entry.Data
+		encrypted := encryption.IsEncrypted(data)
+		if encrypted {
+			if w.keys == nil {
+				return fmt.Errorf("WAL record %d is encrypted but no encryption keys were provided", tx)
+			}
+			data, err = encryption.Decrypt(w.keys, recordEncryptionName(tx), data)
+			if err != nil {
+				// The record was read from the log intact, so it is not
+				// corrupt but encrypted with a key that is missing or
+				// wrong, or tampered with. Repairing the WAL would drop it
+				// and every record that follows it.
+				return fmt.Errorf("decrypt WAL record %d: %w", tx, err)
+			}
+		}
+
 		record := &walpb.Record{}
-		if err := record.UnmarshalVT(entry.Data); err != nil {
+		if err := record.UnmarshalVT(data); err != nil {
+			if encrypted {
+				// Authenticated data is not corrupt either.
+				return fmt.Errorf("unmarshal WAL record %d: %w", tx, err)
+			}
 			// Panic since this is most likely a corruption issue. The recover
 			// call above will truncate the WAL to the last valid transaction.
 			panic(fmt.Sprintf("unmarshal WAL record: %v", err))
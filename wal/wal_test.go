@@ -1,6 +1,7 @@
 package wal
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,6 +12,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 
+	"github.com/polarsignals/frostdb/encryption"
 	walpb "github.com/polarsignals/frostdb/gen/proto/go/frostdb/wal/v1alpha1"
 )
 
@@ -78,6 +80,119 @@ func TestWAL(t *testing.T) {
 	defer w.Close()
 }
 
+func TestEncryptedWAL(t *testing.T) {
+	dir := t.TempDir()
+	keys, err := encryption.NewStaticKeyProvider("key1", map[string][]byte{
+		"key1": make([]byte, 32),
+	})
+	require.NoError(t, err)
+
+	// Log an unencrypted record first to verify that records written before
+	// encryption was enabled can still be replayed.
+	w, err := Open(log.NewNopLogger(), prometheus.NewRegistry(), dir)
+	require.NoError(t, err)
+	w.RunAsync()
+	require.NoError(t, w.Log(1, &walpb.Record{
+		Entry: &walpb.Entry{
+			EntryType: &walpb.Entry_Write_{
+				Write: &walpb.Entry_Write{
+					Data:      []byte("plaintext-data"),
+					TableName: "test-table",
+				},
+			},
+		},
+	}))
+	require.NoError(t, w.Close())
+
+	w, err = Open(log.NewNopLogger(), prometheus.NewRegistry(), dir, WithEncryption(keys))
+	require.NoError(t, err)
+	w.RunAsync()
+	require.NoError(t, w.Log(2, &walpb.Record{
+		Entry: &walpb.Entry{
+			EntryType: &walpb.Entry_Write_{
+				Write: &walpb.Entry_Write{
+					Data:      []byte("secret-data"),
+					TableName: "test-table",
+				},
+			},
+		},
+	}))
+	require.NoError(t, w.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	require.NoError(t, err)
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		require.NoError(t, err)
+		require.False(t, bytes.Contains(data, []byte("secret-data")))
+	}
+
+	w, err = Open(log.NewNopLogger(), prometheus.NewRegistry(), dir, WithEncryption(keys))
+	require.NoError(t, err)
+	w.RunAsync()
+	defer w.Close()
+
+	var data []string
+	require.NoError(t, w.Replay(0, func(tx uint64, r *walpb.Record) error {
+		data = append(data, string(r.Entry.GetWrite().Data))
+		return nil
+	}))
+	require.Equal(t, []string{"plaintext-data", "secret-data"}, data)
+}
+
+func TestEncryptedWALWrongKey(t *testing.T) {
+	dir := t.TempDir()
+	keys, err := encryption.NewStaticKeyProvider("key1", map[string][]byte{
+		"key1": make([]byte, 32),
+	})
+	require.NoError(t, err)
+
+	w, err := Open(log.NewNopLogger(), prometheus.NewRegistry(), dir, WithEncryption(keys))
+	require.NoError(t, err)
+	w.RunAsync()
+	for tx := uint64(1); tx <= 2; tx++ {
+		require.NoError(t, w.Log(tx, &walpb.Record{
+			Entry: &walpb.Entry{
+				EntryType: &walpb.Entry_Write_{
+					Write: &walpb.Entry_Write{
+						Data:      []byte("secret-data"),
+						TableName: "test-table",
+					},
+				},
+			},
+		}))
+	}
+	require.NoError(t, w.Close())
+
+	// A key with the same id that differs fails authentication. The WAL is
+	// not repaired, since the records are not corrupt.
+	wrongKey := bytes.Repeat([]byte{1}, 32)
+	wrongKeys, err := encryption.NewStaticKeyProvider("key1", map[string][]byte{
+		"key1": wrongKey,
+	})
+	require.NoError(t, err)
+	w, err = Open(log.NewNopLogger(), prometheus.NewRegistry(), dir, WithEncryption(wrongKeys))
+	require.NoError(t, err)
+	w.RunAsync()
+	err = w.Replay(0, func(uint64, *walpb.Record) error { return nil })
+	require.ErrorIs(t, err, encryption.ErrAuthentication)
+	lastIdx, err := w.LastIndex()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), lastIdx)
+	require.NoError(t, w.Close())
+
+	w, err = Open(log.NewNopLogger(), prometheus.NewRegistry(), dir, WithEncryption(keys))
+	require.NoError(t, err)
+	w.RunAsync()
+	defer w.Close()
+	replayed := 0
+	require.NoError(t, w.Replay(0, func(uint64, *walpb.Record) error {
+		replayed++
+		return nil
+	}))
+	require.Equal(t, 2, replayed)
+}
+
 func TestCorruptWAL(t *testing.T) {
 	path := t.TempDir()
 
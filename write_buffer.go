@@ -0,0 +1,67 @@
+package frostdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WithWriteBuffer stages inserts in a write buffer of the active block of each
+// table instead of adding them to its index one by one. The staged records are
+// sorted and merged into the index in batches in the background, every
+// flushInterval or as soon as the staged records of a block exceed flushSize
+// bytes if flushSize is greater than 0. This takes the cost of maintaining the
+// index off the latency of inserts. Staged records are read by queries like
+// the records in the index.
+func WithWriteBuffer(flushInterval time.Duration, flushSize int64) Option {
+	return func(s *ColumnStore) error {
+		if flushInterval <= 0 {
+			return fmt.Errorf("write buffer flush interval must be positive, got %s", flushInterval)
+		}
+		s.writeBufferInterval = flushInterval
+		s.writeBufferSize = flushSize
+		return nil
+	}
+}
+
+// requestWriteFlush requests a flush of the write buffers of the tables
+// without waiting for the next flush interval.
+func (db *DB) requestWriteFlush() {
+	select {
+	case db.flushRequests <- struct{}{}:
+	default:
+	}
+}
+
+// flushWriteBuffers flushes the write buffers of the tables at the given
+// interval and when requested until the context is done.
+func (db *DB) flushWriteBuffers(ctx context.Context, interval time.Duration) {
+	ticker := db.columnStore.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		case <-db.flushRequests:
+		}
+
+		db.mtx.RLock()
+		tables := make([]*Table, 0, len(db.tables))
+		for _, table := range db.tables {
+			tables = append(tables, table)
+		}
+		db.mtx.RUnlock()
+
+		// Only the staged records of completed transactions are flushed,
+		// see index.LSM.Flush.
+		watermark := db.HighWatermark()
+		for _, table := range tables {
+			blocks, _ := table.memoryBlocks()
+			for _, block := range blocks {
+				block.index.Flush(watermark)
+				block.pendingReadersWg.Done()
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package frostdb
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/polarsignals/frostdb/clock"
+	"github.com/polarsignals/frostdb/index"
+	"github.com/polarsignals/frostdb/query"
+	"github.com/polarsignals/frostdb/query/logicalplan"
+)
+
+func TestWriteBuffer(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewManual(time.Unix(0, 0))
+	c, table := basicTable(t,
+		WithClock(clk),
+		WithWriteBuffer(time.Second, 0),
+		WithReadWriteStorage(NewDefaultObjstoreBucket(objstore.NewInMemBucket())),
+	)
+	defer c.Close()
+
+	rows := func() int64 {
+		var rows int64
+		require.NoError(t, query.NewEngine(memory.DefaultAllocator, table.db.TableProvider()).
+			ScanTable("test").
+			Execute(ctx, func(_ context.Context, r arrow.Record) error {
+				rows += r.NumRows()
+				return nil
+			}))
+		return rows
+	}
+	numParts := func() int {
+		n := 0
+		table.ActiveBlock().Index().Iterate(func(node *index.Node) bool {
+			if node.Part() != nil {
+				n++
+			}
+			return true
+		})
+		return n
+	}
+
+	insertSampleRecords(ctx, t, table, 3, 1)
+	insertSampleRecords(ctx, t, table, 2)
+	insertSampleRecords(ctx, t, table, 4)
+
+	// The inserts are staged but read by queries.
+	require.Zero(t, numParts())
+	require.Greater(t, table.ActiveBlock().Index().StagedSize(), int64(0))
+	require.Equal(t, int64(4), rows())
+
+	// The staged records are merged into a part per transaction on flush.
+	require.Eventually(t, func() bool {
+		clk.Advance(time.Second)
+		return table.ActiveBlock().Index().StagedSize() == 0
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, 3, numParts())
+	require.Equal(t, int64(4), rows())
+
+	// Staged records are flushed before the block is persisted.
+	insertSampleRecords(ctx, t, table, 5)
+	require.NoError(t, table.RotateBlock(ctx, table.ActiveBlock(), false))
+	table.blockWrites.Wait()
+	require.Equal(t, int64(5), rows())
+}
+
+// TestWriteBufferOlderRead checks that a read at a transaction older than some
+// of the flushed records still reads the records it read before the flush.
+func TestWriteBufferOlderRead(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewManual(time.Unix(0, 0))
+	c, table := basicTable(t, WithClock(clk), WithWriteBuffer(time.Second, 0))
+	defer c.Close()
+
+	rows := func(tx uint64) int64 {
+		var rows int64
+		require.NoError(t, table.Iterator(ctx, tx, memory.DefaultAllocator, []logicalplan.Callback{
+			func(_ context.Context, r arrow.Record) error {
+				atomic.AddInt64(&rows, r.NumRows())
+				return nil
+			},
+		}))
+		return rows
+	}
+
+	tx := insertSampleRecords(ctx, t, table, 1)
+	newer := insertSampleRecords(ctx, t, table, 2, 3)
+	require.Equal(t, int64(1), rows(tx))
+	require.Equal(t, int64(3), rows(newer))
+
+	// The flush changes neither the records a read at an older transaction
+	// reads nor those of the newer one.
+	require.Eventually(t, func() bool {
+		clk.Advance(time.Second)
+		return table.ActiveBlock().Index().StagedSize() == 0
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, int64(1), rows(tx))
+	require.Equal(t, int64(3), rows(newer))
+}
+
+func TestWriteBufferFlushSize(t *testing.T) {
+	ctx := context.Background()
+	c, table := basicTable(t, WithWriteBuffer(time.Hour, 1))
+	defer c.Close()
+
+	insertSampleRecords(ctx, t, table, 1, 2)
+	require.Eventually(t, func() bool {
+		return table.ActiveBlock().Index().StagedSize() == 0
+	}, time.Second, 10*time.Millisecond)
+}